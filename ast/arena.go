@@ -0,0 +1,396 @@
+package ast
+
+const (
+	// minimum and maximum number of nodes of a given type allocated at once by an arena
+	arenaMinChunkSize = 4
+	arenaMaxChunkSize = 64
+)
+
+// Arena allocates AST nodes from slabs, instead of one by one.
+//
+// All nodes of a template are then held by a few large objects, reducing GC scanning cost for applications that keep lots of parsed templates in memory. Slabs are released all together, when no node they contain is referenced anymore.
+//
+// The zero value is ready to use, and a nil *Arena allocates nodes individually. An arena is not safe for concurrent use.
+type Arena struct {
+	programs    []Program
+	mustaches   []MustacheStatement
+	blocks      []BlockStatement
+	partials    []PartialStatement
+	contents    []ContentStatement
+	comments    []CommentStatement
+	exprs       []Expression
+	subExprs    []SubExpression
+	paths       []PathExpression
+	strings     []StringLiteral
+	booleans    []BooleanLiteral
+	numbers     []NumberLiteral
+	hashes      []Hash
+	hashPairs   []HashPair
+	strips      []Strip
+	allocations int
+}
+
+// NewArena instanciates a new arena.
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Len returns the number of nodes allocated by arena.
+func (a *Arena) Len() int {
+	if a == nil {
+		return 0
+	}
+
+	return a.allocations
+}
+
+// NewProgram instanciates a new program node.
+func (a *Arena) NewProgram(pos int, line int) *Program {
+	if a == nil {
+		return NewProgram(pos, line)
+	}
+
+	if len(a.programs) == cap(a.programs) {
+		a.programs = make([]Program, 0, nextChunkSize(cap(a.programs)))
+	}
+
+	a.allocations++
+	a.programs = append(a.programs, Program{
+		NodeType: NodeProgram,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.programs[len(a.programs)-1]
+}
+
+// NewMustacheStatement instanciates a new mustache node.
+func (a *Arena) NewMustacheStatement(pos int, line int, unescaped bool) *MustacheStatement {
+	if a == nil {
+		return NewMustacheStatement(pos, line, unescaped)
+	}
+
+	if len(a.mustaches) == cap(a.mustaches) {
+		a.mustaches = make([]MustacheStatement, 0, nextChunkSize(cap(a.mustaches)))
+	}
+
+	a.allocations++
+	a.mustaches = append(a.mustaches, MustacheStatement{
+		NodeType:  NodeMustache,
+		Loc:       Loc{pos, line},
+		Unescaped: unescaped,
+	})
+
+	return &a.mustaches[len(a.mustaches)-1]
+}
+
+// NewBlockStatement instanciates a new block node.
+func (a *Arena) NewBlockStatement(pos int, line int) *BlockStatement {
+	if a == nil {
+		return NewBlockStatement(pos, line)
+	}
+
+	if len(a.blocks) == cap(a.blocks) {
+		a.blocks = make([]BlockStatement, 0, nextChunkSize(cap(a.blocks)))
+	}
+
+	a.allocations++
+	a.blocks = append(a.blocks, BlockStatement{
+		NodeType: NodeBlock,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.blocks[len(a.blocks)-1]
+}
+
+// NewPartialStatement instanciates a new partial node.
+func (a *Arena) NewPartialStatement(pos int, line int) *PartialStatement {
+	if a == nil {
+		return NewPartialStatement(pos, line)
+	}
+
+	if len(a.partials) == cap(a.partials) {
+		a.partials = make([]PartialStatement, 0, nextChunkSize(cap(a.partials)))
+	}
+
+	a.allocations++
+	a.partials = append(a.partials, PartialStatement{
+		NodeType: NodePartial,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.partials[len(a.partials)-1]
+}
+
+// NewContentStatement instanciates a new content node.
+func (a *Arena) NewContentStatement(pos int, line int, val string) *ContentStatement {
+	if a == nil {
+		return NewContentStatement(pos, line, val)
+	}
+
+	if len(a.contents) == cap(a.contents) {
+		a.contents = make([]ContentStatement, 0, nextChunkSize(cap(a.contents)))
+	}
+
+	a.allocations++
+	a.contents = append(a.contents, ContentStatement{
+		NodeType: NodeContent,
+		Loc:      Loc{pos, line},
+
+		Value:    val,
+		Original: val,
+	})
+
+	return &a.contents[len(a.contents)-1]
+}
+
+// NewCommentStatement instanciates a new comment node.
+func (a *Arena) NewCommentStatement(pos int, line int, val string) *CommentStatement {
+	if a == nil {
+		return NewCommentStatement(pos, line, val)
+	}
+
+	if len(a.comments) == cap(a.comments) {
+		a.comments = make([]CommentStatement, 0, nextChunkSize(cap(a.comments)))
+	}
+
+	a.allocations++
+	a.comments = append(a.comments, CommentStatement{
+		NodeType: NodeComment,
+		Loc:      Loc{pos, line},
+
+		Value: val,
+	})
+
+	return &a.comments[len(a.comments)-1]
+}
+
+// NewExpression instanciates a new expression node.
+func (a *Arena) NewExpression(pos int, line int) *Expression {
+	if a == nil {
+		return NewExpression(pos, line)
+	}
+
+	if len(a.exprs) == cap(a.exprs) {
+		a.exprs = make([]Expression, 0, nextChunkSize(cap(a.exprs)))
+	}
+
+	a.allocations++
+	a.exprs = append(a.exprs, Expression{
+		NodeType: NodeExpression,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.exprs[len(a.exprs)-1]
+}
+
+// NewSubExpression instanciates a new subexpression node.
+func (a *Arena) NewSubExpression(pos int, line int) *SubExpression {
+	if a == nil {
+		return NewSubExpression(pos, line)
+	}
+
+	if len(a.subExprs) == cap(a.subExprs) {
+		a.subExprs = make([]SubExpression, 0, nextChunkSize(cap(a.subExprs)))
+	}
+
+	a.allocations++
+	a.subExprs = append(a.subExprs, SubExpression{
+		NodeType: NodeSubExpression,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.subExprs[len(a.subExprs)-1]
+}
+
+// NewPathExpression instanciates a new path expression node.
+func (a *Arena) NewPathExpression(pos int, line int, data bool) *PathExpression {
+	if a == nil {
+		return NewPathExpression(pos, line, data)
+	}
+
+	if len(a.paths) == cap(a.paths) {
+		a.paths = make([]PathExpression, 0, nextChunkSize(cap(a.paths)))
+	}
+
+	a.allocations++
+	a.paths = append(a.paths, PathExpression{
+		NodeType: NodePath,
+		Loc:      Loc{pos, line},
+
+		Data: data,
+	})
+
+	result := &a.paths[len(a.paths)-1]
+	if data {
+		result.Original = "@"
+	}
+
+	return result
+}
+
+// NewStringLiteral instanciates a new string node.
+func (a *Arena) NewStringLiteral(pos int, line int, val string) *StringLiteral {
+	if a == nil {
+		return NewStringLiteral(pos, line, val)
+	}
+
+	if len(a.strings) == cap(a.strings) {
+		a.strings = make([]StringLiteral, 0, nextChunkSize(cap(a.strings)))
+	}
+
+	a.allocations++
+	a.strings = append(a.strings, StringLiteral{
+		NodeType: NodeString,
+		Loc:      Loc{pos, line},
+
+		Value: val,
+	})
+
+	return &a.strings[len(a.strings)-1]
+}
+
+// NewBooleanLiteral instanciates a new boolean node.
+func (a *Arena) NewBooleanLiteral(pos int, line int, val bool, original string) *BooleanLiteral {
+	if a == nil {
+		return NewBooleanLiteral(pos, line, val, original)
+	}
+
+	if len(a.booleans) == cap(a.booleans) {
+		a.booleans = make([]BooleanLiteral, 0, nextChunkSize(cap(a.booleans)))
+	}
+
+	a.allocations++
+	a.booleans = append(a.booleans, BooleanLiteral{
+		NodeType: NodeBoolean,
+		Loc:      Loc{pos, line},
+
+		Value:    val,
+		Original: original,
+	})
+
+	return &a.booleans[len(a.booleans)-1]
+}
+
+// NewNumberLiteral instanciates a new number node.
+func (a *Arena) NewNumberLiteral(pos int, line int, val float64, isInt bool, original string) *NumberLiteral {
+	if a == nil {
+		return NewNumberLiteral(pos, line, val, isInt, original)
+	}
+
+	if len(a.numbers) == cap(a.numbers) {
+		a.numbers = make([]NumberLiteral, 0, nextChunkSize(cap(a.numbers)))
+	}
+
+	a.allocations++
+	a.numbers = append(a.numbers, NumberLiteral{
+		NodeType: NodeNumber,
+		Loc:      Loc{pos, line},
+
+		Value:    val,
+		IsInt:    isInt,
+		Original: original,
+	})
+
+	return &a.numbers[len(a.numbers)-1]
+}
+
+// NewHash instanciates a new hash node.
+func (a *Arena) NewHash(pos int, line int) *Hash {
+	if a == nil {
+		return NewHash(pos, line)
+	}
+
+	if len(a.hashes) == cap(a.hashes) {
+		a.hashes = make([]Hash, 0, nextChunkSize(cap(a.hashes)))
+	}
+
+	a.allocations++
+	a.hashes = append(a.hashes, Hash{
+		NodeType: NodeHash,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.hashes[len(a.hashes)-1]
+}
+
+// NewHashPair instanciates a new hash pair node.
+func (a *Arena) NewHashPair(pos int, line int) *HashPair {
+	if a == nil {
+		return NewHashPair(pos, line)
+	}
+
+	if len(a.hashPairs) == cap(a.hashPairs) {
+		a.hashPairs = make([]HashPair, 0, nextChunkSize(cap(a.hashPairs)))
+	}
+
+	a.allocations++
+	a.hashPairs = append(a.hashPairs, HashPair{
+		NodeType: NodeHashPair,
+		Loc:      Loc{pos, line},
+	})
+
+	return &a.hashPairs[len(a.hashPairs)-1]
+}
+
+// NewStrip instanciates a Strip for given open and close mustaches.
+func (a *Arena) NewStrip(openStr, closeStr string) *Strip {
+	if a == nil {
+		return NewStrip(openStr, closeStr)
+	}
+
+	result := a.newStrip()
+	result.Open = (len(openStr) > 2) && openStr[2] == '~'
+	result.Close = (len(closeStr) > 2) && closeStr[len(closeStr)-3] == '~'
+
+	return result
+}
+
+// NewStripForStr instanciates a Strip for given tag.
+func (a *Arena) NewStripForStr(str string) *Strip {
+	if a == nil {
+		return NewStripForStr(str)
+	}
+
+	result := a.newStrip()
+	result.Open = (len(str) > 2) && str[2] == '~'
+	result.Close = (len(str) > 2) && str[len(str)-3] == '~'
+
+	return result
+}
+
+// NewStripFlags instanciates a Strip for given strip flags of open and close mustaches.
+func (a *Arena) NewStripFlags(open bool, close bool) *Strip {
+	if a == nil {
+		return NewStripFlags(open, close)
+	}
+
+	result := a.newStrip()
+	result.Open = open
+	result.Close = close
+
+	return result
+}
+
+// nextChunkSize returns the number of nodes to allocate after a chunk of given size, so that small templates do not waste memory
+func nextChunkSize(size int) int {
+	switch {
+	case size < arenaMinChunkSize:
+		return arenaMinChunkSize
+	case size >= arenaMaxChunkSize:
+		return arenaMaxChunkSize
+	default:
+		return size * 2
+	}
+}
+
+// newStrip allocates an empty Strip
+func (a *Arena) newStrip() *Strip {
+	if len(a.strips) == cap(a.strips) {
+		a.strips = make([]Strip, 0, nextChunkSize(cap(a.strips)))
+	}
+
+	a.allocations++
+	a.strips = append(a.strips, Strip{})
+
+	return &a.strips[len(a.strips)-1]
+}
@@ -0,0 +1,583 @@
+package ast
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// binaryMagic prefixes binary encoded programs, and its last byte is the format version
+const binaryMagic = "hbs\x01"
+
+// binaryNil encodes a nil node, instead of a node type
+const binaryNil = 0xff
+
+// strip flags
+const (
+	stripPresent = 1 << iota
+	stripOpen
+	stripClose
+	stripOpenStandalone
+	stripCloseStandalone
+	stripInlineStandalone
+)
+
+// ErrInvalidBinary is returned when decoding data that is not a binary encoded program.
+var ErrInvalidBinary = errors.New("invalid binary encoded program")
+
+// binaryEncoder encodes an AST in binary form
+type binaryEncoder struct {
+	buf []byte
+
+	// strings table, and index of each string in that table
+	strings []string
+	indexes map[string]int
+}
+
+// EncodeBinary returns a compact binary form of given AST, that is decoded by DecodeBinary() without lexing nor parsing.
+//
+// Strings are stored once, in a table referenced by nodes. The format is versioned, and is only guaranteed to be decoded by the same raymond version.
+func EncodeBinary(program *Program) []byte {
+	e := &binaryEncoder{indexes: make(map[string]int)}
+	e.program(program)
+
+	body := e.buf
+
+	e.buf = append(make([]byte, 0, len(body)+64), binaryMagic...)
+	e.uint(len(e.strings))
+
+	for _, str := range e.strings {
+		e.uint(len(str))
+		e.buf = append(e.buf, str...)
+	}
+
+	return append(e.buf, body...)
+}
+
+func (e *binaryEncoder) uint(val int) {
+	var scratch [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, scratch[:binary.PutUvarint(scratch[:], uint64(val))]...)
+}
+
+func (e *binaryEncoder) int(val int) {
+	var scratch [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, scratch[:binary.PutVarint(scratch[:], int64(val))]...)
+}
+
+func (e *binaryEncoder) bool(val bool) {
+	if val {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *binaryEncoder) str(val string) {
+	index, ok := e.indexes[val]
+	if !ok {
+		index = len(e.strings)
+		e.strings = append(e.strings, val)
+		e.indexes[val] = index
+	}
+
+	e.uint(index)
+}
+
+func (e *binaryEncoder) strs(vals []string) {
+	e.uint(len(vals))
+
+	for _, val := range vals {
+		e.str(val)
+	}
+}
+
+func (e *binaryEncoder) loc(loc Loc) {
+	e.int(loc.Pos)
+	e.int(loc.Line)
+}
+
+func (e *binaryEncoder) strip(strip *Strip) {
+	if strip == nil {
+		e.buf = append(e.buf, 0)
+		return
+	}
+
+	flags := byte(stripPresent)
+
+	for _, flag := range []struct {
+		set  bool
+		flag byte
+	}{
+		{strip.Open, stripOpen},
+		{strip.Close, stripClose},
+		{strip.OpenStandalone, stripOpenStandalone},
+		{strip.CloseStandalone, stripCloseStandalone},
+		{strip.InlineStandalone, stripInlineStandalone},
+	} {
+		if flag.set {
+			flags |= flag.flag
+		}
+	}
+
+	e.buf = append(e.buf, flags)
+}
+
+func (e *binaryEncoder) nodes(nodes []Node) {
+	e.uint(len(nodes))
+
+	for _, node := range nodes {
+		e.node(node)
+	}
+}
+
+func (e *binaryEncoder) program(node *Program) {
+	if node == nil {
+		e.node(nil)
+	} else {
+		e.node(node)
+	}
+}
+
+func (e *binaryEncoder) expression(node *Expression) {
+	if node == nil {
+		e.node(nil)
+	} else {
+		e.node(node)
+	}
+}
+
+func (e *binaryEncoder) hash(node *Hash) {
+	if node == nil {
+		e.node(nil)
+	} else {
+		e.node(node)
+	}
+}
+
+// node encodes the type of given node followed by its fields
+func (e *binaryEncoder) node(node Node) {
+	if node == nil {
+		e.buf = append(e.buf, binaryNil)
+		return
+	}
+
+	e.buf = append(e.buf, byte(node.Type()))
+	e.loc(node.Location())
+
+	switch n := node.(type) {
+	case *Program:
+		e.nodes(n.Body)
+		e.strs(n.BlockParams)
+		e.bool(n.Chained)
+		e.strip(n.Strip)
+	case *MustacheStatement:
+		e.bool(n.Unescaped)
+		e.expression(n.Expression)
+		e.strip(n.Strip)
+	case *BlockStatement:
+		e.expression(n.Expression)
+		e.program(n.Program)
+		e.program(n.Inverse)
+		e.strip(n.OpenStrip)
+		e.strip(n.InverseStrip)
+		e.strip(n.CloseStrip)
+	case *PartialStatement:
+		e.node(n.Name)
+		e.nodes(n.Params)
+		e.hash(n.Hash)
+		e.strip(n.Strip)
+		e.str(n.Indent)
+	case *ContentStatement:
+		e.str(n.Value)
+		e.str(n.Original)
+		e.bool(n.RightStripped)
+		e.bool(n.LeftStripped)
+	case *CommentStatement:
+		e.str(n.Value)
+		e.strip(n.Strip)
+	case *Expression:
+		e.node(n.Path)
+		e.nodes(n.Params)
+		e.hash(n.Hash)
+	case *SubExpression:
+		e.expression(n.Expression)
+	case *PathExpression:
+		e.str(n.Original)
+		e.int(n.Depth)
+		e.strs(n.Parts)
+		e.bool(n.Data)
+		e.bool(n.Scoped)
+	case *StringLiteral:
+		e.str(n.Value)
+	case *BooleanLiteral:
+		e.bool(n.Value)
+		e.str(n.Original)
+	case *NumberLiteral:
+		var scratch [8]byte
+		binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(n.Value))
+		e.buf = append(e.buf, scratch[:]...)
+		e.bool(n.IsInt)
+		e.str(n.Original)
+	case *Hash:
+		e.uint(len(n.Pairs))
+
+		for _, pair := range n.Pairs {
+			e.node(pair)
+		}
+	case *HashPair:
+		e.str(n.Key)
+		e.node(n.Val)
+	default:
+		panic(fmt.Errorf("Unsupported node: %s", node))
+	}
+}
+
+// binaryDecoder decodes an AST from its binary form
+type binaryDecoder struct {
+	data  []byte
+	pos   int
+	arena *Arena
+
+	strings []string
+}
+
+// DecodeBinary returns the AST encoded by EncodeBinary(), with all nodes allocated from given arena.
+//
+// A nil arena allocates nodes individually. An error is returned if data is truncated, corrupted, or has been encoded by an incompatible raymond version.
+func DecodeBinary(data []byte, arena *Arena) (result *Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != ErrInvalidBinary {
+				panic(r)
+			}
+
+			result, err = nil, ErrInvalidBinary
+		}
+	}()
+
+	if (len(data) < len(binaryMagic)) || (string(data[:len(binaryMagic)]) != binaryMagic) {
+		return nil, ErrInvalidBinary
+	}
+
+	d := &binaryDecoder{
+		data:  data,
+		pos:   len(binaryMagic),
+		arena: arena,
+	}
+
+	d.stringsTable()
+
+	result = d.program()
+	if (result == nil) || (d.pos != len(d.data)) {
+		return nil, ErrInvalidBinary
+	}
+
+	return result, nil
+}
+
+// stringsTable decodes all strings at once, so that they share a single allocation
+func (d *binaryDecoder) stringsTable() {
+	count := d.count()
+
+	bounds := make([]int, 0, 2*count)
+	for i := 0; i < count; i++ {
+		size := d.count()
+		bounds = append(bounds, d.pos, d.pos+size)
+		d.pos += size
+	}
+
+	start := len(binaryMagic)
+	if count > 0 {
+		start = bounds[0]
+	}
+
+	all := string(d.data[start:d.pos])
+
+	d.strings = make([]string, count)
+	for i := range d.strings {
+		d.strings[i] = all[bounds[2*i]-start : bounds[2*i+1]-start]
+	}
+}
+
+func (d *binaryDecoder) byte() byte {
+	if d.pos >= len(d.data) {
+		panic(ErrInvalidBinary)
+	}
+
+	d.pos++
+
+	return d.data[d.pos-1]
+}
+
+func (d *binaryDecoder) uint() uint64 {
+	val, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		panic(ErrInvalidBinary)
+	}
+
+	d.pos += n
+
+	return val
+}
+
+func (d *binaryDecoder) int() int {
+	val, n := binary.Varint(d.data[d.pos:])
+	if (n <= 0) || (val < math.MinInt32) || (val > math.MaxInt32) {
+		panic(ErrInvalidBinary)
+	}
+
+	d.pos += n
+
+	return int(val)
+}
+
+// count decodes a number of elements, that can't exceed the remaining number of bytes as each element is encoded with at least one byte
+func (d *binaryDecoder) count() int {
+	val := d.uint()
+	if val > uint64(len(d.data)-d.pos) {
+		panic(ErrInvalidBinary)
+	}
+
+	return int(val)
+}
+
+func (d *binaryDecoder) bool() bool {
+	switch d.byte() {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		panic(ErrInvalidBinary)
+	}
+}
+
+func (d *binaryDecoder) str() string {
+	index := d.uint()
+	if index >= uint64(len(d.strings)) {
+		panic(ErrInvalidBinary)
+	}
+
+	return d.strings[index]
+}
+
+func (d *binaryDecoder) strs() []string {
+	count := d.count()
+	if count == 0 {
+		return nil
+	}
+
+	result := make([]string, count)
+	for i := range result {
+		result[i] = d.str()
+	}
+
+	return result
+}
+
+func (d *binaryDecoder) strip() *Strip {
+	flags := d.byte()
+	if flags == 0 {
+		return nil
+	}
+
+	if (flags&stripPresent == 0) || (flags >= stripInlineStandalone<<1) {
+		panic(ErrInvalidBinary)
+	}
+
+	var result *Strip
+	if d.arena == nil {
+		result = &Strip{}
+	} else {
+		result = d.arena.newStrip()
+	}
+
+	result.Open = flags&stripOpen != 0
+	result.Close = flags&stripClose != 0
+	result.OpenStandalone = flags&stripOpenStandalone != 0
+	result.CloseStandalone = flags&stripCloseStandalone != 0
+	result.InlineStandalone = flags&stripInlineStandalone != 0
+
+	return result
+}
+
+func (d *binaryDecoder) nodes() []Node {
+	count := d.count()
+	if count == 0 {
+		return nil
+	}
+
+	result := make([]Node, count)
+	for i := range result {
+		if result[i] = d.node(); result[i] == nil {
+			panic(ErrInvalidBinary)
+		}
+	}
+
+	return result
+}
+
+func (d *binaryDecoder) program() *Program {
+	node := d.node()
+	if node == nil {
+		return nil
+	}
+
+	result, ok := node.(*Program)
+	if !ok {
+		panic(ErrInvalidBinary)
+	}
+
+	return result
+}
+
+func (d *binaryDecoder) expression() *Expression {
+	node := d.node()
+	if node == nil {
+		return nil
+	}
+
+	result, ok := node.(*Expression)
+	if !ok {
+		panic(ErrInvalidBinary)
+	}
+
+	return result
+}
+
+func (d *binaryDecoder) hash() *Hash {
+	node := d.node()
+	if node == nil {
+		return nil
+	}
+
+	result, ok := node.(*Hash)
+	if !ok {
+		panic(ErrInvalidBinary)
+	}
+
+	return result
+}
+
+// node decodes a node, or returns nil if a nil node was encoded
+func (d *binaryDecoder) node() Node {
+	kind := d.byte()
+	if kind == binaryNil {
+		return nil
+	}
+
+	pos := d.int()
+	line := d.int()
+
+	switch NodeType(kind) {
+	case NodeProgram:
+		result := d.arena.NewProgram(pos, line)
+		result.Body = d.nodes()
+		result.BlockParams = d.strs()
+		result.Chained = d.bool()
+		result.Strip = d.strip()
+
+		return result
+	case NodeMustache:
+		result := d.arena.NewMustacheStatement(pos, line, d.bool())
+		result.Expression = d.expression()
+		result.Strip = d.strip()
+
+		return result
+	case NodeBlock:
+		result := d.arena.NewBlockStatement(pos, line)
+		result.Expression = d.expression()
+		result.Program = d.program()
+		result.Inverse = d.program()
+		result.OpenStrip = d.strip()
+		result.InverseStrip = d.strip()
+		result.CloseStrip = d.strip()
+
+		return result
+	case NodePartial:
+		result := d.arena.NewPartialStatement(pos, line)
+		result.Name = d.node()
+		result.Params = d.nodes()
+		result.Hash = d.hash()
+		result.Strip = d.strip()
+		result.Indent = d.str()
+
+		return result
+	case NodeContent:
+		result := d.arena.NewContentStatement(pos, line, d.str())
+		result.Original = d.str()
+		result.RightStripped = d.bool()
+		result.LeftStripped = d.bool()
+
+		return result
+	case NodeComment:
+		result := d.arena.NewCommentStatement(pos, line, d.str())
+		result.Strip = d.strip()
+
+		return result
+	case NodeExpression:
+		result := d.arena.NewExpression(pos, line)
+		result.Path = d.node()
+		result.Params = d.nodes()
+		result.Hash = d.hash()
+
+		return result
+	case NodeSubExpression:
+		result := d.arena.NewSubExpression(pos, line)
+		result.Expression = d.expression()
+
+		return result
+	case NodePath:
+		original := d.str()
+		depth := d.int()
+		parts := d.strs()
+
+		result := d.arena.NewPathExpression(pos, line, d.bool())
+		result.Original = original
+		result.Depth = depth
+		result.Parts = parts
+		result.Scoped = d.bool()
+
+		return result
+	case NodeString:
+		return d.arena.NewStringLiteral(pos, line, d.str())
+	case NodeBoolean:
+		val := d.bool()
+
+		return d.arena.NewBooleanLiteral(pos, line, val, d.str())
+	case NodeNumber:
+		if len(d.data)-d.pos < 8 {
+			panic(ErrInvalidBinary)
+		}
+
+		val := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+
+		isInt := d.bool()
+
+		return d.arena.NewNumberLiteral(pos, line, val, isInt, d.str())
+	case NodeHash:
+		result := d.arena.NewHash(pos, line)
+
+		count := d.count()
+		for i := 0; i < count; i++ {
+			pair, ok := d.node().(*HashPair)
+			if !ok {
+				panic(ErrInvalidBinary)
+			}
+
+			result.Pairs = append(result.Pairs, pair)
+		}
+
+		return result
+	case NodeHashPair:
+		result := d.arena.NewHashPair(pos, line)
+		result.Key = d.str()
+		result.Val = d.node()
+
+		return result
+	default:
+		panic(ErrInvalidBinary)
+	}
+}
@@ -0,0 +1,215 @@
+package ast
+
+import (
+	"encoding/json"
+)
+
+// jsonVisitor implements the Visitor interface to convert an AST to values that are marshalled to JSON.
+type jsonVisitor struct{}
+
+// JSON returns the JSON representation of given AST, for debugging and tooling purpose.
+//
+// Nodes are objects with a "type" field set to their handlebars.js name (eg. "MustacheStatement"), a "loc" object with "line" and "pos" fields, and their other fields named like in handlebars.js. Object keys are sorted, so that output is stable.
+func JSON(node Node) ([]byte, error) {
+	return json.Marshal(node.Accept(&jsonVisitor{}))
+}
+
+// jsonObject is the JSON representation of a node
+type jsonObject map[string]interface{}
+
+// object returns a new JSON object for given node, with given handlebars.js type name
+func (v *jsonVisitor) object(node Node, typeName string) jsonObject {
+	loc := node.Location()
+
+	return jsonObject{
+		"type": typeName,
+		"loc":  jsonObject{"line": loc.Line, "pos": loc.Pos},
+	}
+}
+
+// nodes returns the JSON representations of given nodes
+func (v *jsonVisitor) nodes(nodes []Node) []interface{} {
+	result := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		result[i] = node.Accept(v)
+	}
+
+	return result
+}
+
+// optional returns the JSON representation of given node, or nil if node is nil
+func (v *jsonVisitor) optional(node Node) interface{} {
+	switch n := node.(type) {
+	case *Program:
+		if n == nil {
+			return nil
+		}
+	case *Hash:
+		if n == nil {
+			return nil
+		}
+	}
+
+	return node.Accept(v)
+}
+
+// strip returns the JSON representation of given whitespace control, or nil if there is none
+func strip(s *Strip) interface{} {
+	if s == nil {
+		return nil
+	}
+
+	return jsonObject{"open": s.Open, "close": s.Close}
+}
+
+// expression sets the path, params and hash fields of given object
+func (v *jsonVisitor) expression(obj jsonObject, node *Expression) {
+	obj["path"] = node.Path.Accept(v)
+	obj["params"] = v.nodes(node.Params)
+	obj["hash"] = v.optional(node.Hash)
+}
+
+//
+// Visitor interface
+//
+
+// VisitProgram implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitProgram(node *Program) interface{} {
+	result := v.object(node, "Program")
+	result["body"] = v.nodes(node.Body)
+	result["blockParams"] = node.BlockParams
+	result["chained"] = node.Chained
+	result["strip"] = strip(node.Strip)
+
+	return result
+}
+
+// VisitMustache implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitMustache(node *MustacheStatement) interface{} {
+	result := v.object(node, "MustacheStatement")
+	v.expression(result, node.Expression)
+	result["escaped"] = !node.Unescaped
+	result["strip"] = strip(node.Strip)
+
+	return result
+}
+
+// VisitBlock implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitBlock(node *BlockStatement) interface{} {
+	result := v.object(node, "BlockStatement")
+	v.expression(result, node.Expression)
+	result["program"] = v.optional(node.Program)
+	result["inverse"] = v.optional(node.Inverse)
+	result["openStrip"] = strip(node.OpenStrip)
+	result["inverseStrip"] = strip(node.InverseStrip)
+	result["closeStrip"] = strip(node.CloseStrip)
+
+	return result
+}
+
+// VisitPartial implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitPartial(node *PartialStatement) interface{} {
+	result := v.object(node, "PartialStatement")
+	result["name"] = node.Name.Accept(v)
+	result["params"] = v.nodes(node.Params)
+	result["hash"] = v.optional(node.Hash)
+	result["indent"] = node.Indent
+	result["strip"] = strip(node.Strip)
+
+	return result
+}
+
+// VisitContent implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitContent(node *ContentStatement) interface{} {
+	result := v.object(node, "ContentStatement")
+	result["value"] = node.Value
+	result["original"] = node.Original
+
+	return result
+}
+
+// VisitComment implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitComment(node *CommentStatement) interface{} {
+	result := v.object(node, "CommentStatement")
+	result["value"] = node.Value
+	result["strip"] = strip(node.Strip)
+
+	return result
+}
+
+// VisitExpression implements corresponding Visitor interface method
+//
+// Expressions are not handlebars.js nodes: their fields are set on the parent node, except for the root node.
+func (v *jsonVisitor) VisitExpression(node *Expression) interface{} {
+	result := v.object(node, "Expression")
+	v.expression(result, node)
+
+	return result
+}
+
+// VisitSubExpression implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitSubExpression(node *SubExpression) interface{} {
+	result := v.object(node, "SubExpression")
+	v.expression(result, node.Expression)
+
+	return result
+}
+
+// VisitPath implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitPath(node *PathExpression) interface{} {
+	result := v.object(node, "PathExpression")
+	result["original"] = node.Original
+	result["data"] = node.Data
+	result["depth"] = node.Depth
+	result["parts"] = node.Parts
+
+	return result
+}
+
+// VisitString implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitString(node *StringLiteral) interface{} {
+	result := v.object(node, "StringLiteral")
+	result["value"] = node.Value
+
+	return result
+}
+
+// VisitBoolean implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitBoolean(node *BooleanLiteral) interface{} {
+	result := v.object(node, "BooleanLiteral")
+	result["value"] = node.Value
+	result["original"] = node.Original
+
+	return result
+}
+
+// VisitNumber implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitNumber(node *NumberLiteral) interface{} {
+	result := v.object(node, "NumberLiteral")
+	result["value"] = node.Value
+	result["original"] = node.Original
+
+	return result
+}
+
+// VisitHash implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitHash(node *Hash) interface{} {
+	pairs := make([]interface{}, len(node.Pairs))
+	for i, pair := range node.Pairs {
+		pairs[i] = pair.Accept(v)
+	}
+
+	result := v.object(node, "Hash")
+	result["pairs"] = pairs
+
+	return result
+}
+
+// VisitHashPair implements corresponding Visitor interface method
+func (v *jsonVisitor) VisitHashPair(node *HashPair) interface{} {
+	result := v.object(node, "HashPair")
+	result["key"] = node.Key
+	result["value"] = node.Val.Accept(v)
+
+	return result
+}
@@ -0,0 +1,60 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	program, err := parser.Parse("foo {{#if (eq a 1) x=true}}{{{bar.baz}}}{{else}}{{> p}}{{/if}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ast.JSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatal(err)
+	}
+
+	body := root["body"].([]interface{})
+	block := body[1].(map[string]interface{})
+	sexpr := block["params"].([]interface{})[0].(map[string]interface{})
+	pair := block["hash"].(map[string]interface{})["pairs"].([]interface{})[0].(map[string]interface{})
+	mustache := block["program"].(map[string]interface{})["body"].([]interface{})[0].(map[string]interface{})
+	partial := block["inverse"].(map[string]interface{})["body"].([]interface{})[0].(map[string]interface{})
+
+	checks := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"root type", root["type"], "Program"},
+		{"content", body[0].(map[string]interface{})["value"], "foo "},
+		{"block type", block["type"], "BlockStatement"},
+		{"block loc", block["loc"].(map[string]interface{})["pos"], float64(4)},
+		{"block helper", block["path"].(map[string]interface{})["original"], "if"},
+		{"subexpression type", sexpr["type"], "SubExpression"},
+		{"subexpression param", sexpr["params"].([]interface{})[1].(map[string]interface{})["value"], float64(1)},
+		{"hash key", pair["key"], "x"},
+		{"hash value", pair["value"].(map[string]interface{})["value"], true},
+		{"unescaped", mustache["escaped"], false},
+		{"path parts", len(mustache["path"].(map[string]interface{})["parts"].([]interface{})), 2},
+		{"partial type", partial["type"], "PartialStatement"},
+	}
+
+	for _, check := range checks {
+		if check.value != check.expected {
+			t.Errorf("Unexpected %s: %v, expected: %v", check.name, check.value, check.expected)
+		}
+	}
+}
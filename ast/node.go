@@ -3,7 +3,9 @@ package ast
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 // References:
@@ -141,6 +143,14 @@ func NewStripForStr(str string) *Strip {
 	}
 }
 
+// NewStripFlags instanciates a Strip for given strip flags of open and close mustaches, eg. the StripBefore and StripAfter flags of lexer tokens.
+func NewStripFlags(open bool, close bool) *Strip {
+	return &Strip{
+		Open:  open,
+		Close: close,
+	}
+}
+
 // String returns a string representation of receiver that can be used for debugging.
 func (s *Strip) String() string {
 	return fmt.Sprintf("Open: %t, Close: %t, OpenStandalone: %t, CloseStandalone: %t, InlineStandalone: %t", s.Open, s.Close, s.OpenStandalone, s.CloseStandalone, s.InlineStandalone)
@@ -414,6 +424,20 @@ func (node *Expression) HelperName() string {
 	return path.Parts[0]
 }
 
+// NamespacedHelperName returns the dotted name of a namespaced helper (eg: "str.upper" for `{{str.upper name}}`), or an empty string if this expression can't be a namespaced helper.
+func (node *Expression) NamespacedHelperName() string {
+	path, ok := node.Path.(*PathExpression)
+	if !ok {
+		return ""
+	}
+
+	if path.Data || (len(path.Parts) < 2) || (path.Depth > 0) || path.Scoped {
+		return ""
+	}
+
+	return strings.Join(path.Parts, ".")
+}
+
 // FieldPath returns path expression representing a field path, or nil if this is not a field path.
 func (node *Expression) FieldPath() *PathExpression {
 	path, ok := node.Path.(*PathExpression)
@@ -582,7 +606,7 @@ func (node *PathExpression) Sep(separator string) {
 
 // IsDataRoot returns true if path expression is @root.
 func (node *PathExpression) IsDataRoot() bool {
-	return node.Data && (node.Parts[0] == "root")
+	return node.Data && (len(node.Parts) > 0) && (node.Parts[0] == "root")
 }
 
 //
@@ -705,9 +729,10 @@ func (node *NumberLiteral) Canonical() string {
 	return strconv.FormatFloat(node.Value, 'f', prec, 64)
 }
 
-// Number returns an integer or a float.
+// Number returns an integer or a float, for an integer that overflows an int.
 func (node *NumberLiteral) Number() interface{} {
-	if node.IsInt {
+	// float64(math.MaxInt) may be rounded up to a power of two, that overflows
+	if node.IsInt && (node.Value >= math.MinInt) && (node.Value < math.MaxInt) {
 		return int(node.Value)
 	}
 
@@ -0,0 +1,187 @@
+package raymond
+
+import (
+	"sort"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// AuditNode is a statement or expression reported by Audit().
+type AuditNode struct {
+	// Template is the name of the template containing the node
+	Template string
+
+	// Line is the line of the node in template source
+	Line int
+
+	// Name is the helper name, partial name or path of the node, eg. "../title". For a mustache, this is its path or the name of called helper, and for a dynamic partial, the name of the helper computing the partial name.
+	Name string
+}
+
+// AuditReport is the result of Audit(): it lists the nodes of a template set that deserve attention during a security review, in template name order then source order.
+type AuditReport struct {
+	// Unescaped lists the mustaches that output values without escaping them, ie. {{{value}}} and {{& value}}
+	Unescaped []AuditNode
+
+	// Helpers lists the helper calls, ie. expressions with arguments, subexpressions, and paths that resolve to a helper registered globally or on the template
+	Helpers []AuditNode
+
+	// Partials lists the partials referenced by name
+	Partials []AuditNode
+
+	// ParentScopes lists the paths that escape the scope of their block with ../
+	ParentScopes []AuditNode
+
+	// DynamicPartials lists the partials whose name is computed by a subexpression, eg. {{> (whichPartial)}}
+	DynamicPartials []AuditNode
+}
+
+// HelperNames returns the sorted names of all helpers called by the template set.
+func (r *AuditReport) HelperNames() []string {
+	return auditNames(r.Helpers)
+}
+
+// PartialNames returns the sorted names of all partials referenced by name in the template set.
+func (r *AuditReport) PartialNames() []string {
+	return auditNames(r.Partials)
+}
+
+// auditNames returns the sorted unique names of given nodes
+func auditNames(nodes []AuditNode) []string {
+	seen := make(map[string]bool)
+
+	var result []string
+
+	for _, node := range nodes {
+		if !seen[node.Name] {
+			seen[node.Name] = true
+			result = append(result, node.Name)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Audit returns a report of the unescaped outputs, helper calls, partial references, parent scope escapes and dynamic partials of given templates, indexed by name, so that security reviews of large template repositories are mechanical.
+//
+// Templates are parsed if necessary, and are not evaluated. Partial sources are not followed: add partials to given templates to audit them too.
+func Audit(templates map[string]*Template) (*AuditReport, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	result := &AuditReport{}
+
+	for _, name := range names {
+		tpl := templates[name]
+
+		if err := tpl.parse(); err != nil {
+			return nil, err
+		}
+
+		a := &auditor{tpl: tpl, name: name, report: result}
+		a.auditProgram(tpl.program)
+	}
+
+	return result, nil
+}
+
+// auditor walks the program of a template to fill a report
+type auditor struct {
+	tpl    *Template
+	name   string
+	report *AuditReport
+}
+
+// node returns an audit node for given line and name
+func (a *auditor) node(line int, name string) AuditNode {
+	return AuditNode{Template: a.name, Line: line, Name: name}
+}
+
+// auditProgram audits given program and its nested programs
+func (a *auditor) auditProgram(program *ast.Program) {
+	if program == nil {
+		return
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.MustacheStatement:
+			if n.Unescaped {
+				a.report.Unescaped = append(a.report.Unescaped, a.node(n.Line, n.Expression.Canonical()))
+			}
+
+			a.auditExpression(n.Expression, false)
+		case *ast.BlockStatement:
+			a.auditExpression(n.Expression, false)
+			a.auditProgram(n.Program)
+			a.auditProgram(n.Inverse)
+		case *ast.PartialStatement:
+			if name, ok := ast.HelperNameStr(n.Name); ok {
+				a.report.Partials = append(a.report.Partials, a.node(n.Line, name))
+			} else if sub, ok := n.Name.(*ast.SubExpression); ok {
+				a.report.DynamicPartials = append(a.report.DynamicPartials, a.node(n.Line, sub.Expression.Canonical()))
+			}
+
+			a.auditNode(n.Name)
+			a.auditNodes(n.Params)
+			a.auditHash(n.Hash)
+		}
+	}
+}
+
+// auditExpression audits given expression, that is necessarily a helper call if call is true
+func (a *auditor) auditExpression(node *ast.Expression, call bool) {
+	name := node.HelperName()
+	if name == "" {
+		name = node.NamespacedHelperName()
+	}
+
+	if (name != "") && (call || (len(node.Params) > 0) || (node.Hash != nil) || a.isHelper(name)) {
+		a.report.Helpers = append(a.report.Helpers, a.node(node.Line, name))
+	}
+
+	a.auditNode(node.Path)
+	a.auditNodes(node.Params)
+	a.auditHash(node.Hash)
+}
+
+// isHelper returns true if given name resolves to a helper registered on template or globally
+func (a *auditor) isHelper(name string) bool {
+	return (a.tpl.findHelper(name) != zero) || (findHelper(name) != zero)
+}
+
+// auditNodes audits given nodes
+func (a *auditor) auditNodes(nodes []ast.Node) {
+	for _, node := range nodes {
+		a.auditNode(node)
+	}
+}
+
+// auditHash audits the values of given hash
+func (a *auditor) auditHash(node *ast.Hash) {
+	if node == nil {
+		return
+	}
+
+	for _, pair := range node.Pairs {
+		a.auditNode(pair.Val)
+	}
+}
+
+// auditNode audits given path or subexpression
+func (a *auditor) auditNode(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.PathExpression:
+		if n.Depth > 0 {
+			a.report.ParentScopes = append(a.report.ParentScopes, a.node(n.Line, n.Original))
+		}
+	case *ast.SubExpression:
+		a.auditExpression(n.Expression, true)
+	}
+}
@@ -0,0 +1,72 @@
+package raymond
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	page := MustParse(`<h1>{{title}}</h1>
+{{{body}}}
+{{#each posts}}
+  {{> post}}{{upper ../author}}
+  {{& link url}}
+{{/each}}
+{{> (lookup . "footer") name=@../name}}`)
+
+	page.RegisterHelper("upper", func(str string) string { return str })
+
+	post := MustParse(`{{#if visible}}<p>{{format date "2006-01-02"}}</p>{{/if}}`)
+
+	report, err := Audit(map[string]*Template{"page": page, "post": post})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &AuditReport{
+		Unescaped: []AuditNode{
+			{"page", 2, "body"},
+			{"page", 5, "link"},
+		},
+		Helpers: []AuditNode{
+			{"page", 3, "each"},
+			{"page", 4, "upper"},
+			{"page", 5, "link"},
+			{"page", 7, "lookup"},
+			{"post", 1, "if"},
+			{"post", 1, "format"},
+		},
+		Partials: []AuditNode{
+			{"page", 4, "post"},
+		},
+		ParentScopes: []AuditNode{
+			{"page", 4, "../author"},
+			{"page", 7, "@../name"},
+		},
+		DynamicPartials: []AuditNode{
+			{"page", 7, "lookup"},
+		},
+	}
+
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Unexpected report\nexpected:\n\t%+v\ngot:\n\t%+v", expected, report)
+	}
+
+	if names := report.HelperNames(); !reflect.DeepEqual(names, []string{"each", "format", "if", "link", "lookup", "upper"}) {
+		t.Errorf("Unexpected helper names: %q", names)
+	}
+
+	if names := report.PartialNames(); !reflect.DeepEqual(names, []string{"post"}) {
+		t.Errorf("Unexpected partial names: %q", names)
+	}
+}
+
+func TestAuditParseError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Audit(map[string]*Template{"broken": newTemplate("{{#if}}")}); err == nil {
+		t.Errorf("Expected parse error")
+	}
+}
@@ -165,3 +165,10 @@ func launchErrorTests(t *testing.T, tests []Test) {
 		}
 	}
 }
+
+// skipTiny skips test in tiny build mode, as it needs struct fields and methods to be resolved
+func skipTiny(t *testing.T) {
+	if !structReflection {
+		t.Skip("Struct fields and methods are not resolved in tiny build mode")
+	}
+}
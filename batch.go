@@ -0,0 +1,91 @@
+//go:build go1.23
+
+package raymond
+
+import (
+	"fmt"
+	"iter"
+)
+
+// BatchError is the error returned by ExecBatch() when the evaluation of a context fails.
+type BatchError struct {
+	// Index is the index of the context in the sequence
+	Index int
+
+	// Err is the evaluation error
+	Err error
+}
+
+// Error implements the error interface.
+func (err *BatchError) Error() string {
+	return fmt.Sprintf("Evaluation of context %d failed: %s", err.Index, err.Err)
+}
+
+// Unwrap returns the evaluation error.
+func (err *BatchError) Unwrap() error {
+	return err.Err
+}
+
+// ExecBatch evaluates template with each context of given sequence, and calls sink with the index of that context and the output.
+//
+// The output buffer, evaluation stacks and data frames are reused from one context to the next, so that rendering the same template millions of times (eg. for mail merge or reports generation) does not allocate per context. The out slice is only valid during the sink call: copy it to retain it.
+//
+// Iteration stops at the first evaluation error, returned as a *BatchError, or at the first error returned by sink, returned as is.
+func (tpl *Template) ExecBatch(contexts iter.Seq[any], sink func(i int, out []byte) error) error {
+	return tpl.ExecBatchWithOptions(contexts, sink, ExecOptions{})
+}
+
+// ExecBatchWithOptions evaluates template with each context of given sequence and given evaluation options, and calls sink with the index of that context and the output.
+//
+// Options apply to each evaluation: the memory budget is per context, and the outputs of pure partials are memoized for the whole batch when no PartialMemo is set. With Deterministic, the source of randomness is reseeded for each context, so that each output is the one of that context evaluated alone, whereas a Rand source is shared by all contexts.
+func (tpl *Template) ExecBatchWithOptions(contexts iter.Seq[any], sink func(i int, out []byte) error, opts ExecOptions) error {
+	// parses template if necessary
+	if err := tpl.parse(); err != nil {
+		return err
+	}
+
+	buf := getBuffer()
+	defer buf.Release()
+
+	// setup visitor, that is initialized for each context
+	v := newEvalVisitor(tpl, nil, opts.Data)
+	defer v.release()
+
+	v.setOptions(opts)
+
+	i := 0
+	for ctx := range contexts {
+		buf.Reset()
+
+		v.reset()
+		v.init(tpl, ctx, opts.Data)
+
+		if v.budget != nil {
+			v.budget.used = 0
+		}
+
+		if v.usage != nil {
+			v.usage.start()
+		}
+
+		if v.secrets != nil {
+			v.secrets.reset()
+		}
+
+		if opts.Deterministic && (opts.Rand == nil) {
+			v.rand.Seed(DeterministicSeed)
+		}
+
+		if err := v.render(&buf.Buffer); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+
+		if err := sink(i, buf.Bytes()); err != nil {
+			return err
+		}
+
+		i++
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+//go:build go1.23
+
+package raymond
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExecBatch(t *testing.T) {
+	skipTiny(t)
+	t.Parallel()
+
+	tpl := MustParse(`Dear {{name}},{{#each items}} {{@index}}:{{this}}{{/each}}{{#if vip}} VIP{{/if}}`)
+
+	contexts := []any{
+		map[string]interface{}{"name": "Alice", "items": []string{"a", "b"}, "vip": true},
+		map[string]interface{}{"name": "Bob"},
+		struct{ Name string }{"Carol"},
+	}
+
+	var outputs []string
+
+	err := tpl.ExecBatch(slices.Values(contexts), func(i int, out []byte) error {
+		if i != len(outputs) {
+			t.Errorf("Unexpected index %d, expected %d", i, len(outputs))
+		}
+
+		outputs = append(outputs, string(out))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Dear Alice, 0:a 1:b VIP", "Dear Bob,", "Dear Carol,"}
+	if !slices.Equal(outputs, expected) {
+		t.Errorf("Unexpected outputs, expected %q, got %q", expected, outputs)
+	}
+
+	// same outputs as individual evaluations
+	for i, ctx := range contexts {
+		if output := tpl.MustExec(ctx); output != outputs[i] {
+			t.Errorf("Batch output differs from individual output %d: %q", i, output)
+		}
+	}
+}
+
+func TestExecBatchError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{indent value "x"}}`)
+
+	var outputs []string
+
+	err := tpl.ExecBatch(slices.Values([]any{
+		map[string]int{"value": 1},
+		map[string]int{"value": -1},
+		map[string]int{"value": 2},
+	}), func(i int, out []byte) error {
+		outputs = append(outputs, string(out))
+		return nil
+	})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || (batchErr.Index != 1) || !strings.Contains(err.Error(), "indent helper expects a positive number") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !slices.Equal(outputs, []string{" x"}) {
+		t.Errorf("Unexpected outputs: %q", outputs)
+	}
+}
+
+func TestExecBatchSinkError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{this}}`)
+
+	errStop := errors.New("stop")
+	calls := 0
+
+	err := tpl.ExecBatch(slices.Values([]any{1, 2, 3}), func(i int, out []byte) error {
+		calls++
+		return errStop
+	})
+
+	if (err != errStop) || (calls != 1) {
+		t.Errorf("Expected sink error to stop batch, got %v after %d calls", err, calls)
+	}
+}
+
+func TestExecBatchOptions(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+
+	tpl := MustParse(`{{> icon icon}}{{body}}`)
+	tpl.RegisterHelper("count", func(str string) string {
+		calls++
+		return str
+	})
+	tpl.RegisterPurePartial("icon", `[{{count this}}]`)
+
+	contexts := []any{
+		map[string]string{"icon": "x", "body": "12345"},
+		map[string]string{"icon": "x", "body": "1234"},
+		map[string]string{"icon": "x", "body": "1234567890"},
+	}
+
+	var outputs []string
+
+	err := tpl.ExecBatchWithOptions(slices.Values(contexts), func(i int, out []byte) error {
+		outputs = append(outputs, string(out))
+		return nil
+	}, ExecOptions{MemoryBudget: 12})
+
+	// the memory budget applies to each context
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) || (err.(*BatchError).Index != 2) {
+		t.Fatalf("Expected budget to be exceeded by third context, got: %v", err)
+	}
+
+	if !slices.Equal(outputs, []string{"[x]12345", "[x]1234"}) {
+		t.Errorf("Unexpected outputs: %q", outputs)
+	}
+
+	// pure partial outputs are memoized for the whole batch
+	if calls != 1 {
+		t.Errorf("Expected pure partial to be rendered once, got %d renders", calls)
+	}
+}
+
+func TestExecBatchDeterministic(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{name}} {{uuid}} {{randomInt 0 1000000}} {{randomString 8}}`)
+
+	contexts := []any{
+		map[string]string{"name": "a"},
+		map[string]string{"name": "b"},
+	}
+
+	var outputs []string
+
+	err := tpl.ExecBatchWithOptions(slices.Values(contexts), func(i int, out []byte) error {
+		outputs = append(outputs, string(out))
+		return nil
+	}, ExecOptions{Deterministic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each context renders like it does alone
+	for i, ctx := range contexts {
+		output, err := tpl.ExecWithOptions(ctx, ExecOptions{Deterministic: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != outputs[i] {
+			t.Errorf("Batch output %d differs from individual output, expected %q, got %q", i, output, outputs[i])
+		}
+	}
+}
+
+func TestExecBatchAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not reliable with the race detector")
+	}
+
+	tpl := MustParse(`{{#each items}}<li>{{name}}</li>{{/each}}`)
+
+	ctx := map[string]interface{}{
+		"items": []map[string]string{{"name": "a"}, {"name": "b"}},
+	}
+
+	contexts := func(n int) func(yield func(any) bool) {
+		return func(yield func(any) bool) {
+			for i := 0; i < n; i++ {
+				if !yield(ctx) {
+					return
+				}
+			}
+		}
+	}
+
+	sink := func(i int, out []byte) error { return nil }
+
+	small := testing.AllocsPerRun(10, func() { tpl.ExecBatch(contexts(1), sink) })
+	large := testing.AllocsPerRun(10, func() { tpl.ExecBatch(contexts(101), sink) })
+
+	single := testing.AllocsPerRun(10, func() {
+		buf, _ := tpl.ExecBuffer(ctx)
+		buf.Release()
+	})
+
+	// batch does not allocate more than the evaluation itself
+	if perContext := (large - small) / 100; perContext > single {
+		t.Errorf("Expected at most %.0f allocations per context, got %.2f", single, perContext)
+	}
+}
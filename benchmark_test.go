@@ -314,3 +314,45 @@ func BenchmarkVariables(b *testing.B) {
 		tpl.MustExec(ctx)
 	}
 }
+
+//
+// Allocation benchmarks of static templates, that should not allocate.
+//
+
+func BenchmarkStatic(b *testing.B) {
+	source := `<!DOCTYPE html>
+<html>
+  {{! a comment }}
+  <body>Hello world</body>
+</html>`
+
+	tpl := MustParse(source)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tpl.MustExec(nil)
+	}
+}
+
+func BenchmarkStaticPartials(b *testing.B) {
+	source := `{{> header}}<h1>{{title}}</h1>{{> footer}}`
+
+	ctx := map[string]string{
+		"title": "Hello",
+	}
+
+	tpl := MustParse(source)
+	tpl.RegisterPartial("header", `<html><body>`)
+	tpl.RegisterPartial("footer", `</body></html>`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := tpl.ExecBuffer(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf.Release()
+	}
+}
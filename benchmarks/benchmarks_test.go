@@ -0,0 +1,264 @@
+//go:build !tinygo && !raymond_tiny
+
+package benchmarks
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/lexer"
+	"github.com/aymerick/raymond/parser"
+)
+
+type person struct {
+	FirstName string
+	LastName  string
+	Email     string
+	Admin     bool
+	Tags      []string
+}
+
+type page struct {
+	Title  string
+	Header string
+	People []person
+	Footer string
+}
+
+// benchCase is a template written for both raymond and text/template
+type benchCase struct {
+	name  string
+	hbs   string
+	gotpl string
+
+	// partials, by name
+	hbsPartials   map[string]string
+	gotplPartials map[string]string
+
+	ctx interface{}
+}
+
+func newPage(nb int) *page {
+	result := &page{
+		Title:  "People",
+		Header: "All the people we know",
+		Footer: "That is all folks",
+	}
+
+	for i := 0; i < nb; i++ {
+		result.People = append(result.People, person{
+			FirstName: "Jean",
+			LastName:  "Dupont",
+			Email:     "jean@example.com",
+			Admin:     i%3 == 0,
+			Tags:      []string{"foo", "bar"},
+		})
+	}
+
+	return result
+}
+
+var benchCases = []benchCase{
+	{
+		name:  "variables",
+		hbs:   `<h1>{{title}}</h1><p>{{header}}</p><footer>{{footer}}</footer>`,
+		gotpl: `<h1>{{.Title}}</h1><p>{{.Header}}</p><footer>{{.Footer}}</footer>`,
+		ctx:   newPage(0),
+	},
+	{
+		name:  "list",
+		hbs:   `<ul>{{#each people}}<li>{{firstName}} {{lastName}}{{#if admin}} (admin){{/if}}</li>{{/each}}</ul>`,
+		gotpl: `<ul>{{range .People}}<li>{{.FirstName}} {{.LastName}}{{if .Admin}} (admin){{end}}</li>{{end}}</ul>`,
+		ctx:   newPage(20),
+	},
+	{
+		name:  "expressions",
+		hbs:   `<ul>{{#each people as |p i|}}<li>{{lookup p "firstName"}} {{p.lastName}} {{#each p.tags as |tag|}}<span>{{tag}}</span>{{/each}}{{#if p.admin}}{{#unless false}}admin{{/unless}}{{else if true}}user{{/if}}</li>{{/each}}</ul>`,
+		gotpl: `<ul>{{range $i, $p := .People}}<li>{{$p.FirstName}} {{$p.LastName}} {{range $tag := $p.Tags}}<span>{{$tag}}</span>{{end}}{{if $p.Admin}}{{if not false}}admin{{end}}{{else if true}}user{{end}}</li>{{end}}</ul>`,
+		ctx:   newPage(20),
+	},
+	{
+		name: "page",
+		hbs: `<!DOCTYPE html>
+<html>
+  <head><title>{{title}}</title></head>
+  <body>
+    {{> header}}
+    <table>
+      {{#each people}}
+      <tr>
+        <td>{{firstName}} {{lastName}}</td>
+        <td><a href="mailto:{{email}}">{{email}}</a></td>
+        <td>{{#each tags}}<span>{{this}}</span>{{/each}}</td>
+        <td>{{#if admin}}admin{{else}}user{{/if}}</td>
+      </tr>
+      {{/each}}
+    </table>
+    {{> footer}}
+  </body>
+</html>`,
+		gotpl: `<!DOCTYPE html>
+<html>
+  <head><title>{{.Title}}</title></head>
+  <body>
+    {{template "header" .}}
+    <table>
+      {{range .People}}
+      <tr>
+        <td>{{.FirstName}} {{.LastName}}</td>
+        <td><a href="mailto:{{.Email}}">{{.Email}}</a></td>
+        <td>{{range .Tags}}<span>{{.}}</span>{{end}}</td>
+        <td>{{if .Admin}}admin{{else}}user{{end}}</td>
+      </tr>
+      {{end}}
+    </table>
+    {{template "footer" .}}
+  </body>
+</html>`,
+		hbsPartials: map[string]string{
+			"header": `<header><h1>{{title}}</h1><p>{{header}}</p></header>`,
+			"footer": `<footer>{{footer}}</footer>`,
+		},
+		gotplPartials: map[string]string{
+			"header": `<header><h1>{{.Title}}</h1><p>{{.Header}}</p></header>`,
+			"footer": `<footer>{{.Footer}}</footer>`,
+		},
+		ctx: newPage(100),
+	},
+}
+
+// parseHbs parses raymond template of given case
+func parseHbs(tb testing.TB, c benchCase) *raymond.Template {
+	tpl, err := raymond.Parse(c.hbs)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tpl.RegisterPartials(c.hbsPartials)
+
+	return tpl
+}
+
+// parseGoTpl parses text/template template of given case
+func parseGoTpl(tb testing.TB, c benchCase) *template.Template {
+	tpl, err := template.New(c.name).Parse(c.gotpl)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	for name, source := range c.gotplPartials {
+		if _, err := tpl.New(name).Parse(source); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return tpl
+}
+
+// compact removes whitespaces, as handlebars strips standalone lines while text/template does not
+func compact(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// TestBenchCases checks that both templates of all cases render the same output, ignoring whitespaces
+func TestBenchCases(t *testing.T) {
+	for _, c := range benchCases {
+		hbs, err := parseHbs(t, c).Exec(c.ctx)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+
+		var gotpl strings.Builder
+		if err := parseGoTpl(t, c).Execute(&gotpl, c.ctx); err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+
+		if compact(hbs) != compact(gotpl.String()) {
+			t.Errorf("%s: outputs differ\nraymond:\n%s\ntext/template:\n%s", c.name, hbs, gotpl.String())
+		}
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(c.name, func(b *testing.B) {
+			b.SetBytes(int64(len(c.hbs)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				lexer.Collect(c.hbs)
+			}
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(c.name+"/raymond", func(b *testing.B) {
+			b.SetBytes(int64(len(c.hbs)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.Parse(c.hbs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(c.name+"/raymond_arena", func(b *testing.B) {
+			b.SetBytes(int64(len(c.hbs)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.ParseWithArena(c.hbs, ast.NewArena()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(c.name+"/text_template", func(b *testing.B) {
+			b.SetBytes(int64(len(c.gotpl)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := template.New(c.name).Parse(c.gotpl); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(c.name+"/raymond", func(b *testing.B) {
+			tpl := parseHbs(b, c)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := tpl.ExecTo(io.Discard, c.ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(c.name+"/text_template", func(b *testing.B) {
+			tpl := parseGoTpl(b, c)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := tpl.Execute(io.Discard, c.ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
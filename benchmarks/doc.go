@@ -0,0 +1,11 @@
+// Package benchmarks compares the lexing, parsing and rendering throughput of raymond with text/template, on representative templates.
+//
+// Run benchmarks with:
+//
+//	go test -bench . -benchmem ./benchmarks
+//
+// Each benchmark has a raymond and a text_template variant, rendering the same output with the same context. To compare with another version of raymond (eg. upstream github.com/aymerick/raymond), run the benchmarks on both checkouts and compare results with benchstat:
+//
+//	go test -bench . -benchmem -count 10 ./benchmarks > new.txt
+//	benchstat old.txt new.txt
+package benchmarks
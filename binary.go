@@ -0,0 +1,86 @@
+package raymond
+
+import (
+	"errors"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// errMustacheBinary is returned when a Mustache template is marshaled or loaded, as the raw source of its sections is not encoded
+var errMustacheBinary = errors.New("Mustache templates have no binary form")
+
+// MarshalBinary returns the parsed program of that template in a compact binary form, that is loaded by ParseBinary() without lexing nor parsing.
+//
+// Only the program is encoded: helpers and partials registered on that template must be registered again after loading. Templates parsed with the Mustache option are not supported.
+func (tpl *Template) MarshalBinary() ([]byte, error) {
+	if tpl.mustache != nil {
+		return nil, errMustacheBinary
+	}
+
+	if err := tpl.parse(); err != nil {
+		return nil, err
+	}
+
+	return ast.EncodeBinary(tpl.program), nil
+}
+
+// ParseBinary instanciates a template from the binary form returned by Template.MarshalBinary(), that must have been produced by the same raymond version.
+//
+// The program is compiled as when parsing a source, so that global pure helpers must be registered before loading.
+func ParseBinary(data []byte) (*Template, error) {
+	return ParseBinaryWithOptions(data, ParseOptions{})
+}
+
+// ParseBinaryWithOptions instanciates a template from its binary form, with given options.
+//
+// The ContentChunkSize option is ignored, as content has been split when the template was parsed, and the MaxInput limit of the Policy option applies to the size of data. The Mustache option is not supported.
+func ParseBinaryWithOptions(data []byte, opts ParseOptions) (*Template, error) {
+	if opts.Mustache {
+		return nil, errMustacheBinary
+	}
+
+	tpl := newTemplate("")
+
+	if opts.Arena {
+		tpl.arena = ast.NewArena()
+	}
+
+	tpl.contextualEscaping = opts.ContextualEscaping
+	tpl.escaping = opts.Escaping
+	tpl.jsNumbers = opts.JSNumbers
+	tpl.redactErrors = opts.RedactErrors
+	tpl.invalidUTF8 = opts.InvalidUTF8
+	tpl.setPolicy(opts.Policy)
+
+	if err := tpl.policy.checkInput(len(data)); err != nil {
+		return nil, tpl.redactParseError(err)
+	}
+
+	program, err := ast.DecodeBinary(data, tpl.arena)
+	if err != nil {
+		return nil, tpl.redactParseError(err)
+	}
+
+	tpl.program = program
+
+	if err := tpl.checkSandbox(); err != nil {
+		return nil, tpl.redactParseError(err)
+	}
+
+	tpl.code = compile(program)
+
+	if err := tpl.checkEscaping(); err != nil {
+		return nil, tpl.redactParseError(err)
+	}
+
+	return tpl, nil
+}
+
+// MustParseBinary instanciates a template from its binary form. It panics on error.
+func MustParseBinary(data []byte) *Template {
+	result, err := ParseBinary(data)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
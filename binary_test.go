@@ -0,0 +1,170 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+const binaryTestSource = `{{! comment }}
+{{#each items as |item index|}}
+  {{~> (concat "item" "Partial") item depth=1.5 label='x'}}
+{{else if @root.empty}}
+  {{{raw}}} {{../name}} {{this.title}} {{lookup . "foo" true -12}}
+{{else}}
+  {{#unless ok}}no{{^}}yes{{/unless}}
+{{/each}}
+`
+
+func TestBinary(t *testing.T) {
+	t.Parallel()
+
+	var sources []string
+	for _, tests := range [][]Test{evalTests, helperTests} {
+		for _, test := range tests {
+			sources = append(sources, test.input)
+		}
+	}
+
+	sources = append(sources, binaryTestSource)
+
+	for _, source := range sources {
+		tpl, err := Parse(source)
+		if err != nil {
+			continue
+		}
+
+		data, err := tpl.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal template %q: %s", source, err)
+		}
+
+		loaded, err := ParseBinary(data)
+		if err != nil {
+			t.Fatalf("Failed to load template %q: %s", source, err)
+		}
+
+		if !reflect.DeepEqual(loaded.program, tpl.program) {
+			t.Errorf("Loaded AST differs for template %q\nexpected:\n%s\ngot:\n%s", source, tpl.PrintAST(), loaded.PrintAST())
+		}
+	}
+}
+
+func TestBinaryExec(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items as |item|}}{{#if true}}{{> item}}{{/if}}{{#if false}}never{{/if}}{{/each}}`)
+
+	data, err := tpl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := ParseBinaryWithOptions(data, ParseOptions{Arena: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.arena.Len() == 0 {
+		t.Errorf("Expected nodes to be allocated from arena")
+	}
+
+	loaded.RegisterPartial("item", `<{{upper this}}>`)
+	loaded.RegisterHelper("upper", func(str string) string {
+		return str + "!"
+	})
+
+	if output := loaded.MustExec(map[string][]string{"items": {"a", "b"}}); output != "<a!><b!>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestBinaryStrings(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{foo}}{{foo}}{{foo}}{{bar "foo"}}`)
+
+	data, err := tpl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each string is stored once
+	if nb := strings.Count(string(data), "foo"); nb != 1 {
+		t.Errorf("Expected string to be stored once, found %d times", nb)
+	}
+}
+
+func TestBinaryInvalid(t *testing.T) {
+	t.Parallel()
+
+	data, err := MustParse(binaryTestSource).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// truncated data
+	for i := 0; i < len(data); i++ {
+		if _, err := ParseBinary(data[:i]); err != ast.ErrInvalidBinary {
+			t.Fatalf("Expected truncated data at %d/%d to be invalid, got: %v", i, len(data), err)
+		}
+	}
+
+	// trailing data
+	if _, err := ParseBinary(append(append([]byte{}, data...), 0)); err != ast.ErrInvalidBinary {
+		t.Errorf("Expected data with trailing bytes to be invalid, got: %v", err)
+	}
+
+	// corrupted data must never panic
+	corrupted := make([]byte, len(data))
+	for i := range data {
+		for _, b := range []byte{0x00, 0x01, 0x7f, 0x80, 0xff} {
+			copy(corrupted, data)
+			corrupted[i] = b
+
+			ParseBinary(corrupted)
+		}
+	}
+}
+
+func TestBinaryMustache(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions("{{#a}}{{b}}{{/a}}", ParseOptions{Mustache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tpl.MarshalBinary(); err != errMustacheBinary {
+		t.Errorf("Expected Mustache template to have no binary form, got: %v", err)
+	}
+
+	data, err := MustParse(binaryTestSource).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseBinaryWithOptions(data, ParseOptions{Mustache: true}); err != errMustacheBinary {
+		t.Errorf("Expected Mustache option to be rejected, got: %v", err)
+	}
+}
+
+func BenchmarkParseSource(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		MustParse(binaryTestSource)
+	}
+}
+
+func BenchmarkParseBinary(b *testing.B) {
+	data, err := MustParse(binaryTestSource).MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MustParseBinary(data)
+	}
+}
@@ -0,0 +1,37 @@
+package raymond
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// BudgetExceededError is the error returned when an evaluation produces more bytes than allowed by the MemoryBudget evaluation option.
+type BudgetExceededError struct {
+	// Budget is the memory budget, in bytes
+	Budget int64
+
+	// Used is the number of bytes produced when budget was exceeded
+	Used int64
+}
+
+// Error implements the error interface.
+func (err *BudgetExceededError) Error() string {
+	return fmt.Sprintf("Memory budget exceeded: %d bytes used, budget is %d bytes", err.Used, err.Budget)
+}
+
+// memoryBudget tracks the bytes produced by an evaluation, shared with partials rendered concurrently
+type memoryBudget struct {
+	limit int64
+	used  int64
+}
+
+// account records given number of produced bytes, and panics with a BudgetExceededError if budget is exceeded
+func (v *evalVisitor) account(n int) {
+	if v.budget == nil {
+		return
+	}
+
+	if used := atomic.AddInt64(&v.budget.used, int64(n)); used > v.budget.limit {
+		panic(&BudgetExceededError{Budget: v.budget.limit, Used: used})
+	}
+}
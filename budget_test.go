@@ -0,0 +1,69 @@
+package raymond
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}<li>{{this}}</li>{{/each}}`)
+	ctx := map[string][]string{"items": {"foo", "bar", "baz"}}
+
+	// each item: 12 bytes written by each helper, then 36 bytes written by block
+	output, err := tpl.ExecWithOptions(ctx, ExecOptions{MemoryBudget: 72})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "<li>foo</li><li>bar</li><li>baz</li>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	_, err = tpl.ExecWithOptions(ctx, ExecOptions{MemoryBudget: 71})
+
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("Budget exceeded error expected, got: %v", err)
+	}
+
+	if (budgetErr.Budget != 71) || (budgetErr.Used != 72) {
+		t.Errorf("Unexpected error: %s", budgetErr)
+	}
+}
+
+func TestMemoryBudgetStatic(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(strings.Repeat("a", 100))
+
+	if _, err := tpl.ExecWithOptions(nil, ExecOptions{MemoryBudget: 99}); err == nil {
+		t.Errorf("Budget exceeded error expected")
+	}
+
+	if _, err := tpl.ExecWithOptions(nil, ExecOptions{MemoryBudget: 100}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestMemoryBudgetConcurrentPartials(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{> a}}{{> b}}{{> c}}`)
+	tpl.RegisterPartials(map[string]string{
+		"a": strings.Repeat("a", 50),
+		"b": strings.Repeat("b", 50),
+		"c": "{{#each items}}{{this}}{{/each}}",
+	})
+
+	ctx := map[string][]string{"items": {strings.Repeat("c", 50)}}
+
+	_, err := tpl.ExecWithOptions(ctx, ExecOptions{MemoryBudget: 200, PartialConcurrency: 3})
+
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Errorf("Budget exceeded error expected, got: %v", err)
+	}
+}
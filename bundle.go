@@ -0,0 +1,118 @@
+package raymond
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// bundleMagic prefixes template bundles, and its last byte is the format version
+const bundleMagic = "hbb\x01"
+
+// ErrInvalidBundle is returned when loading data that is not a template bundle.
+var ErrInvalidBundle = errors.New("invalid template bundle")
+
+// MarshalBundle returns given templates, indexed by name, in a single binary bundle that is loaded by ParseBundle() without lexing nor parsing.
+//
+// Each template is encoded with Template.MarshalBinary(), in name order, so that the bundle of a template set is stable.
+func MarshalBundle(templates map[string]*Template) ([]byte, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	result := append([]byte(nil), bundleMagic...)
+	result = appendUvarint(result, uint64(len(names)))
+
+	for _, name := range names {
+		data, err := templates[name].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		result = appendUvarint(result, uint64(len(name)))
+		result = append(result, name...)
+		result = appendUvarint(result, uint64(len(data)))
+		result = append(result, data...)
+	}
+
+	return result, nil
+}
+
+// ParseBundle instanciates the templates of a bundle returned by MarshalBundle(), indexed by name.
+//
+// All templates of the bundle are registered as partials of each other, so that a bundle made from a directory of templates and partials renders like the original sources.
+func ParseBundle(data []byte) (map[string]*Template, error) {
+	return ParseBundleWithOptions(data, ParseOptions{})
+}
+
+// ParseBundleWithOptions instanciates the templates of a bundle, with given options, as ParseBinaryWithOptions() does.
+func ParseBundleWithOptions(data []byte, opts ParseOptions) (map[string]*Template, error) {
+	if (len(data) < len(bundleMagic)) || (string(data[:len(bundleMagic)]) != bundleMagic) {
+		return nil, ErrInvalidBundle
+	}
+
+	data = data[len(bundleMagic):]
+
+	// next returns the next length prefixed bytes of data
+	next := func() ([]byte, bool) {
+		size, n := binary.Uvarint(data)
+		if (n <= 0) || (size > uint64(len(data)-n)) {
+			return nil, false
+		}
+
+		result := data[n : n+int(size)]
+		data = data[n+int(size):]
+
+		return result, true
+	}
+
+	count, n := binary.Uvarint(data)
+	if (n <= 0) || (count > uint64(len(data))) {
+		return nil, ErrInvalidBundle
+	}
+
+	data = data[n:]
+
+	result := make(map[string]*Template, count)
+
+	for i := uint64(0); i < count; i++ {
+		name, ok := next()
+		if !ok {
+			return nil, ErrInvalidBundle
+		}
+
+		encoded, ok := next()
+		if !ok {
+			return nil, ErrInvalidBundle
+		}
+
+		tpl, err := ParseBinaryWithOptions(encoded, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		result[string(name)] = tpl
+	}
+
+	if len(data) > 0 {
+		return nil, ErrInvalidBundle
+	}
+
+	for _, tpl := range result {
+		for name, partial := range result {
+			tpl.RegisterPartialTemplate(name, partial)
+		}
+	}
+
+	return result, nil
+}
+
+// appendUvarint appends given value to given bytes, encoded as an unsigned varint
+func appendUvarint(buf []byte, val uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+
+	return append(buf, scratch[:binary.PutUvarint(scratch[:], val)]...)
+}
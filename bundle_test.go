@@ -0,0 +1,64 @@
+package raymond
+
+import (
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	t.Parallel()
+
+	templates := map[string]*Template{
+		"page":          MustParse("<h1>{{title}}</h1>{{#each items}}{{> partials/item}}{{/each}}"),
+		"partials/item": MustParse("<p>{{name}}</p>"),
+	}
+
+	data, err := MarshalBundle(templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := ParseBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != len(templates) {
+		t.Fatalf("Expected %d templates, got %d", len(templates), len(loaded))
+	}
+
+	ctx := map[string]interface{}{
+		"title": "Foo",
+		"items": []map[string]string{{"name": "bar"}, {"name": "baz"}},
+	}
+
+	expected := "<h1>Foo</h1><p>bar</p><p>baz</p>"
+
+	if output := loaded["page"].MustExec(ctx); output != expected {
+		t.Errorf("Expected %q, got: %q", expected, output)
+	}
+
+	// bundles are stable
+	again, err := MarshalBundle(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(again) != string(data) {
+		t.Errorf("Expected bundle to be stable")
+	}
+}
+
+func TestBundleInvalid(t *testing.T) {
+	t.Parallel()
+
+	data, err := MarshalBundle(map[string]*Template{"foo": MustParse("{{foo}}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range [][]byte{nil, []byte("hbs\x01"), data[:len(data)-1], append(data, 0)} {
+		if _, err := ParseBundle(invalid); err == nil {
+			t.Errorf("Expected an error for invalid bundle %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package raymond
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheOptions represents the configuration of a templates cache.
+type CacheOptions struct {
+	// MaxEntries is the maximum number of cached templates, the least recently used template being evicted first. Zero means no limit.
+	MaxEntries int
+
+	// TTL is the duration after which a cached template expires. Zero means that templates never expire.
+	TTL time.Duration
+}
+
+// CacheStats represents the statistics of a templates cache.
+//
+// It can be published with expvar, or exported as Prometheus counters and gauge.
+type CacheStats struct {
+	Entries     int    `json:"entries"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+}
+
+// Cache is a concurrency-safe cache of parsed templates.
+//
+// Templates are cached by name, with a hash of their source and their parse options: a template is parsed again when its source or options change. Cached templates are shared, so Clone() them before registering template helpers or partials.
+type Cache struct {
+	opts CacheOptions
+
+	mutex   sync.Mutex // protects lru and entries
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+
+	// returns current time, replaced in tests
+	now func() time.Time
+}
+
+// cacheEntry is a cached template
+type cacheEntry struct {
+	name    string
+	hash    [sha256.Size]byte
+	opts    ParseOptions
+	tpl     *Template
+	expires time.Time
+}
+
+// NewCache instanciates a new templates cache.
+func NewCache(opts CacheOptions) *Cache {
+	return &Cache{
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Parse returns the template cached with given name, or parses given source and caches resulting template if not found or if source changed.
+//
+// Parsing errors are not cached.
+func (c *Cache) Parse(name string, source string) (*Template, error) {
+	return c.ParseWithOptions(name, source, ParseOptions{})
+}
+
+// ParseWithOptions is like Parse() but parses template with given options. The template cached with given name is parsed again if it was parsed with other options.
+func (c *Cache) ParseWithOptions(name string, source string, opts ParseOptions) (*Template, error) {
+	hash := sha256.Sum256([]byte(source))
+
+	if tpl := c.get(name, hash, opts); tpl != nil {
+		atomic.AddUint64(&c.hits, 1)
+		return tpl, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	// parse outside of lock, so that a slow parsing does not block other lookups
+	tpl, err := ParseWithOptions(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(name, hash, opts, tpl)
+
+	return tpl, nil
+}
+
+// MustParse is like Parse() but panics on error.
+func (c *Cache) MustParse(name string, source string) *Template {
+	result, err := c.Parse(name, source)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ParseFile reads given file and returns the template cached with file path as name.
+func (c *Cache) ParseFile(filePath string) (*Template, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Parse(filePath, string(b))
+}
+
+// Remove removes the template cached with given name.
+func (c *Cache) Remove(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elt := c.entries[name]; elt != nil {
+		c.removeElement(elt)
+	}
+}
+
+// Purge removes all cached templates.
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// Len returns the number of cached templates.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.lru.Len()
+}
+
+// Stats returns cache statistics.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Entries:     c.Len(),
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+	}
+}
+
+// get returns the cached template with given name, source hash and parse options, or nil if not found
+func (c *Cache) get(name string, hash [sha256.Size]byte, opts ParseOptions) *Template {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elt := c.entries[name]
+	if elt == nil {
+		return nil
+	}
+
+	entry := elt.Value.(*cacheEntry)
+
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.removeElement(elt)
+		atomic.AddUint64(&c.expirations, 1)
+		return nil
+	}
+
+	if (entry.hash != hash) || (entry.opts != opts) {
+		return nil
+	}
+
+	c.lru.MoveToFront(elt)
+
+	return entry.tpl
+}
+
+// add caches given template, evicting least recently used templates if needed
+func (c *Cache) add(name string, hash [sha256.Size]byte, opts ParseOptions, tpl *Template) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &cacheEntry{name: name, hash: hash, opts: opts, tpl: tpl}
+	if c.opts.TTL > 0 {
+		entry.expires = c.now().Add(c.opts.TTL)
+	}
+
+	if elt := c.entries[name]; elt != nil {
+		elt.Value = entry
+		c.lru.MoveToFront(elt)
+		return
+	}
+
+	c.entries[name] = c.lru.PushFront(entry)
+
+	for (c.opts.MaxEntries > 0) && (c.lru.Len() > c.opts.MaxEntries) {
+		c.removeElement(c.lru.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// removeElement removes given element from cache
+func (c *Cache) removeElement(elt *list.Element) {
+	c.lru.Remove(elt)
+	delete(c.entries, elt.Value.(*cacheEntry).name)
+}
@@ -0,0 +1,159 @@
+package raymond
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(CacheOptions{})
+
+	tpl1 := cache.MustParse("hello", "Hello {{name}}")
+	tpl2 := cache.MustParse("hello", "Hello {{name}}")
+	if tpl1 != tpl2 {
+		t.Errorf("Template not cached")
+	}
+
+	tpl3 := cache.MustParse("hello", "Hi {{name}}")
+	if tpl3 == tpl1 {
+		t.Errorf("Template not parsed again when source changed")
+	}
+
+	if output := tpl3.MustExec(map[string]string{"name": "Jean"}); output != "Hi Jean" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	expected := CacheStats{Entries: 1, Hits: 1, Misses: 2}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("Unexpected stats\nexpected:\n\t%+v\ngot:\n\t%+v", expected, stats)
+	}
+
+	cache.Remove("hello")
+	if cache.Len() != 0 {
+		t.Errorf("Template not removed")
+	}
+}
+
+func TestCacheParseOptions(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(CacheOptions{})
+
+	tpl1 := cache.MustParse("hello", "{{name}}")
+
+	tpl2, err := cache.ParseWithOptions("hello", "{{name}}", ParseOptions{Escaping: EscapeNone})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if tpl2 == tpl1 {
+		t.Errorf("Template not parsed again when options changed")
+	}
+
+	if output := tpl2.MustExec(map[string]string{"name": "<b>"}); output != "<b>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if tpl, _ := cache.ParseWithOptions("hello", "{{name}}", ParseOptions{Escaping: EscapeNone}); tpl != tpl2 {
+		t.Errorf("Template not cached with its options")
+	}
+}
+
+func TestCacheParseError(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(CacheOptions{})
+
+	if _, err := cache.Parse("bad", "{{#if}}"); err == nil {
+		t.Errorf("Parsing error expected")
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("Parsing error cached")
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(CacheOptions{MaxEntries: 2})
+
+	cache.MustParse("a", "a")
+	cache.MustParse("b", "b")
+	cache.MustParse("a", "a") // a is now the most recently used
+	cache.MustParse("c", "c") // b is evicted
+
+	if cache.Len() != 2 {
+		t.Errorf("Unexpected cache length: %d", cache.Len())
+	}
+
+	cache.MustParse("a", "a")
+	cache.MustParse("b", "b")
+
+	expected := CacheStats{Entries: 2, Hits: 2, Misses: 4, Evictions: 2}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("Unexpected stats\nexpected:\n\t%+v\ngot:\n\t%+v", expected, stats)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2018, 3, 22, 0, 0, 0, 0, time.UTC)
+
+	cache := NewCache(CacheOptions{TTL: time.Minute})
+	cache.now = func() time.Time { return now }
+
+	tpl1 := cache.MustParse("hello", "Hello")
+
+	now = now.Add(59 * time.Second)
+	if tpl := cache.MustParse("hello", "Hello"); tpl != tpl1 {
+		t.Errorf("Template expired too early")
+	}
+
+	now = now.Add(time.Second)
+	if tpl := cache.MustParse("hello", "Hello"); tpl == tpl1 {
+		t.Errorf("Template not expired")
+	}
+
+	expected := CacheStats{Entries: 1, Hits: 1, Misses: 2, Expirations: 1}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("Unexpected stats\nexpected:\n\t%+v\ngot:\n\t%+v", expected, stats)
+	}
+
+	if b, _ := json.Marshal(cache.Stats()); string(b) != `{"entries":1,"hits":1,"misses":2,"evictions":0,"expirations":1}` {
+		t.Errorf("Unexpected JSON stats: %s", b)
+	}
+}
+
+func TestCacheConcurrency(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(CacheOptions{MaxEntries: 5})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				name := fmt.Sprintf("tpl%d", (i+j)%8)
+				tpl := cache.MustParse(name, name)
+				if output := tpl.MustExec(nil); output != name {
+					t.Errorf("Unexpected output: %q", output)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Hits+stats.Misses != 1000 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
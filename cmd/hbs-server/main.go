@@ -0,0 +1,146 @@
+// Command hbs-server is a template rendering service, so that services that are not written in Go share the same handlebars engine.
+//
+// Usage:
+//
+//	hbs-server [flags]
+//
+// Templates and partials are uploaded with an HTTP/JSON API, and are then rendered with the contexts posted by clients:
+//
+//	PUT    /partials/{name}          registers the partial with the request body as source
+//	DELETE /partials/{name}          removes a partial
+//	GET    /partials                 lists the names of partials
+//	PUT    /templates/{name}         registers the template with the request body as source
+//	DELETE /templates/{name}         removes a template
+//	GET    /templates                lists the names of templates
+//	POST   /templates/{name}/render  renders a template with {"context": ..., "data": {...}}
+//	POST   /render                   renders {"source": "...", "context": ..., "data": {...}} without registering it
+//	GET    /healthz                  reports that the server is up
+//
+// Renders respond with {"output": "..."}, and failures with {"error": "...", "kind": "..."}.
+//
+// The server listens on localhost by default. When it listens on other interfaces, set a token with the HBS_SERVER_TOKEN environment variable or the -token-file flag: the PUT and DELETE requests must then send it as "Authorization: Bearer <token>". The server refuses to start on a non-loopback address without a token, unless the -insecure flag is set. The number of templates and partials, and the size of request bodies are limited with flags.
+//
+// Templates are untrusted by default: they are parsed and rendered with raymond.PolicyUntrusted(), and may only include the partials that are registered when they are uploaded, so partials must be uploaded before the templates that use them. The limits and the sandbox are configured with flags.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aymerick/raymond"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	trusted := flag.Bool("trusted", false, "trust templates: disable the sandbox and the limits, unless set by other flags")
+	helpers := flag.String("helpers", "", "comma separated names of built-in helpers allowed in addition to the sandbox defaults, eg. \"upper,lower\"")
+	allowUnescaped := flag.Bool("allow-unescaped", false, "allow triple-stash and {{& }} mustaches in sandboxed templates")
+	maxInput := flag.Int("max-input", 0, "maximum size in bytes of template and partial sources, 0 for no limit (default: from policy)")
+	maxDepth := flag.Int("max-depth", 0, "maximum nesting level of blocks and partials, 0 for no limit (default: from policy)")
+	maxIterations := flag.Int64("max-iterations", 0, "maximum number of iterations of a render, 0 for no limit (default: from policy)")
+	maxOutput := flag.Int64("max-output", 0, "maximum size in bytes of a render output, 0 for no limit (default: from policy)")
+	timeout := flag.Duration("timeout", 0, "maximum duration of a render, 0 for no limit (default: from policy)")
+	maxBody := flag.Int64("max-body", 8<<20, "maximum size in bytes of request bodies")
+	maxTemplates := flag.Int("max-templates", 1000, "maximum number of registered templates, and of registered partials, 0 for no limit")
+	tokenFile := flag.String("token-file", "", "file of the token that requests registering or removing templates and partials must send as \"Authorization: Bearer <token>\" (default: $HBS_SERVER_TOKEN, if set)")
+	insecure := flag.Bool("insecure", false, "listen on a non-loopback address without a token, so that anyone who can reach the server can register and remove templates")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: hbs-server [flags]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	policy := raymond.PolicyUntrusted()
+	if *trusted {
+		policy = raymond.PolicyTrusted()
+	}
+
+	// limits are only changed by the flags that are set, as zero means no limit
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "max-input":
+			policy.MaxInput = *maxInput
+		case "max-depth":
+			policy.MaxDepth = *maxDepth
+		case "max-iterations":
+			policy.MaxIterations = *maxIterations
+		case "max-output":
+			policy.MaxOutput = *maxOutput
+		case "timeout":
+			policy.Timeout = *timeout
+		}
+	})
+
+	if policy.Sandbox != nil {
+		if *helpers != "" {
+			policy.Sandbox.Helpers = append(policy.Sandbox.Helpers, strings.Split(*helpers, ",")...)
+		}
+
+		policy.Sandbox.AllowUnescaped = *allowUnescaped
+	}
+
+	token := os.Getenv("HBS_SERVER_TOKEN")
+	if *tokenFile != "" {
+		data, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			log.Fatalf("hbs-server: %s", err)
+		}
+
+		token = strings.TrimSpace(string(data))
+	}
+
+	if token == "" {
+		if !*insecure && !isLoopback(*addr) {
+			log.Fatalf("hbs-server: refusing to listen on %s without a token: set HBS_SERVER_TOKEN or -token-file, or pass -insecure", *addr)
+		}
+
+		log.Printf("hbs-server: no token set, anyone who can reach %s can register and remove templates", *addr)
+	}
+
+	opts := serverOptions{
+		maxBody:      *maxBody,
+		maxTemplates: *maxTemplates,
+		token:        token,
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(policy, opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("hbs-server: listening on %s", *addr)
+
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("hbs-server: %s", err)
+	}
+}
+
+// isLoopback returns true if given listen address only accepts connections from the local host
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return (ip != nil) && ip.IsLoopback()
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestIsLoopback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		addr     string
+		loopback bool
+	}{
+		{"localhost:8080", true},
+		{"127.0.0.1:8080", true},
+		{"127.1.2.3:80", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"[::]:8080", false},
+		{"192.168.1.2:8080", false},
+		{"example.com:8080", false},
+		{"localhost", false},
+	}
+
+	for _, test := range tests {
+		if loopback := isLoopback(test.addr); loopback != test.loopback {
+			t.Errorf("Unexpected result for %q: %v", test.addr, loopback)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aymerick/raymond"
+)
+
+// renderRequest is the body of render requests
+type renderRequest struct {
+	// Source is the template source, only for ad-hoc renders
+	Source string `json:"source"`
+
+	// Context is the context of render
+	Context interface{} `json:"context"`
+
+	// Data are the private data variables of render, eg. {"locale": "fr"} for @locale
+	Data map[string]interface{} `json:"data"`
+}
+
+// renderResponse is the body of successful render responses
+type renderResponse struct {
+	Output string `json:"output"`
+}
+
+// errorResponse is the body of failed responses
+type errorResponse struct {
+	Error string `json:"error"`
+
+	// Kind is the kind of error: "bad_request", "unauthorized", "not_found", "parse", "sandbox", "limit" or "render"
+	Kind string `json:"kind"`
+}
+
+// serverOptions are the options of the rendering service
+type serverOptions struct {
+	// maximum size in bytes of request bodies
+	maxBody int64
+
+	// maximum number of registered templates, and of registered partials, zero meaning no limit
+	maxTemplates int
+
+	// token expected in the Authorization header of the requests that register or remove templates and partials, as "Bearer <token>", empty meaning that those requests are not authenticated
+	token string
+}
+
+// server is the rendering service, that is safe for concurrent use
+type server struct {
+	policy raymond.SecurityPolicy
+	opts   serverOptions
+
+	mutex     sync.RWMutex
+	templates map[string]*raymond.Template
+	partials  map[string]*raymond.Template
+}
+
+// newServer instanciates a new server, that parses and renders templates with given policy and options
+func newServer(policy raymond.SecurityPolicy, opts serverOptions) *server {
+	return &server{
+		policy:    policy,
+		opts:      opts,
+		templates: make(map[string]*raymond.Template),
+		partials:  make(map[string]*raymond.Template),
+	}
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(w, req.Body, s.opts.maxBody)
+
+	path := req.URL.Path
+
+	switch {
+	case path == "/healthz":
+		s.allow(w, req, http.MethodGet, func() { w.WriteHeader(http.StatusNoContent) })
+	case path == "/render":
+		s.allow(w, req, http.MethodPost, func() { s.renderSource(w, req) })
+	case path == "/templates":
+		s.allow(w, req, http.MethodGet, func() { s.list(w, s.templates) })
+	case path == "/partials":
+		s.allow(w, req, http.MethodGet, func() { s.list(w, s.partials) })
+	case strings.HasPrefix(path, "/templates/") && strings.HasSuffix(path, "/render") && (req.Method == http.MethodPost):
+		s.renderTemplate(w, req, strings.TrimSuffix(strings.TrimPrefix(path, "/templates/"), "/render"))
+	case strings.HasPrefix(path, "/templates/"):
+		s.resource(w, req, false, strings.TrimPrefix(path, "/templates/"))
+	case strings.HasPrefix(path, "/partials/"):
+		s.resource(w, req, true, strings.TrimPrefix(path, "/partials/"))
+	default:
+		writeError(w, http.StatusNotFound, "not_found", fmt.Errorf("Unknown path: %s", path))
+	}
+}
+
+// allow calls given function if request has given method, and responds with a 405 Method Not Allowed otherwise
+func (s *server) allow(w http.ResponseWriter, req *http.Request, method string, f func()) {
+	if req.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "bad_request", fmt.Errorf("Method %s not allowed", req.Method))
+		return
+	}
+
+	f()
+}
+
+// list responds with the sorted names of given templates
+func (s *server) list(w http.ResponseWriter, templates map[string]*raymond.Template) {
+	s.mutex.RLock()
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	s.mutex.RUnlock()
+
+	sort.Strings(names)
+
+	writeJSON(w, http.StatusOK, names)
+}
+
+// resource handles the PUT and DELETE requests of a template or a partial with given name
+func (s *server) resource(w http.ResponseWriter, req *http.Request, partial bool, name string) {
+	templates := s.templates
+	if partial {
+		templates = s.partials
+	}
+
+	if name == "" {
+		writeError(w, http.StatusNotFound, "not_found", errors.New("Missing name"))
+		return
+	}
+
+	if ((req.Method == http.MethodPut) || (req.Method == http.MethodDelete)) && !s.authorized(req) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "unauthorized", errors.New("Missing or invalid token"))
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPut:
+		source, err := io.ReadAll(req.Body)
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, "bad_request", err)
+			return
+		}
+
+		self := ""
+		if partial {
+			self = name
+		}
+
+		tpl, err := s.parse(string(source), self)
+		if err != nil {
+			writeError(w, parseStatus(err), errorKind(err, "parse"), err)
+			return
+		}
+
+		s.mutex.Lock()
+		_, exists := templates[name]
+		if !exists && (s.opts.maxTemplates > 0) && (len(templates) >= s.opts.maxTemplates) {
+			s.mutex.Unlock()
+			writeError(w, http.StatusInsufficientStorage, "limit", fmt.Errorf("Too many registered templates, the limit is %d", s.opts.maxTemplates))
+			return
+		}
+
+		templates[name] = tpl
+		s.mutex.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		s.mutex.Lock()
+		_, ok := templates[name]
+		delete(templates, name)
+		s.mutex.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", fmt.Errorf("Unknown name: %s", name))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "bad_request", fmt.Errorf("Method %s not allowed", req.Method))
+	}
+}
+
+// authorized returns true if given request carries the token of server, or if server has no token
+func (s *server) authorized(req *http.Request) bool {
+	if s.opts.token == "" {
+		return true
+	}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == req.Header.Get("Authorization") {
+		// not a bearer token
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.token)) == 1
+}
+
+// parse parses given source with server policy, allowing the partials that are currently registered, and the partial with given name if not empty, so that a partial may be recursive
+func (s *server) parse(source string, self string) (*raymond.Template, error) {
+	policy := s.policy
+
+	if policy.Sandbox != nil {
+		sandbox := *policy.Sandbox
+		sandbox.Partials = append([]string(nil), sandbox.Partials...)
+
+		if self != "" {
+			sandbox.Partials = append(sandbox.Partials, self)
+		}
+
+		s.mutex.RLock()
+		for partial := range s.partials {
+			sandbox.Partials = append(sandbox.Partials, partial)
+		}
+		s.mutex.RUnlock()
+
+		policy.Sandbox = &sandbox
+	}
+
+	return raymond.ParseWithOptions(source, raymond.ParseOptions{Policy: &policy})
+}
+
+// renderTemplate handles the render requests of the registered template with given name
+func (s *server) renderTemplate(w http.ResponseWriter, req *http.Request, name string) {
+	s.mutex.RLock()
+	tpl := s.templates[name]
+	s.mutex.RUnlock()
+
+	if tpl == nil {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Errorf("Unknown template: %s", name))
+		return
+	}
+
+	var body renderRequest
+	if !decodeRequest(w, req, &body) {
+		return
+	}
+
+	s.render(w, tpl, body)
+}
+
+// renderSource handles the ad-hoc render requests
+func (s *server) renderSource(w http.ResponseWriter, req *http.Request) {
+	var body renderRequest
+	if !decodeRequest(w, req, &body) {
+		return
+	}
+
+	tpl, err := s.parse(body.Source, "")
+	if err != nil {
+		writeError(w, parseStatus(err), errorKind(err, "parse"), err)
+		return
+	}
+
+	s.render(w, tpl, body)
+}
+
+// render renders given template with the currently registered partials, and responds with its output
+func (s *server) render(w http.ResponseWriter, tpl *raymond.Template, body renderRequest) {
+	// partials are registered on a clone, so that a template uses the latest version of partials
+	tpl = tpl.Clone()
+
+	s.mutex.RLock()
+	for name, partial := range s.partials {
+		tpl.RegisterPartialTemplate(name, partial)
+	}
+	s.mutex.RUnlock()
+
+	data := raymond.NewDataFrame()
+	for name, value := range body.Data {
+		data.Set(name, value)
+	}
+
+	output, err := tpl.ExecWithOptions(body.Context, raymond.ExecOptions{Data: data})
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, errorKind(err, "render"), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, renderResponse{Output: output})
+}
+
+// decodeRequest decodes the JSON body of given request, or responds with an error and returns false
+func decodeRequest(w http.ResponseWriter, req *http.Request, body *renderRequest) bool {
+	if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("Invalid JSON body: %s", err))
+		return false
+	}
+
+	return true
+}
+
+// errorKind returns the kind of given error, or given default kind
+func errorKind(err error, kind string) string {
+	var sandboxErr *raymond.SandboxError
+	var limitErr *raymond.LimitExceededError
+	var budgetErr *raymond.BudgetExceededError
+
+	switch {
+	case errors.As(err, &sandboxErr):
+		return "sandbox"
+	case errors.As(err, &limitErr), errors.As(err, &budgetErr):
+		return "limit"
+	}
+
+	return kind
+}
+
+// parseStatus returns the status code of given parse error
+func parseStatus(err error) int {
+	var limitErr *raymond.LimitExceededError
+	if errors.As(err, &limitErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+// writeError responds with given status and error
+func writeError(w http.ResponseWriter, status int, kind string, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error(), Kind: kind})
+}
+
+// writeJSON responds with given status and JSON value
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+}
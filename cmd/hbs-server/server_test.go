@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+// serverTest is a request sent to a server, with the expected response
+type serverTest struct {
+	method string
+	path   string
+	body   string
+	token  string
+
+	status int
+
+	// expected body: JSON value, or error kind for failures
+	output string
+	kind   string
+}
+
+// testServer instanciates a server with the untrusted policy and given options
+func testServer(opts serverOptions) *server {
+	if opts.maxBody == 0 {
+		opts.maxBody = 1 << 20
+	}
+
+	return newServer(raymond.PolicyUntrusted(), opts)
+}
+
+// launchServerTests sends given requests in order to given server, and checks responses
+func launchServerTests(t *testing.T, s *server, tests []serverTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		name := test.method + " " + test.path
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", name, test.status, rec.Code, rec.Body.String())
+			continue
+		}
+
+		if test.kind != "" {
+			var resp errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); (err != nil) || (resp.Kind != test.kind) || (resp.Error == "") {
+				t.Errorf("%s: expected error of kind %q, got: %s", name, test.kind, rec.Body.String())
+			}
+		} else if test.output != strings.TrimSpace(rec.Body.String()) {
+			t.Errorf("%s: expected body %s, got: %s", name, test.output, rec.Body.String())
+		}
+	}
+}
+
+func TestServerTemplates(t *testing.T) {
+	t.Parallel()
+
+	launchServerTests(t, testServer(serverOptions{}), []serverTest{
+		{method: "GET", path: "/healthz", status: 204},
+		{method: "GET", path: "/templates", status: 200, output: `[]`},
+
+		// partials must be registered before templates that use them
+		{method: "PUT", path: "/templates/hello", body: `Hi {{> user}}`, status: 400, kind: "sandbox"},
+		{method: "PUT", path: "/partials/user", body: `<b>{{name}}</b>`, status: 204},
+		{method: "PUT", path: "/templates/hello", body: `Hi {{> user}}{{@locale}}`, status: 204},
+		{method: "GET", path: "/templates", status: 200, output: `["hello"]`},
+		{method: "GET", path: "/partials", status: 200, output: `["user"]`},
+
+		{method: "POST", path: "/templates/hello/render", body: `{"context": {"name": "<Ann>"}, "data": {"locale": "fr"}}`, status: 200, output: `{"output":"Hi <b>&lt;Ann&gt;</b>fr"}`},
+		{method: "POST", path: "/templates/hello/render", body: `{`, status: 400, kind: "bad_request"},
+		{method: "POST", path: "/templates/unknown/render", body: `{}`, status: 404, kind: "not_found"},
+
+		// partials are updated in place
+		{method: "PUT", path: "/partials/user", body: `<i>{{name}}</i>`, status: 204},
+		{method: "POST", path: "/templates/hello/render", body: `{"context": {"name": "Ann"}}`, status: 200, output: `{"output":"Hi <i>Ann</i>"}`},
+
+		{method: "DELETE", path: "/templates/hello", status: 204},
+		{method: "DELETE", path: "/templates/hello", status: 404, kind: "not_found"},
+		{method: "DELETE", path: "/partials/user", status: 204},
+		{method: "GET", path: "/templates", status: 200, output: `[]`},
+	})
+}
+
+func TestServerRender(t *testing.T) {
+	t.Parallel()
+
+	launchServerTests(t, testServer(serverOptions{}), []serverTest{
+		{method: "POST", path: "/render", body: `{"source": "{{a}}", "context": {"a": 1}}`, status: 200, output: `{"output":"1"}`},
+		{method: "POST", path: "/render", body: `{"source": "{{#if}}"}`, status: 400, kind: "parse"},
+		{method: "POST", path: "/render", body: `{"source": "{{{a}}}"}`, status: 400, kind: "sandbox"},
+		{method: "POST", path: "/render", body: `{"source": "{{lookup a 1 2 3}}"}`, status: 422, kind: "render"},
+		{method: "POST", path: "/render", body: `not json`, status: 400, kind: "bad_request"},
+	})
+}
+
+func TestServerErrors(t *testing.T) {
+	t.Parallel()
+
+	launchServerTests(t, testServer(serverOptions{maxBody: 16}), []serverTest{
+		{method: "GET", path: "/unknown", status: 404, kind: "not_found"},
+		{method: "POST", path: "/healthz", status: 405, kind: "bad_request"},
+		{method: "GET", path: "/render", status: 405, kind: "bad_request"},
+		{method: "POST", path: "/templates", status: 405, kind: "bad_request"},
+		{method: "GET", path: "/templates/hello", status: 405, kind: "bad_request"},
+		{method: "PUT", path: "/templates/", status: 404, kind: "not_found"},
+		{method: "PUT", path: "/templates/big", body: strings.Repeat("a", 17), status: 413, kind: "bad_request"},
+	})
+}
+
+func TestServerLimits(t *testing.T) {
+	t.Parallel()
+
+	policy := raymond.PolicyUntrusted()
+	policy.MaxInput = 8
+
+	s := newServer(policy, serverOptions{maxBody: 1 << 20, maxTemplates: 1})
+
+	launchServerTests(t, s, []serverTest{
+		{method: "PUT", path: "/templates/a", body: `a`, status: 204},
+		{method: "PUT", path: "/templates/a", body: `b`, status: 204},
+		{method: "PUT", path: "/templates/b", body: `b`, status: 507, kind: "limit"},
+		{method: "PUT", path: "/partials/b", body: `b`, status: 204},
+		{method: "PUT", path: "/partials/c", body: `c`, status: 507, kind: "limit"},
+		{method: "PUT", path: "/partials/b", body: `too large template`, status: 413, kind: "limit"},
+		{method: "DELETE", path: "/templates/a", status: 204},
+		{method: "PUT", path: "/templates/b", body: `b`, status: 204},
+	})
+}
+
+func TestServerToken(t *testing.T) {
+	t.Parallel()
+
+	s := testServer(serverOptions{token: "secret"})
+
+	launchServerTests(t, s, []serverTest{
+		{method: "PUT", path: "/templates/a", body: `{{a}}`, status: 401, kind: "unauthorized"},
+		{method: "PUT", path: "/templates/a", body: `{{a}}`, token: "wrong", status: 401, kind: "unauthorized"},
+		{method: "PUT", path: "/templates/a", body: `{{a}}`, token: "secret", status: 204},
+		{method: "POST", path: "/templates/a/render", body: `{"context": {"a": 1}}`, status: 200, output: `{"output":"1"}`},
+		{method: "DELETE", path: "/templates/a", status: 401, kind: "unauthorized"},
+		{method: "DELETE", path: "/templates/a", token: "secret", status: 204},
+	})
+
+	// token must be sent as a bearer token
+	req := httptest.NewRequest("DELETE", "/partials/a", nil)
+	req.Header.Set("Authorization", "secret")
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if (rec.Code != http.StatusUnauthorized) || (rec.Header().Get("WWW-Authenticate") != "Bearer") {
+		t.Errorf("Expected unauthorized status, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,19 @@
+//go:build js && wasm
+
+// Command hbs-wasm is the WebAssembly module of raymond, that exports its API as the `raymond` JavaScript global (see package wasm).
+//
+// Build it with:
+//
+//	$ GOOS=js GOARCH=wasm go build -o raymond.wasm github.com/aymerick/raymond/cmd/hbs-wasm
+package main
+
+import (
+	"github.com/aymerick/raymond/wasm"
+)
+
+func main() {
+	wasm.Export("raymond")
+
+	// keeps exported functions available
+	select {}
+}
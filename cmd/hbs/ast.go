@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+var astCommand = &command{
+	name:  "ast",
+	args:  "template.hbs",
+	short: "print the parsed AST of a template, as an indented tree or JSON, or its compiled program",
+	run:   runAST,
+}
+
+// runAST runs the ast command
+func runAST(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	format := fs.String("format", "tree", "output format: tree, json, or dump for the compiled program")
+
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one template, got %d", len(files))
+	}
+
+	source, err := readSource(files[0])
+	if err != nil {
+		return err
+	}
+
+	if *format == "dump" {
+		tpl, err := raymond.Parse(source)
+		if err != nil {
+			return err
+		}
+
+		dump, err := tpl.Dump()
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprint(os.Stdout, dump)
+		return err
+	}
+
+	program, err := parser.Parse(source)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "tree":
+		_, err = fmt.Fprint(os.Stdout, ast.Print(program))
+		return err
+	case "json":
+		data, err := ast.JSON(program)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q, expected tree, json or dump", *format)
+	}
+}
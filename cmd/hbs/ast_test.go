@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAST(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs": "{{#if a}}{{b}}{{/if}}",
+		"bad.hbs":  "{{#if a}}",
+	})
+
+	page := filepath.Join(dir, "page.hbs")
+
+	output, err := runCommand(t, "ast", page)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !strings.Contains(output, "BLOCK:") || !strings.Contains(output, "PATH:b") {
+		t.Errorf("Unexpected tree:\n%s", output)
+	}
+
+	output, err = runCommand(t, "ast", page, "-format", "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !json.Valid([]byte(output)) || !strings.Contains(output, `"BlockStatement"`) {
+		t.Errorf("Unexpected JSON:\n%s", output)
+	}
+
+	if output, err = runCommand(t, "ast", page, "-format", "dump"); (err != nil) || (output == "") {
+		t.Errorf("Unexpected dump: %q, %v", output, err)
+	}
+
+	if _, err := runCommand(t, "ast", filepath.Join(dir, "bad.hbs")); err == nil {
+		t.Errorf("Expected a parse error")
+	}
+
+	if _, err := runCommand(t, "ast", page, "-format", "xml"); (err == nil) || !strings.Contains(err.Error(), "unknown format") {
+		t.Errorf("Expected unknown format error, got: %v", err)
+	}
+}
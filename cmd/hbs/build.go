@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aymerick/raymond/ssg"
+)
+
+var buildCommand = &command{
+	name:  "build",
+	args:  "dir",
+	short: "build a static site from a directory of pages, layouts, partials and assets",
+	run:   runBuild,
+}
+
+// runBuild runs the build command
+func runBuild(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	output := fs.String("o", "_site", "output directory")
+	dataFile := fs.String("data", "", "JSON or YAML file of site data, available as @site")
+	layout := fs.String("layout", "", "layout of pages without a layout in their front matter")
+	drafts := fs.Bool("drafts", false, "include pages marked as draft in their front matter")
+
+	dirs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(dirs) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one directory, got %d", len(dirs))
+	}
+
+	site, err := loadData(*dataFile)
+	if err != nil {
+		return err
+	}
+
+	return ssg.Build(dirs[0], *output, ssg.Options{
+		Layout: *layout,
+		Site:   site,
+		Drafts: *drafts,
+	})
+}
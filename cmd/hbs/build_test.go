@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildFiles is the source tree of build tests
+var buildFiles = map[string]string{
+	"index.hbs":           "---\ntitle: Home\n---\n{{#each @pages}}<a href=\"{{url}}\">{{title}}</a>{{/each}}",
+	"blog/first.hbs":      "---\ntitle: First\nlayout: post\n---\n<p>first</p>",
+	"blog/draft.hbs":      "---\ntitle: Draft\ndraft: true\n---\n<p>draft</p>",
+	"layouts/default.hbs": "<html><title>{{title}} - {{@site.name}}</title>{{> nav}}{{{@body}}}</html>",
+	"layouts/post.hbs":    "---\nlayout: default\n---\n<article>{{{@body}}}</article>",
+	"partials/nav.hbs":    "<nav>{{@site.name}}</nav>",
+	"css/site.css":        "body {}",
+	"_data/site.yaml":     "name: YAML site\n",
+	"_data/site.json":     `{"name": "JSON site"}`,
+}
+
+func TestBuild(t *testing.T) {
+	dir := writeFiles(t, buildFiles)
+	output := filepath.Join(dir, "_site")
+
+	if _, err := runCommand(t, "build", dir, "-o", output, "-data", filepath.Join(dir, "_data", "site.yaml"), "-layout", "default"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"index.html":      `<html><title>Home - YAML site</title><nav>YAML site</nav><a href="/blog/first.html">First</a><a href="/">Home</a></html>`,
+		"blog/first.html": `<html><title>First - YAML site</title><nav>YAML site</nav><article><p>first</p></article></html>`,
+		"css/site.css":    "body {}",
+	}
+
+	for name, content := range expected {
+		if data, err := ioutil.ReadFile(filepath.Join(output, filepath.FromSlash(name))); (err != nil) || (string(data) != content) {
+			t.Errorf("Unexpected %s: %q, %v\nexpected: %q", name, data, err, content)
+		}
+	}
+
+	for _, name := range []string{"blog/draft.html", "_data/site.yaml", "layouts/default.html", "partials/nav.html"} {
+		if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("Unexpected output file %s: %v", name, err)
+		}
+	}
+
+	// JSON data, with drafts
+	output = filepath.Join(dir, "_drafts")
+
+	if _, err := runCommand(t, "build", dir, "-o", output, "-data", filepath.Join(dir, "_data", "site.json"), "-layout", "default", "-drafts"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(output, "blog", "draft.html")); (err != nil) || (string(data) != `<html><title>Draft - JSON site</title><nav>JSON site</nav><p>draft</p></html>`) {
+		t.Errorf("Unexpected draft page: %q, %v", data, err)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"index.hbs": "{{> missing}}",
+		"site.toml": "",
+	})
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no directory", nil},
+		{"unsupported data file", []string{dir, "-data", filepath.Join(dir, "site.toml")}},
+		{"missing partial", []string{dir, "-o", filepath.Join(dir, "_site")}},
+	}
+
+	for _, test := range tests {
+		if _, err := runCommand(t, "build", test.args...); err == nil {
+			t.Errorf("Test '%s' failed - Expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+var compatCommand = &command{
+	name:  "compat",
+	args:  "template.hbs",
+	short: "render a template with raymond and with handlebars.js, and print the diff of outputs",
+	run:   runCompat,
+}
+
+// compatScript renders the template, partials and data read as JSON from stdin with handlebars.js, that is required from the current directory or NODE_PATH
+const compatScript = `
+let input = "";
+process.stdin.setEncoding("utf8");
+process.stdin.on("data", (chunk) => { input += chunk; });
+process.stdin.on("end", () => {
+  try {
+    const Handlebars = require("handlebars");
+    const req = JSON.parse(input);
+    for (const name in req.partials) {
+      Handlebars.registerPartial(name, req.partials[name]);
+    }
+    process.stdout.write(Handlebars.compile(req.template)(req.data));
+  } catch (err) {
+    process.stderr.write(String(err.message));
+    process.exitCode = 1;
+  }
+});
+`
+
+// compatInput is the input of compatScript
+type compatInput struct {
+	Template string            `json:"template"`
+	Partials map[string]string `json:"partials"`
+	Data     interface{}       `json:"data"`
+}
+
+// jsRenderer renders a compat input with handlebars.js, with given node executable. It is replaced in tests, that don't depend on node.
+var jsRenderer = renderJS
+
+// runCompat runs the compat command
+//
+// It exits with status 1 if outputs differ, and with status 2 if they can't be compared, ie. when node or handlebars.js is not available, or when a render fails.
+func runCompat(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	dataFile := fs.String("data", "", "JSON or YAML context file, by extension (.json, .yaml or .yml), or - to read JSON from stdin")
+	partialsDir := fs.String("partials", "", "directory of partials, named after their path relative to directory, without extension")
+	jsNumbers := fs.Bool("jsnumbers", false, "render numbers like JavaScript, with the JSNumbers parse option")
+	nodeBin := fs.String("node", "node", "node executable")
+
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one template, got %d", len(files))
+	}
+
+	source, err := readSource(files[0])
+	if err != nil {
+		return err
+	}
+
+	partials := make(map[string]string)
+	if *partialsDir != "" {
+		if partials, err = loadPartials(*partialsDir); err != nil {
+			return err
+		}
+	}
+
+	ctx, err := loadData(*dataFile)
+	if err != nil {
+		return err
+	}
+
+	expected, jsErr := jsRenderer(*nodeBin, compatInput{Template: source, Partials: partials, Data: ctx})
+	output, err := renderSource(source, ctx, partials, raymond.ParseOptions{JSNumbers: *jsNumbers})
+
+	switch {
+	case (jsErr != nil) && (err != nil):
+		fmt.Fprintf(os.Stderr, "raymond: %s\nhandlebars.js: %s\n", err, jsErr)
+		return exitCode(2)
+	case jsErr != nil:
+		fmt.Fprintf(os.Stderr, "handlebars.js: %s\n", jsErr)
+		return exitCode(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "raymond: %s\n", err)
+		return exitCode(2)
+	}
+
+	if diff := unifiedDiff("handlebars.js", "raymond", expected, output); diff != "" {
+		fmt.Print(diff)
+		return exitCode(1)
+	}
+
+	fmt.Println("Outputs are identical")
+
+	return nil
+}
+
+// renderJS renders given input with handlebars.js, by running given node executable
+func renderJS(nodeBin string, input compatInput) (string, error) {
+	path, err := exec.LookPath(nodeBin)
+	if err != nil {
+		return "", fmt.Errorf("node is not available: %s", err)
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("context can't be converted to JSON: %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	c := exec.Command(path, "-e", compatScript)
+	c.Stdin = bytes.NewReader(data)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	if err := c.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+
+		if strings.Contains(msg, "Cannot find module 'handlebars'") {
+			return "", fmt.Errorf("handlebars.js is not available: install it with `npm install handlebars`, or set NODE_PATH")
+		}
+
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return "", fmt.Errorf("%s", msg)
+	}
+
+	return stdout.String(), nil
+}
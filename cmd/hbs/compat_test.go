@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubJSRenderer replaces the handlebars.js renderer with given function, for the duration of test
+func stubJSRenderer(t *testing.T, render func(nodeBin string, input compatInput) (string, error)) {
+	t.Helper()
+
+	previous := jsRenderer
+	jsRenderer = render
+
+	t.Cleanup(func() { jsRenderer = previous })
+}
+
+func TestCompat(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs":            "{{> header}}{{#each items}}{{this}} {{/each}}",
+		"partials/header.hbs": "<h1>{{title}}</h1>\n",
+		"data.yaml":           "title: Hello\nitems: [1, 2.5]\n",
+	})
+
+	args := []string{filepath.Join(dir, "page.hbs"), "-data", filepath.Join(dir, "data.yaml"), "-partials", filepath.Join(dir, "partials"), "-node", "nodejs"}
+
+	var received compatInput
+
+	stubJSRenderer(t, func(nodeBin string, input compatInput) (string, error) {
+		if nodeBin != "nodejs" {
+			t.Errorf("Unexpected node executable: %q", nodeBin)
+		}
+
+		received = input
+		return "<h1>Hello</h1>\n1 2.5 ", nil
+	})
+
+	output, err := runCommand(t, "compat", args...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "Outputs are identical\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if received.Template != "{{> header}}{{#each items}}{{this}} {{/each}}" || !reflect.DeepEqual(received.Partials, map[string]string{"header": "<h1>{{title}}</h1>\n"}) {
+		t.Errorf("Unexpected handlebars.js input: %#v", received)
+	}
+
+	// outputs differ
+	stubJSRenderer(t, func(nodeBin string, input compatInput) (string, error) {
+		return "<h1>Hello</h1>\n1 2.50 ", nil
+	})
+
+	output, err = runCommand(t, "compat", args...)
+	if err != exitCode(1) {
+		t.Errorf("Expected exit code 1, got: %v", err)
+	}
+
+	for _, expected := range []string{"--- handlebars.js", "+++ raymond", " <h1>Hello</h1>", "-1 2.50 ", "+1 2.5 "} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected diff to contain %q, got:\n%s", expected, output)
+		}
+	}
+
+	// outputs can't be compared
+	stubJSRenderer(t, func(nodeBin string, input compatInput) (string, error) {
+		return "", fmt.Errorf("handlebars.js is not available")
+	})
+
+	if _, err := runCommand(t, "compat", args...); err != exitCode(2) {
+		t.Errorf("Expected exit code 2 on handlebars.js error, got: %v", err)
+	}
+
+	stubJSRenderer(t, func(nodeBin string, input compatInput) (string, error) {
+		return "", nil
+	})
+
+	if _, err := runCommand(t, "compat", filepath.Join(dir, "page.hbs")); err != exitCode(2) {
+		t.Errorf("Expected exit code 2 on raymond error, got: %v", err)
+	}
+}
+
+func TestRenderJSWithoutNode(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	if _, err := renderJS("node", compatInput{Template: "x"}); (err == nil) || !strings.HasPrefix(err.Error(), "node is not available") {
+		t.Errorf("Expected node error, got: %v", err)
+	}
+
+	dir := writeFiles(t, map[string]string{"page.hbs": "x"})
+
+	if _, err := runCommand(t, "compat", filepath.Join(dir, "page.hbs")); err != exitCode(2) {
+		t.Errorf("Expected exit code 2 without node, got: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines printed around changes
+const diffContext = 3
+
+// diffLine is a line of a diff
+type diffLine struct {
+	// ' ' for unchanged line, '-' for removed line and '+' for added line
+	op byte
+
+	// line, without its newline
+	text string
+
+	// line numbers in old and new texts, starting at 1
+	oldLine, newLine int
+}
+
+// unifiedDiff returns the unified diff between given old and new texts, with their names, or an empty string if they are equal
+func unifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(lines); {
+		// find next change
+		for (start < len(lines)) && (lines[start].op == ' ') {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// extend hunk until changes are separated by more than twice the context
+		end, unchanged := start, 0
+		for i := start; (i < len(lines)) && (unchanged <= 2*diffContext); i++ {
+			if lines[i].op == ' ' {
+				unchanged++
+			} else {
+				end, unchanged = i+1, 0
+			}
+		}
+
+		first, last := maxInt(start-diffContext, 0), minInt(end+diffContext, len(lines))
+		writeHunk(&b, lines[first:last])
+
+		start = last
+	}
+
+	return b.String()
+}
+
+// writeHunk writes given diff lines as a unified diff hunk
+func writeHunk(b *strings.Builder, lines []diffLine) {
+	var oldCount, newCount int
+	for _, line := range lines {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", hunkStart(lines[0].oldLine, oldCount), oldCount, hunkStart(lines[0].newLine, newCount), newCount)
+
+	for _, line := range lines {
+		fmt.Fprintf(b, "%c%s\n", line.op, line.text)
+	}
+}
+
+// hunkStart returns the start line of a hunk side, that is 0 for an empty side
+func hunkStart(line int, count int) int {
+	if count == 0 {
+		return line - 1
+	}
+
+	return line
+}
+
+// splitLines splits given text into lines, without their newlines
+func splitLines(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the diff between given old and new lines, computed with their longest common subsequence
+func diffLines(oldLines []string, newLines []string) []diffLine {
+	// lcs[i][j] is the length of longest common subsequence of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = maxInt(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var result []diffLine
+
+	i, j := 0, 0
+	for (i < len(oldLines)) || (j < len(newLines)) {
+		switch {
+		case (i < len(oldLines)) && (j < len(newLines)) && (oldLines[i] == newLines[j]):
+			result = append(result, diffLine{' ', oldLines[i], i + 1, j + 1})
+			i, j = i+1, j+1
+		case (j == len(newLines)) || ((i < len(oldLines)) && (lcs[i+1][j] >= lcs[i][j+1])):
+			result = append(result, diffLine{'-', oldLines[i], i + 1, j + 1})
+			i++
+		default:
+			result = append(result, diffLine{'+', newLines[j], i + 1, j + 1})
+			j++
+		}
+	}
+
+	return result
+}
+
+// minInt returns the smaller of given integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// maxInt returns the larger of given integers
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
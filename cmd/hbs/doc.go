@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+var docCommand = &command{
+	name:  "doc",
+	args:  "path...",
+	short: "document the context, partials and helpers of templates, as Markdown or JSON",
+	run:   runDoc,
+}
+
+// runDoc runs the doc command
+func runDoc(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	format := fs.String("format", "markdown", "output format: markdown or json")
+	output := fs.String("o", "", "output file, instead of stdout")
+	helperNames := fs.String("helpers", "", "comma separated names of helpers registered by the application, so that they are not documented as context values")
+
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one path")
+	}
+
+	if (*format != "markdown") && (*format != "json") {
+		return fmt.Errorf("unknown format %q, expected markdown or json", *format)
+	}
+
+	templates := make(map[string]*raymond.Template)
+
+	for _, path := range paths {
+		files, err := templateFiles(path)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			source, err := readSource(file)
+			if err != nil {
+				return err
+			}
+
+			tpl, err := raymond.Parse(source)
+			if err != nil {
+				return fmt.Errorf("%s: %s", file, err)
+			}
+
+			if *helperNames != "" {
+				for _, name := range strings.Split(*helperNames, ",") {
+					tpl.RegisterHelper(strings.TrimSpace(name), func(args ...interface{}) string { return "" })
+				}
+			}
+
+			templates[file] = tpl
+		}
+	}
+
+	docs, err := raymond.Document(templates)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(docs, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		buf.Write(append(data, '\n'))
+	} else if err := raymond.WriteDocsMarkdown(&buf, docs); err != nil {
+		return err
+	}
+
+	return writeOutput(*output, buf.Bytes())
+}
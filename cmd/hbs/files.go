@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymerick/raymond/internal/yamlconv"
+	"gopkg.in/yaml.v2"
+)
+
+// templateExts are the extensions of template files
+var templateExts = map[string]bool{
+	".hbs":        true,
+	".handlebars": true,
+}
+
+// isTemplateFile returns true if given file path has a template extension
+func isTemplateFile(filePath string) bool {
+	return templateExts[filepath.Ext(filePath)]
+}
+
+// loadPartials returns the sources of all template files found in given directory and its subdirectories, by name
+//
+// Partials are named after their path relative to directory, without extension: dir/layouts/main.hbs => layouts/main
+func loadPartials(dir string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !isTemplateFile(filePath) {
+			return nil
+		}
+
+		source, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		result[filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))] = string(source)
+
+		return nil
+	})
+
+	return result, err
+}
+
+// readSource returns the content of given template file, or reads it from stdin if file path is "-"
+func readSource(filePath string) (string, error) {
+	var data []byte
+	var err error
+
+	if filePath == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filePath)
+	}
+
+	return string(data), err
+}
+
+// loadData returns the context decoded from given JSON or YAML file, nil if file path is empty, or decoded from stdin if file path is "-"
+func loadData(filePath string) (interface{}, error) {
+	var data []byte
+	var err error
+
+	switch filePath {
+	case "":
+		return nil, nil
+	case "-":
+		data, err = ioutil.ReadAll(os.Stdin)
+	default:
+		data, err = ioutil.ReadFile(filePath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+
+	switch ext := filepath.Ext(filePath); ext {
+	case ".json", "":
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("Failed to parse JSON file %s: %s", filePath, err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("Failed to parse YAML file %s: %s", filePath, err)
+		}
+		result = yamlconv.Value(result)
+	default:
+		return nil, fmt.Errorf("Unsupported data file extension %s, expected .json, .yaml or .yml", ext)
+	}
+
+	return result, nil
+}
+
+// writeOutput writes given data to given file, or to stdout if file path is empty
+func writeOutput(filePath string, data []byte) error {
+	if filePath == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, data, 0644)
+}
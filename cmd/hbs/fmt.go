@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aymerick/raymond"
+)
+
+var fmtCommand = &command{
+	name:  "fmt",
+	args:  "path...",
+	short: "format templates in canonical format, or report unformatted ones with a diff",
+	run:   runFmt,
+}
+
+// runFmt runs the fmt command
+//
+// Without -w, it exits with status 1 if a template is not formatted. In all modes, it exits with status 2 if a template can't be read or parsed.
+func runFmt(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	write := fs.Bool("w", false, "write formatted templates to their files instead of reporting diffs")
+	list := fs.Bool("l", false, "only list unformatted templates, without their diffs")
+
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one path")
+	}
+
+	var unformatted, failed bool
+
+	for _, path := range paths {
+		if path == "-" {
+			// format stdin to stdout
+			if err := formatStdin(); err != nil {
+				fmt.Fprintf(os.Stderr, "hbs fmt: <stdin>: %s\n", err)
+				failed = true
+			}
+			continue
+		}
+
+		files, err := templateFiles(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hbs fmt: %s\n", err)
+			failed = true
+			continue
+		}
+
+		for _, file := range files {
+			changed, err := formatFile(file, *write, *list)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "hbs fmt: %s: %s\n", file, err)
+				failed = true
+			}
+
+			unformatted = unformatted || changed
+		}
+	}
+
+	switch {
+	case failed:
+		return exitCode(2)
+	case unformatted && !*write:
+		return exitCode(1)
+	default:
+		return nil
+	}
+}
+
+// templateFiles returns given file path, or the template files found in given directory and its subdirectories
+func templateFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var result []string
+
+	err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && isTemplateFile(filePath) {
+			result = append(result, filePath)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// formatFile formats given template file, and returns true if it was not formatted
+//
+// If write is true, file is overwritten with formatted template. Otherwise, file path is printed if list is true, or the diff if not.
+func formatFile(filePath string, write bool, list bool) (bool, error) {
+	source, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	formatted, err := raymond.Format(string(source))
+	if err != nil {
+		return false, err
+	}
+
+	if formatted == string(source) {
+		return false, nil
+	}
+
+	switch {
+	case write:
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return true, err
+		}
+
+		return true, ioutil.WriteFile(filePath, []byte(formatted), info.Mode().Perm())
+	case list:
+		fmt.Println(filePath)
+	default:
+		fmt.Print(unifiedDiff(filePath, filePath, string(source), formatted))
+	}
+
+	return true, nil
+}
+
+// formatStdin writes the template read from stdin to stdout, formatted
+func formatStdin() error {
+	source, err := readSource("-")
+	if err != nil {
+		return err
+	}
+
+	formatted, err := raymond.Format(source)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.WriteString(formatted)
+	return err
+}
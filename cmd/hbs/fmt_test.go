@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFmt(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ok.hbs":        "{{foo}}\n",
+		"sub/ugly.hbs":  "{{ foo   }}\n",
+		"not-a-tpl.txt": "{{ foo   }}\n",
+	})
+
+	ugly := filepath.Join(dir, "sub", "ugly.hbs")
+
+	// check mode reports a diff, and exits with status 1
+	output, err := runCommand(t, "fmt", dir)
+	if err != exitCode(1) {
+		t.Errorf("Expected exit status 1, got: %v", err)
+	}
+
+	if !strings.Contains(output, "-{{ foo   }}\n+{{foo}}\n") || strings.Contains(output, "ok.hbs") || strings.Contains(output, "not-a-tpl.txt") {
+		t.Errorf("Unexpected diff: %q", output)
+	}
+
+	// list mode only prints unformatted files
+	if output, err := runCommand(t, "fmt", "-l", dir); (err != exitCode(1)) || (output != ugly+"\n") {
+		t.Errorf("Unexpected list: %q, %v", output, err)
+	}
+
+	// write mode formats files, and succeeds
+	if output, err := runCommand(t, "fmt", "-w", dir); (err != nil) || (output != "") {
+		t.Errorf("Unexpected write mode result: %q, %v", output, err)
+	}
+
+	if data, _ := ioutil.ReadFile(ugly); string(data) != "{{foo}}\n" {
+		t.Errorf("Template not formatted: %q", data)
+	}
+
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "not-a-tpl.txt")); string(data) != "{{ foo   }}\n" {
+		t.Errorf("Non template file formatted: %q", data)
+	}
+
+	// formatted templates pass check mode
+	if output, err := runCommand(t, "fmt", dir); (err != nil) || (output != "") {
+		t.Errorf("Unexpected check of formatted templates: %q, %v", output, err)
+	}
+}
+
+func TestFmtErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.hbs": "{{#if a}}",
+	})
+
+	// templates that can't be read or parsed exit with status 2
+	for _, path := range []string{filepath.Join(dir, "bad.hbs"), filepath.Join(dir, "unknown.hbs")} {
+		if _, err := runCommand(t, "fmt", path); err != exitCode(2) {
+			t.Errorf("Expected exit status 2 for %s, got: %v", path, err)
+		}
+	}
+}
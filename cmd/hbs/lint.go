@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/parser"
+)
+
+var lintCommand = &command{
+	name:  "lint",
+	args:  "path...",
+	short: "check templates with lint rules, as text or JSON",
+	run:   runLint,
+}
+
+// parseRule is the rule name of parse errors, that can't be disabled
+const parseRule = "parse"
+
+// jsonIssue is the JSON representation of a lint issue
+type jsonIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// runLint runs the lint command
+//
+// It exits with status 1 if an issue is found.
+func runLint(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	format := fs.String("format", "text", "output format: text or json")
+	partialsDir := fs.String("partials", "", "directory of partials, named after their path relative to directory, without extension, in addition to the templates of linted directories")
+	helperNames := fs.String("helpers", "", "comma separated names of helpers registered by the application, that accept any arguments")
+
+	enabled := make(map[*raymond.LintRule]*bool, len(raymond.LintRules))
+	for _, rule := range raymond.LintRules {
+		enabled[rule] = fs.Bool(rule.Name, !rule.Optional, "enable rule: "+rule.Description)
+	}
+
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one path")
+	}
+
+	if (*format != "text") && (*format != "json") {
+		return fmt.Errorf("unknown format %q, expected text or json", *format)
+	}
+
+	var rules []*raymond.LintRule
+	for _, rule := range raymond.LintRules {
+		if *enabled[rule] {
+			rules = append(rules, rule)
+		}
+	}
+
+	partials := make(map[string]string)
+	if *partialsDir != "" {
+		if partials, err = loadPartials(*partialsDir); err != nil {
+			return err
+		}
+	}
+
+	var files []string
+
+	for _, path := range paths {
+		found, err := templateFiles(path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, found...)
+
+		if info, err := os.Stat(path); (err == nil) && info.IsDir() {
+			dirPartials, err := loadPartials(path)
+			if err != nil {
+				return err
+			}
+
+			for name, source := range dirPartials {
+				partials[name] = source
+			}
+		}
+	}
+
+	var issues []raymond.LintIssue
+	templates := make(map[string]*raymond.Template, len(files))
+
+	for _, file := range files {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		// invalid UTF-8 sequences are parse errors
+		tpl, err := raymond.ParseWithOptions(string(source), raymond.ParseOptions{InvalidUTF8: raymond.UTF8Error})
+		if err != nil {
+			issues = append(issues, parseIssue(file, err))
+			continue
+		}
+
+		tpl.RegisterPartials(partials)
+
+		if *helperNames != "" {
+			for _, name := range strings.Split(*helperNames, ",") {
+				tpl.RegisterHelper(strings.TrimSpace(name), func(args ...interface{}) string { return "" })
+			}
+		}
+
+		templates[file] = tpl
+	}
+
+	found, err := raymond.Lint(templates, rules)
+	if err != nil {
+		return err
+	}
+
+	issues = append(issues, found...)
+
+	if err := printIssues(issues, *format); err != nil {
+		return err
+	}
+
+	if len(issues) > 0 {
+		return exitCode(1)
+	}
+
+	return nil
+}
+
+// parseIssue returns the lint issue of given parse error of given file
+func parseIssue(file string, err error) raymond.LintIssue {
+	result := raymond.LintIssue{Template: file, Rule: parseRule, Message: err.Error()}
+
+	var parseErr *parser.Error
+	if errors.As(err, &parseErr) {
+		result.Line, result.Message = parseErr.Line, strings.Replace(parseErr.Message, "\n", " ", -1)
+	}
+
+	return result
+}
+
+// printIssues prints given issues to stdout, with given format
+func printIssues(issues []raymond.LintIssue, format string) error {
+	if format == "text" {
+		for _, issue := range issues {
+			fmt.Printf("%s:%d: %s (%s)\n", issue.Template, issue.Line, issue.Message, issue.Rule)
+		}
+
+		return nil
+	}
+
+	result := make([]jsonIssue, len(issues))
+	for i, issue := range issues {
+		result[i] = jsonIssue{
+			File:    issue.Template,
+			Line:    issue.Line,
+			Rule:    issue.Rule,
+			Message: issue.Message,
+		}
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeOutput("", append(data, '\n'))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs":   "{{> header}}\n{{> missing}}\n{{myHelper 1}}",
+		"header.hbs": "<h1>{{title}}</h1>",
+	})
+
+	page := filepath.Join(dir, "page.hbs")
+
+	output, err := runCommand(t, "lint", page, "-helpers", "myHelper")
+	if err != exitCode(1) {
+		t.Errorf("Expected exit status 1, got: %v", err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); (len(lines) != 2) || !strings.HasPrefix(lines[0], page+":1: ") || !strings.Contains(lines[0], "(unknown-partial)") || !strings.HasPrefix(lines[1], page+":2: ") {
+		t.Errorf("Unexpected issues:\n%s", output)
+	}
+
+	// JSON format
+	output, err = runCommand(t, "lint", page, "-format", "json", "-helpers", "myHelper", "-partials", dir)
+	if err != exitCode(1) {
+		t.Errorf("Expected exit status 1, got: %v", err)
+	}
+
+	var issues []jsonIssue
+	if err := json.Unmarshal([]byte(output), &issues); err != nil {
+		t.Fatalf("Invalid JSON output: %s\n%s", err, output)
+	}
+
+	if (len(issues) != 1) || (issues[0] != jsonIssue{File: page, Line: 2, Rule: "unknown-partial", Message: issues[0].Message}) {
+		t.Errorf("Unexpected issues: %+v", issues)
+	}
+
+	// no issue
+	if output, err := runCommand(t, "lint", filepath.Join(dir, "header.hbs")); (err != nil) || (output != "") {
+		t.Errorf("Unexpected result without issues: %q, %v", output, err)
+	}
+
+	if output, err := runCommand(t, "lint", filepath.Join(dir, "header.hbs"), "-format", "json"); (err != nil) || (output != "[]\n") {
+		t.Errorf("Unexpected JSON result without issues: %q, %v", output, err)
+	}
+}
+
+func TestLintParseError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.hbs": "ok\n{{#if a}}",
+	})
+
+	output, err := runCommand(t, "lint", dir, "-format", "json")
+	if err != exitCode(1) {
+		t.Errorf("Expected exit status 1, got: %v", err)
+	}
+
+	var issues []jsonIssue
+	if err := json.Unmarshal([]byte(output), &issues); err != nil {
+		t.Fatalf("Invalid JSON output: %s\n%s", err, output)
+	}
+
+	if (len(issues) != 1) || (issues[0].Rule != parseRule) || (issues[0].File != filepath.Join(dir, "bad.hbs")) {
+		t.Errorf("Unexpected issues: %+v", issues)
+	}
+}
+
+func TestLintErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs": "{{foo}}",
+	})
+
+	if _, err := runCommand(t, "lint"); err == nil {
+		t.Errorf("Expected an error without path")
+	}
+
+	if _, err := runCommand(t, "lint", dir, "-format", "xml"); (err == nil) || !strings.Contains(err.Error(), "unknown format") {
+		t.Errorf("Expected unknown format error, got: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/lsp"
+)
+
+var lspCommand = &command{
+	name:  "lsp",
+	short: "runs a language server on stdin and stdout, for editors",
+	run:   runLSP,
+}
+
+// runLSP runs the lsp command
+func runLSP(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	partialsDir := fs.String("partials", "", "directory of partials, named after their path relative to directory, without extension (default: directory of each template)")
+	schemaFile := fs.String("schema", "", "JSON Schema file describing the context of templates, to complete paths")
+	helperNames := fs.String("helpers", "", "comma separated names of helpers registered by the application, that accept any arguments")
+
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+
+	opts := lsp.Options{PartialsDir: *partialsDir}
+
+	if *schemaFile != "" {
+		data, err := ioutil.ReadFile(*schemaFile)
+		if err != nil {
+			return err
+		}
+
+		opts.Schema = &lsp.Schema{}
+		if err := json.Unmarshal(data, opts.Schema); err != nil {
+			return fmt.Errorf("%s: %s", *schemaFile, err)
+		}
+	}
+
+	if *helperNames != "" {
+		for _, name := range strings.Split(*helperNames, ",") {
+			opts.Helpers = append(opts.Helpers, raymond.HelperInfo{Name: strings.TrimSpace(name), Variadic: true})
+		}
+	}
+
+	return lsp.NewServer(opts).Serve(os.Stdin, os.Stdout)
+}
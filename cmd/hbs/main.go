@@ -0,0 +1,152 @@
+// Command hbs renders handlebars templates from the command line, so that they can be used from shell scripts and CI without writing Go.
+//
+// Usage:
+//
+//	hbs <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	render      renders a template with a JSON or YAML context
+//	tokens      prints the tokens of a template, with their position
+//	ast         prints the parsed AST of a template
+//	fmt         formats templates in canonical format, or reports unformatted ones
+//	lint        checks templates with lint rules
+//	doc         documents the context, partials and helpers of templates
+//	precompile  precompiles a directory of templates to a binary bundle, to Go source code or to JavaScript
+//	watch       re-renders a template whenever it, its partials or its context change
+//	compat      renders a template with raymond and with handlebars.js, and diffs outputs
+//	lsp         runs a language server on stdin and stdout, for editors
+//	build       builds a static site from a directory of pages, layouts, partials and assets
+//
+// Run `hbs <command> -h` for the flags of a command. Flags can be given before or after arguments.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command is a hbs subcommand
+type command struct {
+	// name of command
+	name string
+
+	// arguments of command, after its flags
+	args string
+
+	// short description of command
+	short string
+
+	// runs command with given arguments, that do not include command name
+	run func(cmd *command, args []string) error
+}
+
+// commands are all hbs subcommands
+var commands = []*command{
+	renderCommand,
+	tokensCommand,
+	astCommand,
+	fmtCommand,
+	lintCommand,
+	docCommand,
+	precompileCommand,
+	watchCommand,
+	compatCommand,
+	lspCommand,
+	buildCommand,
+}
+
+// exitCode is an error that makes hbs exit with given status code, without printing a message
+type exitCode int
+
+// Error implements the error interface
+func (code exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(code))
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd := findCommand(flag.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "hbs: unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(cmd, flag.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+
+		fmt.Fprintf(os.Stderr, "hbs %s: %s\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+// usage prints hbs usage
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: hbs <command> [flags] [arguments]\n\nCommands:\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.short)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun 'hbs <command> -h' for the flags of a command.\n")
+}
+
+// findCommand returns the command with given name, or nil if not found
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+// flagSet returns a new flag set for command
+func (cmd *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: hbs %s [flags] %s\n\nFlags:\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses given arguments with given flag set, and returns positional arguments
+//
+// Contrary to fs.Parse(), flags can be given after positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var result []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return result, nil
+		}
+
+		result = append(result, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes given files, by path relative to a new temporary directory, and returns that directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// runCommand runs the command with given name and arguments, and returns what it printed to stdout, discarding what it printed to stderr
+//
+// Commands print to os.Stdout and os.Stderr, so tests running commands can't be parallel.
+func runCommand(t *testing.T, name string, args ...string) (string, error) {
+	t.Helper()
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		t.Fatalf("Unknown command %q", name)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, devNull
+
+	output := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		output <- string(data)
+	}()
+
+	err = cmd.run(cmd, args)
+
+	os.Stdout, os.Stderr = stdout, stderr
+	w.Close()
+
+	return string(<-output), err
+}
+
+func TestParseFlags(t *testing.T) {
+	t.Parallel()
+
+	fs := renderCommand.flagSet()
+	data := fs.String("data", "", "")
+
+	args, err := parseFlags(fs, []string{"a.hbs", "-data", "ctx.json", "b.hbs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (len(args) != 2) || (args[0] != "a.hbs") || (args[1] != "b.hbs") || (*data != "ctx.json") {
+		t.Errorf("Unexpected arguments %v and data flag %q", args, *data)
+	}
+}
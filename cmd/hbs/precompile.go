@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/hbsgen"
+	"github.com/aymerick/raymond/jsgen"
+)
+
+var precompileCommand = &command{
+	name:  "precompile",
+	args:  "dir",
+	short: "precompile a directory of templates to a binary bundle, to Go source code or to JavaScript",
+	run:   runPrecompile,
+}
+
+// runPrecompile runs the precompile command
+func runPrecompile(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	format := fs.String("format", "binary", "output format: binary, for a bundle loaded with raymond.ParseBundle(), go, for code generated by hbsgen, or js, for the handlebars.js runtime")
+	output := fs.String("o", "", "output file (defaults to stdout)")
+	types := fs.String("types", "", "go format: comma separated template:Type pairs, of the templates to generate render functions for, with their context type")
+	pkgDir := fs.String("dir", ".", "go format: directory of the Go package that declares context types")
+	pkg := fs.String("pkg", "", "go format: name of generated package (defaults to the package found in -dir)")
+	namespace := fs.String("namespace", jsgen.DefaultNamespace, "js format: JavaScript object where templates are registered")
+
+	dirs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(dirs) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one directory, got %d", len(dirs))
+	}
+
+	// templates are named like partials, so that they reference each other by name
+	sources, err := loadPartials(dirs[0])
+	if err != nil {
+		return err
+	}
+
+	var result []byte
+
+	switch *format {
+	case "binary":
+		result, err = precompileBinary(sources)
+	case "go":
+		result, err = precompileGo(sources, *types, *pkgDir, *pkg)
+	case "js":
+		// templates are also registered as partials, like they are in other formats
+		result, err = jsgen.Generate(jsgen.Options{Templates: sources, Partials: sources, Namespace: *namespace})
+	default:
+		return fmt.Errorf("unknown format %q, expected binary, go or js", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, result)
+}
+
+// precompileBinary returns the bundle of given template sources
+func precompileBinary(sources map[string]string) ([]byte, error) {
+	templates := make(map[string]*raymond.Template, len(sources))
+
+	for name, source := range sources {
+		tpl, err := raymond.Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+
+		templates[name] = tpl
+	}
+
+	return raymond.MarshalBundle(templates)
+}
+
+// precompileGo returns the Go source code generated for given template sources
+//
+// Render functions are generated for the templates listed in types, and all templates are available as partials.
+func precompileGo(sources map[string]string, types string, pkgDir string, pkg string) ([]byte, error) {
+	if types == "" {
+		return nil, fmt.Errorf("go format requires the -types flag")
+	}
+
+	opts := hbsgen.Options{
+		Dir:      pkgDir,
+		Package:  pkg,
+		Partials: sources,
+	}
+
+	for _, spec := range strings.Split(types, ",") {
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("Invalid template %q, expected: template:Type", spec)
+		}
+
+		name := strings.TrimSpace(spec[:i])
+
+		source, ok := sources[name]
+		if !ok {
+			return nil, fmt.Errorf("Template %q not found, expected one of: %s", name, strings.Join(sortedNames(sources), ", "))
+		}
+
+		opts.Templates = append(opts.Templates, hbsgen.Template{
+			Name:   name,
+			Source: source,
+			Type:   strings.TrimSpace(spec[i+1:]),
+		})
+	}
+
+	return hbsgen.Generate(opts)
+}
+
+// sortedNames returns the sorted keys of given map
+func sortedNames(sources map[string]string) []string {
+	result := make([]string, 0, len(sources))
+	for name := range sources {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
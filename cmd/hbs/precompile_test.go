@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+// precompileFiles are the templates of precompile tests
+var precompileFiles = map[string]string{
+	"templates/page.hbs":         "<h1>{{Title}}</h1>{{#each Posts}}{{> posts/item}}{{/each}}",
+	"templates/posts/item.hbs":   "<p>{{Title}}</p>",
+	"templates/posts/README.txt": "not a template",
+	"site.go":                    "package site\n\ntype Page struct {\n\tTitle string\n\tPosts []Post\n}\n\ntype Post struct {\n\tTitle string\n}\n",
+}
+
+func TestPrecompileBinary(t *testing.T) {
+	dir := writeFiles(t, precompileFiles)
+	outFile := filepath.Join(dir, "templates.hbb")
+
+	if _, err := runCommand(t, "precompile", filepath.Join(dir, "templates"), "-o", outFile); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := raymond.ParseBundle(data)
+	if err != nil {
+		t.Fatalf("Failed to parse bundle: %s", err)
+	}
+
+	if len(templates) != 2 || templates["page"] == nil || templates["posts/item"] == nil {
+		t.Fatalf("Unexpected bundle templates: %v", templates)
+	}
+
+	output, err := templates["page"].Exec(map[string]interface{}{"Title": "Blog", "Posts": []map[string]string{{"Title": "a"}, {"Title": "b"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "<h1>Blog</h1><p>a</p><p>b</p>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestPrecompileJS(t *testing.T) {
+	dir := writeFiles(t, precompileFiles)
+
+	output, err := runCommand(t, "precompile", filepath.Join(dir, "templates"), "-format", "js", "-namespace", "App.templates")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, expected := range []string{
+		"templates = App.templates = App.templates || {};",
+		`templates["page"] = template(`,
+		`templates["posts/item"] = template(`,
+		`Handlebars.registerPartial("posts/item", template(`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestPrecompileGo(t *testing.T) {
+	dir := writeFiles(t, precompileFiles)
+
+	output, err := runCommand(t, "precompile", filepath.Join(dir, "templates"), "-format", "go", "-types", "page:Page", "-dir", dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, expected := range []string{"package site", "func RenderPage(w io.Writer, ctx *Page) error {"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestPrecompileErrors(t *testing.T) {
+	dir := writeFiles(t, precompileFiles)
+	templates := filepath.Join(dir, "templates")
+
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"no directory", nil, "expected one directory, got 0"},
+		{"unknown format", []string{templates, "-format", "yaml"}, `unknown format "yaml"`},
+		{"go format without types", []string{templates, "-format", "go", "-dir", dir}, "go format requires the -types flag"},
+		{"invalid type", []string{templates, "-format", "go", "-types", "page", "-dir", dir}, `Invalid template "page"`},
+		{"unknown template", []string{templates, "-format", "go", "-types", "home:Page", "-dir", dir}, `Template "home" not found, expected one of: page, posts/item`},
+	}
+
+	for _, test := range tests {
+		if _, err := runCommand(t, "precompile", test.args...); (err == nil) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test '%s' failed - Expected error %q, got: %v", test.name, test.err, err)
+		}
+	}
+}
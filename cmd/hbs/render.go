@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aymerick/raymond"
+)
+
+var renderCommand = &command{
+	name:  "render",
+	args:  "template.hbs",
+	short: "render a template with a JSON or YAML context, to stdout or to a file",
+	run:   runRender,
+}
+
+// runRender runs the render command
+func runRender(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	dataFile := fs.String("data", "", "JSON or YAML context file, by extension (.json, .yaml or .yml), or - to read JSON from stdin")
+	partialsDir := fs.String("partials", "", "directory of partials, named after their path relative to directory, without extension")
+	output := fs.String("o", "", "output file (defaults to stdout)")
+
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one template, got %d", len(files))
+	}
+
+	result, err := render(files[0], *dataFile, *partialsDir, raymond.ParseOptions{})
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, []byte(result))
+}
+
+// render renders given template file parsed with given options, with the context of given data file and the partials of given directory
+func render(filePath string, dataFile string, partialsDir string, opts raymond.ParseOptions) (string, error) {
+	source, err := readSource(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	var partials map[string]string
+	if partialsDir != "" {
+		if partials, err = loadPartials(partialsDir); err != nil {
+			return "", err
+		}
+	}
+
+	ctx, err := loadData(dataFile)
+	if err != nil {
+		return "", err
+	}
+
+	return renderSource(source, ctx, partials, opts)
+}
+
+// renderSource renders given template source parsed with given options, with given context and partials
+func renderSource(source string, ctx interface{}, partials map[string]string, opts raymond.ParseOptions) (string, error) {
+	tpl, err := raymond.ParseWithOptions(source, opts)
+	if err != nil {
+		return "", err
+	}
+
+	tpl.RegisterPartials(partials)
+
+	return tpl.Exec(ctx)
+}
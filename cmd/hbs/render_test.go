@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs":                    "{{> layouts/header}}{{#each items}}<{{this}}>{{/each}}",
+		"partials/layouts/header.hbs": "<h1>{{title}}</h1>",
+		"data.yaml":                   "title: Hello\nitems: [a, b]\n",
+		"data.json":                   `{"title": "Hi", "items": [1]}`,
+	})
+
+	output, err := runCommand(t, "render", filepath.Join(dir, "page.hbs"), "-data", filepath.Join(dir, "data.yaml"), "-partials", filepath.Join(dir, "partials"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "<h1>Hello</h1><a><b>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// JSON context, written to output file
+	outFile := filepath.Join(dir, "out.html")
+
+	if _, err := runCommand(t, "render", filepath.Join(dir, "page.hbs"), "-data", filepath.Join(dir, "data.json"), "-partials", filepath.Join(dir, "partials"), "-o", outFile); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if data, err := ioutil.ReadFile(outFile); (err != nil) || (string(data) != "<h1>Hi</h1><1>") {
+		t.Errorf("Unexpected output file: %q, %v", data, err)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs":  "{{> missing}}",
+		"data.toml": "",
+	})
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no template", nil},
+		{"missing template", []string{filepath.Join(dir, "unknown.hbs")}},
+		{"unsupported data file", []string{filepath.Join(dir, "page.hbs"), "-data", filepath.Join(dir, "data.toml")}},
+		{"missing partial", []string{filepath.Join(dir, "page.hbs")}},
+	}
+
+	for _, test := range tests {
+		if _, err := runCommand(t, "render", test.args...); err == nil {
+			t.Errorf("Test '%s' failed - Expected an error", test.name)
+		}
+	}
+}
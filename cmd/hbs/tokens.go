@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/aymerick/raymond/lexer"
+)
+
+var tokensCommand = &command{
+	name:  "tokens",
+	args:  "template.hbs",
+	short: "print the tokens of a template, with their position",
+	run:   runTokens,
+}
+
+// jsonToken is the JSON representation of a token
+type jsonToken struct {
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+	Pos    int    `json:"pos"`
+	End    int    `json:"end"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+
+	StripBefore bool `json:"stripBefore,omitempty"`
+	StripAfter  bool `json:"stripAfter,omitempty"`
+
+	Text string `json:"text,omitempty"`
+}
+
+// runTokens runs the tokens command
+func runTokens(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	format := fs.String("format", "table", "output format: table or json")
+
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one template, got %d", len(files))
+	}
+
+	source, err := readSource(files[0])
+	if err != nil {
+		return err
+	}
+
+	tokens := lexer.Collect(source)
+
+	result := make([]jsonToken, len(tokens))
+	for i, token := range tokens {
+		result[i] = jsonToken{
+			Kind:   token.Kind.String(),
+			Value:  token.Val,
+			Pos:    token.Pos,
+			End:    token.End,
+			Line:   token.Line,
+			Column: column(source, token.Pos),
+
+			StripBefore: token.StripBefore,
+			StripAfter:  token.StripAfter,
+
+			Text: token.Text,
+		}
+	}
+
+	switch *format {
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+		fmt.Fprintln(w, "LINE\tCOL\tKIND\tVALUE")
+		for _, token := range result {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", token.Line, token.Column, token.Kind, strconv.Quote(token.Value))
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected table or json", *format)
+	}
+
+	if last := tokens[len(tokens)-1]; last.Kind == lexer.TokenError {
+		return fmt.Errorf("Lexer error on line %d: %s", last.Line, last.Val)
+	}
+
+	return nil
+}
+
+// column returns the column of given byte position in source, starting at 1 and counted in runes
+func column(source string, pos int) int {
+	if pos > len(source) {
+		pos = len(source)
+	}
+
+	lineStart := strings.LastIndexByte(source[:pos], '\n') + 1
+
+	return utf8.RuneCountInString(source[lineStart:pos]) + 1
+}
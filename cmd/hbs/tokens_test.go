@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"page.hbs": "é\n{{foo}}",
+		"bad.hbs":  "{{foo",
+	})
+
+	output, err := runCommand(t, "tokens", filepath.Join(dir, "page.hbs"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := `LINE  COL  KIND     VALUE
+1     1    Content  "é\n"
+2     1    Open     "{{"
+2     3    ID       "foo"
+2     6    Close    "}}"
+2     8    EOF      ""
+`
+	if output != expected {
+		t.Errorf("Unexpected output\nexpected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	// JSON format
+	output, err = runCommand(t, "tokens", filepath.Join(dir, "page.hbs"), "-format", "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var tokens []jsonToken
+	if err := json.Unmarshal([]byte(output), &tokens); err != nil {
+		t.Fatalf("Invalid JSON output: %s\n%s", err, output)
+	}
+
+	if (len(tokens) != 5) || (tokens[2] != jsonToken{Kind: "ID", Value: "foo", Pos: 5, End: 8, Line: 2, Column: 3}) {
+		t.Errorf("Unexpected tokens: %+v", tokens)
+	}
+
+	// lexer errors are printed, then reported
+	output, err = runCommand(t, "tokens", filepath.Join(dir, "bad.hbs"))
+	if (err == nil) || !strings.Contains(err.Error(), "Lexer error on line 1") || !strings.Contains(output, "Error") {
+		t.Errorf("Expected lexer error, got: %v\n%s", err, output)
+	}
+}
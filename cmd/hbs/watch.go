@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aymerick/raymond"
+)
+
+var watchCommand = &command{
+	name:  "watch",
+	args:  "template.hbs",
+	short: "re-render a template to a file, or serve it with live reload, whenever it, its partials or its context change",
+	run:   runWatch,
+}
+
+// reloadPath is the path of the server-sent events stream that notifies pages served by watch command of new renders
+const reloadPath = "/_hbs/reload"
+
+// reloadScript is injected in pages served by watch command, to reload them on new renders
+const reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function() { location.reload(); };</script>`
+
+// watcher re-renders a template when its files change
+type watcher struct {
+	file        string
+	dataFile    string
+	partialsDir string
+	output      string
+
+	mutex sync.Mutex
+
+	// last render result, or error page
+	page string
+
+	// closed on next render
+	rendered chan struct{}
+}
+
+// runWatch runs the watch command
+func runWatch(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	dataFile := fs.String("data", "", "JSON or YAML context file, by extension (.json, .yaml or .yml)")
+	partialsDir := fs.String("partials", "", "directory of partials, named after their path relative to directory, without extension")
+	output := fs.String("o", "", "output file, rewritten on each render")
+	serve := fs.String("serve", "", "serve rendered template with live reload on given address, eg. localhost:8080")
+	interval := fs.Duration("interval", 500*time.Millisecond, "interval between checks of file changes")
+
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one template, got %d", len(files))
+	}
+
+	if (*output == "") && (*serve == "") {
+		fs.Usage()
+		return fmt.Errorf("expected -o or -serve")
+	}
+
+	if (files[0] == "-") || (*dataFile == "-") {
+		return fmt.Errorf("stdin can't be watched")
+	}
+
+	w := &watcher{
+		file:        files[0],
+		dataFile:    *dataFile,
+		partialsDir: *partialsDir,
+		output:      *output,
+		rendered:    make(chan struct{}),
+	}
+
+	errs := make(chan error, 1)
+
+	if *serve != "" {
+		go func() {
+			errs <- http.ListenAndServe(*serve, w)
+		}()
+
+		fmt.Fprintf(os.Stderr, "Serving %s on http://%s\n", w.file, *serve)
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	stamp := ""
+
+	for {
+		if current := w.stamp(); current != stamp {
+			stamp = current
+
+			if err := w.render(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// stamp returns the modification times and sizes of watched files, that change when a file is modified, added or removed
+func (w *watcher) stamp() string {
+	paths := []string{w.file}
+
+	if w.dataFile != "" {
+		paths = append(paths, w.dataFile)
+	}
+
+	if w.partialsDir != "" {
+		// errors are ignored, as files may be replaced while walking
+		filepath.Walk(w.partialsDir, func(filePath string, info os.FileInfo, err error) error {
+			if (err == nil) && !info.IsDir() && isTemplateFile(filePath) {
+				paths = append(paths, filePath)
+			}
+
+			return nil
+		})
+	}
+
+	sort.Strings(paths)
+
+	var b strings.Builder
+
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&b, "%s missing\n", path)
+		}
+	}
+
+	return b.String()
+}
+
+// render renders template, writes result to output file, and notifies served pages
+//
+// Render errors are reported without stopping the watch. Only an error writing to output file is returned.
+func (w *watcher) render() error {
+	result, err := render(w.file, w.dataFile, w.partialsDir, raymond.ParseOptions{})
+
+	page := result
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", time.Now().Format("15:04:05"), w.file, err)
+		page = "<pre>" + html.EscapeString(err.Error()) + "</pre>"
+	} else {
+		fmt.Fprintf(os.Stderr, "%s %s rendered\n", time.Now().Format("15:04:05"), w.file)
+
+		if w.output != "" {
+			if err := writeOutput(w.output, []byte(result)); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.mutex.Lock()
+	w.page = page
+	close(w.rendered)
+	w.rendered = make(chan struct{})
+	w.mutex.Unlock()
+
+	return nil
+}
+
+// ServeHTTP serves the last render with the live reload script, and the server-sent events stream used by that script
+func (w *watcher) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w.mutex.Lock()
+	page, rendered := w.page, w.rendered
+	w.mutex.Unlock()
+
+	if req.URL.Path != reloadPath {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(rw, injectReloadScript(page))
+		return
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+
+	select {
+	case <-rendered:
+		fmt.Fprint(rw, "data: reload\n\n")
+		flusher.Flush()
+	case <-req.Context().Done():
+	}
+}
+
+// injectReloadScript returns given page with the live reload script, before its closing body tag if any
+func injectReloadScript(page string) string {
+	if i := strings.LastIndex(page, "</body>"); i != -1 {
+		return page[:i] + reloadScript + page[i:]
+	}
+
+	return page + reloadScript
+}
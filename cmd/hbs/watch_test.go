@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestWatcher returns a watcher of the page template of a new temporary directory, with its data file and partials
+func newTestWatcher(t *testing.T) *watcher {
+	t.Helper()
+
+	dir := writeFiles(t, map[string]string{
+		"page.hbs":                 "<html><body>{{> header}}{{text}}</body></html>",
+		"partials/header.hbs":      "<h1>{{title}}</h1>",
+		"partials/README.txt":      "not a partial",
+		"data.json":                `{"title": "Hello", "text": "world"}`,
+		"output/.gitkeep":          "",
+		"partials/nested/.gitkeep": "",
+	})
+
+	return &watcher{
+		file:        filepath.Join(dir, "page.hbs"),
+		dataFile:    filepath.Join(dir, "data.json"),
+		partialsDir: filepath.Join(dir, "partials"),
+		output:      filepath.Join(dir, "output", "page.html"),
+		rendered:    make(chan struct{}),
+	}
+}
+
+func TestWatchStamp(t *testing.T) {
+	w := newTestWatcher(t)
+
+	stamp := w.stamp()
+	if stamp != w.stamp() {
+		t.Fatalf("Stamp changed without file changes")
+	}
+
+	if strings.Contains(stamp, "README.txt") {
+		t.Errorf("Stamp includes a file that is not a template: %s", stamp)
+	}
+
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{"template modified", func() error {
+			return ioutil.WriteFile(w.file, []byte("{{> header}}{{text}}!"), 0644)
+		}},
+		{"data modified", func() error {
+			return ioutil.WriteFile(w.dataFile, []byte(`{"title": "Hi"}`), 0644)
+		}},
+		{"partial added", func() error {
+			return ioutil.WriteFile(filepath.Join(w.partialsDir, "nested", "footer.hbs"), []byte("footer"), 0644)
+		}},
+		{"partial removed", func() error {
+			return os.Remove(filepath.Join(w.partialsDir, "nested", "footer.hbs"))
+		}},
+		{"data removed", func() error {
+			return os.Remove(w.dataFile)
+		}},
+	}
+
+	for _, test := range changes {
+		if err := test.change(); err != nil {
+			t.Fatal(err)
+		}
+
+		current := w.stamp()
+		if current == stamp {
+			t.Errorf("Test '%s' failed - Stamp did not change", test.name)
+		}
+
+		stamp = current
+	}
+}
+
+func TestWatchRender(t *testing.T) {
+	w := newTestWatcher(t)
+
+	rendered := w.rendered
+
+	if err := w.render(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	select {
+	case <-rendered:
+	default:
+		t.Errorf("Render did not notify served pages")
+	}
+
+	if data, err := ioutil.ReadFile(w.output); (err != nil) || (string(data) != "<html><body><h1>Hello</h1>world</body></html>") {
+		t.Errorf("Unexpected output file: %q, %v", data, err)
+	}
+
+	// re-render after a change
+	if err := ioutil.WriteFile(filepath.Join(w.partialsDir, "header.hbs"), []byte("<h2>{{title}}</h2>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.render(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if data, err := ioutil.ReadFile(w.output); (err != nil) || (string(data) != "<html><body><h2>Hello</h2>world</body></html>") {
+		t.Errorf("Unexpected output file after change: %q, %v", data, err)
+	}
+
+	// render errors are served, and keep last output file
+	if err := ioutil.WriteFile(w.file, []byte("{{#if}}<b>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.render(); err != nil {
+		t.Fatalf("Unexpected error on render error: %s", err)
+	}
+
+	if !strings.HasPrefix(w.page, "<pre>") {
+		t.Errorf("Expected an error page, got: %q", w.page)
+	}
+
+	if data, err := ioutil.ReadFile(w.output); (err != nil) || (string(data) != "<html><body><h2>Hello</h2>world</body></html>") {
+		t.Errorf("Unexpected output file after render error: %q, %v", data, err)
+	}
+
+	// output file can't be written
+	w.output = filepath.Join(w.partialsDir, "header.hbs", "page.html")
+	if err := ioutil.WriteFile(w.file, []byte("ok"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.render(); err == nil {
+		t.Errorf("Expected an error writing output file")
+	}
+}
+
+func TestWatchServeHTTP(t *testing.T) {
+	w := newTestWatcher(t)
+	w.output = ""
+
+	if err := w.render(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(w)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "<html><body><h1>Hello</h1>world" + reloadScript + "</body></html>"; string(body) != expected {
+		t.Errorf("Unexpected page:\n%s\nexpected:\n%s", body, expected)
+	}
+
+	if resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("Unexpected Cache-Control header: %q", resp.Header.Get("Cache-Control"))
+	}
+
+	// reload stream is notified on next render
+	resp, err = http.Get(server.URL + reloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Unexpected Content-Type header: %q", resp.Header.Get("Content-Type"))
+	}
+
+	if err := w.render(); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if (err != nil) || (line != "data: reload\n") {
+		t.Errorf("Unexpected event: %q, %v", line, err)
+	}
+}
+
+func TestInjectReloadScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		page     string
+		expected string
+	}{
+		{"<body><p>a</p></body>", "<body><p>a</p>" + reloadScript + "</body>"},
+		{"<p>a</p>", "<p>a</p>" + reloadScript},
+		{"", reloadScript},
+		{"<pre>&lt;/body&gt;</pre></body>x</body>", "<pre>&lt;/body&gt;</pre></body>x" + reloadScript + "</body>"},
+	}
+
+	for _, test := range tests {
+		if output := injectReloadScript(test.page); output != test.expected {
+			t.Errorf("Unexpected result for %q: %q", test.page, output)
+		}
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"page.hbs": "x"})
+	page := filepath.Join(dir, "page.hbs")
+
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"no template", []string{"-o", "out.html"}, "expected one template, got 0"},
+		{"no output", []string{page}, "expected -o or -serve"},
+		{"stdin template", []string{"-", "-o", "out.html"}, "stdin can't be watched"},
+		{"stdin data", []string{page, "-data", "-", "-o", "out.html"}, "stdin can't be watched"},
+	}
+
+	for _, test := range tests {
+		if _, err := runCommand(t, "watch", test.args...); (err == nil) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test '%s' failed - Expected error %q, got: %v", test.name, test.err, err)
+		}
+	}
+}
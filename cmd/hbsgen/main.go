@@ -0,0 +1,114 @@
+// Command hbsgen generates Go source code from handlebars templates.
+//
+// Usage:
+//
+//	hbsgen [-dir dir] [-o file] [-pkg name] [-partials dir] template.hbs:Type...
+//
+// Each template is rendered by a generated function named after the template file, with a pointer to given context type, that must be declared in the Go package found in dir. For example, `user-list.hbs:UserList` generates:
+//
+//	func RenderUserList(w io.Writer, ctx *UserList) error
+//
+// All `.hbs` and `.handlebars` files of the partials directory are available as partials, named after their file name without extension.
+//
+// It is meant to be used with go generate:
+//
+//	//go:generate hbsgen -o templates_hbs.go -partials templates/partials templates/page.hbs:Page
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymerick/raymond/hbsgen"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the Go package that declares context types")
+	output := flag.String("o", "templates_hbs.go", "output file, relative to package directory")
+	pkg := flag.String("pkg", "", "name of generated package (defaults to the package found in directory)")
+	partialsDir := flag.String("partials", "", "directory of partials")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: hbsgen [flags] template.hbs:Type...\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*dir, *output, *pkg, *partialsDir, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "hbsgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates code for given templates
+func run(dir string, output string, pkg string, partialsDir string, specs []string) error {
+	opts := hbsgen.Options{
+		Dir:      dir,
+		Package:  pkg,
+		Partials: make(map[string]string),
+	}
+
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 {
+			return fmt.Errorf("Invalid template %q, expected: template.hbs:Type", spec)
+		}
+
+		source, err := ioutil.ReadFile(spec[:i])
+		if err != nil {
+			return err
+		}
+
+		opts.Templates = append(opts.Templates, hbsgen.Template{
+			Name:   fileBase(spec[:i]),
+			Source: string(source),
+			Type:   spec[i+1:],
+		})
+	}
+
+	if partialsDir != "" {
+		files, err := ioutil.ReadDir(partialsDir)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if ext := filepath.Ext(file.Name()); file.IsDir() || ((ext != ".hbs") && (ext != ".handlebars")) {
+				continue
+			}
+
+			source, err := ioutil.ReadFile(filepath.Join(partialsDir, file.Name()))
+			if err != nil {
+				return err
+			}
+
+			opts.Partials[fileBase(file.Name())] = string(source)
+		}
+	}
+
+	code, err := hbsgen.Generate(opts)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, output), code, 0644)
+}
+
+// fileBase returns base file name without extension
+//
+// example: /foo/bar/baz.hbs => baz
+func fileBase(filePath string) string {
+	fileName := filepath.Base(filePath)
+
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
+}
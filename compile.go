@@ -0,0 +1,426 @@
+package raymond
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// opcode identifies a bytecode instruction
+type opcode uint8
+
+const (
+	// opContent writes a constant string
+	opContent opcode = iota
+
+	// opPath writes the value of a mustache statement that only contains a path, unless that path is a helper call
+	opPath
+
+	// opMustache evaluates a mustache statement
+	opMustache
+
+	// opBlock evaluates a block statement
+	opBlock
+
+	// opPartial evaluates a partial statement
+	opPartial
+
+	// opBranch evaluates the reachable program of a #if or #unless block statement with a literal condition
+	opBranch
+
+	// opNonce writes the nonce attribute of a script or style start tag, if a nonce is set
+	opNonce
+)
+
+// instr is a bytecode instruction: the opcode is stored in the high byte, and the operand in the low bytes
+//
+// The operand is an index in the table of the compiled program corresponding to the opcode.
+type instr uint32
+
+const (
+	operandBits = 24
+	maxOperand  = 1<<operandBits - 1
+)
+
+const (
+	// maximum length of adjacent contents merged in a single constant
+	maxMergedContentSize = 256
+
+	// maximum length of the pre-rendered output of a program that only contains content
+	maxStaticTextSize = DefaultContentChunkSize
+)
+
+// newInstr instanciates a new instruction
+func newInstr(op opcode, operand int) instr {
+	return instr(uint32(op)<<operandBits | uint32(operand))
+}
+
+// op returns instruction opcode
+func (i instr) op() opcode {
+	return opcode(i >> operandBits)
+}
+
+// operand returns instruction operand
+func (i instr) operand() int {
+	return int(i & maxOperand)
+}
+
+// pathOperand is the operand of an opPath instruction
+type pathOperand struct {
+	stmt *ast.MustacheStatement
+	path *ast.PathExpression
+
+	// name of the helper that would be called instead of evaluating path, empty if path can't be a helper call
+	helper string
+}
+
+// branchOperand is the operand of opBranch
+type branchOperand struct {
+	stmt *ast.BlockStatement
+
+	// name and code pointer of the helper that is not called
+	name   string
+	helper uintptr
+
+	// reachable program, nil if there is none
+	program *ast.Program
+}
+
+// bytecode represents a compiled program
+type bytecode struct {
+	code []instr
+
+	// operands tables
+	consts    []string
+	paths     []pathOperand
+	mustaches []*ast.MustacheStatement
+	blocks    []*ast.BlockStatement
+	partials  []*ast.PartialStatement
+	branches  []branchOperand
+
+	// total length of constant strings, used to preallocate output
+	size int
+
+	// true if program only outputs constant content, pre-rendered in text
+	static bool
+	text   string
+}
+
+// compiledPrograms holds the compiled programs of a template
+//
+// Inverse programs (ie. else branches) are rarely all executed, so they are compiled lazily, the first time they are evaluated.
+type compiledPrograms struct {
+	// programs compiled at parse time, read-only
+	programs map[*ast.Program]*bytecode
+
+	// programs compiled on first evaluation: *ast.Program => *bytecode, nil if program must be interpreted
+	lazy sync.Map
+
+	// subexpressions folded at parse time, read-only
+	exprs map[*ast.SubExpression]foldedExpr
+
+	// subexpressions folded on first evaluation: *ast.SubExpression => foldedExpr
+	lazyExprs sync.Map
+
+	// root program, and its analysis computed on first contextual escaping
+	root           *ast.Program
+	escapingOnce   sync.Once
+	escapingResult *escapingAnalysis
+
+	// raw source of sections passed to lambdas, nil if this is not a Mustache template
+	sections map[*ast.BlockStatement]mustacheSection
+}
+
+// compiler lowers programs to bytecode
+type compiler struct {
+	// compiled programs
+	programs map[*ast.Program]*bytecode
+
+	// folded subexpressions
+	folded map[*ast.SubExpression]foldedExpr
+
+	// compiled program
+	cur *bytecode
+
+	// pending contents, and their total length
+	contents    []string
+	contentSize int
+}
+
+// compile compiles given program and all its nested programs, except inverse ones, and returns compiled programs
+func compile(program *ast.Program) *compiledPrograms {
+	c := compileNested(program)
+
+	return &compiledPrograms{
+		programs: c.programs,
+		exprs:    c.folded,
+		root:     program,
+	}
+}
+
+// compileNested compiles given program and all its nested programs, except inverse ones
+func compileNested(program *ast.Program) *compiler {
+	c := &compiler{
+		programs: make(map[*ast.Program]*bytecode),
+		folded:   make(map[*ast.SubExpression]foldedExpr),
+	}
+
+	c.compileProgram(program)
+
+	return c
+}
+
+// get returns the bytecode of given program, or nil if it must be interpreted
+//
+// A program that was not compiled at parse time is compiled on first call, concurrent calls getting the same bytecode.
+func (p *compiledPrograms) get(program *ast.Program) *bytecode {
+	if p == nil {
+		return nil
+	}
+
+	if code, ok := p.programs[program]; ok {
+		return code
+	}
+
+	if program == nil {
+		return nil
+	}
+
+	if code, ok := p.lazy.Load(program); ok {
+		return code.(*bytecode)
+	}
+
+	c := compileNested(program)
+
+	// store folded subexpressions first, so that they are available as soon as bytecode is
+	for node, folded := range c.folded {
+		p.lazyExprs.LoadOrStore(node, folded)
+	}
+
+	for nested, code := range c.programs {
+		p.lazy.LoadOrStore(nested, code)
+	}
+
+	// store nil bytecode for a program that must be interpreted, so that it is not compiled again
+	code, _ := p.lazy.LoadOrStore(program, (*bytecode)(nil))
+
+	return code.(*bytecode)
+}
+
+// compileProgram compiles given program and all its nested programs, except inverse ones
+func (c *compiler) compileProgram(program *ast.Program) {
+	if program == nil {
+		return
+	}
+
+	if _, done := c.programs[program]; done {
+		return
+	}
+
+	c.cur = &bytecode{}
+
+	for _, node := range program.Body {
+		c.foldStatement(node)
+
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			c.addNonceContent(n.Value)
+		case *ast.CommentStatement:
+			// ignore comments
+		case *ast.MustacheStatement:
+			if path, helper, ok := mustachePath(n); ok {
+				c.emit(opPath, len(c.cur.paths))
+				c.cur.paths = append(c.cur.paths, pathOperand{stmt: n, path: path, helper: helper})
+			} else {
+				c.emit(opMustache, len(c.cur.mustaches))
+				c.cur.mustaches = append(c.cur.mustaches, n)
+			}
+		case *ast.BlockStatement:
+			if branch, ok := literalBranch(n); ok {
+				c.emit(opBranch, len(c.cur.branches))
+				c.cur.branches = append(c.cur.branches, branch)
+			} else {
+				c.emit(opBlock, len(c.cur.blocks))
+				c.cur.blocks = append(c.cur.blocks, n)
+			}
+		case *ast.PartialStatement:
+			c.emit(opPartial, len(c.cur.partials))
+			c.cur.partials = append(c.cur.partials, n)
+		default:
+			// unknown statement: that program will be interpreted
+			c.cur = nil
+			c.resetContent()
+			return
+		}
+	}
+
+	c.flushContent()
+
+	if len(c.cur.code) > maxOperand {
+		// too many statements: that program will be interpreted
+		c.cur = nil
+		return
+	}
+
+	c.cur.text, c.cur.static = staticText(c.cur)
+
+	c.programs[program] = c.cur
+
+	// compile nested programs, inverse ones being compiled on first evaluation, and unreachable ones never
+	for _, node := range program.Body {
+		if block, ok := node.(*ast.BlockStatement); ok {
+			if branch, ok := literalBranch(block); ok {
+				c.compileProgram(branch.program)
+			} else {
+				c.compileProgram(block.Program)
+			}
+		}
+	}
+}
+
+// emit appends an instruction to compiled program, after pending content
+func (c *compiler) emit(op opcode, operand int) {
+	if op != opContent {
+		c.flushContent()
+	}
+
+	c.cur.code = append(c.cur.code, newInstr(op, operand))
+}
+
+// addContent appends given content to pending one
+//
+// Adjacent contents are merged, unless merged content would be larger than maxMergedContentSize, so that chunks of a large content are not copied.
+func (c *compiler) addContent(str string) {
+	if (c.contentSize > 0) && (c.contentSize+len(str) > maxMergedContentSize) {
+		c.flushContent()
+	}
+
+	c.contents = append(c.contents, str)
+	c.contentSize += len(str)
+}
+
+// addNonceContent appends given content to pending one, and emits an opNonce instruction after the name of each script and style start tag
+func (c *compiler) addNonceContent(str string) {
+	start := 0
+	for _, offset := range nonceOffsets(str) {
+		c.addContent(str[start:offset])
+		c.emit(opNonce, 0)
+		start = offset
+	}
+
+	c.addContent(str[start:])
+}
+
+// resetContent discards pending content
+func (c *compiler) resetContent() {
+	c.contents = c.contents[:0]
+	c.contentSize = 0
+}
+
+// flushContent emits pending content
+func (c *compiler) flushContent() {
+	if c.contentSize == 0 {
+		c.resetContent()
+		return
+	}
+
+	str := c.contents[0]
+	if len(c.contents) > 1 {
+		str = strings.Join(c.contents, "")
+	}
+
+	c.resetContent()
+
+	c.emit(opContent, len(c.cur.consts))
+	c.cur.consts = append(c.cur.consts, str)
+	c.cur.size += len(str)
+}
+
+// staticText returns the output of given compiled program and true if it only contains content
+//
+// Chunks of a content larger than maxStaticTextSize are not joined, so such a program is not static and its chunks are output one after the other.
+func staticText(code *bytecode) (string, bool) {
+	for _, in := range code.code {
+		if in.op() != opContent {
+			return "", false
+		}
+	}
+
+	switch {
+	case len(code.consts) == 1:
+		return code.consts[0], true
+	case code.size > maxStaticTextSize:
+		return "", false
+	default:
+		return strings.Join(code.consts, ""), true
+	}
+}
+
+// literalBranch returns the reachable program of given block statement if it is a #if or #unless block with a literal condition, eg. {{#if true}}
+func literalBranch(node *ast.BlockStatement) (branchOperand, bool) {
+	expr := node.Expression
+
+	name := expr.HelperName()
+	if ((name != "if") && (name != "unless")) || (len(expr.Params) != 1) || (expr.Hash != nil) {
+		return branchOperand{}, false
+	}
+
+	if (node.Program != nil) && (len(node.Program.BlockParams) > 0) {
+		return branchOperand{}, false
+	}
+
+	var truth bool
+
+	switch param := expr.Params[0].(type) {
+	case *ast.BooleanLiteral:
+		truth = param.Value
+	case *ast.StringLiteral:
+		truth = IsTrue(param.Value)
+	case *ast.NumberLiteral:
+		truth = IsTrue(param.Number())
+	default:
+		return branchOperand{}, false
+	}
+
+	helpersMutex.RLock()
+	helper := helpers.get(name)
+	helpersMutex.RUnlock()
+
+	if helper == zero {
+		return branchOperand{}, false
+	}
+
+	result := branchOperand{
+		stmt:    node,
+		name:    name,
+		helper:  helper.Pointer(),
+		program: node.Inverse,
+	}
+
+	if truth != (name == "unless") {
+		result.program = node.Program
+	}
+
+	return result, true
+}
+
+// mustachePath returns the path of given mustache statement if it only contains a path, and the name of the helper that would be called instead
+func mustachePath(node *ast.MustacheStatement) (*ast.PathExpression, string, bool) {
+	expr := node.Expression
+	if (len(expr.Params) > 0) || (expr.Hash != nil) {
+		return nil, "", false
+	}
+
+	path, ok := expr.Path.(*ast.PathExpression)
+	if !ok {
+		return nil, "", false
+	}
+
+	helper := expr.HelperName()
+	if helper == "" {
+		helper = expr.NamespacedHelperName()
+	}
+
+	return path, helper, true
+}
@@ -0,0 +1,338 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+func TestInstr(t *testing.T) {
+	t.Parallel()
+
+	in := newInstr(opPartial, maxOperand)
+	if in.op() != opPartial || in.operand() != maxOperand {
+		t.Errorf("Failed to decode instruction: %d %d", in.op(), in.operand())
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`Hello {{! comment }}{{name}} {{#if a}}x{{else}}y{{/if}}{{> p}}{{foo bar}}!`)
+
+	if len(tpl.code.programs) != 2 {
+		t.Fatalf("Expected 2 compiled programs, got %d", len(tpl.code.programs))
+	}
+
+	code := tpl.code.get(tpl.program)
+
+	var ops []opcode
+	for _, in := range code.code {
+		ops = append(ops, in.op())
+	}
+
+	expectedOps := []opcode{opContent, opPath, opContent, opBlock, opPartial, opMustache, opContent}
+	if !reflect.DeepEqual(ops, expectedOps) {
+		t.Errorf("Unexpected opcodes\nexpected:\n\t%v\ngot:\n\t%v", expectedOps, ops)
+	}
+
+	expectedConsts := []string{"Hello ", " ", "!"}
+	if !reflect.DeepEqual(code.consts, expectedConsts) {
+		t.Errorf("Unexpected constants\nexpected:\n\t%q\ngot:\n\t%q", expectedConsts, code.consts)
+	}
+
+	if code.size != 8 {
+		t.Errorf("Unexpected size: %d", code.size)
+	}
+}
+
+func TestCompilePathHelper(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"{{foo}}":         "foo",
+		"{{str.upper}}":   "str.upper",
+		"{{@index}}":      "",
+		"{{../foo}}":      "",
+		"{{this.foo}}":    "",
+		"{{{foo.bar}}}":   "foo.bar",
+		"{{[foo bar]}}":   "[foo bar]",
+		"{{foo.[0].bar}}": "foo.[0].bar",
+	}
+
+	for source, expected := range tests {
+		tpl := MustParse(source)
+
+		code := tpl.code.get(tpl.program)
+		if (len(code.code) != 1) || (code.code[0].op() != opPath) {
+			t.Errorf("Template %s not compiled to a path instruction", source)
+			continue
+		}
+
+		if helper := code.paths[0].helper; helper != expected {
+			t.Errorf("Unexpected helper name for %s\nexpected:\n\t%q\ngot:\n\t%q", source, expected, helper)
+		}
+	}
+}
+
+func TestCompileLazyInverse(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#if a}}x{{else if b}}{{#each c}}{{this}}{{/each}}{{else}}z{{/if}}`)
+
+	block := tpl.program.Body[0].(*ast.BlockStatement)
+	chained := block.Inverse.Body[0].(*ast.BlockStatement)
+
+	if _, ok := tpl.code.programs[block.Inverse]; ok {
+		t.Errorf("Inverse program compiled at parse time")
+	}
+
+	if output := tpl.MustExec(map[string]interface{}{"b": true, "c": []int{1, 2}}); output != "12" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// nested programs are compiled with inverse program, except inverse ones
+	for _, program := range []*ast.Program{block.Inverse, chained.Program} {
+		if code, ok := tpl.code.lazy.Load(program); !ok || code.(*bytecode) == nil {
+			t.Errorf("Program not compiled on first evaluation: %s", program)
+		}
+	}
+
+	if _, ok := tpl.code.lazy.Load(chained.Inverse); ok {
+		t.Errorf("Nested inverse program compiled before evaluation")
+	}
+
+	if tpl.code.get(block.Inverse) != tpl.code.get(block.Inverse) {
+		t.Errorf("Inverse program compiled twice")
+	}
+}
+
+func TestCompileLazyInverseConcurrency(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{#if this}}y{{else}}n{{/if}}{{/each}}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if output := tpl.MustExec(map[string][]bool{"items": {true, false}}); output != "yn" {
+				t.Errorf("Unexpected output: %q", output)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCompileStatic(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`Hello {{! comment }}world{{#if a}}x{{else}}{{foo}}{{/if}}`)
+
+	if code := tpl.code.get(tpl.program); code.static {
+		t.Errorf("Template compiled as static")
+	}
+
+	block := tpl.program.Body[len(tpl.program.Body)-1].(*ast.BlockStatement)
+
+	if code := tpl.code.get(block.Program); !code.static || (code.text != "x") {
+		t.Errorf("Block program not compiled as static: %v %q", code.static, code.text)
+	}
+
+	if code := tpl.code.get(block.Inverse); code.static {
+		t.Errorf("Block inverse compiled as static")
+	}
+
+	tpl = MustParse(`Hello {{! comment }}world`)
+
+	if text, ok := tpl.staticText(); !ok || (text != "Hello world") {
+		t.Errorf("Template not compiled as static: %v %q", ok, text)
+	}
+}
+
+func TestCompileChunkedContent(t *testing.T) {
+	t.Parallel()
+
+	page := strings.Repeat("<p>some static text</p>\n", 10000)
+
+	tpl, err := ParseWithOptions("{{#if ok}}\n"+page+"{{/if}}\n"+page+"{{foo}}", ParseOptions{ContentChunkSize: 1024})
+	if err != nil {
+		t.Fatalf("Failed to parse template: %s", err)
+	}
+
+	block := tpl.program.Body[0].(*ast.BlockStatement)
+
+	for _, program := range []*ast.Program{tpl.program, block.Program} {
+		code := tpl.code.get(program)
+		if code.static {
+			t.Errorf("Chunked content compiled as static")
+		}
+
+		for _, str := range code.consts {
+			if len(str) > 1024 {
+				t.Errorf("Content chunk too large: %d", len(str))
+			}
+		}
+	}
+
+	if output := tpl.MustExec(map[string]interface{}{"ok": true, "foo": "bar"}); output != page+page+"bar" {
+		t.Errorf("Unexpected output of chunked content")
+	}
+}
+
+func TestCompileChunkedContentWhitespaces(t *testing.T) {
+	t.Parallel()
+
+	source := "  {{#if ok}}  \nfoo bar\n  baz  \n  {{~/if}}\n  {{> p}}\nend  {{~foo~}}  \n\n x\n {{!-- comment --}} \nlast line\n"
+	ctx := map[string]interface{}{"ok": true, "foo": "bar"}
+
+	tpl := MustParse(source)
+	tpl.RegisterPartial("p", "one\ntwo\n")
+
+	expected := tpl.MustExec(ctx)
+
+	for size := 1; size < len(source); size++ {
+		tpl, err := ParseWithOptions(source, ParseOptions{ContentChunkSize: size})
+		if err != nil {
+			t.Fatalf("Failed to parse template: %s", err)
+		}
+		tpl.RegisterPartial("p", "one\ntwo\n")
+
+		if output := tpl.MustExec(ctx); output != expected {
+			t.Errorf("Unexpected output with chunks of %d bytes\nexpected\n\t%q\ngot\n\t%q", size, expected, output)
+		}
+	}
+}
+
+func TestCompileLiteralBranch(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#if false}}a{{else}}b{{/if}}{{#unless 0}}c{{else}}d{{/unless}}{{#if "x"}}{{#if foo}}e{{/if}}f{{/if}}{{#if true as |x|}}g{{/if}}`)
+
+	code := tpl.code.get(tpl.program)
+	if len(code.branches) != 3 {
+		t.Fatalf("Expected 3 literal branches, got %d", len(code.branches))
+	}
+
+	block := tpl.program.Body[0].(*ast.BlockStatement)
+	if _, ok := tpl.code.programs[block.Program]; ok {
+		t.Errorf("Unreachable program compiled")
+	}
+
+	if _, ok := tpl.code.programs[block.Inverse]; !ok {
+		t.Errorf("Reachable inverse program not compiled")
+	}
+
+	if output := tpl.MustExec(map[string]bool{"foo": true}); output != "bcefg" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// shadowed helper
+	tpl = MustParse(`{{#if true}}a{{else}}b{{/if}}`)
+	tpl.RegisterHelper("if", func(cond interface{}, options *Options) string {
+		return options.Inverse()
+	})
+
+	if output := tpl.MustExec(nil); output != "b" {
+		t.Errorf("Unexpected output with shadowed helper: %q", output)
+	}
+
+	// middlewares
+	var calls []string
+
+	tpl = MustParse(`{{#unless true}}a{{else}}b{{/unless}}`)
+	tpl.UseHelperMiddleware(func(next HelperFunc) HelperFunc {
+		return func(call *HelperCall) (interface{}, error) {
+			calls = append(calls, call.Name)
+			return next(call)
+		}
+	})
+
+	if output := tpl.MustExec(nil); (output != "b") || (strings.Join(calls, " ") != "unless") {
+		t.Errorf("Unexpected output with middlewares: %q %v", output, calls)
+	}
+}
+
+// set when running with the race detector
+var raceEnabled bool
+
+func TestStaticAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not checked with the race detector")
+	}
+
+	tpl := MustParse(`<html>{{! comment }}</html>`)
+
+	if allocs := testing.AllocsPerRun(100, func() { tpl.MustExec(nil) }); allocs != 0 {
+		t.Errorf("Static template evaluation allocated %v times", allocs)
+	}
+
+	tpl = MustParse(`{{> header}}<p>body</p>{{> footer}}`)
+	tpl.RegisterPartial("header", `<html><body>`)
+	tpl.RegisterPartial("footer", `</body></html>`)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, err := tpl.ExecBuffer(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Release()
+	})
+
+	if allocs != 0 {
+		t.Errorf("Static partials evaluation allocated %v times", allocs)
+	}
+}
+
+// TestCompiledOutput checks that compiled and interpreted templates output the same result
+func TestCompiledOutput(t *testing.T) {
+	t.Parallel()
+
+	var tests []Test
+	for _, list := range [][]Test{evalTests, helperTests, tableTests, yamlTests} {
+		tests = append(tests, list...)
+	}
+
+	for _, test := range tests {
+		tpl := MustParse(test.input)
+
+		interpreted := tpl.Clone()
+		interpreted.code = nil
+
+		var outputs [2]string
+
+		for i, tpl := range []*Template{tpl, interpreted} {
+			if len(test.helpers) > 0 {
+				tpl.RegisterHelpers(test.helpers)
+			}
+
+			if len(test.partials) > 0 {
+				tpl.RegisterPartials(test.partials)
+			}
+
+			var privData *DataFrame
+			if test.privData != nil {
+				privData = NewDataFrame()
+				for k, v := range test.privData {
+					privData.Set(k, v)
+				}
+			}
+
+			output, err := tpl.ExecWith(test.data, privData)
+			if err != nil {
+				output = err.Error()
+			}
+
+			outputs[i] = output
+		}
+
+		if outputs[0] != outputs[1] {
+			t.Errorf("Test '%s' failed\ninput:\n\t'%s'\ncompiled:\n\t%q\ninterpreted:\n\t%q", test.name, test.input, outputs[0], outputs[1])
+		}
+	}
+}
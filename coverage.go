@@ -0,0 +1,328 @@
+package raymond
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// CoverageEntry is the coverage of a template.
+type CoverageEntry struct {
+	// Template is the name of template, or of partial
+	Template string
+
+	// Statements is the number of mustache, block and partial statements, and CoveredStatements the number of those that were rendered
+	Statements        int
+	CoveredStatements int
+
+	// Branches is the number of block programs, ie. the main program and the else program of blocks, and CoveredBranches the number of those that were rendered
+	Branches        int
+	CoveredBranches int
+
+	// UncoveredLines are the lines of statements, branches and content that were never rendered, in ascending order
+	UncoveredLines []int
+}
+
+// Percent returns the percentage of statements and branches that were rendered, or 100 if template has none.
+func (e CoverageEntry) Percent() float64 {
+	total := e.Statements + e.Branches
+	if total == 0 {
+		return 100
+	}
+
+	return 100 * float64(e.CoveredStatements+e.CoveredBranches) / float64(total)
+}
+
+// Coverage records which programs of templates are rendered, ie. which branches of blocks, to find dead branches and untested else paths in template test suites.
+//
+// Pass it with the Coverage evaluation option, and add templates to the report with Track(). A coverage is safe for concurrent use, and accumulates records across evaluations until Reset() is called.
+type Coverage struct {
+	mutex sync.Mutex // protects templates and hits
+
+	// tracked templates, by name
+	templates map[string]*Template
+
+	// number of evaluations, by program
+	hits map[*ast.Program]int
+}
+
+// coverageLine is the coverage status of a source line
+type coverageLine int
+
+const (
+	// no statement starts on line
+	lineNeutral coverageLine = iota
+
+	// all statements of line were rendered
+	lineCovered
+
+	// a statement of line was never rendered
+	lineUncovered
+)
+
+// NewCoverage instanciates a new coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{
+		templates: make(map[string]*Template),
+		hits:      make(map[*ast.Program]int),
+	}
+}
+
+// Track adds given template to the report, under given name, eg. its file path, so that it is reported even if it is never rendered.
+//
+// Partials registered on template are tracked too, under their names. Other partials are tracked when they are rendered, under their names: partials with the same name are only tracked once. An error is returned if template or one of its partials can't be parsed.
+func (c *Coverage) Track(name string, tpl *Template) error {
+	if err := tpl.parse(); err != nil {
+		return err
+	}
+
+	c.track(name, tpl)
+
+	for partialName, p := range tpl.partials.load() {
+		partialTpl, err := p.template()
+		if err != nil {
+			return err
+		}
+
+		c.track(partialName, partialTpl)
+	}
+
+	return nil
+}
+
+// Reset removes all records. Tracked templates are kept.
+func (c *Coverage) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.hits = make(map[*ast.Program]int)
+}
+
+// track tracks given template under given name, unless a template is already tracked with that name
+func (c *Coverage) track(name string, tpl *Template) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.templates[name]; !ok {
+		c.templates[name] = tpl
+	}
+}
+
+// hit records an evaluation of given program
+func (c *Coverage) hit(program *ast.Program) {
+	c.mutex.Lock()
+	c.hits[program]++
+	c.mutex.Unlock()
+}
+
+// Report returns the coverage of tracked templates, in name order.
+func (c *Coverage) Report() []CoverageEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	names := c.names()
+
+	result := make([]CoverageEntry, len(names))
+	for i, name := range names {
+		result[i], _ = c.entry(name)
+	}
+
+	return result
+}
+
+// WriteText writes a summary of coverage, with a line per template and a total line.
+func (c *Coverage) WriteText(w io.Writer) error {
+	entries := c.Report()
+
+	total := CoverageEntry{Template: "total"}
+	for _, entry := range entries {
+		total.Statements += entry.Statements
+		total.CoveredStatements += entry.CoveredStatements
+		total.Branches += entry.Branches
+		total.CoveredBranches += entry.CoveredBranches
+	}
+
+	for _, entry := range append(entries, total) {
+		if _, err := fmt.Fprintf(w, "%s\tstatements %d/%d\tbranches %d/%d\t%.1f%%\n", entry.Template, entry.CoveredStatements, entry.Statements, entry.CoveredBranches, entry.Branches, entry.Percent()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// coverageHTML is the template of the HTML view of coverage
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 12px; text-align: left; }
+pre { background: #f8f8f8; padding: 8px; }
+.num { display: inline-block; width: 4em; color: #999; user-select: none; }
+.covered { background: #ddffdd; }
+.uncovered { background: #ffdddd; }
+</style>
+</head>
+<body>
+<h1>Template coverage</h1>
+<table>
+<tr><th>Template</th><th>Statements</th><th>Branches</th><th>Coverage</th></tr>
+{{- range $i, $file := .}}
+<tr><td><a href="#file{{$i}}">{{$file.Entry.Template}}</a></td><td>{{$file.Entry.CoveredStatements}}/{{$file.Entry.Statements}}</td><td>{{$file.Entry.CoveredBranches}}/{{$file.Entry.Branches}}</td><td>{{printf "%.1f%%" $file.Entry.Percent}}</td></tr>
+{{- end}}
+</table>
+{{- range $i, $file := .}}
+<h2 id="file{{$i}}">{{$file.Entry.Template}}</h2>
+<pre>
+{{- range $file.Lines}}
+<span class="{{.Class}}"><span class="num">{{.Num}}</span>{{.Text}}</span>
+{{- end}}
+</pre>
+{{- end}}
+</body>
+</html>
+`))
+
+// coverageHTMLFile is a template in the HTML view of coverage
+type coverageHTMLFile struct {
+	Entry CoverageEntry
+	Lines []coverageHTMLLine
+}
+
+// coverageHTMLLine is a source line of the HTML view of coverage
+type coverageHTMLLine struct {
+	Num   int
+	Text  string
+	Class string
+}
+
+// WriteHTML writes an HTML view of coverage, with the source of tracked templates where rendered and never rendered lines are highlighted.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	c.mutex.Lock()
+
+	var files []coverageHTMLFile
+
+	for _, name := range c.names() {
+		entry, status := c.entry(name)
+
+		file := coverageHTMLFile{Entry: entry}
+
+		for i, text := range strings.Split(c.templates[name].source, "\n") {
+			line := coverageHTMLLine{Num: i + 1, Text: text}
+
+			switch status[i+1] {
+			case lineCovered:
+				line.Class = "covered"
+			case lineUncovered:
+				line.Class = "uncovered"
+			}
+
+			file.Lines = append(file.Lines, line)
+		}
+
+		files = append(files, file)
+	}
+
+	c.mutex.Unlock()
+
+	return coverageHTML.Execute(w, files)
+}
+
+// names returns the names of tracked templates, sorted
+func (c *Coverage) names() []string {
+	result := make([]string, 0, len(c.templates))
+	for name := range c.templates {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// entry returns the coverage of template tracked with given name, and the status of its lines
+func (c *Coverage) entry(name string) (CoverageEntry, map[int]coverageLine) {
+	result := CoverageEntry{Template: name}
+	status := make(map[int]coverageLine)
+
+	mark := func(line int, covered bool) {
+		if !covered {
+			status[line] = lineUncovered
+		} else if status[line] == lineNeutral {
+			status[line] = lineCovered
+		}
+	}
+
+	var walk func(program *ast.Program)
+	walk = func(program *ast.Program) {
+		covered := c.hits[program] > 0
+
+		for _, node := range program.Body {
+			switch n := node.(type) {
+			case *ast.ContentStatement:
+				// lines with text only
+				for i, text := range strings.Split(n.Original, "\n") {
+					if strings.TrimSpace(text) != "" {
+						mark(n.Line+i, covered)
+					}
+				}
+
+				continue
+			case *ast.CommentStatement:
+				continue
+			}
+
+			result.Statements++
+			if covered {
+				result.CoveredStatements++
+			}
+
+			mark(node.Location().Line, covered)
+
+			if block, ok := node.(*ast.BlockStatement); ok {
+				for _, branch := range []*ast.Program{block.Program, block.Inverse} {
+					if branch == nil {
+						continue
+					}
+
+					result.Branches++
+					if c.hits[branch] > 0 {
+						result.CoveredBranches++
+					} else {
+						mark(branch.Line, false)
+					}
+
+					walk(branch)
+				}
+			}
+		}
+	}
+
+	walk(c.templates[name].program)
+
+	for line, s := range status {
+		if s == lineUncovered {
+			result.UncoveredLines = append(result.UncoveredLines, line)
+		}
+	}
+
+	sort.Ints(result.UncoveredLines)
+
+	return result, status
+}
+
+// cover records an evaluation of given program, if coverage is recorded
+func (v *evalVisitor) cover(program *ast.Program) {
+	if v.coverage != nil {
+		v.coverage.hit(program)
+	}
+}
@@ -0,0 +1,126 @@
+package raymond
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	t.Parallel()
+
+	source := `<h1>{{title}}</h1>
+{{#if items}}
+  <ul>{{#each items}}{{> item}}{{/each}}</ul>
+{{else}}
+  <p>No items</p>
+{{/if}}
+{{#if false}}dead{{/if}}`
+
+	tpl := MustParse(source)
+	tpl.RegisterPartial("item", "<li>{{#if done}}x{{else}}{{name}}{{/if}}</li>")
+	tpl.RegisterPartial("unused", "{{foo}}")
+
+	cov := NewCoverage()
+	if err := cov.Track("page.hbs", tpl); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ctx := map[string]interface{}{
+		"title": "Items",
+		"items": []map[string]interface{}{{"name": "foo", "done": false}},
+	}
+
+	if _, err := tpl.ExecWithOptions(ctx, ExecOptions{Coverage: cov}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []CoverageEntry{
+		{Template: "item", Statements: 2, CoveredStatements: 2, Branches: 2, CoveredBranches: 1, UncoveredLines: []int{1}},
+		{Template: "page.hbs", Statements: 5, CoveredStatements: 5, Branches: 4, CoveredBranches: 2, UncoveredLines: []int{4, 5, 7}},
+		{Template: "unused", Statements: 1, CoveredStatements: 0, Branches: 0, CoveredBranches: 0, UncoveredLines: []int{1}},
+	}
+
+	if report := cov.Report(); !reflect.DeepEqual(report, expected) {
+		t.Errorf("Unexpected report\nexpected:\n\t%+v\ngot:\n\t%+v", expected, report)
+	}
+
+	// else branch is covered by another evaluation
+	if _, err := tpl.ExecWithOptions(map[string]interface{}{}, ExecOptions{Coverage: cov}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if entry := cov.Report()[1]; (entry.CoveredBranches != 3) || !reflect.DeepEqual(entry.UncoveredLines, []int{7}) {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	var buf bytes.Buffer
+	if err := cov.WriteText(&buf); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expectedText := "item\tstatements 2/2\tbranches 1/2\t75.0%\n" +
+		"page.hbs\tstatements 5/5\tbranches 3/4\t88.9%\n" +
+		"unused\tstatements 0/1\tbranches 0/0\t0.0%\n" +
+		"total\tstatements 7/8\tbranches 4/6\t78.6%\n"
+
+	if buf.String() != expectedText {
+		t.Errorf("Unexpected text report\nexpected:\n%s\ngot:\n%s", expectedText, buf.String())
+	}
+
+	cov.Reset()
+
+	if entry := cov.Report()[1]; entry.CoveredStatements != 0 {
+		t.Errorf("Expected no coverage after reset, got: %+v", entry)
+	}
+}
+
+func TestCoverageHTML(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{#if a}}\n<b>yes</b>\n{{else}}\n<i>no</i>\n{{/if}}")
+
+	cov := NewCoverage()
+	if err := cov.Track("page.hbs", tpl); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := tpl.ExecWithOptions(map[string]bool{"a": true}, ExecOptions{Coverage: cov}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cov.WriteHTML(&buf); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, expected := range []string{
+		`<span class="covered"><span class="num">2</span>&lt;b&gt;yes&lt;/b&gt;</span>`,
+		`<span class="uncovered"><span class="num">4</span>&lt;i&gt;no&lt;/i&gt;</span>`,
+		`<td>1/1</td><td>1/2</td><td>66.7%</td>`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected HTML report to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestCoverageStatic(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("static")
+
+	cov := NewCoverage()
+	if err := cov.Track("static", tpl); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := tpl.ExecWithOptions(nil, ExecOptions{Coverage: cov}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if status := cov.Report(); (len(status) != 1) || (len(status[0].UncoveredLines) != 0) {
+		t.Errorf("Expected static template to be covered, got: %+v", status)
+	}
+}
@@ -20,26 +20,47 @@ func NewDataFrame() *DataFrame {
 // Copy instanciates a new private data frame with receiver as parent.
 func (p *DataFrame) Copy() *DataFrame {
 	result := NewDataFrame()
+	result.copyFrom(p)
 
+	return result
+}
+
+// copyFrom copies data of given frame, and sets it as parent
+func (p *DataFrame) copyFrom(parent *DataFrame) {
+	for k, v := range parent.data {
+		p.data[k] = v
+	}
+
+	p.parent = parent
+}
+
+// clone returns a copy of data frame, with the same parent
+func (p *DataFrame) clone() *DataFrame {
+	result := NewDataFrame()
 	for k, v := range p.data {
 		result.data[k] = v
 	}
 
-	result.parent = p
+	result.parent = p.parent
 
 	return result
 }
 
-// newIterDataFrame instanciates a new private data frame with receiver as parent and with iteration data set (@index, @key, @first, @last)
-func (p *DataFrame) newIterDataFrame(length int, i int, key interface{}) *DataFrame {
-	result := p.Copy()
+// setIterData sets iteration data (@index, @key, @first, @last)
+func (p *DataFrame) setIterData(length int, i int, key interface{}) {
+	p.Set("index", i)
+	p.Set("key", key)
+	p.Set("first", i == 0)
+	p.Set("last", i == length-1)
+}
 
-	result.Set("index", i)
-	result.Set("key", key)
-	result.Set("first", i == 0)
-	result.Set("last", i == length-1)
+// reset clears data frame, so that it can be reused
+func (p *DataFrame) reset() {
+	for k := range p.data {
+		delete(p.data, k)
+	}
 
-	return result
+	p.parent = nil
 }
 
 // Set sets a data value.
@@ -0,0 +1,66 @@
+package raymond
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// DeterministicSeed is the seed of the source of randomness used in deterministic mode.
+const DeterministicSeed int64 = 1
+
+// DeterministicTime is the time returned by Options.Now() in deterministic mode.
+var DeterministicTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Now returns the current time of evaluation.
+//
+// Helpers should use it instead of time.Now(), so that their output is stable in deterministic mode or with a clock provided with ExecOptions.
+func (options *Options) Now() time.Time {
+	return options.eval.now()
+}
+
+// now returns the evaluation current time
+func (v *evalVisitor) now() time.Time {
+	switch {
+	case v.clock != nil:
+		return v.clock()
+	case v.deterministic:
+		return DeterministicTime
+	default:
+		return time.Now()
+	}
+}
+
+// mapKeys returns the keys of given map, sorted in deterministic mode
+func (v *evalVisitor) mapKeys(val reflect.Value) []reflect.Value {
+	keys := val.MapKeys()
+
+	if v.deterministic {
+		sortMapKeys(keys)
+	}
+
+	return keys
+}
+
+// sortMapKeys sorts given map keys: numbers by value, strings and other keys by their string representation
+func sortMapKeys(keys []reflect.Value) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+
+		if a.Kind() == b.Kind() {
+			switch a.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return a.Int() < b.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				return a.Uint() < b.Uint()
+			case reflect.Float32, reflect.Float64:
+				return a.Float() < b.Float()
+			case reflect.String:
+				return a.String() < b.String()
+			}
+		}
+
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	})
+}
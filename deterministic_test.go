@@ -0,0 +1,65 @@
+package raymond
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDeterministic(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each names}}{{@key}}={{this}} {{/each}}{{#each ids}}{{@key}} {{/each}}{{uuid}} {{randomInt 0 1000000}} {{randomString 8}} {{date "2006-01-02"}}`)
+	tpl.RegisterHelper("date", func(layout string, options *Options) string {
+		return options.Now().Format(layout)
+	})
+
+	names := make(map[string]int)
+	ids := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		names[string(rune('a'+i))] = i
+		ids[i*5] = true
+	}
+
+	ctx := map[string]interface{}{"names": names, "ids": ids}
+	opts := ExecOptions{Deterministic: true}
+
+	first, err := tpl.ExecWithOptions(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if output, _ := tpl.ExecWithOptions(ctx, opts); output != first {
+			t.Fatalf("Deterministic outputs differ:\n\t%s\n\t%s", first, output)
+		}
+	}
+
+	expected := "a=0 b=1 c=2 d=3 e=4 f=5 g=6 h=7 i=8 j=9 k=10 l=11 m=12 n=13 o=14 p=15 q=16 r=17 s=18 t=19 0 5 10 15 20 25 30 35 40 45 50 55 60 65 70 75 80 85 90 95 "
+	if first[:len(expected)] != expected {
+		t.Errorf("Maps are not iterated in sorted key order: %s", first)
+	}
+
+	if date := first[len(first)-10:]; date != "2000-01-01" {
+		t.Errorf("Unexpected date in deterministic mode: %s", date)
+	}
+}
+
+func TestDeterministicNow(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{year}}`)
+	tpl.RegisterHelper("year", func(options *Options) string {
+		return strconv.Itoa(options.Now().Year())
+	})
+
+	clock := func() time.Time { return time.Date(1999, time.December, 31, 23, 59, 59, 0, time.UTC) }
+
+	if output, _ := tpl.ExecWithOptions(nil, ExecOptions{Deterministic: true, Now: clock}); output != "1999" {
+		t.Errorf("Unexpected year with clock: %s", output)
+	}
+
+	if output := tpl.MustExec(nil); output != strconv.Itoa(time.Now().Year()) {
+		t.Errorf("Unexpected current year: %s", output)
+	}
+}
@@ -0,0 +1,403 @@
+package raymond
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// TemplateDoc documents a template: the context it expects, and the partials and helpers it uses.
+type TemplateDoc struct {
+	// Name is the template name
+	Name string `json:"name"`
+
+	// Description is the text of doc comments, that is not a tag
+	Description string `json:"description,omitempty"`
+
+	// Params are the context values documented with @param tags, and the ones used by template, in path order
+	Params []ParamDoc `json:"params"`
+
+	// Partials are the names of partials rendered by template, sorted
+	Partials []string `json:"partials"`
+
+	// Helpers are the names of helpers called by template, sorted
+	Helpers []string `json:"helpers"`
+}
+
+// ParamDoc documents a context value expected by a template.
+type ParamDoc struct {
+	// Path is the path of value from template context, where "[]" denotes the elements of an array, eg. "posts[].title"
+	Path string `json:"path"`
+
+	// Type is the type of value, eg. "string"
+	Type string `json:"type,omitempty"`
+
+	// Description describes value
+	Description string `json:"description,omitempty"`
+
+	// Documented is true if value is documented with a @param tag, and false if it is only used by template
+	Documented bool `json:"documented"`
+}
+
+// docScope is the context of a block, while documenting a template
+type docScope struct {
+	// path of context from template context, with a trailing dot, eg. "posts[]."
+	prefix string
+
+	// paths of block params, by name, empty for block params that are not context values
+	params map[string]string
+}
+
+// docWalker documents a template
+type docWalker struct {
+	tpl    *Template
+	scopes []docScope
+
+	doc      *TemplateDoc
+	params   map[string]*ParamDoc
+	partials map[string]bool
+	helpers  map[string]bool
+}
+
+// Document returns the documentation of given templates, by name, in name order.
+//
+// Doc comments are comments with tags, ie. lines starting with "@". A "@param path type description" tag documents a context value, where type and description are optional, and type can be enclosed in braces. Other lines of doc comments are the template description:
+//
+//	{{!--
+//	  Renders a blog post.
+//	  @param post.title string Title of post
+//	  @param post.tags[] {string} Tags of post
+//	--}}
+//
+// Context values used by templates are documented too, with their path from template context: paths in #each blocks are relative to array elements, and paths in #with blocks to their param. Helpers are looked up on each template and globally, so register them before documenting.
+func Document(templates map[string]*Template) ([]TemplateDoc, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	result := make([]TemplateDoc, len(names))
+
+	for i, name := range names {
+		tpl := templates[name]
+
+		if err := tpl.parse(); err != nil {
+			return nil, err
+		}
+
+		w := &docWalker{
+			tpl:      tpl,
+			scopes:   []docScope{{}},
+			doc:      &result[i],
+			params:   make(map[string]*ParamDoc),
+			partials: make(map[string]bool),
+			helpers:  make(map[string]bool),
+		}
+
+		w.doc.Name = name
+		w.program(tpl.program)
+		w.finish()
+	}
+
+	return result, nil
+}
+
+// WriteDocsMarkdown writes given template documentations as a Markdown catalog.
+func WriteDocsMarkdown(w io.Writer, docs []TemplateDoc) error {
+	var b strings.Builder
+
+	b.WriteString("# Templates\n")
+
+	for _, doc := range docs {
+		fmt.Fprintf(&b, "\n## %s\n", doc.Name)
+
+		if doc.Description != "" {
+			fmt.Fprintf(&b, "\n%s\n", doc.Description)
+		}
+
+		if len(doc.Params) > 0 {
+			b.WriteString("\n### Context\n\n| Path | Type | Description |\n| --- | --- | --- |\n")
+
+			for _, param := range doc.Params {
+				fmt.Fprintf(&b, "| `%s` | %s | %s |\n", markdownCell(param.Path), markdownCell(param.Type), markdownCell(param.Description))
+			}
+		}
+
+		for _, section := range []struct {
+			title string
+			names []string
+		}{{"Partials", doc.Partials}, {"Helpers", doc.Helpers}} {
+			if len(section.names) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(&b, "\n### %s\n\n", section.title)
+
+			for _, name := range section.names {
+				fmt.Fprintf(&b, "- `%s`\n", name)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes given text to be written in a Markdown table cell
+func markdownCell(text string) string {
+	return strings.Replace(strings.Replace(text, "|", `\|`, -1), "\n", " ", -1)
+}
+
+// finish fills documentation with collected params, partials and helpers
+func (w *docWalker) finish() {
+	w.doc.Params = []ParamDoc{}
+	for _, param := range w.params {
+		w.doc.Params = append(w.doc.Params, *param)
+	}
+
+	sort.Slice(w.doc.Params, func(i, j int) bool { return w.doc.Params[i].Path < w.doc.Params[j].Path })
+
+	w.doc.Partials = sortedKeys(w.partials)
+	w.doc.Helpers = sortedKeys(w.helpers)
+}
+
+// sortedKeys returns the keys of given set, sorted
+func sortedKeys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// comment parses the tags and description of given comment, if it is a doc comment
+func (w *docWalker) comment(node *ast.CommentStatement) {
+	lines := strings.Split(node.Value, "\n")
+
+	var description []string
+	tagged := false
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if !strings.HasPrefix(line, "@") {
+			description = append(description, line)
+			continue
+		}
+
+		tagged = true
+
+		fields := strings.Fields(line)
+		if (fields[0] != "@param") || (len(fields) < 2) {
+			continue
+		}
+
+		param := &ParamDoc{Path: fields[1], Documented: true}
+
+		if len(fields) > 2 {
+			param.Type = strings.TrimSuffix(strings.TrimPrefix(fields[2], "{"), "}")
+			param.Description = strings.Join(fields[3:], " ")
+		}
+
+		w.params[param.Path] = param
+	}
+
+	if !tagged {
+		// not a doc comment
+		return
+	}
+
+	if text := strings.TrimSpace(strings.Join(description, "\n")); text != "" {
+		if w.doc.Description != "" {
+			w.doc.Description += "\n\n"
+		}
+
+		w.doc.Description += text
+	}
+}
+
+// use records a context value used by template, unless it is documented
+func (w *docWalker) use(path string) {
+	if (path != "") && (w.params[path] == nil) {
+		w.params[path] = &ParamDoc{Path: path}
+	}
+}
+
+// resolve returns the path from template context of given path expression, or an empty string if it is not a context value
+func (w *docWalker) resolve(node *ast.PathExpression) string {
+	if node.Data {
+		if node.IsDataRoot() && (len(node.Parts) > 1) {
+			return strings.Join(node.Parts[1:], ".")
+		}
+
+		// data variable
+		return ""
+	}
+
+	if (node.Depth == 0) && (len(node.Parts) > 0) {
+		// innermost block param with that name
+		for i := len(w.scopes) - 1; i >= 0; i-- {
+			if target, ok := w.scopes[i].params[node.Parts[0]]; ok {
+				if target == "" {
+					return ""
+				}
+
+				return strings.Join(append([]string{target}, node.Parts[1:]...), ".")
+			}
+		}
+	}
+
+	i := len(w.scopes) - 1 - node.Depth
+	if i < 0 {
+		i = 0
+	}
+
+	prefix := w.scopes[i].prefix
+	if len(node.Parts) == 0 {
+		// "this" is the context itself
+		return strings.TrimSuffix(prefix, ".")
+	}
+
+	return prefix + strings.Join(node.Parts, ".")
+}
+
+// program documents given program
+func (w *docWalker) program(program *ast.Program) {
+	if program == nil {
+		return
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.CommentStatement:
+			w.comment(n)
+		case *ast.MustacheStatement:
+			if path, ok := n.Expression.Path.(*ast.PathExpression); !w.expression(n.Expression, false) && ok {
+				w.use(w.resolve(path))
+			}
+		case *ast.BlockStatement:
+			w.block(n)
+		case *ast.PartialStatement:
+			switch name := n.Name.(type) {
+			case *ast.PathExpression:
+				w.partials[name.Original] = true
+			case *ast.StringLiteral:
+				w.partials[name.Value] = true
+			default:
+				w.node(n.Name)
+			}
+
+			w.nodes(n.Params)
+			w.hash(n.Hash)
+		}
+	}
+}
+
+// block documents given block statement
+func (w *docWalker) block(node *ast.BlockStatement) {
+	helper := w.expression(node.Expression, false)
+
+	path := ""
+	if len(node.Expression.Params) > 0 {
+		if param, ok := node.Expression.Params[0].(*ast.PathExpression); ok {
+			path = w.resolve(param)
+		}
+	} else if name, ok := node.Expression.Path.(*ast.PathExpression); ok && !helper {
+		// section
+		path = w.resolve(name)
+	}
+
+	scope := docScope{prefix: w.scopes[len(w.scopes)-1].prefix, params: make(map[string]string)}
+	target := ""
+
+	if path != "" {
+		switch name := node.Expression.HelperName(); {
+		case name == "each":
+			target = path + "[]"
+			scope.prefix = target + "."
+		case (name == "with") || !helper:
+			target = path
+			scope.prefix = target + "."
+		}
+	}
+
+	if node.Program != nil {
+		for i, param := range node.Program.BlockParams {
+			if i == 0 {
+				scope.params[param] = target
+			} else {
+				// eg. index of #each
+				scope.params[param] = ""
+			}
+		}
+	}
+
+	w.scopes = append(w.scopes, scope)
+	w.program(node.Program)
+	w.scopes = w.scopes[:len(w.scopes)-1]
+
+	// inverse program is rendered with current context
+	w.program(node.Inverse)
+}
+
+// expression documents the params and hash of given expression, and returns true if it is a helper call, ie. if it is a subexpression, if it has arguments or if it is a registered helper
+func (w *docWalker) expression(node *ast.Expression, call bool) bool {
+	name := node.HelperName()
+	if name == "" {
+		name = node.NamespacedHelperName()
+	}
+
+	helper := false
+
+	if name != "" {
+		_, registered := w.tpl.Helper(name)
+		helper = call || registered || (len(node.Params) > 0) || (node.Hash != nil)
+	}
+
+	if helper {
+		w.helpers[name] = true
+	} else if _, ok := node.Path.(*ast.PathExpression); !ok {
+		w.node(node.Path)
+	}
+
+	w.nodes(node.Params)
+	w.hash(node.Hash)
+
+	return helper
+}
+
+// nodes documents given params
+func (w *docWalker) nodes(nodes []ast.Node) {
+	for _, node := range nodes {
+		w.node(node)
+	}
+}
+
+// hash documents the values of given hash
+func (w *docWalker) hash(node *ast.Hash) {
+	if node == nil {
+		return
+	}
+
+	for _, pair := range node.Pairs {
+		w.node(pair.Val)
+	}
+}
+
+// node documents given param
+func (w *docWalker) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.PathExpression:
+		w.use(w.resolve(n))
+	case *ast.SubExpression:
+		w.expression(n.Expression, true)
+	}
+}
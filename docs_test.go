@@ -0,0 +1,83 @@
+package raymond
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	t.Parallel()
+
+	source := `{{!--
+  Renders a blog post.
+  @param post.title string Title of post
+  @param post.tags[] {string}
+--}}
+{{! not a doc comment }}
+<h1>{{post.title}}</h1>
+{{#with post.author as |author|}}{{name}} {{author.email}}{{/with}}
+{{#each post.comments}}{{upper body}} {{@index}} {{../site}} {{@root.site}}{{else}}{{noComments}}{{/each}}
+{{#each post.tags as |tag i|}}{{tag}}{{i}}{{/each}}
+{{> footer year=(year now)}}{{> (layout)}}`
+
+	tpl := MustParse(source)
+	tpl.RegisterHelper("layout", func() string { return "footer" })
+
+	docs, err := Document(map[string]*Template{"post": tpl})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []TemplateDoc{{
+		Name:        "post",
+		Description: "Renders a blog post.",
+		Params: []ParamDoc{
+			{Path: "noComments"},
+			{Path: "now"},
+			{Path: "post.author"},
+			{Path: "post.author.email"},
+			{Path: "post.author.name"},
+			{Path: "post.comments"},
+			{Path: "post.comments[].body"},
+			{Path: "post.tags"},
+			{Path: "post.tags[]", Type: "string", Documented: true},
+			{Path: "post.title", Type: "string", Description: "Title of post", Documented: true},
+			{Path: "site"},
+		},
+		Partials: []string{"footer"},
+		Helpers:  []string{"each", "layout", "upper", "with", "year"},
+	}}
+
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("Unexpected docs\nexpected:\n\t%+v\ngot:\n\t%+v", expected, docs)
+	}
+}
+
+func TestWriteDocsMarkdown(t *testing.T) {
+	t.Parallel()
+
+	docs := []TemplateDoc{
+		{
+			Name:        "post",
+			Description: "Renders a post.",
+			Params:      []ParamDoc{{Path: "title", Type: "string", Description: "Title | subtitle", Documented: true}, {Path: "body"}},
+			Partials:    []string{"footer"},
+		},
+		{Name: "empty"},
+	}
+
+	expected := "# Templates\n\n## post\n\nRenders a post.\n\n" +
+		"### Context\n\n| Path | Type | Description |\n| --- | --- | --- |\n" +
+		"| `title` | string | Title \\| subtitle |\n| `body` |  |  |\n\n" +
+		"### Partials\n\n- `footer`\n\n## empty\n"
+
+	var buf bytes.Buffer
+	if err := WriteDocsMarkdown(&buf, docs); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("Unexpected markdown\nexpected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,227 @@
+package raymond
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// opcodeNames are the names of opcodes in dumps
+var opcodeNames = [...]string{
+	opContent:  "content",
+	opPath:     "path",
+	opMustache: "mustache",
+	opBlock:    "block",
+	opPartial:  "partial",
+	opBranch:   "branch",
+	opNonce:    "nonce",
+}
+
+// dumper writes the dump of a template
+type dumper struct {
+	tpl *Template
+	buf strings.Builder
+
+	// numbers of referenced programs, and programs to dump, in reference order
+	ids   map[*ast.Program]int
+	queue []*ast.Program
+}
+
+// Dump returns a stable textual representation of the compiled template, so that code reviews can see how a template change altered the compiled program, and caching layers can fingerprint templates.
+//
+// Programs are numbered in reference order, the main program being "program 0", and each one lists its bytecode instructions, or its statements if it is interpreted. Statements are written in canonical form, with folded subexpressions followed by their value, eg. `(upper "a")="A"`. Positions, comments and whitespace inside mustaches are not part of the dump, so that formatting a template does not change it.
+//
+// An error is returned if template can't be parsed.
+func (tpl *Template) Dump() (string, error) {
+	if err := tpl.parse(); err != nil {
+		return "", err
+	}
+
+	d := &dumper{
+		tpl: tpl,
+		ids: make(map[*ast.Program]int),
+	}
+
+	d.ref(tpl.program)
+
+	for i := 0; i < len(d.queue); i++ {
+		d.program(i, d.queue[i])
+	}
+
+	return d.buf.String(), nil
+}
+
+// Fingerprint returns the hex encoded SHA-256 hash of the dump of template. See Dump().
+func (tpl *Template) Fingerprint() (string, error) {
+	dump, err := tpl.Dump()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(dump))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ref returns a reference to given program, eg. "program 1", or "none" if it is nil
+func (d *dumper) ref(program *ast.Program) string {
+	if program == nil {
+		return "none"
+	}
+
+	result, ok := d.ids[program]
+	if !ok {
+		result = len(d.queue)
+		d.ids[program] = result
+		d.queue = append(d.queue, program)
+	}
+
+	return "program " + strconv.Itoa(result)
+}
+
+// line writes an instruction or statement line
+func (d *dumper) line(format string, args ...interface{}) {
+	d.buf.WriteString("  ")
+	fmt.Fprintf(&d.buf, format, args...)
+	d.buf.WriteByte('\n')
+}
+
+// program dumps given program, with given number
+func (d *dumper) program(id int, program *ast.Program) {
+	code := d.tpl.code.get(program)
+
+	if code == nil {
+		fmt.Fprintf(&d.buf, "program %d interpreted\n", id)
+
+		for _, node := range program.Body {
+			switch n := node.(type) {
+			case *ast.ContentStatement:
+				d.line("content %s", strconv.Quote(n.Value))
+			case *ast.MustacheStatement:
+				d.line("mustache %s", d.mustache(n))
+			case *ast.BlockStatement:
+				d.line("block %s", d.block(n))
+			case *ast.PartialStatement:
+				d.line("partial %s", d.partial(n))
+			}
+		}
+
+		return
+	}
+
+	fmt.Fprintf(&d.buf, "program %d\n", id)
+
+	for _, in := range code.code {
+		op, operand := in.op(), in.operand()
+
+		switch op {
+		case opContent:
+			d.line("content %s", strconv.Quote(code.consts[operand]))
+		case opPath:
+			d.line("path %s", d.mustache(code.paths[operand].stmt))
+		case opMustache:
+			d.line("mustache %s", d.mustache(code.mustaches[operand]))
+		case opBlock:
+			d.line("block %s", d.block(code.blocks[operand]))
+		case opPartial:
+			d.line("partial %s", d.partial(code.partials[operand]))
+		case opBranch:
+			branch := code.branches[operand]
+			d.line("branch {{#%s}} %s", d.expression(branch.stmt.Expression), d.ref(branch.program))
+		default:
+			d.line("%s", opcodeNames[op])
+		}
+	}
+}
+
+// mustache returns the canonical form of given mustache statement
+func (d *dumper) mustache(node *ast.MustacheStatement) string {
+	if node.Unescaped {
+		return "{{{" + d.expression(node.Expression) + "}}}"
+	}
+
+	return "{{" + d.expression(node.Expression) + "}}"
+}
+
+// block returns the canonical form of given block statement, with the numbers of its programs
+func (d *dumper) block(node *ast.BlockStatement) string {
+	result := "{{#" + d.expression(node.Expression)
+
+	if (node.Program != nil) && (len(node.Program.BlockParams) > 0) {
+		result += " as |" + strings.Join(node.Program.BlockParams, " ") + "|"
+	}
+
+	return result + "}} " + d.ref(node.Program) + " else " + d.ref(node.Inverse)
+}
+
+// partial returns the canonical form of given partial statement
+func (d *dumper) partial(node *ast.PartialStatement) string {
+	parts := []string{d.node(node.Name)}
+
+	for _, param := range node.Params {
+		parts = append(parts, d.node(param))
+	}
+
+	if node.Hash != nil {
+		parts = append(parts, d.hash(node.Hash))
+	}
+
+	return "{{> " + strings.Join(parts, " ") + "}}"
+}
+
+// expression returns the canonical form of given expression
+func (d *dumper) expression(node *ast.Expression) string {
+	parts := []string{d.node(node.Path)}
+
+	for _, param := range node.Params {
+		parts = append(parts, d.node(param))
+	}
+
+	if node.Hash != nil {
+		parts = append(parts, d.hash(node.Hash))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// hash returns the canonical form of given hash
+func (d *dumper) hash(node *ast.Hash) string {
+	pairs := make([]string, len(node.Pairs))
+	for i, pair := range node.Pairs {
+		pairs[i] = pair.Key + "=" + d.node(pair.Val)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// node returns the canonical form of given expression node
+func (d *dumper) node(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.PathExpression:
+		return n.Original
+	case *ast.StringLiteral:
+		return strconv.Quote(n.Value)
+	case *ast.BooleanLiteral:
+		return n.Canonical()
+	case *ast.NumberLiteral:
+		return n.Canonical()
+	case *ast.SubExpression:
+		result := "(" + d.expression(n.Expression) + ")"
+
+		if folded, ok := d.tpl.code.folded(n); ok {
+			if str, isStr := folded.value.(string); isStr {
+				result += "=" + strconv.Quote(str)
+			} else {
+				result += fmt.Sprintf("=%v", folded.value)
+			}
+		}
+
+		return result
+	default:
+		return ""
+	}
+}
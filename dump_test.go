@@ -0,0 +1,77 @@
+package raymond
+
+import "testing"
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+
+	source := `<h1>{{ title }}</h1>
+{{#each items as |item i|}}{{> item name=item.name}}{{{body}}}{{else}}{{upper "x" (concat "a" 1)}}{{/each}}
+{{#if true}}yes{{else}}no{{/if}}{{! comment }}<script>{{#unless x}}{{/unless}}`
+
+	expected := `program 0
+  content "<h1>"
+  path {{title}}
+  content "</h1>\n"
+  block {{#each items as |item i|}} program 1 else program 2
+  content "\n"
+  branch {{#if true}} program 3
+  content "<script"
+  nonce
+  content ">"
+  block {{#unless x}} program 4 else none
+program 1
+  partial {{> item name=item.name}}
+  path {{{body}}}
+program 2
+  mustache {{upper "x" (concat "a" 1)="a1"}}
+program 3
+  content "yes"
+program 4
+`
+
+	dump, err := MustParse(source).Dump()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if dump != expected {
+		t.Errorf("Unexpected dump\nexpected:\n%s\ngot:\n%s", expected, dump)
+	}
+
+	if _, err := newTemplate("{{foo").Dump(); err == nil {
+		t.Errorf("Expected a parse error")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	fingerprint := func(source string) string {
+		tpl, err := Parse(source)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		result, err := tpl.Fingerprint()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		return result
+	}
+
+	source := "<p>{{#each items}}{{name}}{{/each}}</p>"
+
+	if fingerprint(source) != fingerprint("<p>{{#each  items }}{{! name }}{{ name }}{{/each}}</p>") {
+		t.Errorf("Expected formatting and comments not to change fingerprint")
+	}
+
+	if fingerprint(source) == fingerprint("<p>{{#each items}}{{title}}{{/each}}</p>") {
+		t.Errorf("Expected a template change to change fingerprint")
+	}
+
+	if len(fingerprint(source)) != 64 {
+		t.Errorf("Unexpected fingerprint: %s", fingerprint(source))
+	}
+}
@@ -0,0 +1,150 @@
+// Package email renders transactional emails with raymond templates: a subject line, an HTML body and a plain-text body, as ready-to-send MIME parts.
+//
+// The plain-text body is optional: when it is not given, it is generated from the HTML body template, by converting its content to text and keeping its mustaches, so both bodies render the same values:
+//
+//	tpl, err := email.New(email.Sources{
+//		Subject: "Welcome {{name}}!",
+//		HTML:    `<p>Hello <b>{{name}}</b>,</p><p><a href="{{url}}">Confirm your account</a></p>{{> footer}}`,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//
+//	if err := tpl.RegisterPartial("footer", "<p>The Team</p>", ""); err != nil {
+//		return err
+//	}
+//
+//	msg, err := tpl.Exec(ctx)
+//	if err != nil {
+//		return err
+//	}
+//
+//	// msg.Text is "Hello jean,\n\nConfirm your account (https://example.com/confirm)\n\nThe Team"
+//	err = msg.WriteMIME(&buf, textproto.MIMEHeader{"From": {from}, "To": {to}})
+//
+// Subject and plain-text templates are parsed with raymond.EscapeNone, so their mustaches are not HTML escaped. Partials are shared by all templates, with a plain-text version that is generated from the HTML one too, unless given.
+package email
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+// Sources represents the sources of an email template.
+type Sources struct {
+	// Subject is the subject line. Its whitespaces are collapsed once rendered, so it always fits on a single header line.
+	Subject string
+
+	// HTML is the HTML body.
+	HTML string
+
+	// Text is the plain-text body. If empty, it is generated from the HTML body with TextFromHTML().
+	Text string
+}
+
+// Template represents a parsed email template. It is safe for concurrent use once its helpers and partials are registered.
+type Template struct {
+	subject *raymond.Template
+	html    *raymond.Template
+	text    *raymond.Template
+}
+
+// Message represents a rendered email.
+type Message struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// New instanciates an email template by parsing given sources.
+func New(src Sources) (*Template, error) {
+	var err error
+
+	if src.Text == "" {
+		if src.Text, err = TextFromHTML(src.HTML); err != nil {
+			return nil, fmt.Errorf("email: html: %s", err)
+		}
+	}
+
+	result := &Template{}
+
+	if result.subject, err = parseText(src.Subject); err != nil {
+		return nil, fmt.Errorf("email: subject: %s", err)
+	}
+
+	if result.html, err = raymond.Parse(src.HTML); err != nil {
+		return nil, fmt.Errorf("email: html: %s", err)
+	}
+
+	if result.text, err = parseText(src.Text); err != nil {
+		return nil, fmt.Errorf("email: text: %s", err)
+	}
+
+	return result, nil
+}
+
+// parseText parses a template that outputs plain text
+func parseText(source string) (*raymond.Template, error) {
+	return raymond.ParseWithOptions(source, raymond.ParseOptions{Escaping: raymond.EscapeNone})
+}
+
+// RegisterHelper registers a helper for all the templates of that email.
+func (t *Template) RegisterHelper(name string, helper interface{}) {
+	t.subject.RegisterHelper(name, helper)
+	t.html.RegisterHelper(name, helper)
+	t.text.RegisterHelper(name, helper)
+}
+
+// RegisterHelpers registers several helpers for all the templates of that email.
+func (t *Template) RegisterHelpers(helpers map[string]interface{}) {
+	for name, helper := range helpers {
+		t.RegisterHelper(name, helper)
+	}
+}
+
+// RegisterPartial registers a partial with given HTML and plain-text sources. The HTML source is used by the HTML body, and the plain-text one by the subject and the plain-text body. If text is empty, it is generated from the HTML source with TextFromHTML().
+func (t *Template) RegisterPartial(name string, html string, text string) error {
+	if text == "" {
+		var err error
+		if text, err = TextFromHTML(html); err != nil {
+			return fmt.Errorf("email: partial %s: %s", name, err)
+		}
+	}
+
+	t.html.RegisterPartial(name, html)
+	t.subject.RegisterPartial(name, text)
+	t.text.RegisterPartial(name, text)
+
+	return nil
+}
+
+// Exec renders the subject and bodies of that email with given context.
+func (t *Template) Exec(ctx interface{}) (*Message, error) {
+	return t.ExecWithOptions(ctx, raymond.ExecOptions{})
+}
+
+// ExecWithOptions renders the subject and bodies of that email with given context and evaluation options.
+func (t *Template) ExecWithOptions(ctx interface{}, opts raymond.ExecOptions) (*Message, error) {
+	subject, err := t.subject.ExecWithOptions(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("email: subject: %s", err)
+	}
+
+	html, err := t.html.ExecWithOptions(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("email: html: %s", err)
+	}
+
+	text, err := t.text.ExecWithOptions(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("email: text: %s", err)
+	}
+
+	return &Message{
+		Subject: strings.Join(strings.Fields(subject), " "),
+		HTML:    html,
+		Text:    text,
+	}, nil
+}
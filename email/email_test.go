@@ -0,0 +1,181 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+const orderHTML = `<html>
+<head><title>Order</title><style>p { color: red; }</style></head>
+<body>
+<h1>Order #{{id}}</h1>
+<ul>
+  {{#each items}}
+  <li>{{name}} &times; {{qty}}</li>
+  {{/each}}
+</ul>
+{{#if note}}<p>Note: {{note}}</p>{{/if}}
+<p><a href="{{url}}" class="{{#if urgent}}urgent{{/if}}">Track your order</a></p>
+{{> footer}}
+</body>
+</html>`
+
+func orderTemplate(t *testing.T) *Template {
+	tpl, err := New(Sources{
+		Subject: "Order #{{id}}: {{shout status}}\n",
+		HTML:    orderHTML,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tpl.RegisterHelper("shout", strings.ToUpper)
+
+	if err := tpl.RegisterPartial("footer", "<p>The <b>Shop</b> Team</p>", ""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return tpl
+}
+
+var orderCtx = map[string]interface{}{
+	"id":     42,
+	"status": "shipped",
+	"url":    "https://example.com/track?id=42&from=mail",
+	"note":   "<fragile>",
+	"items": []map[string]interface{}{
+		{"name": "Book", "qty": 2},
+		{"name": "Pen", "qty": 1},
+	},
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+
+	msg, err := orderTemplate(t).Exec(orderCtx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if msg.Subject != "Order #42: SHIPPED" {
+		t.Errorf("Unexpected subject: %q", msg.Subject)
+	}
+
+	expected := "Order #42\n\n- Book × 2\n- Pen × 1\n\nNote: <fragile>\n\nTrack your order (https://example.com/track?id=42&from=mail)\n\nThe Shop Team"
+	if msg.Text != expected {
+		t.Errorf("Unexpected text:\nexpected:\n%s\ngot:\n%s", expected, msg.Text)
+	}
+
+	if !strings.Contains(msg.HTML, "<p>Note: &lt;fragile&gt;</p>") || !strings.Contains(msg.HTML, `href="https://example.com/track?id&#x3D;42&amp;from&#x3D;mail"`) {
+		t.Errorf("Unexpected HTML: %s", msg.HTML)
+	}
+}
+
+func TestExecText(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := New(Sources{
+		Subject: "Hi",
+		HTML:    "<p>Hi {{name}}</p>{{> sig}}",
+		Text:    "Hi {{name}}\n-- \n{{> sig}}",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := tpl.RegisterPartial("sig", "<i>Jean & co</i>", "Jean and co"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	msg, err := tpl.Exec(map[string]string{"name": "<Marcel>"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if (msg.Text != "Hi <Marcel>\n-- \nJean and co") || (msg.HTML != "<p>Hi &lt;Marcel&gt;</p><i>Jean & co</i>") {
+		t.Errorf("Unexpected message: %q %q", msg.Text, msg.HTML)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	t.Parallel()
+
+	for _, src := range []Sources{
+		{Subject: "{{#if}}", HTML: "ok"},
+		{HTML: "{{/if}}"},
+		{HTML: "ok", Text: "{{"},
+	} {
+		if _, err := New(src); err == nil {
+			t.Errorf("Expected error with %+v", src)
+		}
+	}
+}
+
+func TestWriteMIME(t *testing.T) {
+	t.Parallel()
+
+	msg := &Message{
+		Subject: "Votre commande a été expédiée",
+		HTML:    "<p>Merci !</p>",
+		Text:    "Merci !",
+	}
+
+	var buf bytes.Buffer
+	if err := msg.WriteMIME(&buf, textproto.MIMEHeader{"from": {"shop@example.com"}}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	m, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if (err != nil) || (subject != msg.Subject) {
+		t.Errorf("Unexpected subject: %q %v", m.Header.Get("Subject"), err)
+	}
+
+	if (m.Header.Get("From") != "shop@example.com") || (m.Header.Get("MIME-Version") != "1.0") {
+		t.Errorf("Unexpected header: %v", m.Header)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if (err != nil) || (mediaType != "multipart/alternative") {
+		t.Fatalf("Unexpected content type: %s %v", mediaType, err)
+	}
+
+	r := multipart.NewReader(m.Body, params["boundary"])
+
+	for _, expected := range []struct {
+		contentType string
+		body        string
+	}{
+		{ContentTypeText, msg.Text},
+		{ContentTypeHTML, msg.HTML},
+	} {
+		part, err := r.NextRawPart()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if (part.Header.Get("Content-Type") != expected.contentType) || (string(body) != expected.body) {
+			t.Errorf("Unexpected part: %v %q", part.Header, body)
+		}
+	}
+
+	if _, err := r.NextPart(); err != io.EOF {
+		t.Errorf("Unexpected part: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sort"
+)
+
+// Content types of message parts.
+const (
+	ContentTypeHTML = "text/html; charset=utf-8"
+	ContentTypeText = "text/plain; charset=utf-8"
+)
+
+// Part represents a MIME part of a message, with its body already encoded.
+type Part struct {
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// Parts returns the MIME parts of message: the plain-text body, then the HTML one, as expected in a multipart/alternative message. Bodies are quoted-printable encoded.
+func (m *Message) Parts() []Part {
+	return []Part{
+		newPart(ContentTypeText, m.Text),
+		newPart(ContentTypeHTML, m.HTML),
+	}
+}
+
+// newPart instanciates a quoted-printable encoded part
+func newPart(contentType string, body string) Part {
+	var buf bytes.Buffer
+
+	w := quotedprintable.NewWriter(&buf)
+	w.Write([]byte(body))
+	w.Close()
+
+	return Part{
+		Header: textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		Body: buf.Bytes(),
+	}
+}
+
+// WriteMIME writes message as a multipart/alternative MIME message, ready to be sent, eg. with smtp.SendMail().
+//
+// Given header fields, like From and To, are written as is, followed by the encoded Subject, and the MIME-Version and Content-Type fields. Use mail.Address.String() to format addresses with non-ASCII names.
+func (m *Message) WriteMIME(w io.Writer, header textproto.MIMEHeader) error {
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
+
+	for _, part := range m.Parts() {
+		pw, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+
+		if _, err := pw.Write(part.Body); err != nil {
+			return err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	fields := textproto.MIMEHeader{}
+	for key, values := range header {
+		fields[textproto.CanonicalMIMEHeaderKey(key)] = values
+	}
+
+	fields.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	fields.Del("Mime-Version")
+	fields["MIME-Version"] = []string{"1.0"}
+	fields.Set("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+
+	if err := writeHeader(w, fields); err != nil {
+		return err
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeHeader writes given header fields sorted by key, and the blank line that ends them
+func writeHeader(w io.Writer, header textproto.MIMEHeader) error {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range header[key] {
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
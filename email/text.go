@@ -0,0 +1,453 @@
+package email
+
+import (
+	"html"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+// TextFromHTML returns the source of a plain-text template generated from the source of given HTML template.
+//
+// The content nodes of the template AST are converted to text, and its mustaches, blocks and partials are kept, so that the plain-text template renders the same values:
+//
+//   - tags are removed, and the content of script, style, head and title elements is skipped,
+//   - whitespaces are collapsed, except in pre elements, and entities are decoded,
+//   - paragraphs, headings, lists, tables and line breaks start new lines, and list items are prefixed with "- ",
+//   - links are followed by their URL in parentheses, and images are replaced by their alt text,
+//   - mustaches in tags are removed, except in the href attribute of links.
+//
+// Triple-stash mustaches are kept as is, so values that are already HTML are output as HTML in plain text.
+func TextFromHTML(source string) (string, error) {
+	program, err := parser.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	nodes := statements(program, nil)
+
+	w := &textWriter{}
+
+	pos := 0
+	for i, node := range nodes {
+		start := node.Location().Pos
+
+		if pos < start {
+			// block closes and inverses that follow a content
+			if gap := source[pos:start]; gap != `\` {
+				w.mustache(gap, false)
+			}
+		}
+
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			w.content(n.Original)
+			pos = start + len(n.Original)
+
+		default:
+			pos = len(source)
+			for _, next := range nodes[i+1:] {
+				if next.Location().Pos > start {
+					pos = next.Location().Pos
+					break
+				}
+			}
+
+			_, isBlock := n.(*ast.BlockStatement)
+			_, isComment := n.(*ast.CommentStatement)
+
+			w.mustache(source[start:pos], !isBlock && !isComment)
+		}
+	}
+
+	if pos < len(source) {
+		w.mustache(source[pos:], false)
+	}
+
+	return w.out.String(), nil
+}
+
+// statements appends the statements of given program and of its blocks to result, in source order
+func statements(program *ast.Program, result []ast.Node) []ast.Node {
+	for _, node := range program.Body {
+		result = append(result, node)
+
+		if block, ok := node.(*ast.BlockStatement); ok {
+			if block.Program != nil {
+				result = statements(block.Program, result)
+			}
+
+			if block.Inverse != nil {
+				result = statements(block.Inverse, result)
+			}
+		}
+	}
+
+	return result
+}
+
+// textState is the state of the HTML scanner of a textWriter
+type textState uint8
+
+const (
+	textContent textState = iota
+	textTag
+	textComment
+	textSkipped
+)
+
+// textWriter writes the plain-text version of HTML content
+type textWriter struct {
+	out strings.Builder
+
+	state textState
+
+	// source of current tag, and delimiter of current quoted attribute value
+	tag   strings.Builder
+	quote byte
+
+	// end tag of skipped element
+	skipEnd string
+
+	// depth of pre elements
+	pre int
+
+	// href of current link, and position of its text in output
+	href      string
+	linkStart int
+
+	// text was written, and whitespaces waiting to be written before next text
+	started  bool
+	space    bool
+	newlines int
+
+	// newlines ending output, not counting the mustaches that output nothing
+	trailing int
+
+	// last mustache is alone on its line, so the newline that follows it is removed by the parser
+	alone bool
+}
+
+// mustache writes the source of mustaches found in HTML content: as is in text, so they are rendered by the plain-text template, or in current tag. Pending whitespaces are written first, but only mustaches that output something start the text.
+func (w *textWriter) mustache(source string, output bool) {
+	switch w.state {
+	case textContent:
+		w.flush(output)
+
+		alone := (w.out.Len() == 0) || strings.HasSuffix(w.out.String(), "\n")
+
+		w.out.WriteString(source)
+
+		// standalone lines only have one mustache
+		w.alone = alone && (strings.Count(source, "{{") == 1)
+
+		if output {
+			w.trailing = 0
+		}
+
+	case textTag:
+		w.tag.WriteString(source)
+	}
+}
+
+// content converts given HTML content to text
+func (w *textWriter) content(s string) {
+	for len(s) > 0 {
+		switch w.state {
+		case textContent:
+			i := tagStart(s)
+			if i == -1 {
+				w.text(s)
+				return
+			}
+
+			w.text(s[:i])
+
+			if strings.HasPrefix(s[i:], "<!--") {
+				w.state = textComment
+				s = s[i+4:]
+			} else {
+				w.state = textTag
+				w.tag.Reset()
+				w.quote = 0
+				s = s[i+1:]
+			}
+
+		case textTag:
+			i := 0
+			for ; i < len(s); i++ {
+				c := s[i]
+
+				if w.quote != 0 {
+					if c == w.quote {
+						w.quote = 0
+					}
+				} else if (c == '"') || (c == '\'') {
+					w.quote = c
+				} else if c == '>' {
+					break
+				}
+			}
+
+			w.tag.WriteString(s[:i])
+
+			if i == len(s) {
+				return
+			}
+
+			w.state = textContent
+			w.endTag(w.tag.String())
+			s = s[i+1:]
+
+		case textComment:
+			i := strings.Index(s, "-->")
+			if i == -1 {
+				return
+			}
+
+			w.state = textContent
+			s = s[i+3:]
+
+		case textSkipped:
+			i := strings.Index(strings.ToLower(s), w.skipEnd)
+			if i == -1 {
+				return
+			}
+
+			w.state = textContent
+			s = s[i:]
+		}
+	}
+}
+
+// tagStart returns the index of the first tag or comment of given HTML content, or -1 if not found
+func tagStart(s string) int {
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] != '<' {
+			continue
+		}
+
+		if c := s[i+1]; (c == '/') || (c == '!') || (c == '?') || isASCIILetter(c) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// endTag handles the tag with given source, without its delimiters
+func (w *textWriter) endTag(source string) {
+	closing := strings.HasPrefix(source, "/")
+	if closing {
+		source = source[1:]
+	}
+
+	i := strings.IndexAny(source, " \t\n\f\r/")
+	if i == -1 {
+		i = len(source)
+	}
+
+	name, attrs := strings.ToLower(source[:i]), source[i:]
+
+	switch name {
+	case "br":
+		if w.started {
+			w.newlines = max(w.newlines, w.trailing) + 1
+		}
+
+	case "p", "h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "dl", "table", "blockquote", "hr":
+		w.block(2)
+
+	case "pre":
+		w.block(2)
+
+		if closing {
+			w.pre--
+		} else {
+			w.pre++
+		}
+
+	case "div", "center", "section", "article", "header", "footer", "address", "tr", "dt", "dd":
+		w.block(1)
+
+	case "li":
+		w.block(1)
+
+		if !closing {
+			w.write("- ")
+		}
+
+	case "td", "th":
+		w.space = true
+
+	case "script", "style", "head", "title":
+		if !closing && !strings.HasSuffix(attrs, "/") {
+			w.state = textSkipped
+			w.skipEnd = "</" + name
+		}
+
+	case "a":
+		if !closing {
+			w.href = html.UnescapeString(attribute(attrs, "href"))
+			w.linkStart = w.out.Len()
+		} else if w.href != "" {
+			w.link(strings.TrimSpace(w.out.String()[w.linkStart:]))
+			w.href = ""
+		}
+
+	case "img":
+		if alt := attribute(attrs, "alt"); alt != "" {
+			w.text(alt)
+		}
+	}
+}
+
+// link writes the URL of current link, that has given text
+func (w *textWriter) link(text string) {
+	if strings.HasPrefix(w.href, "#") || (text == w.href) || ("mailto:"+text == w.href) {
+		return
+	}
+
+	if text == "" {
+		w.flush(true)
+		w.out.WriteString(w.href)
+	} else {
+		w.out.WriteString(" (" + w.href + ")")
+	}
+
+	w.trailing = 0
+	w.alone = false
+}
+
+// block starts a block of text, separated from previous one by given number of newlines
+func (w *textWriter) block(newlines int) {
+	if w.started && (w.newlines < newlines) {
+		w.newlines = newlines
+	}
+
+	w.space = false
+}
+
+// text writes given HTML text
+func (w *textWriter) text(s string) {
+	if s == "" {
+		return
+	}
+
+	s = html.UnescapeString(s)
+
+	if w.pre > 0 {
+		w.write(s)
+		return
+	}
+
+	words := strings.FieldsFunc(s, isHTMLSpace)
+
+	if isHTMLSpace(rune(s[0])) {
+		w.space = true
+	}
+
+	if len(words) > 0 {
+		w.write(strings.ReplaceAll(strings.Join(words, " "), "\u00a0", " "))
+
+		if isHTMLSpace(rune(s[len(s)-1])) {
+			w.space = true
+		}
+	}
+}
+
+// write writes given text, after pending whitespaces, escaping mustaches
+func (w *textWriter) write(s string) {
+	w.flush(true)
+
+	if w.alone && strings.HasPrefix(strings.TrimLeft(s, " \t"), "\n") {
+		s = "\n" + s
+	}
+
+	w.out.WriteString(strings.ReplaceAll(s, "{{", `\{{`))
+
+	w.trailing = len(s) - len(strings.TrimRight(s, "\n"))
+	w.alone = false
+}
+
+// flush writes pending whitespaces, once text is started
+func (w *textWriter) flush(start bool) {
+	if w.started {
+		if n := w.newlines - w.trailing; n > 0 {
+			w.trailing += n
+
+			if w.alone {
+				n++
+				w.alone = false
+			}
+
+			w.out.WriteString(strings.Repeat("\n", n))
+		} else if (w.newlines == 0) && w.space && !strings.HasSuffix(w.out.String(), " ") {
+			w.out.WriteByte(' ')
+			w.trailing = 0
+			w.alone = false
+		}
+	}
+
+	if start {
+		w.started = true
+	}
+
+	w.space = false
+	w.newlines = 0
+}
+
+// attribute returns the value of the attribute with given name in given tag attributes source, or an empty string if not found
+func attribute(attrs string, name string) string {
+	for attrs != "" {
+		attrs = strings.TrimLeft(attrs, " \t\n\f\r/")
+
+		i := strings.IndexAny(attrs, " \t\n\f\r/=")
+		if i == -1 {
+			return ""
+		}
+
+		attrName := attrs[:i]
+		attrs = strings.TrimLeft(attrs[i:], " \t\n\f\r")
+
+		if !strings.HasPrefix(attrs, "=") {
+			continue
+		}
+		attrs = strings.TrimLeft(attrs[1:], " \t\n\f\r")
+
+		var value string
+
+		if (attrs != "") && ((attrs[0] == '"') || (attrs[0] == '\'')) {
+			end := strings.IndexByte(attrs[1:], attrs[0])
+			if end == -1 {
+				value, attrs = attrs[1:], ""
+			} else {
+				value, attrs = attrs[1:end+1], attrs[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(attrs, " \t\n\f\r")
+			if end == -1 {
+				end = len(attrs)
+			}
+
+			value, attrs = attrs[:end], attrs[end:]
+		}
+
+		if strings.EqualFold(attrName, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// isHTMLSpace returns true if given character is an HTML whitespace
+func isHTMLSpace(c rune) bool {
+	return (c == ' ') || (c == '\t') || (c == '\n') || (c == '\f') || (c == '\r')
+}
+
+// isASCIILetter returns true if given character is an ASCII letter
+func isASCIILetter(c byte) bool {
+	return ((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z'))
+}
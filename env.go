@@ -0,0 +1,69 @@
+package raymond
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvHelper returns a helper that reads environment variables, restricted to given allowlist.
+//
+// An allowlist entry ending with `*` allows all variables starting with that prefix. Reading a variable that is not allowed aborts template evaluation. When an allowed variable is not set, the `default` hash argument is returned.
+//
+// The helper is not registered by default, so that templates can't access the environment unless explicitly configured:
+//
+//	tpl.RegisterHelper("env", raymond.EnvHelper("APP_VERSION", "APP_*"))
+//
+// Then in template:
+//
+//	{{env "APP_VERSION"}} {{env "APP_REGION" default="eu-west-1"}}
+func EnvHelper(allowlist ...string) interface{} {
+	return newEnvHelper(os.LookupEnv, allowlist)
+}
+
+// MapEnvHelper returns a helper that reads given configuration values, restricted to given allowlist.
+//
+// It behaves exactly like EnvHelper(), but reads values from given map instead of environment variables.
+func MapEnvHelper(values map[string]string, allowlist ...string) interface{} {
+	// copy values so that caller can't change them afterward
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+
+	return newEnvHelper(func(name string) (string, bool) {
+		val, ok := copied[name]
+		return val, ok
+	}, allowlist)
+}
+
+// newEnvHelper instanciates an env helper with given lookup function and allowlist
+func newEnvHelper(lookup func(string) (string, bool), allowlist []string) interface{} {
+	allowed := append([]string(nil), allowlist...)
+
+	return func(name string, options *Options) interface{} {
+		if !envAllowed(allowed, name) {
+			options.eval.errorf("Access to variable %q is not allowed", name)
+		}
+
+		if val, ok := lookup(name); ok {
+			return val
+		}
+
+		return options.HashProp("default")
+	}
+}
+
+// envAllowed returns true if given variable name is allowed by allowlist
+func envAllowed(allowlist []string, name string) bool {
+	for _, pattern := range allowlist {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,89 @@
+package raymond
+
+import (
+	"os"
+	"testing"
+)
+
+var envConfig = map[string]string{
+	"APP_VERSION": "1.2.3",
+	"APP_REGION":  "eu-west-1",
+	"SECRET":      "p4ssw0rd",
+}
+
+var envTests = []Test{
+	{
+		"env helper",
+		`{{env "APP_VERSION"}}`,
+		nil, nil,
+		map[string]interface{}{"env": MapEnvHelper(envConfig, "APP_VERSION")},
+		nil,
+		`1.2.3`,
+	},
+	{
+		"env helper with prefix allowlist",
+		`{{env "APP_VERSION"}} {{env "APP_REGION"}}`,
+		nil, nil,
+		map[string]interface{}{"env": MapEnvHelper(envConfig, "APP_*")},
+		nil,
+		`1.2.3 eu-west-1`,
+	},
+	{
+		"env helper with default value",
+		`{{env "APP_MISSING" default="none"}}|{{env "APP_MISSING"}}`,
+		nil, nil,
+		map[string]interface{}{"env": MapEnvHelper(envConfig, "APP_*")},
+		nil,
+		`none|`,
+	},
+	{
+		"env helper with identifier param",
+		`{{#each names}}{{env this}} {{/each}}`,
+		map[string]interface{}{"names": []string{"APP_VERSION", "APP_REGION"}},
+		nil,
+		map[string]interface{}{"env": MapEnvHelper(envConfig, "APP_VERSION", "APP_REGION")},
+		nil,
+		`1.2.3 eu-west-1 `,
+	},
+}
+
+var envErrors = []Test{
+	{
+		"env helper with variable not in allowlist",
+		`{{env "SECRET"}}`,
+		nil, nil,
+		map[string]interface{}{"env": MapEnvHelper(envConfig, "APP_*")},
+		nil,
+		`Access to variable "SECRET" is not allowed`,
+	},
+	{
+		"env helper with empty allowlist",
+		`{{env "APP_VERSION"}}`,
+		nil, nil,
+		map[string]interface{}{"env": MapEnvHelper(envConfig)},
+		nil,
+		`Access to variable "APP_VERSION" is not allowed`,
+	},
+}
+
+func TestEnvHelper(t *testing.T) {
+	t.Parallel()
+
+	launchTests(t, envTests)
+}
+
+func TestEnvHelperErrors(t *testing.T) {
+	launchErrorTests(t, envErrors)
+}
+
+func TestEnvHelperEnvironment(t *testing.T) {
+	os.Setenv("RAYMOND_TEST_ENV", "foo")
+	defer os.Unsetenv("RAYMOND_TEST_ENV")
+
+	tpl := MustParse(`{{env "RAYMOND_TEST_ENV"}}`)
+	tpl.RegisterHelper("env", EnvHelper("RAYMOND_TEST_*"))
+
+	if output := tpl.MustExec(nil); output != "foo" {
+		t.Errorf("Failed to read environment variable: %q", output)
+	}
+}
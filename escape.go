@@ -1,7 +1,6 @@
 package raymond
 
 import (
-	"bytes"
 	"strings"
 )
 
@@ -9,57 +8,109 @@ import (
 // That whole file is borrowed from https://github.com/golang/go/tree/master/src/html/escape.go
 //
 // With changes:
-//    &#39 => &apos;
-//    &#34 => &quot;
-//
-// To stay in sync with JS implementation, and make mustache tests pass.
+//    the escaped characters and their replacements depend on the escaping set, to stay in sync with JS implementation by default
 //
 
 type writer interface {
 	WriteString(string) (int, error)
 }
 
-const escapedChars = `&'<>"`
+// Escaping is the set of characters escaped in HTML output.
+type Escaping int
+
+const (
+	// EscapeHandlebars escapes & < > " ' ` and = like handlebars.js does. This is the default.
+	EscapeHandlebars Escaping = iota
+
+	// EscapeGo escapes & < > " and ' like html.EscapeString() and text/template do.
+	EscapeGo
+
+	// EscapeNone escapes nothing, like the noEscape compile option of handlebars.js, for templates that do not output HTML, eg. plain-text emails.
+	EscapeNone
+)
+
+// escapeSet is a set of escaped characters, with their replacements
+type escapeSet struct {
+	chars string
+	repl  [256]string
+}
+
+// escapeSets are the sets of escaped characters, indexed by Escaping
+var escapeSets = [...]escapeSet{
+	EscapeHandlebars: {
+		chars: "&<>\"'`=",
+		repl: [256]string{
+			'&':  "&amp;",
+			'<':  "&lt;",
+			'>':  "&gt;",
+			'"':  "&quot;",
+			'\'': "&#x27;",
+			'`':  "&#x60;",
+			'=':  "&#x3D;",
+		},
+	},
+	EscapeGo: {
+		chars: "&<>\"'",
+		repl: [256]string{
+			'&':  "&amp;",
+			'<':  "&lt;",
+			'>':  "&gt;",
+			'"':  "&#34;",
+			'\'': "&#39;",
+		},
+	},
+	EscapeNone: {},
+}
+
+// set returns the escaped characters of that escaping, defaulting to handlebars.js ones
+func (e Escaping) set() *escapeSet {
+	if (e < 0) || (int(e) >= len(escapeSets)) {
+		e = EscapeHandlebars
+	}
+
+	return &escapeSets[e]
+}
+
+func escape(w writer, s string, escaping Escaping) error {
+	set := escaping.set()
 
-func escape(w writer, s string) error {
-	i := strings.IndexAny(s, escapedChars)
+	i := strings.IndexAny(s, set.chars)
 	for i != -1 {
 		if _, err := w.WriteString(s[:i]); err != nil {
 			return err
 		}
-		var esc string
-		switch s[i] {
-		case '&':
-			esc = "&amp;"
-		case '\'':
-			esc = "&apos;"
-		case '<':
-			esc = "&lt;"
-		case '>':
-			esc = "&gt;"
-		case '"':
-			esc = "&quot;"
-		default:
+		esc := set.repl[s[i]]
+		if esc == "" {
 			panic("unrecognized escape character")
 		}
 		s = s[i+1:]
 		if _, err := w.WriteString(esc); err != nil {
 			return err
 		}
-		i = strings.IndexAny(s, escapedChars)
+		i = strings.IndexAny(s, set.chars)
 	}
 	_, err := w.WriteString(s)
 	return err
 }
 
-// Escape escapes special HTML characters.
+// Escape escapes special HTML characters, like handlebars.js does.
 //
 // It can be used by helpers that return a SafeString and that need to escape some content by themselves.
 func Escape(s string) string {
-	if strings.IndexAny(s, escapedChars) == -1 {
+	return EscapeWith(s, EscapeHandlebars)
+}
+
+// EscapeWith escapes the special HTML characters of given escaping set.
+func EscapeWith(s string, escaping Escaping) string {
+	if strings.IndexAny(s, escaping.set().chars) == -1 {
 		return s
 	}
-	var buf bytes.Buffer
-	escape(&buf, s)
-	return buf.String()
+
+	// pooled scratch buffer
+	buf := getBuffer()
+	escape(buf, s, escaping)
+	result := buf.String()
+	buf.Release()
+
+	return result
 }
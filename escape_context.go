@@ -0,0 +1,859 @@
+package raymond
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// invalidValue replaces mustache values that can't be safely output in their context
+const invalidValue = "ZraymondZ"
+
+// valueEscaping is the escaping applied to a mustache value, depending on the language it is embedded in
+type valueEscaping uint8
+
+const (
+	// HTML text, only escaped by the outer escaping
+	valueText valueEscaping = iota
+
+	// attribute name in a tag
+	valueAttrName
+
+	// start of an URL, whose scheme is checked
+	valueURL
+
+	// URL path, after its start
+	valueURLPath
+
+	// URL query or fragment
+	valueURLQuery
+
+	// JS expression
+	valueJS
+
+	// content of a JS string, template literal or comment
+	valueJSString
+
+	// CSS
+	valueCSS
+)
+
+// outerEscaping is the escaping applied to a mustache value after its value escaping, depending on where the embedded language sits in the HTML document
+type outerEscaping uint8
+
+const (
+	// HTML text, or quoted attribute value
+	outerHTML outerEscaping = iota
+
+	// unquoted attribute value
+	outerUnquoted
+
+	// raw text of a script or style element
+	outerRaw
+)
+
+// escaper describes how a mustache value is escaped
+type escaper struct {
+	value valueEscaping
+	outer outerEscaping
+}
+
+// htmlEscaper is the default escaper, for mustaches in HTML text
+var htmlEscaper = escaper{valueText, outerHTML}
+
+// htmlState is the state of the HTML scanner
+type htmlState uint8
+
+const (
+	htmlText htmlState = iota
+	htmlTagName
+	htmlTag
+	htmlAttrName
+	htmlAfterAttrName
+	htmlBeforeAttrValue
+	htmlAttrValue
+	htmlComment
+
+	// raw text of a script element
+	htmlScript
+
+	// raw text of a style element
+	htmlStyle
+
+	// text of title and textarea elements, that can't contain tags
+	htmlRCDATA
+)
+
+// attrKind is the language of an attribute value
+type attrKind uint8
+
+const (
+	attrText attrKind = iota
+	attrURL
+	attrJS
+	attrCSS
+)
+
+// jsState is the state of the JS scanner
+type jsState uint8
+
+const (
+	jsCode jsState = iota
+	jsDoubleQuote
+	jsSingleQuote
+	jsTemplate
+	jsLineComment
+	jsBlockComment
+)
+
+// urlPart is the part of an URL being scanned
+type urlPart uint8
+
+const (
+	urlStart urlPart = iota
+	urlPath
+	urlQuery
+)
+
+// urlAttrs are the attributes whose value is an URL
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"src":        true,
+	"srcset":     true,
+	"usemap":     true,
+	"xlink:href": true,
+}
+
+// htmlContext is the context of the HTML scanner, at a given position of a template
+type htmlContext struct {
+	state htmlState
+
+	// name of current tag, and of current attribute
+	tag      string
+	attrName string
+	endTag   bool
+
+	// current attribute value, delim is 0 if unquoted
+	attr  attrKind
+	delim byte
+
+	// position in a script element or a JS attribute value
+	js jsState
+
+	// position in an URL attribute value
+	url urlPart
+
+	// element which raw text is being scanned
+	element string
+}
+
+// errPartialEnd is returned when a partial does not end in HTML text, with contextual escaping
+var errPartialEnd = errors.New("Partial must end in HTML text with contextual escaping")
+
+// escapingAnalysis is the result of the contextual escaping analysis of a program
+type escapingAnalysis struct {
+	// escapers of the mustache statements
+	escapers map[*ast.MustacheStatement]escaper
+
+	// first partial statement that is not in HTML text
+	partial *ast.PartialStatement
+
+	// context at the end of program
+	end htmlContext
+
+	// first error found, when a block or a mustache can end in several contexts
+	err error
+}
+
+// singlePassHelpers are the block helpers that render their program at most once
+var singlePassHelpers = map[string]bool{"if": true, "unless": true, "with": true}
+
+// maxBlockPasses is the maximum number of times the program of a block is scanned, before its context converges
+const maxBlockPasses = 8
+
+// escaping returns the contextual escaping analysis of template, computed on first call
+func (p *compiledPrograms) escaping() *escapingAnalysis {
+	p.escapingOnce.Do(func() {
+		p.escapingResult = analyzeEscaping(p.root)
+	})
+
+	return p.escapingResult
+}
+
+// escapers returns the escapers of the mustache statements of template
+func (p *compiledPrograms) escapers() map[*ast.MustacheStatement]escaper {
+	return p.escaping().escapers
+}
+
+// checkEscaping returns an error if template calls a partial outside of HTML text, as partials are analyzed as if they start in HTML text. When template is a partial, it must also end in HTML text, so that statements following the partial call are escaped in the right context.
+func (p *compiledPrograms) checkEscaping(partial bool) error {
+	analysis := p.escaping()
+
+	if analysis.err != nil {
+		return analysis.err
+	}
+
+	if analysis.partial != nil {
+		return fmt.Errorf("Partial can't be called outside of HTML text with contextual escaping, line %d", analysis.partial.Line)
+	}
+
+	if partial && (analysis.end.state != htmlText) {
+		return errPartialEnd
+	}
+
+	return nil
+}
+
+// escaper returns the escaper of given mustache statement
+func (p *compiledPrograms) escaper(node *ast.MustacheStatement) escaper {
+	if p == nil {
+		return htmlEscaper
+	}
+
+	if esc, ok := p.escapers()[node]; ok {
+		return esc
+	}
+
+	return htmlEscaper
+}
+
+// analyzeEscaping scans the content of given program, and returns the escapers of its mustache statements
+//
+// The program is expected to start in HTML text, and the branches of a block to end in the same context.
+func analyzeEscaping(program *ast.Program) *escapingAnalysis {
+	result := &escapingAnalysis{escapers: make(map[*ast.MustacheStatement]escaper)}
+	result.end = result.analyzeProgram(program, htmlContext{})
+
+	return result
+}
+
+// analyzeProgram scans given program from given context, and returns the context at its end
+func (a *escapingAnalysis) analyzeProgram(program *ast.Program, c htmlContext) htmlContext {
+	if program == nil {
+		return c
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			c = c.scan(n.Value)
+		case *ast.MustacheStatement:
+			esc := c.escaper()
+			if prev, ok := a.escapers[n]; ok && (prev != esc) {
+				a.fail(fmt.Errorf("Mustache is output in different contexts with contextual escaping, line %d", n.Line))
+			}
+
+			a.escapers[n] = esc
+			c = c.afterValue()
+		case *ast.PartialStatement:
+			if (c.state != htmlText) && (a.partial == nil) {
+				a.partial = n
+			}
+		case *ast.BlockStatement:
+			c = a.analyzeBlock(n, c)
+		}
+	}
+
+	return c
+}
+
+// analyzeBlock scans given block from given context, and returns the context after it
+//
+// The program and the inverse may not be rendered at all, so they must both end in the context the block starts in when the other one is missing. Block helpers other than the conditional ones may also render their program several times, so the program is scanned again from its end context until that context converges.
+func (a *escapingAnalysis) analyzeBlock(block *ast.BlockStatement, c htmlContext) htmlContext {
+	name := block.Expression.HelperName()
+
+	end := a.analyzeProgram(block.Program, c)
+	if end.normalized() != a.analyzeProgram(block.Inverse, c).normalized() {
+		a.fail(fmt.Errorf("Branches of block '%s' end in different contexts with contextual escaping, line %d", name, block.Line))
+		return end
+	}
+
+	if (block.Program == nil) || singlePassHelpers[name] {
+		return end
+	}
+
+	for pass := 1; end.normalized() != c.normalized(); pass++ {
+		if pass == maxBlockPasses {
+			a.fail(fmt.Errorf("Block '%s' does not end in a stable context when repeated with contextual escaping, line %d", name, block.Line))
+			return end
+		}
+
+		next := a.analyzeProgram(block.Program, end)
+		if next.normalized() == end.normalized() {
+			break
+		}
+
+		end = next
+	}
+
+	return end
+}
+
+// fail records given error, if no error was found yet
+func (a *escapingAnalysis) fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// normalized returns the context without the fields that are not relevant in its state, so that contexts can be compared
+func (c htmlContext) normalized() htmlContext {
+	switch c.state {
+	case htmlText, htmlComment:
+		return htmlContext{state: c.state}
+	case htmlTagName, htmlTag:
+		return htmlContext{state: c.state, tag: c.tag, endTag: c.endTag}
+	case htmlAttrName, htmlAfterAttrName, htmlBeforeAttrValue:
+		return htmlContext{state: c.state, tag: c.tag, endTag: c.endTag, attrName: c.attrName}
+	case htmlAttrValue:
+		result := htmlContext{state: c.state, tag: c.tag, endTag: c.endTag, attr: c.attr, delim: c.delim}
+
+		switch c.attr {
+		case attrJS:
+			result.js = c.js
+		case attrURL:
+			result.url = c.url
+		}
+
+		return result
+	case htmlScript:
+		return htmlContext{state: c.state, element: c.element, js: c.js}
+	default:
+		return htmlContext{state: c.state, element: c.element}
+	}
+}
+
+// scan returns the context after given content
+func (c htmlContext) scan(s string) htmlContext {
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		switch c.state {
+		case htmlText:
+			next := strings.IndexByte(s[i:], '<')
+			if next == -1 {
+				return c
+			}
+
+			i += next
+			rest := s[i+1:]
+
+			switch {
+			case strings.HasPrefix(rest, "!--"):
+				c.state = htmlComment
+				i += 3
+			case (len(rest) > 1) && (rest[0] == '/') && isASCIILetter(rest[1]):
+				c = htmlContext{state: htmlTagName, endTag: true}
+				i++
+			case (len(rest) > 0) && isASCIILetter(rest[0]):
+				c = htmlContext{state: htmlTagName}
+			}
+		case htmlComment:
+			end := strings.Index(s[i:], "-->")
+			if end == -1 {
+				return c
+			}
+
+			c.state = htmlText
+			i += end + 2
+		case htmlTagName:
+			switch {
+			case ch == '>':
+				c = c.endOfTag()
+			case isHTMLSpace(ch) || (ch == '/'):
+				c.state = htmlTag
+			default:
+				c.tag += string(toASCIILower(ch))
+			}
+		case htmlTag, htmlAfterAttrName:
+			switch {
+			case ch == '>':
+				c = c.endOfTag()
+			case (ch == '=') && (c.state == htmlAfterAttrName):
+				c.state = htmlBeforeAttrValue
+			case isHTMLSpace(ch) || (ch == '/'):
+			default:
+				c.state = htmlAttrName
+				c.attrName = string(toASCIILower(ch))
+			}
+		case htmlAttrName:
+			switch {
+			case ch == '>':
+				c = c.endOfTag()
+			case ch == '=':
+				c.state = htmlBeforeAttrValue
+			case isHTMLSpace(ch) || (ch == '/'):
+				c.state = htmlAfterAttrName
+			default:
+				c.attrName += string(toASCIILower(ch))
+			}
+		case htmlBeforeAttrValue:
+			switch {
+			case ch == '>':
+				c = c.endOfTag()
+			case isHTMLSpace(ch):
+			case (ch == '"') || (ch == '\''):
+				c = c.startAttrValue(ch)
+			default:
+				// that character is part of unquoted value
+				c = c.startAttrValue(0)
+				i--
+			}
+		case htmlAttrValue:
+			switch {
+			case (c.delim != 0) && (ch == c.delim):
+				c.state = htmlTag
+			case (c.delim == 0) && isHTMLSpace(ch):
+				c.state = htmlTag
+			case (c.delim == 0) && (ch == '>'):
+				c = c.endOfTag()
+			default:
+				switch c.attr {
+				case attrJS:
+					c.js, i = c.js.scan(s, i)
+				case attrURL:
+					c.url = c.url.scan(ch)
+				}
+			}
+		case htmlScript, htmlStyle, htmlRCDATA:
+			if (ch == '<') && isEndTag(s[i:], c.element) {
+				c = htmlContext{state: htmlTagName, endTag: true}
+				i++
+			} else if c.state == htmlScript {
+				c.js, i = c.js.scan(s, i)
+			}
+		}
+	}
+
+	return c
+}
+
+// endOfTag returns the context after the end of current tag
+func (c htmlContext) endOfTag() htmlContext {
+	if c.endTag {
+		return htmlContext{}
+	}
+
+	switch c.tag {
+	case "script":
+		return htmlContext{state: htmlScript, element: c.tag}
+	case "style":
+		return htmlContext{state: htmlStyle, element: c.tag}
+	case "title", "textarea":
+		return htmlContext{state: htmlRCDATA, element: c.tag}
+	default:
+		return htmlContext{}
+	}
+}
+
+// startAttrValue returns the context at the start of the value of current attribute, delimited by given quote
+func (c htmlContext) startAttrValue(delim byte) htmlContext {
+	c.state = htmlAttrValue
+	c.delim = delim
+	c.attr = attrKindOf(c.attrName)
+	c.js = jsCode
+	c.url = urlStart
+
+	return c
+}
+
+// afterValue returns the context after a mustache value
+func (c htmlContext) afterValue() htmlContext {
+	if c.state == htmlBeforeAttrValue {
+		c = c.startAttrValue(0)
+	}
+
+	if (c.state == htmlAttrValue) && (c.url == urlStart) {
+		c.url = urlPath
+	}
+
+	return c
+}
+
+// escaper returns the escaper of a mustache value in that context
+func (c htmlContext) escaper() escaper {
+	switch c.state {
+	case htmlTagName, htmlTag, htmlAttrName, htmlAfterAttrName:
+		return escaper{valueAttrName, outerHTML}
+	case htmlBeforeAttrValue:
+		return c.startAttrValue(0).escaper()
+	case htmlAttrValue:
+		result := escaper{valueText, outerHTML}
+		if c.delim == 0 {
+			result.outer = outerUnquoted
+		}
+
+		switch c.attr {
+		case attrURL:
+			result.value = c.url.escaping()
+		case attrJS:
+			result.value = c.js.escaping()
+		case attrCSS:
+			result.value = valueCSS
+		}
+
+		return result
+	case htmlScript:
+		return escaper{c.js.escaping(), outerRaw}
+	case htmlStyle:
+		return escaper{valueCSS, outerRaw}
+	default:
+		return htmlEscaper
+	}
+}
+
+// scan returns the JS state after character at given index of given string, and the index of last scanned character
+func (js jsState) scan(s string, i int) (jsState, int) {
+	ch := s[i]
+	next := byte(0)
+	if i+1 < len(s) {
+		next = s[i+1]
+	}
+
+	switch js {
+	case jsCode:
+		switch {
+		case ch == '"':
+			return jsDoubleQuote, i
+		case ch == '\'':
+			return jsSingleQuote, i
+		case ch == '`':
+			return jsTemplate, i
+		case (ch == '/') && (next == '/'):
+			return jsLineComment, i + 1
+		case (ch == '/') && (next == '*'):
+			return jsBlockComment, i + 1
+		}
+	case jsDoubleQuote, jsSingleQuote, jsTemplate:
+		switch {
+		case ch == '\\':
+			return js, i + 1
+		case (ch == '"') && (js == jsDoubleQuote), (ch == '\'') && (js == jsSingleQuote), (ch == '`') && (js == jsTemplate):
+			return jsCode, i
+		}
+	case jsLineComment:
+		if ch == '\n' {
+			return jsCode, i
+		}
+	case jsBlockComment:
+		if (ch == '*') && (next == '/') {
+			return jsCode, i + 1
+		}
+	}
+
+	return js, i
+}
+
+// escaping returns the escaping of a mustache value in that JS state
+func (js jsState) escaping() valueEscaping {
+	if js == jsCode {
+		return valueJS
+	}
+
+	return valueJSString
+}
+
+// scan returns the URL part after given character
+func (u urlPart) scan(ch byte) urlPart {
+	switch {
+	case (ch == '?') || (ch == '#'):
+		return urlQuery
+	case u == urlStart:
+		return urlPath
+	default:
+		return u
+	}
+}
+
+// escaping returns the escaping of a mustache value in that URL part
+func (u urlPart) escaping() valueEscaping {
+	switch u {
+	case urlStart:
+		return valueURL
+	case urlPath:
+		return valueURLPath
+	default:
+		return valueURLQuery
+	}
+}
+
+// attrKindOf returns the language of the value of given attribute
+func attrKindOf(name string) attrKind {
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case urlAttrs[name] || strings.Contains(name, "url") || strings.Contains(name, "uri"):
+		return attrURL
+	default:
+		return attrText
+	}
+}
+
+// isEndTag returns true if given string starts with the end tag of given element, case insensitively
+func isEndTag(s string, element string) bool {
+	if (len(s) < len(element)+3) || (s[1] != '/') || !strings.EqualFold(s[2:2+len(element)], element) {
+		return false
+	}
+
+	ch := s[2+len(element)]
+
+	return isHTMLSpace(ch) || (ch == '>') || (ch == '/')
+}
+
+func isHTMLSpace(ch byte) bool {
+	return (ch == ' ') || (ch == '\t') || (ch == '\n') || (ch == '\f') || (ch == '\r')
+}
+
+func isASCIILetter(ch byte) bool {
+	return (('a' <= ch) && (ch <= 'z')) || (('A' <= ch) && (ch <= 'Z'))
+}
+
+func toASCIILower(ch byte) byte {
+	if ('A' <= ch) && (ch <= 'Z') {
+		return ch + 'a' - 'A'
+	}
+
+	return ch
+}
+
+//
+// Escapers
+//
+
+// escape returns the escaped string representation of given mustache value
+//
+// Safe strings are trusted in HTML text and in attribute names, but not in URL, JS and CSS. The special HTML characters are those of given escaping set.
+func (e escaper) escape(val interface{}, escaping Escaping) string {
+	var str string
+
+	switch e.value {
+	case valueText, valueAttrName:
+		if isSafeString(val) {
+			return Str(val)
+		}
+
+		str = Str(val)
+		if e.value == valueAttrName {
+			str = filterAttrName(str)
+		}
+	case valueURL:
+		str = normalizeURL(filterURL(Str(val)))
+	case valueURLPath:
+		str = normalizeURL(Str(val))
+	case valueURLQuery:
+		str = escapeURLQuery(Str(val))
+	case valueJS:
+		str = jsValue(val)
+	case valueJSString:
+		str = escapeJSString(Str(val))
+	case valueCSS:
+		str = escapeCSS(Str(val))
+	}
+
+	switch e.outer {
+	case outerHTML:
+		return EscapeWith(str, escaping)
+	case outerUnquoted:
+		return unquotedAttrReplacer.Replace(str)
+	default:
+		return str
+	}
+}
+
+// unquotedAttrReplacer escapes the characters that end an unquoted attribute value, in addition to special HTML characters
+var unquotedAttrReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"'", "&apos;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	" ", "&#32;",
+	"\t", "&#9;",
+	"\n", "&#10;",
+	"\f", "&#12;",
+	"\r", "&#13;",
+	"=", "&#61;",
+	"`", "&#96;",
+)
+
+// filterAttrName returns given attribute name, or invalidValue if it is not a plain text attribute name
+func filterAttrName(name string) string {
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if !isASCIILetter(ch) && !(('0' <= ch) && (ch <= '9')) && (ch != '-') && (ch != '_') && (ch != ':') {
+			return invalidValue
+		}
+	}
+
+	if attrKindOf(strings.ToLower(name)) != attrText {
+		return invalidValue
+	}
+
+	return name
+}
+
+// filterURL returns given URL, or a harmless URL if its scheme is not http, https or mailto
+func filterURL(url string) string {
+	// browsers ignore leading spaces, and tabs and newlines anywhere
+	stripped := strings.Map(func(r rune) rune {
+		if (r == '\t') || (r == '\n') || (r == '\r') {
+			return -1
+		}
+		return r
+	}, strings.TrimLeft(url, "\x00\t\n\f\r "))
+
+	if i := strings.IndexAny(stripped, ":/?#"); (i != -1) && (stripped[i] == ':') {
+		switch strings.ToLower(stripped[:i]) {
+		case "http", "https", "mailto":
+		default:
+			return "#" + invalidValue
+		}
+	}
+
+	return url
+}
+
+// normalizeURL percent-encodes the characters of given URL that are neither reserved nor unreserved
+func normalizeURL(url string) string {
+	return percentEncode(url, func(ch byte) bool {
+		return isURLUnreserved(ch) || strings.IndexByte("!#$%&'()*+,/:;=?@[]", ch) != -1
+	})
+}
+
+// escapeURLQuery percent-encodes all characters of given string that are not unreserved
+func escapeURLQuery(str string) string {
+	return percentEncode(str, isURLUnreserved)
+}
+
+func isURLUnreserved(ch byte) bool {
+	return isASCIILetter(ch) || (('0' <= ch) && (ch <= '9')) || (ch == '-') || (ch == '.') || (ch == '_') || (ch == '~')
+}
+
+// percentEncode percent-encodes the bytes of given string that are not kept
+func percentEncode(str string, keep func(byte) bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		if keep(str[i]) {
+			if b.Len() > 0 {
+				b.WriteByte(str[i])
+			}
+			continue
+		}
+
+		if b.Len() == 0 {
+			b.Grow(len(str) + 16)
+			b.WriteString(str[:i])
+		}
+
+		fmt.Fprintf(&b, "%%%02X", str[i])
+	}
+
+	if b.Len() == 0 {
+		return str
+	}
+
+	return b.String()
+}
+
+// jsValue returns the JSON representation of given value, surrounded by spaces so that it can't be merged with adjacent JS tokens
+func jsValue(val interface{}) string {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return " null "
+	}
+
+	// json.Marshal() escapes <, > and &, and line and paragraph separators
+	return " " + string(data) + " "
+}
+
+// escapeJSString escapes given string to be embedded in a JS string, template literal or comment
+func escapeJSString(str string) string {
+	var b strings.Builder
+
+	for i, r := range str {
+		var esc string
+
+		switch r {
+		case '\\':
+			esc = `\\`
+		case '\n':
+			esc = `\n`
+		case '\r':
+			esc = `\r`
+		case '\t':
+			esc = `\t`
+		case '"', '\'', '`', '<', '>', '&', '=', '$', '/', '\u2028', '\u2029':
+			esc = fmt.Sprintf(`\u%04x`, r)
+		default:
+			if (r < 0x20) || (r == utf8.RuneError) {
+				esc = fmt.Sprintf(`\u%04x`, r)
+			}
+		}
+
+		if esc == "" {
+			if b.Len() > 0 {
+				b.WriteRune(r)
+			}
+			continue
+		}
+
+		if b.Len() == 0 {
+			b.Grow(len(str) + 16)
+			b.WriteString(str[:i])
+		}
+
+		b.WriteString(esc)
+	}
+
+	if b.Len() == 0 {
+		return str
+	}
+
+	return b.String()
+}
+
+// escapeCSS escapes the characters of given string that can end a CSS value, a declaration or a rule, or start a function call
+func escapeCSS(str string) string {
+	var b strings.Builder
+
+	for i, r := range str {
+		if (r >= 0x20) && (r != utf8.RuneError) && !strings.ContainsRune("\"&'()+/:;<>\\{}", r) {
+			if b.Len() > 0 {
+				b.WriteRune(r)
+			}
+			continue
+		}
+
+		if b.Len() == 0 {
+			b.Grow(len(str) + 16)
+			b.WriteString(str[:i])
+		}
+
+		// the space terminates the hexadecimal escape sequence
+		fmt.Fprintf(&b, `\%x `, r)
+	}
+
+	if b.Len() == 0 {
+		return str
+	}
+
+	return b.String()
+}
@@ -0,0 +1,240 @@
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+var contextualEscapingTests = []struct {
+	name   string
+	input  string
+	data   interface{}
+	output string
+}{
+	{
+		"HTML text",
+		`<p>{{a}}</p>`,
+		map[string]string{"a": `<b>"x"</b>`},
+		`<p>&lt;b&gt;&quot;x&quot;&lt;/b&gt;</p>`,
+	},
+	{
+		"quoted attribute",
+		`<p title="{{a}}" class='{{a}}'>`,
+		map[string]string{"a": `" onclick='x'`},
+		`<p title="&quot; onclick&#x3D;&#x27;x&#x27;" class='&quot; onclick&#x3D;&#x27;x&#x27;'>`,
+	},
+	{
+		"unquoted attribute",
+		`<p title={{a}}>{{a}}</p>`,
+		map[string]string{"a": "a onclick=x"},
+		`<p title=a&#32;onclick&#61;x>a onclick&#x3D;x</p>`,
+	},
+	{
+		"attribute name",
+		`<input {{a}} {{b}} {{c}}>`,
+		map[string]interface{}{"a": "checked", "b": "onclick", "c": SafeString(`type="text"`)},
+		`<input checked ZraymondZ type="text">`,
+	},
+	{
+		"URL scheme",
+		`<a href="{{a}}">{{a}}</a><a href="{{b}}"></a><a href="{{c}}"></a>`,
+		map[string]string{"a": "javascript:alert(1)", "b": "https://example.com/a b?c=d", "c": " Java\tScript:x"},
+		`<a href="#ZraymondZ">javascript:alert(1)</a><a href="https://example.com/a%20b?c&#x3D;d"></a><a href="#ZraymondZ"></a>`,
+	},
+	{
+		"URL path and query",
+		`<a href="/users/{{a}}?q={{a}}#{{a}}"></a><img src=/{{a}}>`,
+		map[string]string{"a": "a&b/c d"},
+		`<a href="/users/a&amp;b/c%20d?q=a%26b%2Fc%20d#a%26b%2Fc%20d"></a><img src=/a&amp;b/c%20d>`,
+	},
+	{
+		"safe string in URL",
+		`<a href="{{a}}"></a>`,
+		map[string]interface{}{"a": SafeString("javascript:alert(1)")},
+		`<a href="#ZraymondZ"></a>`,
+	},
+	{
+		"script values",
+		`<script>var a = {{a}}, b = {{b}}, c = {{c}}, d = {{d}};</script>{{a}}`,
+		map[string]interface{}{"a": "</script><x>", "b": 12, "c": []string{"x"}, "d": nil},
+		`<script>var a =  "\u003c/script\u003e\u003cx\u003e" , b =  12 , c =  ["x"] , d =  null ;</script>&lt;/script&gt;&lt;x&gt;`,
+	},
+	{
+		"script strings",
+		`<script>var a = "{{a}}", b = '{{a}}'; // {{a}}
+var c = ` + "`{{a}}`" + `; /* {{a}} */ var d = {{b}};</script>`,
+		map[string]interface{}{"a": "'\"\n\\</script>", "b": true},
+		`<script>var a = "\u0027\u0022\n\\\u003c\u002fscript\u003e", b = '\u0027\u0022\n\\\u003c\u002fscript\u003e'; // \u0027\u0022\n\\\u003c\u002fscript\u003e
+var c = ` + "`\\u0027\\u0022\\n\\\\\\u003c\\u002fscript\\u003e`" + `; /* \u0027\u0022\n\\\u003c\u002fscript\u003e */ var d =  true ;</script>`,
+	},
+	{
+		"event handler attribute",
+		`<button onclick="go({{a}}, '{{a}}')">`,
+		map[string]string{"a": "x'y"},
+		`<button onclick="go( &quot;x&#x27;y&quot; , 'x\u0027y')">`,
+	},
+	{
+		"style",
+		`<style>p { color: {{a}}; }</style><p style="color: {{a}}">`,
+		map[string]string{"a": "red; } body { x: url(y)"},
+		`<style>p { color: red\3b  \7d  body \7b  x\3a  url\28 y\29 ; }</style><p style="color: red\3b  \7d  body \7b  x\3a  url\28 y\29 ">`,
+	},
+	{
+		"end of raw text",
+		`<SCRIPT type="text/javascript">x = "</script>"</Script ><p title={{a}}>{{a}}`,
+		map[string]string{"a": "a b"},
+		`<SCRIPT type="text/javascript">x = "</script>"</Script ><p title=a&#32;b>a b`,
+	},
+	{
+		"RCDATA and comments",
+		`<title>{{a}}</title><!-- <a href="{{a}}"> -->{{a}}`,
+		map[string]string{"a": "<x>"},
+		`<title>&lt;x&gt;</title><!-- <a href="&lt;x&gt;"> -->&lt;x&gt;`,
+	},
+	{
+		"blocks",
+		`<a {{#if b}}href="{{a}}"{{else}}title="{{a}}"{{/if}} class={{a}}>{{#each l}}<img src="{{this}}">{{/each}}`,
+		map[string]interface{}{"a": "javascript:x", "b": true, "l": []string{"javascript:y", "/y"}},
+		`<a href="#ZraymondZ" class=javascript:x><img src="#ZraymondZ"><img src="/y">`,
+	},
+	{
+		"unescaped",
+		`<script>var a = {{{a}}};</script><a href="{{{a}}}">`,
+		map[string]string{"a": "javascript:x"},
+		`<script>var a = javascript:x;</script><a href="javascript:x">`,
+	},
+}
+
+func TestContextualEscaping(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range contextualEscapingTests {
+		tpl, err := ParseWithOptions(test.input, ParseOptions{ContextualEscaping: true})
+		if err != nil {
+			t.Errorf("Test '%s' failed - Failed to parse template: %s", test.name, err)
+			continue
+		}
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%s\nexpected:\n\t%s\ngot:\n\t%s", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestContextualEscapingDisabled(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`<a href="{{a}}">`)
+
+	if output := tpl.MustExec(map[string]string{"a": "javascript:x"}); output != `<a href="javascript:x">` {
+		t.Errorf("Unexpected output without contextual escaping: %q", output)
+	}
+}
+
+func TestContextualEscapingPartials(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions(`<a href="{{a}}">{{> link}}</a>`, ParseOptions{ContextualEscaping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterPartial("link", `<a href="{{a}}">`)
+
+	ctx := map[string]string{"a": "javascript:x"}
+
+	if output := tpl.MustExec(ctx); output != `<a href="#ZraymondZ"><a href="#ZraymondZ"></a>` {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// clones and templates sharing the same partial source
+	if output := tpl.Clone().MustExec(ctx); output != `<a href="#ZraymondZ"><a href="#ZraymondZ"></a>` {
+		t.Errorf("Unexpected output of clone: %q", output)
+	}
+
+	other := MustParse(`{{> link}}`)
+	other.RegisterPartial("link", `<a href="{{a}}">`)
+
+	if output := other.MustExec(ctx); output != `<a href="javascript:x">` {
+		t.Errorf("Unexpected output without contextual escaping: %q", output)
+	}
+}
+
+func TestContextualEscapingPartialsOutsideText(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range []string{
+		`<a href="{{> q}}">`,
+		`<a {{> q}}>`,
+		`<script>var a = {{> q}};</script>`,
+		`<a href="{{#if ok}}{{> q}}{{/if}}">`,
+	} {
+		if _, err := ParseWithOptions(source, ParseOptions{ContextualEscaping: true}); (err == nil) || !strings.Contains(err.Error(), "Partial can't be called outside of HTML text") {
+			t.Errorf("Expected partial error for %q, got: %v", source, err)
+		}
+	}
+
+	// nested partial
+	tpl, err := ParseWithOptions(`{{> link}}`, ParseOptions{ContextualEscaping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterPartials(map[string]string{"link": `<a href="{{> q}}">`, "q": `{{x}}`})
+
+	if _, err := tpl.Exec(map[string]string{"x": "javascript:alert(1)"}); (err == nil) || !strings.Contains(err.Error(), "Partial can't be called outside of HTML text") {
+		t.Errorf("Expected nested partial error, got: %v", err)
+	}
+}
+
+func TestContextualEscapingPartialsEnd(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions(`{{> open}}{{x}}">`, ParseOptions{ContextualEscaping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterPartial("open", `<a href="`)
+
+	if _, err := tpl.Exec(map[string]string{"x": "javascript:alert(1)"}); (err == nil) || !strings.Contains(err.Error(), "Partial must end in HTML text") {
+		t.Errorf("Expected partial end error, got: %v", err)
+	}
+}
+
+func TestContextualEscapingBlockContexts(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		source  string
+		options ParseOptions
+		err     string
+	}{
+		{`{{#if a}}{{else}}<script>{{/if}}var x = {{u}};`, ParseOptions{}, "Branches of block 'if' end in different contexts"},
+		{`{{#each l}}{{this}}"><a href="{{/each}}">`, ParseOptions{}, "Branches of block 'each' end in different contexts"},
+		{`{{#if a}}<p title="{{else}}<p {{/if}}{{u}}">`, ParseOptions{Escaping: EscapeGo}, "Branches of block 'if' end in different contexts"},
+		{`{{#each l}}{{this}}"><a href="{{else}}<a href="{{/each}}">`, ParseOptions{}, "Mustache is output in different contexts"},
+		{`<a href="{{#each l}}{{this}}{{else}}/{{/each}}">`, ParseOptions{}, "Mustache is output in different contexts"},
+		{`{{#each l}}<a{{else}}<a{{/each}}>`, ParseOptions{}, "does not end in a stable context"},
+	} {
+		test.options.ContextualEscaping = true
+
+		if _, err := ParseWithOptions(test.source, test.options); (err == nil) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected error %q for %q, got: %v", test.err, test.source, err)
+		}
+	}
+
+	// branches ending in the same context
+	tpl, err := ParseWithOptions(`{{#if a}}<p title="{{else}}<p class="{{/if}}{{u}}">{{#each l}}<a href="{{this}}">{{/each}}`, ParseOptions{ContextualEscaping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := tpl.MustExec(map[string]interface{}{"a": false, "u": `" onmouseover="x`, "l": []string{"javascript:x"}})
+	if output != `<p class="&quot; onmouseover&#x3D;&quot;x"><a href="#ZraymondZ">` {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
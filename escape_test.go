@@ -1,6 +1,9 @@
 package raymond
 
-import "fmt"
+import (
+	"fmt"
+	"testing"
+)
 
 func ExampleEscape() {
 	tpl := MustParse("{{link url text}}")
@@ -18,3 +21,41 @@ func ExampleEscape() {
 	fmt.Print(result)
 	// Output: <a href='http://www.aymerick.com/'>This is a &lt;em&gt;cool&lt;/em&gt; website</a>
 }
+
+func ExampleEscapeWith() {
+	fmt.Println(EscapeWith(`<a href="x">'=`+"`", EscapeHandlebars))
+	fmt.Println(EscapeWith(`<a href="x">'=`+"`", EscapeGo))
+	// Output: &lt;a href&#x3D;&quot;x&quot;&gt;&#x27;&#x3D;&#x60;
+	// &lt;a href=&#34;x&#34;&gt;&#39;=`
+}
+
+func TestEscaping(t *testing.T) {
+	t.Parallel()
+
+	ctx := map[string]string{"foo": "&<>\"'`="}
+
+	tests := []struct {
+		opts   ParseOptions
+		output string
+	}{
+		{ParseOptions{}, "&amp;&lt;&gt;&quot;&#x27;&#x60;&#x3D; <p title=\"&amp;&lt;&gt;&quot;&#x27;&#x60;&#x3D;\">"},
+		{ParseOptions{Escaping: EscapeGo}, "&amp;&lt;&gt;&#34;&#39;`= <p title=\"&amp;&lt;&gt;&#34;&#39;`=\">"},
+		{ParseOptions{Escaping: EscapeGo, ContextualEscaping: true}, "&amp;&lt;&gt;&#34;&#39;`= <p title=\"&amp;&lt;&gt;&#34;&#39;`=\">"},
+		{ParseOptions{Escaping: EscapeNone}, "&<>\"'`= <p title=\"&<>\"'`=\">"},
+	}
+
+	for _, test := range tests {
+		tpl, err := ParseWithOptions(`{{foo}} <p title="{{foo}}">`, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output := tpl.MustExec(ctx); output != test.output {
+			t.Errorf("Unexpected output with %+v\nexpected:\n\t%s\ngot:\n\t%s", test.opts, test.output, output)
+		}
+
+		if output := tpl.Clone().MustExec(ctx); output != test.output {
+			t.Errorf("Escaping not cloned with %+v: %s", test.opts, output)
+		}
+	}
+}
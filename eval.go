@@ -3,9 +3,11 @@ package raymond
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aymerick/raymond/ast"
 )
@@ -15,6 +17,9 @@ var (
 	errorType       = reflect.TypeOf((*error)(nil)).Elem()
 	fmtStringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
 
+	strType  = reflect.TypeOf("")
+	boolType = reflect.TypeOf(true)
+
 	zero reflect.Value
 )
 
@@ -28,6 +33,10 @@ type evalVisitor struct {
 	// current data frame (chained with parent)
 	dataFrame *DataFrame
 
+	// default root data frame, and free list of iteration data frames, reused across evaluations
+	rootFrame *DataFrame
+	frames    []*DataFrame
+
 	// block parameters stack
 	blockParams []map[string]interface{}
 
@@ -40,24 +49,74 @@ type evalVisitor struct {
 	// memoize expressions that were function calls
 	exprFunc map[*ast.Expression]bool
 
+	// compiled programs of the template being evaluated
+	code *compiledPrograms
+
 	// used for info on panic
 	curNode ast.Node
+
+	// name of partial being evaluated, empty for the template itself
+	curPartial string
+
+	// source of randomness, lazily instanciated if not provided
+	rand       *rand.Rand
+	randSeeded bool
+
+	// deterministic mode, and clock of helpers
+	deterministic bool
+	clock         func() time.Time
+
+	// limits the number of partials rendered concurrently, nil if partials are rendered sequentially
+	partialSem chan struct{}
+
+	// records render times, and statements being rendered, nil if not profiling
+	profiler *Profiler
+	profile  []profileFrame
+
+	// records rendered programs, nil if not recording coverage
+	coverage *Coverage
+
+	// bytes produced, nil if there is no memory budget
+	budget *memoryBudget
+
+	// memoized outputs of pure partials, for this evaluation or shared by evaluations, and buffer used to compute their keys
+	memo       map[memoKey]string
+	sharedMemo *PartialMemo
+	memoBuf    []byte
+
+	// CSP nonce exposed as @nonce, and corresponding attribute injected into script and style tags
+	nonce     string
+	nonceAttr string
+
+	// security policy of evaluation: sandbox, nesting level of blocks and partials, and iterations and duration
+	sandbox  *sandbox
+	depth    int
+	maxDepth int
+	usage    *evalUsage
+
+	// secret values resolved by evaluation, nil if no secret classifier is registered
+	secrets *secretSet
 }
 
-// NewEvalVisitor instanciate a new evaluation visitor with given context and initial private data frame
+// NewEvalVisitor returns an evaluation visitor from the pool, with given context and initial private data frame
 //
-// If privData is nil, then a default data frame is created
+// If privData is nil, then a default data frame is used. Call release() once evaluation is done.
 func newEvalVisitor(tpl *Template, ctx interface{}, privData *DataFrame) *evalVisitor {
-	frame := privData
-	if frame == nil {
-		frame = NewDataFrame()
-	}
+	v := evalVisitorPool.Get().(*evalVisitor)
+	v.init(tpl, ctx, privData)
+
+	return v
+}
+
+// init sets up visitor to evaluate given template with given context and private data frame
+func (v *evalVisitor) init(tpl *Template, ctx interface{}, privData *DataFrame) {
+	v.tpl = tpl
+	v.ctx = append(v.ctx, reflect.ValueOf(ctx))
+	v.code = tpl.code
 
-	return &evalVisitor{
-		tpl:       tpl,
-		ctx:       []reflect.Value{reflect.ValueOf(ctx)},
-		dataFrame: frame,
-		exprFunc:  make(map[*ast.Expression]bool),
+	v.dataFrame = privData
+	if v.dataFrame == nil {
+		v.dataFrame = v.rootFrame
 	}
 }
 
@@ -221,7 +280,7 @@ func (v *evalVisitor) curExpr() *ast.Expression {
 
 // errPanic panics
 func (v *evalVisitor) errPanic(err error) {
-	panic(fmt.Errorf("Evaluation error: %s\nCurrent node:\n\t%s", err, v.curNode))
+	panic(fmt.Errorf("Evaluation error: %w\nCurrent node:\n\t%s", err, v.curNode))
 }
 
 // errorf panics with a custom message
@@ -233,52 +292,74 @@ func (v *evalVisitor) errorf(format string, args ...interface{}) {
 // Evaluation
 //
 
-// evalProgram eEvaluates program with given context and returns string result
-func (v *evalVisitor) evalProgram(program *ast.Program, ctx interface{}, data *DataFrame, key interface{}) string {
-	blockParams := make(map[string]interface{})
+// programScope records what was pushed to stacks when entering a program
+type programScope struct {
+	blockParams bool
+	ctx         bool
+	data        bool
+}
+
+// enterProgram pushes block params, context and private data of a program evaluation
+func (v *evalVisitor) enterProgram(program *ast.Program, ctx interface{}, data *DataFrame, key interface{}) programScope {
+	var scope programScope
 
 	// compute block params
 	if len(program.BlockParams) > 0 {
-		blockParams[program.BlockParams[0]] = ctx
-	}
+		blockParams := map[string]interface{}{program.BlockParams[0]: ctx}
 
-	if (len(program.BlockParams) > 1) && (key != nil) {
-		blockParams[program.BlockParams[1]] = key
-	}
+		if (len(program.BlockParams) > 1) && (key != nil) {
+			blockParams[program.BlockParams[1]] = key
+		}
 
-	// push contexts
-	if len(blockParams) > 0 {
 		v.pushBlockParams(blockParams)
+		scope.blockParams = true
 	}
 
-	ctxVal := reflect.ValueOf(ctx)
-	if ctxVal.IsValid() {
+	// push contexts
+	if ctxVal := reflect.ValueOf(ctx); ctxVal.IsValid() {
 		v.pushCtx(ctxVal)
+		scope.ctx = true
 	}
 
 	if data != nil {
 		v.setDataFrame(data)
+		scope.data = true
 	}
 
-	// evaluate program
-	result, _ := program.Accept(v).(string)
+	return scope
+}
 
-	// pop contexts
-	if data != nil {
+// leaveProgram pops what was pushed by enterProgram()
+func (v *evalVisitor) leaveProgram(scope programScope) {
+	if scope.data {
 		v.popDataFrame()
 	}
 
-	if ctxVal.IsValid() {
+	if scope.ctx {
 		v.popCtx()
 	}
 
-	if len(blockParams) > 0 {
+	if scope.blockParams {
 		v.popBlockParams()
 	}
+}
+
+// evalProgram evaluates program with given context and returns string result
+func (v *evalVisitor) evalProgram(program *ast.Program, ctx interface{}, data *DataFrame, key interface{}) string {
+	scope := v.enterProgram(program, ctx, data, key)
+	result := v.programStr(program)
+	v.leaveProgram(scope)
 
 	return result
 }
 
+// evalProgramTo evaluates program with given context and writes result to given writer
+func (v *evalVisitor) evalProgramTo(w writer, program *ast.Program, ctx interface{}, data *DataFrame, key interface{}) {
+	scope := v.enterProgram(program, ctx, data, key)
+	v.programTo(w, program)
+	v.leaveProgram(scope)
+}
+
 // evalPath evaluates all path parts with given context
 func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool) (reflect.Value, bool) {
 	partResolved := false
@@ -291,13 +372,19 @@ func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool)
 			part = part[1 : len(part)-1]
 		}
 
-		ctx = v.evalField(ctx, part, exprRoot)
-		if !ctx.IsValid() {
+		var found bool
+
+		ctx, found = v.lookupField(ctx, part, exprRoot)
+		if !found {
 			break
 		}
 
-		// we resolved at least one part of path
+		// we resolved at least one part of path, even if its value is undefined
 		partResolved = true
+
+		if !ctx.IsValid() {
+			break
+		}
 	}
 
 	return ctx, partResolved
@@ -305,30 +392,69 @@ func (v *evalVisitor) evalPath(ctx reflect.Value, parts []string, exprRoot bool)
 
 // evalField evaluates field with given context
 func (v *evalVisitor) evalField(ctx reflect.Value, fieldName string, exprRoot bool) reflect.Value {
+	result, _ := v.lookupField(ctx, fieldName, exprRoot)
+	return result
+}
+
+// lookupField evaluates field with given context, and returns true if that field exists, even if its value is undefined (eg. a nil pointer)
+func (v *evalVisitor) lookupField(ctx reflect.Value, fieldName string, exprRoot bool) (reflect.Value, bool) {
 	result := zero
 
 	ctx, _ = indirect(ctx)
 	if !ctx.IsValid() {
-		return result
+		return result, false
 	}
 
-	// check if this is a method call
-	result, isMeth := v.evalMethod(ctx, fieldName, exprRoot)
-	if !isMeth {
-		switch ctx.Kind() {
-		case reflect.Struct:
-			// example: firstName => FirstName
-			expFieldName := strings.Title(fieldName)
-
-			// check if struct have this field and that it is exported
-			if tField, ok := ctx.Type().FieldByName(expFieldName); ok && (tField.PkgPath == "") {
-				// struct field
-				result = ctx.FieldByIndex(tField.Index)
-				break
-			}
+	resolvers := valueResolvers.load()
+
+	found := false
+	if len(resolvers) > 0 {
+		result, found = resolveField(resolvers, ctx, fieldName)
+	}
+
+	if !found {
+		result = v.evalMember(ctx, fieldName, exprRoot)
+		found = result.IsValid()
+	}
+
+	result = adaptValue(result)
+
+	// check if result is a function
+	if result.Kind() == reflect.Func {
+		v.checkFunc(fieldName)
+		result = v.evalFieldFunc(fieldName, result, exprRoot)
+	}
+
+	if len(resolvers) > 0 {
+		result = resolveValue(resolvers, result)
+	}
+
+	return result, found
+}
+
+// evalMember evaluates the method, struct field, map key or slice index of given context with given name
+func (v *evalVisitor) evalMember(ctx reflect.Value, fieldName string, exprRoot bool) reflect.Value {
+	result := zero
+
+	plan := findFieldPlan(ctx, fieldName)
+	v.checkMember(ctx, plan)
+
+	if plan.unsupported {
+		v.errorf("Can't resolve field %s of struct %s: struct fields and methods are not supported in tiny build mode", fieldName, ctx.Type())
+	}
 
-			// attempts to find template variable name as a struct tag
-			result = v.evalStructTag(ctx, fieldName)
+	switch {
+	case plan.method >= 0:
+		// method call
+		result = v.evalMethod(ctx, plan.method, fieldName, exprRoot)
+	case plan.tag:
+		// template variable name found as a struct tag
+		result = reflect.ValueOf(ctx.Interface()).FieldByIndex(plan.field)
+	case plan.field != nil:
+		// struct field
+		result = ctx.FieldByIndex(plan.field)
+	default:
+		switch ctx.Kind() {
 		case reflect.Map:
 			nameVal := reflect.ValueOf(fieldName)
 			if nameVal.Type().AssignableTo(ctx.Type().Key()) {
@@ -342,32 +468,16 @@ func (v *evalVisitor) evalField(ctx reflect.Value, fieldName string, exprRoot bo
 		}
 	}
 
-	// check if result is a function
-	result, _ = indirect(result)
-	if result.Kind() == reflect.Func {
-		result = v.evalFieldFunc(fieldName, result, exprRoot)
-	}
-
 	return result
 }
 
-// evalFieldFunc tries to evaluate given method name, and a boolean to indicate if this was a method call
-func (v *evalVisitor) evalMethod(ctx reflect.Value, name string, exprRoot bool) (reflect.Value, bool) {
+// evalMethod evaluates method with given index
+func (v *evalVisitor) evalMethod(ctx reflect.Value, index int, name string, exprRoot bool) reflect.Value {
 	if ctx.Kind() != reflect.Interface && ctx.CanAddr() {
 		ctx = ctx.Addr()
 	}
 
-	method := ctx.MethodByName(name)
-	if !method.IsValid() {
-		// example: subject() => Subject()
-		method = ctx.MethodByName(strings.Title(name))
-	}
-
-	if !method.IsValid() {
-		return zero, false
-	}
-
-	return v.evalFieldFunc(name, method, exprRoot), true
+	return v.evalFieldFunc(name, ctx.Method(index), exprRoot)
 }
 
 // evalFieldFunc evaluates given function
@@ -388,24 +498,11 @@ func (v *evalVisitor) evalFieldFunc(name string, funcVal reflect.Value, exprRoot
 		options = newEmptyOptions(v)
 	}
 
-	return v.callFunc(name, funcVal, options)
-}
-
-// evalStructTag checks for the existence of a struct tag containing the
-// name of the variable in the template. This allows for a template variable to
-// be separated from the field in the struct.
-func (v *evalVisitor) evalStructTag(ctx reflect.Value, name string) reflect.Value {
-	val := reflect.ValueOf(ctx.Interface())
-
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Type().Field(i)
-		tag := field.Tag.Get("handlebars")
-		if tag == name {
-			return val.Field(i)
-		}
+	if exprRoot && (v.tpl.mustache != nil) {
+		return v.callLambda(name, funcVal, options)
 	}
 
-	return zero
+	return v.callFunc(name, funcVal, options)
 }
 
 // findBlockParam returns node's block parameter
@@ -465,6 +562,8 @@ func (v *evalVisitor) evalPathExpression(node *ast.PathExpression, exprRoot bool
 		}
 	}
 
+	v.classify(node.Original, result)
+
 	return result
 }
 
@@ -482,6 +581,12 @@ func (v *evalVisitor) evalDataPathExpression(node *ast.PathExpression, exprRoot
 	// resolve data
 	// @note Can be changed to v.evalCtx() as context can't be an array
 	result, _ := v.evalCtxPath(reflect.ValueOf(frame.data), node.Parts, exprRoot)
+
+	if (result == nil) && (v.nonce != "") && (len(node.Parts) == 1) && (node.Parts[0] == "nonce") {
+		// @nonce
+		return v.nonce
+	}
+
 	return result
 }
 
@@ -560,14 +665,30 @@ func (v *evalVisitor) evalCtxPath(ctx reflect.Value, parts []string, exprRoot bo
 
 // isHelperCall returns true if given expression is a helper call
 func (v *evalVisitor) isHelperCall(node *ast.Expression) bool {
+	_, helper := v.findExprHelper(node)
+	return helper != zero
+}
+
+// findExprHelper finds the helper called by given expression, and returns its name
+func (v *evalVisitor) findExprHelper(node *ast.Expression) (string, reflect.Value) {
 	if helperName := node.HelperName(); helperName != "" {
-		return v.findHelper(helperName) != zero
+		return helperName, v.findHelper(helperName)
 	}
-	return false
+
+	// path-aware lookup of namespaced helper
+	if helperName := node.NamespacedHelperName(); helperName != "" {
+		return helperName, v.findHelper(helperName)
+	}
+
+	return "", zero
 }
 
 // findHelper finds given helper
 func (v *evalVisitor) findHelper(name string) reflect.Value {
+	if (v.tpl.mustache != nil) || !v.sandbox.allowHelper(name) {
+		return zero
+	}
+
 	// check template helpers
 	if h := v.tpl.findHelper(name); h != zero {
 		return h
@@ -577,59 +698,61 @@ func (v *evalVisitor) findHelper(name string) reflect.Value {
 	return findHelper(name)
 }
 
+// isDirectHelper returns true if given name still resolves to the helper with given code pointer, and if that helper is not wrapped by middlewares
+//
+// This checks that a helper call optimized away at parse time would behave the same at render time.
+func (v *evalVisitor) isDirectHelper(name string, helper uintptr) bool {
+	if len(v.tpl.helperMiddlewares()) > 0 {
+		return false
+	}
+
+	found := v.findHelper(name)
+
+	return (found != zero) && (found.Pointer() == helper)
+}
+
 // callFunc calls function with given options
 func (v *evalVisitor) callFunc(name string, funcVal reflect.Value, options *Options) reflect.Value {
 	params := options.Params()
 
 	funcType := funcVal.Type()
 
-	// @todo Is there a better way to do that ?
-	strType := reflect.TypeOf("")
-	boolType := reflect.TypeOf(true)
+	if funcType.IsVariadic() {
+		return v.callVariadicFunc(name, funcVal, options)
+	}
 
 	// check parameters number
 	addOptions := false
 	numIn := funcType.NumIn()
 
+	optionsType := reflect.TypeOf(options)
+
 	if numIn == len(params)+1 {
 		lastArgType := funcType.In(numIn - 1)
-		if reflect.TypeOf(options).AssignableTo(lastArgType) {
+		if optionsType.AssignableTo(lastArgType) {
 			addOptions = true
 		}
 	}
 
-	if !addOptions && (len(params) != numIn) {
-		v.errorf("Helper '%s' called with wrong number of arguments, needed %d but got %d", name, numIn, len(params))
+	if !addOptions {
+		needed := numIn
+		if (numIn > 0) && (funcType.In(numIn-1) == optionsType) {
+			// options argument is not provided by template
+			needed--
+		}
+
+		if len(params) != needed {
+			v.errorf("Helper '%s' called with wrong number of arguments, needed %d but got %d", name, needed, len(params))
+		}
 	}
 
 	// check and collect arguments
 	args := make([]reflect.Value, numIn)
 	for i, param := range params {
-		arg := reflect.ValueOf(param)
-		argType := funcType.In(i)
-
-		if !arg.IsValid() {
-			if canBeNil(argType) {
-				arg = reflect.Zero(argType)
-			} else if argType.Kind() == reflect.String {
-				arg = reflect.ValueOf("")
-			} else {
-				// @todo Maybe we can panic on that
-				return reflect.Zero(strType)
-			}
-		}
-
-		if !arg.Type().AssignableTo(argType) {
-			if strType.AssignableTo(argType) {
-				// convert parameter to string
-				arg = reflect.ValueOf(strValue(arg))
-			} else if boolType.AssignableTo(argType) {
-				// convert parameter to bool
-				val, _ := isTrueValue(arg)
-				arg = reflect.ValueOf(val)
-			} else {
-				v.errorf("Helper %s called with argument %d with type %s but it should be %s", name, i, arg.Type(), argType)
-			}
+		arg, ok := v.funcArg(name, i, param, funcType.In(i))
+		if !ok {
+			// @todo Maybe we can panic on that
+			return reflect.Zero(strType)
 		}
 
 		args[i] = arg
@@ -644,9 +767,105 @@ func (v *evalVisitor) callFunc(name string, funcVal reflect.Value, options *Opti
 	return result[0]
 }
 
+// callVariadicFunc calls variadic function with given options
+//
+// As no parameter can follow the variadic one, options argument is provided as first argument, if function expects it.
+func (v *evalVisitor) callVariadicFunc(name string, funcVal reflect.Value, options *Options) reflect.Value {
+	params := options.Params()
+
+	funcType := funcVal.Type()
+
+	// options argument is not provided by template
+	first := 0
+	if (funcType.NumIn() > 1) && reflect.TypeOf(options).AssignableTo(funcType.In(0)) {
+		first = 1
+	} else if len(options.Hash()) > 0 {
+		v.errorf("Helper '%s' called with hash arguments, but it does not expect an options argument", name)
+	}
+
+	// check parameters number
+	numFixed := funcType.NumIn() - 1 - first
+	if len(params) < numFixed {
+		v.errorf("Helper '%s' called with wrong number of arguments, needed at least %d but got %d", name, numFixed, len(params))
+	}
+
+	// check and collect arguments
+	args := make([]reflect.Value, first+len(params))
+	if first > 0 {
+		args[0] = reflect.ValueOf(options)
+	}
+
+	for i, param := range params {
+		argType := funcType.In(first + numFixed).Elem()
+		if i < numFixed {
+			argType = funcType.In(first + i)
+		}
+
+		arg, ok := v.funcArg(name, i, param, argType)
+		if !ok {
+			// @todo Maybe we can panic on that
+			return reflect.Zero(strType)
+		}
+
+		args[first+i] = arg
+	}
+
+	result := funcVal.Call(args)
+
+	return result[0]
+}
+
+// funcArg converts given parameter to a function argument of given type, with a boolean set to false if that is not possible
+func (v *evalVisitor) funcArg(name string, pos int, param interface{}, argType reflect.Type) (reflect.Value, bool) {
+	arg := reflect.ValueOf(param)
+
+	if !arg.IsValid() {
+		if canBeNil(argType) {
+			arg = reflect.Zero(argType)
+		} else if argType.Kind() == reflect.String {
+			arg = reflect.ValueOf("")
+		} else {
+			return zero, false
+		}
+	}
+
+	if !arg.Type().AssignableTo(argType) {
+		if strType.AssignableTo(argType) {
+			// convert parameter to string
+			if f, ok := numberValue(arg); ok && v.tpl.jsNumbers {
+				arg = reflect.ValueOf(jsNumberStr(f))
+			} else {
+				arg = reflect.ValueOf(strValue(arg))
+			}
+		} else if boolType.AssignableTo(argType) {
+			// convert parameter to bool
+			val, _ := isTrueValue(arg)
+			arg = reflect.ValueOf(val)
+		} else {
+			v.errorf("Helper %s called with argument %d with type %s but it should be %s", name, pos, arg.Type(), argType)
+		}
+	}
+
+	return arg, true
+}
+
 // callHelper invoqs helper function for given expression node
 func (v *evalVisitor) callHelper(name string, helper reflect.Value, node *ast.Expression) interface{} {
-	result := v.callFunc(name, helper, v.helperOptions(node))
+	options := v.helperOptions(node)
+
+	v.profileStart(ProfileHelper, name, 0)
+	defer v.profileEnd()
+
+	if middlewares := v.tpl.helperMiddlewares(); len(middlewares) > 0 {
+		return v.callHelperMiddlewares(middlewares, name, helper, options)
+	}
+
+	return v.callHelperFunc(name, helper, options)
+}
+
+// callHelperFunc calls helper function with given options
+func (v *evalVisitor) callHelperFunc(name string, helper reflect.Value, options *Options) interface{} {
+	result := v.callFunc(name, helper, options)
 	if !result.IsValid() {
 		return nil
 	}
@@ -709,31 +928,62 @@ func (v *evalVisitor) partialContext(node *ast.PartialStatement) reflect.Value {
 	return zero
 }
 
-// evalPartial evaluates a partial
-func (v *evalVisitor) evalPartial(p *partial, node *ast.PartialStatement) string {
+// evalPartialTo evaluates a partial and writes result to given writer
+func (v *evalVisitor) evalPartialTo(w writer, p *partial, node *ast.PartialStatement) {
 	// get partial template
 	partialTpl, err := p.template()
 	if err != nil {
 		v.errPanic(err)
 	}
 
+	if v.coverage != nil {
+		v.coverage.track(p.name, partialTpl)
+	}
+
+	// partials are analyzed as if they start in HTML text
+	if v.tpl.contextualEscaping {
+		if err := partialTpl.code.checkEscaping(true); err != nil {
+			v.errPanic(err)
+		}
+	}
+
+	v.nest(node.Line)
+
 	// push partial context
 	ctx := v.partialContext(node)
 	if ctx.IsValid() {
 		v.pushCtx(ctx)
 	}
 
-	// evaluate partial template
-	result, _ := partialTpl.program.Accept(v).(string)
+	// Mustache indents partial source instead of its output
+	mustacheIndent := (partialTpl.mustache != nil) && (node.Indent != "")
+	if mustacheIndent {
+		if partialTpl, err = p.indented(partialTpl, node.Indent); err != nil {
+			v.errPanic(err)
+		}
+	}
 
-	// ident partial
-	result = indentLines(result, node.Indent)
+	// evaluate partial template with its own compiled programs
+	code := v.code
+	v.code = partialTpl.code
+
+	switch {
+	case p.pure && !mustacheIndent:
+		v.write(w, indentLines(v.pureProgramStr(p, partialTpl), node.Indent))
+	case (node.Indent == "") || mustacheIndent:
+		v.programTo(w, partialTpl.program)
+	default:
+		// ident partial
+		v.write(w, indentLines(v.programStr(partialTpl.program), node.Indent))
+	}
+
+	v.code = code
 
 	if ctx.IsValid() {
 		v.popCtx()
 	}
 
-	return result
+	v.unnest()
 }
 
 // indentLines indents all lines of given string
@@ -777,10 +1027,16 @@ func (v *evalVisitor) wasFuncCall(node *ast.Expression) bool {
 func (v *evalVisitor) VisitProgram(node *ast.Program) interface{} {
 	v.at(node)
 
+	if code := v.compiled(node); code != nil {
+		return v.run(code)
+	}
+
 	buf := new(bytes.Buffer)
 
 	for _, n := range node.Body {
 		if str := Str(n.Accept(v)); str != "" {
+			v.account(len(str))
+
 			if _, err := buf.Write([]byte(str)); err != nil {
 				v.errPanic(err)
 			}
@@ -792,75 +1048,119 @@ func (v *evalVisitor) VisitProgram(node *ast.Program) interface{} {
 
 // VisitMustache implements corresponding Visitor interface method
 func (v *evalVisitor) VisitMustache(node *ast.MustacheStatement) interface{} {
+	var buf strings.Builder
+	v.writeMustache(&buf, node)
+
+	return buf.String()
+}
+
+// writeMustache evaluates a mustache statement and writes result to given writer
+func (v *evalVisitor) writeMustache(w writer, node *ast.MustacheStatement) {
 	v.at(node)
+	v.profileStart(ProfileMustache, exprName(node.Expression), node.Line)
 
 	// evaluate expression
 	expr := node.Expression.Accept(v)
 
-	// check if this is a safe string
-	isSafe := isSafeString(expr)
-
-	// get string value
-	str := Str(expr)
-	if !isSafe && !node.Unescaped {
-		// escape html
-		str = Escape(str)
-	}
+	v.writeValue(w, node, expr)
 
-	return str
+	v.profileEnd()
 }
 
 // VisitBlock implements corresponding Visitor interface method
 func (v *evalVisitor) VisitBlock(node *ast.BlockStatement) interface{} {
+	var buf strings.Builder
+	v.writeBlock(&buf, node)
+
+	return buf.String()
+}
+
+// writeBlock evaluates a block statement and writes result to given writer
+func (v *evalVisitor) writeBlock(w writer, node *ast.BlockStatement) {
 	v.at(node)
+	v.profileStart(ProfileBlock, exprName(node.Expression), node.Line)
 
+	v.nest(node.Line)
 	v.pushBlock(node)
 
-	var result interface{}
-
 	// evaluate expression
 	expr := node.Expression.Accept(v)
 
 	if v.isHelperCall(node.Expression) || v.wasFuncCall(node.Expression) {
 		// it is the responsibility of the helper/function to evaluate block
-		result = expr
+		v.write(w, Str(expr))
 	} else {
 		val := reflect.ValueOf(expr)
 
-		truth, _ := isTrueValue(val)
-		if truth {
+		if v.isTrue(expr) {
 			if node.Program != nil {
 				switch val.Kind() {
 				case reflect.Array, reflect.Slice:
-					concat := ""
-
 					// Array context
 					for i := 0; i < val.Len(); i++ {
 						// Computes new private data frame
-						frame := v.dataFrame.newIterDataFrame(val.Len(), i, nil)
+						frame := v.newIterDataFrame(val.Len(), i, nil)
 
 						// Evaluate program
-						concat += v.evalProgram(node.Program, val.Index(i).Interface(), frame, i)
-					}
+						v.evalProgramTo(w, node.Program, val.Index(i).Interface(), frame, i)
 
-					result = concat
+						v.releaseDataFrame(frame)
+					}
 				default:
 					// NOT array
-					result = v.evalProgram(node.Program, expr, nil, nil)
+					v.evalProgramTo(w, node.Program, expr, nil, nil)
 				}
 			}
 		} else if node.Inverse != nil {
-			result, _ = node.Inverse.Accept(v).(string)
+			v.programTo(w, node.Inverse)
 		}
 	}
 
 	v.popBlock()
+	v.unnest()
 
-	return result
+	v.profileEnd()
+}
+
+// writeBranch evaluates a #if or #unless block statement with a literal condition, and writes result to given writer
+//
+// Only the reachable program is evaluated, without calling the helper, unless it has been shadowed, replaced or wrapped by middlewares.
+func (v *evalVisitor) writeBranch(w writer, op *branchOperand) {
+	if !v.isDirectHelper(op.name, op.helper) {
+		v.writeBlock(w, op.stmt)
+		return
+	}
+
+	node := op.stmt
+
+	v.at(node)
+	v.profileStart(ProfileBlock, exprName(node.Expression), node.Line)
+
+	v.nest(node.Line)
+	v.pushBlock(node)
+	v.pushExpr(node.Expression)
+
+	if op.program != nil {
+		v.programTo(w, op.program)
+	}
+
+	v.popExpr()
+	v.popBlock()
+	v.unnest()
+
+	v.profileEnd()
 }
 
 // VisitPartial implements corresponding Visitor interface method
 func (v *evalVisitor) VisitPartial(node *ast.PartialStatement) interface{} {
+	var buf strings.Builder
+	v.writePartial(&buf, node)
+
+	return buf.String()
+}
+
+// writePartial evaluates a partial statement and writes result to given writer
+func (v *evalVisitor) writePartial(w writer, node *ast.PartialStatement) {
 	v.at(node)
 
 	// partialName: helperName | sexpr
@@ -875,18 +1175,38 @@ func (v *evalVisitor) VisitPartial(node *ast.PartialStatement) interface{} {
 		v.errorf("Unexpected partial name: %q", node.Name)
 	}
 
+	v.checkPartial(name, node)
+
 	partial := v.findPartial(name)
 	if partial == nil {
+		if v.tpl.mustache != nil {
+			// missing partials render nothing
+			return
+		}
+
 		v.errorf("Partial not found: %s", name)
 	}
 
-	return v.evalPartial(partial, node)
+	v.profileStart(ProfilePartial, name, node.Line)
+
+	// not restored on panic, so that errors report the innermost partial
+	prev := v.curPartial
+	v.curPartial = name
+
+	v.evalPartialTo(w, partial, node)
+
+	v.curPartial = prev
+	v.profileEnd()
 }
 
 // VisitContent implements corresponding Visitor interface method
 func (v *evalVisitor) VisitContent(node *ast.ContentStatement) interface{} {
 	v.at(node)
 
+	if v.nonceAttr != "" {
+		return injectNonce(node.Value, v.nonceAttr)
+	}
+
 	// write content as is
 	return node.Value
 }
@@ -911,11 +1231,9 @@ func (v *evalVisitor) VisitExpression(node *ast.Expression) interface{} {
 	v.pushExpr(node)
 
 	// helper call
-	if helperName := node.HelperName(); helperName != "" {
-		if helper := v.findHelper(helperName); helper != zero {
-			result = v.callHelper(helperName, helper, node)
-			done = true
-		}
+	if helperName, helper := v.findExprHelper(node); helper != zero {
+		result = v.callHelper(helperName, helper, node)
+		done = true
 	}
 
 	if !done {
@@ -949,7 +1267,15 @@ func (v *evalVisitor) VisitExpression(node *ast.Expression) interface{} {
 func (v *evalVisitor) VisitSubExpression(node *ast.SubExpression) interface{} {
 	v.at(node)
 
-	return node.Expression.Accept(v)
+	if value, ok := v.foldedValue(node); ok {
+		return value
+	}
+
+	result := node.Expression.Accept(v)
+
+	v.classify(node.Expression.Canonical(), result)
+
+	return result
 }
 
 // VisitPath implements corresponding Visitor interface method
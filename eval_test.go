@@ -111,6 +111,7 @@ func TestEvalErrors(t *testing.T) {
 }
 
 func TestEvalStruct(t *testing.T) {
+	skipTiny(t)
 	t.Parallel()
 
 	source := `<div class="post">
@@ -169,6 +170,7 @@ func TestEvalStruct(t *testing.T) {
 }
 
 func TestEvalStructTag(t *testing.T) {
+	skipTiny(t)
 	t.Parallel()
 
 	source := `<div class="person">
@@ -233,6 +235,7 @@ func (t *TestFoo) Subject() string {
 }
 
 func TestEvalMethod(t *testing.T) {
+	skipTiny(t)
 	t.Parallel()
 
 	source := `Subject is {{subject}}! YES I SAID {{Subject}}!`
@@ -258,6 +261,7 @@ func testBar() string {
 }
 
 func TestEvalMethodReturningFunc(t *testing.T) {
+	skipTiny(t)
 	t.Parallel()
 
 	source := `Subject is {{subject}}! YES I SAID {{Subject}}!`
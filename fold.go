@@ -0,0 +1,193 @@
+package raymond
+
+import (
+	"reflect"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// foldedExpr is the value of a subexpression computed at parse time
+type foldedExpr struct {
+	// name of called helper
+	name string
+
+	// code pointer of called helper, to detect that it has been replaced since folding
+	helper uintptr
+
+	value interface{}
+}
+
+// foldTemplate is the template used to evaluate subexpressions at parse time: it has no helper, so only global ones are called
+var foldTemplate = newTemplate("")
+
+// foldStatement folds the subexpressions of given statement
+func (c *compiler) foldStatement(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.MustacheStatement:
+		c.foldExpression(n.Expression)
+	case *ast.BlockStatement:
+		c.foldExpression(n.Expression)
+	case *ast.PartialStatement:
+		c.foldNode(n.Name)
+		c.foldNodes(n.Params)
+		c.foldHash(n.Hash)
+	}
+}
+
+// foldExpression folds the subexpressions of given expression, and returns true if all its arguments are constant
+func (c *compiler) foldExpression(node *ast.Expression) bool {
+	result := true
+
+	if sub, ok := node.Path.(*ast.SubExpression); ok {
+		// eg. {{(helper) arg}}
+		c.foldSubExpression(sub)
+		result = false
+	}
+
+	if !c.foldNodes(node.Params) {
+		result = false
+	}
+
+	if !c.foldHash(node.Hash) {
+		result = false
+	}
+
+	return result
+}
+
+// foldNodes folds given nodes, and returns true if they are all constant
+func (c *compiler) foldNodes(nodes []ast.Node) bool {
+	result := true
+
+	for _, node := range nodes {
+		if !c.foldNode(node) {
+			result = false
+		}
+	}
+
+	return result
+}
+
+// foldHash folds the values of given hash, and returns true if they are all constant
+func (c *compiler) foldHash(node *ast.Hash) bool {
+	if node == nil {
+		return true
+	}
+
+	result := true
+
+	for _, pair := range node.Pairs {
+		if !c.foldNode(pair.Val) {
+			result = false
+		}
+	}
+
+	return result
+}
+
+// foldNode folds given node if it is a subexpression, and returns true if it is constant, ie. a literal or a folded subexpression
+func (c *compiler) foldNode(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.StringLiteral, *ast.BooleanLiteral, *ast.NumberLiteral:
+		return true
+	case *ast.SubExpression:
+		return c.foldSubExpression(n)
+	default:
+		return false
+	}
+}
+
+// foldSubExpression records the value of given subexpression if it calls a pure global helper with constant arguments, and returns true if it has been folded
+func (c *compiler) foldSubExpression(node *ast.SubExpression) bool {
+	if !c.foldExpression(node.Expression) {
+		return false
+	}
+
+	name := node.Expression.HelperName()
+	if name == "" {
+		name = node.Expression.NamespacedHelperName()
+	}
+
+	if name == "" {
+		return false
+	}
+
+	helpersMutex.RLock()
+	helper, info := helpers.get(name), helpersInfo[name]
+	helpersMutex.RUnlock()
+
+	if (helper == zero) || !info.Pure {
+		return false
+	}
+
+	value, ok := evalFolded(node)
+	if !ok {
+		return false
+	}
+
+	c.folded[node] = foldedExpr{
+		name:   name,
+		helper: helper.Pointer(),
+		value:  value,
+	}
+
+	return true
+}
+
+// evalFolded evaluates given subexpression at parse time, and returns false if that failed or if its value is not a scalar
+//
+// Evaluation errors are reported when the template is evaluated instead.
+func evalFolded(node *ast.SubExpression) (result interface{}, ok bool) {
+	v := newEvalVisitor(foldTemplate, nil, nil)
+	defer v.release()
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, ok = nil, false
+		}
+	}()
+
+	result = node.Expression.Accept(v)
+
+	return result, isScalar(result)
+}
+
+// isScalar returns true if given value is a string, a boolean or a number, so that a folded value can't be modified by helpers
+func isScalar(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// folded returns the value of given subexpression computed at parse time
+func (p *compiledPrograms) folded(node *ast.SubExpression) (foldedExpr, bool) {
+	if p == nil {
+		return foldedExpr{}, false
+	}
+
+	if result, ok := p.exprs[node]; ok {
+		return result, true
+	}
+
+	if result, ok := p.lazyExprs.Load(node); ok {
+		return result.(foldedExpr), true
+	}
+
+	return foldedExpr{}, false
+}
+
+// foldedValue returns the value of given subexpression computed at parse time, unless its helper has been shadowed by a template helper, replaced, or must be called through middlewares
+func (v *evalVisitor) foldedValue(node *ast.SubExpression) (interface{}, bool) {
+	folded, ok := v.code.folded(node)
+	if !ok || !v.isDirectHelper(folded.name, folded.helper) {
+		return nil, false
+	}
+
+	return folded.value, true
+}
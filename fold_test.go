@@ -0,0 +1,120 @@
+package raymond
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// number of calls of the testfoldeq helper
+var foldEqCalls int64
+
+func init() {
+	RegisterHelperWithInfo("testfoldeq", func(a, b interface{}) bool {
+		atomic.AddInt64(&foldEqCalls, 1)
+		return Str(a) == Str(b)
+	}, HelperInfo{Pure: true})
+
+	RegisterHelperWithInfo("testfoldlist", func(a, b interface{}) []interface{} {
+		return []interface{}{a, b}
+	}, HelperInfo{Pure: true})
+
+	RegisterHelper("testfoldimpure", func(a interface{}) string {
+		return Str(a)
+	})
+}
+
+func TestFold(t *testing.T) {
+	calls := atomic.LoadInt64(&foldEqCalls)
+
+	tpl := MustParse(`{{#if (testfoldeq "a" (concat "a" ""))}}yes{{/if}} {{#if (testfoldeq name "a")}}no{{/if}}`)
+
+	if nb := atomic.LoadInt64(&foldEqCalls) - calls; nb != 1 {
+		t.Errorf("Expected pure helper to be called once at parse time, got %d calls", nb)
+	}
+
+	for i := 0; i < 3; i++ {
+		if output := tpl.MustExec(map[string]string{"name": "b"}); output != "yes " {
+			t.Errorf("Unexpected output: %q", output)
+		}
+	}
+
+	// only the subexpression with a path argument is evaluated
+	if nb := atomic.LoadInt64(&foldEqCalls) - calls; nb != 4 {
+		t.Errorf("Expected folded subexpression to not be evaluated, got %d calls", nb)
+	}
+}
+
+func TestFoldNodes(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#if a}}{{else}}{{foo (concat "a" "b") bar=(concat "c" "d")}}{{/if}}` +
+		`{{> (concat "part" "ial") (testfoldimpure "x")}}{{testfoldlist "a" "b"}}{{indent (testfoldlist 1 2) "x"}}`)
+
+	// inverse programs are compiled lazily
+	tpl.code.get(tpl.program.Body[0].(*ast.BlockStatement).Inverse)
+
+	var values []string
+	for _, folded := range tpl.code.exprs {
+		values = append(values, Str(folded.value))
+	}
+
+	tpl.code.lazyExprs.Range(func(key, value interface{}) bool {
+		values = append(values, Str(value.(foldedExpr).value))
+		return true
+	})
+
+	sort.Strings(values)
+
+	if expected := "ab cd partial"; strings.Join(values, " ") != expected {
+		t.Errorf("Unexpected folded subexpressions, expected %q, got %q", expected, values)
+	}
+}
+
+func TestFoldShadowed(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{concat "a" (concat "b" "c")}}`)
+	tpl.RegisterHelper("concat", func(a, b string) string {
+		return strings.ToUpper(a + b)
+	})
+
+	if output := tpl.MustExec(nil); output != "ABC" {
+		t.Errorf("Unexpected output with shadowed pure helper: %q", output)
+	}
+}
+
+func TestFoldMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+
+	tpl := MustParse(`{{testfoldimpure (concat "a" "b")}}`)
+	tpl.UseHelperMiddleware(func(next HelperFunc) HelperFunc {
+		return func(call *HelperCall) (interface{}, error) {
+			calls = append(calls, call.Name)
+			return next(call)
+		}
+	})
+
+	if output := tpl.MustExec(nil); output != "ab" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if str := strings.Join(calls, " "); str != "concat testfoldimpure" {
+		t.Errorf("Folded subexpression not evaluated through middlewares: %q", str)
+	}
+}
+
+func TestFoldError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{testfoldimpure (indent "x" "text")}}`)
+
+	if _, err := tpl.Exec(nil); err == nil || !strings.Contains(err.Error(), "indent helper expects a positive number") {
+		t.Errorf("Expected evaluation error, got: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package raymond
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/lexer"
+	"github.com/aymerick/raymond/parser"
+)
+
+// Format returns given template source in canonical format.
+//
+// Content, comments and raw block contents are kept as is. Inside mustaches, whitespaces are normalized to a single space between params, no space after opening delimiters (except for partials and `else` chains) and before closing delimiters, and none around `=` of hash pairs. Strings are double quoted when possible, and whitespace control markers are kept.
+//
+// An error is returned if source is not a valid template. Formatting never changes the AST of a template.
+func Format(source string) (string, error) {
+	program, err := parser.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	result := formatTokens(source, lexer.Collect(source))
+
+	formatted, err := parser.Parse(result)
+	if (err != nil) || (ast.Print(formatted) != ast.Print(program)) {
+		return "", errors.New("Failed to format template: formatting changes its AST")
+	}
+
+	return result, nil
+}
+
+// formatTokens returns the canonical source for given tokens scanned from given source
+func formatTokens(source string, tokens []lexer.Token) string {
+	var b strings.Builder
+
+	// end of last token copied from source
+	end := 0
+
+	var prev *lexer.Token
+
+	for i := range tokens {
+		tok := &tokens[i]
+
+		switch tok.Kind {
+		case lexer.TokenContent:
+			// content is copied from source when next token is reached, to keep escaped mustaches
+			continue
+		case lexer.TokenEOF, lexer.TokenError:
+			b.WriteString(source[end:])
+			return b.String()
+		case lexer.TokenComment:
+			b.WriteString(source[end:tok.Pos])
+			b.WriteString(tok.Val)
+			end = tok.End
+			prev = nil
+			continue
+		case lexer.TokenInverse:
+			b.WriteString(source[end:tok.Pos])
+			b.WriteString(removeSpaces(tok.Val))
+			end = tok.End
+			prev = nil
+			continue
+		}
+
+		if prev == nil {
+			// opening delimiter
+			b.WriteString(source[end:tok.Pos])
+		} else if formatSpaced(prev, tok) {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(formatToken(source, tok))
+
+		switch tok.Kind {
+		case lexer.TokenClose, lexer.TokenCloseUnescaped, lexer.TokenCloseRawBlock:
+			end = tok.End
+			prev = nil
+		default:
+			prev = tok
+		}
+	}
+
+	b.WriteString(source[end:])
+
+	return b.String()
+}
+
+// formatToken returns the canonical source of given token inside mustaches
+func formatToken(source string, tok *lexer.Token) string {
+	switch tok.Kind {
+	case lexer.TokenOpenInverseChain:
+		return removeSpaces(tok.Val)
+	case lexer.TokenOpenBlockParams:
+		return "as |"
+	case lexer.TokenString:
+		if !strings.ContainsAny(tok.Val, `"\`) {
+			return `"` + tok.Val + `"`
+		}
+
+		// keep original delimiter, that precedes string value
+		delim := source[tok.Pos-1 : tok.Pos]
+
+		return delim + strings.Replace(tok.Val, delim, `\`+delim, -1) + delim
+	default:
+		return tok.Val
+	}
+}
+
+// formatSpaced returns true if a space must be written between given consecutive tokens inside mustaches
+func formatSpaced(prev *lexer.Token, tok *lexer.Token) bool {
+	switch prev.Kind {
+	case lexer.TokenOpenPartial, lexer.TokenOpenInverseChain:
+		return true
+	case lexer.TokenOpen, lexer.TokenOpenUnescaped, lexer.TokenOpenBlock, lexer.TokenOpenEndBlock,
+		lexer.TokenOpenRawBlock, lexer.TokenOpenEndRawBlock, lexer.TokenOpenInverse, lexer.TokenOpenSexpr,
+		lexer.TokenOpenBlockParams, lexer.TokenEquals, lexer.TokenData, lexer.TokenSep:
+		return false
+	}
+
+	switch tok.Kind {
+	case lexer.TokenClose, lexer.TokenCloseUnescaped, lexer.TokenCloseRawBlock, lexer.TokenCloseSexpr,
+		lexer.TokenCloseBlockParams, lexer.TokenEquals, lexer.TokenSep:
+		return false
+	}
+
+	return true
+}
+
+// removeSpaces returns given string without whitespaces
+func removeSpaces(str string) string {
+	return strings.Join(strings.Fields(str), "")
+}
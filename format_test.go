@@ -0,0 +1,55 @@
+package raymond
+
+import "testing"
+
+var formatTests = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{"content", "foo\n  bar  \n", "foo\n  bar  \n"},
+	{"mustache", "{{  foo  }}", "{{foo}}"},
+	{"path", "{{ ../foo/bar.[baz qux] }} {{ @root.foo }}", "{{../foo/bar.[baz qux]}} {{@root.foo}}"},
+	{"params", "{{foo  bar\n  1   true  null}}", "{{foo bar 1 true null}}"},
+	{"hash", "{{foo bar = 1  baz= 'qux'}}", `{{foo bar=1 baz="qux"}}`},
+	{"strings", `{{foo 'a"b' "c'd" "e\"f'g"}}`, `{{foo 'a"b' "c'd" "e\"f'g"}}`},
+	{"subexpression", "{{foo ( bar  baz ) ( qux )}}", "{{foo (bar baz) (qux)}}"},
+	{"unescaped", "{{{ foo }}} {{& bar }}", "{{{foo}}} {{&bar}}"},
+	{"strip", "{{~ foo ~}} {{~{ bar }~}}", "{{~foo~}} {{~{bar}~}}"},
+	{"block", "{{# each items as | item idx | }}{{ item }}{{ else }}none{{/ each }}", "{{#each items as |item idx|}}{{item}}{{else}}none{{/each}}"},
+	{"inverse", "{{^ foo }}bar{{^  }}baz{{/foo}}", "{{^foo}}bar{{^}}baz{{/foo}}"},
+	{"else chain", "{{#if a}}1{{ else  if  b }}2{{~ else ~}}3{{/if}}", "{{#if a}}1{{else if b}}2{{~else~}}3{{/if}}"},
+	{"partial", "{{>foo  bar  baz=1}} {{>  (qux) }}", "{{> foo bar baz=1}} {{> (qux)}}"},
+	{"comments", "{{!  foo  }}{{!--  {{bar}}  --}}", "{{!  foo  }}{{!--  {{bar}}  --}}"},
+	{"raw block", "{{{{ raw }}}} {{ foo }} {{{{/ raw }}}}", "{{{{raw}}}} {{ foo }} {{{{/raw}}}}"},
+	{"escaped mustache", `\{{ foo }} \\{{ bar }}`, `\{{ foo }} \\{{bar}}`},
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range formatTests {
+		output, err := Format(test.input)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+			continue
+		}
+
+		if output != test.expected {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.expected, output)
+		}
+
+		// formatting is idempotent
+		if again, err := Format(output); (err != nil) || (again != output) {
+			t.Errorf("Test '%s' failed - Formatting is not idempotent: %q, %v", test.name, again, err)
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Format("{{#if a}}"); err == nil {
+		t.Errorf("Expected an error for an invalid template")
+	}
+}
@@ -0,0 +1,111 @@
+package raymond
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// FSOptions represents the options of ParseFS().
+type FSOptions struct {
+	// Root is the directory of templates in file system, eg. "templates" for an embed.FS of that directory. Default is the file system root.
+	Root string
+
+	// Extensions are the extensions of template files, that are removed from template names. Default is ".hbs" and ".handlebars".
+	Extensions []string
+
+	// PartialsDir is the directory of partials, relative to Root. Default is "partials".
+	PartialsDir string
+
+	// Prefix is prepended to template names, eg. "emails/".
+	Prefix string
+
+	// ParseOptions are the options used to parse templates and partials.
+	ParseOptions ParseOptions
+}
+
+// defaultExtensions are the default extensions of template files
+var defaultExtensions = []string{".hbs", ".handlebars"}
+
+// ParseFS parses the template files of given file system, eg. an embed.FS, and returns them indexed by name.
+//
+// A template is named after its path relative to Root, without extension, and with Prefix, eg. "admin/users" for "admin/users.hbs". Files of PartialsDir are not returned: they are registered as partials of all templates, named after their path relative to PartialsDir, without extension, eg. "header" for "partials/header.hbs".
+//
+// Parse errors are prefixed with the path of the invalid file.
+func ParseFS(fsys fs.FS, opts FSOptions) (map[string]*Template, error) {
+	if opts.Root != "" {
+		sub, err := fs.Sub(fsys, opts.Root)
+		if err != nil {
+			return nil, err
+		}
+
+		fsys = sub
+	}
+
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = defaultExtensions
+	}
+
+	partialsDir := opts.PartialsDir
+	if partialsDir == "" {
+		partialsDir = "partials"
+	}
+
+	partialsDir = path.Clean(partialsDir) + "/"
+
+	result := make(map[string]*Template)
+	partials := make(map[string]*Template)
+
+	err := fs.WalkDir(fsys, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if (err != nil) || entry.IsDir() {
+			return err
+		}
+
+		name, ok := trimExtension(filePath, extensions)
+		if !ok {
+			return nil
+		}
+
+		source, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+
+		tpl, err := ParseWithOptions(string(source), opts.ParseOptions)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+
+		if strings.HasPrefix(name, partialsDir) {
+			partials[strings.TrimPrefix(name, partialsDir)] = tpl
+		} else {
+			result[opts.Prefix+name] = tpl
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tpl := range result {
+		for name, partial := range partials {
+			tpl.RegisterPartialTemplate(name, partial)
+		}
+	}
+
+	return result, nil
+}
+
+// trimExtension returns given file path without its extension, and true if that extension is one of given ones
+func trimExtension(filePath string, extensions []string) (string, bool) {
+	for _, ext := range extensions {
+		if strings.HasSuffix(filePath, ext) {
+			return strings.TrimSuffix(filePath, ext), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,73 @@
+package raymond
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"templates/page.hbs":               {Data: []byte("{{> header}}<p>{{body}}</p>")},
+		"templates/admin/users.handlebars": {Data: []byte("{{#each users}}{{> user}}{{/each}}")},
+		"templates/partials/header.hbs":    {Data: []byte("<h1>{{title}}</h1>")},
+		"templates/partials/user.hbs":      {Data: []byte("<li>{{> name}}</li>")},
+		"templates/partials/name.hbs":      {Data: []byte("{{name}}")},
+		"templates/README.md":              {Data: []byte("{{not a template")},
+	}
+
+	templates, err := ParseFS(fsys, FSOptions{Root: "templates"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if strings.Join(names, ",") != "admin/users,page" {
+		t.Fatalf("Unexpected templates: %v", names)
+	}
+
+	if output := templates["page"].MustExec(map[string]string{"title": "Hello", "body": "World"}); output != "<h1>Hello</h1><p>World</p>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	ctx := map[string]interface{}{"users": []map[string]string{{"name": "foo"}, {"name": "bar"}}}
+	if output := templates["admin/users"].MustExec(ctx); output != "<li>foo</li><li>bar</li>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestParseFSOptions(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"welcome.mustache":              {Data: []byte("{{> layout/header}}Welcome")},
+		"page.hbs":                      {Data: []byte("{{not a template")},
+		"shared/layout/header.mustache": {Data: []byte("Hi! ")},
+	}
+
+	templates, err := ParseFS(fsys, FSOptions{Extensions: []string{".mustache"}, PartialsDir: "shared", Prefix: "emails/"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if (len(templates) != 1) || (templates["emails/welcome"] == nil) {
+		t.Fatalf("Unexpected templates: %v", templates)
+	}
+
+	if output := templates["emails/welcome"].MustExec(nil); output != "Hi! Welcome" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if _, err := ParseFS(fsys, FSOptions{}); (err == nil) || !strings.HasPrefix(err.Error(), "page.hbs: ") {
+		t.Errorf("Expected a parse error prefixed by file path, got: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package raymond
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode"
+)
+
+// FuncHelper adapts a text/template function to a helper.
+//
+// The returned helper accepts any number of arguments, converts them to the types expected by the function (numbers, strings, booleans and slices are converted), and calls it. As with text/template, the function must return one value, or a value and an error: a non-nil error aborts template evaluation.
+//
+// It panics if fn is not a valid text/template function.
+func FuncHelper(fn interface{}) interface{} {
+	funcVal := reflect.ValueOf(fn)
+	ensureValidFunc(funcVal)
+
+	return func(params ...interface{}) interface{} {
+		return callTemplateFunc(funcVal, params)
+	}
+}
+
+// RegisterFuncMap registers all functions of given text/template function map as global helpers, thanks to FuncHelper().
+//
+// Functions named like an already registered helper are skipped, so builtin helpers keep precedence. That permits to import a whole library of functions, for example Sprig ones:
+//
+//	raymond.RegisterFuncMap(sprig.TxtFuncMap())
+func RegisterFuncMap(funcs map[string]interface{}) {
+	for name, fn := range funcs {
+		if findHelper(name) != zero {
+			continue
+		}
+
+		RegisterHelper(name, FuncHelper(fn))
+	}
+}
+
+// RegisterFuncMap registers all functions of given text/template function map as helpers for that template, thanks to FuncHelper().
+//
+// Functions named like an already registered helper (for that template or globally) are skipped, so builtin helpers keep precedence.
+func (tpl *Template) RegisterFuncMap(funcs map[string]interface{}) {
+	for name, fn := range funcs {
+		if (tpl.findHelper(name) != zero) || (findHelper(name) != zero) {
+			continue
+		}
+
+		tpl.RegisterHelper(name, FuncHelper(fn))
+	}
+}
+
+// FuncMap returns all global helpers as a text/template function map, so that they can be called from text/template and html/template templates. This eases the incremental migration of projects that use both engines:
+//
+//	tpl := template.New("page").Funcs(raymond.FuncMap())
+//
+// Functions pass their arguments to helpers, and return helper errors. Safe strings are returned as html/template HTML, so that html/template does not escape them. Hash arguments can't be passed, and block helpers can't be called. Helpers whose name is not a valid function name, like namespaced helpers, are skipped.
+func FuncMap() map[string]interface{} {
+	return funcMap(nil, helpers.load())
+}
+
+// FuncMap returns all helpers registered for that template and globally as a text/template function map, template helpers taking precedence. See FuncMap().
+func (tpl *Template) FuncMap() map[string]interface{} {
+	result := FuncMap()
+
+	for name, fn := range funcMap(tpl, tpl.helpers.load()) {
+		result[name] = fn
+	}
+
+	return result
+}
+
+// funcMap returns given helpers as text/template functions, that evaluate them with given template
+func funcMap(tpl *Template, helpers map[string]reflect.Value) map[string]interface{} {
+	if tpl == nil {
+		// global helpers only
+		tpl = newTemplate("")
+	}
+
+	result := make(map[string]interface{}, len(helpers))
+
+	for name, helper := range helpers {
+		if !isFuncName(name) {
+			continue
+		}
+
+		name, helper := name, helper
+
+		result[name] = func(params ...interface{}) (result interface{}, err error) {
+			v := newEvalVisitor(tpl, nil, nil)
+			defer v.release()
+
+			defer errRecover(&err)
+
+			options := newOptions(v, params, nil)
+
+			if middlewares := tpl.helperMiddlewares(); len(middlewares) > 0 {
+				result = v.callHelperMiddlewares(middlewares, name, helper, options)
+			} else {
+				result = v.callHelperFunc(name, helper, options)
+			}
+
+			if str, ok := result.(SafeString); ok {
+				result = template.HTML(str)
+			}
+
+			return result, nil
+		}
+	}
+
+	return result
+}
+
+// isFuncName returns true if given name is a valid text/template function name
+func isFuncName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		if (r != '_') && !unicode.IsLetter(r) && ((i == 0) || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ensureValidFunc panics if given value is not a valid text/template function
+func ensureValidFunc(funcVal reflect.Value) {
+	if funcVal.Kind() != reflect.Func {
+		panic(fmt.Errorf("Template function must be a function: %s", funcVal.Type()))
+	}
+
+	funcType := funcVal.Type()
+
+	switch funcType.NumOut() {
+	case 1:
+		// ok
+	case 2:
+		if funcType.Out(1) != errorType {
+			panic(fmt.Errorf("Second value returned by template function must be an error: %s", funcType))
+		}
+	default:
+		panic(fmt.Errorf("Template function must return one value, or a value and an error: %s", funcType))
+	}
+}
+
+// callTemplateFunc calls a text/template function with given parameters
+func callTemplateFunc(funcVal reflect.Value, params []interface{}) interface{} {
+	funcType := funcVal.Type()
+	numIn := funcType.NumIn()
+
+	if funcType.IsVariadic() {
+		if len(params) < numIn-1 {
+			panic(fmt.Errorf("Template function called with wrong number of arguments, needed at least %d but got %d", numIn-1, len(params)))
+		}
+	} else if len(params) != numIn {
+		panic(fmt.Errorf("Template function called with wrong number of arguments, needed %d but got %d", numIn, len(params)))
+	}
+
+	args := make([]reflect.Value, len(params))
+	for i, param := range params {
+		var argType reflect.Type
+		if funcType.IsVariadic() && (i >= numIn-1) {
+			argType = funcType.In(numIn - 1).Elem()
+		} else {
+			argType = funcType.In(i)
+		}
+
+		arg, err := convertArg(param, argType)
+		if err != nil {
+			panic(fmt.Errorf("Template function argument %d: %s", i, err))
+		}
+
+		args[i] = arg
+	}
+
+	result := funcVal.Call(args)
+
+	if len(result) == 2 && !result[1].IsNil() {
+		panic(result[1].Interface().(error))
+	}
+
+	return result[0].Interface()
+}
+
+// convertArg converts given value to given type
+func convertArg(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	val := reflect.ValueOf(value)
+
+	if !val.IsValid() {
+		return reflect.Zero(typ), nil
+	}
+
+	if val.Type().AssignableTo(typ) {
+		return val, nil
+	}
+
+	val, _ = indirect(val)
+	if !val.IsValid() {
+		return reflect.Zero(typ), nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(strValue(val)).Convert(typ), nil
+	case reflect.Bool:
+		truth, _ := isTrueValue(val)
+		return reflect.ValueOf(truth).Convert(typ), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if val.Kind() == reflect.String {
+			f, err := strconv.ParseFloat(val.String(), 64)
+			if err != nil {
+				return zero, fmt.Errorf("can't convert %q to %s", val.String(), typ)
+			}
+			val = reflect.ValueOf(f)
+		}
+
+		return convertNumber(val, typ)
+	case reflect.Slice:
+		if (val.Kind() == reflect.Slice) || (val.Kind() == reflect.Array) {
+			result := reflect.MakeSlice(typ, val.Len(), val.Len())
+			for i := 0; i < val.Len(); i++ {
+				elem, err := convertArg(val.Index(i).Interface(), typ.Elem())
+				if err != nil {
+					return zero, err
+				}
+				result.Index(i).Set(elem)
+			}
+			return result, nil
+		}
+	}
+
+	if val.Type().AssignableTo(typ) {
+		return val, nil
+	}
+
+	if val.Type().ConvertibleTo(typ) {
+		return val.Convert(typ), nil
+	}
+
+	return zero, fmt.Errorf("can't convert %s to %s", val.Type(), typ)
+}
+
+// convertNumber converts given numeric value to given numeric type, and returns an error if the value is not an integer while an integer is expected, or if it overflows that type
+func convertNumber(val reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	if !val.Type().ConvertibleTo(typ) {
+		return zero, fmt.Errorf("can't convert %s to %s", val.Type(), typ)
+	}
+
+	result := reflect.New(typ).Elem()
+	overflow := false
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			overflow = result.OverflowInt(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			overflow = (val.Uint() > math.MaxInt64) || result.OverflowInt(int64(val.Uint()))
+		case reflect.Float32, reflect.Float64:
+			f := val.Float()
+			if f != math.Trunc(f) {
+				return zero, fmt.Errorf("can't convert %v to %s, it is not an integer", f, typ)
+			}
+
+			// float64(math.MaxInt64) is rounded up to a power of two, that overflows
+			overflow = (f < math.MinInt64) || (f >= math.MaxInt64) || result.OverflowInt(int64(f))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			overflow = (val.Int() < 0) || result.OverflowUint(uint64(val.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			overflow = result.OverflowUint(val.Uint())
+		case reflect.Float32, reflect.Float64:
+			f := val.Float()
+			if f != math.Trunc(f) {
+				return zero, fmt.Errorf("can't convert %v to %s, it is not an integer", f, typ)
+			}
+
+			overflow = (f < 0) || (f >= math.MaxUint64) || result.OverflowUint(uint64(f))
+		}
+	case reflect.Float32:
+		if (val.Kind() == reflect.Float64) && !math.IsInf(val.Float(), 0) {
+			overflow = result.OverflowFloat(val.Float())
+		}
+	}
+
+	if overflow {
+		return zero, fmt.Errorf("can't convert %v to %s, it is out of range", val, typ)
+	}
+
+	return val.Convert(typ), nil
+}
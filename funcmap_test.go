@@ -0,0 +1,214 @@
+package raymond
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+)
+
+// a subset of functions mimicking Sprig ones
+var testFuncMap = map[string]interface{}{
+	"upper":  strings.ToUpper,
+	"repeat": func(count int, str string) string { return strings.Repeat(str, count) },
+	"add": func(a, b float64) float64 {
+		return a + b
+	},
+	"join": func(sep string, v []string) string { return strings.Join(v, sep) },
+	"list": func(v ...interface{}) []interface{} { return v },
+	"fail": func() (string, error) { return "", errors.New("failure") },
+	"safe": func(s string) (string, error) { return s, nil },
+	"if":   func() string { return "should not override builtin helper" },
+	"byte": func(b uint8) uint8 { return b },
+	"int8": func(i int8) int8 { return i },
+	"f32":  func(f float32) float32 { return f },
+}
+
+var funcMapTests = []Test{
+	{
+		"template function",
+		`{{upper name}}`,
+		map[string]interface{}{"name": "foo"},
+		nil, nil, nil,
+		`FOO`,
+	},
+	{
+		"template function with number conversion",
+		`{{repeat nb "ab"}} {{add 1 "2.5"}}`,
+		map[string]interface{}{"nb": 3.0},
+		nil, nil, nil,
+		`ababab 3.5`,
+	},
+	{
+		"template function with integer conversions",
+		`{{repeat "2" "ab"}} {{byte 255}} {{byte big}} {{int8 -128}} {{int8 "1e2"}} {{f32 1.5}}`,
+		map[string]interface{}{"big": uint64(200)},
+		nil, nil, nil,
+		`abab 255 200 -128 100 1.5`,
+	},
+	{
+		"template function with slice conversion",
+		`{{join "-" items}}`,
+		map[string]interface{}{"items": []interface{}{"a", 1, true}},
+		nil, nil, nil,
+		`a-1-true`,
+	},
+	{
+		"variadic template function in subexpression",
+		`{{join ", " (list "a" "b" 3)}}`,
+		nil, nil, nil, nil,
+		`a, b, 3`,
+	},
+	{
+		"template function returning a nil error",
+		`{{safe "<b>"}}`,
+		nil, nil, nil, nil,
+		`&lt;b&gt;`,
+	},
+	{
+		"template function does not override builtin helper",
+		`{{#if true}}yes{{/if}}`,
+		nil, nil, nil, nil,
+		`yes`,
+	},
+}
+
+var funcMapErrors = []Test{
+	{
+		"template function returning an error",
+		`{{fail}}`,
+		nil, nil, nil, nil,
+		`failure`,
+	},
+	{
+		"template function with wrong number of arguments",
+		`{{upper}}`,
+		nil, nil, nil, nil,
+		`Template function called with wrong number of arguments, needed 1 but got 0`,
+	},
+	{
+		"template function with invalid argument",
+		`{{add 1 "foo"}}`,
+		nil, nil, nil, nil,
+		`can't convert "foo" to float64`,
+	},
+	{
+		"template function with non-integral argument",
+		`{{repeat 3.7 "ab"}}`,
+		nil, nil, nil, nil,
+		`can't convert 3.7 to int, it is not an integer`,
+	},
+	{
+		"template function with non-integral string argument",
+		`{{byte "2.5"}}`,
+		nil, nil, nil, nil,
+		`can't convert 2.5 to uint8, it is not an integer`,
+	},
+	{
+		"template function with overflowing argument",
+		`{{byte 256}}`,
+		nil, nil, nil, nil,
+		`can't convert 256 to uint8, it is out of range`,
+	},
+	{
+		"template function with negative unsigned argument",
+		`{{byte -1}}`,
+		nil, nil, nil, nil,
+		`can't convert -1 to uint8, it is out of range`,
+	},
+	{
+		"template function with overflowing signed argument",
+		`{{int8 big}}`,
+		map[string]interface{}{"big": uint64(1 << 63)},
+		nil, nil, nil,
+		`can't convert 9223372036854775808 to int8, it is out of range`,
+	},
+	{
+		"template function with overflowing float argument",
+		`{{repeat 1e19 "ab"}}`,
+		nil, nil, nil, nil,
+		`can't convert 1e+19 to int, it is out of range`,
+	},
+	{
+		"template function with overflowing float32 argument",
+		`{{f32 1e39}}`,
+		nil, nil, nil, nil,
+		`can't convert 1e+39 to float32, it is out of range`,
+	},
+}
+
+func TestFuncMap(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range funcMapTests {
+		tpl := MustParse(test.input)
+		tpl.RegisterFuncMap(testFuncMap)
+
+		output, err := tpl.Exec(test.data)
+		if err != nil {
+			t.Errorf("Test '%s' failed\ninput:\n\t'%s'\nerror:\n\t%s", test.name, test.input, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t'%s'\nexpected\n\t%q\ngot\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestFuncMapErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range funcMapErrors {
+		tpl := MustParse(test.input)
+		tpl.RegisterFuncMap(testFuncMap)
+
+		_, err := tpl.Exec(test.data)
+		if err == nil {
+			t.Errorf("Test '%s' failed - Error expected", test.name)
+		} else if !strings.Contains(err.Error(), test.output.(string)) {
+			t.Errorf("Test '%s' failed - Incorrect error returned\nexpected\n\t%q\ngot\n\t%q", test.name, test.output, err)
+		}
+	}
+}
+
+func TestFuncHelperInvalid(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("FuncHelper should panic with a function returning two values that are not an error")
+		}
+	}()
+
+	FuncHelper(func() (string, string) { return "", "" })
+}
+
+func TestExportFuncMap(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("")
+	tpl.RegisterHelper("bold", func(s string) SafeString { return SafeString("<b>" + Escape(s) + "</b>") })
+	tpl.RegisterHelper("fail", func() string { panic(errors.New("boom")) })
+	tpl.RegisterHelper("concat", func(a, b string) string { return a + "|" + b })
+
+	html := htmltemplate.Must(htmltemplate.New("test").Funcs(tpl.FuncMap()).Parse(`{{bold .}} {{concat "a" "b"}}`))
+
+	var buf bytes.Buffer
+	if err := html.Execute(&buf, "<i>"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// template helper shadows global helper, and safe strings are not escaped
+	if expected := "<b>&lt;i&gt;</b> a|b"; buf.String() != expected {
+		t.Errorf("Unexpected output\nexpected\n\t%q\ngot\n\t%q", expected, buf.String())
+	}
+
+	text := texttemplate.Must(texttemplate.New("test").Funcs(tpl.FuncMap()).Parse(`{{fail}}`))
+	if err := text.Execute(&buf, nil); (err == nil) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected helper error, got: %v", err)
+	}
+
+	if _, ok := FuncMap()["bold"]; ok {
+		t.Errorf("Expected template helper not to be exported with global helpers")
+	}
+}
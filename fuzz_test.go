@@ -0,0 +1,215 @@
+//go:build go1.18
+
+package raymond
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fuzzPolicy enables all limits, so that evaluations of fuzzed templates are bounded
+var fuzzPolicy = SecurityPolicy{
+	MaxInput:      64 << 10,
+	MaxDepth:      16,
+	MaxIterations: 10000,
+	MaxOutput:     1 << 20,
+	Timeout:       time.Second,
+	Sandbox: &SandboxPolicy{
+		Helpers: []string{
+			"if", "unless", "with", "each", "lookup", "equal", "times", "range", "concat", "indent", "nindent",
+			"switch", "case", "default", "nonce", "randomInt", "randomString", "table", "table.row", "table.cell",
+			"toYaml", "fromYaml",
+		},
+		Partials:       []string{"self", "item"},
+		AllowUnescaped: true,
+	},
+}
+
+// fuzzMaxDuration is the maximum duration of a fuzzed parse and evaluation, far above the Timeout limit, that detects hangs
+const fuzzMaxDuration = 10 * time.Second
+
+// fuzzNames are the keys of the maps of fuzzed contexts, so that templates have a chance to resolve them
+var fuzzNames = []string{"a", "b", "foo", "bar", "items", "name", "this", "0", "1", "@index", "length", ""}
+
+// fuzzContext decodes a context from given fuzzed data
+type fuzzContext struct {
+	data []byte
+}
+
+// next returns next byte of data, or zero if there is nothing left
+func (c *fuzzContext) next() int {
+	if len(c.data) == 0 {
+		return 0
+	}
+
+	result := c.data[0]
+	c.data = c.data[1:]
+
+	return int(result)
+}
+
+// value decodes a value, nested at given depth
+func (c *fuzzContext) value(depth int) interface{} {
+	kind := c.next() % 10
+	if (depth > 4) && (kind >= 7) {
+		kind = 4
+	}
+
+	switch kind {
+	case 0:
+		return nil
+	case 1:
+		return c.next()%2 == 1
+	case 2:
+		return c.next() - 128
+	case 3:
+		return []float64{0, -0.5, 1.5, 1e21, math.Inf(1), math.NaN()}[c.next()%6]
+	case 4:
+		n := c.next() % 16
+		if n > len(c.data) {
+			n = len(c.data)
+		}
+
+		result := string(c.data[:n])
+		c.data = c.data[n:]
+
+		return result
+	case 5:
+		return SafeString(fuzzNames[c.next()%len(fuzzNames)])
+	case 6:
+		return func(options *Options) string { return options.Fn() }
+	case 7:
+		result := make([]interface{}, c.next()%5)
+		for i := range result {
+			result[i] = c.value(depth + 1)
+		}
+
+		return result
+	case 8:
+		result := make(map[string]interface{})
+		for i := c.next() % 5; i > 0; i-- {
+			result[fuzzNames[c.next()%len(fuzzNames)]] = c.value(depth + 1)
+		}
+
+		return result
+	default:
+		return &struct {
+			Name  string
+			Items []interface{}
+			Foo   interface{}
+		}{
+			Name:  fuzzNames[c.next()%len(fuzzNames)],
+			Items: []interface{}{c.value(depth + 1), c.value(depth + 1)},
+			Foo:   c.value(depth + 1),
+		}
+	}
+}
+
+// fuzzSeeds adds the templates of the handlebars.js and Mustache specs to the seed corpus
+func fuzzSeeds(f *testing.F) {
+	f.Add("Hello {{name}}!", []byte{8, 2, 4, 4, 5, 'w', 'o', 'r', 'l', 'd'})
+	f.Add("{{#each items}}{{@index}}: {{> item}}{{/each}}", []byte{8, 1, 2, 7, 3, 4, 1, 'x', 2, 200, 8, 0})
+	f.Add("{{#with foo as |x|}}{{x.name}}{{else}}none{{/with}}{{> self}}", []byte{9, 3})
+	f.Add("{{#times 3}}{{#range 1 3}}{{concat @index this}}{{/range}}{{/times}}", []byte{})
+	f.Add("{{=<% %>=}}<%#a%><%.%><%/a%>", []byte{8, 1, 0, 6})
+
+	paths, _ := filepath.Glob(filepath.Join("testdata", "handlebars", "*.json"))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		var file handlebarsSpecFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			f.Fatal(err)
+		}
+
+		for _, test := range file.Tests {
+			f.Add(test.Template, []byte(test.Name))
+		}
+	}
+}
+
+// fuzzParseOptions returns the parse options selected by given fuzzed data
+func fuzzParseOptions(data []byte) ParseOptions {
+	result := ParseOptions{Policy: &fuzzPolicy}
+
+	if len(data) > 0 {
+		result.Mustache = data[0]&0x80 != 0
+		result.ContextualEscaping = data[0]&0x40 != 0
+	}
+
+	return result
+}
+
+// fuzzExec parses given template with given options and renders it with a context decoded from given data
+func fuzzExec(t *testing.T, source string, data []byte, opts ParseOptions) (*Template, string, error) {
+	tpl, err := ParseWithOptions(source, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tpl.RegisterPartial("self", source)
+	tpl.RegisterPartial("item", "[{{.}}]")
+
+	ctx := (&fuzzContext{data: data}).value(0)
+
+	output, err := tpl.ExecWithOptions(ctx, ExecOptions{Deterministic: true, Nonce: "fuzz"})
+	if (err == nil) && (int64(len(output)) > fuzzPolicy.MaxOutput) {
+		t.Errorf("Output of %d bytes exceeds limit", len(output))
+	}
+
+	return tpl, output, err
+}
+
+func FuzzRender(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, source string, data []byte) {
+		start := time.Now()
+
+		fuzzExec(t, source, data, fuzzParseOptions(data))
+
+		if elapsed := time.Since(start); elapsed > fuzzMaxDuration {
+			t.Errorf("Render took %s", elapsed)
+		}
+	})
+}
+
+func FuzzBinaryRender(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, source string, data []byte) {
+		opts := ParseOptions{Policy: &fuzzPolicy}
+
+		tpl, output, err := fuzzExec(t, source, data, opts)
+		if tpl == nil {
+			return
+		}
+
+		bin, errBin := tpl.MarshalBinary()
+		if errBin != nil {
+			t.Fatalf("Failed to marshal parsed template: %s", errBin)
+		}
+
+		loaded, errBin := ParseBinaryWithOptions(bin, opts)
+		if errBin != nil {
+			t.Fatalf("Failed to load binary template: %s", errBin)
+		}
+
+		loaded.RegisterPartial("self", source)
+		loaded.RegisterPartial("item", "[{{.}}]")
+
+		ctx := (&fuzzContext{data: data}).value(0)
+
+		outputBin, errBin := loaded.ExecWithOptions(ctx, ExecOptions{Deterministic: true, Nonce: "fuzz"})
+		if (err == nil) && ((errBin != nil) || (outputBin != output)) {
+			t.Errorf("Binary template renders differently:\n\t%q\n\t%q (%v)", output, outputBin, errBin)
+		}
+	})
+}
@@ -253,7 +253,7 @@ var basicTests = []Test{
 		"{{awesome}}",
 		map[string]string{"awesome": "&\"'`\\<>"},
 		nil, nil, nil,
-		"&amp;&quot;&apos;`\\&lt;&gt;",
+		"&amp;&quot;&#x27;&#x60;\\&lt;&gt;",
 	},
 	{
 		"escaping expressions (9)",
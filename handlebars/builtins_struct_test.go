@@ -0,0 +1,23 @@
+//go:build !tinygo && !raymond_tiny
+
+package handlebars
+
+import "testing"
+
+// builtinsStructTests need struct fields to be resolved, which is not supported in tiny build mode
+var builtinsStructTests = []Test{
+	{
+		"#lookup - should lookup struct field",
+		"{{#each goodbyes}}{{lookup ../data .}}{{/each}}",
+		map[string]interface{}{"goodbyes": []string{"Foo", "Bar"}, "data": struct {
+			Foo string
+			Bar string
+		}{"baz", "bat"}},
+		nil, nil, nil,
+		"bazbat",
+	},
+}
+
+func TestBuiltinsStruct(t *testing.T) {
+	launchTests(t, builtinsStructTests)
+}
@@ -310,16 +310,6 @@ var builtinsTests = []Test{
 		nil, nil, nil,
 		"bazbat",
 	},
-	{
-		"#lookup - should lookup struct field",
-		"{{#each goodbyes}}{{lookup ../data .}}{{/each}}",
-		map[string]interface{}{"goodbyes": []string{"Foo", "Bar"}, "data": struct {
-			Foo string
-			Bar string
-		}{"baz", "bat"}},
-		nil, nil, nil,
-		"bazbat",
-	},
 	{
 		"#lookup - should lookup arbitrary content",
 		"{{#each goodbyes}}{{lookup ../data .}}{{/each}}",
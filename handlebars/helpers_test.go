@@ -265,7 +265,7 @@ var helpersTests = []Test{
 		nil,
 		map[string]interface{}{"list": listHelper},
 		nil,
-		`<p>Nobody&apos;s here</p>`,
+		`<p>Nobody&#x27;s here</p>`,
 	},
 
 	{
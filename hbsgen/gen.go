@@ -0,0 +1,601 @@
+package hbsgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+//
+// Output
+//
+
+// printf writes generated code
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// errorf records a generation error at given node, only the first error is kept
+func (g *generator) errorf(node ast.Node, format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf("%s:%d: %s", g.tplName, node.Location().Line, fmt.Sprintf(format, args...))
+	}
+}
+
+// newVar returns a new local variable name
+func (g *generator) newVar(prefix string) string {
+	g.vars++
+	return prefix + strconv.Itoa(g.vars)
+}
+
+// write generates code that writes given Go string expression
+func (g *generator) write(expr string) {
+	g.printf("if _, err := io.WriteString(w, %s); err != nil {\nreturn err\n}\n", expr)
+}
+
+// ifGuards generates the opening of a condition block checking given guards, and returns true if a block was opened
+func (g *generator) ifGuards(guards []string) bool {
+	if len(guards) == 0 {
+		return false
+	}
+
+	g.printf("if %s {\n", strings.Join(guards, " && "))
+
+	return true
+}
+
+//
+// Statements
+//
+
+// genProgram generates code for given program
+func (g *generator) genProgram(program *ast.Program, s *scope) {
+	if program == nil {
+		return
+	}
+
+	if len(program.BlockParams) > 0 {
+		g.errorf(program, "block parameters are not supported")
+		return
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			if n.Value != "" {
+				g.write(strconv.Quote(n.Value))
+			}
+		case *ast.CommentStatement:
+			// ignore comments
+		case *ast.MustacheStatement:
+			g.genMustache(n, s)
+		case *ast.BlockStatement:
+			g.genBlock(n, s)
+		case *ast.PartialStatement:
+			g.genPartialCall(n, s)
+		default:
+			g.errorf(node, "unsupported statement: %s", node)
+		}
+	}
+}
+
+// genMustache generates code for given mustache statement
+func (g *generator) genMustache(node *ast.MustacheStatement, s *scope) {
+	path := g.exprPath(node.Expression)
+	if path == nil {
+		return
+	}
+
+	val, ok := g.resolvePath(path, s)
+	if !ok {
+		return
+	}
+
+	opened := g.ifGuards(val.guards)
+	g.write(g.strExpr(val, !node.Unescaped))
+	if opened {
+		g.printf("}\n")
+	}
+}
+
+// genBlock generates code for given block statement
+func (g *generator) genBlock(node *ast.BlockStatement, s *scope) {
+	name := node.Expression.HelperName()
+
+	switch name {
+	case "if", "unless", "each", "with":
+	default:
+		g.errorf(node, "unsupported block: %s", node.Expression.Canonical())
+		return
+	}
+
+	if (len(node.Expression.Params) != 1) || (node.Expression.Hash != nil) {
+		g.errorf(node, "%s block expects exactly one parameter", name)
+		return
+	}
+
+	path, ok := node.Expression.Params[0].(*ast.PathExpression)
+	if !ok {
+		g.errorf(node, "%s block parameter must be a path", name)
+		return
+	}
+
+	val, ok := g.resolvePath(path, s)
+	if !ok {
+		return
+	}
+
+	switch name {
+	case "if":
+		g.printf("if %s {\n", g.truthExpr(val))
+		g.genProgram(node.Program, s)
+	case "unless":
+		g.printf("if !(%s) {\n", g.truthExpr(val))
+		g.genProgram(node.Program, s)
+	case "each":
+		g.genEach(node, val, s)
+	case "with":
+		g.genWith(node, val, s)
+	}
+
+	if node.Inverse != nil {
+		g.printf("} else {\n")
+		g.genProgram(node.Inverse, s)
+	}
+
+	g.printf("}\n")
+}
+
+// genEach generates the opening and the body of an #each block
+func (g *generator) genEach(node *ast.BlockStatement, val value, s *scope) {
+	val = g.deref(val)
+	if val.typ.kind != kindSlice {
+		g.errorf(node, "each block only supports slices and arrays, got: %s", val.typ)
+		return
+	}
+
+	index := g.newVar("i")
+	item := g.newVar("v")
+
+	g.printf("if %s {\n", strings.Join(append(val.guards, "len("+val.expr+") > 0"), " && "))
+	g.printf("for %s := range %s {\n", index, val.expr)
+
+	itemVal := value{expr: item, typ: val.typ.elem}
+	if (val.typ.elem.kind == kindStruct) && (val.addressable || !val.typ.array) {
+		// avoid copying structs
+		g.printf("%s := &%s[%s]\n", item, val.expr, index)
+		itemVal.typ = &goType{kind: kindPtr, elem: val.typ.elem}
+		itemVal.nonNil = true
+		itemVal.addressable = true
+	} else {
+		g.printf("%s := %s[%s]\n", item, val.expr, index)
+	}
+	g.printf("_ = %s\n", item)
+
+	g.genProgram(node.Program, &scope{
+		parent: s,
+		val:    itemVal,
+		index:  index,
+		length: "len(" + val.expr + ")",
+	})
+
+	g.printf("}\n")
+}
+
+// genWith generates the opening and the body of a #with block
+func (g *generator) genWith(node *ast.BlockStatement, val value, s *scope) {
+	item := g.newVar("v")
+
+	g.printf("if %s {\n", g.truthExpr(val))
+
+	itemVal := value{expr: item, typ: val.typ, nonNil: val.nonNil}
+	if (val.typ.kind == kindStruct) && val.addressable {
+		// avoid copying structs
+		g.printf("%s := &%s\n", item, val.expr)
+		itemVal.typ = &goType{kind: kindPtr, elem: val.typ}
+		itemVal.nonNil = true
+		itemVal.addressable = true
+	} else {
+		g.printf("%s := %s\n", item, val.expr)
+		if val.typ.kind == kindPtr {
+			// truth expression checked that pointer is not nil
+			itemVal.nonNil = true
+		}
+	}
+	g.printf("_ = %s\n", item)
+
+	// #with keeps current #each iteration data
+	g.genProgram(node.Program, &scope{
+		parent: s,
+		val:    itemVal,
+		index:  s.index,
+		length: s.length,
+	})
+}
+
+// genPartialCall generates code for given partial statement
+func (g *generator) genPartialCall(node *ast.PartialStatement, s *scope) {
+	name, ok := ast.HelperNameStr(node.Name)
+	if !ok {
+		g.errorf(node, "dynamic partials are not supported")
+		return
+	}
+
+	if _, ok := g.partials[name]; !ok {
+		g.errorf(node, "partial not found: %s", name)
+		return
+	}
+
+	if (len(node.Params) > 1) || (node.Hash != nil) {
+		g.errorf(node, "partial %s: only one context parameter is supported", name)
+		return
+	}
+
+	val := s.val
+	if len(node.Params) == 1 {
+		path, ok := node.Params[0].(*ast.PathExpression)
+		if !ok {
+			g.errorf(node, "partial %s: context parameter must be a path", name)
+			return
+		}
+
+		if val, ok = g.resolvePath(path, s); !ok {
+			return
+		}
+	}
+
+	// partial functions take a pointer to a struct
+	ptr := val.expr
+	typ := val.typ
+	switch {
+	case (typ.kind == kindPtr) && (typ.elem.kind == kindStruct):
+		typ = typ.elem
+	case (typ.kind == kindStruct) && val.addressable:
+		ptr = "&" + val.expr
+	case typ.kind == kindStruct:
+		tmp := g.newVar("v")
+		g.printf("%s := %s\n", tmp, val.expr)
+		ptr = "&" + tmp
+	default:
+		g.errorf(node, "partial %s: context must be a struct, got: %s", name, val.typ)
+		return
+	}
+
+	funcName := g.partialFunc(name, typ)
+
+	ctx := g.newVar("p")
+	if len(val.guards) == 0 {
+		g.printf("%s := %s\n", ctx, ptr)
+	} else {
+		g.printf("var %s *%s\n", ctx, typ.name)
+		g.ifGuards(val.guards)
+		g.printf("%s = %s\n}\n", ctx, ptr)
+	}
+
+	if node.Indent == "" {
+		g.printf("if err := %s(w, %s); err != nil {\nreturn err\n}\n", funcName, ctx)
+	} else {
+		g.imports["strings"] = true
+		g.imports["github.com/aymerick/raymond/hbsgen"] = true
+
+		buf := g.newVar("b")
+		g.printf("var %s strings.Builder\n", buf)
+		g.printf("if err := %s(&%s, %s); err != nil {\nreturn err\n}\n", funcName, buf, ctx)
+		g.write(fmt.Sprintf("hbsgen.Indent(%s.String(), %q)", buf, node.Indent))
+	}
+}
+
+// partialFunc returns the name of the function that renders given partial with given context type
+func (g *generator) partialFunc(name string, typ *goType) string {
+	key := name + "|" + typ.name
+	if result, ok := g.partialFuncs[key]; ok {
+		return result
+	}
+
+	// context type is added to function name only when partial is rendered with several types
+	result := "hbsPartial" + exportedName(name)
+	if g.hasPartialFunc(result) {
+		result += typ.name
+	}
+	for i := 2; g.hasPartialFunc(result); i++ {
+		result = fmt.Sprintf("hbsPartial%s%s%d", exportedName(name), typ.name, i)
+	}
+
+	g.partialFuncs[key] = result
+	g.pending = append(g.pending, pendingPartial{name: name, funcName: result, typ: typ})
+
+	return result
+}
+
+// hasPartialFunc returns true if a partial function with given name was already generated
+func (g *generator) hasPartialFunc(funcName string) bool {
+	for _, name := range g.partialFuncs {
+		if name == funcName {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// Expressions
+//
+
+// exprPath returns the path of given expression, or records an error if that expression is not a single path
+func (g *generator) exprPath(expr *ast.Expression) *ast.PathExpression {
+	if (len(expr.Params) > 0) || (expr.Hash != nil) {
+		g.errorf(expr, "helpers are not supported: %s", expr.Canonical())
+		return nil
+	}
+
+	path, ok := expr.Path.(*ast.PathExpression)
+	if !ok {
+		g.errorf(expr, "unsupported expression: %s", expr)
+		return nil
+	}
+
+	return path
+}
+
+// resolvePath returns the Go value corresponding to given path in given scope
+func (g *generator) resolvePath(path *ast.PathExpression, s *scope) (value, bool) {
+	parts := path.Parts
+
+	if path.Data {
+		return g.resolveData(path, s)
+	}
+
+	for i := 0; i < path.Depth; i++ {
+		if s.parent == nil {
+			g.errorf(path, "path %s goes beyond root context", path.Original)
+			return value{}, false
+		}
+
+		s = s.parent
+	}
+
+	return g.resolveParts(path, s.val, parts)
+}
+
+// resolveData returns the Go value corresponding to given data path
+func (g *generator) resolveData(path *ast.PathExpression, s *scope) (value, bool) {
+	name := path.Parts[0]
+
+	if name == "root" {
+		if g.root == nil {
+			g.errorf(path, "@root is not supported in partials")
+			return value{}, false
+		}
+
+		return g.resolveParts(path, g.root.val, path.Parts[1:])
+	}
+
+	if len(path.Parts) > 1 {
+		g.errorf(path, "unsupported data path: %s", path.Original)
+		return value{}, false
+	}
+
+	if s.index == "" {
+		g.errorf(path, "@%s used outside of an each block", name)
+		return value{}, false
+	}
+
+	switch name {
+	case "index":
+		return value{expr: s.index, typ: &goType{kind: kindBasic, name: "int"}}, true
+	case "first":
+		return value{expr: "(" + s.index + " == 0)", typ: &goType{kind: kindBasic, name: "bool"}}, true
+	case "last":
+		return value{expr: "(" + s.index + " == " + s.length + "-1)", typ: &goType{kind: kindBasic, name: "bool"}}, true
+	}
+
+	g.errorf(path, "unsupported data variable: @%s", name)
+	return value{}, false
+}
+
+// resolveParts returns the Go value corresponding to given path parts from given value
+func (g *generator) resolveParts(path *ast.PathExpression, val value, parts []string) (value, bool) {
+	for _, part := range parts {
+		// "[foo bar]"" => "foo bar"
+		if (len(part) >= 2) && (part[0] == '[') && (part[len(part)-1] == ']') {
+			part = part[1 : len(part)-1]
+		}
+
+		val = g.deref(val)
+
+		switch val.typ.kind {
+		case kindStruct:
+			m, ok := g.pkg.member(val.typ, part)
+			if !ok {
+				g.errorf(path, "%s has no field or method %s", val.typ, part)
+				return value{}, false
+			}
+
+			val = value{
+				expr:        val.expr + "." + m.sel,
+				typ:         m.typ,
+				guards:      val.guards,
+				addressable: val.addressable && !m.isFunc,
+			}
+		case kindMap:
+			if !val.typ.key.isString() {
+				g.errorf(path, "map keys must be strings, got: %s", val.typ.key)
+				return value{}, false
+			}
+
+			val = value{
+				expr:   val.expr + "[" + strconv.Quote(part) + "]",
+				typ:    val.typ.elem,
+				guards: val.guards,
+			}
+		case kindSlice:
+			index, err := strconv.Atoi(part)
+			if err != nil {
+				g.errorf(path, "invalid index %s for %s", part, val.typ)
+				return value{}, false
+			}
+
+			val = value{
+				expr:        fmt.Sprintf("%s[%d]", val.expr, index),
+				typ:         val.typ.elem,
+				guards:      append(val.guards, fmt.Sprintf("len(%s) > %d", val.expr, index)),
+				addressable: val.addressable,
+			}
+		default:
+			g.errorf(path, "can't resolve %s on %s", part, val.typ)
+			return value{}, false
+		}
+
+		// don't share guards slices
+		val.guards = append([]string(nil), val.guards...)
+	}
+
+	return val, true
+}
+
+// deref dereferences given value if it is a pointer, adding a guard if it may be nil
+func (g *generator) deref(val value) value {
+	for val.typ.kind == kindPtr {
+		if !val.nonNil {
+			val.guards = append(append([]string(nil), val.guards...), val.expr+" != nil")
+		}
+
+		if val.typ.elem.kind == kindStruct {
+			// selectors automatically dereference pointers to structs
+			val.typ = val.typ.elem
+		} else {
+			val.expr = "(*" + val.expr + ")"
+			val.typ = val.typ.elem
+		}
+
+		val.addressable = true
+		val.nonNil = false
+	}
+
+	return val
+}
+
+// truthExpr returns a Go boolean expression that is true if given value is truthy
+func (g *generator) truthExpr(val value) string {
+	if val.typ.kind == kindPtr {
+		if !val.nonNil {
+			val.guards = append(append([]string(nil), val.guards...), val.expr+" != nil")
+		}
+
+		if val.typ.elem.kind == kindStruct {
+			return g.conds(val.guards, "true")
+		}
+
+		val.nonNil = true
+		val = g.deref(val)
+	}
+
+	var expr string
+
+	switch val.typ.kind {
+	case kindBasic:
+		switch {
+		case val.typ.name == "bool":
+			expr = val.expr
+		case val.typ.name == "string":
+			expr = val.expr + ` != ""`
+		default:
+			expr = val.expr + " != 0"
+		}
+	case kindStruct:
+		expr = "true"
+	case kindSlice, kindMap:
+		expr = "len(" + val.expr + ") > 0"
+	default:
+		g.imports["github.com/aymerick/raymond"] = true
+		expr = "raymond.IsTrue(" + val.expr + ")"
+	}
+
+	return g.conds(val.guards, expr)
+}
+
+// conds joins guards and given condition
+func (g *generator) conds(guards []string, cond string) string {
+	if len(guards) == 0 {
+		return cond
+	}
+
+	if cond == "true" {
+		return strings.Join(guards, " && ")
+	}
+
+	return strings.Join(guards, " && ") + " && " + cond
+}
+
+// strExpr returns a Go string expression that outputs given value, escaped if requested
+func (g *generator) strExpr(val value, escape bool) string {
+	if (val.typ.kind == kindBasic) && !val.typ.named {
+		switch val.typ.name {
+		case "string":
+			return g.escape(val.expr, escape)
+		case "bool":
+			g.imports["strconv"] = true
+			return "strconv.FormatBool(" + val.expr + ")"
+		case "float32", "float64":
+			g.imports["strconv"] = true
+			return "strconv.FormatFloat(float64(" + val.expr + "), 'f', -1, 64)"
+		case "uint", "uint8", "uint16", "uint32", "uint64", "byte":
+			g.imports["strconv"] = true
+			return "strconv.FormatUint(uint64(" + val.expr + "), 10)"
+		default:
+			g.imports["strconv"] = true
+			return "strconv.FormatInt(int64(" + val.expr + "), 10)"
+		}
+	}
+
+	if (val.typ.kind == kindOpaque) && (val.typ.src == "raymond.SafeString") {
+		escape = false
+	}
+
+	g.imports["github.com/aymerick/raymond"] = true
+
+	return g.escape("raymond.Str("+val.expr+")", escape)
+}
+
+// escape returns a Go expression that escapes given string expression if requested
+func (g *generator) escape(expr string, escape bool) string {
+	if !escape {
+		return expr
+	}
+
+	g.imports["github.com/aymerick/raymond"] = true
+
+	return "raymond.Escape(" + expr + ")"
+}
+
+// exportedName converts given template name to an exported Go identifier
+//
+// example: "user-list" => "UserList"
+func exportedName(name string) string {
+	var result strings.Builder
+
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if (result.Len() == 0) && unicode.IsDigit(r) {
+			result.WriteRune('T')
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+
+		result.WriteRune(r)
+	}
+
+	return result.String()
+}
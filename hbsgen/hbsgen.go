@@ -0,0 +1,236 @@
+// Package hbsgen generates Go source code from handlebars templates.
+//
+// Each template is turned into a Go function that writes its output directly to an io.Writer, with a typed context struct. Context fields are resolved when generating code, so that templates are neither parsed nor evaluated with reflection at runtime.
+//
+// Only a subset of handlebars is supported: paths, the `if`, `unless`, `each` and `with` block helpers, the `@index`, `@first`, `@last` and `@root` data variables, and static partials. Custom helpers, subexpressions, hash arguments and block parameters are not supported, and generation fails with a descriptive error when a template uses them.
+package hbsgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+// Template is a template to generate code for.
+type Template struct {
+	// Name is used to name the generated function: a template named "user-list" is rendered with the RenderUserList() function.
+	Name string
+
+	// Source is the template source.
+	Source string
+
+	// Type is the name of the context struct type, that must be declared in generated package.
+	Type string
+}
+
+// Options represents the code generation options.
+type Options struct {
+	// Dir is the directory of the Go package that declares context types, and where generated code is meant to be written.
+	Dir string
+
+	// Package is the name of the generated package. It defaults to the name of the package found in Dir.
+	Package string
+
+	// Templates are the templates to generate code for.
+	Templates []Template
+
+	// Partials are the sources of partials used by templates, by name.
+	Partials map[string]string
+}
+
+// generator generates Go code
+type generator struct {
+	pkg      *pkgTypes
+	partials map[string]string
+
+	// generated partial functions, by partial name and context type
+	partialFuncs map[string]string
+	pending      []pendingPartial
+
+	imports map[string]bool
+	buf     bytes.Buffer
+	vars    int
+
+	// template being generated, used for error messages
+	tplName string
+	root    *scope
+
+	// first generation error
+	err error
+}
+
+// pendingPartial is a partial function that remains to be generated
+type pendingPartial struct {
+	name     string
+	funcName string
+	typ      *goType
+}
+
+// scope is an evaluation context
+type scope struct {
+	parent *scope
+	val    value
+
+	// set in #each blocks: Go expressions of iteration index and of iterated list length
+	index  string
+	length string
+}
+
+// value is a Go expression, with its type
+type value struct {
+	expr string
+	typ  *goType
+
+	// conditions that must be checked before evaluating expression, to avoid nil pointer dereferences and out of range indexes
+	guards []string
+
+	addressable bool
+
+	// true if this is a pointer that is known not to be nil
+	nonNil bool
+}
+
+// Generate generates Go source code for given templates.
+func Generate(opts Options) ([]byte, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgName := opts.Package
+	if pkgName == "" {
+		pkgName = pkg.name
+	}
+
+	g := &generator{
+		pkg:          pkg,
+		partials:     opts.Partials,
+		partialFuncs: make(map[string]string),
+		imports:      map[string]bool{"io": true},
+	}
+
+	for _, tpl := range opts.Templates {
+		if err := g.genTemplate(tpl); err != nil {
+			return nil, err
+		}
+	}
+
+	for len(g.pending) > 0 {
+		p := g.pending[0]
+		g.pending = g.pending[1:]
+
+		if err := g.genPartial(p); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+
+	out.WriteString("// Code generated by hbsgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkgName)
+
+	// standard library imports first
+	var std, others []string
+	for imp := range g.imports {
+		if strings.Contains(strings.SplitN(imp, "/", 2)[0], ".") {
+			others = append(others, imp)
+		} else {
+			std = append(std, imp)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	for _, imp := range std {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	if len(others) > 0 {
+		out.WriteString("\n")
+	}
+	for _, imp := range others {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+
+	result, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to format generated code: %s", err)
+	}
+
+	return result, nil
+}
+
+// genTemplate generates the render function of given template
+func (g *generator) genTemplate(tpl Template) error {
+	funcName := "Render" + exportedName(tpl.Name)
+	if funcName == "Render" {
+		return fmt.Errorf("Invalid template name: %q", tpl.Name)
+	}
+
+	typ, err := g.pkg.structType(tpl.Type)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tpl.Name, err)
+	}
+
+	program, err := parser.Parse(tpl.Source)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tpl.Name, err)
+	}
+
+	g.tplName = tpl.Name
+
+	g.printf("\n// %s renders the %s template.\n", funcName, tpl.Name)
+	g.genFunc(funcName, typ, program, true)
+
+	return g.err
+}
+
+// genPartial generates the function of given partial
+func (g *generator) genPartial(p pendingPartial) error {
+	program, err := parser.Parse(g.partials[p.name])
+	if err != nil {
+		return fmt.Errorf("%s: %s", p.name, err)
+	}
+
+	g.tplName = p.name
+
+	g.printf("\n// %s renders the %s partial.\n", p.funcName, p.name)
+	g.genFunc(p.funcName, p.typ, program, false)
+
+	return g.err
+}
+
+// genFunc generates a render function with given context type
+func (g *generator) genFunc(funcName string, typ *goType, program *ast.Program, isRoot bool) {
+	g.vars = 0
+
+	g.printf("func %s(w io.Writer, ctx *%s) error {\n", funcName, typ.name)
+	g.printf("if ctx == nil {\nctx = new(%s)\n}\n", typ.name)
+
+	root := &scope{val: value{
+		expr:        "ctx",
+		typ:         &goType{kind: kindPtr, elem: typ},
+		addressable: true,
+		nonNil:      true,
+	}}
+
+	g.root = nil
+	if isRoot {
+		g.root = root
+	}
+
+	g.genProgram(program, root)
+
+	g.printf("return nil\n}\n")
+}
@@ -0,0 +1,101 @@
+package hbsgen
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const exampleDir = "internal/example"
+
+// TestGenerateExample checks that the generated code of example package is up to date
+func TestGenerateExample(t *testing.T) {
+	source, err := ioutil.ReadFile(exampleDir + "/templates/page.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := ioutil.ReadFile(exampleDir + "/templates/partials/post.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := Generate(Options{
+		Dir:       exampleDir,
+		Templates: []Template{{Name: "page", Source: string(source), Type: "Page"}},
+		Partials:  map[string]string{"post": string(partial)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ioutil.ReadFile(exampleDir + "/example_hbs.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(code) != string(expected) {
+		t.Errorf("Generated code is outdated, run go generate in %s", exampleDir)
+	}
+}
+
+var generateErrors = []struct {
+	name   string
+	source string
+	err    string
+}{
+	{"helper call", `{{foo bar}}`, `page:1: helpers are not supported: foo`},
+	{"unknown field", "\n{{unknown}}", `page:2: Page has no field or method unknown`},
+	{"unknown block", `{{#foo}}{{/foo}}`, `unsupported block: foo`},
+	{"block params", `{{#each posts as |post|}}{{/each}}`, `block parameters are not supported`},
+	{"each on struct", `{{#each author}}{{/each}}`, `each block only supports slices and arrays, got: Person`},
+	{"index outside each", `{{@index}}`, `@index used outside of an each block`},
+	{"path beyond root", `{{../title}}`, `path ../title goes beyond root context`},
+	{"missing partial", `{{> missing}}`, `partial not found: missing`},
+	{"partial with string context", `{{> post title}}`, `context must be a struct, got: string`},
+	{"subexpression", `{{title (foo)}}`, `helpers are not supported`},
+	{"map with opaque values", `{{#each meta}}{{/each}}`, `each block only supports slices and arrays`},
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, test := range generateErrors {
+		_, err := Generate(Options{
+			Dir:       exampleDir,
+			Templates: []Template{{Name: "page", Source: test.source, Type: "Page"}},
+			Partials:  map[string]string{"post": "{{title}}"},
+		})
+
+		if err == nil {
+			t.Errorf("Test '%s' failed - Error expected", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test '%s' failed - Incorrect error returned\nexpected:\n\t%q\ngot:\n\t%q", test.name, test.err, err)
+		}
+	}
+}
+
+func TestGenerateUnknownType(t *testing.T) {
+	_, err := Generate(Options{
+		Dir:       exampleDir,
+		Templates: []Template{{Name: "page", Source: "", Type: "Unknown"}},
+	})
+
+	if (err == nil) || !strings.Contains(err.Error(), "Type Unknown not found in package example") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := map[string]string{
+		"page":       "Page",
+		"user-list":  "UserList",
+		"admin/home": "AdminHome",
+		"404":        "T404",
+		"été":        "Été",
+	}
+
+	for name, expected := range tests {
+		if result := exportedName(name); result != expected {
+			t.Errorf("Unexpected exported name for %q: %q", name, result)
+		}
+	}
+}
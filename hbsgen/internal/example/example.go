@@ -0,0 +1,40 @@
+// Package example is used to test code generated by hbsgen.
+package example
+
+//go:generate go run ../../../cmd/hbsgen -o example_hbs.go -partials templates/partials templates/page.hbs:Page
+
+// Page is the context of the page template.
+type Page struct {
+	Title  string
+	Intro  string `handlebars:"intro_text"`
+	Draft  bool
+	Views  int
+	Rating float64
+	Author *Person
+	Tags   []string
+	Posts  []Post
+	Meta   map[string]string
+}
+
+// Person is a page author.
+type Person struct {
+	FirstName string
+	LastName  string
+}
+
+// FullName returns person full name.
+func (p Person) FullName() string {
+	return p.FirstName + " " + p.LastName
+}
+
+// Post is a page post.
+type Post struct {
+	Title     string
+	Published bool
+	Comments  []Comment
+}
+
+// Comment is a post comment.
+type Comment struct {
+	Body string
+}
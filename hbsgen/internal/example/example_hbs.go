@@ -0,0 +1,206 @@
+// Code generated by hbsgen. DO NOT EDIT.
+
+package example
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/hbsgen"
+)
+
+// RenderPage renders the page template.
+func RenderPage(w io.Writer, ctx *Page) error {
+	if ctx == nil {
+		ctx = new(Page)
+	}
+	if _, err := io.WriteString(w, "<h1>"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.Escape(ctx.Title)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "</h1>\n"); err != nil {
+		return err
+	}
+	if ctx.Draft {
+		if _, err := io.WriteString(w, "<p class=\"draft\">Draft</p>"); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "\n<p>"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.Escape(ctx.Intro)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "</p>\n<p>"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, strconv.FormatInt(int64(ctx.Views), 10)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, " views, rated "); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, strconv.FormatFloat(float64(ctx.Rating), 'f', -1, 64)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "</p>\n"); err != nil {
+		return err
+	}
+	if ctx.Author != nil {
+		v1 := ctx.Author
+		_ = v1
+		if _, err := io.WriteString(w, "<p>By "); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, raymond.Escape(v1.FullName())); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, " ("); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, raymond.Escape(v1.FirstName)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, ")</p>\n"); err != nil {
+			return err
+		}
+	} else {
+		if _, err := io.WriteString(w, "<p>Anonymous</p>\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "<ul>\n"); err != nil {
+		return err
+	}
+	if len(ctx.Tags) > 0 {
+		for i2 := range ctx.Tags {
+			v3 := ctx.Tags[i2]
+			_ = v3
+			if _, err := io.WriteString(w, "  <li class=\""); err != nil {
+				return err
+			}
+			if i2 == 0 {
+				if _, err := io.WriteString(w, "first"); err != nil {
+					return err
+				}
+			}
+			if i2 == len(ctx.Tags)-1 {
+				if _, err := io.WriteString(w, "last"); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, "\">"); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, strconv.FormatInt(int64(i2), 10)); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, ": "); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, raymond.Escape(v3)); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "</li>\n"); err != nil {
+				return err
+			}
+		}
+	} else {
+		if _, err := io.WriteString(w, "  <li>No tags</li>\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "</ul>\n"); err != nil {
+		return err
+	}
+	if len(ctx.Posts) > 0 {
+		for i4 := range ctx.Posts {
+			v5 := &ctx.Posts[i4]
+			_ = v5
+			p6 := v5
+			var b7 strings.Builder
+			if err := hbsPartialPost(&b7, p6); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, hbsgen.Indent(b7.String(), "  ")); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := io.WriteString(w, "<footer>"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.Escape(ctx.Meta["copyright"])); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, " - "); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, ctx.Meta["raw"]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, " - "); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.Escape(ctx.Title)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "</footer>\n"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// hbsPartialPost renders the post partial.
+func hbsPartialPost(w io.Writer, ctx *Post) error {
+	if ctx == nil {
+		ctx = new(Post)
+	}
+	if _, err := io.WriteString(w, "<article>\n  <h2>"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, raymond.Escape(ctx.Title)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "</h2>\n  "); err != nil {
+		return err
+	}
+	if !(ctx.Published) {
+		if _, err := io.WriteString(w, "<em>unpublished</em>"); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+	if len(ctx.Comments) > 0 {
+		for i1 := range ctx.Comments {
+			v2 := &ctx.Comments[i1]
+			_ = v2
+			if _, err := io.WriteString(w, "  <p>"); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, raymond.Escape(v2.Body)); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, " on "); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, raymond.Escape(ctx.Title)); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "</p>\n"); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := io.WriteString(w, "</article>\n"); err != nil {
+		return err
+	}
+	return nil
+}
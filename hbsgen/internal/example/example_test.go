@@ -0,0 +1,75 @@
+//go:build !tinygo && !raymond_tiny
+
+package example
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+var pages = map[string]*Page{
+	"full": {
+		Title:  "Hello <world>",
+		Intro:  "Intro & more",
+		Draft:  true,
+		Views:  42,
+		Rating: 4.5,
+		Author: &Person{FirstName: "Jean", LastName: "Valjean"},
+		Tags:   []string{"go", "<hbs>", "templates"},
+		Posts: []Post{
+			{Title: "First", Published: true, Comments: []Comment{{Body: "Nice"}, {Body: "<3"}}},
+			{Title: "Second"},
+		},
+		Meta: map[string]string{"copyright": "(c) Me & co", "raw": "<b>raw</b>"},
+	},
+	"empty": {},
+	"single tag": {
+		Tags: []string{"only"},
+	},
+}
+
+// TestRenderPage checks that generated code outputs the same result as raymond
+func TestRenderPage(t *testing.T) {
+	source, err := ioutil.ReadFile("templates/page.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := ioutil.ReadFile("templates/partials/post.hbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := raymond.MustParse(string(source))
+	tpl.RegisterPartial("post", string(partial))
+
+	for name, page := range pages {
+		expected, err := tpl.Exec(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf strings.Builder
+		if err := RenderPage(&buf, page); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != expected {
+			t.Errorf("Test '%s' failed\nexpected:\n%s\ngot:\n%s", name, expected, buf.String())
+		}
+	}
+}
+
+func TestRenderPageNil(t *testing.T) {
+	var buf strings.Builder
+	if err := RenderPage(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "<p>Anonymous</p>") {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+}
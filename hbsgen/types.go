@@ -0,0 +1,307 @@
+package hbsgen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// kind is the kind of a Go type
+type kind int
+
+const (
+	// kindOpaque is a type that is not known by generator, its values are handled with raymond functions
+	kindOpaque kind = iota
+
+	// kindBasic is a predeclared boolean, numeric or string type
+	kindBasic
+
+	// kindStruct is a struct type declared in package
+	kindStruct
+
+	// kindPtr is a pointer type
+	kindPtr
+
+	// kindSlice is a slice or an array type
+	kindSlice
+
+	// kindMap is a map type
+	kindMap
+)
+
+// goType represents a Go type
+type goType struct {
+	kind kind
+
+	// basic type name, or struct type name
+	name string
+
+	// true if this is a named type declared in package, whose methods are unknown
+	named bool
+
+	// true if this is an array type
+	array bool
+
+	// pointer, slice or map element type
+	elem *goType
+
+	// map key type
+	key *goType
+
+	// source code of opaque type
+	src string
+}
+
+// isString returns true if this is a string type
+func (t *goType) isString() bool {
+	return (t.kind == kindBasic) && (t.name == "string")
+}
+
+// String returns a description of that type, used in error messages
+func (t *goType) String() string {
+	switch t.kind {
+	case kindBasic, kindStruct:
+		return t.name
+	case kindPtr:
+		return "*" + t.elem.String()
+	case kindSlice:
+		return "[]" + t.elem.String()
+	case kindMap:
+		return "map[" + t.key.String() + "]" + t.elem.String()
+	default:
+		return t.src
+	}
+}
+
+// method is a method without parameter declared in package
+type method struct {
+	name   string
+	result ast.Expr
+}
+
+// pkgTypes holds the types declared in a Go package
+type pkgTypes struct {
+	name    string
+	types   map[string]*ast.TypeSpec
+	methods map[string][]method
+}
+
+// loadPackage parses the Go files of given directory, and collects declared types and methods
+func loadPackage(dir string) (*pkgTypes, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &pkgTypes{
+		types:   make(map[string]*ast.TypeSpec),
+		methods: make(map[string][]method),
+	}
+
+	fset := token.NewFileSet()
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.name == "" {
+			result.name = f.Name.Name
+		}
+
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						result.types[ts.Name.Name] = ts
+					}
+				}
+			case *ast.FuncDecl:
+				result.addMethod(d)
+			}
+		}
+	}
+
+	if result.name == "" {
+		return nil, fmt.Errorf("No Go package found in directory: %s", dir)
+	}
+
+	return result, nil
+}
+
+// addMethod registers given function if it is a method without parameter that returns a single value
+func (p *pkgTypes) addMethod(decl *ast.FuncDecl) {
+	if (decl.Recv == nil) || (len(decl.Recv.List) != 1) || !decl.Name.IsExported() {
+		return
+	}
+
+	if (decl.Type.Params.NumFields() != 0) || (decl.Type.Results.NumFields() != 1) {
+		return
+	}
+
+	recv := decl.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		return
+	}
+
+	p.methods[ident.Name] = append(p.methods[ident.Name], method{
+		name:   decl.Name.Name,
+		result: decl.Type.Results.List[0].Type,
+	})
+}
+
+// structType returns the struct type declared in package with given name
+func (p *pkgTypes) structType(name string) (*goType, error) {
+	spec := p.types[name]
+	if spec == nil {
+		return nil, fmt.Errorf("Type %s not found in package %s", name, p.name)
+	}
+
+	if _, ok := spec.Type.(*ast.StructType); !ok {
+		return nil, fmt.Errorf("Type %s is not a struct", name)
+	}
+
+	return &goType{kind: kindStruct, name: name}, nil
+}
+
+// resolve returns the type corresponding to given type expression
+func (p *pkgTypes) resolve(expr ast.Expr) *goType {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if basicTypes[e.Name] {
+			return &goType{kind: kindBasic, name: e.Name}
+		}
+
+		spec := p.types[e.Name]
+		if spec == nil {
+			return &goType{kind: kindOpaque, src: e.Name}
+		}
+
+		if _, ok := spec.Type.(*ast.StructType); ok {
+			return &goType{kind: kindStruct, name: e.Name}
+		}
+
+		if spec.Assign.IsValid() {
+			// type alias
+			return p.resolve(spec.Type)
+		}
+
+		// named type: use underlying type, but its methods are unknown
+		result := *p.resolve(spec.Type)
+		result.named = true
+		if result.kind == kindOpaque {
+			result.src = e.Name
+		}
+
+		return &result
+	case *ast.StarExpr:
+		return &goType{kind: kindPtr, elem: p.resolve(e.X)}
+	case *ast.ArrayType:
+		return &goType{kind: kindSlice, array: e.Len != nil, elem: p.resolve(e.Elt)}
+	case *ast.MapType:
+		return &goType{kind: kindMap, key: p.resolve(e.Key), elem: p.resolve(e.Value)}
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			return &goType{kind: kindOpaque, src: pkg.Name + "." + e.Sel.Name}
+		}
+	}
+
+	return &goType{kind: kindOpaque, src: fmt.Sprintf("%T", expr)}
+}
+
+// member is a field or a method of a struct
+type member struct {
+	// selector to use in Go code, eg: "Name" or "Name()"
+	sel string
+
+	typ    *goType
+	isFunc bool
+}
+
+// member returns the field or method of given struct type that corresponds to given template path part
+//
+// Lookup order is the same as the one used by raymond at runtime: method, exported field, then `handlebars` struct tag.
+func (p *pkgTypes) member(typ *goType, part string) (*member, bool) {
+	title := strings.Title(part)
+
+	for _, meth := range p.methods[typ.name] {
+		if (meth.name == part) || (meth.name == title) {
+			return &member{sel: meth.name + "()", typ: p.resolve(meth.result), isFunc: true}, true
+		}
+	}
+
+	st, ok := p.types[typ.name].Type.(*ast.StructType)
+	if !ok {
+		return nil, false
+	}
+
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if (name.Name == title) && name.IsExported() {
+				return &member{sel: name.Name, typ: p.resolve(field.Type)}, true
+			}
+		}
+
+		if len(field.Names) == 0 {
+			// embedded struct
+			if embedded := p.resolve(field.Type); embedded.kind == kindStruct {
+				if m, ok := p.member(embedded, part); ok {
+					return m, true
+				}
+			}
+		}
+	}
+
+	for _, field := range st.Fields.List {
+		if (field.Tag == nil) || (len(field.Names) == 0) {
+			continue
+		}
+
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+
+		if reflect.StructTag(tag).Get("handlebars") == part {
+			return &member{sel: field.Names[0].Name, typ: p.resolve(field.Type)}, true
+		}
+	}
+
+	return nil, false
+}
+
+// basicTypes are the predeclared types handled without raymond functions
+var basicTypes = map[string]bool{
+	"bool":    true,
+	"string":  true,
+	"int":     true,
+	"int8":    true,
+	"int16":   true,
+	"int32":   true,
+	"int64":   true,
+	"uint":    true,
+	"uint8":   true,
+	"uint16":  true,
+	"uint32":  true,
+	"uint64":  true,
+	"float32": true,
+	"float64": true,
+	"byte":    true,
+	"rune":    true,
+}
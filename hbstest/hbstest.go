@@ -0,0 +1,142 @@
+// Package hbstest provides golden file testing helpers for raymond templates.
+//
+// RenderGolden() renders a template in deterministic mode, and compares its output with a golden file of the testdata directory. Run tests with the -update flag to write golden files instead:
+//
+//	go test ./... -update
+package hbstest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+// updateFlag is the name of the flag that updates golden files
+const updateFlag = "update"
+
+func init() {
+	// the flag may already be defined by tests or by another golden file package
+	if flag.Lookup(updateFlag) == nil {
+		flag.Bool(updateFlag, false, "update golden files instead of comparing outputs with them")
+	}
+}
+
+// Options represents golden file options.
+type Options struct {
+	// Dir is the directory of golden files. If empty, "testdata" is used.
+	Dir string
+
+	// Name is the golden file name, without the ".golden" extension. If empty, the test name is used, so that subtests are stored in subdirectories.
+	Name string
+
+	// ExecOptions are the evaluation options. Deterministic is always set, so that outputs are stable.
+	ExecOptions raymond.ExecOptions
+}
+
+// RenderGolden renders given template with given context in deterministic mode, and compares its output with the golden file named after the test, eg. "testdata/TestPage/admin.golden" for the "admin" subtest of TestPage.
+//
+// The test fails if rendering fails, or if output differs from golden file. If the -update flag is set, golden file is written instead.
+func RenderGolden(t testing.TB, tpl *raymond.Template, ctx interface{}) {
+	t.Helper()
+
+	RenderGoldenWithOptions(t, tpl, ctx, Options{})
+}
+
+// RenderGoldenWithOptions renders given template with given context in deterministic mode, and compares its output with a golden file, with given options.
+func RenderGoldenWithOptions(t testing.TB, tpl *raymond.Template, ctx interface{}, opts Options) {
+	t.Helper()
+
+	if opts.Dir == "" {
+		opts.Dir = "testdata"
+	}
+
+	if opts.Name == "" {
+		opts.Name = t.Name()
+	}
+
+	if err := golden(tpl, ctx, opts, updating()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// updating returns true if golden files must be updated
+func updating() bool {
+	f := flag.Lookup(updateFlag)
+
+	if getter, ok := f.Value.(flag.Getter); ok {
+		if update, ok := getter.Get().(bool); ok {
+			return update
+		}
+	}
+
+	return f.Value.String() == "true"
+}
+
+// golden renders given template and compares its output with the golden file of given options, or writes it if update is true
+func golden(tpl *raymond.Template, ctx interface{}, opts Options, update bool) error {
+	execOpts := opts.ExecOptions
+	execOpts.Deterministic = true
+
+	output, err := tpl.ExecWithOptions(ctx, execOpts)
+	if err != nil {
+		return fmt.Errorf("render failed: %s", err)
+	}
+
+	filePath := filepath.Join(opts.Dir, filepath.FromSlash(opts.Name)+".golden")
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(filePath, []byte(output), 0644)
+	}
+
+	data, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("golden file %s does not exist, run tests with -%s to create it", filePath, updateFlag)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// golden files may have been checked out with CRLF line endings
+	expected := string(bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1))
+
+	if output != expected {
+		return fmt.Errorf("output differs from golden file %s, run tests with -%s to update it\n%s", filePath, updateFlag, firstDiff(expected, output))
+	}
+
+	return nil
+}
+
+// firstDiff describes the first line that differs between given expected and actual outputs
+func firstDiff(expected string, actual string) string {
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+
+	for i := 0; ; i++ {
+		var want, got string
+		wantOK, gotOK := i < len(expectedLines), i < len(actualLines)
+
+		if wantOK {
+			want = expectedLines[i]
+		}
+
+		if gotOK {
+			got = actualLines[i]
+		}
+
+		if (want != got) || (wantOK != gotOK) {
+			return fmt.Sprintf("line %d:\n\twant: %q\n\tgot:  %q", i+1, want, got)
+		}
+	}
+}
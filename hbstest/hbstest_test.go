@@ -0,0 +1,103 @@
+package hbstest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aymerick/raymond"
+)
+
+func TestRenderGolden(t *testing.T) {
+	tpl := raymond.MustParse("<h1>{{title}}</h1>\n<p>{{today}}</p>\n")
+	tpl.RegisterHelper("today", func(options *raymond.Options) string {
+		return options.Now().Format("2006-01-02")
+	})
+
+	RenderGolden(t, tpl, map[string]string{"title": "Hello"})
+}
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hbstest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tpl := raymond.MustParse("{{#each items}}{{@key}}={{this}}\n{{/each}}")
+	opts := Options{Dir: dir, Name: "TestGolden/sub"}
+
+	items := map[string]interface{}{"items": map[string]int{"b": 2, "a": 1, "c": 3}}
+
+	if err := golden(tpl, items, opts, false); (err == nil) || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected a missing golden file error, got: %v", err)
+	}
+
+	if err := golden(tpl, items, opts, true); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "TestGolden", "sub.golden"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// maps are iterated in key order
+	if string(data) != "a=1\nb=2\nc=3\n" {
+		t.Errorf("Unexpected golden file: %q", data)
+	}
+
+	if err := golden(tpl, items, opts, false); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	err = golden(tpl, map[string]interface{}{"items": map[string]int{"a": 1, "b": 5}}, opts, false)
+	if (err == nil) || !strings.Contains(err.Error(), "line 2:\n\twant: \"b=2\"\n\tgot:  \"b=5\"") {
+		t.Errorf("Expected a diff error, got: %v", err)
+	}
+
+	// CRLF line endings are ignored
+	if err := ioutil.WriteFile(filepath.Join(dir, "TestGolden", "sub.golden"), []byte("a=1\r\nb=2\r\nc=3\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := golden(tpl, items, opts, false); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	failing := raymond.MustParse("{{fail}}")
+	failing.RegisterHelper("fail", func() string {
+		panic(errors.New("boom"))
+	})
+
+	if err := golden(failing, nil, Options{Dir: dir, Name: "error"}, true); (err == nil) || !strings.Contains(err.Error(), "render failed") {
+		t.Errorf("Expected a render error, got: %v", err)
+	}
+}
+
+func TestGoldenExecOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hbstest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tpl := raymond.MustParse("{{today}}")
+	tpl.RegisterHelper("today", func(options *raymond.Options) string {
+		return options.Now().Format("2006-01-02")
+	})
+
+	now := func() time.Time { return time.Date(2018, 3, 22, 0, 0, 0, 0, time.UTC) }
+
+	if err := golden(tpl, nil, Options{Dir: dir, Name: "today", ExecOptions: raymond.ExecOptions{Now: now}}, true); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "today.golden")); string(data) != "2018-03-22" {
+		t.Errorf("Expected evaluation options to be used, got: %q", data)
+	}
+}
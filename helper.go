@@ -3,8 +3,12 @@ package raymond
 import (
 	"fmt"
 	"log"
+	"math"
 	"reflect"
+	"strings"
 	"sync"
+
+	"github.com/aymerick/raymond/parser"
 )
 
 // Options represents the options argument provided to helpers and context functions.
@@ -18,20 +22,131 @@ type Options struct {
 }
 
 // helpers stores all globally registered helpers
-var helpers = make(map[string]reflect.Value)
+var helpers helperRegistry
 
-// protects global helpers
+// serializes global helpers registrations, and protects helpersInfo
 var helpersMutex sync.RWMutex
 
 func init() {
 	// register builtin helpers
-	RegisterHelper("if", ifHelper)
-	RegisterHelper("unless", unlessHelper)
-	RegisterHelper("with", withHelper)
-	RegisterHelper("each", eachHelper)
-	RegisterHelper("log", logHelper)
-	RegisterHelper("lookup", lookupHelper)
-	RegisterHelper("equal", equalHelper)
+	RegisterHelperWithInfo("if", ifHelper, HelperInfo{
+		Description: "Renders block if argument is truthy, else renders else block",
+		Params:      []HelperParam{{Name: "conditional"}},
+		Examples:    []string{"{{#if author}}{{name}}{{else}}Unknown{{/if}}"},
+	})
+	RegisterHelperWithInfo("unless", unlessHelper, HelperInfo{
+		Description: "Renders block if argument is falsy, else renders else block",
+		Params:      []HelperParam{{Name: "conditional"}},
+		Examples:    []string{"{{#unless license}}No license{{/unless}}"},
+	})
+	RegisterHelperWithInfo("with", withHelper, HelperInfo{
+		Description: "Renders block with argument as context",
+		Params:      []HelperParam{{Name: "context"}},
+		Examples:    []string{"{{#with author}}{{firstName}}{{/with}}"},
+	})
+	RegisterHelperWithInfo("each", eachHelper, HelperInfo{
+		Description: "Renders block for each item of an array, a slice, a map or a struct",
+		Params:      []HelperParam{{Name: "context"}},
+		Examples:    []string{"{{#each people}}{{@index}}: {{this}}{{/each}}"},
+	})
+	RegisterHelperWithInfo("log", logHelper, HelperInfo{
+		Description: "Logs given message",
+		Params:      []HelperParam{{Name: "message"}},
+		Examples:    []string{`{{log "Look at me!"}}`},
+	})
+	RegisterHelperWithInfo("lookup", lookupHelper, HelperInfo{
+		Description: "Returns the field of an object",
+		Params:      []HelperParam{{Name: "obj"}, {Name: "field"}},
+		Examples:    []string{"{{lookup ../foo @index}}"},
+	})
+	RegisterHelperWithInfo("equal", equalHelper, HelperInfo{
+		Description: "Renders block if string representations of both arguments are equal",
+		Params:      []HelperParam{{Name: "a"}, {Name: "b"}},
+		Examples:    []string{`{{#equal foo "bar"}}foo is bar{{/equal}}`},
+	})
+	RegisterHelperWithInfo("times", timesHelper, HelperInfo{
+		Description: "Renders block given number of times",
+		Params:      []HelperParam{{Name: "count", Type: "int"}},
+		Examples:    []string{"{{#times 3}}{{@index}}{{/times}}"},
+	})
+	RegisterHelperWithInfo("range", rangeHelper, HelperInfo{
+		Description: "Renders block for each number from start (inclusive) to end (exclusive) with given optional step",
+		Params:      []HelperParam{{Name: "start", Type: "int"}, {Name: "end", Type: "int"}, {Name: "step", Type: "...int"}},
+		Examples:    []string{"{{#range 1 10 2}}{{this}}{{/range}}", "{{#range 5 0}}{{this}}{{/range}}"},
+	})
+	RegisterHelperWithInfo("concat", concatHelper, HelperInfo{
+		Description: "Joins string representations of all arguments",
+		Params:      []HelperParam{{Name: "params"}},
+		Examples:    []string{`{{> (concat "icons/" name)}}`},
+		Pure:        true,
+	})
+	RegisterHelperWithInfo("indent", indentHelper, HelperInfo{
+		Description: "Prefixes every line of text with given number of spaces",
+		Params:      []HelperParam{{Name: "count", Type: "int"}, {Name: "text"}},
+		Examples:    []string{"{{{indent 4 (toYaml resources)}}}"},
+		Pure:        true,
+	})
+	RegisterHelperWithInfo("nindent", nindentHelper, HelperInfo{
+		Description: "Prefixes every line of text with given number of spaces, and adds a leading newline",
+		Params:      []HelperParam{{Name: "count", Type: "int"}, {Name: "text"}},
+		Examples:    []string{"resources:{{{nindent 2 (toYaml resources)}}}"},
+		Pure:        true,
+	})
+	RegisterHelperWithInfo("switch", switchHelper, HelperInfo{
+		Description: "Renders the first case block matching argument, or the default block",
+		Params:      []HelperParam{{Name: "value"}},
+		Examples:    []string{`{{#switch status}}{{#case "open"}}Open{{/case}}{{#default}}Unknown{{/default}}{{/switch}}`},
+	})
+	RegisterHelperWithInfo("case", caseHelper, HelperInfo{
+		Description: "Renders block if argument matches enclosing switch argument",
+		Params:      []HelperParam{{Name: "value"}},
+		Examples:    []string{`{{#case "open"}}Open{{/case}}`},
+	})
+	RegisterHelperWithInfo("default", defaultHelper, HelperInfo{
+		Description: "Renders block if no case of enclosing switch matched",
+		Examples:    []string{"{{#default}}Unknown{{/default}}"},
+	})
+	RegisterHelperWithInfo("uuid", uuidHelper, HelperInfo{
+		Description: "Returns a random version 4 UUID",
+		Examples:    []string{"{{uuid}}"},
+	})
+	RegisterHelperWithInfo("nonce", nonceHelper, HelperInfo{
+		Description: "Returns the nonce attribute of current evaluation, if a nonce is set",
+		Examples:    []string{`<link rel="stylesheet" href="/app.css" {{nonce}}>`},
+	})
+	RegisterHelperWithInfo("randomInt", randomIntHelper, HelperInfo{
+		Description: "Returns a random integer between min (inclusive) and max (exclusive)",
+		Params:      []HelperParam{{Name: "min", Type: "int"}, {Name: "max", Type: "int"}},
+		Examples:    []string{"{{randomInt 1 7}}"},
+	})
+	RegisterHelperWithInfo("randomString", randomStringHelper, HelperInfo{
+		Description: "Returns a random alphanumeric string of given length",
+		Params:      []HelperParam{{Name: "length", Type: "int"}},
+		Examples:    []string{"{{randomString 16}}"},
+	})
+	RegisterHelperWithInfo("table", tableHelper, HelperInfo{
+		Description: "Renders rows provided by the rows hash argument or by nested table.row blocks as an aligned ASCII or markdown table",
+		Examples:    []string{`{{#table header=true}}{{#table.row}}{{table.cell "Name"}}{{/table.row}}{{/table}}`, `{{table rows=people columns="name,age" format="markdown"}}`},
+	})
+	RegisterHelperWithInfo("table.row", tableRowHelper, HelperInfo{
+		Description: "Adds a row to enclosing table",
+		Examples:    []string{`{{#table.row}}{{table.cell name}}{{table.cell age}}{{/table.row}}`},
+	})
+	RegisterHelperWithInfo("table.cell", tableCellHelper, HelperInfo{
+		Description: "Adds a cell to enclosing table row",
+		Params:      []HelperParam{{Name: "value"}},
+		Examples:    []string{"{{table.cell name}}"},
+	})
+	RegisterHelperWithInfo("toYaml", toYamlHelper, HelperInfo{
+		Description: "Returns YAML representation of argument",
+		Params:      []HelperParam{{Name: "value"}},
+		Examples:    []string{"{{{toYaml resources}}}"},
+	})
+	RegisterHelperWithInfo("fromYaml", fromYamlHelper, HelperInfo{
+		Description: "Parses YAML document",
+		Params:      []HelperParam{{Name: "str", Type: "string"}},
+		Examples:    []string{"{{#with (fromYaml config)}}{{name}}{{/with}}"},
+	})
 }
 
 // RegisterHelper registers a global helper. That helper will be available to all templates.
@@ -39,21 +154,74 @@ func RegisterHelper(name string, helper interface{}) {
 	helpersMutex.Lock()
 	defer helpersMutex.Unlock()
 
-	if helpers[name] != zero {
+	if helpers.get(name) != zero {
 		panic(fmt.Errorf("Helper already registered: %s", name))
 	}
 
 	val := reflect.ValueOf(helper)
 	ensureValidHelper(name, val)
 
-	helpers[name] = val
+	helpers.set(parser.Intern(name), val)
 }
 
 // RegisterHelpers registers several global helpers. Those helpers will be available to all templates.
 func RegisterHelpers(helpers map[string]interface{}) {
+	registerHelpers(newHelperBatch(helpers))
+}
+
+// registerHelpers registers given batch of global helpers
+func registerHelpers(batch map[string]reflect.Value) {
+	helpersMutex.Lock()
+	defer helpersMutex.Unlock()
+
+	for name := range batch {
+		if helpers.get(name) != zero {
+			panic(fmt.Errorf("Helper already registered: %s", name))
+		}
+	}
+
+	helpers.setAll(batch)
+}
+
+// RegisterNamespace registers several global helpers under given namespace. Those helpers will be available to all templates.
+//
+// For example, an `upper` helper registered under the `str` namespace is called with `{{str.upper name}}`.
+func RegisterNamespace(namespace string, helpers map[string]interface{}) {
+	RegisterHelpers(namespacedHelpers(namespace, helpers))
+}
+
+// newHelperBatch checks given helpers, and returns them with interned names, to be registered at once
+func newHelperBatch(helpers map[string]interface{}) map[string]reflect.Value {
+	result := make(map[string]reflect.Value, len(helpers))
+
 	for name, helper := range helpers {
-		RegisterHelper(name, helper)
+		val := reflect.ValueOf(helper)
+		ensureValidHelper(name, val)
+
+		result[parser.Intern(name)] = val
 	}
+
+	return result
+}
+
+// namespacedHelpers returns given helpers with their names prefixed by given namespace
+func namespacedHelpers(namespace string, helpers map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(helpers))
+
+	for name, helper := range helpers {
+		result[namespacedHelperName(namespace, name)] = helper
+	}
+
+	return result
+}
+
+// namespacedHelperName returns the name of a helper registered under given namespace
+func namespacedHelperName(namespace string, name string) string {
+	if namespace == "" {
+		panic(fmt.Errorf("Helper namespace can't be empty: %s", name))
+	}
+
+	return namespace + "." + name
 }
 
 // RemoveHelper unregisters a global helper
@@ -61,7 +229,8 @@ func RemoveHelper(name string) {
 	helpersMutex.Lock()
 	defer helpersMutex.Unlock()
 
-	delete(helpers, name)
+	helpers.remove(name)
+	delete(helpersInfo, name)
 }
 
 // RemoveAllHelpers unregisters all global helpers
@@ -69,7 +238,8 @@ func RemoveAllHelpers() {
 	helpersMutex.Lock()
 	defer helpersMutex.Unlock()
 
-	helpers = make(map[string]reflect.Value)
+	helpers.reset()
+	helpersInfo = make(map[string]HelperInfo)
 }
 
 // ensureValidHelper panics if given helper is not valid
@@ -89,10 +259,7 @@ func ensureValidHelper(name string, funcValue reflect.Value) {
 
 // findHelper finds a globally registered helper
 func findHelper(name string) reflect.Value {
-	helpersMutex.RLock()
-	defer helpersMutex.RUnlock()
-
-	return helpers[name]
+	return helpers.get(name)
 }
 
 // newOptions instanciates a new Options
@@ -131,9 +298,14 @@ func (options *Options) ValueStr(name string) string {
 	return Str(options.Value(name))
 }
 
-// Ctx returns current evaluation context.
+// Ctx returns current evaluation context, or nil if it is undefined.
 func (options *Options) Ctx() interface{} {
-	return options.eval.curCtx().Interface()
+	ctx := options.eval.curCtx()
+	if !ctx.IsValid() {
+		return nil
+	}
+
+	return ctx.Interface()
 }
 
 //
@@ -193,6 +365,8 @@ func (options *Options) DataStr(name string) string {
 }
 
 // DataFrame returns current private data frame.
+//
+// Data frames are reused by subsequent evaluations, so the returned data frame must not be retained after helper returns.
 func (options *Options) DataFrame() *DataFrame {
 	return options.eval.dataFrame
 }
@@ -204,9 +378,16 @@ func (options *Options) NewDataFrame() *DataFrame {
 	return options.eval.dataFrame.Copy()
 }
 
-// newIterDataFrame instanciates a new data frame and set iteration specific vars
+// newIterDataFrame returns a new data frame with iteration specific vars set
+//
+// Call releaseDataFrame() once iteration is evaluated.
 func (options *Options) newIterDataFrame(length int, i int, key interface{}) *DataFrame {
-	return options.eval.dataFrame.newIterDataFrame(length, i, key)
+	return options.eval.newIterDataFrame(length, i, key)
+}
+
+// releaseDataFrame releases a data frame returned by newIterDataFrame(), so that it is reused for next iteration
+func (options *Options) releaseDataFrame(data *DataFrame) {
+	options.eval.releaseDataFrame(data)
 }
 
 //
@@ -224,6 +405,13 @@ func (options *Options) evalBlock(ctx interface{}, data *DataFrame, key interfac
 	return result
 }
 
+// evalBlockTo evaluates block with given context, private data and iteration key, and writes result to given writer
+func (options *Options) evalBlockTo(w writer, ctx interface{}, data *DataFrame, key interface{}) {
+	if block := options.eval.curBlock(); (block != nil) && (block.Program != nil) {
+		options.eval.evalProgramTo(w, block.Program, ctx, data, key)
+	}
+}
+
 // Fn evaluates block with current evaluation context.
 func (options *Options) Fn() string {
 	return options.evalBlock(nil, nil, nil)
@@ -248,7 +436,7 @@ func (options *Options) FnData(data *DataFrame) string {
 func (options *Options) Inverse() string {
 	result := ""
 	if block := options.eval.curBlock(); (block != nil) && (block.Inverse != nil) {
-		result, _ = block.Inverse.Accept(options.eval).(string)
+		result = options.eval.programStr(block.Inverse)
 	}
 
 	return result
@@ -295,7 +483,7 @@ func (options *Options) isIncludableZero() bool {
 
 // #if block helper
 func ifHelper(conditional interface{}, options *Options) interface{} {
-	if options.isIncludableZero() || IsTrue(conditional) {
+	if options.isIncludableZero() || options.eval.isTrue(conditional) {
 		return options.Fn()
 	}
 
@@ -304,7 +492,7 @@ func ifHelper(conditional interface{}, options *Options) interface{} {
 
 // #unless block helper
 func unlessHelper(conditional interface{}, options *Options) interface{} {
-	if options.isIncludableZero() || IsTrue(conditional) {
+	if options.isIncludableZero() || options.eval.isTrue(conditional) {
 		return options.Inverse()
 	}
 
@@ -326,7 +514,7 @@ func eachHelper(context interface{}, options *Options) interface{} {
 		return options.Inverse()
 	}
 
-	result := ""
+	var result strings.Builder
 
 	val := reflect.ValueOf(context)
 	switch val.Kind() {
@@ -336,11 +524,12 @@ func eachHelper(context interface{}, options *Options) interface{} {
 			data := options.newIterDataFrame(val.Len(), i, nil)
 
 			// evaluates block
-			result += options.evalBlock(val.Index(i).Interface(), data, i)
+			options.evalBlockTo(&result, val.Index(i).Interface(), data, i)
+			options.releaseDataFrame(data)
 		}
 	case reflect.Map:
-		// note: a go hash is not ordered, so result may vary, this behaviour differs from the JS implementation
-		keys := val.MapKeys()
+		// note: a go hash is not ordered, so result may vary unless in deterministic mode, this behaviour differs from the JS implementation
+		keys := options.eval.mapKeys(val)
 		for i := 0; i < len(keys); i++ {
 			key := keys[i].Interface()
 			ctx := val.MapIndex(keys[i]).Interface()
@@ -349,14 +538,15 @@ func eachHelper(context interface{}, options *Options) interface{} {
 			data := options.newIterDataFrame(len(keys), i, key)
 
 			// evaluates block
-			result += options.evalBlock(ctx, data, key)
+			options.evalBlockTo(&result, ctx, data, key)
+			options.releaseDataFrame(data)
 		}
 	case reflect.Struct:
 		var exportedFields []int
 
-		// collect exported fields only
+		// collect exported fields only, that are not denied by sandbox
 		for i := 0; i < val.NumField(); i++ {
-			if tField := val.Type().Field(i); tField.PkgPath == "" {
+			if tField := val.Type().Field(i); (tField.PkgPath == "") && options.eval.sandbox.allowMember(val.Type(), tField.Name) {
 				exportedFields = append(exportedFields, i)
 			}
 		}
@@ -369,16 +559,17 @@ func eachHelper(context interface{}, options *Options) interface{} {
 			data := options.newIterDataFrame(len(exportedFields), i, key)
 
 			// evaluates block
-			result += options.evalBlock(ctx, data, key)
+			options.evalBlockTo(&result, ctx, data, key)
+			options.releaseDataFrame(data)
 		}
 	}
 
-	return result
+	return result.String()
 }
 
 // #log helper
-func logHelper(message string) interface{} {
-	log.Print(message)
+func logHelper(message string, options *Options) interface{} {
+	log.Print(options.eval.secrets.redact(message))
 	return ""
 }
 
@@ -390,9 +581,195 @@ func lookupHelper(obj interface{}, field string, options *Options) interface{} {
 // #equal helper
 // Ref: https://github.com/aymerick/raymond/issues/7
 func equalHelper(a interface{}, b interface{}, options *Options) interface{} {
-	if Str(a) == Str(b) {
+	if options.eval.equal(a, b) {
 		return options.Fn()
 	}
 
 	return ""
 }
+
+// #concat helper
+func concatHelper(params ...interface{}) interface{} {
+	result := ""
+
+	for _, param := range params {
+		result += Str(param)
+	}
+
+	return result
+}
+
+// indent helper
+func indentHelper(count interface{}, text interface{}, options *Options) interface{} {
+	return indentText("indent", count, Str(text), options)
+}
+
+// nindent helper
+func nindentHelper(count interface{}, text interface{}, options *Options) interface{} {
+	return "\n" + indentText("nindent", count, Str(text), options)
+}
+
+// indentText prefixes every line of given text with count spaces
+func indentText(helper string, count interface{}, text string, options *Options) string {
+	nb, ok := options.eval.intValue(count)
+	if !ok || (nb < 0) {
+		options.eval.errorf("%s helper expects a positive number, got: %q", helper, Str(count))
+	}
+
+	return indentLines(text, strings.Repeat(" ", nb))
+}
+
+// switchState is shared by #switch, #case and #default helpers thanks to the private data frame
+type switchState struct {
+	value   interface{}
+	matched bool
+
+	// output of #default block, rendered only when it is reached before a matching #case
+	defaultResult *string
+}
+
+// switchData is the private data key used to store the switch state
+const switchData = "switch"
+
+// enclosingSwitch returns the state of enclosing #switch block
+func (options *Options) enclosingSwitch(helper string) *switchState {
+	state, ok := options.Data(switchData).(*switchState)
+	if !ok {
+		options.eval.errorf("%s helper must be used inside a switch block", helper)
+	}
+
+	return state
+}
+
+// #switch block helper
+func switchHelper(value interface{}, options *Options) interface{} {
+	state := &switchState{value: value}
+
+	frame := options.NewDataFrame()
+	frame.Set(switchData, state)
+
+	result := options.FnData(frame)
+
+	if !state.matched {
+		if state.defaultResult != nil {
+			result += *state.defaultResult
+		} else {
+			result += options.Inverse()
+		}
+	}
+
+	return result
+}
+
+// #case block helper
+func caseHelper(value interface{}, options *Options) interface{} {
+	state := options.enclosingSwitch("case")
+
+	if state.matched || !options.eval.equal(value, state.value) {
+		return ""
+	}
+
+	state.matched = true
+
+	return options.Fn()
+}
+
+// #default block helper
+func defaultHelper(options *Options) interface{} {
+	state := options.enclosingSwitch("default")
+
+	if !state.matched && (state.defaultResult == nil) {
+		result := options.Fn()
+		state.defaultResult = &result
+	}
+
+	return ""
+}
+
+// #times block helper
+func timesHelper(count interface{}, options *Options) interface{} {
+	nb, ok := options.eval.intValue(count)
+	if !ok {
+		options.eval.errorf("times helper expects a number, got: %q", Str(count))
+	}
+
+	if nb <= 0 {
+		return options.Inverse()
+	}
+
+	var result strings.Builder
+
+	for i := 0; i < nb; i++ {
+		// computes private data
+		data := options.newIterDataFrame(nb, i, nil)
+
+		// evaluates block
+		options.evalBlockTo(&result, i, data, i)
+		options.releaseDataFrame(data)
+	}
+
+	return result.String()
+}
+
+// #range block helper
+//
+// Iterates from start (inclusive) to end (exclusive) with given optional step, that can be negative. The step defaults to 1, or to -1 when start is greater than end.
+func rangeHelper(options *Options, start interface{}, end interface{}, step ...interface{}) interface{} {
+	if len(step) > 1 {
+		options.eval.errorf("range helper expects at most 3 arguments, got: %d", len(step)+2)
+	}
+
+	from, okFrom := options.eval.intValue(start)
+	to, okTo := options.eval.intValue(end)
+	if !okFrom || !okTo {
+		options.eval.errorf("range helper expects numbers, got: %q %q", Str(start), Str(end))
+	}
+
+	by := 1
+	if len(step) == 0 {
+		if from > to {
+			by = -1
+		}
+	} else if value, ok := options.eval.intValue(step[0]); ok {
+		by = value
+	} else {
+		options.eval.errorf("range helper expects a number as step, got: %q", Str(step[0]))
+	}
+
+	if by == 0 {
+		options.eval.errorf("range helper step can't be zero")
+	}
+
+	// number of values, computed without overflow, so that they are never all allocated
+	var nb uint64
+	switch {
+	case (by > 0) && (from < to):
+		nb = (uint64(to)-uint64(from)-1)/uint64(by) + 1
+	case (by < 0) && (from > to):
+		nb = (uint64(from)-uint64(to)-1)/uint64(-by) + 1
+	}
+
+	if nb == 0 {
+		return options.Inverse()
+	}
+
+	if nb > math.MaxInt {
+		options.eval.errorf("range helper has too many values: %d", nb)
+	}
+
+	var result strings.Builder
+
+	value := from
+	for i := 0; i < int(nb); i++ {
+		// computes private data
+		data := options.newIterDataFrame(int(nb), i, nil)
+
+		// evaluates block
+		options.evalBlockTo(&result, value, data, i)
+		options.releaseDataFrame(data)
+
+		value += by
+	}
+
+	return result.String()
+}
@@ -0,0 +1,177 @@
+package raymond
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// HelperInfo describes a helper. It is used for documentation generation, editors completion and templates checks.
+type HelperInfo struct {
+	// Name is the helper name, including its namespace if any
+	Name string
+
+	// Description is a short description of what the helper does
+	Description string
+
+	// Params describes the helper parameters, without the trailing Options argument
+	Params []HelperParam
+
+	// Variadic is true if last parameter accepts any number of arguments
+	Variadic bool
+
+	// Options is true if the helper receives the Options argument, which is necessary for block helpers
+	Options bool
+
+	// Examples contains template snippets that illustrate helper usage
+	Examples []string
+
+	// Global is true if this is a global helper, false if it is registered on a template
+	Global bool
+
+	// Pure is true if helper result only depends on its arguments. Subexpressions calling a pure global helper with literal arguments are then evaluated once, when templates are parsed.
+	Pure bool
+}
+
+// HelperParam describes a helper parameter.
+type HelperParam struct {
+	Name string
+	Type string
+}
+
+// Arity returns the minimum and maximum number of arguments of helper. The maximum is -1 for variadic helpers.
+func (info HelperInfo) Arity() (int, int) {
+	if info.Variadic {
+		return len(info.Params) - 1, -1
+	}
+
+	return len(info.Params), len(info.Params)
+}
+
+// helpersInfo stores metadata provided when registering global helpers
+var helpersInfo = make(map[string]HelperInfo)
+
+// RegisterHelperWithInfo registers a global helper with its metadata. That helper will be available to all templates.
+//
+// Parameters types, and parameters names when they are not provided, are computed from helper function signature.
+func RegisterHelperWithInfo(name string, helper interface{}, info HelperInfo) {
+	RegisterHelper(name, helper)
+
+	helpersMutex.Lock()
+	defer helpersMutex.Unlock()
+
+	helpersInfo[name] = info
+}
+
+// RegisterHelperWithInfo registers a helper for that template, with its metadata.
+//
+// Parameters types, and parameters names when they are not provided, are computed from helper function signature.
+func (tpl *Template) RegisterHelperWithInfo(name string, helper interface{}, info HelperInfo) {
+	tpl.RegisterHelper(name, helper)
+
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.helpersInfo[name] = info
+}
+
+// Helpers returns metadata of all helpers available to that template, sorted by name.
+//
+// Template helpers shadow global helpers with the same name.
+func (tpl *Template) Helpers() []HelperInfo {
+	byName := make(map[string]HelperInfo)
+
+	helpersMutex.RLock()
+	for name, helper := range helpers.load() {
+		byName[name] = newHelperInfo(name, helper, helpersInfo[name], true)
+	}
+	helpersMutex.RUnlock()
+
+	tpl.mutex.RLock()
+	for name, helper := range tpl.helpers.load() {
+		byName[name] = newHelperInfo(name, helper, tpl.helpersInfo[name], false)
+	}
+	tpl.mutex.RUnlock()
+
+	result := make([]HelperInfo, 0, len(byName))
+	for _, info := range byName {
+		result = append(result, info)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// Helper returns metadata of helper available to that template with given name, and a boolean set to false if not found.
+func (tpl *Template) Helper(name string) (HelperInfo, bool) {
+	tpl.mutex.RLock()
+	helper, info := tpl.helpers.get(name), tpl.helpersInfo[name]
+	tpl.mutex.RUnlock()
+
+	if helper != zero {
+		return newHelperInfo(name, helper, info, false), true
+	}
+
+	helpersMutex.RLock()
+	helper, info = helpers.get(name), helpersInfo[name]
+	helpersMutex.RUnlock()
+
+	if helper != zero {
+		return newHelperInfo(name, helper, info, true), true
+	}
+
+	return HelperInfo{}, false
+}
+
+// newHelperInfo computes helper metadata from helper function signature and given metadata
+func newHelperInfo(name string, helper reflect.Value, provided HelperInfo, global bool) HelperInfo {
+	result := HelperInfo{
+		Name:        name,
+		Description: provided.Description,
+		Examples:    provided.Examples,
+		Global:      global,
+		Pure:        provided.Pure,
+	}
+
+	funcType := helper.Type()
+	numIn := funcType.NumIn()
+
+	optionsType := reflect.TypeOf((*Options)(nil))
+	result.Variadic = funcType.IsVariadic()
+
+	// options argument is the last one, or the first one of a variadic helper
+	first := 0
+	if result.Variadic && (numIn > 1) && optionsType.AssignableTo(funcType.In(0)) {
+		result.Options = true
+		first = 1
+	} else if !result.Variadic && (numIn > 0) && optionsType.AssignableTo(funcType.In(numIn-1)) {
+		result.Options = true
+		numIn--
+	}
+
+	for i := 0; i < numIn-first; i++ {
+		param := HelperParam{
+			Name: fmt.Sprintf("arg%d", i),
+			Type: funcType.In(first + i).String(),
+		}
+
+		if result.Variadic && (i == numIn-first-1) {
+			param.Type = "..." + funcType.In(first+i).Elem().String()
+		}
+
+		if i < len(provided.Params) {
+			if provided.Params[i].Name != "" {
+				param.Name = provided.Params[i].Name
+			}
+
+			if provided.Params[i].Type != "" {
+				param.Type = provided.Params[i].Type
+			}
+		}
+
+		result.Params = append(result.Params, param)
+	}
+
+	return result
+}
@@ -0,0 +1,83 @@
+package raymond
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHelperInfo(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{repeat "a" 3}}`)
+	tpl.RegisterHelperWithInfo("repeat", echoHelper, HelperInfo{
+		Description: "Repeats a string",
+		Params:      []HelperParam{{Name: "str"}},
+		Examples:    []string{`{{repeat "GnAK!" 3}}`},
+	})
+	tpl.RegisterHelper("bar", barHelper)
+
+	info, ok := tpl.Helper("repeat")
+	if !ok {
+		t.Fatalf("Helper info not found")
+	}
+
+	expected := HelperInfo{
+		Name:        "repeat",
+		Description: "Repeats a string",
+		Params:      []HelperParam{{Name: "str", Type: "string"}, {Name: "arg1", Type: "int"}},
+		Examples:    []string{`{{repeat "GnAK!" 3}}`},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("Unexpected helper info\nexpected:\n\t%#v\ngot:\n\t%#v", expected, info)
+	}
+
+	if min, max := info.Arity(); min != 2 || max != 2 {
+		t.Errorf("Unexpected helper arity: %d, %d", min, max)
+	}
+
+	info, _ = tpl.Helper("bar")
+	if !info.Options || len(info.Params) != 0 || info.Global {
+		t.Errorf("Unexpected helper info: %#v", info)
+	}
+
+	info, _ = tpl.Helper("concat")
+	if min, max := info.Arity(); !info.Global || !info.Variadic || min != 0 || max != -1 || info.Params[0].Type != "...interface {}" {
+		t.Errorf("Unexpected builtin variadic helper info: %#v", info)
+	}
+
+	tpl.RegisterHelper("join", func(options *Options, strs ...string) string { return "" })
+	info, _ = tpl.Helper("join")
+	if min, max := info.Arity(); !info.Options || !info.Variadic || min != 0 || max != -1 || len(info.Params) != 1 || info.Params[0].Type != "...string" {
+		t.Errorf("Unexpected variadic helper with options info: %#v", info)
+	}
+
+	if _, ok := tpl.Helper("unknown"); ok {
+		t.Errorf("Unknown helper should not be found")
+	}
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{foo}}`)
+	tpl.RegisterHelper("if", func() string { return "shadowed" })
+
+	var names []string
+	for _, info := range tpl.Helpers() {
+		names = append(names, info.Name)
+
+		if info.Name == "if" && info.Global {
+			t.Errorf("Template helper should shadow global helper")
+		}
+
+		if info.Name == "each" && info.Description == "" {
+			t.Errorf("Builtin helper should have a description")
+		}
+	}
+
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Errorf("Helpers are not sorted by name: %v", names)
+		}
+	}
+}
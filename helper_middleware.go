@@ -0,0 +1,62 @@
+package raymond
+
+import "reflect"
+
+// HelperCall represents a helper invocation.
+type HelperCall struct {
+	// Name is the name of called helper
+	Name string
+
+	// Options is the options argument of helper, which gives access to parameters, hash arguments, context and private data
+	Options *Options
+}
+
+// HelperFunc invokes a helper and returns its result. A non-nil error aborts template evaluation.
+type HelperFunc func(call *HelperCall) (interface{}, error)
+
+// HelperMiddleware wraps helper invocations.
+//
+// A middleware typically performs some work before and/or after calling next, or returns an error without calling next to reject the invocation.
+type HelperMiddleware func(next HelperFunc) HelperFunc
+
+// UseHelperMiddleware installs middlewares that wrap all helper invocations (including builtin and global helpers) when evaluating that template and its partials.
+//
+// Middlewares are called in the order they are installed: the first one is the outermost.
+//
+// Example, to log all helper calls:
+//
+//	tpl.UseHelperMiddleware(func(next raymond.HelperFunc) raymond.HelperFunc {
+//		return func(call *raymond.HelperCall) (interface{}, error) {
+//			log.Printf("calling helper %s with params %v", call.Name, call.Options.Params())
+//			return next(call)
+//		}
+//	})
+func (tpl *Template) UseHelperMiddleware(middlewares ...HelperMiddleware) {
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	tpl.middlewares.add(middlewares...)
+}
+
+// helperMiddlewares returns installed helper middlewares
+func (tpl *Template) helperMiddlewares() []HelperMiddleware {
+	return tpl.middlewares.load()
+}
+
+// callHelperMiddlewares calls helper function through given middlewares
+func (v *evalVisitor) callHelperMiddlewares(middlewares []HelperMiddleware, name string, helper reflect.Value, options *Options) interface{} {
+	next := func(call *HelperCall) (interface{}, error) {
+		return v.callHelperFunc(call.Name, helper, call.Options), nil
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+
+	result, err := next(&HelperCall{Name: name, Options: options})
+	if err != nil {
+		v.errPanic(err)
+	}
+
+	return result
+}
@@ -0,0 +1,68 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHelperMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+
+	tpl := MustParse(`{{#if ok}}{{echo "foo" 2}}{{/if}} {{> part}}`)
+	tpl.RegisterHelper("echo", echoHelper)
+	tpl.RegisterPartial("part", `{{echo "bar" 1}}`)
+
+	tpl.UseHelperMiddleware(func(next HelperFunc) HelperFunc {
+		return func(call *HelperCall) (interface{}, error) {
+			calls = append(calls, fmt.Sprintf("%s%v", call.Name, call.Options.Params()))
+			return next(call)
+		}
+	}, func(next HelperFunc) HelperFunc {
+		return func(call *HelperCall) (interface{}, error) {
+			result, err := next(call)
+			if call.Name == "echo" {
+				result = strings.ToUpper(Str(result))
+			}
+			return result, err
+		}
+	})
+
+	output := tpl.MustExec(map[string]interface{}{"ok": true})
+	if output != "FOOFOO BAR" {
+		t.Errorf("Unexpected output with helper middlewares: %q", output)
+	}
+
+	expected := "if[true] echo[foo 2] echo[bar 1]"
+	if str := strings.Join(calls, " "); str != expected {
+		t.Errorf("Unexpected helper calls, expected %q, got %q", expected, str)
+	}
+}
+
+func TestHelperMiddlewareError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{echo "foo" 100}}`)
+	tpl.RegisterHelper("echo", echoHelper)
+
+	tpl.UseHelperMiddleware(func(next HelperFunc) HelperFunc {
+		return func(call *HelperCall) (interface{}, error) {
+			if nb, _ := intValue(call.Options.Param(1)); nb > 10 {
+				return nil, fmt.Errorf("too many repetitions: %d", nb)
+			}
+			return next(call)
+		}
+	})
+
+	_, err := tpl.Exec(nil)
+	if err == nil || !strings.Contains(err.Error(), "too many repetitions: 100") {
+		t.Errorf("Helper middleware should reject helper call, got error: %v", err)
+	}
+
+	// middlewares are cloned
+	if _, err := tpl.Clone().Exec(nil); err == nil {
+		t.Errorf("Helper middlewares should be cloned")
+	}
+}
@@ -1,6 +1,10 @@
 package raymond
 
-import "testing"
+import (
+	"math"
+	"strings"
+	"testing"
+)
 
 const (
 	VERBOSE = false
@@ -220,6 +224,252 @@ foo is the same as baz
 there is one
 everything is stringified before comparison`,
 	},
+	{
+		"#times helper",
+		`{{#times 3}}{{@index}}:{{this}}{{#if @first}} first{{/if}}{{#if @last}} last{{/if}} {{/times}}`,
+		nil, nil, nil, nil,
+		`0:0 first 1:1 2:2 last `,
+	},
+	{
+		"#times helper with identifier param",
+		`{{#times nb}}GnAK!{{/times}}`,
+		map[string]interface{}{"nb": 2.0},
+		nil, nil, nil,
+		`GnAK!GnAK!`,
+	},
+	{
+		"#times helper with zero",
+		`{{#times 0}}GnAK!{{else}}nothing{{/times}}`,
+		nil, nil, nil, nil,
+		`nothing`,
+	},
+	{
+		"#times helper with block params",
+		`{{#times 2 as |nb i|}}{{nb}}.{{i}} {{/times}}`,
+		nil, nil, nil, nil,
+		`0.0 1.1 `,
+	},
+	{
+		"#range helper",
+		`{{#range 1 10 2}}{{@index}}:{{this}}{{#if @last}} last{{/if}} {{/range}}`,
+		nil, nil, nil, nil,
+		`0:1 1:3 2:5 3:7 4:9 last `,
+	},
+	{
+		"#range helper with negative step",
+		`{{#range 3 0 -1}}{{this}} {{/range}}`,
+		nil, nil, nil, nil,
+		`3 2 1 `,
+	},
+	{
+		"#range helper without step",
+		`{{#range 1 4}}{{this}} {{/range}}{{#range 3 0}}{{this}} {{/range}}{{#range 2 2}}{{this}}{{else}}empty{{/range}}`,
+		nil, nil, nil, nil,
+		`1 2 3 3 2 1 empty`,
+	},
+	{
+		"#range helper with empty range",
+		`{{#range 5 1 1}}{{this}}{{else}}empty{{/range}}`,
+		nil, nil, nil, nil,
+		`empty`,
+	},
+	{
+		"#range helper with huge bounds",
+		`{{#range 0 to step}}{{@index}}:{{this}}{{#if @last}} last{{/if}} {{/range}}`,
+		map[string]interface{}{"to": math.MaxInt64, "step": 1 << 62},
+		nil, nil, nil,
+		`0:0 1:4611686018427387904 last `,
+	},
+	{
+		"concat helper",
+		`{{concat "foo" 1 true bar}}`,
+		map[string]interface{}{"bar": "<baz>"},
+		nil, nil, nil,
+		`foo1true&lt;baz&gt;`,
+	},
+	{
+		"concat helper without param",
+		`{{concat}}`,
+		nil, nil, nil, nil,
+		``,
+	},
+	{
+		"indent helper",
+		"{{indent 2 text}}|{{indent 0 text}}",
+		map[string]interface{}{"text": "a: 1\nb: 2\n"},
+		nil, nil, nil,
+		"  a: 1\n  b: 2\n|a: 1\nb: 2\n",
+	},
+	{
+		"nindent helper",
+		"resources:{{{nindent 2 (toYaml resources)}}}\nend",
+		map[string]interface{}{"resources": map[string]interface{}{"cpu": "100m", "memory": "1Gi"}},
+		nil, nil, nil,
+		"resources:\n  cpu: 100m\n  memory: 1Gi\nend",
+	},
+	{
+		"#switch helper",
+		`{{#switch status}}{{#case "open"}}Open{{/case}}{{#case "closed"}}Closed{{/case}}{{#default}}Unknown{{/default}}{{/switch}}`,
+		map[string]interface{}{"status": "closed"},
+		nil, nil, nil,
+		`Closed`,
+	},
+	{
+		"#switch helper with default",
+		`{{#switch status}}{{#case "open"}}Open{{/case}}{{#default}}Unknown{{/default}}{{/switch}}`,
+		map[string]interface{}{"status": "draft"},
+		nil, nil, nil,
+		`Unknown`,
+	},
+	{
+		"#switch helper with default first",
+		`{{#switch status}}{{#default}}Unknown{{/default}}{{#case "open"}}Open{{/case}}{{/switch}}`,
+		map[string]interface{}{"status": "open"},
+		nil, nil, nil,
+		`Open`,
+	},
+	{
+		"#switch helper with number",
+		`{{#switch nb}}{{#case 1}}one{{/case}}{{#case 2}}two{{/case}}{{/switch}}`,
+		map[string]interface{}{"nb": 2},
+		nil, nil, nil,
+		`two`,
+	},
+	{
+		"#switch helper only renders first matching case",
+		`{{#switch status}}{{#case "open"}}first{{/case}}{{#case "open"}}second{{/case}}{{/switch}}`,
+		map[string]interface{}{"status": "open"},
+		nil, nil, nil,
+		`first`,
+	},
+	{
+		"#switch helper with else",
+		`{{#switch status}}{{#case "open"}}Open{{/case}}{{else}}nothing{{/switch}}`,
+		map[string]interface{}{"status": "closed"},
+		nil, nil, nil,
+		`nothing`,
+	},
+	{
+		"nested #switch helpers",
+		`{{#switch a}}{{#case "x"}}{{#switch b}}{{#case "y"}}xy{{/case}}{{/switch}}{{/case}}{{#default}}none{{/default}}{{/switch}}`,
+		map[string]interface{}{"a": "x", "b": "z"},
+		nil, nil, nil,
+		``,
+	},
+	{
+		"variadic helper",
+		`{{join ", " "a" b 3}}`,
+		map[string]interface{}{"b": "b"},
+		nil,
+		map[string]interface{}{"join": func(sep string, strs ...string) string { return strings.Join(strs, sep) }},
+		nil,
+		`a, b, 3`,
+	},
+	{
+		"variadic helper with options",
+		`{{join "a" b 3 sep=", "}}`,
+		map[string]interface{}{"b": "b"},
+		nil,
+		map[string]interface{}{"join": func(options *Options, strs ...string) string { return strings.Join(strs, options.HashStr("sep")) }},
+		nil,
+		`a, b, 3`,
+	},
+	{
+		"concat helper in subexpression",
+		`{{> (concat "icons/" name)}}`,
+		map[string]interface{}{"name": "star"},
+		nil, nil,
+		map[string]string{"icons/star": "<i>*</i>"},
+		`<i>*</i>`,
+	},
+}
+
+var helperErrors = []Test{
+	{
+		"randomInt helper with invalid range",
+		`{{randomInt 10 10}}`,
+		nil, nil, nil, nil,
+		`randomInt helper max must be greater than min`,
+	},
+	{
+		"#case helper outside #switch",
+		`{{#case "foo"}}bar{{/case}}`,
+		nil, nil, nil, nil,
+		`case helper must be used inside a switch block`,
+	},
+	{
+		"variadic helper with wrong number of arguments",
+		`{{join}}`,
+		nil, nil,
+		map[string]interface{}{"join": func(sep string, strs ...string) string { return strings.Join(strs, sep) }},
+		nil,
+		`Helper 'join' called with wrong number of arguments, needed at least 1 but got 0`,
+	},
+	{
+		"variadic helper with hash arguments",
+		`{{join ", " "a" b=1}}`,
+		nil, nil,
+		map[string]interface{}{"join": func(sep string, strs ...string) string { return strings.Join(strs, sep) }},
+		nil,
+		`Helper 'join' called with hash arguments, but it does not expect an options argument`,
+	},
+	{
+		"#times helper with invalid param",
+		`{{#times "foo"}}GnAK!{{/times}}`,
+		nil, nil, nil, nil,
+		`times helper expects a number`,
+	},
+	{
+		"#times helper with overflowing number",
+		`{{#times nb}}GnAK!{{else}}nothing{{/times}}`,
+		map[string]interface{}{"nb": 1e300},
+		nil, nil, nil,
+		`times helper expects a number`,
+	},
+	{
+		"#times helper with overflowing literal",
+		`{{#times 9223372036854775808}}GnAK!{{/times}}`,
+		nil, nil, nil, nil,
+		`times helper expects a number`,
+	},
+	{
+		"#times helper with infinite number",
+		`{{#times nb}}GnAK!{{/times}}`,
+		map[string]interface{}{"nb": math.Inf(1)},
+		nil, nil, nil,
+		`times helper expects a number`,
+	},
+	{
+		"indent helper with invalid count",
+		`{{indent "foo" "bar"}}`,
+		nil, nil, nil, nil,
+		`indent helper expects a positive number, got: "foo"`,
+	},
+	{
+		"nindent helper with negative count",
+		`{{nindent -1 "bar"}}`,
+		nil, nil, nil, nil,
+		`nindent helper expects a positive number, got: "-1"`,
+	},
+	{
+		"#range helper with zero step",
+		`{{#range 1 10 0}}{{this}}{{/range}}`,
+		nil, nil, nil, nil,
+		`range helper step can't be zero`,
+	},
+	{
+		"#range helper with too many arguments",
+		`{{#range 1 10 1 2}}{{this}}{{/range}}`,
+		nil, nil, nil, nil,
+		`range helper expects at most 3 arguments, got: 4`,
+	},
+	{
+		"#range helper with too many values",
+		`{{#range from to 1}}{{this}}{{/range}}`,
+		map[string]interface{}{"from": math.MinInt64, "to": math.MaxInt64},
+		nil, nil, nil,
+		`range helper has too many values`,
+	},
 }
 
 //
@@ -232,14 +482,74 @@ func TestHelper(t *testing.T) {
 	launchTests(t, helperTests)
 }
 
+func TestNamespace(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{str.upper name}} {{str/upper "b"}} {{#str.wrap "*"}}{{str.upper name}}{{/str.wrap}} {{str.name}}`)
+	tpl.RegisterNamespace("str", map[string]interface{}{
+		"upper": strings.ToUpper,
+		"wrap": func(with string, options *Options) string {
+			return with + options.Fn() + with
+		},
+	})
+
+	ctx := map[string]interface{}{
+		"name": "foo",
+		"str":  map[string]string{"name": "not a helper"},
+	}
+
+	expected := "FOO B *FOO* not a helper"
+	if output := tpl.MustExec(ctx); output != expected {
+		t.Errorf("Failed to call namespaced helpers, expected %q, got %q", expected, output)
+	}
+}
+
+func TestGlobalNamespace(t *testing.T) {
+	RegisterNamespace("testnamespace", map[string]interface{}{
+		"bar": barHelper,
+	})
+	defer RemoveHelper("testnamespace.bar")
+
+	if output := MustRender(`{{testnamespace.bar}} {{bar}}`, nil); output != "bar " {
+		t.Errorf("Failed to call global namespaced helper: %q", output)
+	}
+}
+
+func TestRegisterHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{a}}{{b}}`)
+	tpl.RegisterHelper("b", func() string { return "b" })
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Registering an already registered helper should panic")
+		}
+
+		// batch is registered at once, or not at all
+		if tpl.findHelper("a") != zero {
+			t.Errorf("Helpers batch partially registered")
+		}
+	}()
+
+	tpl.RegisterHelpers(map[string]interface{}{
+		"a": func() string { return "a" },
+		"b": func() string { return "b" },
+	})
+}
+
+func TestHelperErrors(t *testing.T) {
+	launchErrorTests(t, helperErrors)
+}
+
 func TestRemoveHelper(t *testing.T) {
 	RegisterHelper("testremovehelper", func() string { return "" })
-	if _, ok := helpers["testremovehelper"]; !ok {
+	if _, ok := helpers.load()["testremovehelper"]; !ok {
 		t.Error("Failed to register global helper")
 	}
 
 	RemoveHelper("testremovehelper")
-	if _, ok := helpers["testremovehelper"]; ok {
+	if _, ok := helpers.load()["testremovehelper"]; ok {
 		t.Error("Failed to remove global helper")
 	}
 }
@@ -254,6 +564,8 @@ type Author struct {
 }
 
 func TestHelperCtx(t *testing.T) {
+	skipTiny(t)
+
 	RegisterHelper("template", func(name string, options *Options) SafeString {
 		context := options.Ctx()
 
@@ -271,3 +583,20 @@ func TestHelperCtx(t *testing.T) {
 		t.Errorf("Failed to render template in helper: %q", result)
 	}
 }
+
+func TestHelperCtxUndefined(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{ctx}}`)
+	tpl.RegisterHelper("ctx", func(options *Options) string {
+		if options.Ctx() != nil {
+			return "defined"
+		}
+
+		return "undefined"
+	})
+
+	if result := tpl.MustExec(nil); result != "undefined" {
+		t.Errorf("Expected a nil context, got: %q", result)
+	}
+}
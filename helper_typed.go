@@ -0,0 +1,89 @@
+//go:build go1.18
+// +build go1.18
+
+package raymond
+
+import "reflect"
+
+// Helper0 adapts a typed function without argument to a helper.
+func Helper0[R any](fn func() R) interface{} {
+	return func(options *Options) interface{} {
+		return fn()
+	}
+}
+
+// Helper1 adapts a typed function with one argument to a helper.
+//
+// Argument is converted to the expected type (numbers, strings, booleans and slices are converted), and evaluation fails with a descriptive error if that is not possible.
+func Helper1[A, R any](fn func(A) R) interface{} {
+	return func(a interface{}, options *Options) interface{} {
+		return fn(typedArg[A](options, 0, a))
+	}
+}
+
+// Helper2 adapts a typed function with two arguments to a helper.
+//
+// Arguments are converted to the expected types (numbers, strings, booleans and slices are converted), and evaluation fails with a descriptive error if that is not possible.
+func Helper2[A, B, R any](fn func(A, B) R) interface{} {
+	return func(a, b interface{}, options *Options) interface{} {
+		return fn(typedArg[A](options, 0, a), typedArg[B](options, 1, b))
+	}
+}
+
+// Helper3 adapts a typed function with three arguments to a helper.
+//
+// Arguments are converted to the expected types (numbers, strings, booleans and slices are converted), and evaluation fails with a descriptive error if that is not possible.
+func Helper3[A, B, C, R any](fn func(A, B, C) R) interface{} {
+	return func(a, b, c interface{}, options *Options) interface{} {
+		return fn(typedArg[A](options, 0, a), typedArg[B](options, 1, b), typedArg[C](options, 2, c))
+	}
+}
+
+// RegisterHelper0 registers a global helper from a typed function without argument.
+func RegisterHelper0[R any](name string, fn func() R) {
+	RegisterHelper(name, Helper0(fn))
+}
+
+// RegisterHelper1 registers a global helper from a typed function with one argument.
+//
+// Example:
+//
+//	raymond.RegisterHelper1("double", func(nb int) int { return nb * 2 })
+func RegisterHelper1[A, R any](name string, fn func(A) R) {
+	RegisterHelper(name, Helper1(fn))
+}
+
+// RegisterHelper2 registers a global helper from a typed function with two arguments.
+//
+// Example:
+//
+//	raymond.RegisterHelper2("repeat", func(str string, nb int) string { return strings.Repeat(str, nb) })
+func RegisterHelper2[A, B, R any](name string, fn func(A, B) R) {
+	RegisterHelper(name, Helper2(fn))
+}
+
+// RegisterHelper3 registers a global helper from a typed function with three arguments.
+func RegisterHelper3[A, B, C, R any](name string, fn func(A, B, C) R) {
+	RegisterHelper(name, Helper3(fn))
+}
+
+// typedArg converts helper argument at given position to expected type, or fails evaluation
+func typedArg[T any](options *Options, pos int, value interface{}) T {
+	var result T
+
+	argType := reflect.TypeOf(&result).Elem()
+
+	arg, err := convertArg(value, argType)
+	if err != nil {
+		name := ""
+		if expr := options.eval.curExpr(); expr != nil {
+			name = expr.Canonical()
+		}
+
+		options.eval.errorf("Helper '%s' called with argument %d that can't be converted to %s: %s", name, pos, argType, err)
+	}
+
+	reflect.ValueOf(&result).Elem().Set(arg)
+
+	return result
+}
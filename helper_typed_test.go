@@ -0,0 +1,93 @@
+//go:build go1.18
+// +build go1.18
+
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+var typedHelperTests = []Test{
+	{
+		"typed helper without argument",
+		`{{foo}}`,
+		nil, nil,
+		map[string]interface{}{"foo": Helper0(func() SafeString { return "<b>foo</b>" })},
+		nil,
+		`<b>foo</b>`,
+	},
+	{
+		"typed helper with number conversion",
+		`{{double nb}} {{double "21"}}`,
+		map[string]interface{}{"nb": 1.0},
+		nil,
+		map[string]interface{}{"double": Helper1(func(nb int) int { return nb * 2 })},
+		nil,
+		`2 42`,
+	},
+	{
+		"typed helper with two arguments",
+		`{{repeat "ab" 2}}`,
+		nil, nil,
+		map[string]interface{}{"repeat": Helper2(strings.Repeat)},
+		nil,
+		`abab`,
+	},
+	{
+		"typed helper with three arguments",
+		`{{#if (between nb 1 10)}}yes{{/if}}`,
+		map[string]interface{}{"nb": "5"},
+		nil,
+		map[string]interface{}{"between": Helper3(func(nb, min, max float64) bool { return nb >= min && nb <= max })},
+		nil,
+		`yes`,
+	},
+	{
+		"typed helper with slice argument",
+		`{{join items}}`,
+		map[string]interface{}{"items": []interface{}{"a", 2}},
+		nil,
+		map[string]interface{}{"join": Helper1(func(items []string) string { return strings.Join(items, ",") })},
+		nil,
+		`a,2`,
+	},
+}
+
+var typedHelperErrors = []Test{
+	{
+		"typed helper with invalid argument",
+		`{{double "foo"}}`,
+		nil, nil,
+		map[string]interface{}{"double": Helper1(func(nb int) int { return nb * 2 })},
+		nil,
+		`Helper 'double' called with argument 0 that can't be converted to int`,
+	},
+	{
+		"typed helper with wrong number of arguments",
+		`{{double 1 2}}`,
+		nil, nil,
+		map[string]interface{}{"double": Helper1(func(nb int) int { return nb * 2 })},
+		nil,
+		`Helper 'double' called with wrong number of arguments, needed 1 but got 2`,
+	},
+}
+
+func TestTypedHelper(t *testing.T) {
+	t.Parallel()
+
+	launchTests(t, typedHelperTests)
+}
+
+func TestTypedHelperErrors(t *testing.T) {
+	launchErrorTests(t, typedHelperErrors)
+}
+
+func TestRegisterTypedHelper(t *testing.T) {
+	RegisterHelper2("testtypedhelper", func(a string, b int) string { return strings.Repeat(a, b) })
+	defer RemoveHelper("testtypedhelper")
+
+	if output := MustRender(`{{testtypedhelper "x" 3}}`, nil); output != "xxx" {
+		t.Errorf("Failed to register typed helper: %q", output)
+	}
+}
@@ -0,0 +1,58 @@
+package httprender
+
+import (
+	"net/http"
+)
+
+// Load implements the Views interface of the Fiber framework. Templates are already parsed, so it does nothing.
+//
+// A renderer is a Fiber template engine:
+//
+//	app := fiber.New(fiber.Config{Views: render})
+//
+//	app.Get("/", func(c *fiber.Ctx) error {
+//		return c.Render("page", data)
+//	})
+func (r *Renderer) Load() error {
+	return nil
+}
+
+// Response is the HTML response of a template, that implements the render.Render interface of the Gin framework. See Renderer.Instance().
+type Response struct {
+	renderer *Renderer
+	name     string
+	data     interface{}
+}
+
+// Instance returns the HTML response of the template with given name and data, to implement the render.HTMLRender interface of the Gin framework.
+//
+// That interface returns a type of the Gin package, so it is implemented by a one line wrapper:
+//
+//	type ginRender struct{ *httprender.Renderer }
+//
+//	func (r ginRender) Instance(name string, data any) render.Render {
+//		return r.Renderer.Instance(name, data)
+//	}
+//
+//	router.HTMLRender = ginRender{render}
+func (r *Renderer) Instance(name string, data interface{}) *Response {
+	return &Response{
+		renderer: r,
+		name:     name,
+		data:     data,
+	}
+}
+
+// Render writes the response, whose status is already set.
+func (resp *Response) Render(w http.ResponseWriter) error {
+	resp.WriteContentType(w)
+
+	return resp.renderer.Render(w, resp.name, resp.data)
+}
+
+// WriteContentType sets the Content-Type header of response, unless it is already set.
+func (resp *Response) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", ContentTypeHTML)
+	}
+}
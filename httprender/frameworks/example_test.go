@@ -0,0 +1,51 @@
+package frameworks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/httprender"
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+)
+
+// newRenderer returns a renderer of a page template
+func newRenderer() *httprender.Renderer {
+	return httprender.New(map[string]*raymond.Template{
+		"page": raymond.MustParse("<h1>{{title}}</h1>"),
+	}, httprender.Options{})
+}
+
+func Example_gin() {
+	gin.SetMode(gin.ReleaseMode)
+
+	router := gin.New()
+	router.HTMLRender = ginRender{newRenderer()}
+
+	router.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "page", map[string]string{"title": "Gin"})
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	fmt.Println(rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	// Output: 200 text/html; charset=utf-8 <h1>Gin</h1>
+}
+
+func Example_echo() {
+	e := echo.New()
+	e.Renderer = echoRender{newRenderer()}
+
+	e.GET("/", func(c echo.Context) error {
+		return c.Render(http.StatusOK, "page", map[string]string{"title": "Echo"})
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	fmt.Println(rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	// Output: 200 text/html; charset=UTF-8 <h1>Echo</h1>
+}
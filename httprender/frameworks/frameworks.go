@@ -0,0 +1,31 @@
+// Package frameworks holds the one line wrappers that adapt an httprender.Renderer to the Gin and Echo frameworks, as documented in the README, so that they are compiled against the interfaces of those frameworks.
+//
+// It is a separate module, so that raymond does not depend on those frameworks. Run its tests from its directory.
+package frameworks
+
+import (
+	"io"
+
+	"github.com/aymerick/raymond/httprender"
+	"github.com/gin-gonic/gin/render"
+	"github.com/labstack/echo/v4"
+)
+
+// ginRender implements the render.HTMLRender interface of Gin
+type ginRender struct{ *httprender.Renderer }
+
+func (r ginRender) Instance(name string, data any) render.Render {
+	return r.Renderer.Instance(name, data)
+}
+
+// echoRender implements the echo.Renderer interface of Echo
+type echoRender struct{ *httprender.Renderer }
+
+func (r echoRender) Render(w io.Writer, name string, data any, c echo.Context) error {
+	return r.Renderer.Render(w, name, data)
+}
+
+var (
+	_ render.HTMLRender = ginRender{}
+	_ echo.Renderer     = echoRender{}
+)
@@ -0,0 +1,66 @@
+package httprender
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fiberViews is the Views interface of the Fiber framework
+type fiberViews interface {
+	Load() error
+	Render(io.Writer, string, interface{}, ...string) error
+}
+
+// ginRender is the render.Render interface of the Gin framework
+type ginRender interface {
+	Render(http.ResponseWriter) error
+	WriteContentType(w http.ResponseWriter)
+}
+
+func TestFiberViews(t *testing.T) {
+	t.Parallel()
+
+	var views fiberViews = New(testTemplates(), Options{Layout: "layout"})
+
+	if err := views.Load(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	data := map[string]string{"title": "Hello"}
+
+	for _, test := range []struct {
+		layouts  []string
+		expected string
+	}{
+		{nil, "<title>Hello</title><main><h1>Hello</h1></main>"},
+		{[]string{""}, "<h1>Hello</h1>"},
+		{[]string{"page"}, "<h1>Hello</h1>"},
+	} {
+		var buf bytes.Buffer
+		if err := views.Render(&buf, "page", data, test.layouts...); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if buf.String() != test.expected {
+			t.Errorf("Unexpected output with layouts %q: %q", test.layouts, buf.String())
+		}
+	}
+}
+
+func TestGinRender(t *testing.T) {
+	t.Parallel()
+
+	var resp ginRender = New(testTemplates(), Options{}).Instance("page", map[string]string{"title": "Hello"})
+
+	rec := httptest.NewRecorder()
+	if err := resp.Render(rec); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if (rec.Body.String() != "<h1>Hello</h1>") || (rec.Header().Get("Content-Type") != ContentTypeHTML) {
+		t.Errorf("Unexpected response: %q %s", rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+}
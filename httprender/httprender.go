@@ -0,0 +1,172 @@
+// Package httprender renders raymond templates in HTTP responses.
+//
+// A Renderer wraps a template set, eg. returned by raymond.ParseFS(), and renders its templates by name, optionally wrapped in a layout:
+//
+//	render := httprender.New(templates, httprender.Options{Layout: "layout"})
+//
+//	func handler(w http.ResponseWriter, req *http.Request) {
+//		render.HTML(w, http.StatusOK, "page", data)
+//	}
+package httprender
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aymerick/raymond"
+)
+
+// Default content types of responses.
+const (
+	ContentTypeHTML = "text/html; charset=utf-8"
+	ContentTypeText = "text/plain; charset=utf-8"
+)
+
+// Options represents renderer options.
+type Options struct {
+	// Layout is the name of the template that wraps rendered templates. The layout is rendered with the same context, and the output of the wrapped template is available as {{@body}}. If empty, templates are not wrapped.
+	Layout string
+
+	// Streaming writes responses as templates are rendered, instead of buffering them: responses start sooner and use less memory, but the status and headers are already sent when an error occurs, so ErrorHandler is not called and the response is truncated. With a layout, the wrapped template is still buffered, and its errors are handled.
+	Streaming bool
+
+	// ErrorHandler writes the response of a failed render. If nil, a 500 Internal Server Error response is sent, without error details.
+	ErrorHandler func(w http.ResponseWriter, err error)
+
+	// ExecOptions are the evaluation options of templates.
+	ExecOptions raymond.ExecOptions
+}
+
+// Renderer renders the templates of a template set in HTTP responses. It is safe for concurrent use.
+type Renderer struct {
+	templates map[string]*raymond.Template
+	opts      Options
+}
+
+// New instanciates a new renderer of given templates, indexed by name, with given options.
+func New(templates map[string]*raymond.Template, opts Options) *Renderer {
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = internalError
+	}
+
+	return &Renderer{
+		templates: templates,
+		opts:      opts,
+	}
+}
+
+// internalError is the default error handler
+func internalError(w http.ResponseWriter, err error) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// WithLayout returns a renderer of the same templates, with given layout, eg. an empty one to render a fragment without layout.
+func (r *Renderer) WithLayout(layout string) *Renderer {
+	result := *r
+	result.opts.Layout = layout
+
+	return &result
+}
+
+// HTML renders the template with given name and data as an HTML response, with given status.
+//
+// The error of a failed render is returned, once ErrorHandler has written the response.
+func (r *Renderer) HTML(w http.ResponseWriter, status int, name string, data interface{}) error {
+	return r.respond(w, status, ContentTypeHTML, name, data)
+}
+
+// Text renders the template with given name and data as a plain text response, with given status.
+func (r *Renderer) Text(w http.ResponseWriter, status int, name string, data interface{}) error {
+	return r.respond(w, status, ContentTypeText, name, data)
+}
+
+// Render renders the template with given name and data to given writer, wrapped in layout if any.
+//
+// If layouts are given, the first one replaces the Layout option, an empty name disabling layout. This is the signature of the Views interface of the Fiber framework.
+func (r *Renderer) Render(w io.Writer, name string, data interface{}, layouts ...string) error {
+	layout := r.opts.Layout
+	if len(layouts) > 0 {
+		layout = layouts[0]
+	}
+
+	tpl, opts, err := r.prepare(name, data, layout)
+	if err != nil {
+		return err
+	}
+
+	return tpl.ExecToWithOptions(w, data, opts)
+}
+
+// prepare returns the template to render for the template with given name and data wrapped in given layout, ie. the layout if any, and its evaluation options
+//
+// The wrapped template is rendered by this call, and its output is passed to the layout as @body.
+func (r *Renderer) prepare(name string, data interface{}, layoutName string) (*raymond.Template, raymond.ExecOptions, error) {
+	opts := r.opts.ExecOptions
+
+	tpl, err := r.lookup(name)
+	if (err != nil) || (layoutName == "") {
+		return tpl, opts, err
+	}
+
+	layout, err := r.lookup(layoutName)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	body, err := tpl.ExecWithOptions(data, opts)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	// the data frame of options is shared by all renders
+	if opts.Data != nil {
+		opts.Data = opts.Data.Copy()
+	} else {
+		opts.Data = raymond.NewDataFrame()
+	}
+
+	opts.Data.Set("body", raymond.SafeString(body))
+
+	return layout, opts, nil
+}
+
+// lookup returns the template with given name
+func (r *Renderer) lookup(name string) (*raymond.Template, error) {
+	tpl, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("httprender: template %q not found", name)
+	}
+
+	return tpl, nil
+}
+
+// respond renders the template with given name and data as a response with given status and content type
+func (r *Renderer) respond(w http.ResponseWriter, status int, contentType string, name string, data interface{}) error {
+	if r.opts.Streaming {
+		tpl, opts, err := r.prepare(name, data, r.opts.Layout)
+		if err != nil {
+			r.opts.ErrorHandler(w, err)
+			return err
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+
+		return tpl.ExecToWithOptions(w, data, opts)
+	}
+
+	var buf bytes.Buffer
+
+	if err := r.Render(&buf, name, data); err != nil {
+		r.opts.ErrorHandler(w, err)
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,110 @@
+package httprender
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+func testTemplates() map[string]*raymond.Template {
+	fail := raymond.MustParse("before {{fail}}")
+	fail.RegisterHelper("fail", func() string { panic(errors.New("boom")) })
+
+	return map[string]*raymond.Template{
+		"layout": raymond.MustParse("<title>{{title}}</title><main>{{@body}}</main>"),
+		"page":   raymond.MustParse("<h1>{{title}}</h1>"),
+		"fail":   fail,
+	}
+}
+
+func TestHTML(t *testing.T) {
+	t.Parallel()
+
+	render := New(testTemplates(), Options{Layout: "layout"})
+	data := map[string]string{"title": "Hello"}
+
+	rec := httptest.NewRecorder()
+	if err := render.HTML(rec, http.StatusCreated, "page", data); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Unexpected status: %d", rec.Code)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != ContentTypeHTML {
+		t.Errorf("Unexpected content type: %s", ct)
+	}
+
+	if body := rec.Body.String(); body != "<title>Hello</title><main><h1>Hello</h1></main>" {
+		t.Errorf("Unexpected body: %q", body)
+	}
+
+	// without layout
+	rec = httptest.NewRecorder()
+	if err := render.WithLayout("").Text(rec, http.StatusOK, "page", data); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if (rec.Body.String() != "<h1>Hello</h1>") || (rec.Header().Get("Content-Type") != ContentTypeText) {
+		t.Errorf("Unexpected response: %q %s", rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestHTMLError(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"fail", "missing"} {
+		for _, opts := range []Options{{}, {Layout: "layout", Streaming: true}} {
+			rec := httptest.NewRecorder()
+			if err := New(testTemplates(), opts).HTML(rec, http.StatusOK, name, nil); err == nil {
+				t.Errorf("Expected an error for template %q with options %+v", name, opts)
+			}
+
+			if (rec.Code != http.StatusInternalServerError) || strings.Contains(rec.Body.String(), "before") {
+				t.Errorf("Unexpected response for template %q with options %+v: %d %q", name, opts, rec.Code, rec.Body.String())
+			}
+		}
+	}
+
+	var handled error
+
+	render := New(testTemplates(), Options{ErrorHandler: func(w http.ResponseWriter, err error) {
+		handled = err
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}})
+
+	rec := httptest.NewRecorder()
+	if err := render.HTML(rec, http.StatusOK, "fail", nil); (err == nil) || (err != handled) || (rec.Code != http.StatusServiceUnavailable) {
+		t.Errorf("Expected error to be handled, got: %v %d", err, rec.Code)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	t.Parallel()
+
+	render := New(testTemplates(), Options{Streaming: true})
+
+	rec := httptest.NewRecorder()
+	if err := render.HTML(rec, http.StatusOK, "page", map[string]string{"title": "Hello"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if (rec.Code != http.StatusOK) || (rec.Body.String() != "<h1>Hello</h1>") {
+		t.Errorf("Unexpected response: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// status is already sent when a streamed template fails
+	rec = httptest.NewRecorder()
+	if err := render.HTML(rec, http.StatusOK, "fail", nil); err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Unexpected status: %d", rec.Code)
+	}
+}
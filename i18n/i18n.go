@@ -0,0 +1,380 @@
+// Package i18n provides a translation helper for raymond templates, backed by ICU MessageFormat message catalogs.
+//
+// Messages are loaded in a Catalog, from JSON or TOML files, and the `t` helper formats them with its hash arguments:
+//
+//	catalog := i18n.NewCatalog("en")
+//	if err := catalog.LoadFS(os.DirFS("locales"), "."); err != nil {
+//		return err
+//	}
+//
+//	tpl.RegisterHelpers(catalog.Helpers())
+//
+//	// {{t "inbox" count=unread name=user.name}}
+//	// with "inbox": "{name}, you have {count, plural, =0 {no messages} one {# message} other {# messages}}"
+//
+// The locale of a render is given by the `locale` hash argument of the helper, or by the `@locale` data variable, that may be an Accept-Language header value: it is negotiated with the locales of catalog, and defaults to the default locale of catalog.
+//
+// Messages support the ICU MessageFormat syntax: simple `{name}` arguments, `{n, number}` arguments with the optional `integer` and `percent` styles, and `plural`, `selectordinal` and `select` arguments, with apostrophe quoting. Plural categories follow the CLDR rules of the language of the message, for the languages listed in languages; other languages only have the "other" category.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aymerick/raymond"
+)
+
+// HelperName is the name of the translation helper registered by Catalog.Helpers().
+const HelperName = "t"
+
+// LocaleData is the name of the data variable that holds the locale of a render, ie. @locale.
+const LocaleData = "locale"
+
+// Catalog represents the translated messages of several locales.
+//
+// A catalog is safe for concurrent use, so that messages are loaded while templates are rendered.
+type Catalog struct {
+	defaultLocale string
+
+	mutex    sync.RWMutex
+	messages map[string]map[string]message
+}
+
+// NewCatalog instantiates a new catalog, with given default locale, eg. "en".
+func NewCatalog(defaultLocale string) *Catalog {
+	return &Catalog{
+		defaultLocale: canonicalLocale(defaultLocale),
+		messages:      make(map[string]map[string]message),
+	}
+}
+
+// DefaultLocale returns the locale used when no locale is negotiated, and when a message is missing from the negotiated locale.
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+// AddMessages adds messages to given locale. Messages are ICU MessageFormat patterns, by key.
+//
+// Nothing is added if a message is invalid.
+func (c *Catalog) AddMessages(locale string, messages map[string]string) error {
+	locale = canonicalLocale(locale)
+
+	parsed := make(map[string]message, len(messages))
+
+	for key, source := range messages {
+		msg, err := parseMessage(source)
+		if err != nil {
+			return fmt.Errorf("i18n: %s: message %q: %s", locale, key, err)
+		}
+
+		parsed[key] = msg
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]message, len(parsed))
+	}
+
+	for key, msg := range parsed {
+		c.messages[locale][key] = msg
+	}
+
+	return nil
+}
+
+// LoadJSON adds the messages of given JSON catalog to given locale.
+//
+// Nested objects are flattened with dotted keys, so that `{"inbox": {"title": "Inbox"}}` defines the "inbox.title" message.
+func (c *Catalog) LoadJSON(locale string, data []byte) error {
+	var catalog map[string]interface{}
+
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("i18n: %s: %s", canonicalLocale(locale), err)
+	}
+
+	messages := make(map[string]string)
+
+	if err := flatten(catalog, "", messages); err != nil {
+		return fmt.Errorf("i18n: %s: %s", canonicalLocale(locale), err)
+	}
+
+	return c.AddMessages(locale, messages)
+}
+
+// flatten adds the string values of given JSON object to result, by dotted key
+func flatten(object map[string]interface{}, prefix string, result map[string]string) error {
+	for key, value := range object {
+		switch value := value.(type) {
+		case string:
+			result[prefix+key] = value
+		case map[string]interface{}:
+			if err := flatten(value, prefix+key+".", result); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %q must be a string", prefix+key)
+		}
+	}
+
+	return nil
+}
+
+// LoadTOML adds the messages of given TOML catalog to given locale.
+//
+// Tables and dotted keys define dotted message keys, so that `title` in the `[inbox]` table defines the "inbox.title" message. Only string values are supported.
+func (c *Catalog) LoadTOML(locale string, data []byte) error {
+	messages, err := parseTOML(string(data))
+	if err != nil {
+		return fmt.Errorf("i18n: %s: %s", canonicalLocale(locale), err)
+	}
+
+	return c.AddMessages(locale, messages)
+}
+
+// LoadFS loads the catalogs of given directory of a file system, eg. an embed.FS. Catalogs are named after their locale, with a ".json" or ".toml" extension, eg. "fr-CA.json".
+//
+// Errors are prefixed with the path of the invalid file.
+func (c *Catalog) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || ((ext != ".json") && (ext != ".toml")) {
+			continue
+		}
+
+		filePath := path.Join(dir, entry.Name())
+
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+
+		locale := strings.TrimSuffix(entry.Name(), ext)
+
+		if ext == ".json" {
+			err = c.LoadJSON(locale, data)
+		} else {
+			err = c.LoadTOML(locale, data)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+
+	return nil
+}
+
+// Locales returns the sorted locales of catalog.
+func (c *Catalog) Locales() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	result := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		result = append(result, locale)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Negotiate returns the catalog locale that best matches given preferred locales, or the default locale if none matches.
+//
+// Each preference is a locale or an Accept-Language header value, eg. "fr-CA,fr;q=0.9,en;q=0.8". A preference matches a catalog locale with the same tag or with a parent tag, eg. "fr-CA" matches "fr", and then a catalog locale of the same language, eg. "fr" matches "fr-FR".
+func (c *Catalog) Negotiate(preferences ...string) string {
+	var tags []string
+	for _, pref := range preferences {
+		tags = append(tags, parseAcceptLanguage(pref)...)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, tag := range tags {
+		for _, candidate := range parentLocales(tag) {
+			if _, ok := c.messages[candidate]; ok {
+				return candidate
+			}
+		}
+	}
+
+	for _, tag := range tags {
+		var matches []string
+		for locale := range c.messages {
+			if baseLanguage(locale) == baseLanguage(tag) {
+				matches = append(matches, locale)
+			}
+		}
+
+		if len(matches) > 0 {
+			sort.Strings(matches)
+			return matches[0]
+		}
+	}
+
+	return c.defaultLocale
+}
+
+// Translate formats the message of given key in given locale, with given arguments.
+//
+// If the message is missing from given locale, it is looked up in its parent locales, eg. "fr" for "fr-CA", and then in the default locale. An error is returned if the message is missing, or if an argument of a plural or number placeholder is not a number.
+func (c *Catalog) Translate(locale string, key string, args map[string]interface{}) (string, error) {
+	locale = canonicalLocale(locale)
+
+	c.mutex.RLock()
+
+	var msg message
+	var msgLocale string
+
+	for _, candidate := range append(parentLocales(locale), parentLocales(c.defaultLocale)...) {
+		if m, ok := c.messages[candidate][key]; ok {
+			msg, msgLocale = m, candidate
+			break
+		}
+	}
+
+	c.mutex.RUnlock()
+
+	if msg == nil {
+		return "", fmt.Errorf("i18n: message %q not found for locale %q", key, locale)
+	}
+
+	var buf strings.Builder
+
+	if err := msg.format(&buf, lookupLanguage(msgLocale), args, ""); err != nil {
+		return "", fmt.Errorf("i18n: %s: message %q: %s", msgLocale, key, err)
+	}
+
+	return buf.String(), nil
+}
+
+// Helpers returns the helpers of catalog, to register them with raymond.RegisterHelpers() or Template.RegisterHelpers().
+//
+// The `t` helper formats the message of its key parameter with its hash arguments, eg. `{{t "inbox" count=3}}`. The `locale` hash argument, or else the `@locale` data variable, is negotiated to select the locale. The output is escaped, like the output of any helper returning a string.
+func (c *Catalog) Helpers() map[string]interface{} {
+	return map[string]interface{}{HelperName: c.helper}
+}
+
+// helper is the translation helper
+func (c *Catalog) helper(key string, options *raymond.Options) string {
+	args := options.Hash()
+
+	pref := options.DataStr(LocaleData)
+
+	if locale, ok := args[LocaleData]; ok {
+		pref = raymond.Str(locale)
+
+		args = make(map[string]interface{}, len(options.Hash()))
+		for name, value := range options.Hash() {
+			if name != LocaleData {
+				args[name] = value
+			}
+		}
+	}
+
+	result, err := c.Translate(c.Negotiate(pref), key, args)
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+// canonicalLocale returns the canonical form of given locale tag, eg. "zh-Hant-TW" for "zh_hant_tw"
+func canonicalLocale(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// baseLanguage returns the language subtag of given locale, eg. "fr" for "fr-CA"
+func baseLanguage(locale string) string {
+	if pos := strings.IndexAny(locale, "-_"); pos >= 0 {
+		locale = locale[:pos]
+	}
+
+	return strings.ToLower(locale)
+}
+
+// parentLocales returns given canonical locale followed by its parents, eg. "zh-Hant-TW", "zh-Hant" and "zh"
+func parentLocales(locale string) []string {
+	result := []string{locale}
+
+	for {
+		pos := strings.LastIndexByte(locale, '-')
+		if pos < 0 {
+			return result
+		}
+
+		locale = locale[:pos]
+		result = append(result, locale)
+	}
+}
+
+// parseAcceptLanguage returns the canonical locales of given Accept-Language header value, sorted by decreasing quality
+//
+// Wildcards and locales with a zero quality are ignored.
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		locale  string
+		quality float64
+	}
+
+	var tags []tag
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		t := tag{locale: strings.TrimSpace(fields[0]), quality: 1}
+
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					t.quality = q
+				}
+			}
+		}
+
+		if (t.locale != "") && (t.locale != "*") && (t.quality > 0) {
+			t.locale = canonicalLocale(t.locale)
+			tags = append(tags, t)
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.locale
+	}
+
+	return result
+}
@@ -0,0 +1,182 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aymerick/raymond"
+)
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+
+	fsys := fstest.MapFS{
+		"locales/en.json": {Data: []byte(`{
+			"inbox": "{name}, you have {count, plural, =0 {no messages} one {# message} other {# messages}}",
+			"rank": {"place": "You finished {place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}"},
+			"invite": "{gender, select, female {She} male {He} other {They}} invited {guests, plural, offset:1 =0 {nobody} =1 {{host}} one {{host} and # other} other {{host} and # others}}",
+			"quote": "It''s '{literal}' {n, number} {ratio, number, percent}"
+		}`)},
+		"locales/fr.toml": {Data: []byte(`
+# French messages
+inbox = "{name}, vous avez {count, plural, =0 {aucun message} one {# message} other {# messages}}"
+
+[rank]
+place = '''
+Vous êtes {place, selectordinal, one {#er} other {#e}}'''
+`)},
+		"locales/ru.toml":   {Data: []byte(`inbox = "{count, plural, one {# сообщение} few {# сообщения} many {# сообщений} other {# сообщения}}"`)},
+		"locales/README.md": {Data: []byte(`not a catalog`)},
+	}
+
+	catalog := NewCatalog("en")
+	if err := catalog.LoadFS(fsys, "locales"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return catalog
+}
+
+func TestTranslate(t *testing.T) {
+	t.Parallel()
+
+	catalog := testCatalog(t)
+
+	if locales := strings.Join(catalog.Locales(), ","); locales != "en,fr,ru" {
+		t.Errorf("Unexpected locales: %s", locales)
+	}
+
+	for _, test := range []struct {
+		locale   string
+		key      string
+		args     map[string]interface{}
+		expected string
+	}{
+		{"en", "inbox", map[string]interface{}{"name": "Ann", "count": 0}, "Ann, you have no messages"},
+		{"en", "inbox", map[string]interface{}{"name": "Ann", "count": 1}, "Ann, you have 1 message"},
+		{"en", "inbox", map[string]interface{}{"name": "Ann", "count": "1.0"}, "Ann, you have 1 messages"},
+		{"en", "inbox", map[string]interface{}{"name": "Ann", "count": 1234}, "Ann, you have 1,234 messages"},
+		{"fr", "inbox", map[string]interface{}{"name": "Ann", "count": 1.5}, "Ann, vous avez 1,5 message"},
+		{"fr", "inbox", map[string]interface{}{"name": "Ann", "count": 2000}, "Ann, vous avez 2 000 messages"},
+		{"ru", "inbox", map[string]interface{}{"count": 1}, "1 сообщение"},
+		{"ru", "inbox", map[string]interface{}{"count": 3}, "3 сообщения"},
+		{"ru", "inbox", map[string]interface{}{"count": 11}, "11 сообщений"},
+		{"ru", "inbox", map[string]interface{}{"count": 21}, "21 сообщение"},
+		{"en", "rank.place", map[string]interface{}{"place": 22}, "You finished 22nd"},
+		{"en", "rank.place", map[string]interface{}{"place": 13}, "You finished 13th"},
+		{"fr-CA", "rank.place", map[string]interface{}{"place": 1}, "Vous êtes 1er"},
+		{"en", "invite", map[string]interface{}{"gender": "female", "guests": 1, "host": "Ann"}, "She invited Ann"},
+		{"en", "invite", map[string]interface{}{"gender": "x", "guests": 3, "host": "Ann"}, "They invited Ann and 2 others"},
+		{"en", "invite", map[string]interface{}{"gender": "male", "guests": 2, "host": "Bob"}, "He invited Bob and 1 other"},
+		{"en", "quote", map[string]interface{}{"n": 3.14159, "ratio": 0.25}, "It's {literal} 3.142 25%"},
+		// missing messages fall back to default locale
+		{"ru", "quote", map[string]interface{}{"n": 1, "ratio": 1}, "It's {literal} 1 100%"},
+	} {
+		output, err := catalog.Translate(test.locale, test.key, test.args)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %s", test.locale, test.key, err)
+		} else if output != test.expected {
+			t.Errorf("%s %s: expected %q, got %q", test.locale, test.key, test.expected, output)
+		}
+	}
+
+	if _, err := catalog.Translate("en", "missing", nil); (err == nil) || !strings.Contains(err.Error(), `message "missing" not found`) {
+		t.Errorf("Expected a missing message error, got: %v", err)
+	}
+
+	if _, err := catalog.Translate("en", "inbox", map[string]interface{}{"count": "many"}); (err == nil) || !strings.Contains(err.Error(), "not a number") {
+		t.Errorf("Expected a number error, got: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range []string{
+		"{name",
+		"{}",
+		"{count, plural, one {#}}",
+		"{when, date}",
+		"{n, number, currency}",
+		"unmatched }",
+	} {
+		if err := NewCatalog("en").AddMessages("en", map[string]string{"key": source}); err == nil {
+			t.Errorf("Expected a parse error for %q", source)
+		}
+	}
+
+	if err := NewCatalog("en").LoadTOML("en", []byte("count = 3")); (err == nil) || !strings.Contains(err.Error(), "line 1: only string values are supported") {
+		t.Errorf("Expected a TOML error, got: %v", err)
+	}
+
+	if err := NewCatalog("en").LoadFS(fstest.MapFS{"en.json": {Data: []byte(`{"a": 1}`)}}, "."); (err == nil) || !strings.HasPrefix(err.Error(), "en.json: ") {
+		t.Errorf("Expected a JSON error prefixed by file path, got: %v", err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	catalog := testCatalog(t)
+	catalog.AddMessages("pt-BR", map[string]string{"inbox": "caixa"})
+
+	for _, test := range []struct {
+		preferences []string
+		expected    string
+	}{
+		{nil, "en"},
+		{[]string{"de"}, "en"},
+		{[]string{"fr_ca"}, "fr"},
+		{[]string{"de-DE,ru;q=0.5,fr;q=0.8"}, "fr"},
+		{[]string{"*, fr;q=0"}, "en"},
+		{[]string{"pt-PT"}, "pt-BR"},
+		{[]string{"", "ru-RU"}, "ru"},
+	} {
+		if locale := catalog.Negotiate(test.preferences...); locale != test.expected {
+			t.Errorf("Negotiate(%q): expected %s, got %s", test.preferences, test.expected, locale)
+		}
+	}
+}
+
+func TestHelper(t *testing.T) {
+	t.Parallel()
+
+	catalog := testCatalog(t)
+
+	tpl := raymond.MustParse(`{{t "inbox" count=unread name=user.name}} / {{t "inbox" count=1 name="<b>" locale="fr-FR"}}`)
+	tpl.RegisterHelpers(catalog.Helpers())
+
+	ctx := map[string]interface{}{
+		"unread": 2,
+		"user":   map[string]string{"name": "Ann"},
+	}
+
+	output, err := tpl.Exec(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if expected := "Ann, you have 2 messages / &lt;b&gt;, vous avez 1 message"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	data := raymond.NewDataFrame()
+	data.Set(LocaleData, "fr-CH,fr;q=0.9,en;q=0.8")
+
+	output, err = tpl.ExecWithOptions(ctx, raymond.ExecOptions{Data: data})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if expected := "Ann, vous avez 2 messages / &lt;b&gt;, vous avez 1 message"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	tpl = raymond.MustParse(`{{t "missing"}}`)
+	tpl.RegisterHelpers(catalog.Helpers())
+
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), `message "missing" not found`) {
+		t.Errorf("Expected a missing message error, got: %v", err)
+	}
+}
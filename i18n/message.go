@@ -0,0 +1,456 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aymerick/raymond"
+)
+
+// argument kinds
+const (
+	argSimple = iota
+	argNumber
+	argPlural
+	argOrdinal
+	argSelect
+)
+
+// message is a parsed ICU message: a list of string, *argument and pound parts
+type message []interface{}
+
+// pound is the # placeholder of plural sub-messages, replaced by the formatted number
+type pound struct{}
+
+// argument is a {name, type, style} placeholder of a message
+type argument struct {
+	name   string
+	kind   int
+	style  string
+	offset float64
+
+	// sub-messages of plural, selectordinal and select arguments, by selector
+	cases map[string]message
+}
+
+// parser parses ICU messages
+type parser struct {
+	source []rune
+	pos    int
+}
+
+// parseMessage parses given ICU MessageFormat pattern
+func parseMessage(source string) (message, error) {
+	p := &parser{source: []rune(source)}
+
+	result, err := p.message(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.source) {
+		return nil, p.errorf("unexpected '}'")
+	}
+
+	return result, nil
+}
+
+// errorf returns an error at current position
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+// peek returns the current rune, or 0 at end of source
+func (p *parser) peek() rune {
+	if p.pos < len(p.source) {
+		return p.source[p.pos]
+	}
+
+	return 0
+}
+
+// skipSpaces skips white spaces
+func (p *parser) skipSpaces() {
+	for (p.pos < len(p.source)) && unicode.IsSpace(p.source[p.pos]) {
+		p.pos++
+	}
+}
+
+// message parses a message until end of source or until a closing brace, that is not consumed
+func (p *parser) message(inPlural bool) (message, error) {
+	var result message
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			result = append(result, text.String())
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.source) {
+		r := p.source[p.pos]
+
+		switch {
+		case r == '}':
+			flush()
+			return result, nil
+		case r == '{':
+			flush()
+
+			arg, err := p.argument()
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, arg)
+		case (r == '#') && inPlural:
+			flush()
+			result = append(result, pound{})
+			p.pos++
+		case r == '\'':
+			p.quoted(&text, inPlural)
+		default:
+			text.WriteRune(r)
+			p.pos++
+		}
+	}
+
+	flush()
+
+	return result, nil
+}
+
+// quoted parses an apostrophe: a doubled apostrophe is a literal one, and an apostrophe followed by a syntax character starts a quoted literal
+func (p *parser) quoted(text *strings.Builder, inPlural bool) {
+	p.pos++
+
+	next := p.peek()
+
+	switch {
+	case next == '\'':
+		text.WriteRune('\'')
+		p.pos++
+	case (next == '{') || (next == '}') || ((next == '#') && inPlural):
+		for p.pos < len(p.source) {
+			r := p.source[p.pos]
+			p.pos++
+
+			if r == '\'' {
+				if p.peek() != '\'' {
+					return
+				}
+
+				p.pos++
+			}
+
+			text.WriteRune(r)
+		}
+	default:
+		text.WriteRune('\'')
+	}
+}
+
+// word parses an argument name, type or keyword
+func (p *parser) word() string {
+	start := p.pos
+
+	for p.pos < len(p.source) {
+		r := p.source[p.pos]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && (r != '_') && (r != '-') && (r != '.') && (r != ':') && (r != '=') {
+			break
+		}
+
+		p.pos++
+	}
+
+	return string(p.source[start:p.pos])
+}
+
+// expect consumes given rune, after white spaces
+func (p *parser) expect(r rune) error {
+	p.skipSpaces()
+
+	if p.peek() != r {
+		return p.errorf("expected '%c'", r)
+	}
+
+	p.pos++
+
+	return nil
+}
+
+// argument parses a {name, type, style} argument
+func (p *parser) argument() (*argument, error) {
+	p.pos++
+	p.skipSpaces()
+
+	arg := &argument{name: p.word()}
+	if arg.name == "" {
+		return nil, p.errorf("expected argument name")
+	}
+
+	p.skipSpaces()
+
+	if p.peek() == '}' {
+		p.pos++
+		return arg, nil
+	}
+
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+
+	kind := p.word()
+
+	switch kind {
+	case "number":
+		arg.kind = argNumber
+
+		p.skipSpaces()
+
+		if p.peek() == ',' {
+			p.pos++
+			p.skipSpaces()
+
+			arg.style = p.word()
+			if (arg.style != "integer") && (arg.style != "percent") {
+				return nil, p.errorf("unsupported number style %q", arg.style)
+			}
+		}
+	case "plural", "selectordinal", "select":
+		arg.kind = map[string]int{"plural": argPlural, "selectordinal": argOrdinal, "select": argSelect}[kind]
+
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+
+		if err := p.cases(arg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf("unsupported argument type %q", kind)
+	}
+
+	if err := p.expect('}'); err != nil {
+		return nil, err
+	}
+
+	return arg, nil
+}
+
+// cases parses the selectors and sub-messages of given plural, selectordinal or select argument
+func (p *parser) cases(arg *argument) error {
+	arg.cases = make(map[string]message)
+
+	for {
+		p.skipSpaces()
+
+		if p.peek() == '}' {
+			break
+		}
+
+		selector := p.word()
+		if selector == "" {
+			return p.errorf("expected selector")
+		}
+
+		if strings.HasPrefix(selector, "offset:") && (arg.kind != argSelect) && (len(arg.cases) == 0) {
+			offset, err := strconv.ParseFloat(strings.TrimPrefix(selector, "offset:"), 64)
+			if err != nil {
+				return p.errorf("invalid offset %q", selector)
+			}
+
+			arg.offset = offset
+			continue
+		}
+
+		if err := p.expect('{'); err != nil {
+			return err
+		}
+
+		sub, err := p.message(arg.kind != argSelect)
+		if err != nil {
+			return err
+		}
+
+		if err := p.expect('}'); err != nil {
+			return err
+		}
+
+		arg.cases[selector] = sub
+	}
+
+	if _, ok := arg.cases["other"]; !ok {
+		return p.errorf("missing 'other' case of argument %q", arg.name)
+	}
+
+	return nil
+}
+
+// format writes given message to buffer, with given arguments
+func (m message) format(buf *strings.Builder, lang *language, args map[string]interface{}, number string) error {
+	for _, part := range m {
+		switch part := part.(type) {
+		case string:
+			buf.WriteString(part)
+		case pound:
+			buf.WriteString(formatNumber(number, lang, ""))
+		case *argument:
+			if err := part.format(buf, lang, args); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// format writes given argument to buffer
+func (arg *argument) format(buf *strings.Builder, lang *language, args map[string]interface{}) error {
+	value := args[arg.name]
+
+	switch arg.kind {
+	case argSimple:
+		buf.WriteString(raymond.Str(value))
+	case argSelect:
+		sub, ok := arg.cases[raymond.Str(value)]
+		if !ok {
+			sub = arg.cases["other"]
+		}
+
+		return sub.format(buf, lang, args, "")
+	default:
+		number, ok := decimal(value)
+		if !ok {
+			return fmt.Errorf("argument %q is not a number: %v", arg.name, value)
+		}
+
+		if arg.kind == argNumber {
+			buf.WriteString(formatNumber(number, lang, arg.style))
+			return nil
+		}
+
+		return arg.selectCase(number, lang).format(buf, lang, args, arg.applyOffset(number))
+	}
+
+	return nil
+}
+
+// selectCase returns the sub-message of given number, for a plural or selectordinal argument
+func (arg *argument) selectCase(number string, lang *language) message {
+	value, _ := strconv.ParseFloat(number, 64)
+
+	// explicit values are matched before offset is applied
+	for selector, sub := range arg.cases {
+		if strings.HasPrefix(selector, "=") {
+			if exact, err := strconv.ParseFloat(selector[1:], 64); (err == nil) && (exact == value) {
+				return sub
+			}
+		}
+	}
+
+	rule := lang.cardinal
+	if arg.kind == argOrdinal {
+		rule = lang.ordinal
+	}
+
+	if sub, ok := arg.cases[rule(newOperands(arg.applyOffset(number)))]; ok {
+		return sub
+	}
+
+	return arg.cases["other"]
+}
+
+// applyOffset returns given decimal number minus argument offset
+func (arg *argument) applyOffset(number string) string {
+	if arg.offset == 0 {
+		return number
+	}
+
+	value, _ := strconv.ParseFloat(number, 64)
+
+	return strconv.FormatFloat(value-arg.offset, 'f', -1, 64)
+}
+
+// decimal returns the decimal representation of given value, and false if it is not a number
+//
+// Strings keep their visible fraction digits, eg. "1.50", that are significant for plural rules.
+func decimal(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil, bool:
+		return "", false
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), !math.IsNaN(v) && !math.IsInf(v, 0)
+	}
+
+	str := strings.TrimSpace(raymond.Str(value))
+
+	f, err := strconv.ParseFloat(str, 64)
+	if (err != nil) || math.IsNaN(f) || math.IsInf(f, 0) || strings.ContainsAny(str, "eExXpP_") {
+		return "", false
+	}
+
+	return str, true
+}
+
+// formatNumber formats given decimal number with the symbols of given language, and given number style
+func formatNumber(number string, lang *language, style string) string {
+	value, _ := strconv.ParseFloat(number, 64)
+
+	suffix := ""
+
+	switch style {
+	case "integer":
+		number = strconv.FormatFloat(math.Round(value), 'f', 0, 64)
+	case "percent":
+		number = strconv.FormatFloat(math.Round(value*100), 'f', 0, 64)
+		suffix = lang.percent
+	default:
+		// at most 3 fraction digits, like ICU default number format
+		number = strconv.FormatFloat(value, 'f', 3, 64)
+		number = strings.TrimRight(strings.TrimRight(number, "0"), ".")
+	}
+
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+
+	if number == "0" {
+		sign = ""
+	}
+
+	intPart, fracPart := number, ""
+	if pos := strings.IndexByte(number, '.'); pos >= 0 {
+		intPart, fracPart = number[:pos], number[pos+1:]
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(sign)
+
+	for i, r := range intPart {
+		if (i > 0) && ((len(intPart)-i)%3 == 0) {
+			buf.WriteString(lang.group)
+		}
+
+		buf.WriteRune(r)
+	}
+
+	if fracPart != "" {
+		buf.WriteString(lang.decimal)
+		buf.WriteString(fracPart)
+	}
+
+	buf.WriteString(suffix)
+
+	return buf.String()
+}
@@ -0,0 +1,245 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+)
+
+// operands are the plural operands of a number, as defined by CLDR
+type operands struct {
+	n float64 // absolute value
+	i uint64  // integer digits
+	v int     // number of visible fraction digits, with trailing zeros
+	f uint64  // visible fraction digits, with trailing zeros
+	t uint64  // visible fraction digits, without trailing zeros
+}
+
+// newOperands returns the plural operands of given decimal number
+func newOperands(number string) operands {
+	number = strings.TrimLeft(number, "+-")
+
+	intPart, fracPart := number, ""
+	if pos := strings.IndexByte(number, '.'); pos >= 0 {
+		intPart, fracPart = number[:pos], number[pos+1:]
+	}
+
+	result := operands{v: len(fracPart)}
+	result.n, _ = strconv.ParseFloat(number, 64)
+	result.i = lastDigits(intPart)
+	result.f = lastDigits(fracPart)
+	result.t = lastDigits(strings.TrimRight(fracPart, "0"))
+
+	return result
+}
+
+// lastDigits returns the integer value of the last digits of given number, that do not overflow
+func lastDigits(digits string) uint64 {
+	if len(digits) > 18 {
+		digits = digits[len(digits)-18:]
+	}
+
+	result, _ := strconv.ParseUint(digits, 10, 64)
+
+	return result
+}
+
+// inRange returns true if given value is in given inclusive range
+func inRange(value, from, to uint64) bool {
+	return (value >= from) && (value <= to)
+}
+
+// pluralRule returns the plural category of given number: "zero", "one", "two", "few", "many" or "other"
+type pluralRule func(o operands) string
+
+// language represents the plural rules and number symbols of a language
+type language struct {
+	cardinal pluralRule
+	ordinal  pluralRule
+
+	decimal string
+	group   string
+	percent string
+}
+
+// otherRule is the plural rule of languages without plural forms
+func otherRule(o operands) string {
+	return "other"
+}
+
+// oneRule is the cardinal rule of English and most germanic languages
+func oneRule(o operands) string {
+	if (o.i == 1) && (o.v == 0) {
+		return "one"
+	}
+
+	return "other"
+}
+
+// frenchRule is the cardinal rule of French and Portuguese
+func frenchRule(o operands) string {
+	if o.i <= 1 {
+		return "one"
+	}
+
+	return "other"
+}
+
+// spanishRule is the cardinal rule of Spanish and Greek
+func spanishRule(o operands) string {
+	if o.n == 1 {
+		return "one"
+	}
+
+	return "other"
+}
+
+// slavicRule is the cardinal rule of Russian and Ukrainian
+func slavicRule(o operands) string {
+	if o.v != 0 {
+		return "other"
+	}
+
+	switch {
+	case (o.i%10 == 1) && (o.i%100 != 11):
+		return "one"
+	case inRange(o.i%10, 2, 4) && !inRange(o.i%100, 12, 14):
+		return "few"
+	default:
+		return "many"
+	}
+}
+
+// polishRule is the cardinal rule of Polish
+func polishRule(o operands) string {
+	if o.v != 0 {
+		return "other"
+	}
+
+	switch {
+	case o.i == 1:
+		return "one"
+	case inRange(o.i%10, 2, 4) && !inRange(o.i%100, 12, 14):
+		return "few"
+	default:
+		return "many"
+	}
+}
+
+// czechRule is the cardinal rule of Czech and Slovak
+func czechRule(o operands) string {
+	switch {
+	case o.v != 0:
+		return "many"
+	case o.i == 1:
+		return "one"
+	case inRange(o.i, 2, 4):
+		return "few"
+	default:
+		return "other"
+	}
+}
+
+// arabicRule is the cardinal rule of Arabic
+func arabicRule(o operands) string {
+	if o.n != float64(uint64(o.n)) {
+		return "other"
+	}
+
+	n := uint64(o.n)
+
+	switch {
+	case n == 0:
+		return "zero"
+	case n == 1:
+		return "one"
+	case n == 2:
+		return "two"
+	case inRange(n%100, 3, 10):
+		return "few"
+	case inRange(n%100, 11, 99):
+		return "many"
+	default:
+		return "other"
+	}
+}
+
+// hebrewRule is the cardinal rule of Hebrew
+func hebrewRule(o operands) string {
+	switch {
+	case ((o.i == 1) && (o.v == 0)) || ((o.i == 0) && (o.v != 0)):
+		return "one"
+	case (o.i == 2) && (o.v == 0):
+		return "two"
+	default:
+		return "other"
+	}
+}
+
+// englishOrdinal is the ordinal rule of English
+func englishOrdinal(o operands) string {
+	if o.v != 0 {
+		return "other"
+	}
+
+	switch {
+	case (o.i%10 == 1) && (o.i%100 != 11):
+		return "one"
+	case (o.i%10 == 2) && (o.i%100 != 12):
+		return "two"
+	case (o.i%10 == 3) && (o.i%100 != 13):
+		return "few"
+	default:
+		return "other"
+	}
+}
+
+// frenchOrdinal is the ordinal rule of French
+func frenchOrdinal(o operands) string {
+	if o.n == 1 {
+		return "one"
+	}
+
+	return "other"
+}
+
+// rootLanguage is used for languages without rules: all numbers are "other", and numbers are formatted like in English
+var rootLanguage = &language{cardinal: otherRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"}
+
+// languages are the supported languages, by ISO 639 code
+var languages = map[string]*language{
+	"en": {cardinal: oneRule, ordinal: englishOrdinal, decimal: ".", group: ",", percent: "%"},
+	"de": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: ".", percent: "\u00a0%"},
+	"nl": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"sv": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "\u00a0%"},
+	"da": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: ".", percent: "\u00a0%"},
+	"nb": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "\u00a0%"},
+	"fi": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "\u00a0%"},
+	"it": {cardinal: oneRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"fr": {cardinal: frenchRule, ordinal: frenchOrdinal, decimal: ",", group: "\u202f", percent: "\u202f%"},
+	"pt": {cardinal: frenchRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"es": {cardinal: spanishRule, ordinal: otherRule, decimal: ",", group: ".", percent: "\u00a0%"},
+	"el": {cardinal: spanishRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"tr": {cardinal: spanishRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"ru": {cardinal: slavicRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "\u00a0%"},
+	"uk": {cardinal: slavicRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "%"},
+	"pl": {cardinal: polishRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "%"},
+	"cs": {cardinal: czechRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "\u00a0%"},
+	"sk": {cardinal: czechRule, ordinal: otherRule, decimal: ",", group: "\u00a0", percent: "\u00a0%"},
+	"ar": {cardinal: arabicRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"},
+	"he": {cardinal: hebrewRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"},
+	"ja": {cardinal: otherRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"},
+	"zh": {cardinal: otherRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"},
+	"ko": {cardinal: otherRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"},
+	"vi": {cardinal: otherRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"id": {cardinal: otherRule, ordinal: otherRule, decimal: ",", group: ".", percent: "%"},
+	"th": {cardinal: otherRule, ordinal: otherRule, decimal: ".", group: ",", percent: "%"},
+}
+
+// lookupLanguage returns the language of given locale, or the root language if it is not supported
+func lookupLanguage(locale string) *language {
+	if lang, ok := languages[baseLanguage(locale)]; ok {
+		return lang
+	}
+
+	return rootLanguage
+}
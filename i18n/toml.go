@@ -0,0 +1,311 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses a TOML message catalog, and returns its messages by dotted key
+//
+// Only the subset of TOML used by message catalogs is supported: comments, tables, bare, quoted and dotted keys, and basic, literal and multi-line string values.
+func parseTOML(source string) (map[string]string, error) {
+	p := &tomlParser{source: source, line: 1}
+
+	result := make(map[string]string)
+
+	if err := p.parse(result); err != nil {
+		return nil, fmt.Errorf("line %d: %s", p.line, err)
+	}
+
+	return result, nil
+}
+
+// tomlParser parses TOML catalogs
+type tomlParser struct {
+	source string
+	pos    int
+	line   int
+}
+
+// parse parses all catalog lines into result
+func (p *tomlParser) parse(result map[string]string) error {
+	table := ""
+
+	for {
+		p.skipBlank()
+
+		if p.pos >= len(p.source) {
+			return nil
+		}
+
+		if p.source[p.pos] == '[' {
+			p.pos++
+
+			keys, err := p.keys(']')
+			if err != nil {
+				return err
+			}
+
+			p.pos++
+			table = strings.Join(keys, ".") + "."
+		} else {
+			keys, err := p.keys('=')
+			if err != nil {
+				return err
+			}
+
+			p.pos++
+			p.skipSpaces()
+
+			value, err := p.value()
+			if err != nil {
+				return err
+			}
+
+			key := table + strings.Join(keys, ".")
+			if _, ok := result[key]; ok {
+				return fmt.Errorf("duplicate key %q", key)
+			}
+
+			result[key] = value
+		}
+
+		if err := p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipSpaces skips spaces and tabs
+func (p *tomlParser) skipSpaces() {
+	for (p.pos < len(p.source)) && ((p.source[p.pos] == ' ') || (p.source[p.pos] == '\t')) {
+		p.pos++
+	}
+}
+
+// skipBlank skips white spaces, new lines and comments
+func (p *tomlParser) skipBlank() {
+	for p.pos < len(p.source) {
+		switch p.source[p.pos] {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			for (p.pos < len(p.source)) && (p.source[p.pos] != '\n') {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine checks that only a comment follows on current line
+func (p *tomlParser) endOfLine() error {
+	p.skipSpaces()
+
+	if (p.pos < len(p.source)) && (p.source[p.pos] == '\r') {
+		p.pos++
+	}
+
+	if (p.pos < len(p.source)) && (p.source[p.pos] != '\n') && (p.source[p.pos] != '#') {
+		return fmt.Errorf("unexpected %q", p.source[p.pos])
+	}
+
+	return nil
+}
+
+// keys parses a dotted key, until given delimiter, that is not consumed
+func (p *tomlParser) keys(delim byte) ([]string, error) {
+	var result []string
+
+	for {
+		p.skipSpaces()
+
+		if p.pos >= len(p.source) {
+			return nil, errors.New("unexpected end of file")
+		}
+
+		var key string
+		var err error
+
+		switch c := p.source[p.pos]; {
+		case c == '"':
+			key, err = p.basicString()
+		case c == '\'':
+			key, err = p.literalString()
+		default:
+			start := p.pos
+			for (p.pos < len(p.source)) && isBareKeyChar(p.source[p.pos]) {
+				p.pos++
+			}
+
+			if p.pos == start {
+				return nil, fmt.Errorf("invalid key character %q", c)
+			}
+
+			key = p.source[start:p.pos]
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, key)
+
+		p.skipSpaces()
+
+		if p.pos >= len(p.source) {
+			return nil, errors.New("unexpected end of file")
+		}
+
+		switch p.source[p.pos] {
+		case '.':
+			p.pos++
+		case delim:
+			return result, nil
+		default:
+			return nil, fmt.Errorf("expected '%c' after key %q", delim, key)
+		}
+	}
+}
+
+// isBareKeyChar returns true if given character is allowed in bare keys
+func isBareKeyChar(c byte) bool {
+	return ((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z')) || ((c >= '0') && (c <= '9')) || (c == '_') || (c == '-')
+}
+
+// value parses a string value
+func (p *tomlParser) value() (string, error) {
+	switch {
+	case strings.HasPrefix(p.source[p.pos:], `"""`):
+		return p.multiLineString(`"""`, true)
+	case strings.HasPrefix(p.source[p.pos:], `'''`):
+		return p.multiLineString(`'''`, false)
+	case strings.HasPrefix(p.source[p.pos:], `"`):
+		return p.basicString()
+	case strings.HasPrefix(p.source[p.pos:], `'`):
+		return p.literalString()
+	default:
+		return "", errors.New("only string values are supported")
+	}
+}
+
+// basicString parses a double quoted string
+func (p *tomlParser) basicString() (string, error) {
+	end := p.pos + 1
+
+	for ; end < len(p.source); end++ {
+		switch p.source[end] {
+		case '\\':
+			end++
+		case '\n':
+			return "", errors.New("unterminated string")
+		case '"':
+			str, err := unescape(p.source[p.pos+1 : end])
+			p.pos = end + 1
+
+			return str, err
+		}
+	}
+
+	return "", errors.New("unterminated string")
+}
+
+// literalString parses a single quoted string, without escape sequences
+func (p *tomlParser) literalString() (string, error) {
+	end := strings.IndexAny(p.source[p.pos+1:], "'\n")
+	if (end < 0) || (p.source[p.pos+1+end] != '\'') {
+		return "", errors.New("unterminated string")
+	}
+
+	str := p.source[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+
+	return str, nil
+}
+
+// multiLineString parses a multi-line string delimited by given quotes
+func (p *tomlParser) multiLineString(quotes string, escapes bool) (string, error) {
+	p.pos += len(quotes)
+
+	end := strings.Index(p.source[p.pos:], quotes)
+	if end < 0 {
+		return "", errors.New("unterminated string")
+	}
+
+	str := p.source[p.pos : p.pos+end]
+	p.line += strings.Count(str, "\n")
+	p.pos += end + len(quotes)
+
+	// a new line immediately following the opening delimiter is trimmed
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "\r"), "\n")
+
+	if !escapes {
+		return str, nil
+	}
+
+	return unescape(str)
+}
+
+// unescape replaces the escape sequences of a basic string
+func unescape(str string) (string, error) {
+	if !strings.Contains(str, `\`) {
+		return str, nil
+	}
+
+	var buf strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' {
+			buf.WriteByte(str[i])
+			continue
+		}
+
+		i++
+		if i >= len(str) {
+			return "", errors.New("invalid escape sequence")
+		}
+
+		switch str[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 't':
+			buf.WriteByte('\t')
+		case 'r':
+			buf.WriteByte('\r')
+		case '"', '\\':
+			buf.WriteByte(str[i])
+		case 'u', 'U':
+			size := 4
+			if str[i] == 'U' {
+				size = 8
+			}
+
+			if i+size >= len(str) {
+				return "", errors.New("invalid unicode escape sequence")
+			}
+
+			code, err := strconv.ParseUint(str[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", errors.New("invalid unicode escape sequence")
+			}
+
+			buf.WriteRune(rune(code))
+			i += size
+		case '\n', ' ', '\t', '\r':
+			// line ending backslash: trims white spaces up to next non blank character
+			for (i+1 < len(str)) && strings.ContainsRune(" \t\r\n", rune(str[i+1])) {
+				i++
+			}
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", str[i])
+		}
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,25 @@
+// Package yamlconv converts values decoded by the YAML parser, so that they can be used as template contexts.
+package yamlconv
+
+import "fmt"
+
+// Value converts maps decoded by YAML parser to maps with string keys, recursively.
+//
+// Slices are converted in place.
+func Value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			result[fmt.Sprint(key)] = Value(val)
+		}
+		return result
+	case []interface{}:
+		for i, val := range v {
+			v[i] = Value(val)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,24 @@
+package yamlconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	t.Parallel()
+
+	value := map[interface{}]interface{}{
+		"name": "foo",
+		1:      []interface{}{map[interface{}]interface{}{true: "yes"}, 2},
+	}
+
+	expected := map[string]interface{}{
+		"name": "foo",
+		"1":    []interface{}{map[string]interface{}{"true": "yes"}, 2},
+	}
+
+	if result := Value(value); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected value\nexpected:\n\t%#v\ngot:\n\t%#v", expected, result)
+	}
+}
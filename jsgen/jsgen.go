@@ -0,0 +1,546 @@
+// Package jsgen precompiles handlebars templates to JavaScript, in the template spec format of the handlebars.js runtime.
+//
+// The generated specs are loaded with `Handlebars.template(spec)`, so that a Go backend serves templates that browsers render client-side, without shipping the handlebars.js compiler:
+//
+//	spec, err := jsgen.Precompile("<h1>{{title}}</h1>")
+//
+// Specs declare the compiler revision of handlebars.js 4.3, but they are only tested against the minimal runtime of testdata/runtime.js, not against the official runtime.
+//
+// Templates are compiled like the handlebars.js compiler does with its default options: the `if`, `unless`, `each`, `with`, `lookup` and `log` helpers are known helpers, names without arguments are resolved as helpers or as context values at runtime, and other helpers and partials must be registered in the runtime. Decorators and partial blocks are not supported by raymond, so they are not generated either.
+package jsgen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+// compilerRevision is the handlebars.js compiler revision of generated specs, and the runtime versions that support it
+const compilerRevision = `[8,">= 4.3.0"]`
+
+// DefaultNamespace is the default JavaScript object where templates are registered by Generate().
+const DefaultNamespace = "Handlebars.templates"
+
+// knownHelpers are the helpers of the handlebars.js runtime, that are called directly
+var knownHelpers = map[string]bool{
+	"helperMissing":      true,
+	"blockHelperMissing": true,
+	"each":               true,
+	"if":                 true,
+	"unless":             true,
+	"with":               true,
+	"log":                true,
+	"lookup":             true,
+}
+
+// Options represents the generation options of Generate().
+type Options struct {
+	// Templates are the sources of templates to register in Namespace, by name.
+	Templates map[string]string
+
+	// Partials are the sources of templates to register as partials, with Handlebars.registerPartial(), by name.
+	Partials map[string]string
+
+	// Namespace is the JavaScript object where templates are registered. It defaults to DefaultNamespace.
+	Namespace string
+}
+
+// Precompile returns the template spec of given template source, ie. a JavaScript object literal to pass to Handlebars.template().
+func Precompile(source string) (string, error) {
+	program, err := parser.Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	return precompile(program), nil
+}
+
+// Generate returns a JavaScript file that precompiles given templates and partials, and registers them with the global Handlebars object, like the handlebars.js command line does.
+//
+// Templates are registered in name order, so that the output is stable. Parse errors are prefixed with the name of the invalid template.
+func Generate(opts Options) ([]byte, error) {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "(function() {\n  var template = Handlebars.template, templates = %s = %s || {};\n", namespace, namespace)
+
+	for _, set := range []struct {
+		sources map[string]string
+		format  string
+	}{
+		{opts.Templates, "templates[%s] = template(%s);\n"},
+		{opts.Partials, "Handlebars.registerPartial(%s, template(%s));\n"},
+	} {
+		for _, name := range sortedNames(set.sources) {
+			spec, err := Precompile(set.sources[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+
+			fmt.Fprintf(&buf, set.format, strconv.Quote(name), spec)
+		}
+	}
+
+	buf.WriteString("})();\n")
+
+	return buf.Bytes(), nil
+}
+
+// sortedNames returns the sorted keys of given map
+func sortedNames(sources map[string]string) []string {
+	result := make([]string, 0, len(sources))
+	for name := range sources {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// generator generates the template spec of a template
+type generator struct {
+	// generated program functions, the main program being the first one
+	programs []string
+
+	// block params declared by the programs being generated, innermost first
+	blockParams [][]string
+
+	// spec flags
+	useDepths      bool
+	useBlockParams bool
+	usePartial     bool
+}
+
+// precompile returns the template spec of given program
+func precompile(program *ast.Program) string {
+	g := &generator{}
+	g.scan(program)
+	g.program(program)
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `{"compiler":%s,"main":%s`, compilerRevision, g.programs[0])
+
+	for i, code := range g.programs[1:] {
+		fmt.Fprintf(&buf, `,"%d":%s`, i+1, code)
+	}
+
+	buf.WriteString(`,"useData":true`)
+
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{{"usePartial", g.usePartial}, {"useDepths", g.useDepths}, {"useBlockParams", g.useBlockParams}} {
+		if flag.set {
+			fmt.Fprintf(&buf, `,"%s":true`, flag.name)
+		}
+	}
+
+	buf.WriteString("}")
+
+	return buf.String()
+}
+
+// scan sets the spec flags of given program and its nested programs, that must be known before generating program calls
+func (g *generator) scan(program *ast.Program) {
+	if program == nil {
+		return
+	}
+
+	if len(program.BlockParams) > 0 {
+		g.useBlockParams = true
+	}
+
+	var node func(n ast.Node)
+	node = func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.PathExpression:
+			if !n.Data && (n.Depth > 0) {
+				g.useDepths = true
+			}
+		case *ast.SubExpression:
+			g.scanExpression(n.Expression, node)
+		}
+	}
+
+	for _, stmt := range program.Body {
+		switch n := stmt.(type) {
+		case *ast.MustacheStatement:
+			g.scanExpression(n.Expression, node)
+		case *ast.BlockStatement:
+			g.scanExpression(n.Expression, node)
+			g.scan(n.Program)
+			g.scan(n.Inverse)
+		case *ast.PartialStatement:
+			g.usePartial = true
+
+			node(n.Name)
+
+			for _, param := range n.Params {
+				node(param)
+			}
+
+			if n.Hash != nil {
+				for _, pair := range n.Hash.Pairs {
+					node(pair.Val)
+				}
+			}
+		}
+	}
+}
+
+// scanExpression calls given function with the path, params and hash values of given expression
+func (g *generator) scanExpression(expr *ast.Expression, node func(n ast.Node)) {
+	node(expr.Path)
+
+	for _, param := range expr.Params {
+		node(param)
+	}
+
+	if expr.Hash != nil {
+		for _, pair := range expr.Hash.Pairs {
+			node(pair.Val)
+		}
+	}
+}
+
+// programHeader declares the variables of generated program functions
+const programHeader = `function(container,depth0,helpers,partials,data,blockParams,depths) {
+  var stack1, helper, options, alias1=depth0 != null ? depth0 : (container.nullContext || {}), lookupProperty = container.lookupProperty || function(parent, propertyName) {
+    if (Object.prototype.hasOwnProperty.call(parent, propertyName)) {
+      return parent[propertyName];
+    }
+    return undefined
+  }, buffer = "";
+`
+
+// program generates the function of given program, and returns its index in the spec
+func (g *generator) program(program *ast.Program) int {
+	index := len(g.programs)
+	g.programs = append(g.programs, "")
+
+	g.blockParams = append([][]string{program.BlockParams}, g.blockParams...)
+	defer func() { g.blockParams = g.blockParams[1:] }()
+
+	var buf strings.Builder
+
+	buf.WriteString(programHeader)
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.ContentStatement:
+			if n.Value != "" {
+				fmt.Fprintf(&buf, "  buffer += %s;\n", jsString(n.Value))
+			}
+		case *ast.MustacheStatement:
+			value := g.mustacheValue(n.Expression)
+
+			if n.Unescaped {
+				fmt.Fprintf(&buf, "  buffer += ((stack1 = %s) != null ? stack1 : \"\");\n", value)
+			} else {
+				fmt.Fprintf(&buf, "  buffer += container.escapeExpression(%s);\n", value)
+			}
+		case *ast.BlockStatement:
+			g.block(&buf, n)
+		case *ast.PartialStatement:
+			g.partial(&buf, n)
+		}
+	}
+
+	buf.WriteString("  return buffer;\n}")
+
+	g.programs[index] = buf.String()
+
+	return index
+}
+
+// programCall returns the expression that wraps given program, or container.noop if it is nil
+func (g *generator) programCall(program *ast.Program) string {
+	if program == nil {
+		return "container.noop"
+	}
+
+	params := []string{strconv.Itoa(g.program(program)), "data", strconv.Itoa(len(program.BlockParams))}
+
+	if g.useBlockParams || g.useDepths {
+		params = append(params, "blockParams")
+	}
+
+	if g.useDepths {
+		params = append(params, "depths")
+	}
+
+	return "container.program(" + strings.Join(params, ", ") + ")"
+}
+
+// block generates the code of given block statement
+func (g *generator) block(buf *strings.Builder, node *ast.BlockStatement) {
+	expr := node.Expression
+
+	extra := []string{`"fn":` + g.programCall(node.Program), `"inverse":` + g.programCall(node.Inverse)}
+
+	name, simple := g.simpleName(expr)
+
+	switch {
+	case g.isHelper(expr):
+		fmt.Fprintf(buf, "  buffer += ((stack1 = %s) != null ? stack1 : \"\");\n", g.helperCall(expr, extra))
+	case simple:
+		// the name is a helper or a context value, that is rendered by the blockHelperMissing hook
+		fmt.Fprintf(buf, "  stack1 = ((helper = (helper = lookupProperty(helpers,%s) || %s) != null ? helper : container.hooks.helperMissing),(options=%s),(typeof helper === \"function\" ? helper.call(alias1,options) : helper));\n", jsString(name), g.contextLookup("depth0", []string{name}), g.options(expr, extra))
+		fmt.Fprintf(buf, "  if (!lookupProperty(helpers,%s)) { stack1 = container.hooks.blockHelperMissing.call(depth0,stack1,options); }\n", jsString(name))
+		buf.WriteString("  if (stack1 != null) { buffer += stack1; }\n")
+	default:
+		fmt.Fprintf(buf, "  stack1 = container.hooks.blockHelperMissing.call(depth0,%s,%s);\n", g.value(expr.Path), g.options(expr, extra))
+		buf.WriteString("  if (stack1 != null) { buffer += stack1; }\n")
+	}
+}
+
+// partial generates the code of given partial statement
+func (g *generator) partial(buf *strings.Builder, node *ast.PartialStatement) {
+	ctx := "depth0"
+	if len(node.Params) > 0 {
+		ctx = g.value(node.Params[0])
+	}
+
+	var partial string
+	var opts []string
+
+	switch name := node.Name.(type) {
+	case *ast.PathExpression:
+		partial = "lookupProperty(partials," + jsString(name.Original) + ")"
+		opts = append(opts, `"name":`+jsString(name.Original))
+	case *ast.StringLiteral:
+		partial = "lookupProperty(partials," + jsString(name.Value) + ")"
+		opts = append(opts, `"name":`+jsString(name.Value))
+	default:
+		// dynamic partial: the runtime resolves its name
+		partial = g.value(node.Name)
+	}
+
+	opts = append(opts, `"hash":`+g.hash(node.Hash), `"data":data`)
+
+	if node.Indent != "" {
+		opts = append(opts, `"indent":`+jsString(node.Indent))
+	}
+
+	opts = append(opts, `"helpers":helpers`, `"partials":partials`, `"decorators":container.decorators`)
+
+	fmt.Fprintf(buf, "  stack1 = container.invokePartial(%s,%s,{%s});\n", partial, ctx, strings.Join(opts, ","))
+	buf.WriteString("  if (stack1 != null) { buffer += stack1; }\n")
+}
+
+// mustacheValue returns the expression of the value of given mustache or subexpression
+func (g *generator) mustacheValue(expr *ast.Expression) string {
+	name, simple := g.simpleName(expr)
+
+	switch {
+	case g.isHelper(expr):
+		return g.helperCall(expr, nil)
+	case simple:
+		// the name is a helper or a context value
+		return fmt.Sprintf("((helper = (helper = lookupProperty(helpers,%s) || %s) != null ? helper : container.hooks.helperMissing),(typeof helper === \"function\" ? helper.call(alias1,%s) : helper))", jsString(name), g.contextLookup("depth0", []string{name}), g.options(expr, nil))
+	default:
+		return "container.lambda(" + g.value(expr.Path) + ", depth0)"
+	}
+}
+
+// isHelper returns true if given expression is a helper call, ie. if it has arguments or if its name is a known helper
+func (g *generator) isHelper(expr *ast.Expression) bool {
+	if (len(expr.Params) > 0) || (expr.Hash != nil) {
+		return true
+	}
+
+	name, simple := g.simpleName(expr)
+
+	return simple && knownHelpers[name]
+}
+
+// helperCall returns the expression that calls the helper of given expression, with given extra options
+func (g *generator) helperCall(expr *ast.Expression, extra []string) string {
+	var helper string
+
+	if name, simple := g.simpleName(expr); !simple {
+		helper = "(" + g.value(expr.Path) + " || container.hooks.helperMissing)"
+	} else if knownHelpers[name] {
+		helper = "lookupProperty(helpers," + jsString(name) + ")"
+	} else {
+		helper = "(lookupProperty(helpers," + jsString(name) + ") || (depth0 && lookupProperty(depth0," + jsString(name) + ")) || container.hooks.helperMissing)"
+	}
+
+	args := []string{"alias1"}
+	for _, param := range expr.Params {
+		args = append(args, g.value(param))
+	}
+
+	args = append(args, g.options(expr, extra))
+
+	return helper + ".call(" + strings.Join(args, ",") + ")"
+}
+
+// options returns the options object literal of given helper expression, with given extra options
+func (g *generator) options(expr *ast.Expression, extra []string) string {
+	name, _ := ast.LiteralStr(expr.Path)
+	if path, ok := expr.Path.(*ast.PathExpression); ok {
+		name = path.Original
+	}
+
+	opts := append([]string{`"name":` + jsString(name), `"hash":` + g.hash(expr.Hash)}, extra...)
+	opts = append(opts, `"data":data`)
+
+	if g.useBlockParams {
+		opts = append(opts, `"blockParams":blockParams`)
+	}
+
+	return "{" + strings.Join(opts, ",") + "}"
+}
+
+// hash returns the object literal of given hash
+func (g *generator) hash(node *ast.Hash) string {
+	if node == nil {
+		return "{}"
+	}
+
+	pairs := make([]string, len(node.Pairs))
+	for i, pair := range node.Pairs {
+		pairs[i] = jsString(pair.Key) + ":" + g.value(pair.Val)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// value returns the expression of given param
+func (g *generator) value(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.PathExpression:
+		return g.pathValue(n)
+	case *ast.SubExpression:
+		return g.mustacheValue(n.Expression)
+	case *ast.StringLiteral:
+		return jsString(n.Value)
+	case *ast.BooleanLiteral:
+		return n.Canonical()
+	case *ast.NumberLiteral:
+		return n.Canonical()
+	default:
+		return "undefined"
+	}
+}
+
+// pathValue returns the expression of the value of given path
+func (g *generator) pathValue(path *ast.PathExpression) string {
+	if path.Data {
+		base := "data"
+		if path.Depth > 0 {
+			base = "container.data(data, " + strconv.Itoa(path.Depth) + ")"
+		}
+
+		return g.contextLookup(base, path.Parts)
+	}
+
+	if (path.Depth == 0) && !path.Scoped && (len(path.Parts) > 0) {
+		if depth, i, ok := g.blockParam(path.Parts[0]); ok {
+			return g.contextLookup(fmt.Sprintf("blockParams[%d][%d]", depth, i), path.Parts[1:])
+		}
+	}
+
+	base := "depth0"
+	if path.Depth > 0 {
+		base = "depths[" + strconv.Itoa(path.Depth) + "]"
+	}
+
+	return g.contextLookup(base, path.Parts)
+}
+
+// contextLookup returns the expression that looks up given property names from given base expression, stopping at null values
+func (g *generator) contextLookup(base string, parts []string) string {
+	result := base
+
+	for i, part := range parts {
+		if i == 0 {
+			result = fmt.Sprintf("(%s != null ? lookupProperty(%s,%s) : %s)", base, base, jsString(part), base)
+		} else {
+			result = fmt.Sprintf("((stack1 = %s) != null ? lookupProperty(stack1,%s) : stack1)", result, jsString(part))
+		}
+	}
+
+	return result
+}
+
+// simpleName returns the name of given expression and true if it is a simple identifier, ie. a name that can be a helper or a context value
+func (g *generator) simpleName(expr *ast.Expression) (string, bool) {
+	// literals are looked up as names, eg. {{"foo bar"}}
+	if lit, ok := ast.LiteralStr(expr.Path); ok {
+		return lit, true
+	}
+
+	path, ok := expr.Path.(*ast.PathExpression)
+	if !ok || path.Data || path.Scoped || (path.Depth > 0) || (len(path.Parts) != 1) {
+		return "", false
+	}
+
+	if _, _, ok := g.blockParam(path.Parts[0]); ok {
+		return "", false
+	}
+
+	return path.Parts[0], true
+}
+
+// blockParam returns the depth and index of given block param name, and true if it is declared by a program being generated
+func (g *generator) blockParam(name string) (int, int, bool) {
+	for depth, params := range g.blockParams {
+		for i, param := range params {
+			if param == name {
+				return depth, i, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// jsString returns given string as a JavaScript string literal
+func jsString(str string) string {
+	var buf strings.Builder
+
+	buf.WriteByte('"')
+
+	for _, r := range str {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '<':
+			// no "</script>" in inline scripts
+			buf.WriteString(`\u003C`)
+		case '\u2028', '\u2029':
+			fmt.Fprintf(&buf, `\u%04X`, r)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+
+	buf.WriteByte('"')
+
+	return buf.String()
+}
@@ -0,0 +1,151 @@
+package jsgen
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+var precompileTests = []struct {
+	name   string
+	source string
+	ctx    interface{}
+}{
+	{"content", "Hello </script> \"world\"\n", nil},
+	{"escaped", "<p>{{title}}</p>", map[string]string{"title": "<b>&</b>"}},
+	{"unescaped", "{{{title}}} {{&title}}", map[string]string{"title": "<b>"}},
+	{"paths", "{{author.name}} {{this.author.name}} {{missing.name}} {{author/name}}", map[string]interface{}{"author": map[string]string{"name": "Jean"}}},
+	{"if else", "{{#if ok}}yes{{else}}no{{/if}} {{#unless ok}}no{{else}}yes{{/unless}}", map[string]bool{"ok": true}},
+	{"each", "{{#each items}}{{@index}}:{{.}}{{#if @last}}.{{else}},{{/if}}{{else}}none{{/each}}", map[string][]string{"items": {"a", "b"}}},
+	{"each empty", "{{#each items}}{{.}}{{else}}none{{/each}}", map[string][]string{"items": {}}},
+	{"with", "{{#with author}}{{name}} in {{../title}}{{/with}}", map[string]interface{}{"title": "Book", "author": map[string]string{"name": "Jean"}}},
+	{"sections", "{{#author}}{{name}}{{/author}}{{#items}}[{{.}}]{{/items}}{{^items}}empty{{/items}}", map[string]interface{}{"author": map[string]string{"name": "Jean"}, "items": []int{1, 2}}},
+	{"block params", "{{#each items as |item i|}}{{#with item as |it|}}{{i}}={{it.name}}{{@root.sep}}{{/with}}{{/each}}", map[string]interface{}{"sep": ";", "items": []map[string]string{{"name": "a"}, {"name": "b"}}}},
+	{"data depth", "{{#each rows}}{{#each this}}{{@../index}}.{{@index}} {{/each}}{{/each}}", map[string][][]int{"rows": {{1, 2}, {3}}}},
+	{"lookup", "{{lookup map key}} {{#with (lookup map key)}}{{.}}{{/with}}", map[string]interface{}{"key": "a", "map": map[string]string{"a": "A"}}},
+	{"literals", `{{#if true}}{{"str"}} {{12}} {{lookup . "a b"}}{{/if}}{{#if 0}}zero{{/if}}`, map[string]string{"a b": "c"}},
+}
+
+// TestPrecompile checks generated specs with a minimal JavaScript runtime, against raymond output
+func TestPrecompile(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	runtime, err := os.ReadFile("testdata/runtime.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var script strings.Builder
+	script.Write(runtime)
+	script.WriteString("var results = [];\n")
+
+	for _, test := range precompileTests {
+		spec, err := Precompile(test.source)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+
+		ctx, err := json.Marshal(test.ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		script.WriteString("results.push(Handlebars.template(" + spec + ")(" + string(ctx) + "));\n")
+	}
+
+	script.WriteString("console.log(JSON.stringify(results));\n")
+
+	results := runScript(t, node, script.String())
+
+	for i, test := range precompileTests {
+		if expected := raymond.MustRender(test.source, test.ctx); results[i] != expected {
+			t.Errorf("%s: expected %q, got %q", test.name, expected, results[i])
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	opts := Options{
+		Templates: map[string]string{
+			"page":  "<ul>{{#each users}}{{> user}}{{/each}}</ul>{{> (whichFooter) year=2024}}",
+			"empty": "",
+		},
+		Partials: map[string]string{
+			"user":   "  <li>{{name}}</li>\n",
+			"footer": "<footer>{{year}}</footer>",
+		},
+	}
+
+	code, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, expected := range []string{
+		"templates = Handlebars.templates = Handlebars.templates || {};",
+		`templates["empty"] = template(`,
+		`Handlebars.registerPartial("footer", template(`,
+		`"usePartial":true`,
+	} {
+		if !strings.Contains(string(code), expected) {
+			t.Errorf("Expected generated code to contain %q", expected)
+		}
+	}
+
+	if strings.Index(string(code), `"empty"`) > strings.Index(string(code), `"page"`) {
+		t.Errorf("Expected templates to be sorted by name")
+	}
+
+	if _, err := Generate(Options{Templates: map[string]string{"broken": "{{foo"}}); (err == nil) || !strings.HasPrefix(err.Error(), "broken: ") {
+		t.Errorf("Expected a parse error prefixed by template name, got: %v", err)
+	}
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	runtime, err := os.ReadFile("testdata/runtime.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := string(runtime) + string(code) + `
+Handlebars.helpers.whichFooter = function() { return "footer"; };
+console.log(JSON.stringify([Handlebars.templates.page({users: [{name: "foo"}, {name: "bar"}]})]));
+`
+
+	if results := runScript(t, node, script); results[0] != "<ul>  <li>foo</li>\n  <li>bar</li>\n</ul><footer>2024</footer>" {
+		t.Errorf("Unexpected output: %q", results[0])
+	}
+}
+
+// runScript runs given JavaScript with node, and returns the JSON array of strings it prints
+func runScript(t *testing.T, node string, script string) []string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.js")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(node, path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run generated code: %s\n%s", err, out)
+	}
+
+	var results []string
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("Unexpected script output: %s", out)
+	}
+
+	return results
+}
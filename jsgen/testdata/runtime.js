@@ -0,0 +1,90 @@
+// Minimal implementation of the handlebars.js runtime API used by precompiled templates, to check generated specs without the official runtime.
+var Handlebars = {
+  templates: {},
+  partials: {},
+  helpers: {},
+
+  escapeExpression: function(value) {
+    if (value == null) { return ""; }
+    if (value && value.toHTML) { return value.toHTML(); }
+    return String(value).replace(/[&<>"'`=]/g, function(c) {
+      return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#x27;", "`": "&#x60;", "=": "&#x3D;"}[c];
+    });
+  },
+
+  registerPartial: function(name, fn) { Handlebars.partials[name] = fn; },
+
+  template: function(spec) {
+    var container = {
+      escapeExpression: Handlebars.escapeExpression,
+      lambda: function(current, context) { return typeof current === "function" ? current.call(context) : current; },
+      lookupProperty: function(parent, name) { return Object.prototype.hasOwnProperty.call(parent, name) ? parent[name] : undefined; },
+      noop: function() { return ""; },
+      nullContext: Object.seal({}),
+      data: function(value, depth) { while (value && depth--) { value = value._parent; } return value; },
+      hooks: {
+        helperMissing: function() {
+          if (arguments.length === 1) { return undefined; }
+          throw new Error('Missing helper: "' + arguments[arguments.length - 1].name + '"');
+        },
+        blockHelperMissing: function(context, options) {
+          if (context === true) { return options.fn(this); }
+          if (context === false || context == null || (Array.isArray(context) && context.length === 0)) { return options.inverse(this); }
+          if (Array.isArray(context)) { return Handlebars.helpers.each(context, options); }
+          return options.fn(context);
+        }
+      },
+      program: function(i, data, declaredBlockParams, blockParams, depths) {
+        var fn = spec[i];
+        return function(context, options) {
+          options = options || {};
+          var currentDepths = depths && (context != depths[0] ? [context].concat(depths) : depths);
+          return fn(container, context, container.helpers, container.partials, options.data || data, blockParams && [options.blockParams].concat(blockParams), currentDepths);
+        };
+      },
+      invokePartial: function(partial, context, options) {
+        if (typeof partial === "string" && !options.name) {
+          options.name = partial;
+          partial = options.partials[partial];
+        }
+        if (!partial) { throw new Error("The partial " + options.name + " could not be found"); }
+        if (options.hash) { context = Object.assign({}, context, options.hash); }
+        var result = partial(context, options);
+        if (options.indent) {
+          result = result.split("\n").map(function(line, i, lines) {
+            return (i === lines.length - 1 && line === "") ? line : options.indent + line;
+          }).join("\n");
+        }
+        return result;
+      }
+    };
+
+    return function(context, options) {
+      options = options || {};
+      container.helpers = Object.assign({}, Handlebars.helpers, options.helpers);
+      container.partials = Object.assign({}, Handlebars.partials, options.partials);
+      var data = options.data || {root: context};
+      return spec.main(container, context, container.helpers, container.partials, data, spec.useBlockParams ? [] : undefined, spec.useDepths ? [context] : undefined);
+    };
+  }
+};
+
+function isEmpty(value) {
+  return !value || (Array.isArray(value) && value.length === 0);
+}
+
+Handlebars.helpers = {
+  "if": function(cond, options) { return isEmpty(cond) ? options.inverse(this) : options.fn(this); },
+  "unless": function(cond, options) { return isEmpty(cond) ? options.fn(this) : options.inverse(this); },
+  "with": function(ctx, options) { return isEmpty(ctx) ? options.inverse(this) : options.fn(ctx, {data: options.data, blockParams: [ctx]}); },
+  "lookup": function(obj, field) { return obj && obj[field]; },
+  "log": function() { return ""; },
+  "each": function(ctx, options) {
+    var result = "", keys = Array.isArray(ctx) ? ctx.map(function(v, i) { return i; }) : Object.keys(ctx || {});
+    keys.forEach(function(key, i) {
+      var data = {_parent: options.data, root: options.data && options.data.root, key: key, index: i, first: i === 0, last: i === keys.length - 1};
+      result += options.fn(ctx[key], {data: data, blockParams: [ctx[key], key]});
+    });
+    return keys.length ? result : options.inverse(this);
+  }
+};
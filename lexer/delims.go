@@ -0,0 +1,126 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// delimiters holds the strings and patterns that detect mustaches, for a pair of open and close delimiters
+type delimiters struct {
+	open  string // {{
+	close string // }}
+
+	escapedEscapedOpen  string // \\{{
+	escapedOpen         string // \{{
+	openStrip           string // {{~
+	closeStrip          string // ~}}
+	closeUnescaped      string // }}}
+	closeUnescapedStrip string // }~}}
+
+	// characters that may be matched by patterns
+	chars string
+
+	rOpenRaw             pattern
+	rCloseRaw            pattern
+	rOpenEndRaw          pattern
+	rOpenEndRawLookAhead pattern
+	rOpenUnescaped       pattern
+	rCloseUnescaped      pattern
+	rOpenBlock           pattern
+	rOpenEndBlock        pattern
+	rOpenPartial         pattern
+	rInverse             pattern
+	rOpenInverse         pattern
+	rOpenInverseChain    pattern
+	rOpen                pattern
+	rClose               pattern
+	rOpenCommentDash     pattern
+	rCloseCommentDash    pattern
+	rOpenComment         pattern
+	rCloseComment        pattern
+}
+
+// defaultDelimiters are the {{ and }} delimiters, shared by all lexers scanning without custom delimiters
+var defaultDelimiters = newDelimiters("{{", "}}")
+
+// delimitersPair is the key of delimiters cache
+type delimitersPair struct {
+	open  string
+	close string
+}
+
+// delimitersCache holds the custom delimiters compiled so far, shared by all lexers: delimitersPair => *delimiters
+//
+// Custom delimiters are set by code, not by templates, so there are only a few of them.
+var delimitersCache sync.Map
+
+// cachedDelimiters returns the delimiters for given open and close strings, compiling their patterns on first call only
+func cachedDelimiters(open string, close string) *delimiters {
+	key := delimitersPair{open, close}
+
+	if result, ok := delimitersCache.Load(key); ok {
+		return result.(*delimiters)
+	}
+
+	result, _ := delimitersCache.LoadOrStore(key, newDelimiters(open, close))
+
+	return result.(*delimiters)
+}
+
+// newDelimiters instanciates delimiters, and compiles their patterns
+//
+// A raw block is opened by two open delimiters, and an unescaped mustache by an open delimiter followed by {, like {{{{ and {{{ for the default delimiters.
+func newDelimiters(open string, close string) *delimiters {
+	result := &delimiters{
+		open:                open,
+		close:               close,
+		escapedEscapedOpen:  `\\` + open,
+		escapedOpen:         `\` + open,
+		openStrip:           open + "~",
+		closeStrip:          "~" + close,
+		closeUnescaped:      "}" + close,
+		closeUnescapedStrip: "}~" + close,
+		chars:               patternChars + open + close,
+	}
+
+	result.compile()
+
+	return result
+}
+
+// checkDelimiters returns an error if given open and close delimiters can't be used to scan an input
+func checkDelimiters(open string, close string) error {
+	if (open == "") || (close == "") {
+		return fmt.Errorf("Invalid delimiters %q and %q: both must be set", open, close)
+	}
+
+	if strings.IndexFunc(open+close, isSpace) != -1 {
+		return fmt.Errorf("Invalid delimiters %q and %q: whitespaces are not allowed", open, close)
+	}
+
+	if open == "{" {
+		// {{ would open both a raw block and an unescaped mustache
+		return fmt.Errorf("Invalid delimiters %q and %q: open delimiter can't be {", open, close)
+	}
+
+	return nil
+}
+
+// strip returns true for each ~ marker of given mustache token value, that strips whitespaces preceding and following it
+func (d *delimiters) strip(val string) (bool, bool) {
+	return strings.HasPrefix(val, d.openStrip), strings.HasSuffix(val, d.closeStrip)
+}
+
+// commentText returns the text of given comment token value, without its delimiters, strip markers and up to two dashes on each side
+func (d *delimiters) commentText(val string) string {
+	val = strings.TrimPrefix(val, d.open)
+	val = strings.TrimPrefix(val, "~")
+	val = strings.TrimPrefix(val, "!")
+	val = strings.TrimPrefix(strings.TrimPrefix(val, "-"), "-")
+
+	val = strings.TrimSuffix(val, d.close)
+	val = strings.TrimSuffix(val, "~")
+
+	return strings.TrimSuffix(strings.TrimSuffix(val, "-"), "-")
+}
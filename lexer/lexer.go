@@ -2,8 +2,10 @@
 package lexer
 
 import (
+	"context"
 	"fmt"
-	"regexp"
+	"io"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -13,27 +15,29 @@ import (
 //   - https://github.com/wycats/handlebars.js/blob/master/src/handlebars.l
 //   - https://github.com/golang/go/blob/master/src/text/template/parse/lex.go
 
-const (
-	// Mustaches detection
-	escapedEscapedOpenMustache  = "\\\\{{"
-	escapedOpenMustache         = "\\{{"
-	openMustache                = "{{"
-	closeMustache               = "}}"
-	closeStripMustache          = "~}}"
-	closeUnescapedStripMustache = "}~}}"
-)
-
 const eof = -1
 
+// readSize is the minimum size of the chunks read from a reader
+const readSize = 4096
+
 // lexFunc represents a function that returns the next lexer function.
 type lexFunc func(*Lexer) lexFunc
 
 // Lexer is a lexical analyzer.
 type Lexer struct {
-	input    string     // input to scan
-	name     string     // lexer name, used for testing purpose
-	tokens   chan Token // channel of scanned tokens
-	nextFunc lexFunc    // the next function to execute
+	input    string      // input to scan
+	name     string      // lexer name, used for testing purpose
+	opts     Options     // scanning options
+	delims   *delimiters // mustache delimiters
+	tokens   []Token     // tokens scanned by last steps
+	fetched  int         // number of scanned tokens already fetched
+	last     Token       // last fetched token
+	nextFunc lexFunc     // the next function to execute
+
+	ctx  context.Context // context of scanning, if any
+	done <-chan struct{} // channel closed once context is done
+
+	count int // number of scanned tokens, EOF excepted
 
 	pos   int // current byte position in input string
 	line  int // current line position in input string
@@ -41,73 +45,159 @@ type Lexer struct {
 	start int // start position of the token we are scanning
 
 	// the shameful contextual properties needed because `nextFunc` is not enough
-	closeComment *regexp.Regexp // regexp to scan close of current comment
-	rawBlock     bool           // are we parsing a raw block content ?
+	closeComment pattern // pattern to scan close of current comment
+	rawBlock     bool    // are we parsing a raw block content ?
+	unescaped    bool    // are we parsing an unescaped mustache ?
+	depth        int     // number of subexpressions opened in current mustache
+	chain        bool    // was last token an OPEN_INVERSE_CHAIN ?
+
+	// when scanning a reader, input only holds the current token and the following characters read so far
+	reader  io.Reader // reader of input
+	reading bool      // is there input left to read ?
+	offset  int       // position of input string in read input
+	size    int       // number of bytes read
+	buf     []byte    // read buffer
+	pending []byte    // incomplete rune ending last chunk read
+	invalid bool      // did last chunk read end with invalid UTF-8 bytes ?
+	readErr error     // error that stopped reading
+
+	// index of line starts, to compute positions
+	lines   []int // byte positions of line starts in input
+	indexed int   // number of input bytes indexed in lines
 }
 
 var (
-	lookheadChars        = `[\s` + regexp.QuoteMeta("=~}/)|") + `]`
-	literalLookheadChars = `[\s` + regexp.QuoteMeta("~})") + `]`
+	// characters that may follow an identifier, in addition to whitespaces
+	lookheadChars = "=~}/)|"
+
+	// characters that may follow a literal, in addition to whitespaces
+	literalLookheadChars = "~})"
 
 	// characters not allowed in an identifier
 	unallowedIDChars = " \n\t!\"#%&'()*+,./;<=>@[\\]^`{|}~"
 
-	// regular expressions
-	rID                  = regexp.MustCompile(`^[^` + regexp.QuoteMeta(unallowedIDChars) + `]+`)
-	rDotID               = regexp.MustCompile(`^\.` + lookheadChars)
-	rTrue                = regexp.MustCompile(`^true` + literalLookheadChars)
-	rFalse               = regexp.MustCompile(`^false` + literalLookheadChars)
-	rOpenRaw             = regexp.MustCompile(`^\{\{\{\{`)
-	rCloseRaw            = regexp.MustCompile(`^\}\}\}\}`)
-	rOpenEndRaw          = regexp.MustCompile(`^\{\{\{\{/`)
-	rOpenEndRawLookAhead = regexp.MustCompile(`\{\{\{\{/`)
-	rOpenUnescaped       = regexp.MustCompile(`^\{\{~?\{`)
-	rCloseUnescaped      = regexp.MustCompile(`^\}~?\}\}`)
-	rOpenBlock           = regexp.MustCompile(`^\{\{~?#`)
-	rOpenEndBlock        = regexp.MustCompile(`^\{\{~?/`)
-	rOpenPartial         = regexp.MustCompile(`^\{\{~?>`)
-	// {{^}} or {{else}}
-	rInverse          = regexp.MustCompile(`^(\{\{~?\^\s*~?\}\}|\{\{~?\s*else\s*~?\}\})`)
-	rOpenInverse      = regexp.MustCompile(`^\{\{~?\^`)
-	rOpenInverseChain = regexp.MustCompile(`^\{\{~?\s*else`)
-	// {{ or {{&
-	rOpen            = regexp.MustCompile(`^\{\{~?&?`)
-	rClose           = regexp.MustCompile(`^~?\}\}`)
-	rOpenBlockParams = regexp.MustCompile(`^as\s+\|`)
-	// {{!--  ... --}}
-	rOpenCommentDash  = regexp.MustCompile(`^\{\{~?!--\s*`)
-	rCloseCommentDash = regexp.MustCompile(`^\s*--~?\}\}`)
-	// {{! ... }}
-	rOpenComment  = regexp.MustCompile(`^\{\{~?!\s*`)
-	rCloseComment = regexp.MustCompile(`^\s*~?\}\}`)
+	// characters that may be matched by mustache delimiter patterns, in addition to delimiters
+	patternChars = "{}~!-#/>^&else \t\n\f\r"
+)
+
+// Options represents the options used to scan an input.
+type Options struct {
+	// MaxContentSize is the maximum size in bytes of content tokens, zero meaning no limit.
+	//
+	// Larger content is emitted as several tokens, cut after a newline when possible, so that a huge static span between two mustaches is never handled as a whole. Content is never cut in its leading or trailing whitespaces, so a token may exceed that size when it starts or ends with lots of them.
+	MaxContentSize int
+
+	// InvalidUTF8 defines how invalid UTF-8 sequences of input are handled. By default, they are kept as is.
+	InvalidUTF8 UTF8Policy
+
+	// OpenDelim and CloseDelim are the delimiters of mustaches, eg. "<%" and "%>". By default, they are "{{" and "}}".
+	//
+	// Both must be set, without whitespaces, or an error token is emitted before any other token. Mustaches are then written like with default delimiters: eg. <%# block %>, <%! comment %>, <%{ unescaped }%>, <%<% raw %>%> and \<% for an escaped mustache.
+	//
+	// With a } close delimiter, eg. ${ and }, both unescaped mustaches and raw blocks are closed by }}, so the open mustache tells them apart. The open delimiter can't be {, as {{ would both open a raw block and an unescaped mustache.
+	OpenDelim  string
+	CloseDelim string
+
+	// MaxInputBytes is the maximum size in bytes of input, zero meaning no limit. An error token is emitted if input is larger: before any other token when scanning a string, and once the limit is read when scanning a reader.
+	MaxInputBytes int
+
+	// MaxTokens is the maximum number of tokens, the EOF token excepted, zero meaning no limit. An error token is emitted instead of the first token past that limit.
+	MaxTokens int
+
+	// MaxSubexpressionDepth is the maximum number of nested subexpressions in a mustache, zero meaning DefaultMaxSubexpressionDepth and a negative value no limit. An error token is emitted instead of the first open parenthesis past that limit, so that deeply nested input can't exhaust the stack of recursive consumers.
+	MaxSubexpressionDepth int
+
+	// StringEscapes unescapes the \n, \t, \r, \\, \", \', \xNN and \uXXXX escape sequences of strings, and emits an error token for other ones. By default, only the escaped delimiter of a string is unescaped, like handlebars.js does, so that its raw text is kept, eg. for round-tripping. In both cases, the Pos and End fields of string tokens give the raw text of string in input.
+	StringEscapes bool
+
+	// PreserveEscapes keeps the escape characters of escaped mustaches in content tokens, eg. for formatters round-tripping templates: \{{foo}} is then emitted as content "\{{foo}}" instead of "{{foo}}", and \\{{foo}} as content "\\" followed by the mustache, instead of "\". Content tokens then hold their source verbatim, so they must not be rendered as is.
+	PreserveEscapes bool
+
+	// Recover makes the lexer go on after an invalid expression, eg. for editor tooling that needs tokens following a typo: the error token is followed by the tokens scanned from the next close mustache, or newline. Errors at end of input, like an unclosed comment, and input errors still end scanning.
+	Recover bool
+}
+
+// DefaultMaxSubexpressionDepth is the maximum number of nested subexpressions when the MaxSubexpressionDepth option is not set.
+const DefaultMaxSubexpressionDepth = 40
+
+// UTF8Policy defines how invalid UTF-8 sequences are handled.
+type UTF8Policy int
+
+const (
+	// UTF8PassThrough keeps invalid UTF-8 sequences as is.
+	UTF8PassThrough UTF8Policy = iota
+
+	// UTF8Replace replaces each run of invalid UTF-8 bytes with the replacement rune U+FFFD. Token positions then refer to the replaced input.
+	UTF8Replace
+
+	// UTF8Error emits an error token if input contains an invalid UTF-8 sequence, before any other token.
+	UTF8Error
 )
 
 // Scan scans given input.
 //
-// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer.
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer. Call Release() on returned lexer once done, so that it is reused by subsequent scans.
 func Scan(input string) *Lexer {
-	return scanWithName(input, "")
+	return scanWithName(input, "", Options{})
+}
+
+// ScanWithOptions scans given input, with given options.
+//
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer. Call Release() on returned lexer once done, so that it is reused by subsequent scans.
+func ScanWithOptions(input string, opts Options) *Lexer {
+	return scanWithName(input, "", opts)
+}
+
+// ScanContext scans given input, until given context is done.
+//
+// Context is checked before scanning each token: once it is done, an error token is emitted instead. As input is scanned by the goroutine fetching tokens, a canceled scan leaves nothing running.
+//
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer. Call Release() on returned lexer once done, so that it is reused by subsequent scans.
+func ScanContext(ctx context.Context, input string) *Lexer {
+	return ScanContextWithOptions(ctx, input, Options{})
+}
+
+// ScanContextWithOptions scans given input, with given options, until given context is done.
+//
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer. Call Release() on returned lexer once done, so that it is reused by subsequent scans.
+func ScanContextWithOptions(ctx context.Context, input string, opts Options) *Lexer {
+	result := getLexer(input, nil, opts)
+	result.ctx, result.done = ctx, ctx.Done()
+
+	return result
+}
+
+// ScanReader scans the input read from given reader.
+//
+// Input is read incrementally, as tokens are fetched, so that a large template is never loaded in memory as a whole: only the current token and a few kilobytes of lookahead are buffered. Use the MaxContentSize option to also bound the size of content tokens.
+//
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer. Call Release() on returned lexer once done, so that it is reused by subsequent scans.
+func ScanReader(r io.Reader) *Lexer {
+	return ScanReaderWithOptions(r, Options{})
+}
+
+// ScanReaderWithOptions scans the input read from given reader, with given options.
+//
+// With the UTF8Error option, the error token is emitted once the invalid UTF-8 sequence is read, so tokens preceding it may have been emitted already. A read error is also emitted as an error token.
+//
+// Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer. Call Release() on returned lexer once done, so that it is reused by subsequent scans.
+func ScanReaderWithOptions(r io.Reader, opts Options) *Lexer {
+	return getLexer("", r, opts)
 }
 
 // scanWithName scans given input, with a name used for testing
 //
 // Tokens can then be fetched sequentially thanks to NextToken() function on returned lexer.
-func scanWithName(input string, name string) *Lexer {
-	result := &Lexer{
-		input:  input,
-		name:   name,
-		tokens: make(chan Token),
-		line:   1,
-	}
-
-	go result.run()
+func scanWithName(input string, name string, opts Options) *Lexer {
+	result := getLexer(input, nil, opts)
+	result.name = name
 
 	return result
 }
 
 // Collect scans and collect all tokens.
 //
-// This should be used for debugging purpose only. You should use Scan() and lexer.NextToken() functions instead.
+// This should be used for debugging purpose only. You should use Tokenize(), or Scan() and lexer.NextToken() functions instead.
 func Collect(input string) []Token {
 	var result []Token
 
@@ -121,25 +211,302 @@ func Collect(input string) []Token {
 		}
 	}
 
+	l.Release()
+
 	return result
 }
 
+// Error is the error returned by Tokenize when input can't be scanned.
+type Error struct {
+	// Line is the line of error in input
+	Line int
+
+	// Pos is the byte position of error in input
+	Pos int
+
+	// Message describes the error
+	Message string
+}
+
+// Error implements the error interface.
+func (err *Error) Error() string {
+	return fmt.Sprintf("Lexer error on line %d: %s", err.Line, err.Message)
+}
+
+// Tokenize scans given input and returns all its tokens, the final EOF token included.
+//
+// If input can't be scanned, the tokens preceding the error token are returned with an *Error.
+func Tokenize(input string) ([]Token, error) {
+	// a mustache is usually scanned as an open token, an identifier, a close token and following content
+	result := make([]Token, 0, 4*strings.Count(input, defaultDelimiters.open)+2)
+
+	l := Scan(input)
+	defer l.Release()
+
+	for {
+		token := l.NextToken()
+
+		switch token.Kind {
+		case TokenError:
+			return result, &Error{Line: token.Line, Pos: token.Pos, Message: token.Val}
+		case TokenEOF:
+			return append(result, token), nil
+		}
+
+		result = append(result, token)
+	}
+}
+
 // NextToken returns the next scanned token.
+//
+// Input is scanned as tokens are fetched, by the calling goroutine, so a lexer can be dropped before the end of input. Once an EOF or error token has been returned, it is returned again, unless that error is followed by other tokens with the Recover option.
 func (l *Lexer) NextToken() Token {
-	result := <-l.tokens
+	if l.fetched == len(l.tokens) {
+		// all scanned tokens have been fetched
+		l.tokens = l.tokens[:0]
+		l.fetched = 0
 
-	return result
+		for (len(l.tokens) == 0) && (l.nextFunc != nil) {
+			l.step()
+		}
+
+		if len(l.tokens) == 0 {
+			return l.last
+		}
+	}
+
+	l.last = l.tokens[l.fetched]
+	l.fetched++
+
+	return l.last
+}
+
+// init starts lexical analysis
+func (l *Lexer) init() {
+	l.nextFunc = lexContent
+
+	if (l.opts.OpenDelim != "") || (l.opts.CloseDelim != "") {
+		if err := checkDelimiters(l.opts.OpenDelim, l.opts.CloseDelim); err != nil {
+			l.nextFunc = l.errorf("%s", err)
+			return
+		}
+	}
+
+	if (l.opts.MaxInputBytes > 0) && (len(l.input) > l.opts.MaxInputBytes) {
+		l.nextFunc = l.errorf("Input is larger than %d bytes", l.opts.MaxInputBytes)
+		return
+	}
+
+	if (l.opts.InvalidUTF8 == UTF8Error) && (l.reader == nil) {
+		if pos := invalidUTF8Pos(l.input); pos != -1 {
+			l.start = pos
+			l.line += strings.Count(l.input[:pos], "\n")
+			l.nextFunc = l.errorf("Invalid UTF-8 sequence at byte %d", pos)
+		}
+	}
+
+}
+
+// step executes the next lexer function
+//
+// A panic, that would be a lexer bug, is emitted as an error token, so that scanning untrusted input never crashes.
+func (l *Lexer) step() {
+	defer func() {
+		if r := recover(); r != nil {
+			l.nextFunc = l.errorf("Lexer failure: %v", r)
+		}
+	}()
+
+	if l.reading {
+		// forget already emitted tokens, but not their line starts
+		l.indexLines(l.offset + l.start)
+
+		l.input = l.input[l.start:]
+		l.offset += l.start
+		l.pos -= l.start
+		l.start = 0
+	}
+
+	if l.done != nil {
+		select {
+		case <-l.done:
+			l.nextFunc = l.errorf("Scanning canceled: %s", l.ctx.Err())
+			return
+		default:
+		}
+	}
+
+	l.nextFunc = l.nextFunc(l)
+
+	if (l.opts.MaxTokens > 0) && (l.count > l.opts.MaxTokens) {
+		l.dropTokens()
+	}
+}
+
+// dropTokens removes the tokens scanned past the MaxTokens limit, and emits an error instead
+func (l *Lexer) dropTokens() {
+	// search first token past the limit, EOF and error tokens not being counted
+	n := len(l.tokens)
+	for excess := l.count - l.opts.MaxTokens; excess > 0; {
+		n--
+
+		if kind := l.tokens[n].Kind; (kind != TokenEOF) && (kind != TokenError) {
+			excess--
+		}
+	}
+
+	// error is located at first dropped token
+	l.start = l.tokens[n].Pos - l.offset
+	l.line = l.tokens[n].Line
+
+	for i := n; i < len(l.tokens); i++ {
+		l.tokens[i] = Token{}
+	}
+	l.tokens = l.tokens[:n]
+
+	l.nextFunc = l.errorf("More than %d tokens", l.opts.MaxTokens)
+}
+
+// more reads the next chunk of input, and returns false if there is nothing left to read
+func (l *Lexer) more() bool {
+	if !l.reading {
+		return false
+	}
+
+	size := readSize
+	if len(l.input) > size {
+		// grow chunks with input, so that a large token is read in a few chunks
+		size = len(l.input)
+	}
+
+	if cap(l.buf) < len(l.pending)+size {
+		l.buf = make([]byte, len(l.pending)+size)
+	}
+
+	chunk := l.buf[:len(l.pending)+size]
+	copy(chunk, l.pending)
+
+	n, err := io.ReadAtLeast(l.reader, chunk[len(l.pending):], 1)
+	chunk = chunk[:len(l.pending)+n]
+	l.pending = l.pending[:0]
+	l.size += n
+
+	if err != nil {
+		l.reading = false
+
+		if err != io.EOF {
+			l.readErr = fmt.Errorf("Failed to read input: %s", err)
+		}
+	}
+
+	if (l.opts.MaxInputBytes > 0) && (l.size > l.opts.MaxInputBytes) {
+		// ignore bytes past the limit
+		chunk = chunk[:len(chunk)-(l.size-l.opts.MaxInputBytes)]
+		l.reading = false
+		l.readErr = fmt.Errorf("Input is larger than %d bytes", l.opts.MaxInputBytes)
+	}
+
+	if l.reading && (l.opts.InvalidUTF8 != UTF8PassThrough) {
+		// check incomplete rune with next chunk
+		i := incompleteRune(chunk)
+		l.pending = append(l.pending, chunk[i:]...)
+		chunk = chunk[:i]
+	}
+
+	switch l.opts.InvalidUTF8 {
+	case UTF8Replace:
+		l.input += l.replaceInvalidUTF8(chunk)
+
+	case UTF8Error:
+		if pos := invalidUTF8Pos(string(chunk)); pos != -1 {
+			l.input += string(chunk[:pos])
+			l.reading = false
+			l.readErr = fmt.Errorf("Invalid UTF-8 sequence at byte %d", l.offset+len(l.input))
+		} else {
+			l.input += string(chunk)
+		}
+
+	default:
+		l.input += string(chunk)
+	}
+
+	return true
+}
+
+// buffered returns true if input has a byte at given position, reading input if needed
+func (l *Lexer) buffered(pos int) bool {
+	for (pos >= len(l.input)) && l.more() {
+	}
+
+	return pos < len(l.input)
+}
+
+// fillPattern reads input until the characters that may be matched by a pattern at current scanning position are all buffered
+//
+// Patterns only match the characters of patternChars, so they can't match past the first other character.
+func (l *Lexer) fillPattern() {
+	for l.reading && (strings.TrimLeft(l.input[l.pos:], l.delims.chars) == "") && l.more() {
+	}
+}
+
+// incompleteRune returns the position of the incomplete rune ending given chunk, or the chunk length if it does not end with an incomplete rune
+func incompleteRune(chunk []byte) int {
+	for i := len(chunk) - 1; (i >= 0) && (i > len(chunk)-utf8.UTFMax); i-- {
+		if utf8.RuneStart(chunk[i]) {
+			if !utf8.FullRune(chunk[i:]) {
+				return i
+			}
+
+			break
+		}
+	}
+
+	return len(chunk)
+}
+
+// replaceInvalidUTF8 returns given chunk with each run of invalid UTF-8 bytes replaced by the replacement rune, like strings.ToValidUTF8() does, with runs continuing from previous chunk
+func (l *Lexer) replaceInvalidUTF8(chunk []byte) string {
+	var b strings.Builder
+	b.Grow(len(chunk))
+
+	for i := 0; i < len(chunk); {
+		r, w := utf8.DecodeRune(chunk[i:])
+
+		if (r == utf8.RuneError) && (w == 1) {
+			if !l.invalid {
+				b.WriteRune(utf8.RuneError)
+			}
+
+			l.invalid = true
+		} else {
+			b.Write(chunk[i : i+w])
+			l.invalid = false
+		}
+
+		i += w
+	}
+
+	return b.String()
 }
 
-// run starts lexical analysis
-func (l *Lexer) run() {
-	for l.nextFunc = lexContent; l.nextFunc != nil; {
-		l.nextFunc = l.nextFunc(l)
+// invalidUTF8Pos returns the byte position of the first invalid UTF-8 sequence of given string, or -1 if it is valid
+func invalidUTF8Pos(s string) int {
+	for i := 0; i < len(s); {
+		r, w := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError) && (w == 1) {
+			return i
+		}
+
+		i += w
 	}
+
+	return -1
 }
 
 // next returns next character from input, or eof of there is nothing left to scan
 func (l *Lexer) next() rune {
+	l.buffered(l.pos + utf8.UTFMax - 1)
+
 	if l.pos >= len(l.input) {
 		l.width = 0
 		return eof
@@ -153,13 +520,18 @@ func (l *Lexer) next() rune {
 }
 
 func (l *Lexer) produce(kind TokenKind, val string) {
-	l.tokens <- Token{kind, val, l.start, l.line}
+	if kind != TokenEOF {
+		l.count++
+	}
 
-	// scanning a new token
-	l.start = l.pos
+	l.tokens = append(l.tokens, Token{kind, val, l.offset + l.start, l.line, l.offset + l.pos, l.pos - l.start, false, false, ""})
+	l.chain = (kind == TokenOpenInverseChain)
 
 	// update line number
-	l.line += strings.Count(val, "\n")
+	l.line += strings.Count(l.input[l.start:l.pos], "\n")
+
+	// scanning a new token
+	l.start = l.pos
 }
 
 // emit emits a new scanned token
@@ -167,21 +539,159 @@ func (l *Lexer) emit(kind TokenKind) {
 	l.produce(kind, l.input[l.start:l.pos])
 }
 
-// emitContent emits scanned content
+// emitMustache emits a new scanned mustache delimiter or comment token, with its strip markers, and the text of comment
+func (l *Lexer) emitMustache(kind TokenKind) {
+	l.emit(kind)
+
+	tok := &l.tokens[len(l.tokens)-1]
+	tok.StripBefore, tok.StripAfter = l.delims.strip(tok.Val)
+
+	if kind == TokenComment {
+		tok.Text = l.delims.commentText(tok.Val)
+	}
+}
+
+// emitContent emits scanned content, in several tokens if it is larger than MaxContentSize option
 func (l *Lexer) emitContent() {
-	if l.pos > l.start {
+	if l.pos <= l.start {
+		return
+	}
+
+	l.emitContentChunks()
+	l.emit(TokenContent)
+}
+
+// emitContentChunks emits the first chunks of scanned content if it is larger than MaxContentSize option, and keeps the last one scanned
+func (l *Lexer) emitContentChunks() {
+	if l.opts.MaxContentSize <= 0 {
+		return
+	}
+
+	end := l.pos
+
+	for cut := l.contentCut(end); cut != -1; cut = l.contentCut(end) {
+		l.pos = cut
 		l.emit(TokenContent)
 	}
+
+	l.pos = end
+}
+
+// contentCut returns the position where the first chunk of content ending at given position must be cut, or -1 if it does not need to be cut
+//
+// Content is cut after the last newline that fits in a chunk, otherwise on a rune boundary. It is never cut in leading or trailing whitespaces, so that standalone lines and whitespace control are handled the same way as for a single content token.
+func (l *Lexer) contentCut(end int) int {
+	if end-l.start <= l.opts.MaxContentSize {
+		return -1
+	}
+
+	content := l.input[l.start:end]
+
+	first := strings.IndexFunc(content, isNotSpace)
+	if first == -1 {
+		// only whitespaces
+		return -1
+	}
+
+	last := strings.LastIndexFunc(content, isNotSpace)
+
+	cut := strings.LastIndexByte(content[:l.opts.MaxContentSize], '\n') + 1
+	if cut <= first {
+		cut = l.opts.MaxContentSize
+		for !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+
+		if cut <= first {
+			// keep leading whitespaces and first rune after them together
+			_, w := utf8.DecodeRuneInString(content[first:])
+			cut = first + w
+		}
+	}
+
+	if cut > last {
+		// keep last rune and trailing whitespaces together
+		return -1
+	}
+
+	return l.start + cut
 }
 
 // emitString emits a scanned string
-func (l *Lexer) emitString(delimiter rune) {
+//
+// Escaped delimiters are unescaped, or all escape sequences with the StringEscapes option.
+func (l *Lexer) emitString(delimiter rune) error {
 	str := l.input[l.start:l.pos]
 
-	// replace escaped delimiters
-	str = strings.Replace(str, "\\"+string(delimiter), string(delimiter), -1)
+	if l.opts.StringEscapes {
+		var err error
+		if str, err = unescapeString(str); err != nil {
+			return err
+		}
+	} else {
+		// replace escaped delimiters
+		str = strings.Replace(str, "\\"+string(delimiter), string(delimiter), -1)
+	}
 
 	l.produce(TokenString, str)
+
+	return nil
+}
+
+// unescapeString returns given string with its \n, \t, \r, \\, \", \', \xNN and \uXXXX escape sequences replaced
+func unescapeString(str string) (string, error) {
+	if strings.IndexByte(str, '\\') == -1 {
+		return str, nil
+	}
+
+	var result strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' {
+			result.WriteByte(str[i])
+			continue
+		}
+
+		r, _ := utf8.DecodeRuneInString(str[i+1:])
+		i++
+
+		switch r {
+		case 'n':
+			result.WriteByte('\n')
+		case 't':
+			result.WriteByte('\t')
+		case 'r':
+			result.WriteByte('\r')
+		case '\\', '"', '\'':
+			result.WriteRune(r)
+		case 'x', 'u':
+			size := 2
+			if r == 'u' {
+				size = 4
+			}
+
+			if i+1+size > len(str) {
+				return "", fmt.Errorf("Invalid escape sequence in string: %q", str[i-1:])
+			}
+
+			val, err := strconv.ParseUint(str[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("Invalid escape sequence in string: %q", str[i-1:i+1+size])
+			}
+
+			if r == 'x' {
+				result.WriteByte(byte(val))
+			} else {
+				result.WriteRune(rune(val))
+			}
+
+			i += size
+		default:
+			return "", fmt.Errorf("Invalid escape sequence in string: %q", "\\"+string(r))
+		}
+	}
+
+	return result.String(), nil
 }
 
 // peek returns but does not consume the next character in the input
@@ -223,30 +733,99 @@ func (l *Lexer) acceptRun(valid string) {
 }
 
 // errorf emits an error token
+//
+// If reading input failed, the read error is emitted instead, at the end of input read, as the scanning error may be due to the truncated input.
 func (l *Lexer) errorf(format string, args ...interface{}) lexFunc {
-	l.tokens <- Token{TokenError, fmt.Sprintf(format, args...), l.start, l.line}
+	if l.readErr != nil {
+		l.line += strings.Count(l.input[l.start:], "\n")
+		l.start = len(l.input)
+
+		format, args = "%s", []interface{}{l.readErr}
+	}
+
+	l.tokens = append(l.tokens, Token{TokenError, fmt.Sprintf(format, args...), l.offset + l.start, l.line, l.offset + l.start, 0, false, false, ""})
 	return nil
 }
 
+// syntaxErrorf emits an error token for an invalid expression
+//
+// With the Recover option, scanning then goes on at the next close mustache or newline following the start of invalid token.
+func (l *Lexer) syntaxErrorf(format string, args ...interface{}) lexFunc {
+	if !l.opts.Recover || (l.readErr != nil) {
+		return l.errorf(format, args...)
+	}
+
+	l.errorf(format, args...)
+
+	// resynchronize
+	l.pos = l.start
+
+	for {
+		if l.isString(l.delims.close) || l.isString(l.delims.closeStrip) || l.isString(l.delims.closeUnescaped) || l.isString(l.delims.closeUnescapedStrip) {
+			l.ignore()
+			return lexCloseMustache
+		}
+
+		if r := l.next(); (r == eof) || (r == '\n') {
+			l.backup()
+			l.ignore()
+			return lexContent
+		}
+	}
+}
+
+// maxDepth returns the maximum number of nested subexpressions, zero meaning no limit
+func (l *Lexer) maxDepth() int {
+	switch {
+	case l.opts.MaxSubexpressionDepth == 0:
+		return DefaultMaxSubexpressionDepth
+	case l.opts.MaxSubexpressionDepth < 0:
+		return 0
+	}
+
+	return l.opts.MaxSubexpressionDepth
+}
+
 // isString returns true if content at current scanning position starts with given string
 func (l *Lexer) isString(str string) bool {
+	l.buffered(l.pos + len(str) - 1)
+
 	return strings.HasPrefix(l.input[l.pos:], str)
 }
 
-// findRegexp returns the first string from current scanning position that matches given regular expression
-func (l *Lexer) findRegexp(r *regexp.Regexp) string {
-	return r.FindString(l.input[l.pos:])
+// isLookahead returns true if content at current scanning position starts with given string, followed by a character accepted by given function, or by close delimiter
+func (l *Lexer) isLookahead(str string, lookahead func(byte) bool) bool {
+	next := l.pos + len(str)
+
+	if !l.buffered(next) || !l.isString(str) {
+		return false
+	}
+
+	if lookahead(l.input[next]) {
+		return true
+	}
+
+	l.buffered(next + len(l.delims.close) - 1)
+
+	return strings.HasPrefix(l.input[next:], l.delims.close)
 }
 
-// indexRegexp returns the index of the first string from current scanning position that matches given regular expression
-//
-// It returns -1 if not found
-func (l *Lexer) indexRegexp(r *regexp.Regexp) int {
-	loc := r.FindStringIndex(l.input[l.pos:])
-	if loc == nil {
-		return -1
+// openBlockParamsLen returns the length of the "as |" block params opening at current scanning position, or 0 if not found
+func (l *Lexer) openBlockParamsLen() int {
+	if !l.isString("as") {
+		return 0
 	}
-	return loc[0]
+
+	i := l.pos + len("as")
+	for l.buffered(i) && isSpace(rune(l.input[i])) {
+		i++
+	}
+
+	if (i == l.pos+len("as")) || !l.buffered(i) || (l.input[i] != '|') {
+		return 0
+	}
+
+	return i + 1 - l.pos
 }
 
 // lexContent scans content (ie: not between mustaches)
@@ -254,7 +833,12 @@ func lexContent(l *Lexer) lexFunc {
 	var next lexFunc
 
 	if l.rawBlock {
-		if i := l.indexRegexp(rOpenEndRawLookAhead); i != -1 {
+		i := l.indexPattern(l.delims.rOpenEndRawLookAhead)
+		for (i == -1) && l.more() {
+			i = l.indexPattern(l.delims.rOpenEndRawLookAhead)
+		}
+
+		if i != -1 {
 			// {{{{/
 			l.rawBlock = false
 			l.pos += i
@@ -263,32 +847,40 @@ func lexContent(l *Lexer) lexFunc {
 		} else {
 			return l.errorf("Unclosed raw block")
 		}
-	} else if l.isString(escapedEscapedOpenMustache) {
+	} else if l.isString(l.delims.escapedEscapedOpen) {
 		// \\{{
 
 		// emit content with only one escaped escape
 		l.next()
+
+		if l.opts.PreserveEscapes {
+			// or with both of them
+			l.next()
+		}
+
 		l.emitContent()
 
 		// ignore second escaped escape
-		l.next()
-		l.ignore()
+		if !l.opts.PreserveEscapes {
+			l.next()
+			l.ignore()
+		}
 
 		next = lexContent
-	} else if l.isString(escapedOpenMustache) {
+	} else if l.isString(l.delims.escapedOpen) {
 		// \{{
 		next = lexEscapedOpenMustache
-	} else if str := l.findRegexp(rOpenCommentDash); str != "" {
+	} else if str := l.findPattern(l.delims.rOpenCommentDash); str != "" {
 		// {{!--
-		l.closeComment = rCloseCommentDash
+		l.closeComment = l.delims.rCloseCommentDash
 
 		next = lexComment
-	} else if str := l.findRegexp(rOpenComment); str != "" {
+	} else if str := l.findPattern(l.delims.rOpenComment); str != "" {
 		// {{!
-		l.closeComment = rCloseComment
+		l.closeComment = l.delims.rCloseComment
 
 		next = lexComment
-	} else if l.isString(openMustache) {
+	} else if l.isString(l.delims.open) {
 		// {{
 		next = lexOpenMustache
 	}
@@ -301,29 +893,69 @@ func lexContent(l *Lexer) lexFunc {
 		return next
 	}
 
-	// scan next rune
-	if l.next() == eof {
+	// jump to next possible mustache
+	if !l.skipContent() {
 		// emit scanned content
 		l.emitContent()
 
+		if l.readErr != nil {
+			return l.errorf("%s", l.readErr)
+		}
+
 		// this is over
 		l.emit(TokenEOF)
 		return nil
 	}
 
+	if l.reading {
+		// content goes on in input not read yet
+		l.emitContentChunks()
+	}
+
 	// continue content scanning
 	return lexContent
 }
 
-// lexEscapedOpenMustache scans \{{
+// skipContent advances to the next position where a mustache may start, ie. the next first character of open delimiter, or the escape characters preceding it
+//
+// It returns false if end of input has been reached.
+func (l *Lexer) skipContent() bool {
+	if !l.buffered(l.pos) {
+		return false
+	}
+
+	// the next open delimiter may also follow input read so far, if any
+	next := len(l.input)
+
+	if i := strings.IndexByte(l.input[l.pos+1:], l.delims.open[0]); i != -1 {
+		next = l.pos + 1 + i
+	} else if !l.reading {
+		l.pos = next
+		return true
+	}
+
+	// \\{{ and \{{
+	for n := 0; (n < 2) && (next > l.pos+1) && (l.input[next-1] == '\\'); n++ {
+		next--
+	}
+
+	l.pos = next
+
+	return true
+}
+
+// lexEscapedOpenMustache scans \{{, and following open delimiters
 func lexEscapedOpenMustache(l *Lexer) lexFunc {
-	// ignore escape character
+	// ignore escape character, unless it is preserved in content
 	l.next()
-	l.ignore()
+
+	if !l.opts.PreserveEscapes {
+		l.ignore()
+	}
 
 	// scan mustaches
-	for l.peek() == '{' {
-		l.next()
+	for l.isString(l.delims.open) {
+		l.pos += len(l.delims.open)
 	}
 
 	return lexContent
@@ -336,35 +968,38 @@ func lexOpenMustache(l *Lexer) lexFunc {
 
 	nextFunc := lexExpression
 
-	if str = l.findRegexp(rOpenEndRaw); str != "" {
+	if str = l.findPattern(l.delims.rOpenEndRaw); str != "" {
 		tok = TokenOpenEndRawBlock
-	} else if str = l.findRegexp(rOpenRaw); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenRaw); str != "" {
 		tok = TokenOpenRawBlock
 		l.rawBlock = true
-	} else if str = l.findRegexp(rOpenUnescaped); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenUnescaped); str != "" {
 		tok = TokenOpenUnescaped
-	} else if str = l.findRegexp(rOpenBlock); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenBlock); str != "" {
 		tok = TokenOpenBlock
-	} else if str = l.findRegexp(rOpenEndBlock); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenEndBlock); str != "" {
 		tok = TokenOpenEndBlock
-	} else if str = l.findRegexp(rOpenPartial); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenPartial); str != "" {
 		tok = TokenOpenPartial
-	} else if str = l.findRegexp(rInverse); str != "" {
+	} else if str = l.findPattern(l.delims.rInverse); str != "" {
 		tok = TokenInverse
 		nextFunc = lexContent
-	} else if str = l.findRegexp(rOpenInverse); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenInverse); str != "" {
 		tok = TokenOpenInverse
-	} else if str = l.findRegexp(rOpenInverseChain); str != "" {
+	} else if str = l.findPattern(l.delims.rOpenInverseChain); str != "" {
 		tok = TokenOpenInverseChain
-	} else if str = l.findRegexp(rOpen); str != "" {
+	} else if str = l.findPattern(l.delims.rOpen); str != "" {
 		tok = TokenOpen
 	} else {
 		// this is rotten
-		panic("Current pos MUST be an opening mustache")
+		return l.errorf("Opening mustache expected")
 	}
 
+	l.unescaped = (tok == TokenOpenUnescaped)
+	l.depth = 0
+
 	l.pos += len(str)
-	l.emit(tok)
+	l.emitMustache(tok)
 
 	return nextFunc
 }
@@ -374,22 +1009,24 @@ func lexCloseMustache(l *Lexer) lexFunc {
 	var str string
 	var tok TokenKind
 
-	if str = l.findRegexp(rCloseRaw); str != "" {
+	// the longest close wins, but a custom close delimiter like } makes raw block and unescaped mustache closes the same, so the open mustache decides
+	raw, unescaped := l.findPattern(l.delims.rCloseRaw), l.findPattern(l.delims.rCloseUnescaped)
+	if (raw != "") && ((len(raw) > len(unescaped)) || !l.unescaped) {
 		// }}}}
-		tok = TokenCloseRawBlock
-	} else if str = l.findRegexp(rCloseUnescaped); str != "" {
+		str, tok = raw, TokenCloseRawBlock
+	} else if unescaped != "" {
 		// }}}
-		tok = TokenCloseUnescaped
-	} else if str = l.findRegexp(rClose); str != "" {
+		str, tok = unescaped, TokenCloseUnescaped
+	} else if str = l.findPattern(l.delims.rClose); str != "" {
 		// }}
 		tok = TokenClose
 	} else {
 		// this is rotten
-		panic("Current pos MUST be a closing mustache")
+		return l.errorf("Closing mustache expected")
 	}
 
 	l.pos += len(str)
-	l.emit(tok)
+	l.emitMustache(tok)
 
 	return lexContent
 }
@@ -397,15 +1034,15 @@ func lexCloseMustache(l *Lexer) lexFunc {
 // lexExpression scans inside mustaches
 func lexExpression(l *Lexer) lexFunc {
 	// search close mustache delimiter
-	if l.isString(closeMustache) || l.isString(closeStripMustache) || l.isString(closeUnescapedStripMustache) {
+	if l.isString(l.delims.close) || l.isString(l.delims.closeStrip) || l.isString(l.delims.closeUnescaped) || l.isString(l.delims.closeUnescapedStrip) {
 		return lexCloseMustache
 	}
 
 	// search some patterns before advancing scanning position
 
 	// "as |"
-	if str := l.findRegexp(rOpenBlockParams); str != "" {
-		l.pos += len(str)
+	if n := l.openBlockParamsLen(); n > 0 {
+		l.pos += n
 		l.emit(TokenOpenBlockParams)
 		return lexExpression
 	}
@@ -418,26 +1055,40 @@ func lexExpression(l *Lexer) lexFunc {
 	}
 
 	// .
-	if str := l.findRegexp(rDotID); str != "" {
+	if l.isLookahead(".", isIDLookahead) {
 		l.pos += len(".")
 		l.emit(TokenID)
 		return lexExpression
 	}
 
 	// true
-	if str := l.findRegexp(rTrue); str != "" {
+	if l.isLookahead("true", isLiteralLookahead) {
 		l.pos += len("true")
 		l.emit(TokenBoolean)
 		return lexExpression
 	}
 
 	// false
-	if str := l.findRegexp(rFalse); str != "" {
+	if l.isLookahead("false", isLiteralLookahead) {
 		l.pos += len("false")
 		l.emit(TokenBoolean)
 		return lexExpression
 	}
 
+	// null
+	if l.isLookahead("null", isLiteralLookahead) {
+		l.pos += len("null")
+		l.emit(TokenNull)
+		return lexExpression
+	}
+
+	// undefined
+	if l.isLookahead("undefined", isLiteralLookahead) {
+		l.pos += len("undefined")
+		l.emit(TokenUndefined)
+		return lexExpression
+	}
+
 	// let's scan next character
 	switch r := l.next(); {
 	case r == eof:
@@ -445,8 +1096,17 @@ func lexExpression(l *Lexer) lexFunc {
 	case isIgnorable(r):
 		return lexIgnorable
 	case r == '(':
+		if max := l.maxDepth(); (max > 0) && (l.depth >= max) {
+			return l.errorf("More than %d nested subexpressions", max)
+		}
+
+		l.depth++
 		l.emit(TokenOpenSexpr)
 	case r == ')':
+		if l.depth > 0 {
+			l.depth--
+		}
+
 		l.emit(TokenCloseSexpr)
 	case r == '=':
 		l.emit(TokenEquals)
@@ -468,7 +1128,7 @@ func lexExpression(l *Lexer) lexFunc {
 		l.backup()
 		return lexIdentifier
 	default:
-		return l.errorf("Unexpected character in expression: '%c'", r)
+		return l.syntaxErrorf("Unexpected character in expression: '%c'", r)
 	}
 
 	return lexExpression
@@ -476,9 +1136,9 @@ func lexExpression(l *Lexer) lexFunc {
 
 // lexComment scans {{!-- or {{!
 func lexComment(l *Lexer) lexFunc {
-	if str := l.findRegexp(l.closeComment); str != "" {
+	if str := l.findPattern(l.closeComment); str != "" {
 		l.pos += len(str)
-		l.emit(TokenComment)
+		l.emitMustache(TokenComment)
 
 		return lexContent
 	}
@@ -512,13 +1172,18 @@ func lexString(l *Lexer) lexFunc {
 	for {
 		r := l.next()
 		if r == eof || r == '\n' {
-			return l.errorf("Unterminated string")
+			return l.syntaxErrorf("Unterminated string")
 		}
 
 		if (r == delim) && (prev != '\\') {
 			break
 		}
 
+		if l.opts.StringEscapes && (r == '\\') && (prev == '\\') {
+			// an escaped backslash does not escape following character
+			r = 0
+		}
+
 		prev = r
 	}
 
@@ -526,7 +1191,9 @@ func lexString(l *Lexer) lexFunc {
 	l.backup()
 
 	// emit string
-	l.emitString(delim)
+	if err := l.emitString(delim); err != nil {
+		return l.syntaxErrorf("%s", err)
+	}
 
 	// skip end delimiter
 	l.next()
@@ -543,12 +1210,12 @@ func lexString(l *Lexer) lexFunc {
 // NOTE: borrowed from https://github.com/golang/go/tree/master/src/text/template/parse/lex.go
 func lexNumber(l *Lexer) lexFunc {
 	if !l.scanNumber() {
-		return l.errorf("bad number syntax: %q", l.input[l.start:l.pos])
+		return l.syntaxErrorf("bad number syntax: %q", l.input[l.start:l.pos])
 	}
 	if sign := l.peek(); sign == '+' || sign == '-' {
 		// Complex: 1+2i. No spaces, must end in 'i'.
 		if !l.scanNumber() || l.input[l.pos-1] != 'i' {
-			return l.errorf("bad number syntax: %q", l.input[l.start:l.pos])
+			return l.syntaxErrorf("bad number syntax: %q", l.input[l.start:l.pos])
 		}
 		l.emit(TokenNumber)
 	} else {
@@ -596,14 +1263,35 @@ func (l *Lexer) scanNumber() bool {
 
 // lexIdentifier scans an ID
 func lexIdentifier(l *Lexer) lexFunc {
-	str := l.findRegexp(rID)
+	i := strings.IndexAny(l.input[l.pos:], unallowedIDChars)
+	for (i == -1) && l.more() {
+		i = strings.IndexAny(l.input[l.pos:], unallowedIDChars)
+	}
+
+	str := l.input[l.pos:]
+	if i != -1 {
+		str = str[:i]
+	}
+
+	// custom close delimiter may be made of characters allowed in an identifier
+	if i := strings.Index(str, l.delims.close); i != -1 {
+		str = str[:i]
+	}
+
 	if len(str) == 0 {
 		// this is rotten
-		panic("Identifier expected")
+		return l.errorf("Identifier expected")
 	}
 
 	l.pos += len(str)
-	l.emit(TokenID)
+
+	// eg. if in {{else if foo}}, but not foo in {{else foo.bar}}
+	kind := TokenID
+	if l.chain && !strings.HasPrefix(l.input[l.pos:], ".") && !strings.HasPrefix(l.input[l.pos:], "/") {
+		kind = TokenChainHelper
+	}
+
+	l.emit(kind)
 
 	return lexExpression
 }
@@ -613,7 +1301,7 @@ func lexPathLiteral(l *Lexer) lexFunc {
 	for {
 		r := l.next()
 		if r == eof || r == '\n' {
-			return l.errorf("Unterminated path literal")
+			return l.syntaxErrorf("Unterminated path literal")
 		}
 
 		if r == ']' {
@@ -637,3 +1325,23 @@ func isIgnorable(r rune) bool {
 func isAlphaNumeric(r rune) bool {
 	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
 }
+
+// isSpace returns true if r is a whitespace, as matched by \s in regular expressions
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\f' || r == '\r'
+}
+
+// isNotSpace returns true if r is not a whitespace
+func isNotSpace(r rune) bool {
+	return !isSpace(r)
+}
+
+// isIDLookahead returns true if given character may follow an identifier
+func isIDLookahead(c byte) bool {
+	return isSpace(rune(c)) || (strings.IndexByte(lookheadChars, c) >= 0)
+}
+
+// isLiteralLookahead returns true if given character may follow a literal
+func isLiteralLookahead(c byte) bool {
+	return isSpace(rune(c)) || (strings.IndexByte(literalLookheadChars, c) >= 0)
+}
@@ -1,8 +1,15 @@
 package lexer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 )
 
 type lexTest struct {
@@ -12,40 +19,43 @@ type lexTest struct {
 }
 
 // helpers
-func tokContent(val string) Token { return Token{TokenContent, val, 0, 1} }
-func tokID(val string) Token      { return Token{TokenID, val, 0, 1} }
-func tokSep(val string) Token     { return Token{TokenSep, val, 0, 1} }
-func tokString(val string) Token  { return Token{TokenString, val, 0, 1} }
-func tokNumber(val string) Token  { return Token{TokenNumber, val, 0, 1} }
-func tokInverse(val string) Token { return Token{TokenInverse, val, 0, 1} }
-func tokBool(val string) Token    { return Token{TokenBoolean, val, 0, 1} }
-func tokError(val string) Token   { return Token{TokenError, val, 0, 1} }
-func tokComment(val string) Token { return Token{TokenComment, val, 0, 1} }
-
-var tokEOF = Token{TokenEOF, "", 0, 1}
-var tokEquals = Token{TokenEquals, "=", 0, 1}
-var tokData = Token{TokenData, "@", 0, 1}
-var tokOpen = Token{TokenOpen, "{{", 0, 1}
-var tokOpenAmp = Token{TokenOpen, "{{&", 0, 1}
-var tokOpenPartial = Token{TokenOpenPartial, "{{>", 0, 1}
-var tokClose = Token{TokenClose, "}}", 0, 1}
-var tokOpenStrip = Token{TokenOpen, "{{~", 0, 1}
-var tokCloseStrip = Token{TokenClose, "~}}", 0, 1}
-var tokOpenUnescaped = Token{TokenOpenUnescaped, "{{{", 0, 1}
-var tokCloseUnescaped = Token{TokenCloseUnescaped, "}}}", 0, 1}
-var tokOpenUnescapedStrip = Token{TokenOpenUnescaped, "{{~{", 0, 1}
-var tokCloseUnescapedStrip = Token{TokenCloseUnescaped, "}~}}", 0, 1}
-var tokOpenBlock = Token{TokenOpenBlock, "{{#", 0, 1}
-var tokOpenEndBlock = Token{TokenOpenEndBlock, "{{/", 0, 1}
-var tokOpenInverse = Token{TokenOpenInverse, "{{^", 0, 1}
-var tokOpenInverseChain = Token{TokenOpenInverseChain, "{{else", 0, 1}
-var tokOpenSexpr = Token{TokenOpenSexpr, "(", 0, 1}
-var tokCloseSexpr = Token{TokenCloseSexpr, ")", 0, 1}
-var tokOpenBlockParams = Token{TokenOpenBlockParams, "as |", 0, 1}
-var tokCloseBlockParams = Token{TokenCloseBlockParams, "|", 0, 1}
-var tokOpenRawBlock = Token{TokenOpenRawBlock, "{{{{", 0, 1}
-var tokCloseRawBlock = Token{TokenCloseRawBlock, "}}}}", 0, 1}
-var tokOpenEndRawBlock = Token{TokenOpenEndRawBlock, "{{{{/", 0, 1}
+func tokContent(val string) Token { return Token{TokenContent, val, 0, 1, 0, 0, false, false, ""} }
+func tokID(val string) Token      { return Token{TokenID, val, 0, 1, 0, 0, false, false, ""} }
+func tokSep(val string) Token     { return Token{TokenSep, val, 0, 1, 0, 0, false, false, ""} }
+func tokString(val string) Token  { return Token{TokenString, val, 0, 1, 0, 0, false, false, ""} }
+func tokNumber(val string) Token  { return Token{TokenNumber, val, 0, 1, 0, 0, false, false, ""} }
+func tokInverse(val string) Token { return Token{TokenInverse, val, 0, 1, 0, 0, false, false, ""} }
+func tokBool(val string) Token    { return Token{TokenBoolean, val, 0, 1, 0, 0, false, false, ""} }
+func tokError(val string) Token   { return Token{TokenError, val, 0, 1, 0, 0, false, false, ""} }
+func tokComment(val string) Token { return Token{TokenComment, val, 0, 1, 0, 0, false, false, ""} }
+func tokChainHelper(val string) Token {
+	return Token{TokenChainHelper, val, 0, 1, 0, 0, false, false, ""}
+}
+
+var tokEOF = Token{TokenEOF, "", 0, 1, 0, 0, false, false, ""}
+var tokEquals = Token{TokenEquals, "=", 0, 1, 1, 1, false, false, ""}
+var tokData = Token{TokenData, "@", 0, 1, 1, 1, false, false, ""}
+var tokOpen = Token{TokenOpen, "{{", 0, 1, 2, 2, false, false, ""}
+var tokOpenAmp = Token{TokenOpen, "{{&", 0, 1, 3, 3, false, false, ""}
+var tokOpenPartial = Token{TokenOpenPartial, "{{>", 0, 1, 3, 3, false, false, ""}
+var tokClose = Token{TokenClose, "}}", 0, 1, 2, 2, false, false, ""}
+var tokOpenStrip = Token{TokenOpen, "{{~", 0, 1, 3, 3, true, false, ""}
+var tokCloseStrip = Token{TokenClose, "~}}", 0, 1, 3, 3, false, true, ""}
+var tokOpenUnescaped = Token{TokenOpenUnescaped, "{{{", 0, 1, 3, 3, false, false, ""}
+var tokCloseUnescaped = Token{TokenCloseUnescaped, "}}}", 0, 1, 3, 3, false, false, ""}
+var tokOpenUnescapedStrip = Token{TokenOpenUnescaped, "{{~{", 0, 1, 4, 4, true, false, ""}
+var tokCloseUnescapedStrip = Token{TokenCloseUnescaped, "}~}}", 0, 1, 4, 4, false, true, ""}
+var tokOpenBlock = Token{TokenOpenBlock, "{{#", 0, 1, 3, 3, false, false, ""}
+var tokOpenEndBlock = Token{TokenOpenEndBlock, "{{/", 0, 1, 3, 3, false, false, ""}
+var tokOpenInverse = Token{TokenOpenInverse, "{{^", 0, 1, 3, 3, false, false, ""}
+var tokOpenInverseChain = Token{TokenOpenInverseChain, "{{else", 0, 1, 6, 6, false, false, ""}
+var tokOpenSexpr = Token{TokenOpenSexpr, "(", 0, 1, 1, 1, false, false, ""}
+var tokCloseSexpr = Token{TokenCloseSexpr, ")", 0, 1, 1, 1, false, false, ""}
+var tokOpenBlockParams = Token{TokenOpenBlockParams, "as |", 0, 1, 4, 4, false, false, ""}
+var tokCloseBlockParams = Token{TokenCloseBlockParams, "|", 0, 1, 1, 1, false, false, ""}
+var tokOpenRawBlock = Token{TokenOpenRawBlock, "{{{{", 0, 1, 4, 4, false, false, ""}
+var tokCloseRawBlock = Token{TokenCloseRawBlock, "}}}}", 0, 1, 4, 4, false, false, ""}
+var tokOpenEndRawBlock = Token{TokenOpenEndRawBlock, "{{{{/", 0, 1, 5, 5, false, false, ""}
 
 var lexTests = []lexTest{
 	{"empty", "", []Token{tokEOF}},
@@ -157,6 +167,16 @@ var lexTests = []lexTest{
 		"{{foo}} \\\\{{{bar}}} {{baz}}",
 		[]Token{tokOpen, tokID("foo"), tokClose, tokContent(" \\"), tokOpenUnescaped, tokID("bar"), tokCloseUnescaped, tokContent(" "), tokOpen, tokID("baz"), tokClose, tokEOF},
 	},
+	{
+		`tokenizes content with single braces and escape characters`,
+		"a { b \\ c \\\\ d {x} é{{foo}}\\",
+		[]Token{tokContent("a { b \\ c \\\\ d {x} é"), tokOpen, tokID("foo"), tokClose, tokContent("\\"), tokEOF},
+	},
+	{
+		`supports escaped mustaches after several escape characters`,
+		"a \\\\\\{{foo}} {",
+		[]Token{tokContent("a \\\\"), tokOpen, tokID("foo"), tokClose, tokContent(" {"), tokEOF},
+	},
 	{
 		`tokenizes a simple path`,
 		`{{foo/bar}}`,
@@ -347,7 +367,31 @@ var lexTests = []lexTest{
 		`{{ foo false }}`,
 		[]Token{tokOpen, tokID("foo"), tokBool("false"), tokClose, tokEOF},
 	},
-	// SKIP: 'tokenizes undefined and null'
+	{
+		`tokenizes undefined and null`,
+		`{{ foo undefined null }}`,
+		[]Token{tokOpen, tokID("foo"), {TokenUndefined, "undefined", 0, 1, 0, 0, false, false, ""}, {TokenNull, "null", 0, 1, 0, 0, false, false, ""}, tokClose, tokEOF},
+	},
+	{
+		`tokenizes else if chain`,
+		`{{#if a}}x{{else if b}}y{{~ else unless c}}z{{/if}}`,
+		[]Token{
+			tokOpenBlock, tokID("if"), tokID("a"), tokClose, tokContent("x"),
+			tokOpenInverseChain, tokChainHelper("if"), tokID("b"), tokClose, tokContent("y"),
+			{TokenOpenInverseChain, "{{~ else", 0, 1, 0, 0, true, false, ""}, tokChainHelper("unless"), tokID("c"), tokClose, tokContent("z"),
+			tokOpenEndBlock, tokID("if"), tokClose, tokEOF,
+		},
+	},
+	{
+		`does not tokenizes path or params of else chain as chained helper`,
+		`{{else foo.bar baz}}{{else if}}`,
+		[]Token{tokOpenInverseChain, tokID("foo"), tokSep("."), tokID("bar"), tokID("baz"), tokClose, tokOpenInverseChain, tokChainHelper("if"), tokClose, tokEOF},
+	},
+	{
+		`does not tokenizes identifier starting with null, or hash key null, as null`,
+		`{{ foo nullbar null=1 null.baz }}`,
+		[]Token{tokOpen, tokID("foo"), tokID("nullbar"), tokID("null"), tokEquals, tokNumber("1"), tokID("null"), tokSep("."), tokID("baz"), tokClose, tokEOF},
+	},
 	{
 		`tokenizes hash arguments (1)`,
 		`{{ foo bar=baz }}`,
@@ -461,14 +505,34 @@ var lexTests = []lexTest{
 	{
 		`tokenizes block params (5)`,
 		`{{else foo as |bar baz|}}`,
-		[]Token{tokOpenInverseChain, tokID("foo"), tokOpenBlockParams, tokID("bar"), tokID("baz"), tokCloseBlockParams, tokClose, tokEOF},
+		[]Token{tokOpenInverseChain, tokChainHelper("foo"), tokOpenBlockParams, tokID("bar"), tokID("baz"), tokCloseBlockParams, tokClose, tokEOF},
+	},
+	{
+		`tokenizes block params (6)`,
+		"{{#foo as\t\n|bar|}}",
+		[]Token{tokOpenBlock, tokID("foo"), {TokenOpenBlockParams, "as\t\n|", 0, 1, 5, 5, false, false, ""}, tokID("bar"), tokCloseBlockParams, tokClose, tokEOF},
+	},
+	{
+		`does not tokenize block params without whitespaces`,
+		`{{#foo as|bar|}}`,
+		[]Token{tokOpenBlock, tokID("foo"), tokID("as"), tokCloseBlockParams, tokID("bar"), tokCloseBlockParams, tokClose, tokEOF},
+	},
+	{
+		`tokenizes booleans and dot before strip`,
+		`{{foo . true false~}}`,
+		[]Token{tokOpen, tokID("foo"), tokID("."), tokBool("true"), tokBool("false"), tokCloseStrip, tokEOF},
+	},
+	{
+		`does not tokenize boolean at end of input`,
+		`{{foo true`,
+		[]Token{tokOpen, tokID("foo"), tokID("true"), {TokenError, "Unclosed expression", 0, 1, 0, 0, false, false, ""}},
 	},
 }
 
 func collect(t *lexTest) []Token {
 	var result []Token
 
-	l := scanWithName(t.input, t.name)
+	l := scanWithName(t.input, t.name, Options{})
 	for {
 		token := l.NextToken()
 		result = append(result, token)
@@ -498,6 +562,10 @@ func equal(i1, i2 []Token, checkPos bool) bool {
 		if i1[k].Val != i2[k].Val {
 			return false
 		}
+
+		if (i1[k].StripBefore != i2[k].StripBefore) || (i1[k].StripAfter != i2[k].StripAfter) {
+			return false
+		}
 	}
 
 	return true
@@ -514,6 +582,951 @@ func TestLexer(t *testing.T) {
 	}
 }
 
+var maxContentSizeTests = []struct {
+	name     string
+	input    string
+	size     int
+	expected []string
+}{
+	{"no limit", "foo\nbar\nbaz", 0, []string{"foo\nbar\nbaz"}},
+	{"small content", "foo\nbar", 16, []string{"foo\nbar"}},
+	{"cut after newline", "foo\nbar\nbaz", 9, []string{"foo\nbar\n", "baz"}},
+	{"cut long lines", "foobarbaz", 4, []string{"foob", "arba", "z"}},
+	{"cut on rune boundary", "ééé!", 3, []string{"é", "é", "é!"}},
+	{"keep leading whitespaces", "\n  \n foo\nbar", 2, []string{"\n  \n f", "oo", "\nb", "ar"}},
+	{"keep trailing whitespaces", "foo\nbar \n  ", 4, []string{"foo\n", "bar \n  "}},
+	{"only whitespaces", " \n \n \n ", 2, []string{" \n \n \n "}},
+}
+
+func TestMaxContentSize(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range maxContentSizeTests {
+		var contents []string
+
+		l := ScanWithOptions(test.input+"{{foo}}"+test.input, Options{MaxContentSize: test.size})
+		for {
+			token := l.NextToken()
+			if token.Kind == TokenEOF || token.Kind == TokenError {
+				break
+			}
+
+			if token.Kind == TokenContent {
+				contents = append(contents, token.Val)
+			}
+		}
+
+		expected := append(append([]string{}, test.expected...), test.expected...)
+		if !reflect.DeepEqual(contents, expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%q\ngot\n\t%q", test.name, expected, contents)
+		}
+	}
+}
+
+func TestMaxContentSizeLines(t *testing.T) {
+	t.Parallel()
+
+	tokens := make([]Token, 0)
+
+	l := ScanWithOptions("a\nb\nc\nd{{foo}}", Options{MaxContentSize: 3})
+	for token := l.NextToken(); token.Kind != TokenEOF; token = l.NextToken() {
+		tokens = append(tokens, token)
+	}
+
+	expected := []Token{
+		{TokenContent, "a\n", 0, 1, 2, 2, false, false, ""},
+		{TokenContent, "b\n", 2, 2, 4, 2, false, false, ""},
+		{TokenContent, "c\nd", 4, 3, 7, 3, false, false, ""},
+		{TokenOpen, "{{", 7, 4, 9, 2, false, false, ""},
+		{TokenID, "foo", 9, 4, 12, 3, false, false, ""},
+		{TokenClose, "}}", 12, 4, 14, 2, false, false, ""},
+	}
+
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Unexpected tokens\nexpected\n\t%v\ngot\n\t%v", expected, tokens)
+	}
+}
+
+var invalidUTF8Tests = []struct {
+	name     string
+	policy   UTF8Policy
+	expected []Token
+}{
+	{"pass through", UTF8PassThrough, []Token{
+		{TokenContent, "a\xffb\n", 0, 1, 4, 4, false, false, ""},
+		{TokenOpen, "{{", 4, 2, 6, 2, false, false, ""},
+		{TokenID, "foo", 6, 2, 9, 3, false, false, ""},
+		{TokenClose, "}}", 9, 2, 11, 2, false, false, ""},
+		{TokenContent, "\xc3", 11, 2, 12, 1, false, false, ""},
+		{TokenEOF, "", 12, 2, 12, 0, false, false, ""},
+	}},
+	{"replace", UTF8Replace, []Token{
+		{TokenContent, "a\uFFFDb\n", 0, 1, 6, 6, false, false, ""},
+		{TokenOpen, "{{", 6, 2, 8, 2, false, false, ""},
+		{TokenID, "foo", 8, 2, 11, 3, false, false, ""},
+		{TokenClose, "}}", 11, 2, 13, 2, false, false, ""},
+		{TokenContent, "\uFFFD", 13, 2, 16, 3, false, false, ""},
+		{TokenEOF, "", 16, 2, 16, 0, false, false, ""},
+	}},
+	{"error", UTF8Error, []Token{
+		{TokenError, "Invalid UTF-8 sequence at byte 1", 1, 1, 1, 0, false, false, ""},
+	}},
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range invalidUTF8Tests {
+		var tokens []Token
+
+		l := ScanWithOptions("a\xffb\n{{foo}}\xc3", Options{InvalidUTF8: test.policy})
+		for {
+			token := l.NextToken()
+			tokens = append(tokens, token)
+
+			if token.Kind == TokenEOF || token.Kind == TokenError {
+				break
+			}
+		}
+
+		if !reflect.DeepEqual(tokens, test.expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, test.expected, tokens)
+		}
+	}
+
+	// valid replacement rune
+	l := ScanWithOptions("\uFFFD", Options{InvalidUTF8: UTF8Error})
+	if token := l.NextToken(); token.Kind != TokenContent {
+		t.Errorf("Unexpected token for valid replacement rune: %v", token)
+	}
+}
+
+var delimitersTests = []struct {
+	name     string
+	input    string
+	open     string
+	close    string
+	expected []Token
+}{
+	{
+		"mustache", "a <% foo %> b", "<%", "%>",
+		[]Token{tokContent("a "), {TokenOpen, "<%", 0, 1, 0, 0, false, false, ""}, tokID("foo"), {TokenClose, "%>", 0, 1, 0, 0, false, false, ""}, tokContent(" b"), tokEOF},
+	},
+	{
+		"block", "<%# if . %>x<%else%>y<%/if%>", "<%", "%>",
+		[]Token{
+			{TokenOpenBlock, "<%#", 0, 1, 0, 0, false, false, ""}, tokID("if"), tokID("."), {TokenClose, "%>", 0, 1, 0, 0, false, false, ""},
+			tokContent("x"), tokInverse("<%else%>"), tokContent("y"),
+			{TokenOpenEndBlock, "<%/", 0, 1, 0, 0, false, false, ""}, tokID("if"), {TokenClose, "%>", 0, 1, 0, 0, false, false, ""}, tokEOF,
+		},
+	},
+	{
+		"unescaped and strip", "<%{foo}%><%&bar true~%>", "<%", "%>",
+		[]Token{
+			{TokenOpenUnescaped, "<%{", 0, 1, 0, 0, false, false, ""}, tokID("foo"), {TokenCloseUnescaped, "}%>", 0, 1, 0, 0, false, false, ""},
+			{TokenOpen, "<%&", 0, 1, 0, 0, false, false, ""}, tokID("bar"), tokBool("true"), {TokenClose, "~%>", 0, 1, 0, 0, false, true, ""}, tokEOF,
+		},
+	},
+	{
+		"comments", "<%! note %><%!-- <% x %> --%>", "<%", "%>",
+		[]Token{tokComment("<%! note %>"), tokComment("<%!-- <% x %> --%>"), tokEOF},
+	},
+	{
+		"raw block", "<%<%raw%>%>{{x}}<%<%/raw%>%>", "<%", "%>",
+		[]Token{
+			{TokenOpenRawBlock, "<%<%", 0, 1, 0, 0, false, false, ""}, tokID("raw"), {TokenCloseRawBlock, "%>%>", 0, 1, 0, 0, false, false, ""},
+			tokContent("{{x}}"),
+			{TokenOpenEndRawBlock, "<%<%/", 0, 1, 0, 0, false, false, ""}, tokID("raw"), {TokenCloseRawBlock, "%>%>", 0, 1, 0, 0, false, false, ""}, tokEOF,
+		},
+	},
+	{
+		"escaped", "\\<%foo%> {{bar}}", "<%", "%>",
+		[]Token{tokContent("<%foo%> {{bar}}"), tokEOF},
+	},
+	{
+		"close made of identifier characters", "$$foo 1$$ $$.$$", "$$", "$$",
+		[]Token{
+			{TokenOpen, "$$", 0, 1, 0, 0, false, false, ""}, tokID("foo"), tokNumber("1"), {TokenClose, "$$", 0, 1, 0, 0, false, false, ""}, tokContent(" "),
+			{TokenOpen, "$$", 0, 1, 0, 0, false, false, ""}, tokID("."), {TokenClose, "$$", 0, 1, 0, 0, false, false, ""}, tokEOF,
+		},
+	},
+	{
+		"unescaped with brace close", "${{foo}} ${bar} ${${raw}}x${${/raw}}", "${", "}",
+		[]Token{
+			{TokenOpenUnescaped, "${{", 0, 1, 0, 0, false, false, ""}, tokID("foo"), {TokenCloseUnescaped, "}}", 0, 1, 0, 0, false, false, ""}, tokContent(" "),
+			{TokenOpen, "${", 0, 1, 0, 0, false, false, ""}, tokID("bar"), {TokenClose, "}", 0, 1, 0, 0, false, false, ""}, tokContent(" "),
+			{TokenOpenRawBlock, "${${", 0, 1, 0, 0, false, false, ""}, tokID("raw"), {TokenCloseRawBlock, "}}", 0, 1, 0, 0, false, false, ""},
+			tokContent("x"),
+			{TokenOpenEndRawBlock, "${${/", 0, 1, 0, 0, false, false, ""}, tokID("raw"), {TokenCloseRawBlock, "}}", 0, 1, 0, 0, false, false, ""}, tokEOF,
+		},
+	},
+	{
+		"unescaped and strip with brace delimiters", "{%~{ foo }~%} {%{bar}%}", "{%", "%}",
+		[]Token{
+			{TokenOpenUnescaped, "{%~{", 0, 1, 0, 0, true, false, ""}, tokID("foo"), {TokenCloseUnescaped, "}~%}", 0, 1, 0, 0, false, true, ""}, tokContent(" "),
+			{TokenOpenUnescaped, "{%{", 0, 1, 0, 0, false, false, ""}, tokID("bar"), {TokenCloseUnescaped, "}%}", 0, 1, 0, 0, false, false, ""}, tokEOF,
+		},
+	},
+	{
+		"open brace", "{foo}", "{", "}",
+		[]Token{tokError(`Invalid delimiters "{" and "}": open delimiter can't be {`)},
+	},
+	{
+		"missing close", "{{foo}}", "<%", "",
+		[]Token{tokError(`Invalid delimiters "<%" and "": both must be set`)},
+	},
+	{
+		"whitespaces", "{{foo}}", "<% ", "%>",
+		[]Token{tokError(`Invalid delimiters "<% " and "%>": whitespaces are not allowed`)},
+	},
+}
+
+func TestDelimiters(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range delimitersTests {
+		opts := Options{OpenDelim: test.open, CloseDelim: test.close}
+
+		expected := collectAll(ScanWithOptions(test.input, opts))
+		if !equal(expected, test.expected, false) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, test.expected, expected)
+		}
+
+		if tokens := collectAll(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(test.input)), opts)); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Test '%s' failed with reader\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+	}
+}
+
+func TestDelimitersCache(t *testing.T) {
+	t.Parallel()
+
+	erb := Options{OpenDelim: "<%", CloseDelim: "%>"}
+	brackets := Options{OpenDelim: "[[", CloseDelim: "]]"}
+
+	l := getLexer("", nil, erb)
+	delims := l.delims
+	l.Release()
+
+	// compiled patterns are reused when delimiters change between scans
+	l = getLexer("", nil, brackets)
+	l.reset("", nil, erb)
+
+	if l.delims != delims {
+		t.Errorf("Custom delimiters compiled again")
+	}
+	l.Release()
+}
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected []Token
+		reader   []Token
+	}{
+		{
+			"input size", "{{foo}}", Options{MaxInputBytes: 7},
+			[]Token{
+				{TokenOpen, "{{", 0, 1, 2, 2, false, false, ""},
+				{TokenID, "foo", 2, 1, 5, 3, false, false, ""},
+				{TokenClose, "}}", 5, 1, 7, 2, false, false, ""},
+				{TokenEOF, "", 7, 1, 7, 0, false, false, ""},
+			},
+			nil,
+		},
+		{
+			"input too large", "{{foo}}", Options{MaxInputBytes: 5},
+			[]Token{{TokenError, "Input is larger than 5 bytes", 0, 1, 0, 0, false, false, ""}},
+			[]Token{
+				{TokenOpen, "{{", 0, 1, 2, 2, false, false, ""},
+				{TokenID, "foo", 2, 1, 5, 3, false, false, ""},
+				{TokenError, "Input is larger than 5 bytes", 5, 1, 5, 0, false, false, ""},
+			},
+		},
+		{
+			"tokens", "{{foo}}", Options{MaxTokens: 3},
+			[]Token{
+				{TokenOpen, "{{", 0, 1, 2, 2, false, false, ""},
+				{TokenID, "foo", 2, 1, 5, 3, false, false, ""},
+				{TokenClose, "}}", 5, 1, 7, 2, false, false, ""},
+				{TokenEOF, "", 7, 1, 7, 0, false, false, ""},
+			},
+			nil,
+		},
+		{
+			"too many tokens", "a\n{{foo}}b", Options{MaxTokens: 3},
+			[]Token{
+				{TokenContent, "a\n", 0, 1, 2, 2, false, false, ""},
+				{TokenOpen, "{{", 2, 2, 4, 2, false, false, ""},
+				{TokenID, "foo", 4, 2, 7, 3, false, false, ""},
+				{TokenError, "More than 3 tokens", 7, 2, 7, 0, false, false, ""},
+			},
+			nil,
+		},
+		{
+			"too many content chunks", "abcdef{{foo}}", Options{MaxTokens: 2, MaxContentSize: 2},
+			[]Token{
+				{TokenContent, "ab", 0, 1, 2, 2, false, false, ""},
+				{TokenContent, "cd", 2, 1, 4, 2, false, false, ""},
+				{TokenError, "More than 2 tokens", 4, 1, 4, 0, false, false, ""},
+			},
+			nil,
+		},
+		{
+			"subexpression depth", "{{a (b) (b (c))}}", Options{MaxSubexpressionDepth: 1},
+			[]Token{
+				{TokenOpen, "{{", 0, 1, 2, 2, false, false, ""},
+				{TokenID, "a", 2, 1, 3, 1, false, false, ""},
+				{TokenOpenSexpr, "(", 4, 1, 5, 1, false, false, ""},
+				{TokenID, "b", 5, 1, 6, 1, false, false, ""},
+				{TokenCloseSexpr, ")", 6, 1, 7, 1, false, false, ""},
+				{TokenOpenSexpr, "(", 8, 1, 9, 1, false, false, ""},
+				{TokenID, "b", 9, 1, 10, 1, false, false, ""},
+				{TokenError, "More than 1 nested subexpressions", 11, 1, 11, 0, false, false, ""},
+			},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		if tokens := collectAll(ScanWithOptions(test.input, test.opts)); !reflect.DeepEqual(tokens, test.expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, test.expected, tokens)
+		}
+
+		expected := test.reader
+		if expected == nil {
+			expected = test.expected
+		}
+
+		if tokens := collectAll(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(test.input)), test.opts)); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Test '%s' failed with reader\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+	}
+
+	// default depth is generous, and can be lifted
+	nested := "{{a " + strings.Repeat("(b ", DefaultMaxSubexpressionDepth) + strings.Repeat(")", DefaultMaxSubexpressionDepth) + "}}"
+	if tokens := Collect(nested); tokens[len(tokens)-1].Kind != TokenEOF {
+		t.Errorf("Unexpected error with %d nested subexpressions: %v", DefaultMaxSubexpressionDepth, tokens[len(tokens)-1])
+	}
+
+	nested = "{{a (" + nested[len("{{a "):]
+	if tokens := Collect(nested); tokens[len(tokens)-1].Val != fmt.Sprintf("More than %d nested subexpressions", DefaultMaxSubexpressionDepth) {
+		t.Errorf("Unexpected last token with too many nested subexpressions: %v", tokens[len(tokens)-1])
+	}
+
+	if tokens := collectAll(ScanWithOptions(nested, Options{MaxSubexpressionDepth: -1})); tokens[len(tokens)-1].Kind != TokenEOF {
+		t.Errorf("Unexpected error without subexpression depth limit: %v", tokens[len(tokens)-1])
+	}
+}
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []Token
+	}{
+		{
+			"unexpected character", "{{foo ;}} bar",
+			[]Token{tokOpen, tokID("foo"), tokError("Unexpected character in expression: ';'"), tokClose, tokContent(" bar"), tokEOF},
+		},
+		{
+			"unterminated string", "{{foo \"bar\nbaz}}",
+			[]Token{tokOpen, tokID("foo"), tokError("Unterminated string"), tokContent("\nbaz}}"), tokEOF},
+		},
+		{
+			"bad number", "{{foo 1x}}{{bar}}",
+			[]Token{tokOpen, tokID("foo"), tokError(`bad number syntax: "1x"`), tokClose, tokOpen, tokID("bar"), tokClose, tokEOF},
+		},
+		{
+			"unterminated path literal", "{{foo [bar~}}",
+			[]Token{tokOpen, tokID("foo"), tokError("Unterminated path literal"), tokCloseStrip, tokEOF},
+		},
+		{
+			"several errors", "{{#foo ;}}\n{{/foo ;}}",
+			[]Token{
+				tokOpenBlock, tokID("foo"), tokError("Unexpected character in expression: ';'"), tokClose, tokContent("\n"),
+				tokOpenEndBlock, tokID("foo"), tokError("Unexpected character in expression: ';'"), tokClose, tokEOF,
+			},
+		},
+		{
+			"unclosed comment", "{{foo ;}}{{! bar",
+			[]Token{tokOpen, tokID("foo"), tokError("Unexpected character in expression: ';'"), tokClose, tokError("Unclosed comment")},
+		},
+	}
+
+	for _, test := range tests {
+		expected := collectRecovered(ScanWithOptions(test.input, Options{Recover: true}))
+		if !equal(expected, test.expected, false) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, test.expected, expected)
+		}
+
+		if tokens := collectRecovered(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(test.input)), Options{Recover: true})); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Test '%s' failed with reader\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+	}
+
+	// scanning stops at first error by default
+	expected := []Token{tokOpen, tokID("foo"), tokError("Unexpected character in expression: ';'")}
+	if tokens := collectRecovered(Scan("{{foo ;}} bar")); !equal(tokens, expected, false) {
+		t.Errorf("Unexpected tokens without recovery: %v", tokens)
+	}
+}
+
+func TestStripMarkers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []Token
+	}{
+		{
+			"{{~#foo}} {{^~}} {{~else}} {{! c ~}}{{~/foo~}}", Options{},
+			[]Token{
+				{TokenOpenBlock, "{{~#", 0, 1, 0, 0, true, false, ""}, tokID("foo"), tokClose, tokContent(" "),
+				{TokenInverse, "{{^~}}", 0, 1, 0, 0, false, true, ""}, tokContent(" "),
+				{TokenInverse, "{{~else}}", 0, 1, 0, 0, true, false, ""}, tokContent(" "),
+				{TokenComment, "{{! c ~}}", 0, 1, 0, 0, false, true, ""},
+				{TokenOpenEndBlock, "{{~/", 0, 1, 0, 0, true, false, ""}, tokID("foo"), tokCloseStrip, tokEOF,
+			},
+		},
+		{
+			"<%~{foo}~%><%~!-- c --%>", Options{OpenDelim: "<%", CloseDelim: "%>"},
+			[]Token{
+				{TokenOpenUnescaped, "<%~{", 0, 1, 0, 0, true, false, ""}, tokID("foo"), {TokenCloseUnescaped, "}~%>", 0, 1, 0, 0, false, true, ""},
+				{TokenComment, "<%~!-- c --%>", 0, 1, 0, 0, true, false, ""}, tokEOF,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if tokens := collectAll(ScanWithOptions(test.input, test.opts)); !equal(tokens, test.expected, false) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%+v\ngot\n\t%+v", test.input, test.expected, tokens)
+		}
+	}
+}
+
+func TestCommentText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		opts    Options
+		text    string
+		trimmed string
+	}{
+		{"{{! foo }}", Options{}, " foo ", "foo"},
+		{"{{!-- foo }} bar --}}", Options{}, " foo }} bar ", "foo }} bar"},
+		{"{{~!--\n foo\n--~}}", Options{}, "\n foo\n", "foo"},
+		{"{{!-foo-}}", Options{}, "foo", "foo"},
+		{"{{!}}", Options{}, "", ""},
+		{"<%!-- foo --%>", Options{OpenDelim: "<%", CloseDelim: "%>"}, " foo ", "foo"},
+	}
+
+	for _, test := range tests {
+		tok := ScanWithOptions(test.input, test.opts).NextToken()
+		if (tok.Kind != TokenComment) || (tok.Text != test.text) || (tok.TrimmedText() != test.trimmed) {
+			t.Errorf("Unexpected comment token for %q: %v %q %q", test.input, tok, tok.Text, tok.TrimmedText())
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []Token
+	}{
+		{
+			`{{foo "a\nb\t\\\"\'é\x41" 'c\'d\"'}}`, Options{StringEscapes: true},
+			[]Token{tokOpen, tokID("foo"), tokString("a\nb\t\\\"'éA"), tokString(`c'd"`), tokClose, tokEOF},
+		},
+		{
+			`{{foo "a\\" "b"}}`, Options{StringEscapes: true},
+			[]Token{tokOpen, tokID("foo"), tokString(`a\`), tokString("b"), tokClose, tokEOF},
+		},
+		{
+			`{{foo "a\nb\"" 'c\'d\"'}}`, Options{},
+			[]Token{tokOpen, tokID("foo"), tokString(`a\nb"`), tokString(`c'd\"`), tokClose, tokEOF},
+		},
+		{
+			`{{foo "a\q"}}`, Options{StringEscapes: true},
+			[]Token{tokOpen, tokID("foo"), tokError(`Invalid escape sequence in string: "\\q"`)},
+		},
+		{
+			`{{foo "\u00g9"}}`, Options{StringEscapes: true},
+			[]Token{tokOpen, tokID("foo"), tokError(`Invalid escape sequence in string: "\\u00g9"`)},
+		},
+		{
+			`{{foo "\x4"}}`, Options{StringEscapes: true},
+			[]Token{tokOpen, tokID("foo"), tokError(`Invalid escape sequence in string: "\\x4"`)},
+		},
+	}
+
+	for _, test := range tests {
+		if tokens := collectAll(ScanWithOptions(test.input, test.opts)); !equal(tokens, test.expected, false) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.input, test.expected, tokens)
+		}
+	}
+
+	// lines are counted in input, and string range is its raw text
+	input := `{{foo "a\nb"}}` + "\n{{bar}}"
+
+	tokens := collectAll(ScanWithOptions(input, Options{StringEscapes: true}))
+	if (len(tokens) != 9) || (input[tokens[2].Pos:tokens[2].End] != `a\nb`) || (tokens[6].Line != 2) {
+		t.Errorf("Unexpected tokens: %v", tokens)
+	}
+}
+
+func TestPreserveEscapes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		opts     Options
+		expected []Token
+	}{
+		{"\\{{bar}}", Options{PreserveEscapes: true}, []Token{tokContent("\\{{bar}}"), tokEOF}},
+		{
+			"a \\\\{{b}} \\{{c}}", Options{PreserveEscapes: true},
+			[]Token{tokContent("a \\\\"), tokOpen, tokID("b"), tokClose, tokContent(" "), tokContent("\\{{c}}"), tokEOF},
+		},
+		{
+			"a \\\\\\{{b}} {", Options{PreserveEscapes: true},
+			[]Token{tokContent("a \\\\\\"), tokOpen, tokID("b"), tokClose, tokContent(" {"), tokEOF},
+		},
+		{
+			"\\<%a%> <%b%>", Options{PreserveEscapes: true, OpenDelim: "<%", CloseDelim: "%>"},
+			[]Token{tokContent("\\<%a%> "), {TokenOpen, "<%", 0, 1, 0, 0, false, false, ""}, tokID("b"), {TokenClose, "%>", 0, 1, 0, 0, false, false, ""}, tokEOF},
+		},
+	}
+
+	for _, test := range tests {
+		tokens := collectAll(ScanWithOptions(test.input, test.opts))
+		if !equal(tokens, test.expected, false) {
+			t.Errorf("Test %q failed\nexpected\n\t%v\ngot\n\t%v", test.input, test.expected, tokens)
+		}
+
+		// content holds its source verbatim
+		for _, token := range tokens {
+			if (token.Kind == TokenContent) && (test.input[token.Pos:token.End] != token.Val) {
+				t.Errorf("Unexpected source of %v in %q: %q", token, test.input, test.input[token.Pos:token.End])
+			}
+		}
+
+		if reader := collectAll(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(test.input)), test.opts)); !reflect.DeepEqual(reader, tokens) {
+			t.Errorf("Test %q failed with reader\nexpected\n\t%v\ngot\n\t%v", test.input, tokens, reader)
+		}
+	}
+}
+
+func TestNextTokenAfterEnd(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"foo {{bar}}", "foo {{bar"} {
+		l := Scan(input)
+
+		tokens := collectAll(l)
+		last := tokens[len(tokens)-1]
+
+		for i := 0; i < 2; i++ {
+			if token := l.NextToken(); token != last {
+				t.Errorf("Unexpected token after end of %q: %v", input, token)
+			}
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	t.Parallel()
+
+	input := "a\n{{foo}}\r\n\t\u00e9 {{#bar}}\n\n{{/bar}}"
+
+	// line and column of byte offsets
+	expected := map[int][2]int{-1: {1, 1}, 0: {1, 1}, 1: {1, 2}, 2: {2, 1}, 11: {3, 1}, 14: {3, 4}, 15: {3, 5}, 24: {4, 1}, 25: {5, 1}, 32: {5, 8}, 100: {5, 9}}
+
+	for name, l := range map[string]*Lexer{
+		"string": Scan(input),
+		"reader": ScanReader(iotest.OneByteReader(strings.NewReader(input))),
+	} {
+		// tokens are scanned first, as a reader lexer only knows positions of input read so far
+		for _, token := range collectAll(l) {
+			if line, _ := l.Position(token.Pos); line != token.Line {
+				t.Errorf("Unexpected line of %v with %s lexer: %d, expected %d", token, name, line, token.Line)
+			}
+		}
+
+		for offset, pos := range expected {
+			if line, col := l.Position(offset); (line != pos[0]) || (col != pos[1]) {
+				t.Errorf("Unexpected position of offset %d with %s lexer: %d:%d, expected %d:%d", offset, name, line, col, pos[0], pos[1])
+			}
+		}
+	}
+
+	// index is built again for new input
+	l := Scan("a\nb")
+	l.Position(3)
+	l.Reset("abc")
+
+	if line, col := l.Position(2); (line != 1) || (col != 3) {
+		t.Errorf("Unexpected position after reset: %d:%d", line, col)
+	}
+}
+
+func TestScanContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l := ScanContext(ctx, "a {{b}} c")
+	if token := l.NextToken(); token.Kind != TokenContent {
+		t.Errorf("Unexpected token before cancel: %v", token)
+	}
+
+	cancel()
+
+	expected := Token{TokenError, "Scanning canceled: context canceled", 2, 1, 2, 0, false, false, ""}
+	for i := 0; i < 2; i++ {
+		if token := l.NextToken(); token != expected {
+			t.Errorf("Unexpected token after cancel: %v", token)
+		}
+	}
+
+	// context is kept on reset
+	l.Reset("{{d}}")
+	if tokens := collectAll(l); (len(tokens) != 1) || (tokens[0].Kind != TokenError) {
+		t.Errorf("Unexpected tokens after reset of canceled lexer: %v", tokens)
+	}
+
+	l.Release()
+
+	// a context that is never done does not change tokens
+	if tokens := collectAll(ScanContext(context.Background(), "a {{b}} c")); !reflect.DeepEqual(tokens, Collect("a {{b}} c")) {
+		t.Errorf("Unexpected tokens with background context: %v", tokens)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range lexTests {
+		expected := Collect(test.input)
+
+		tokens, err := Tokenize(test.input)
+		if last := expected[len(expected)-1]; last.Kind == TokenError {
+			expectedErr := &Error{Line: last.Line, Pos: last.Pos, Message: last.Val}
+			if !reflect.DeepEqual(err, expectedErr) {
+				t.Errorf("Unexpected error for '%s': %v", test.name, err)
+			}
+
+			expected = expected[:len(expected)-1]
+		} else if err != nil {
+			t.Errorf("Unexpected error for '%s': %v", test.name, err)
+		}
+
+		if !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Unexpected tokens for '%s'\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+	}
+
+	var lexErr *Error
+	if _, err := Tokenize("foo\n{{bar"); !errors.As(err, &lexErr) || (err.Error() != "Lexer error on line 2: Unclosed expression") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+
+	l := ScanWithOptions("a\xff {{b}}", Options{InvalidUTF8: UTF8Replace})
+	l.NextToken()
+
+	// options are kept, and tokens not fetched yet are discarded
+	l.Reset("{{c}}\xff")
+
+	expected := collectAll(ScanWithOptions("{{c}}\xff", Options{InvalidUTF8: UTF8Replace}))
+	if tokens := collectAll(l); !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Unexpected tokens after reset\nexpected\n\t%v\ngot\n\t%v", expected, tokens)
+	}
+
+	l.Release()
+
+	for i := 0; i < 3; i++ {
+		l := ScanReader(strings.NewReader("x {{y}}"))
+		if tokens := collectAll(l); !reflect.DeepEqual(tokens, Collect("x {{y}}")) {
+			t.Errorf("Unexpected tokens with released lexer: %v", tokens)
+		}
+
+		l.Release()
+	}
+}
+
+// set when running with the race detector
+var raceEnabled bool
+
+func TestScanAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not checked with the race detector")
+	}
+
+	// patterns are compiled once, and token values are substrings of input
+	input := "<p>{{#each items as |item|}}{{item.name}} {{{raw}}} {{! comment }}{{/each}}</p>\n{{> foo bar=\"baz\" n=1}}"
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l := Scan(input)
+		for token := l.NextToken(); token.Kind != TokenEOF; token = l.NextToken() {
+		}
+
+		l.Release()
+	})
+
+	if allocs != 0 {
+		t.Errorf("Scanning allocated %v times", allocs)
+	}
+}
+
+func TestTokenRange(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range lexTests {
+		for _, token := range collectAll(Scan(test.input)) {
+			src := test.input[token.Pos:token.End]
+
+			switch token.Kind {
+			case TokenError, TokenEOF:
+				src, token.Val = "", ""
+			case TokenString:
+				delim := test.input[token.Pos-1 : token.Pos]
+				src = strings.Replace(src, `\`+delim, delim, -1)
+			}
+
+			if (src != token.Val) || (token.Len != token.End-token.Pos) {
+				t.Errorf("Test '%s' failed: unexpected range %d-%d (%d) for %v", test.name, token.Pos, token.End, token.Len, token)
+			}
+		}
+	}
+}
+
+func TestLexerFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		fn       lexFunc
+		expected string
+	}{
+		{"foo", lexOpenMustache, "Opening mustache expected"},
+		{"foo", lexCloseMustache, "Closing mustache expected"},
+		{"}}", lexIdentifier, "Identifier expected"},
+		{"foo", func(l *Lexer) lexFunc { panic("boom") }, "Lexer failure: boom"},
+	}
+
+	for _, test := range tests {
+		l := Scan(test.input)
+		l.nextFunc = test.fn
+
+		if token := l.NextToken(); (token.Kind != TokenError) || (token.Val != test.expected) {
+			t.Errorf("Unexpected token for %q: %v", test.expected, token)
+		}
+
+		if token := l.NextToken(); token.Kind != TokenError {
+			t.Errorf("Unexpected token after failure: %v", token)
+		}
+	}
+}
+
+func FuzzScan(f *testing.F) {
+	for _, test := range lexTests {
+		f.Add(test.input)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens := collectAll(Scan(input))
+
+		pos := 0
+		for _, token := range tokens {
+			if (token.Pos < pos) || (token.End < token.Pos) || (token.End > len(input)) {
+				t.Fatalf("Unexpected token range %d-%d: %v", token.Pos, token.End, token)
+			}
+
+			pos = token.Pos
+		}
+
+		if tokens := collectAll(ScanReader(iotest.OneByteReader(strings.NewReader(input)))); !reflect.DeepEqual(tokens, collectAll(Scan(input))) {
+			t.Fatalf("Unexpected tokens with reader: %v", tokens)
+		}
+
+		opts := Options{Recover: true}
+		if tokens := collectRecovered(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(input)), opts)); !reflect.DeepEqual(tokens, collectRecovered(ScanWithOptions(input, opts))) {
+			t.Fatalf("Unexpected recovered tokens with reader: %v", tokens)
+		}
+	})
+}
+
+// collectRecovered returns all tokens of given lexer, scanned with the Recover option
+func collectRecovered(l *Lexer) []Token {
+	var result []Token
+
+	for {
+		token := l.NextToken()
+		if (len(result) > 0) && (token == result[len(result)-1]) {
+			// last token is returned again
+			return result
+		}
+
+		result = append(result, token)
+
+		if token.Kind == TokenEOF {
+			return result
+		}
+	}
+}
+
+// collectAll returns all tokens of given lexer
+func collectAll(l *Lexer) []Token {
+	var result []Token
+
+	for {
+		token := l.NextToken()
+		result = append(result, token)
+
+		if token.Kind == TokenEOF || token.Kind == TokenError {
+			return result
+		}
+	}
+}
+
+func TestScanReader(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range lexTests {
+		expected := collectAll(Scan(test.input))
+
+		if tokens := collectAll(ScanReader(strings.NewReader(test.input))); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+
+		// read input byte by byte, so that all tokens are split across reads
+		if tokens := collectAll(ScanReader(iotest.OneByteReader(strings.NewReader(test.input)))); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Test '%s' failed with one byte reads\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+	}
+}
+
+func TestScanReaderOptions(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range maxContentSizeTests {
+		input := test.input + "{{foo}}\\\\{{bar}}" + test.input
+		opts := Options{MaxContentSize: test.size}
+
+		expected := collectAll(ScanWithOptions(input, opts))
+		if tokens := collectAll(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(input)), opts)); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, expected, tokens)
+		}
+	}
+
+	// runs of invalid bytes are replaced once, even when split across reads
+	input := "a\xff\xfe\xc3b\n{{foo}}é\xc3"
+	opts := Options{InvalidUTF8: UTF8Replace}
+
+	expected := collectAll(ScanWithOptions(input, opts))
+	if tokens := collectAll(ScanReaderWithOptions(iotest.OneByteReader(strings.NewReader(input)), opts)); !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Unexpected replaced tokens\nexpected\n\t%v\ngot\n\t%v", expected, tokens)
+	}
+}
+
+func TestScanReaderErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		reader   io.Reader
+		opts     Options
+		expected []Token
+	}{
+		{
+			"invalid UTF-8",
+			iotest.OneByteReader(strings.NewReader("a\n{{foo}}\xffb")),
+			Options{InvalidUTF8: UTF8Error},
+			[]Token{
+				{TokenContent, "a\n", 0, 1, 2, 2, false, false, ""},
+				{TokenOpen, "{{", 2, 2, 4, 2, false, false, ""},
+				{TokenID, "foo", 4, 2, 7, 3, false, false, ""},
+				{TokenClose, "}}", 7, 2, 9, 2, false, false, ""},
+				{TokenError, "Invalid UTF-8 sequence at byte 9", 9, 2, 9, 0, false, false, ""},
+			},
+		},
+		{
+			"read error",
+			io.MultiReader(strings.NewReader("{{foo"), iotest.ErrReader(errors.New("boom"))),
+			Options{},
+			[]Token{
+				{TokenOpen, "{{", 0, 1, 2, 2, false, false, ""},
+				{TokenID, "foo", 2, 1, 5, 3, false, false, ""},
+				{TokenError, "Failed to read input: boom", 5, 1, 5, 0, false, false, ""},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if tokens := collectAll(ScanReaderWithOptions(test.reader, test.opts)); !reflect.DeepEqual(tokens, test.expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, test.expected, tokens)
+		}
+	}
+}
+
+// countingReader counts the bytes read from its reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+
+	return n, err
+}
+
+func TestScanReaderIncremental(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Repeat("{{foo}} bar\n", 1<<16) + strings.Repeat("content\n", 1<<16)
+	r := &countingReader{r: strings.NewReader(input)}
+
+	l := ScanReaderWithOptions(r, Options{MaxContentSize: 1024})
+
+	if token := l.NextToken(); (token.Kind != TokenOpen) || (atomic.LoadInt64(&r.n) >= int64(len(input))) {
+		t.Errorf("Unexpected first token: %v, after reading %d bytes", token, atomic.LoadInt64(&r.n))
+	}
+
+	var last Token
+	for token := l.NextToken(); token.Kind != TokenEOF; token = l.NextToken() {
+		if token.Kind == TokenError {
+			t.Fatalf("Unexpected error: %v", token)
+		}
+
+		if (token.Kind == TokenContent) && (len(token.Val) > 1024) {
+			t.Errorf("Unexpected content size: %d", len(token.Val))
+		}
+
+		last = token
+	}
+
+	if (last.Pos != len(input)-len(last.Val)) || (last.Line != 1<<17) {
+		t.Errorf("Unexpected last token: %d %d", last.Pos, last.Line)
+	}
+}
+
 // @todo Test errors:
 //   `{{{{raw foo`
 
@@ -0,0 +1,57 @@
+//go:build !tinygo && !raymond_tiny
+
+package lexer
+
+import "regexp"
+
+// pattern matches mustache delimiters
+type pattern = *regexp.Regexp
+
+// compile compiles the patterns of delimiters
+//
+// Patterns of default delimiters are compiled once, when package is initialized, and shared by all lexers.
+func (d *delimiters) compile() {
+	o := regexp.QuoteMeta(d.open)
+	c := regexp.QuoteMeta(d.close)
+
+	d.rOpenRaw = regexp.MustCompile(`^` + o + o)
+	d.rCloseRaw = regexp.MustCompile(`^` + c + c)
+	d.rOpenEndRaw = regexp.MustCompile(`^` + o + o + `/`)
+	d.rOpenEndRawLookAhead = regexp.MustCompile(o + o + `/`)
+	d.rOpenUnescaped = regexp.MustCompile(`^` + o + `~?\{`)
+	d.rCloseUnescaped = regexp.MustCompile(`^\}~?` + c)
+	d.rOpenBlock = regexp.MustCompile(`^` + o + `~?#`)
+	d.rOpenEndBlock = regexp.MustCompile(`^` + o + `~?/`)
+	d.rOpenPartial = regexp.MustCompile(`^` + o + `~?>`)
+	// {{^}} or {{else}}
+	d.rInverse = regexp.MustCompile(`^(` + o + `~?\^\s*~?` + c + `|` + o + `~?\s*else\s*~?` + c + `)`)
+	d.rOpenInverse = regexp.MustCompile(`^` + o + `~?\^`)
+	d.rOpenInverseChain = regexp.MustCompile(`^` + o + `~?\s*else`)
+	// {{ or {{&
+	d.rOpen = regexp.MustCompile(`^` + o + `~?&?`)
+	d.rClose = regexp.MustCompile(`^~?` + c)
+	// {{!--  ... --}}
+	d.rOpenCommentDash = regexp.MustCompile(`^` + o + `~?!--\s*`)
+	d.rCloseCommentDash = regexp.MustCompile(`^\s*--~?` + c)
+	// {{! ... }}
+	d.rOpenComment = regexp.MustCompile(`^` + o + `~?!\s*`)
+	d.rCloseComment = regexp.MustCompile(`^\s*~?` + c)
+}
+
+// findPattern returns the first string from current scanning position that matches given pattern
+func (l *Lexer) findPattern(p pattern) string {
+	l.fillPattern()
+
+	return p.FindString(l.input[l.pos:])
+}
+
+// indexPattern returns the index of the first string from current scanning position that matches given pattern
+//
+// It returns -1 if not found
+func (l *Lexer) indexPattern(p pattern) int {
+	loc := p.FindStringIndex(l.input[l.pos:])
+	if loc == nil {
+		return -1
+	}
+	return loc[0]
+}
@@ -0,0 +1,136 @@
+//go:build tinygo || raymond_tiny
+
+package lexer
+
+import "strings"
+
+// pattern matches mustache delimiters, without the regexp package that is costly on TinyGo
+//
+// It returns the start and end positions of the first match in given string, or -1 if not found.
+type pattern func(s string) (int, int)
+
+// step matches the element of a pattern starting at given position, and returns the position following it, or -1 if it doesn't match
+type step func(s string, pos int) int
+
+// compile builds the patterns of delimiters
+func (d *delimiters) compile() {
+	o := d.open
+	c := d.close
+
+	d.rOpenRaw = prefix(lit(o + o))
+	d.rCloseRaw = prefix(lit(c + c))
+	d.rOpenEndRaw = prefix(lit(o + o + "/"))
+	d.rOpenEndRawLookAhead = contains(o + o + "/")
+	d.rOpenUnescaped = prefix(lit(o), opt("~"), lit("{"))
+	d.rCloseUnescaped = prefix(lit("}"), opt("~"), lit(c))
+	d.rOpenBlock = prefix(lit(o), opt("~"), lit("#"))
+	d.rOpenEndBlock = prefix(lit(o), opt("~"), lit("/"))
+	d.rOpenPartial = prefix(lit(o), opt("~"), lit(">"))
+	// {{^}} or {{else}}
+	d.rInverse = either(
+		prefix(lit(o), opt("~"), lit("^"), spaces, opt("~"), lit(c)),
+		prefix(lit(o), opt("~"), spaces, lit("else"), spaces, opt("~"), lit(c)),
+	)
+	d.rOpenInverse = prefix(lit(o), opt("~"), lit("^"))
+	d.rOpenInverseChain = prefix(lit(o), opt("~"), spaces, lit("else"))
+	// {{ or {{&
+	d.rOpen = prefix(lit(o), opt("~"), opt("&"))
+	d.rClose = prefix(opt("~"), lit(c))
+	// {{!--  ... --}}
+	d.rOpenCommentDash = prefix(lit(o), opt("~"), lit("!--"), spaces)
+	d.rCloseCommentDash = prefix(spaces, lit("--"), opt("~"), lit(c))
+	// {{! ... }}
+	d.rOpenComment = prefix(lit(o), opt("~"), lit("!"), spaces)
+	d.rCloseComment = prefix(spaces, opt("~"), lit(c))
+}
+
+// prefix returns a pattern matching given steps at start of string
+func prefix(steps ...step) pattern {
+	return func(s string) (int, int) {
+		pos := 0
+
+		for _, st := range steps {
+			if pos = st(s, pos); pos == -1 {
+				return -1, -1
+			}
+		}
+
+		return 0, pos
+	}
+}
+
+// either returns a pattern matching the first given pattern that matches
+func either(patterns ...pattern) pattern {
+	return func(s string) (int, int) {
+		for _, p := range patterns {
+			if start, end := p(s); start != -1 {
+				return start, end
+			}
+		}
+
+		return -1, -1
+	}
+}
+
+// contains returns a pattern matching given string anywhere
+func contains(str string) pattern {
+	return func(s string) (int, int) {
+		i := strings.Index(s, str)
+		if i == -1 {
+			return -1, -1
+		}
+
+		return i, i + len(str)
+	}
+}
+
+// lit returns a step matching given string
+func lit(str string) step {
+	return func(s string, pos int) int {
+		if !strings.HasPrefix(s[pos:], str) {
+			return -1
+		}
+
+		return pos + len(str)
+	}
+}
+
+// opt returns a step matching given string, or nothing
+func opt(str string) step {
+	return func(s string, pos int) int {
+		if strings.HasPrefix(s[pos:], str) {
+			return pos + len(str)
+		}
+
+		return pos
+	}
+}
+
+// spaces is a step matching any number of whitespaces, like \s* in regular expressions
+func spaces(s string, pos int) int {
+	for (pos < len(s)) && isSpace(rune(s[pos])) {
+		pos++
+	}
+
+	return pos
+}
+
+// findPattern returns the first string from current scanning position that matches given pattern
+func (l *Lexer) findPattern(p pattern) string {
+	l.fillPattern()
+
+	start, end := p(l.input[l.pos:])
+	if start == -1 {
+		return ""
+	}
+
+	return l.input[l.pos+start : l.pos+end]
+}
+
+// indexPattern returns the index of the first string from current scanning position that matches given pattern
+//
+// It returns -1 if not found
+func (l *Lexer) indexPattern(p pattern) int {
+	start, _ := p(l.input[l.pos:])
+	return start
+}
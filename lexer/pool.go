@@ -0,0 +1,80 @@
+package lexer
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxPooledBufferSize is the capacity above which the read buffer of a lexer is not kept in the pool, so that a huge template does not pin memory forever
+const maxPooledBufferSize = 1 << 20
+
+// lexerPool holds the lexers released after use, that are reused by Scan functions
+var lexerPool = sync.Pool{
+	New: func() interface{} { return new(Lexer) },
+}
+
+// getLexer returns a lexer from the pool, that scans given input string or reader with given options
+func getLexer(input string, r io.Reader, opts Options) *Lexer {
+	result := lexerPool.Get().(*Lexer)
+	result.reset(input, r, opts)
+
+	return result
+}
+
+// Reset resets lexer to scan given input, with the same options and context, so that it is reused instead of allocating a new lexer.
+//
+// Tokens that were not fetched yet are discarded.
+func (l *Lexer) Reset(input string) {
+	l.reset(input, nil, l.opts)
+}
+
+// Release puts lexer back to the pool used by Scan functions, so that it is reused by subsequent scans. The lexer must not be used after that call.
+func (l *Lexer) Release() {
+	l.reset("", nil, Options{})
+	l.ctx, l.done = nil, nil
+
+	if cap(l.buf) > maxPooledBufferSize {
+		l.buf = nil
+	}
+
+	lexerPool.Put(l)
+}
+
+// reset resets lexer to scan given input string, or given reader if not nil, with given options
+//
+// Allocated buffers are kept.
+func (l *Lexer) reset(input string, r io.Reader, opts Options) {
+	if (r == nil) && (opts.InvalidUTF8 == UTF8Replace) {
+		input = strings.ToValidUTF8(input, string(utf8.RuneError))
+	}
+
+	// custom delimiters are cached, so that their patterns are not compiled again
+	delims := defaultDelimiters
+	if (opts.OpenDelim != "") && (opts.CloseDelim != "") && (checkDelimiters(opts.OpenDelim, opts.CloseDelim) == nil) {
+		delims = cachedDelimiters(opts.OpenDelim, opts.CloseDelim)
+	}
+
+	// forget values of previous input
+	for i := range l.tokens {
+		l.tokens[i] = Token{}
+	}
+
+	*l = Lexer{
+		input:   input,
+		opts:    opts,
+		delims:  delims,
+		ctx:     l.ctx,
+		done:    l.done,
+		tokens:  l.tokens[:0],
+		line:    1,
+		reader:  r,
+		reading: r != nil,
+		buf:     l.buf,
+		pending: l.pending[:0],
+		lines:   l.lines[:0],
+	}
+
+	l.init()
+}
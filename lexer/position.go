@@ -0,0 +1,52 @@
+package lexer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Position returns the line and column of given byte offset in input, like the Pos and End of tokens.
+//
+// Both start at 1, and the column is counted in bytes, like in go/token. An offset past the input read so far is clamped to its end.
+//
+// The index of line starts is built on first call, and extended with the input scanned since. When scanning a reader, the lexer indexes input before forgetting it, so that positions of previous tokens are still known.
+func (l *Lexer) Position(offset int) (int, int) {
+	l.indexLines(l.offset + len(l.input))
+
+	if offset > l.indexed {
+		offset = l.indexed
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	// first line starting after offset
+	line := sort.SearchInts(l.lines, offset+1)
+
+	return line, offset - l.lines[line-1] + 1
+}
+
+// indexLines adds to lines index the line starts of input up to given byte offset
+func (l *Lexer) indexLines(end int) {
+	if len(l.lines) == 0 {
+		l.lines = append(l.lines, 0)
+	}
+
+	if end <= l.indexed {
+		return
+	}
+
+	for str, pos := l.input[l.indexed-l.offset:end-l.offset], l.indexed; ; {
+		i := strings.IndexByte(str, '\n')
+		if i == -1 {
+			break
+		}
+
+		pos += i + 1
+		str = str[i+1:]
+		l.lines = append(l.lines, pos)
+	}
+
+	l.indexed = end
+}
@@ -1,6 +1,9 @@
 package lexer
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	// TokenError represents an error
@@ -98,6 +101,15 @@ const (
 
 	// TokenBoolean is the BOOLEAN token
 	TokenBoolean
+
+	// TokenNull is the NULL token
+	TokenNull
+
+	// TokenUndefined is the UNDEFINED token
+	TokenUndefined
+
+	// TokenChainHelper is the ID token of the helper chained by an OPEN_INVERSE_CHAIN token, eg. if for {{else if foo}}
+	TokenChainHelper
 )
 
 const (
@@ -112,12 +124,27 @@ const (
 type TokenKind int
 
 // Token represents a scanned token.
+//
+// Pos and End delimit the source of token in input: for a string, that source excludes the delimiters, and includes the escape characters removed from value. Error and EOF tokens have an empty source.
 type Token struct {
 	Kind TokenKind // Token kind
 	Val  string    // Token value
 
 	Pos  int // Byte position in input string
 	Line int // Line number in input string
+
+	End int // Byte position following token in input string
+	Len int // Length of token in input string, ie. End - Pos
+
+	StripBefore bool // Token has a ~ marker that strips whitespaces preceding it, eg. {{~
+	StripAfter  bool // Token has a ~ marker that strips whitespaces following it, eg. ~}}
+
+	Text string // Text of comment token, without its delimiters, strip markers and up to two dashes on each side, like handlebars.js does, eg. " foo " for {{!-- foo --}}
+}
+
+// TrimmedText returns the text of comment token, without leading and trailing whitespaces.
+func (t Token) TrimmedText() string {
+	return strings.TrimSpace(t.Text)
 }
 
 // tokenName permits to display token name given token type
@@ -148,6 +175,9 @@ var tokenName = map[TokenKind]string{
 	TokenString:           "String",
 	TokenNumber:           "Number",
 	TokenBoolean:          "Boolean",
+	TokenNull:             "Null",
+	TokenUndefined:        "Undefined",
+	TokenChainHelper:      "ChainHelper",
 	TokenData:             "Data",
 	TokenSep:              "Sep",
 }
@@ -0,0 +1,320 @@
+package raymond
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// LintIssue is a problem reported by Lint().
+type LintIssue struct {
+	// Template is the name of the template containing the issue
+	Template string
+
+	// Line is the line of the issue in template source
+	Line int
+
+	// Rule is the name of the rule that reported the issue
+	Rule string
+
+	// Message describes the issue
+	Message string
+}
+
+// LintRule is a check done by Lint() on all nodes of templates.
+type LintRule struct {
+	// Name identifies the rule, eg. "unknown-helper"
+	Name string
+
+	// Description is a short description of what the rule checks
+	Description string
+
+	// Optional is true if rule is not part of the default rules
+	Optional bool
+
+	// Check reports the issues of given node with ctx.Report(). It is called for all statements and expressions of templates, in source order.
+	Check func(ctx *LintContext, node ast.Node)
+}
+
+// LintContext is the context of a LintRule check.
+type LintContext struct {
+	// Template is the linted template
+	Template *Template
+
+	name   string
+	rule   *LintRule
+	issues []LintIssue
+}
+
+// Report reports an issue of given node, with a message formatted with fmt.Sprintf().
+func (ctx *LintContext) Report(node ast.Node, format string, args ...interface{}) {
+	ctx.issues = append(ctx.issues, LintIssue{
+		Template: ctx.name,
+		Line:     node.Location().Line,
+		Rule:     ctx.rule.Name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// LintRules are the built-in lint rules.
+var LintRules = []*LintRule{
+	{
+		Name:        "unknown-helper",
+		Description: "helper calls, ie. expressions with arguments and subexpressions, must call a helper registered globally or on template",
+		Check:       lintUnknownHelper,
+	},
+	{
+		Name:        "helper-arity",
+		Description: "helpers must be called with the number of arguments they expect",
+		Check:       lintHelperArity,
+	},
+	{
+		Name:        "unknown-partial",
+		Description: "partials referenced by name must be registered globally or on template",
+		Check:       lintUnknownPartial,
+	},
+	{
+		Name:        "empty-block",
+		Description: "blocks must not be empty",
+		Check:       lintEmptyBlock,
+	},
+	{
+		Name:        "duplicate-hash-key",
+		Description: "hash arguments must not set the same key twice",
+		Check:       lintDuplicateHashKey,
+	},
+	{
+		Name:        "unescaped-output",
+		Description: "values must be escaped, ie. {{{value}}} and {{& value}} are not allowed",
+		Optional:    true,
+		Check:       lintUnescapedOutput,
+	},
+}
+
+// DefaultLintRules returns the built-in lint rules that are not optional.
+func DefaultLintRules() []*LintRule {
+	var result []*LintRule
+
+	for _, rule := range LintRules {
+		if !rule.Optional {
+			result = append(result, rule)
+		}
+	}
+
+	return result
+}
+
+// Lint checks given templates, indexed by name, with given rules, and returns the issues found, in template name order then line order.
+//
+// Templates are parsed if necessary, and are not evaluated. Partial sources are not followed: add partials to given templates to lint them too.
+func Lint(templates map[string]*Template, rules []*LintRule) ([]LintIssue, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var result []LintIssue
+
+	for _, name := range names {
+		tpl := templates[name]
+
+		if err := tpl.parse(); err != nil {
+			return nil, err
+		}
+
+		ctx := &LintContext{Template: tpl, name: name}
+
+		lintProgram(tpl.program, func(node ast.Node) {
+			for _, rule := range rules {
+				ctx.rule = rule
+				rule.Check(ctx, node)
+			}
+		})
+
+		sort.SliceStable(ctx.issues, func(i, j int) bool { return ctx.issues[i].Line < ctx.issues[j].Line })
+
+		result = append(result, ctx.issues...)
+	}
+
+	return result, nil
+}
+
+// lintProgram calls given function with all statements and expressions of given program and its nested programs
+func lintProgram(program *ast.Program, check func(node ast.Node)) {
+	if program == nil {
+		return
+	}
+
+	for _, node := range program.Body {
+		check(node)
+
+		switch n := node.(type) {
+		case *ast.MustacheStatement:
+			lintExpression(n.Expression, check)
+		case *ast.BlockStatement:
+			lintExpression(n.Expression, check)
+			lintProgram(n.Program, check)
+			lintProgram(n.Inverse, check)
+		case *ast.PartialStatement:
+			lintNode(n.Name, check)
+			lintNodes(n.Params, check)
+			lintHash(n.Hash, check)
+		}
+	}
+}
+
+// lintExpression calls given function with given expression and its nested expressions
+func lintExpression(node *ast.Expression, check func(node ast.Node)) {
+	check(node)
+
+	lintNode(node.Path, check)
+	lintNodes(node.Params, check)
+	lintHash(node.Hash, check)
+}
+
+// lintNodes calls given function with given expressions and their nested expressions
+func lintNodes(nodes []ast.Node, check func(node ast.Node)) {
+	for _, node := range nodes {
+		lintNode(node, check)
+	}
+}
+
+// lintHash calls given function with given hash and its values
+func lintHash(node *ast.Hash, check func(node ast.Node)) {
+	if node == nil {
+		return
+	}
+
+	check(node)
+
+	for _, pair := range node.Pairs {
+		lintNode(pair.Val, check)
+	}
+}
+
+// lintNode calls given function with given expression and its nested expressions
+func lintNode(node ast.Node, check func(node ast.Node)) {
+	check(node)
+
+	if sub, ok := node.(*ast.SubExpression); ok {
+		lintExpression(sub.Expression, check)
+	}
+}
+
+// lintHelperCall returns the expression and the helper name of given node if it is a mustache, block or subexpression, and true if that expression is a helper call, ie. a subexpression or an expression with arguments
+func lintHelperCall(node ast.Node) (*ast.Expression, string, bool) {
+	var expr *ast.Expression
+	call := false
+
+	switch n := node.(type) {
+	case *ast.MustacheStatement:
+		expr = n.Expression
+	case *ast.BlockStatement:
+		expr = n.Expression
+	case *ast.SubExpression:
+		expr, call = n.Expression, true
+	default:
+		return nil, "", false
+	}
+
+	name := expr.HelperName()
+	if name == "" {
+		name = expr.NamespacedHelperName()
+	}
+
+	return expr, name, call || (len(expr.Params) > 0) || (expr.Hash != nil)
+}
+
+// lintUnknownHelper implements the unknown-helper rule
+func lintUnknownHelper(ctx *LintContext, node ast.Node) {
+	if _, name, call := lintHelperCall(node); (name != "") && call {
+		if _, ok := ctx.Template.Helper(name); !ok {
+			ctx.Report(node, "helper %q is not registered", name)
+		}
+	}
+}
+
+// lintHelperArity implements the helper-arity rule
+func lintHelperArity(ctx *LintContext, node ast.Node) {
+	expr, name, _ := lintHelperCall(node)
+	if name == "" {
+		return
+	}
+
+	info, ok := ctx.Template.Helper(name)
+	if !ok {
+		return
+	}
+
+	minArgs, maxArgs := info.Arity()
+
+	switch got := len(expr.Params); {
+	case (maxArgs == -1) && (got < minArgs):
+		ctx.Report(node, "helper %q expects at least %d arguments, got %d", name, minArgs, got)
+	case (maxArgs != -1) && (got != minArgs):
+		ctx.Report(node, "helper %q expects %d arguments, got %d", name, minArgs, got)
+	}
+}
+
+// lintUnknownPartial implements the unknown-partial rule
+func lintUnknownPartial(ctx *LintContext, node ast.Node) {
+	partial, ok := node.(*ast.PartialStatement)
+	if !ok {
+		return
+	}
+
+	name, ok := ast.HelperNameStr(partial.Name)
+	if !ok {
+		// dynamic partial
+		return
+	}
+
+	if (ctx.Template.findPartial(name) == nil) && (findPartial(name) == nil) {
+		ctx.Report(node, "partial %q is not registered", name)
+	}
+}
+
+// lintEmptyBlock implements the empty-block rule
+func lintEmptyBlock(ctx *LintContext, node ast.Node) {
+	block, ok := node.(*ast.BlockStatement)
+	if !ok {
+		return
+	}
+
+	if lintEmptyProgram(block.Program) && lintEmptyProgram(block.Inverse) {
+		ctx.Report(node, "block %q is empty", block.Expression.Canonical())
+	}
+}
+
+// lintEmptyProgram returns true if given program is nil or has no statement
+func lintEmptyProgram(program *ast.Program) bool {
+	return (program == nil) || (len(program.Body) == 0)
+}
+
+// lintDuplicateHashKey implements the duplicate-hash-key rule
+func lintDuplicateHashKey(ctx *LintContext, node ast.Node) {
+	hash, ok := node.(*ast.Hash)
+	if !ok {
+		return
+	}
+
+	seen := make(map[string]bool, len(hash.Pairs))
+
+	for _, pair := range hash.Pairs {
+		if seen[pair.Key] {
+			ctx.Report(pair, "hash key %q is set more than once", pair.Key)
+		}
+
+		seen[pair.Key] = true
+	}
+}
+
+// lintUnescapedOutput implements the unescaped-output rule
+func lintUnescapedOutput(ctx *LintContext, node ast.Node) {
+	if mustache, ok := node.(*ast.MustacheStatement); ok && mustache.Unescaped {
+		ctx.Report(node, "value %q is not escaped", mustache.Expression.Canonical())
+	}
+}
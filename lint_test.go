@@ -0,0 +1,81 @@
+package raymond
+
+import (
+	"reflect"
+	"testing"
+)
+
+var lintTests = []struct {
+	name     string
+	input    string
+	expected []LintIssue
+}{
+	{"valid", "{{#if a}}{{foo}} {{str.upper b}}{{> item}}{{/if}}", nil},
+	{"unknown helper", "{{foo bar}}\n{{#baz a}}x{{/baz}} {{qux (quux 1)}}", []LintIssue{
+		{"test", 1, "unknown-helper", `helper "foo" is not registered`},
+		{"test", 2, "unknown-helper", `helper "baz" is not registered`},
+		{"test", 2, "unknown-helper", `helper "qux" is not registered`},
+		{"test", 2, "unknown-helper", `helper "quux" is not registered`},
+	}},
+	{"helper arity", "{{#if}}x{{/if}}\n{{lookup a}} {{concat}} {{str.upper a b}}", []LintIssue{
+		{"test", 1, "helper-arity", `helper "if" expects 1 arguments, got 0`},
+		{"test", 2, "helper-arity", `helper "lookup" expects 2 arguments, got 1`},
+		{"test", 2, "helper-arity", `helper "str.upper" expects 1 arguments, got 2`},
+	}},
+	{"unknown partial", "{{> item}}\n{{> missing}} {{> (dynamic)}}", []LintIssue{
+		{"test", 2, "unknown-partial", `partial "missing" is not registered`},
+	}},
+	{"empty block", "{{#if a}}{{/if}}{{#if b}}{{else}}x{{/if}}", []LintIssue{
+		{"test", 1, "empty-block", `block "if" is empty`},
+	}},
+	{"duplicate hash key", "{{> item a=1 b=2 a=3}}", []LintIssue{
+		{"test", 1, "duplicate-hash-key", `hash key "a" is set more than once`},
+	}},
+	{"optional rule", "{{{foo}}}", nil},
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range lintTests {
+		tpl := MustParse(test.input)
+		tpl.RegisterPartial("item", "item")
+		tpl.RegisterHelper("dynamic", func() string { return "item" })
+		tpl.RegisterNamespace("str", map[string]interface{}{
+			"upper": func(s string) string { return s },
+		})
+
+		issues, err := Lint(map[string]*Template{"test": tpl}, DefaultLintRules())
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(issues, test.expected) {
+			t.Errorf("Test '%s' failed\nexpected\n\t%v\ngot\n\t%v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	t.Parallel()
+
+	templates := map[string]*Template{
+		"b": MustParse("{{{foo}}}"),
+		"a": MustParse("{{& bar}}{{#if a}}{{/if}}"),
+	}
+
+	issues, err := Lint(templates, LintRules[len(LintRules)-1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []LintIssue{
+		{"a", 1, "unescaped-output", `value "bar" is not escaped`},
+		{"b", 1, "unescaped-output", `value "foo" is not escaped`},
+	}
+
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected %v, got: %v", expected, issues)
+	}
+}
@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is an opened template
+type document struct {
+	uri  string
+	text string
+}
+
+// offset returns the byte offset of given position in document, clamped to the document bounds
+func (doc *document) offset(pos position) int {
+	offset := 0
+
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(doc.text[offset:], '\n')
+		if i == -1 {
+			return len(doc.text)
+		}
+
+		offset += i + 1
+	}
+
+	// characters are counted in UTF-16 code units
+	for units := 0; (units < pos.Character) && (offset < len(doc.text)); {
+		r, size := utf8.DecodeRuneInString(doc.text[offset:])
+		if r == '\n' {
+			break
+		}
+
+		units += utf16Len(r)
+		offset += size
+	}
+
+	return offset
+}
+
+// position returns the position of given byte offset in document
+func (doc *document) position(offset int) position {
+	if offset > len(doc.text) {
+		offset = len(doc.text)
+	}
+
+	lineStart := strings.LastIndexByte(doc.text[:offset], '\n') + 1
+
+	result := position{Line: strings.Count(doc.text[:lineStart], "\n")}
+	for _, r := range doc.text[lineStart:offset] {
+		result.Character += utf16Len(r)
+	}
+
+	return result
+}
+
+// lineRange returns the range of given line in document, starting at 1, without its newline
+func (doc *document) lineRange(line int) textRange {
+	start := doc.offset(position{Line: line - 1})
+
+	end := strings.IndexByte(doc.text[start:], '\n')
+	if end == -1 {
+		end = len(doc.text) - start
+	}
+
+	return textRange{Start: doc.position(start), End: doc.position(start + end)}
+}
+
+// utf16Len returns the number of UTF-16 code units of given rune
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+// uriPath returns the file path of given file URI, or an empty string if this is not a file URI
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if (err != nil) || (u.Scheme != "file") {
+		return ""
+	}
+
+	return filepath.FromSlash(u.Path)
+}
+
+// pathURI returns the file URI of given file path
+func pathURI(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filePath)}).String()
+}
@@ -0,0 +1,399 @@
+package lsp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/lexer"
+	"github.com/aymerick/raymond/parser"
+)
+
+// dataVariables are the data variables set by built-in helpers
+var dataVariables = []string{"@root", "@index", "@key", "@first", "@last"}
+
+// partials returns the file paths of partials available to given document, by name, and false if there is no partials directory
+func (s *Server) partials(doc *document) (map[string]string, bool) {
+	dir := s.opts.PartialsDir
+	if dir == "" {
+		docPath := uriPath(doc.uri)
+		if docPath == "" {
+			return nil, false
+		}
+
+		dir = filepath.Dir(docPath)
+	}
+
+	result := make(map[string]string)
+
+	// errors are ignored, so that unreadable files are only reported as unknown partials
+	filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if ext := filepath.Ext(filePath); info.IsDir() || ((ext != ".hbs") && (ext != ".handlebars")) {
+			return nil
+		}
+
+		if rel, err := filepath.Rel(dir, filePath); err == nil {
+			result[filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))] = filePath
+		}
+
+		return nil
+	})
+
+	return result, true
+}
+
+// template parses given document, and registers the helpers given with options on it
+func (s *Server) template(doc *document) (*raymond.Template, error) {
+	tpl, err := raymond.Parse(doc.text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range s.opts.Helpers {
+		if _, ok := tpl.Helper(info.Name); !ok {
+			tpl.RegisterHelperWithInfo(info.Name, func(args ...interface{}) string { return "" }, info)
+		}
+	}
+
+	return tpl, nil
+}
+
+// diagnostics returns the parse error or lint issues of given document
+func (s *Server) diagnostics(doc *document) []diagnostic {
+	tpl, err := s.template(doc)
+	if err != nil {
+		line, msg := 1, err.Error()
+
+		var parseErr *parser.Error
+		if errors.As(err, &parseErr) {
+			line, msg = parseErr.Line, strings.Replace(parseErr.Message, "\n", " ", -1)
+		}
+
+		return []diagnostic{{
+			Range:    doc.lineRange(line),
+			Severity: severityError,
+			Code:     "parse",
+			Source:   "raymond",
+			Message:  msg,
+		}}
+	}
+
+	rules := s.opts.LintRules
+
+	if partials, ok := s.partials(doc); ok {
+		for name := range partials {
+			tpl.RegisterPartial(name, "")
+		}
+	} else {
+		// partials can't be checked
+		rules = nil
+		for _, rule := range s.opts.LintRules {
+			if rule.Name != "unknown-partial" {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	issues, err := raymond.Lint(map[string]*raymond.Template{doc.uri: tpl}, rules)
+	if err != nil {
+		return nil
+	}
+
+	result := make([]diagnostic, len(issues))
+	for i, issue := range issues {
+		result[i] = diagnostic{
+			Range:    doc.lineRange(issue.Line),
+			Severity: severityWarning,
+			Code:     issue.Rule,
+			Source:   "raymond",
+			Message:  issue.Message,
+		}
+	}
+
+	return result
+}
+
+// definition returns the location of the partial whose name is at given offset of document, or nil if not found
+func (s *Server) definition(doc *document, offset int) []location {
+	tokens := lexer.Collect(doc.text)
+
+	for i, tok := range tokens {
+		if tok.Kind != lexer.TokenOpenPartial {
+			continue
+		}
+
+		name, start, end := partialName(tokens[i+1:])
+		if (name == "") || (offset < start) || (offset > end) {
+			continue
+		}
+
+		partials, _ := s.partials(doc)
+
+		if filePath, ok := partials[name]; ok {
+			return []location{{URI: pathURI(filePath)}}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// partialName returns the name of partial defined by given tokens, that follow a partial opening delimiter, with its start and end offsets
+func partialName(tokens []lexer.Token) (string, int, int) {
+	if len(tokens) == 0 {
+		return "", 0, 0
+	}
+
+	if tokens[0].Kind == lexer.TokenString {
+		return tokens[0].Val, tokens[0].Pos, tokens[0].End
+	}
+
+	var name strings.Builder
+	start, end := tokens[0].Pos, tokens[0].Pos
+
+	for _, tok := range tokens {
+		if (tok.Kind != lexer.TokenID) && (tok.Kind != lexer.TokenSep) {
+			break
+		}
+
+		if (tok.Kind == lexer.TokenSep) && (tok.Pos != end) {
+			// separator of another expression
+			break
+		}
+
+		name.WriteString(tok.Val)
+		end = tok.End
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(name.String(), "["), "]"), start, end
+}
+
+// complete returns the completion proposals at given offset of document
+func (s *Server) complete(doc *document, offset int) []completionItem {
+	open := strings.LastIndex(doc.text[:offset], "{{")
+	if (open == -1) || strings.Contains(doc.text[open:offset], "}}") {
+		// not in a mustache
+		return []completionItem{}
+	}
+
+	inside := doc.text[open+2 : offset]
+
+	word := inside[strings.LastIndexAny(inside, " \t\r\n(){}=|~")+1:]
+	before := strings.TrimRight(inside[:len(inside)-len(word)], " \t\r\n")
+
+	// mustache opening, eg. "~#"
+	opening := strings.TrimLeft(before, "{~#^/&!>")
+
+	switch {
+	case strings.TrimLeft(before, "{~") == ">":
+		return s.completePartials(doc)
+	case strings.HasPrefix(word, "@") && !strings.Contains(word, "."):
+		return completeDataVariables()
+	}
+
+	result := s.completePaths(doc.text[:open], word)
+
+	if ((opening == "") || strings.HasSuffix(before, "(")) && !strings.ContainsAny(word, "./") {
+		// helper position
+		result = append(result, s.completeHelpers()...)
+	}
+
+	return result
+}
+
+// completePartials returns the partial names available to given document
+func (s *Server) completePartials(doc *document) []completionItem {
+	partials, _ := s.partials(doc)
+
+	result := []completionItem{}
+	for name, filePath := range partials {
+		result = append(result, completionItem{Label: name, Kind: kindFile, Detail: filePath})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+
+	return result
+}
+
+// completeDataVariables returns the data variables
+func completeDataVariables() []completionItem {
+	result := make([]completionItem, len(dataVariables))
+	for i, name := range dataVariables {
+		result[i] = completionItem{Label: name, Kind: kindVariable}
+	}
+
+	return result
+}
+
+// completeHelpers returns global helpers and helpers given with options
+func (s *Server) completeHelpers() []completionItem {
+	tpl, _ := raymond.Parse("")
+	helpers := append(tpl.Helpers(), s.opts.Helpers...)
+
+	result := make([]completionItem, 0, len(helpers))
+
+	for _, info := range helpers {
+		detail := info.Name
+		for _, param := range info.Params {
+			detail += " " + param.Name
+		}
+
+		result = append(result, completionItem{
+			Label:         info.Name,
+			Kind:          kindFunction,
+			Detail:        detail,
+			Documentation: info.Description,
+		})
+	}
+
+	return result
+}
+
+// completePaths returns the fields of the context of given path prefix being typed, at the end of given text
+func (s *Server) completePaths(text string, word string) []completionItem {
+	if s.opts.Schema == nil {
+		return []completionItem{}
+	}
+
+	scopes := blockScopes(s.opts.Schema, text)
+
+	// "../../foo" => "foo", two levels up
+	for strings.HasPrefix(word, "../") {
+		word = word[3:]
+
+		if len(scopes) > 1 {
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+
+	scope := scopes[len(scopes)-1]
+
+	parts := strings.FieldsFunc(word, func(r rune) bool { return (r == '.') || (r == '/') })
+	if !strings.HasSuffix(word, ".") && !strings.HasSuffix(word, "/") && (len(parts) > 0) {
+		// last part is being typed
+		parts = parts[:len(parts)-1]
+	}
+
+	if (len(parts) > 0) && (parts[0] == "@root") {
+		scope, parts = s.opts.Schema, parts[1:]
+	}
+
+	scope = scope.lookup(parts)
+	if (scope == nil) || (len(scope.Properties) == 0) {
+		return []completionItem{}
+	}
+
+	result := make([]completionItem, 0, len(scope.Properties))
+	for name, prop := range scope.Properties {
+		result = append(result, completionItem{
+			Label:         name,
+			Kind:          kindField,
+			Detail:        prop.Type,
+			Documentation: prop.Description,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+
+	return result
+}
+
+// blockScopes returns the schemas of the contexts of the blocks that are still opened at the end of given text, starting with given root schema
+//
+// The #each block iterates on the items of its param, and the #with block changes context to its param. Other blocks keep the current context, so that they are skipped by "../" paths. Schemas are nil for unknown contexts.
+func blockScopes(root *Schema, text string) []*Schema {
+	result := []*Schema{root}
+
+	// true for each opened block that changes context
+	var blocks []bool
+
+	tokens := lexer.Collect(text)
+
+	for i, tok := range tokens {
+		switch tok.Kind {
+		case lexer.TokenOpenBlock:
+			var parts []string
+			helper := ""
+
+			if (i+2 < len(tokens)) && (tokens[i+1].Kind == lexer.TokenID) {
+				helper, parts = tokens[i+1].Val, paramPath(tokens[i+2:])
+			}
+
+			scope := result[len(result)-1].lookup(parts)
+
+			switch {
+			case (helper == "each") && (parts != nil):
+				if scope != nil {
+					scope = scope.Items
+				}
+			case (helper == "with") && (parts != nil):
+			default:
+				blocks = append(blocks, false)
+				continue
+			}
+
+			result = append(result, scope)
+			blocks = append(blocks, true)
+		case lexer.TokenOpenInverse:
+			blocks = append(blocks, false)
+		case lexer.TokenOpenEndBlock:
+			if len(blocks) == 0 {
+				continue
+			}
+
+			if blocks[len(blocks)-1] {
+				result = result[:len(result)-1]
+			}
+
+			blocks = blocks[:len(blocks)-1]
+		}
+	}
+
+	return result
+}
+
+// paramPath returns the parts of the path param that starts given tokens, or nil if they do not start with a path
+func paramPath(tokens []lexer.Token) []string {
+	if (len(tokens) == 0) || (tokens[0].Kind != lexer.TokenID) {
+		return nil
+	}
+
+	result := []string{}
+
+	for _, tok := range tokens {
+		if tok.Kind == lexer.TokenSep {
+			continue
+		}
+
+		if tok.Kind != lexer.TokenID {
+			break
+		}
+
+		if (tok.Val != "this") && (tok.Val != ".") {
+			result = append(result, tok.Val)
+		}
+	}
+
+	return result
+}
+
+// lookup returns the schema of the value at given path from that schema, or nil if unknown
+func (schema *Schema) lookup(parts []string) *Schema {
+	for _, part := range parts {
+		if schema == nil {
+			return nil
+		}
+
+		schema = schema.Properties[part]
+	}
+
+	return schema
+}
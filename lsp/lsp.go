@@ -0,0 +1,280 @@
+// Package lsp implements a Language Server Protocol server for handlebars templates, backed by raymond lexer, parser and linter.
+//
+// It provides diagnostics for parse errors and lint issues, go-to-definition for partials, semantic tokens for highlighting, and completion for helpers, partials, data variables and context paths described by a schema. Documents are synchronized in full, and the server communicates with JSON-RPC messages over a reader and a writer, usually stdin and stdout of an editor subprocess.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+// Options represents the server options.
+type Options struct {
+	// PartialsDir is the directory of partials, named after their path relative to directory, without extension. If empty, partials are looked up in the directory of each document.
+	PartialsDir string
+
+	// Helpers are the helpers registered by the application, in addition to global helpers. They are completed, and are not reported by the unknown-helper lint rule.
+	Helpers []raymond.HelperInfo
+
+	// Schema describes the context of templates, to complete paths. If nil, paths are not completed.
+	Schema *Schema
+
+	// LintRules are the lint rules checked on documents. If nil, raymond.DefaultLintRules() are used.
+	LintRules []*raymond.LintRule
+}
+
+// Schema describes a context value. It is a subset of JSON Schema, so that it can be loaded from a JSON Schema file with json.Unmarshal().
+type Schema struct {
+	// Type is the JSON type of value, eg. "object" or "array"
+	Type string `json:"type,omitempty"`
+
+	// Description is shown with completions
+	Description string `json:"description,omitempty"`
+
+	// Properties describes object fields, by name
+	Properties map[string]*Schema `json:"properties,omitempty"`
+
+	// Items describes array elements
+	Items *Schema `json:"items,omitempty"`
+}
+
+// Server is a Language Server Protocol server.
+type Server struct {
+	opts Options
+
+	// opened documents, by URI
+	docs map[string]*document
+
+	out      io.Writer
+	shutdown bool
+}
+
+// errExit is returned by handlers when the server must stop
+var errExit = errors.New("exit")
+
+// NewServer instanciates a new server with given options.
+func NewServer(opts Options) *Server {
+	if opts.LintRules == nil {
+		opts.LintRules = raymond.DefaultLintRules()
+	}
+
+	return &Server{
+		opts: opts,
+		docs: make(map[string]*document),
+	}
+}
+
+// Serve reads requests and notifications from given reader, and writes responses and notifications to given writer, until the exit notification or the end of input.
+//
+// Messages are handled sequentially, and an error is returned if input is not a valid message stream, or if client exits without requesting a shutdown first.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+
+	r := bufio.NewReader(in)
+
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.handle(msg); err != nil {
+			if err == errExit {
+				if !s.shutdown {
+					return errors.New("exit notification received before shutdown request")
+				}
+
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// readMessage reads a message with its base protocol headers
+func readMessage(r *bufio.Reader) (*message, error) {
+	headers, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if (err == io.EOF) && (len(headers) == 0) {
+			return nil, io.EOF
+		}
+
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", headers.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		// reported to client as a parse error
+		return &message{Error: &responseError{Code: codeParseError, Message: err.Error()}}, nil
+	}
+
+	return msg, nil
+}
+
+// write writes given message
+func (s *Server) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+
+	_, err = s.out.Write(body)
+	return err
+}
+
+// reply writes the response to given request
+func (s *Server) reply(req *message, result interface{}, err *responseError) error {
+	if req.ID == nil {
+		// notifications have no response
+		return nil
+	}
+
+	if (result == nil) && (err == nil) {
+		// result is required in successful responses
+		result = json.RawMessage("null")
+	}
+
+	return s.write(&message{ID: req.ID, Result: result, Error: err})
+}
+
+// notify writes a notification
+func (s *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return s.write(&message{Method: method, Params: data})
+}
+
+// handle handles given message
+func (s *Server) handle(msg *message) error {
+	if msg.Error != nil {
+		return s.write(&message{ID: msg.ID, Error: msg.Error})
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   syncFull,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"{", ".", ">", "(", "@", " "},
+				},
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{
+						"tokenTypes":     semanticTokenTypes,
+						"tokenModifiers": semanticTokenModifiers,
+					},
+					"full": true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "raymond"},
+		}, nil)
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(msg, nil, nil)
+	case "exit":
+		return errExit
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+
+		return s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if (json.Unmarshal(msg.Params, &params) != nil) || (len(params.ContentChanges) == 0) {
+			return nil
+		}
+
+		return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+
+		delete(s.docs, params.TextDocument.URI)
+
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}})
+	case "textDocument/semanticTokens/full":
+		var params semanticTokensParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.reply(msg, nil, &responseError{Code: codeInvalidParams, Message: err.Error()})
+		}
+
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return s.reply(msg, nil, &responseError{Code: codeInvalidParams, Message: "unknown document " + params.TextDocument.URI})
+		}
+
+		return s.reply(msg, map[string]interface{}{"data": s.semanticTokens(doc)}, nil)
+	case "textDocument/completion", "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.reply(msg, nil, &responseError{Code: codeInvalidParams, Message: err.Error()})
+		}
+
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return s.reply(msg, nil, &responseError{Code: codeInvalidParams, Message: "unknown document " + params.TextDocument.URI})
+		}
+
+		if msg.Method == "textDocument/completion" {
+			return s.reply(msg, s.complete(doc, doc.offset(params.Position)), nil)
+		}
+
+		return s.reply(msg, s.definition(doc, doc.offset(params.Position)), nil)
+	default:
+		if strings.HasPrefix(msg.Method, "$/") || (msg.ID == nil) {
+			// optional notifications are ignored
+			return nil
+		}
+
+		if msg.Method == "" {
+			return s.reply(msg, nil, &responseError{Code: codeInvalidRequest, Message: "missing method"})
+		}
+
+		return s.reply(msg, nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+	}
+}
+
+// update stores given document text, and publishes its diagnostics
+func (s *Server) update(uri string, text string) error {
+	doc := &document{uri: uri, text: text}
+	s.docs[uri] = doc
+
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: s.diagnostics(doc)})
+}
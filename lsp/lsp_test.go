@@ -0,0 +1,287 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aymerick/raymond"
+)
+
+// request returns a framed JSON-RPC request, or a notification if id is zero
+func request(id int, method string, params interface{}) string {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != 0 {
+		msg["id"] = id
+	}
+
+	body, _ := json.Marshal(msg)
+
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// serve runs a server with given options on given messages, and returns the messages it writes
+func serve(t *testing.T, opts Options, msgs ...string) []map[string]interface{} {
+	var in, out bytes.Buffer
+	for _, msg := range msgs {
+		in.WriteString(msg)
+	}
+
+	if err := NewServer(opts).Serve(&in, &out); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var result []map[string]interface{}
+
+	r := bufio.NewReader(&out)
+	for {
+		headers, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err != nil {
+			break
+		}
+
+		length, _ := strconv.Atoi(headers.Get("Content-Length"))
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		result = append(result, msg)
+	}
+
+	return result
+}
+
+// open returns a didOpen notification for given document
+func open(uri string, text string) string {
+	return request(0, "textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "handlebars", "version": 1, "text": text},
+	})
+}
+
+// at returns the params of a request on the first line of given document, after the last occurrence of given string in text
+func at(uri string, text string, after string) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": 0, "character": strings.LastIndex(text, after) + len(after)},
+	}
+}
+
+// labels returns the sorted labels of given completion result
+func labels(result interface{}) []string {
+	var labels []string
+
+	items, _ := result.([]interface{})
+	for _, item := range items {
+		labels = append(labels, item.(map[string]interface{})["label"].(string))
+	}
+
+	sort.Strings(labels)
+
+	return labels
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Parallel()
+
+	msgs := serve(t, Options{},
+		request(1, "initialize", map[string]interface{}{}),
+		request(0, "initialized", map[string]interface{}{}),
+		request(2, "unknown/method", nil),
+		request(3, "shutdown", nil),
+		request(0, "exit", nil),
+	)
+
+	if len(msgs) != 3 {
+		t.Fatalf("Expected 3 responses, got: %v", msgs)
+	}
+
+	capabilities := msgs[0]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	if capabilities["definitionProvider"] != true {
+		t.Errorf("Unexpected capabilities: %v", capabilities)
+	}
+
+	if code := msgs[1]["error"].(map[string]interface{})["code"]; code != float64(codeMethodNotFound) {
+		t.Errorf("Expected method not found error, got: %v", msgs[1])
+	}
+
+	if _, ok := msgs[2]["result"]; !ok || (msgs[2]["result"] != nil) {
+		t.Errorf("Expected null shutdown result, got: %v", msgs[2])
+	}
+
+	if err := NewServer(Options{}).Serve(bytes.NewBufferString(request(0, "exit", nil)), ioutil.Discard); err == nil {
+		t.Errorf("Expected an error when exiting before shutdown")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	msgs := serve(t, Options{},
+		open("untitled:a", "foo\n{{#if a}}\nbar"),
+		open("untitled:b", "{{#if}}x{{/if}}\n{{> missing}}"),
+	)
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"range":    map[string]interface{}{"start": map[string]interface{}{"line": 2.0, "character": 0.0}, "end": map[string]interface{}{"line": 2.0, "character": 3.0}},
+			"severity": 1.0,
+			"code":     "parse",
+			"source":   "raymond",
+			"message":  "Expecting OpenEndBlock, got: 'EOF'",
+		},
+	}
+
+	if diagnostics := msgs[0]["params"].(map[string]interface{})["diagnostics"]; !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("Unexpected diagnostics: %v", diagnostics)
+	}
+
+	// partials can't be checked without directory
+	diagnostics := msgs[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if (len(diagnostics) != 1) || (diagnostics[0].(map[string]interface{})["code"] != "helper-arity") {
+		t.Errorf("Unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestCompletionAndDefinition(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "partials"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "partials", "item.hbs"), []byte("{{name}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := &Schema{}
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"title": {"type": "string"},
+			"users": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "address": {"type": "object", "properties": {"city": {"type": "string"}}}}}}
+		}
+	}`), schema); err != nil {
+		t.Fatal(err)
+	}
+
+	uri := pathURI(filepath.Join(dir, "page.hbs"))
+	text := "{{> partials/item}}{{#each users}}{{#if ok}}{{na}} {{address.}} {{../ti}} {{@root.}}{{/if}}{{/each}}{{lookup t}}{{> p}}"
+
+	msgs := serve(t, Options{Schema: schema},
+		open(uri, text),
+		request(1, "textDocument/definition", at(uri, text, "{{> parti")),
+		request(2, "textDocument/definition", at(uri, text, "{{#ea")),
+		request(3, "textDocument/completion", at(uri, text, "{{na")),
+		request(4, "textDocument/completion", at(uri, text, "{{address.")),
+		request(5, "textDocument/completion", at(uri, text, "{{../ti")),
+		request(6, "textDocument/completion", at(uri, text, "{{@root.")),
+		request(7, "textDocument/completion", at(uri, text, "{{lookup t")),
+		request(8, "textDocument/completion", at(uri, text, "{{> p")),
+		request(9, "textDocument/completion", at(uri, text, "{{> partials/item}}")),
+	)
+
+	expected := []interface{}{map[string]interface{}{
+		"uri":   pathURI(filepath.Join(dir, "partials", "item.hbs")),
+		"range": map[string]interface{}{"start": map[string]interface{}{"line": 0.0, "character": 0.0}, "end": map[string]interface{}{"line": 0.0, "character": 0.0}},
+	}}
+
+	if !reflect.DeepEqual(msgs[1]["result"], expected) {
+		t.Errorf("Unexpected definition: %v", msgs[1]["result"])
+	}
+
+	if msgs[2]["result"] != nil {
+		t.Errorf("Expected no definition, got: %v", msgs[2]["result"])
+	}
+
+	tests := []struct {
+		name     string
+		msg      map[string]interface{}
+		contains []string
+		excludes []string
+	}{
+		{"each items", msgs[3], []string{"address", "name", "each", "if"}, []string{"title"}},
+		{"nested path", msgs[4], []string{"city"}, []string{"name", "if"}},
+		{"parent scope", msgs[5], []string{"title", "users"}, []string{"name"}},
+		{"root", msgs[6], []string{"title", "users"}, []string{"name"}},
+		{"param", msgs[7], []string{"title"}, []string{"lookup", "name"}},
+		{"partial", msgs[8], []string{"partials/item"}, []string{"title"}},
+		{"content", msgs[9], nil, []string{"title", "if"}},
+	}
+
+	for _, test := range tests {
+		found := make(map[string]bool)
+		for _, label := range labels(test.msg["result"]) {
+			found[label] = true
+		}
+
+		for _, label := range test.contains {
+			if !found[label] {
+				t.Errorf("Test '%s' failed - Expected completion %q, got: %v", test.name, label, labels(test.msg["result"]))
+			}
+		}
+
+		for _, label := range test.excludes {
+			if found[label] {
+				t.Errorf("Test '%s' failed - Unexpected completion %q", test.name, label)
+			}
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	t.Parallel()
+
+	doc := &document{text: "a\n😀b\nc"}
+
+	for offset, pos := range map[int]position{0: {0, 0}, 2: {1, 0}, 6: {1, 2}, 7: {1, 3}, 9: {2, 1}} {
+		if result := doc.position(offset); result != pos {
+			t.Errorf("Expected position %v for offset %d, got: %v", pos, offset, result)
+		}
+
+		if result := doc.offset(pos); result != offset {
+			t.Errorf("Expected offset %d for position %v, got: %d", offset, pos, result)
+		}
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	msgs := serve(t, Options{Helpers: []raymond.HelperInfo{{Name: "foo", Variadic: true}}},
+		open("untitled:a", "{{!-- a\nb --}}\n{{foo @index}}"),
+		request(1, "textDocument/semanticTokens/full", map[string]interface{}{"textDocument": map[string]interface{}{"uri": "untitled:a"}}),
+	)
+
+	expected := []interface{}{}
+	for _, n := range []int{0, 0, 7, 0, 0, 1, 0, 6, 0, 0, 1, 0, 2, 1, 0, 0, 2, 3, 3, 0, 0, 4, 6, 4, 1, 0, 6, 2, 1, 0} {
+		expected = append(expected, float64(n))
+	}
+
+	if data := msgs[1]["result"].(map[string]interface{})["data"]; !reflect.DeepEqual(data, expected) {
+		t.Errorf("Unexpected semantic tokens: %v", data)
+	}
+}
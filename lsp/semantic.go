@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+// semanticTokenTypes are the LSP token types of the semantic tokens legend
+var semanticTokenTypes = []string{"comment", "operator", "keyword", "function", "variable", "parameter", "property", "macro", "string", "number"}
+
+// semanticTokenModifiers are the LSP token modifiers of the semantic tokens legend
+var semanticTokenModifiers = []string{"readonly"}
+
+// semanticLegend maps semantic kinds to the indexes of their LSP token type and the bitset of their LSP token modifiers. Content is not highlighted, so that editors keep highlighting it as HTML.
+var semanticLegend = map[raymond.SemanticKind]struct{ tokenType, modifiers int }{
+	raymond.SemanticComment:    {0, 0},
+	raymond.SemanticDelimiter:  {1, 0},
+	raymond.SemanticKeyword:    {2, 0},
+	raymond.SemanticHelper:     {3, 0},
+	raymond.SemanticPath:       {4, 0},
+	raymond.SemanticData:       {4, 1},
+	raymond.SemanticBlockParam: {5, 0},
+	raymond.SemanticHashKey:    {6, 0},
+	raymond.SemanticPartial:    {7, 0},
+	raymond.SemanticString:     {8, 0},
+	raymond.SemanticNumber:     {9, 0},
+	raymond.SemanticBoolean:    {2, 0},
+}
+
+// semanticTokens returns the semantic tokens of given document, in LSP relative encoding
+//
+// Tokens spanning several lines, ie. comments, are split by line, as clients do not have to support multiline tokens.
+func (s *Server) semanticTokens(doc *document) []int {
+	var tokens []raymond.SemanticToken
+
+	if tpl, err := s.template(doc); err == nil {
+		tokens = tpl.SemanticTokens()
+	} else {
+		// invalid documents are highlighted too, with global helpers only
+		tokens, _ = raymond.SemanticTokens(doc.text)
+	}
+
+	result := []int{}
+	prev := position{}
+
+	for _, tok := range tokens {
+		legend, ok := semanticLegend[tok.Kind]
+		if !ok {
+			continue
+		}
+
+		for start := tok.Pos; start < tok.End; {
+			end := tok.End
+			if i := strings.IndexByte(doc.text[start:end], '\n'); i != -1 {
+				end = start + i
+			}
+
+			pos := doc.position(start)
+			length := doc.position(end).Character - pos.Character
+
+			if length > 0 {
+				deltaStart := pos.Character
+				if pos.Line == prev.Line {
+					deltaStart -= prev.Character
+				}
+
+				result = append(result, pos.Line-prev.Line, deltaStart, length, legend.tokenType, legend.modifiers)
+				prev = pos
+			}
+
+			start = end + 1
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,275 @@
+package raymond
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aymerick/raymond/parser"
+)
+
+// maxMemoDepth is the maximum nesting depth of a context hashed to memoize the output of a pure partial
+const maxMemoDepth = 16
+
+// memoKey identifies the output of a pure partial for a given context
+type memoKey struct {
+	partial *partial
+	hash    [sha256.Size]byte
+}
+
+// PartialMemo is a concurrency-safe cache of pure partials outputs, shared by evaluations with the PartialMemo evaluation option.
+//
+// See RegisterPurePartial().
+type PartialMemo struct {
+	maxEntries int
+
+	mutex   sync.Mutex // protects lru and entries
+	lru     *list.List
+	entries map[memoKey]*list.Element
+}
+
+// memoEntry is a memoized partial output
+type memoEntry struct {
+	key    memoKey
+	output string
+}
+
+// NewPartialMemo instanciates a new cache of pure partials outputs, that holds at most given number of outputs, the least recently used one being evicted first. Zero means no limit.
+func NewPartialMemo(maxEntries int) *PartialMemo {
+	return &PartialMemo{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[memoKey]*list.Element),
+	}
+}
+
+// Len returns the number of memoized outputs.
+func (m *PartialMemo) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.lru.Len()
+}
+
+// Purge removes all memoized outputs.
+func (m *PartialMemo) Purge() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.lru.Init()
+	m.entries = make(map[memoKey]*list.Element)
+}
+
+// get returns the memoized output with given key
+func (m *PartialMemo) get(key memoKey) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	elt := m.entries[key]
+	if elt == nil {
+		return "", false
+	}
+
+	m.lru.MoveToFront(elt)
+
+	return elt.Value.(*memoEntry).output, true
+}
+
+// add memoizes given output, evicting least recently used outputs if needed
+func (m *PartialMemo) add(key memoKey, output string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if elt := m.entries[key]; elt != nil {
+		m.lru.MoveToFront(elt)
+		return
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoEntry{key: key, output: output})
+
+	for (m.maxEntries > 0) && (m.lru.Len() > m.maxEntries) {
+		elt := m.lru.Back()
+		m.lru.Remove(elt)
+		delete(m.entries, elt.Value.(*memoEntry).key)
+	}
+}
+
+// RegisterPurePartial registers a global partial whose output only depends on its context. That partial will be available to all templates.
+//
+// The output of a pure partial is memoized for each distinct context it is called with, during an evaluation, or across evaluations with the PartialMemo evaluation option. A pure partial must not use private data, parent contexts, block parameters, nor helpers with side effects.
+func RegisterPurePartial(name string, source string) {
+	partialsMutex.Lock()
+	defer partialsMutex.Unlock()
+
+	if partials.get(name) != nil {
+		panic(fmt.Errorf("Partial already registered: %s", name))
+	}
+
+	p := newPartial(name, source, nil)
+	p.pure = true
+
+	partials.set(parser.Intern(name), p)
+}
+
+// RegisterPurePartial registers a partial for that template, whose output only depends on its context.
+//
+// See the RegisterPurePartial() function.
+func (tpl *Template) RegisterPurePartial(name string, source string) {
+	p := newPartial(name, source, nil)
+	p.pure = true
+
+	tpl.addPartial(p)
+}
+
+// pureProgramStr evaluates the program of given pure partial with current context, or returns its memoized output
+func (v *evalVisitor) pureProgramStr(p *partial, tpl *Template) string {
+	key, ok := v.memoKey(p)
+	if !ok {
+		// context can't be hashed
+		return v.programStr(tpl.program)
+	}
+
+	if v.sharedMemo != nil {
+		if output, ok := v.sharedMemo.get(key); ok {
+			return output
+		}
+	} else if output, ok := v.memo[key]; ok {
+		return output
+	}
+
+	output := v.programStr(tpl.program)
+
+	if v.sharedMemo != nil {
+		v.sharedMemo.add(key, output)
+	} else {
+		if v.memo == nil {
+			v.memo = make(map[memoKey]string)
+		}
+
+		v.memo[key] = output
+	}
+
+	return output
+}
+
+// memoKey computes the memoization key of given partial with current context, and returns false if that context can't be hashed
+func (v *evalVisitor) memoKey(p *partial) (memoKey, bool) {
+	// output depends on the nonce injected into script and style tags
+	buf, ok := appendMemoValue(appendMemoString(v.memoBuf[:0], v.nonce), v.curCtx(), 0)
+	v.memoBuf = buf
+
+	if !ok {
+		return memoKey{}, false
+	}
+
+	return memoKey{partial: p, hash: sha256.Sum256(buf)}, true
+}
+
+// appendMemoValue appends an unambiguous representation of given value to given buffer, and returns false if that value contains functions, channels or too many nesting levels
+//
+// Pointers are followed, so that values that are equal but at different addresses have the same representation.
+func appendMemoValue(buf []byte, val reflect.Value, depth int) ([]byte, bool) {
+	if depth > maxMemoDepth {
+		return buf, false
+	}
+
+	if !val.IsValid() {
+		return append(buf, 'n'), true
+	}
+
+	buf = appendMemoString(buf, val.Type().String())
+
+	switch val.Kind() {
+	case reflect.Bool:
+		buf = append(strconv.AppendBool(buf, val.Bool()), ';')
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf = append(strconv.AppendInt(buf, val.Int(), 10), ';')
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf = append(strconv.AppendUint(buf, val.Uint(), 10), ';')
+	case reflect.Float32, reflect.Float64:
+		buf = append(strconv.AppendUint(buf, math.Float64bits(val.Float()), 16), ';')
+	case reflect.String:
+		buf = appendMemoString(buf, val.String())
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return append(buf, 'n'), true
+		}
+
+		return appendMemoValue(buf, val.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if (val.Kind() == reflect.Slice) && val.IsNil() {
+			return append(buf, 'n'), true
+		}
+
+		buf = append(strconv.AppendInt(buf, int64(val.Len()), 10), ';')
+
+		for i := 0; i < val.Len(); i++ {
+			var ok bool
+			if buf, ok = appendMemoValue(buf, val.Index(i), depth+1); !ok {
+				return buf, false
+			}
+		}
+	case reflect.Map:
+		return appendMemoMap(buf, val, depth)
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			var ok bool
+			if buf, ok = appendMemoValue(buf, val.Field(i), depth+1); !ok {
+				return buf, false
+			}
+		}
+	default:
+		// functions, channels, complex numbers and unsafe pointers
+		return buf, false
+	}
+
+	return buf, true
+}
+
+// appendMemoMap appends an unambiguous representation of given map to given buffer, with entries sorted by key representation
+func appendMemoMap(buf []byte, val reflect.Value, depth int) ([]byte, bool) {
+	if val.IsNil() {
+		return append(buf, 'n'), true
+	}
+
+	entries := make([][]byte, 0, val.Len())
+
+	iter := val.MapRange()
+	for iter.Next() {
+		entry, ok := appendMemoValue(nil, iter.Key(), depth+1)
+		if !ok {
+			return buf, false
+		}
+
+		if entry, ok = appendMemoValue(entry, iter.Value(), depth+1); !ok {
+			return buf, false
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i], entries[j]) < 0 })
+
+	buf = append(strconv.AppendInt(buf, int64(len(entries)), 10), ';')
+
+	for _, entry := range entries {
+		buf = append(strconv.AppendInt(buf, int64(len(entry)), 10), ':')
+		buf = append(buf, entry...)
+	}
+
+	return buf, true
+}
+
+// appendMemoString appends given string to given buffer, prefixed with its length
+func appendMemoString(buf []byte, str string) []byte {
+	buf = strconv.AppendInt(buf, int64(len(str)), 10)
+	buf = append(buf, ':')
+
+	return append(buf, str...)
+}
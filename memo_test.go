@@ -0,0 +1,230 @@
+package raymond
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// number of calls of the testmemocount helper
+var memoCalls int64
+
+func init() {
+	RegisterHelper("testmemocount", func(str string) string {
+		atomic.AddInt64(&memoCalls, 1)
+		return str
+	})
+
+	RegisterPurePartial("testmemoicon", `<i class="{{testmemocount name}}"></i>`)
+}
+
+func TestPurePartial(t *testing.T) {
+	calls := atomic.LoadInt64(&memoCalls)
+
+	tpl := MustParse(`{{#each items}}{{> testmemoicon name=this}}{{/each}}`)
+
+	output := tpl.MustExec(map[string]interface{}{"items": []string{"a", "b", "a", "a", "b"}})
+
+	expected := `<i class="a"></i><i class="b"></i><i class="a"></i><i class="a"></i><i class="b"></i>`
+	if output != expected {
+		t.Errorf("Unexpected output, expected %q, got %q", expected, output)
+	}
+
+	if nb := atomic.LoadInt64(&memoCalls) - calls; nb != 2 {
+		t.Errorf("Expected pure partial to be rendered once per distinct context, got %d renders", nb)
+	}
+
+	// outputs are not shared by evaluations without a PartialMemo
+	tpl.MustExec(map[string]interface{}{"items": []string{"a"}})
+
+	if nb := atomic.LoadInt64(&memoCalls) - calls; nb != 3 {
+		t.Errorf("Expected pure partial to be rendered again, got %d renders", nb)
+	}
+}
+
+func TestPurePartialSharedMemo(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+
+	tpl := MustParse(`{{> icon}}{{> icon}} {{> icon name="x"}}`)
+	tpl.RegisterHelper("count", func(str string) string {
+		calls++
+		return str
+	})
+	tpl.RegisterPurePartial("icon", `[{{count name}}]`)
+
+	memo := NewPartialMemo(0)
+
+	for i := 0; i < 3; i++ {
+		output, err := tpl.ExecWithOptions(map[string]string{"name": "y"}, ExecOptions{PartialMemo: memo})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "[y][y] [x]" {
+			t.Errorf("Unexpected output: %q", output)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected pure partial to be rendered once per distinct context, got %d renders", calls)
+	}
+
+	if memo.Len() != 2 {
+		t.Errorf("Expected 2 memoized outputs, got %d", memo.Len())
+	}
+
+	memo.Purge()
+
+	if memo.Len() != 0 {
+		t.Errorf("Expected no memoized output after purge, got %d", memo.Len())
+	}
+
+	tpl.MustExec(map[string]string{"name": "y"})
+
+	if calls != 4 {
+		t.Errorf("Expected pure partial to be rendered again, got %d renders", calls)
+	}
+}
+
+func TestPartialMemoEviction(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{> item}}`)
+	tpl.RegisterPurePartial("item", `{{name}}`)
+
+	memo := NewPartialMemo(2)
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		if _, err := tpl.ExecWithOptions(map[string]string{"name": name}, ExecOptions{PartialMemo: memo}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if memo.Len() != 2 {
+		t.Fatalf("Expected 2 memoized outputs, got %d", memo.Len())
+	}
+
+	// "b" is the least recently used output
+	for _, name := range []string{"a", "c"} {
+		if key, ok := testMemoKey(tpl, map[string]string{"name": name}); !ok {
+			t.Errorf("Failed to compute memoization key of %q", name)
+		} else if _, ok := memo.get(key); !ok {
+			t.Errorf("Expected output of %q to be memoized", name)
+		}
+	}
+
+	if key, _ := testMemoKey(tpl, map[string]string{"name": "b"}); memo.entries[key] != nil {
+		t.Errorf("Expected output of %q to be evicted", "b")
+	}
+}
+
+// testMemoKey returns the memoization key of the item partial of given template for given context
+func testMemoKey(tpl *Template, ctx interface{}) (memoKey, bool) {
+	v := newEvalVisitor(tpl, ctx, nil)
+	defer v.release()
+
+	return v.memoKey(tpl.findPartial("item"))
+}
+
+type testMemoStruct struct {
+	Name  string
+	Count *int
+}
+
+var memoKeyTests = []struct {
+	name  string
+	a     interface{}
+	b     interface{}
+	equal bool
+}{
+	{"same strings", "1", "1", true},
+	{"int and string", 1, "1", false},
+	{"int and int64", 1, int64(1), false},
+	{"ints", 12, 1, false},
+	{"maps in different orders", map[string]interface{}{"a": 1, "b": "2"}, map[string]interface{}{"b": "2", "a": 1}, true},
+	{"maps with different values", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}, false},
+	{"map and nil map", map[string]int{}, map[string]int(nil), false},
+	{"slices", []interface{}{1, "a"}, []interface{}{1, "a"}, true},
+	{"slices with concatenated values", []interface{}{"ab", "c"}, []interface{}{"a", "bc"}, false},
+	{"pointers to equal values", &testMemoStruct{Name: "a", Count: new(int)}, &testMemoStruct{Name: "a", Count: new(int)}, true},
+	{"structs with different fields", testMemoStruct{Name: "a"}, testMemoStruct{Name: "b"}, false},
+}
+
+func TestMemoKey(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{> item}}`)
+	tpl.RegisterPurePartial("item", `{{name}}`)
+
+	for _, test := range memoKeyTests {
+		keyA, okA := testMemoKey(tpl, test.a)
+		keyB, okB := testMemoKey(tpl, test.b)
+
+		if !okA || !okB {
+			t.Errorf("Test '%s' failed: memoization keys could not be computed", test.name)
+			continue
+		}
+
+		if (keyA == keyB) != test.equal {
+			t.Errorf("Test '%s' failed: expected keys equality to be %t", test.name, test.equal)
+		}
+	}
+}
+
+func TestPurePartialNotHashable(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+
+	tpl := MustParse(`{{> item}}{{> item}}`)
+	tpl.RegisterPurePartial("item", `{{name}}`)
+
+	ctx := map[string]interface{}{
+		"name": func() string {
+			calls++
+			return "a"
+		},
+	}
+
+	if output := tpl.MustExec(ctx); output != "aa" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// context with a function is not memoized
+	if calls != 2 {
+		t.Errorf("Expected partial to be rendered twice, got %d renders", calls)
+	}
+}
+
+func TestPurePartialIndent(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("list:\n  {{> item}}\n{{> item}}\n")
+	tpl.RegisterPurePartial("item", "- a\n- b\n")
+
+	if expected, output := "list:\n  - a\n  - b\n- a\n- b\n", tpl.MustExec(nil); output != expected {
+		t.Errorf("Unexpected output, expected %q, got %q", expected, output)
+	}
+}
+
+func TestPurePartialClone(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+
+	tpl := MustParse(`{{> item}}{{> item}}`)
+	tpl.RegisterHelper("count", func() string {
+		calls++
+		return "x"
+	})
+	tpl.RegisterPurePartial("item", `{{count}}`)
+
+	if output := tpl.Clone().MustExec(nil); output != "xx" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected cloned partial to be pure, got %d renders", calls)
+	}
+}
@@ -0,0 +1,380 @@
+package raymond
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// mustacheDelims are the delimiters of Mustache tags
+type mustacheDelims struct {
+	open  string
+	close string
+}
+
+// default Mustache delimiters
+var defaultMustacheDelims = mustacheDelims{open: "{{", close: "}}"}
+
+// mustacheSection is the raw source of a Mustache section, passed to lambdas
+type mustacheSection struct {
+	raw string
+
+	// delimiters in effect at section start, used to render lambda result
+	delims mustacheDelims
+}
+
+// mustacheKeywords are the names that have a special meaning in Handlebars expressions
+var mustacheKeywords = map[string]bool{
+	"as":        true,
+	"else":      true,
+	"false":     true,
+	"null":      true,
+	"this":      true,
+	"true":      true,
+	"undefined": true,
+}
+
+// newMustacheTemplate instanciates a new Mustache template, starting with given delimiters, without parsing it
+func newMustacheTemplate(source string, delims mustacheDelims) *Template {
+	result := newTemplate(source)
+	result.mustache = &delims
+
+	return result
+}
+
+// mustacheTranslator translates a Mustache template to the equivalent Handlebars template
+//
+// Set delimiters tags are processed, and all tags are written with default delimiters. Comments and set delimiters tags are replaced by empty comments, so that they are still stripped when standalone.
+type mustacheTranslator struct {
+	src    string
+	delims mustacheDelims
+	out    strings.Builder
+
+	// raw source of sections, indexed by the position of their open tag in translated source
+	sections map[int]mustacheSection
+
+	// sections being translated
+	open []openMustacheSection
+}
+
+// openMustacheSection is a section being translated
+type openMustacheSection struct {
+	name   string
+	pos    int
+	start  int
+	delims mustacheDelims
+}
+
+// translateMustache translates given Mustache source, starting with given delimiters, to Handlebars source, and returns the raw source of sections indexed by the position of their open tag in translated source
+func translateMustache(src string, delims mustacheDelims) (string, map[int]mustacheSection, error) {
+	t := &mustacheTranslator{
+		src:      src,
+		delims:   delims,
+		sections: make(map[int]mustacheSection),
+	}
+
+	if err := t.translate(); err != nil {
+		return "", nil, err
+	}
+
+	return t.out.String(), t.sections, nil
+}
+
+// translate translates all tags and contents of source
+func (t *mustacheTranslator) translate() error {
+	pos := 0
+
+	for {
+		i := strings.Index(t.src[pos:], t.delims.open)
+		if i == -1 {
+			t.content(t.src[pos:], false)
+			return nil
+		}
+
+		start := pos + i
+		t.content(t.src[pos:start], true)
+
+		inner := start + len(t.delims.open)
+
+		// {{{name}}} and {{=<% %>=}} tags end with their sigil
+		closeDelim := t.delims.close
+		if inner < len(t.src) {
+			switch t.src[inner] {
+			case '{':
+				closeDelim = "}" + closeDelim
+			case '=':
+				closeDelim = "=" + closeDelim
+			}
+		}
+
+		j := strings.Index(t.src[inner:], closeDelim)
+		if j == -1 {
+			return t.errorf(start, "Unclosed tag")
+		}
+
+		end := inner + j + len(closeDelim)
+
+		if err := t.tag(t.src[inner:inner+j], start, end); err != nil {
+			return err
+		}
+
+		pos = end
+	}
+}
+
+// tag translates a tag, with given content between delimiters, that starts and ends at given positions in source
+func (t *mustacheTranslator) tag(tag string, start, end int) error {
+	var sigil byte
+	if tag != "" {
+		sigil = tag[0]
+	}
+
+	switch sigil {
+	case '!':
+		t.out.WriteString("{{!}}")
+	case '=':
+		delims := strings.Fields(tag[1:])
+		if len(delims) != 2 {
+			return t.errorf(start, "Invalid set delimiters tag: %q", tag)
+		}
+
+		t.delims = mustacheDelims{open: delims[0], close: delims[1]}
+		t.out.WriteString("{{!}}")
+	case '#', '^':
+		name, err := t.name(tag[1:], start)
+		if err != nil {
+			return err
+		}
+
+		t.open = append(t.open, openMustacheSection{
+			name:   strings.TrimSpace(tag[1:]),
+			pos:    t.out.Len(),
+			start:  end,
+			delims: t.delims,
+		})
+
+		t.out.WriteString("{{" + string(sigil) + name + "}}")
+	case '/':
+		name, err := t.name(tag[1:], start)
+		if err != nil {
+			return err
+		}
+
+		// mismatched sections are reported by parser
+		if last := len(t.open) - 1; (last >= 0) && (t.open[last].name == strings.TrimSpace(tag[1:])) {
+			section := t.open[last]
+			t.sections[section.pos] = mustacheSection{raw: t.src[section.start:start], delims: section.delims}
+			t.open = t.open[:last]
+		}
+
+		t.out.WriteString("{{/" + name + "}}")
+	case '>':
+		name := strings.TrimSpace(tag[1:])
+		if strings.HasPrefix(name, "*") {
+			return t.errorf(start, "Dynamic partial names are not supported: %q", tag)
+		}
+
+		if (name == "") || strings.Contains(name, "]") {
+			return t.errorf(start, "Invalid partial name: %q", tag)
+		}
+
+		t.out.WriteString("{{>" + mustacheSegment(name) + "}}")
+	case '&', '{':
+		name, err := t.name(tag[1:], start)
+		if err != nil {
+			return err
+		}
+
+		t.out.WriteString("{{{" + name + "}}}")
+	case '<', '$':
+		return t.errorf(start, "Template inheritance is not supported: %q", tag)
+	default:
+		name, err := t.name(tag, start)
+		if err != nil {
+			return err
+		}
+
+		t.out.WriteString("{{" + name + "}}")
+	}
+
+	return nil
+}
+
+// name translates a Mustache name to a Handlebars path, that never resolves to a helper or to a literal
+func (t *mustacheTranslator) name(name string, pos int) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "." {
+		return name, nil
+	}
+
+	if (name == "") || strings.ContainsAny(name, " \t\r\n]") {
+		return "", t.errorf(pos, "Invalid name: %q", name)
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "" {
+			return "", t.errorf(pos, "Invalid name: %q", name)
+		}
+
+		parts[i] = mustacheSegment(part)
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
+// mustacheSegment returns the Handlebars path segment corresponding to given Mustache name segment, enclosed in brackets unless it is a plain identifier
+func mustacheSegment(part string) string {
+	if !mustacheKeywords[part] && !strings.ContainsAny(part[:1], "+-0123456789") && !strings.ContainsAny(part, " \n\t!\"#%&'()*+,./;<=>@[\\]^`{|}~") {
+		return part
+	}
+
+	return "[" + part + "]"
+}
+
+// content translates given content, followed by a tag if beforeTag is true
+//
+// Handlebars escapes mustaches with backslashes: literal {{ are escaped, and a backslash preceding a mustache is doubled.
+func (t *mustacheTranslator) content(str string, beforeTag bool) {
+	for {
+		i := strings.Index(str, "{{")
+		if i == -1 {
+			break
+		}
+
+		t.out.WriteString(str[:i])
+
+		if strings.HasSuffix(str[:i], "\\") {
+			// separate backslash from escaped mustache
+			t.out.WriteString("\\{{!}}")
+		}
+
+		t.out.WriteString("\\{{")
+		str = str[i+2:]
+	}
+
+	t.out.WriteString(str)
+
+	if beforeTag && strings.HasSuffix(str, "\\") {
+		t.out.WriteString("\\")
+	}
+}
+
+// errorf returns an error at given position of source
+func (t *mustacheTranslator) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("Mustache error on line %d: %s", strings.Count(t.src[:pos], "\n")+1, fmt.Sprintf(format, args...))
+}
+
+// mustacheSections returns the raw source of given program sections, found by the position of their open tag
+func mustacheSections(program *ast.Program, sections map[int]mustacheSection) map[*ast.BlockStatement]mustacheSection {
+	result := make(map[*ast.BlockStatement]mustacheSection)
+
+	var walk func(program *ast.Program)
+
+	walk = func(program *ast.Program) {
+		if program == nil {
+			return
+		}
+
+		for _, node := range program.Body {
+			if block, ok := node.(*ast.BlockStatement); ok {
+				if section, ok := sections[block.Loc.Pos]; ok {
+					result[block] = section
+				}
+
+				walk(block.Program)
+				walk(block.Inverse)
+			}
+		}
+	}
+
+	walk(program)
+
+	return result
+}
+
+//
+// Evaluation
+//
+
+// callLambda calls a function found in context of a Mustache template
+//
+// In a section, a function taking a string receives the raw section source, and in an inverted section the function is not called as lambdas are truthy. A string result is rendered as a template with current context, with the delimiters in effect at section start or with default delimiters.
+func (v *evalVisitor) callLambda(name string, funcVal reflect.Value, options *Options) reflect.Value {
+	expr := v.curExpr()
+	block := v.curBlock()
+
+	if (block == nil) || (block.Expression != expr) {
+		// interpolation
+		result := v.callFunc(name, funcVal, options)
+		if result.IsValid() && (result.Type() == strType) {
+			return reflect.ValueOf(v.renderLambda(result.String(), defaultMustacheDelims))
+		}
+
+		return result
+	}
+
+	if block.Program == nil {
+		// inverted section
+		return reflect.ValueOf(SafeString(""))
+	}
+
+	section := v.code.sections[block]
+
+	if funcType := funcVal.Type(); (funcType.NumIn() == 1) && (funcType.In(0) == strType) {
+		options = newOptions(v, []interface{}{section.raw}, nil)
+	}
+
+	result := v.callFunc(name, funcVal, options)
+	if result.IsValid() && (result.Type() == strType) {
+		// section output is not escaped
+		return reflect.ValueOf(SafeString(v.renderLambda(result.String(), section.delims)))
+	}
+
+	return result
+}
+
+// renderLambda renders given lambda result as a Mustache template, starting with given delimiters, with current context
+func (v *evalVisitor) renderLambda(source string, delims mustacheDelims) string {
+	tpl := newMustacheTemplate(source, delims)
+
+	if err := tpl.parse(); err != nil {
+		v.errPanic(err)
+	}
+
+	code := v.code
+	v.code = tpl.code
+
+	result := v.programStr(tpl.program)
+
+	v.code = code
+
+	return result
+}
+
+// indented returns the template of a Mustache partial with each line of source indented, as standalone partials are indented before being rendered
+func (p *partial) indented(tpl *Template, indent string) (*Template, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if result := p.indents[indent]; result != nil {
+		return result, nil
+	}
+
+	result := newMustacheTemplate(indentLines(tpl.source, indent), *tpl.mustache)
+
+	if err := result.parse(); err != nil {
+		return nil, err
+	}
+
+	if p.indents == nil {
+		p.indents = make(map[string]*Template)
+	}
+
+	p.indents[indent] = result
+
+	return result, nil
+}
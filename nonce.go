@@ -0,0 +1,114 @@
+package raymond
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+// size in bytes of the random part of nonces generated by NewNonce()
+const nonceSize = 16
+
+// NewNonce returns a random nonce suitable for a Content-Security-Policy header, to be used as the Nonce evaluation option.
+//
+// A new nonce must be generated for each response.
+func NewNonce() string {
+	var b [nonceSize]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// nonceAttr returns the nonce attribute injected into script and style tags, or an empty string if no nonce is set
+func nonceAttr(nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+
+	return ` nonce="` + Escape(nonce) + `"`
+}
+
+// nonceOffsets returns the offsets in given content where a nonce attribute must be injected, ie. right after the name of each script and style start tag
+//
+// Tags that already have a nonce attribute are skipped, so that nonces set explicitly in templates (eg. with the nonce helper) are kept.
+func nonceOffsets(str string) []int {
+	var result []int
+
+	for i := 0; i < len(str); i++ {
+		if str[i] != '<' {
+			continue
+		}
+
+		end := nonceTagEnd(str, i+1)
+		if end < 0 {
+			continue
+		}
+
+		// skip tags that already have a nonce attribute
+		attrs := str[end:]
+		if j := strings.IndexByte(attrs, '>'); j >= 0 {
+			attrs = attrs[:j]
+		}
+
+		if strings.Contains(strings.ToLower(attrs), "nonce=") {
+			continue
+		}
+
+		result = append(result, end)
+	}
+
+	return result
+}
+
+// nonceTagEnd returns the offset of the end of the script or style tag name starting at given offset, or -1 if there is no such tag name
+func nonceTagEnd(str string, start int) int {
+	for _, name := range [...]string{"script", "style"} {
+		end := start + len(name)
+		if (end > len(str)) || !strings.EqualFold(str[start:end], name) {
+			continue
+		}
+
+		if end == len(str) {
+			return end
+		}
+
+		switch str[end] {
+		case ' ', '\t', '\n', '\f', '\r', '/', '>':
+			return end
+		}
+	}
+
+	return -1
+}
+
+// injectNonce returns given content with given nonce attribute injected into script and style start tags
+func injectNonce(str string, attr string) string {
+	offsets := nonceOffsets(str)
+	if len(offsets) == 0 {
+		return str
+	}
+
+	var b strings.Builder
+	b.Grow(len(str) + len(offsets)*len(attr))
+
+	start := 0
+	for _, offset := range offsets {
+		b.WriteString(str[start:offset])
+		b.WriteString(attr)
+		start = offset
+	}
+
+	b.WriteString(str[start:])
+
+	return b.String()
+}
+
+// #nonce helper
+//
+// Returns the nonce attribute of current evaluation, to add to tags that are not rewritten automatically, eg. <link rel="stylesheet" {{nonce}}>.
+func nonceHelper(options *Options) SafeString {
+	return SafeString(strings.TrimPrefix(options.eval.nonceAttr, " "))
+}
@@ -0,0 +1,129 @@
+package raymond
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+var nonceTests = []struct {
+	name   string
+	input  string
+	output string
+}{
+	{
+		"script and style tags",
+		`<script>a()</script><SCRIPT src="/a.js"></SCRIPT><style>p {}</style><script/>`,
+		`<script nonce="abc">a()</script><SCRIPT nonce="abc" src="/a.js"></SCRIPT><style nonce="abc">p {}</style><script nonce="abc"/>`,
+	},
+	{
+		"other tags",
+		`<scripts><stylesheet><p>script</p></script>`,
+		`<scripts><stylesheet><p>script</p></script>`,
+	},
+	{
+		"existing nonce",
+		`<script nonce="{{@nonce}}">a()</script><style NONCE=x></style><script>`,
+		`<script nonce="abc">a()</script><style NONCE=x></style><script nonce="abc">`,
+	},
+	{
+		"data and helper",
+		`{{@nonce}} <link rel="stylesheet" {{nonce}}>`,
+		`abc <link rel="stylesheet" nonce="abc">`,
+	},
+	{
+		"blocks",
+		`{{#each items}}<script>{{this}}</script>{{/each}}{{#if true}}<style></style>{{/if}}`,
+		`<script nonce="abc">a</script><script nonce="abc">b</script><style nonce="abc"></style>`,
+	},
+	{
+		"partials",
+		`{{> scripts}}`,
+		`<script nonce="abc" src="/a.js"></script>`,
+	},
+}
+
+func TestNonce(t *testing.T) {
+	t.Parallel()
+
+	ctx := map[string]interface{}{"items": []string{"a", "b"}}
+
+	for _, test := range nonceTests {
+		tpl := MustParse(test.input)
+		tpl.RegisterPartial("scripts", `<script src="/a.js"></script>`)
+
+		output, err := tpl.ExecWithOptions(ctx, ExecOptions{Nonce: "abc"})
+		if err != nil {
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t%s\nexpected:\n\t%s\ngot:\n\t%s", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestNonceDisabled(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`<script>a()</script>{{@nonce}}<link {{nonce}}>`)
+
+	if output := tpl.MustExec(nil); output != `<script>a()</script><link >` {
+		t.Errorf("Unexpected output without nonce: %q", output)
+	}
+}
+
+func TestNonceEscaping(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions(`<script>var a = {{a}};</script><a href="{{a}}">`, ParseOptions{ContextualEscaping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := tpl.ExecWithOptions(map[string]string{"a": "javascript:x"}, ExecOptions{Nonce: `"><x>`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<script nonce="&quot;&gt;&lt;x&gt;">var a =  "javascript:x" ;</script><a href="#ZraymondZ">`
+	if output != expected {
+		t.Errorf("Unexpected output with contextual escaping\nexpected:\n\t%s\ngot:\n\t%s", expected, output)
+	}
+}
+
+func TestNoncePurePartial(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{> scripts}}`)
+	tpl.RegisterPurePartial("scripts", `<script></script>`)
+
+	memo := NewPartialMemo(10)
+
+	for _, nonce := range []string{"a", "b", ""} {
+		expected := `<script nonce="` + nonce + `"></script>`
+		if nonce == "" {
+			expected = `<script></script>`
+		}
+
+		output, err := tpl.ExecWithOptions(nil, ExecOptions{Nonce: nonce, PartialMemo: memo})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != expected {
+			t.Errorf("Unexpected output with nonce %q: %q", nonce, output)
+		}
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	t.Parallel()
+
+	first, second := NewNonce(), NewNonce()
+
+	if b, err := base64.StdEncoding.DecodeString(first); (err != nil) || (len(b) != nonceSize) {
+		t.Errorf("Invalid nonce: %q", first)
+	}
+
+	if first == second {
+		t.Errorf("Same nonce generated twice: %q", first)
+	}
+}
@@ -0,0 +1,252 @@
+package raymond
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//
+// JavaScript numeric semantics, used when the JSNumbers parse option is set
+//
+
+// numberValue returns the float64 value of given Go number, and a boolean set to false if it is not a number
+func numberValue(val reflect.Value) (float64, bool) {
+	val, _ = indirect(val)
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	}
+
+	return 0, false
+}
+
+// jsToNumber converts given value to a number, like the JS Number() function does
+//
+// Nil values are undefined, and values that are neither numbers, booleans nor strings are NaN.
+func jsToNumber(value interface{}) float64 {
+	val, _ := indirect(reflect.ValueOf(value))
+
+	if f, ok := numberValue(val); ok {
+		return f
+	}
+
+	switch val.Kind() {
+	case reflect.Bool:
+		if val.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.String:
+		return jsParseNumber(val.String())
+	}
+
+	return math.NaN()
+}
+
+// jsParseNumber converts given string to a number, like the JS Number() function does
+func jsParseNumber(str string) float64 {
+	str = strings.TrimSpace(str)
+
+	switch str {
+	case "":
+		return 0
+	case "Infinity", "+Infinity":
+		return math.Inf(1)
+	case "-Infinity":
+		return math.Inf(-1)
+	}
+
+	if len(str) > 2 && str[0] == '0' {
+		base := 0
+		switch str[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+
+		if base != 0 {
+			if n, err := strconv.ParseUint(str[2:], base, 64); err == nil {
+				return float64(n)
+			}
+			return math.NaN()
+		}
+	}
+
+	// rejects Go syntaxes unknown to JS: "inf", "nan", hexadecimal floats and underscores
+	if strings.ContainsAny(str, "xXpPnNiI_") {
+		return math.NaN()
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil && !isRangeError(err) {
+		return math.NaN()
+	}
+
+	// out of range values are infinite, like in JS
+	return f
+}
+
+// isRangeError returns true if given error is a strconv out of range error
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && (numErr.Err == strconv.ErrRange)
+}
+
+// jsNumberStr returns the string representation of given number, like the JS Number.prototype.toString() method does
+func jsNumberStr(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		// both 0 and -0
+		return "0"
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// shortest digits that identify the number, and decimal exponent
+	str := strconv.FormatFloat(f, 'e', -1, 64)
+	pos := strings.IndexByte(str, 'e')
+	digits := strings.Replace(str[:pos], ".", "", 1)
+	exp, _ := strconv.Atoi(str[pos+1:])
+
+	// position of decimal point in digits
+	k, n := len(digits), exp+1
+
+	switch {
+	case (k <= n) && (n <= 21):
+		return sign + digits + strings.Repeat("0", n-k)
+	case (0 < n) && (n <= 21):
+		return sign + digits[:n] + "." + digits[n:]
+	case (-6 < n) && (n <= 0):
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+
+	mantissa := digits[:1]
+	if k > 1 {
+		mantissa += "." + digits[1:]
+	}
+
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+
+	return sign + mantissa + "e" + expSign + strconv.Itoa(abs(n-1))
+}
+
+// abs returns the absolute value of given integer
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// jsEqual compares given values like the JS loose equality operator (==) does
+//
+// When one value is a number or a boolean, both values are converted to numbers. Otherwise string representations are compared.
+func jsEqual(a interface{}, b interface{}) bool {
+	if (a == nil) || (b == nil) {
+		return (a == nil) && (b == nil)
+	}
+
+	if isJSPrimitive(a) && isJSPrimitive(b) && (!isString(a) || !isString(b)) {
+		// NaN is not equal to anything
+		return jsToNumber(a) == jsToNumber(b)
+	}
+
+	return jsStr(a) == jsStr(b)
+}
+
+// isJSPrimitive returns true if given value is a number, a boolean or a string
+func isJSPrimitive(value interface{}) bool {
+	val, _ := indirect(reflect.ValueOf(value))
+
+	if _, ok := numberValue(val); ok {
+		return true
+	}
+
+	return (val.Kind() == reflect.Bool) || (val.Kind() == reflect.String)
+}
+
+// isString returns true if given value is a string
+func isString(value interface{}) bool {
+	val, _ := indirect(reflect.ValueOf(value))
+
+	return val.Kind() == reflect.String
+}
+
+// jsStr returns string representation of given value, with numbers formatted like in JS
+func jsStr(value interface{}) string {
+	if f, ok := numberValue(reflect.ValueOf(value)); ok {
+		return jsNumberStr(f)
+	}
+
+	return Str(value)
+}
+
+// jsIntValue returns the integer value of given value converted like the JS Number() function does, and a boolean set to false if it is not an integer
+func jsIntValue(value interface{}) (int, bool) {
+	return floatIntValue(jsToNumber(value))
+}
+
+//
+// Evaluation
+//
+
+// str returns string representation of given value, with numbers formatted like in JS if JSNumbers option is set
+func (v *evalVisitor) str(value interface{}) string {
+	if v.tpl.jsNumbers {
+		return jsStr(value)
+	}
+
+	return Str(value)
+}
+
+// equal compares given values with JS loose equality if JSNumbers option is set, and string representations otherwise
+func (v *evalVisitor) equal(a interface{}, b interface{}) bool {
+	if v.tpl.jsNumbers {
+		return jsEqual(a, b)
+	}
+
+	return Str(a) == Str(b)
+}
+
+// intValue returns the integer value of given value, converted with JS rules if JSNumbers option is set
+func (v *evalVisitor) intValue(value interface{}) (int, bool) {
+	if v.tpl.jsNumbers {
+		return jsIntValue(value)
+	}
+
+	return intValue(value)
+}
+
+// isTrue returns true if given value is truthy, NaN being falsy if JSNumbers option is set
+func (v *evalVisitor) isTrue(value interface{}) bool {
+	if v.tpl.jsNumbers {
+		if f, ok := numberValue(reflect.ValueOf(value)); ok && math.IsNaN(f) {
+			return false
+		}
+	}
+
+	return IsTrue(value)
+}
@@ -0,0 +1,155 @@
+package raymond
+
+import (
+	"math"
+	"testing"
+)
+
+// not a constant, so that sums are computed with float64 precision
+var tenth = 0.1
+
+var jsNumberStrTests = []struct {
+	input  float64
+	output string
+}{
+	{0, "0"},
+	{math.Copysign(0, -1), "0"},
+	{1, "1"},
+	{-25.75, "-25.75"},
+	{tenth + 0.2, "0.30000000000000004"},
+	{1e20, "100000000000000000000"},
+	{1e21, "1e+21"},
+	{1.5e300, "1.5e+300"},
+	{0.000001, "0.000001"},
+	{0.0000001, "1e-7"},
+	{-1.25e-10, "-1.25e-10"},
+	{123456.789, "123456.789"},
+	{float64(9007199254740993), "9007199254740992"},
+	{math.NaN(), "NaN"},
+	{math.Inf(1), "Infinity"},
+	{math.Inf(-1), "-Infinity"},
+}
+
+func TestJSNumberStr(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range jsNumberStrTests {
+		if output := jsNumberStr(test.input); output != test.output {
+			t.Errorf("Failed to format %v\nexpected:\n\t%s\ngot:\n\t%s", test.input, test.output, output)
+		}
+	}
+}
+
+var jsToNumberTests = []struct {
+	input  interface{}
+	output float64
+}{
+	{"", 0},
+	{"  42  ", 42},
+	{"-1.5e3", -1500},
+	{".5", 0.5},
+	{"0x1F", 31},
+	{"0b101", 5},
+	{"Infinity", math.Inf(1)},
+	{"1e400", math.Inf(1)},
+	{true, 1},
+	{false, 0},
+	{uint8(7), 7},
+}
+
+var jsToNaNTests = []interface{}{"inf", "NaN", "1_000", "0x1p-2", "-0x1F", "12px", nil, []int{1}}
+
+func TestJSToNumber(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range jsToNumberTests {
+		if output := jsToNumber(test.input); output != test.output {
+			t.Errorf("Failed to convert %#v\nexpected:\n\t%v\ngot:\n\t%v", test.input, test.output, output)
+		}
+	}
+
+	for _, input := range jsToNaNTests {
+		if output := jsToNumber(input); !math.IsNaN(output) {
+			t.Errorf("Failed to convert %#v\nexpected:\n\tNaN\ngot:\n\t%v", input, output)
+		}
+	}
+}
+
+var jsNumbersTests = []Test{
+	{
+		"formatting",
+		"{{a}} {{b}} {{c}} {{d}} {{e}}",
+		map[string]interface{}{"a": 1e21, "b": tenth + 0.2, "c": int64(9007199254740993), "d": math.Inf(-1), "e": float32(0.5)},
+		nil, nil, nil,
+		"1e+21 0.30000000000000004 9007199254740992 -Infinity 0.5",
+	},
+	{
+		"helper string argument",
+		"{{echo a}}",
+		map[string]interface{}{"a": 1e-7},
+		nil,
+		map[string]interface{}{"echo": func(s string) string { return s }},
+		nil,
+		"1e-7",
+	},
+	{
+		"equal",
+		`{{#equal a "1.0"}}a{{/equal}}{{#equal b 1}}b{{/equal}}{{#equal c ""}}c{{/equal}}{{#equal d d}}d{{/equal}}`,
+		map[string]interface{}{"a": 1, "b": true, "c": 0, "d": math.NaN()},
+		nil, nil, nil,
+		"abc",
+	},
+	{
+		"switch",
+		`{{#switch a}}{{#case "2"}}two{{/case}}{{#default}}other{{/default}}{{/switch}}`,
+		map[string]interface{}{"a": 2.0},
+		nil, nil, nil,
+		"two",
+	},
+	{
+		"times and range",
+		`{{#times a}}{{@index}}{{/times}} {{#range "1" b " 2 "}}{{this}}{{/range}}`,
+		map[string]interface{}{"a": "3", "b": 6.0},
+		nil, nil, nil,
+		"012 135",
+	},
+	{
+		"NaN is falsy",
+		"{{#if a}}yes{{else}}no{{/if}} {{#unless a}}no{{/unless}} {{#a}}yes{{else}}no{{/a}}",
+		map[string]interface{}{"a": math.NaN()},
+		nil, nil, nil,
+		"no no no",
+	},
+}
+
+func TestJSNumbers(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range jsNumbersTests {
+		tpl, err := ParseWithOptions(test.input, ParseOptions{JSNumbers: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, helper := range test.helpers {
+			tpl.RegisterHelper(name, helper)
+		}
+
+		for _, tpl := range []*Template{tpl, tpl.Clone()} {
+			if output := tpl.MustExec(test.data); output != test.output {
+				t.Errorf("Test '%s' failed\nexpected:\n\t%s\ngot:\n\t%s", test.name, test.output, output)
+			}
+		}
+	}
+}
+
+func TestJSNumbersDisabled(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{a}} {{#equal b "1.0"}}equal{{/equal}}`)
+
+	expected := "1000000000000000000000 "
+	if output := tpl.MustExec(map[string]interface{}{"a": 1e21, "b": 1}); output != expected {
+		t.Errorf("Unexpected output\nexpected:\n\t%s\ngot:\n\t%s", expected, output)
+	}
+}
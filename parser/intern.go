@@ -0,0 +1,15 @@
+//go:build go1.23
+// +build go1.23
+
+package parser
+
+import "unique"
+
+// Intern returns the canonical instance of given string.
+//
+// The parser interns identifiers, path segments and helper names, so that identical names share memory across all parsed templates. Registering helpers and partials with interned names makes name lookups faster, as the comparison of identical strings stops at their pointers.
+//
+// Interned strings are released when no longer referenced.
+func Intern(s string) string {
+	return unique.Make(s).Value()
+}
@@ -0,0 +1,9 @@
+//go:build !go1.23
+// +build !go1.23
+
+package parser
+
+// Intern returns given string: interning needs the unique package, available with Go 1.23+.
+func Intern(s string) string {
+	return s
+}
@@ -0,0 +1,52 @@
+//go:build go1.23
+// +build go1.23
+
+package parser
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// sameString returns true if given strings share the same memory
+func sameString(a, b string) bool {
+	return (a == b) && (unsafe.StringData(a) == unsafe.StringData(b))
+}
+
+func TestIntern(t *testing.T) {
+	t.Parallel()
+
+	prog1, err := Parse(`{{#each people as |person|}}{{person.name}}{{/each}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog2, err := Parse(`{{foo bar=person.name}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path1 := prog1.Body[0].(*ast.BlockStatement).Program.Body[0].(*ast.MustacheStatement).Expression.Path.(*ast.PathExpression)
+	pair := prog2.Body[0].(*ast.MustacheStatement).Expression.Hash.Pairs[0]
+	path2 := pair.Val.(*ast.PathExpression)
+
+	if !sameString(path1.Original, path2.Original) {
+		t.Errorf("Path not interned: %q", path1.Original)
+	}
+
+	for i := range path1.Parts {
+		if !sameString(path1.Parts[i], path2.Parts[i]) {
+			t.Errorf("Path segment not interned: %q", path1.Parts[i])
+		}
+	}
+
+	if blockParam := prog1.Body[0].(*ast.BlockStatement).Program.BlockParams[0]; !sameString(blockParam, path1.Parts[0]) {
+		t.Errorf("Block param not interned: %q", blockParam)
+	}
+
+	if !sameString(pair.Key, Intern("bar")) {
+		t.Errorf("Hash key not interned: %q", pair.Key)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build !tinygo && !raymond_tiny
+
+package parser
+
+import "regexp"
+
+var (
+	rOpenAmp = regexp.MustCompile(`^\{\{~?&`)
+
+	rTrimLeft         = regexp.MustCompile(`^[ \t]*\r?\n?`)
+	rTrimLeftMultiple = regexp.MustCompile(`^\s+`)
+
+	rTrimRight         = regexp.MustCompile(`[ \t]+$`)
+	rTrimRightMultiple = regexp.MustCompile(`\s+$`)
+
+	rPrevWhitespace      = regexp.MustCompile(`\r?\n\s*?$`)
+	rPrevWhitespaceStart = regexp.MustCompile(`(^|\r?\n)\s*?$`)
+
+	rNextWhitespace    = regexp.MustCompile(`^\s*?\r?\n`)
+	rNextWhitespaceEnd = regexp.MustCompile(`^\s*?(\r?\n|$)`)
+
+	rPartialIndent = regexp.MustCompile(`([ \t]+$)`)
+)
+
+// isOpenAmp returns true if given open mustache token is {{&
+func isOpenAmp(val string) bool {
+	return rOpenAmp.MatchString(val)
+}
+
+// trimLeft removes the leading whitespaces of given content up to the first newline included, or all of them if multiple is true
+func trimLeft(s string, multiple bool) string {
+	r := rTrimLeft
+	if multiple {
+		r = rTrimLeftMultiple
+	}
+
+	return r.ReplaceAllString(s, "")
+}
+
+// trimRight removes the trailing spaces and tabs of given content, or all trailing whitespaces if multiple is true
+func trimRight(s string, multiple bool) string {
+	r := rTrimRight
+	if multiple {
+		r = rTrimRightMultiple
+	}
+
+	return r.ReplaceAllString(s, "")
+}
+
+// endsWithBlankLine returns true if given content ends with a newline followed by whitespaces, or is only whitespaces if start is true
+func endsWithBlankLine(s string, start bool) bool {
+	r := rPrevWhitespace
+	if start {
+		r = rPrevWhitespaceStart
+	}
+
+	return r.MatchString(s)
+}
+
+// startsWithBlankLine returns true if given content starts with whitespaces followed by a newline, or is only whitespaces if end is true
+func startsWithBlankLine(s string, end bool) bool {
+	r := rNextWhitespace
+	if end {
+		r = rNextWhitespaceEnd
+	}
+
+	return r.MatchString(s)
+}
+
+// partialIndent returns the trailing spaces and tabs of given content
+func partialIndent(s string) string {
+	return rPartialIndent.FindString(s)
+}
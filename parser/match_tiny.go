@@ -0,0 +1,55 @@
+//go:build tinygo || raymond_tiny
+
+package parser
+
+import "strings"
+
+// Hand-rolled equivalents of the regular expressions of match.go, as the regexp package is costly on TinyGo
+
+// isOpenAmp returns true if given open mustache token is {{&
+func isOpenAmp(val string) bool {
+	return strings.HasPrefix(val, "{{&") || strings.HasPrefix(val, "{{~&")
+}
+
+// trimLeft removes the leading whitespaces of given content up to the first newline included, or all of them if multiple is true
+func trimLeft(s string, multiple bool) string {
+	if multiple {
+		return strings.TrimLeft(s, spaceChars)
+	}
+
+	s = strings.TrimLeft(s, " \t")
+	s = strings.TrimPrefix(s, "\r")
+
+	return strings.TrimPrefix(s, "\n")
+}
+
+// trimRight removes the trailing spaces and tabs of given content, or all trailing whitespaces if multiple is true
+func trimRight(s string, multiple bool) string {
+	if multiple {
+		return strings.TrimRight(s, spaceChars)
+	}
+
+	return strings.TrimRight(s, " \t")
+}
+
+// endsWithBlankLine returns true if given content ends with a newline followed by whitespaces, or is only whitespaces if start is true
+func endsWithBlankLine(s string, start bool) bool {
+	trimmed := strings.TrimRight(s, spaceChars)
+
+	return (start && (trimmed == "")) || strings.Contains(s[len(trimmed):], "\n")
+}
+
+// startsWithBlankLine returns true if given content starts with whitespaces followed by a newline, or is only whitespaces if end is true
+func startsWithBlankLine(s string, end bool) bool {
+	trimmed := strings.TrimLeft(s, spaceChars)
+
+	return (end && (trimmed == "")) || strings.Contains(s[:len(s)-len(trimmed)], "\n")
+}
+
+// partialIndent returns the trailing spaces and tabs of given content
+func partialIndent(s string) string {
+	return s[len(strings.TrimRight(s, " \t")):]
+}
+
+// spaceChars are the whitespaces matched by \s in regular expressions
+const spaceChars = " \t\n\f\r"
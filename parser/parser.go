@@ -3,7 +3,6 @@ package parser
 
 import (
 	"fmt"
-	"regexp"
 	"runtime"
 	"strconv"
 
@@ -28,27 +27,69 @@ type parser struct {
 
 	// All tokens have been retreieved from lexer
 	lexOver bool
+
+	// Nodes allocator, nil to allocate nodes individually
+	arena *ast.Arena
 }
 
-var (
-	rOpenComment  = regexp.MustCompile(`^\{\{~?!-?-?`)
-	rCloseComment = regexp.MustCompile(`-?-?~?\}\}$`)
-	rOpenAmp      = regexp.MustCompile(`^\{\{~?&`)
-)
+// Options represents the options used to parse an input.
+type Options struct {
+	// Arena allocates all nodes, nil to allocate them individually
+	Arena *ast.Arena
+
+	// MaxContentSize is the maximum size in bytes of content nodes, zero meaning no limit. Larger content is split in several adjacent content nodes: see lexer.Options.
+	MaxContentSize int
+
+	// InvalidUTF8 defines how invalid UTF-8 sequences of input are handled: see lexer.Options.
+	InvalidUTF8 lexer.UTF8Policy
+
+	// MaxInputBytes is the maximum size in bytes of input, zero meaning no limit. Parsing a larger input fails: see lexer.Options.
+	MaxInputBytes int
+
+	// MaxTokens is the maximum number of tokens of input, zero meaning no limit. Parsing an input with more tokens fails: see lexer.Options.
+	MaxTokens int
+
+	// MaxSubexpressionDepth is the maximum number of nested subexpressions in a mustache, zero meaning lexer.DefaultMaxSubexpressionDepth and a negative value no limit. Parsing an input with more nested subexpressions fails: see lexer.Options.
+	MaxSubexpressionDepth int
+
+	// StringEscapes unescapes the escape sequences of string literals, eg. \n: see lexer.Options.
+	StringEscapes bool
+}
 
 // new instanciates a new parser
-func new(input string) *parser {
+func new(input string, opts Options) *parser {
 	return &parser{
-		lex: lexer.Scan(input),
+		lex: lexer.ScanWithOptions(input, lexer.Options{
+			MaxContentSize:        opts.MaxContentSize,
+			InvalidUTF8:           opts.InvalidUTF8,
+			MaxInputBytes:         opts.MaxInputBytes,
+			MaxTokens:             opts.MaxTokens,
+			MaxSubexpressionDepth: opts.MaxSubexpressionDepth,
+			StringEscapes:         opts.StringEscapes,
+		}),
+		arena: opts.Arena,
 	}
 }
 
 // Parse analyzes given input and returns the AST root node.
 func Parse(input string) (result *ast.Program, err error) {
+	return ParseWithArena(input, nil)
+}
+
+// ParseWithArena analyzes given input and returns the AST root node, with all nodes allocated from given arena.
+//
+// A nil arena allocates nodes individually.
+func ParseWithArena(input string, arena *ast.Arena) (result *ast.Program, err error) {
+	return ParseWithOptions(input, Options{Arena: arena})
+}
+
+// ParseWithOptions analyzes given input with given options, and returns the AST root node.
+func ParseWithOptions(input string, opts Options) (result *ast.Program, err error) {
 	// recover error
 	defer errRecover(&err)
 
-	parser := new(input)
+	parser := new(input, opts)
+	defer parser.lex.Release()
 
 	// parse
 	result = parser.parseProgram()
@@ -82,9 +123,23 @@ func errRecover(errp *error) {
 	}
 }
 
+// Error is the error returned when parsing fails.
+type Error struct {
+	// Line is the line of error in template source
+	Line int
+
+	// Message describes the error, and may include parts of template source
+	Message string
+}
+
+// Error implements the error interface.
+func (err *Error) Error() string {
+	return fmt.Sprintf("Parse error on line %d:\n%s", err.Line, err.Message)
+}
+
 // errPanic panics
 func errPanic(err error, line int) {
-	panic(fmt.Errorf("Parse error on line %d:\n%s", line, err))
+	panic(&Error{Line: line, Message: err.Error()})
 }
 
 // errNode panics with given node infos
@@ -104,7 +159,7 @@ func errExpected(expect lexer.TokenKind, tok *lexer.Token) {
 
 // program : statement*
 func (p *parser) parseProgram() *ast.Program {
-	result := ast.NewProgram(p.next().Pos, p.next().Line)
+	result := p.arena.NewProgram(p.next().Pos, p.next().Line)
 
 	for p.isStatement() {
 		result.AddStatement(p.parseStatement())
@@ -171,7 +226,7 @@ func (p *parser) parseContent() *ast.ContentStatement {
 		errExpected(lexer.TokenContent, tok)
 	}
 
-	return ast.NewContentStatement(tok.Pos, tok.Line, tok.Val)
+	return p.arena.NewContentStatement(tok.Pos, tok.Line, tok.Val)
 }
 
 // COMMENT
@@ -179,11 +234,8 @@ func (p *parser) parseComment() *ast.CommentStatement {
 	// COMMENT
 	tok := p.shift()
 
-	value := rOpenComment.ReplaceAllString(tok.Val, "")
-	value = rCloseComment.ReplaceAllString(value, "")
-
-	result := ast.NewCommentStatement(tok.Pos, tok.Line, value)
-	result.Strip = ast.NewStripForStr(tok.Val)
+	result := p.arena.NewCommentStatement(tok.Pos, tok.Line, tok.Text)
+	result.Strip = p.arena.NewStripFlags(tok.StripBefore, tok.StripAfter)
 
 	return result
 }
@@ -208,7 +260,7 @@ func (p *parser) parseExpressionParamsHash() ([]ast.Node, *ast.Hash) {
 
 // helperName param* hash?
 func (p *parser) parseExpression(tok *lexer.Token) *ast.Expression {
-	result := ast.NewExpression(tok.Pos, tok.Line)
+	result := p.arena.NewExpression(tok.Pos, tok.Line)
 
 	// helperName
 	result.Path = p.parseHelperName()
@@ -226,7 +278,7 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 	// OPEN_RAW_BLOCK
 	tok := p.shift()
 
-	result := ast.NewBlockStatement(tok.Pos, tok.Line)
+	result := p.arena.NewBlockStatement(tok.Pos, tok.Line)
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -243,9 +295,14 @@ func (p *parser) parseRawBlock() *ast.BlockStatement {
 	// @todo Is content mandatory in a raw block ?
 	content := p.parseContent()
 
-	program := ast.NewProgram(tok.Pos, tok.Line)
+	program := p.arena.NewProgram(tok.Pos, tok.Line)
 	program.AddStatement(content)
 
+	// large content is split in several tokens
+	for p.have(1) && p.next().Kind == lexer.TokenContent {
+		program.AddStatement(p.parseContent())
+	}
+
 	result.Program = program
 
 	// OPEN_END_RAW_BLOCK
@@ -346,7 +403,7 @@ func (p *parser) parseInverse() *ast.BlockStatement {
 func (p *parser) parseOpenBlockExpression(tok *lexer.Token) (*ast.BlockStatement, []string) {
 	var blockParams []string
 
-	result := ast.NewBlockStatement(tok.Pos, tok.Line)
+	result := p.arena.NewBlockStatement(tok.Pos, tok.Line)
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -368,7 +425,7 @@ func (p *parser) parseInverseChain() *ast.Program {
 		return p.parseInverseAndProgram()
 	}
 
-	result := ast.NewProgram(p.next().Pos, p.next().Line)
+	result := p.arena.NewProgram(p.next().Pos, p.next().Line)
 
 	// openInverseChain
 	block, blockParams := p.parseOpenBlock()
@@ -404,7 +461,7 @@ func (p *parser) parseInverseAndProgram() *ast.Program {
 
 	// program
 	result := p.parseProgram()
-	result.Strip = ast.NewStripForStr(tok.Val)
+	result.Strip = p.arena.NewStripFlags(tok.StripBefore, tok.StripAfter)
 
 	return result
 }
@@ -425,7 +482,7 @@ func (p *parser) parseOpenBlock() (*ast.BlockStatement, []string) {
 		errExpected(lexer.TokenClose, tokClose)
 	}
 
-	result.OpenStrip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.OpenStrip = p.arena.NewStripFlags(tok.StripBefore, tokClose.StripAfter)
 
 	// named returned values
 	return result, blockParams
@@ -458,7 +515,7 @@ func (p *parser) parseCloseBlock(block *ast.BlockStatement) {
 		errExpected(lexer.TokenClose, tokClose)
 	}
 
-	block.CloseStrip = ast.NewStrip(tok.Val, tokClose.Val)
+	block.CloseStrip = p.arena.NewStripFlags(tok.StripBefore, tokClose.StripAfter)
 }
 
 // mustache : OPEN helperName param* hash? CLOSE
@@ -473,11 +530,11 @@ func (p *parser) parseMustache() *ast.MustacheStatement {
 	}
 
 	unescaped := false
-	if (tok.Kind == lexer.TokenOpenUnescaped) || (rOpenAmp.MatchString(tok.Val)) {
+	if (tok.Kind == lexer.TokenOpenUnescaped) || isOpenAmp(tok.Val) {
 		unescaped = true
 	}
 
-	result := ast.NewMustacheStatement(tok.Pos, tok.Line, unescaped)
+	result := p.arena.NewMustacheStatement(tok.Pos, tok.Line, unescaped)
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -488,7 +545,7 @@ func (p *parser) parseMustache() *ast.MustacheStatement {
 		errExpected(closeToken, tokClose)
 	}
 
-	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.Strip = p.arena.NewStripFlags(tok.StripBefore, tokClose.StripAfter)
 
 	return result
 }
@@ -498,7 +555,7 @@ func (p *parser) parsePartial() *ast.PartialStatement {
 	// OPEN_PARTIAL
 	tok := p.shift()
 
-	result := ast.NewPartialStatement(tok.Pos, tok.Line)
+	result := p.arena.NewPartialStatement(tok.Pos, tok.Line)
 
 	// partialName
 	result.Name = p.parsePartialName()
@@ -512,7 +569,7 @@ func (p *parser) parsePartial() *ast.PartialStatement {
 		errExpected(lexer.TokenClose, tokClose)
 	}
 
-	result.Strip = ast.NewStrip(tok.Val, tokClose.Val)
+	result.Strip = p.arena.NewStripFlags(tok.StripBefore, tokClose.StripAfter)
 
 	return result
 }
@@ -554,7 +611,7 @@ func (p *parser) parseSexpr() *ast.SubExpression {
 	// OPEN_SEXPR
 	tok := p.shift()
 
-	result := ast.NewSubExpression(tok.Pos, tok.Line)
+	result := p.arena.NewSubExpression(tok.Pos, tok.Line)
 
 	// helperName param* hash?
 	result.Expression = p.parseExpression(tok)
@@ -578,7 +635,7 @@ func (p *parser) parseHash() *ast.Hash {
 
 	firstLoc := pairs[0].Location()
 
-	result := ast.NewHash(firstLoc.Pos, firstLoc.Line)
+	result := p.arena.NewHash(firstLoc.Pos, firstLoc.Line)
 	result.Pairs = pairs
 
 	return result
@@ -600,8 +657,8 @@ func (p *parser) parseHashSegment() *ast.HashPair {
 	// param
 	param := p.parseParam()
 
-	result := ast.NewHashPair(tok.Pos, tok.Line)
-	result.Key = tok.Val
+	result := p.arena.NewHashPair(tok.Pos, tok.Line)
+	result.Key = Intern(tok.Val)
 	result.Val = param
 
 	return result
@@ -616,7 +673,7 @@ func (p *parser) parseBlockParams() []string {
 
 	// ID+
 	for p.isID() {
-		result = append(result, p.shift().Val)
+		result = append(result, Intern(p.shift().Val))
 	}
 
 	if len(result) == 0 {
@@ -642,17 +699,17 @@ func (p *parser) parseHelperName() ast.Node {
 	case lexer.TokenBoolean:
 		// BOOLEAN
 		p.shift()
-		result = ast.NewBooleanLiteral(tok.Pos, tok.Line, (tok.Val == "true"), tok.Val)
+		result = p.arena.NewBooleanLiteral(tok.Pos, tok.Line, (tok.Val == "true"), tok.Val)
 	case lexer.TokenNumber:
 		// NUMBER
 		p.shift()
 
 		val, isInt := parseNumber(tok)
-		result = ast.NewNumberLiteral(tok.Pos, tok.Line, val, isInt, tok.Val)
+		result = p.arena.NewNumberLiteral(tok.Pos, tok.Line, val, isInt, tok.Val)
 	case lexer.TokenString:
 		// STRING
 		p.shift()
-		result = ast.NewStringLiteral(tok.Pos, tok.Line, tok.Val)
+		result = p.arena.NewStringLiteral(tok.Pos, tok.Line, tok.Val)
 	case lexer.TokenData:
 		// dataName
 		result = p.parseDataName()
@@ -723,8 +780,8 @@ func (p *parser) parsePath(data bool) *ast.PathExpression {
 		errExpected(lexer.TokenID, tok)
 	}
 
-	result := ast.NewPathExpression(tok.Pos, tok.Line, data)
-	result.Part(tok.Val)
+	result := p.arena.NewPathExpression(tok.Pos, tok.Line, data)
+	result.Part(Intern(tok.Val))
 
 	for p.isPathSep() {
 		// SEP
@@ -737,7 +794,7 @@ func (p *parser) parsePath(data bool) *ast.PathExpression {
 			errExpected(lexer.TokenID, tok)
 		}
 
-		result.Part(tok.Val)
+		result.Part(Intern(tok.Val))
 
 		if len(result.Parts) > 0 {
 			switch tok.Val {
@@ -747,6 +804,8 @@ func (p *parser) parsePath(data bool) *ast.PathExpression {
 		}
 	}
 
+	result.Original = Intern(result.Original)
+
 	return result
 }
 
@@ -763,6 +822,15 @@ func (p *parser) ensure(index int) {
 		// fetch next token
 		tok := p.lex.NextToken()
 
+		switch tok.Kind {
+		case lexer.TokenNull, lexer.TokenUndefined:
+			// the AST has no null and undefined literals yet, so they are looked up as paths
+			tok.Kind = lexer.TokenID
+		case lexer.TokenChainHelper:
+			// the helper name of an inverse chain is a path, like the one of a block
+			tok.Kind = lexer.TokenID
+		}
+
 		// queue it
 		p.tokens = append(p.tokens, &tok)
 
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/aymerick/raymond/ast"
@@ -39,6 +40,7 @@ var parserTests = []parserTest{
 	{"parses mustaches with string parameters", `{{foo bar "baz" }}`, "{{ PATH:foo [PATH:bar, \"baz\"] }}\n"},
 	{"parses mustaches with NUMBER parameters", `{{foo 1}}`, "{{ PATH:foo [NUMBER{1}] }}\n"},
 	{"parses mustaches with BOOLEAN parameters (1)", `{{foo true}}`, "{{ PATH:foo [BOOLEAN{true}] }}\n"},
+	{"parses mustaches with null and undefined parameters as paths", `{{foo null bar=undefined}}`, "{{ PATH:foo [PATH:null] HASH{bar=PATH:undefined} }}\n"},
 	{"parses mustaches with BOOLEAN parameters (2)", `{{foo false}}`, "{{ PATH:foo [BOOLEAN{false}] }}\n"},
 	{"parses mustaches with DATA parameters", `{{foo @bar}}`, "{{ PATH:foo [@PATH:bar] }}\n"},
 
@@ -101,6 +103,87 @@ func TestParser(t *testing.T) {
 	}
 }
 
+func TestParserArena(t *testing.T) {
+	t.Parallel()
+
+	arena := ast.NewArena()
+
+	for _, test := range parserTests {
+		output := ""
+
+		node, err := ParseWithArena(test.input, arena)
+		if err == nil {
+			output = ast.Print(node)
+		}
+
+		if (err != nil) || (test.output != output) {
+			t.Errorf("Test '%s' failed\ninput:\n\t'%s'\nexpected\n\t%q\ngot\n\t%q\nerror:\n\t%s", test.name, test.input, test.output, output, err)
+		}
+	}
+
+	if arena.Len() == 0 {
+		t.Errorf("No node allocated from arena")
+	}
+}
+
+var parserContentSizeTests = []parserTest{
+	{"content chunks", "foo\nbar\nbaz {{foo}}", "CONTENT[ 'foo\n' ]\nCONTENT[ 'bar\n' ]\nCONTENT[ 'baz ' ]\n{{ PATH:foo [] }}\n"},
+	{"raw block chunks", "{{{{raw}}}}foo\nbar\nbaz{{{{/raw}}}}", "BLOCK:\n  PATH:raw []\n  PROGRAM:\n    CONTENT[ 'foo\n' ]\n    CONTENT[ 'bar\n' ]\n    CONTENT[ 'baz' ]\n"},
+}
+
+func TestParserMaxContentSize(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range parserContentSizeTests {
+		output := ""
+
+		node, err := ParseWithOptions(test.input, Options{MaxContentSize: 4})
+		if err == nil {
+			output = ast.Print(node)
+		}
+
+		if (err != nil) || (test.output != output) {
+			t.Errorf("Test '%s' failed\ninput:\n\t'%s'\nexpected\n\t%q\ngot\n\t%q\nerror:\n\t%s", test.name, test.input, test.output, output, err)
+		}
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{MaxInputBytes: 8}, "Input is larger than 8 bytes"},
+		{Options{MaxTokens: 5}, "More than 5 tokens"},
+		{Options{MaxSubexpressionDepth: 1}, "More than 1 nested subexpressions"},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseWithOptions("{{foo}} {{bar (baz (qux))}}", test.opts); (err == nil) || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected error %q, got %v", test.expected, err)
+		}
+	}
+
+	if _, err := ParseWithOptions("{{foo}} {{bar (baz (qux))}}", Options{MaxInputBytes: 27, MaxTokens: 13, MaxSubexpressionDepth: 2}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestParserStringEscapes(t *testing.T) {
+	t.Parallel()
+
+	node, err := ParseWithOptions(`{{foo "a\tb"}}`, Options{StringEscapes: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output := ast.Print(node); output != "{{ PATH:foo [\"a\tb\"] }}\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
 var parserErrorTests = []parserTest{
 	{"lexer error", `{{! unclosed comment`, "Lexer error"},
 	{"syntax error", `foo{{^}}`, "Syntax error"},
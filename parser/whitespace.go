@@ -1,8 +1,6 @@
 package parser
 
 import (
-	"regexp"
-
 	"github.com/aymerick/raymond/ast"
 )
 
@@ -14,22 +12,6 @@ type whitespaceVisitor struct {
 	isRootSeen bool
 }
 
-var (
-	rTrimLeft         = regexp.MustCompile(`^[ \t]*\r?\n?`)
-	rTrimLeftMultiple = regexp.MustCompile(`^\s+`)
-
-	rTrimRight         = regexp.MustCompile(`[ \t]+$`)
-	rTrimRightMultiple = regexp.MustCompile(`\s+$`)
-
-	rPrevWhitespace      = regexp.MustCompile(`\r?\n\s*?$`)
-	rPrevWhitespaceStart = regexp.MustCompile(`(^|\r?\n)\s*?$`)
-
-	rNextWhitespace    = regexp.MustCompile(`^\s*?\r?\n`)
-	rNextWhitespaceEnd = regexp.MustCompile(`^\s*?(\r?\n|$)`)
-
-	rPartialIndent = regexp.MustCompile(`([ \t]+$)`)
-)
-
 // newWhitespaceVisitor instanciates a new whitespaceVisitor
 func newWhitespaceVisitor() *whitespaceVisitor {
 	return &whitespaceVisitor{}
@@ -64,12 +46,7 @@ func omitRight(body []ast.Node, i int, multiple bool) {
 
 	original := node.Value
 
-	r := rTrimLeft
-	if multiple {
-		r = rTrimLeftMultiple
-	}
-
-	node.Value = r.ReplaceAllString(node.Value, "")
+	node.Value = trimLeft(node.Value, multiple)
 
 	node.RightStripped = (original != node.Value)
 }
@@ -96,12 +73,7 @@ func omitLeft(body []ast.Node, i int, multiple bool) bool {
 
 	original := node.Value
 
-	r := rTrimRight
-	if multiple {
-		r = rTrimRightMultiple
-	}
-
-	node.Value = r.ReplaceAllString(node.Value, "")
+	node.Value = trimRight(node.Value, multiple)
 
 	node.LeftStripped = (original != node.Value)
 
@@ -125,12 +97,7 @@ func isPrevWhitespaceProgram(body []ast.Node, i int, isRoot bool) bool {
 			return true
 		}
 
-		r := rPrevWhitespaceStart
-		if (i > 1) || !isRoot {
-			r = rPrevWhitespace
-		}
-
-		return r.MatchString(node.Value)
+		return endsWithBlankLine(node.Value, (i <= 1) && isRoot)
 	}
 
 	return false
@@ -153,12 +120,7 @@ func isNextWhitespaceProgram(body []ast.Node, i int, isRoot bool) bool {
 			return true
 		}
 
-		r := rNextWhitespaceEnd
-		if (i+2 > len(body)) || !isRoot {
-			r = rNextWhitespace
-		}
-
-		return r.MatchString(node.Value)
+		return startsWithBlankLine(node.Value, (i+2 <= len(body)) && isRoot)
 	}
 
 	return false
@@ -203,7 +165,7 @@ func (v *whitespaceVisitor) VisitProgram(program *ast.Program) interface{} {
 					// Pull out the whitespace from the final line
 					if i > 0 {
 						if prevContent, ok := body[i-1].(*ast.ContentStatement); ok {
-							partial.Indent = rPartialIndent.FindString(prevContent.Original)
+							partial.Indent = partialIndent(prevContent.Original)
 						}
 					}
 				}
@@ -3,6 +3,8 @@ package raymond
 import (
 	"fmt"
 	"sync"
+
+	"github.com/aymerick/raymond/parser"
 )
 
 // partial represents a partial template
@@ -10,18 +12,24 @@ type partial struct {
 	name   string
 	source string
 	tpl    *Template
-}
 
-// partials stores all global partials
-var partials map[string]*partial
+	// output only depends on context, and is memoized
+	pure bool
 
-// protects global partials
-var partialsMutex sync.RWMutex
+	// source is parsed as Mustache, and templates of standalone partials, indexed by indentation
+	mustache bool
+	indents  map[string]*Template
 
-func init() {
-	partials = make(map[string]*partial)
+	// protects lazy parsing of tpl
+	mutex sync.Mutex
 }
 
+// partials stores all global partials
+var partials partialRegistry
+
+// serializes global partials registrations
+var partialsMutex sync.Mutex
+
 // newPartial instanciates a new partial
 func newPartial(name string, source string, tpl *Template) *partial {
 	return &partial{
@@ -36,18 +44,35 @@ func RegisterPartial(name string, source string) {
 	partialsMutex.Lock()
 	defer partialsMutex.Unlock()
 
-	if partials[name] != nil {
+	if partials.get(name) != nil {
 		panic(fmt.Errorf("Partial already registered: %s", name))
 	}
 
-	partials[name] = newPartial(name, source, nil)
+	partials.set(parser.Intern(name), newPartial(name, source, nil))
 }
 
 // RegisterPartials registers several global partials. Those partials will be available to all templates.
 func RegisterPartials(partials map[string]string) {
-	for name, p := range partials {
-		RegisterPartial(name, p)
+	batch := make(map[string]*partial, len(partials))
+	for name, source := range partials {
+		batch[parser.Intern(name)] = newPartial(name, source, nil)
+	}
+
+	registerPartials(batch)
+}
+
+// registerPartials registers given batch of global partials
+func registerPartials(batch map[string]*partial) {
+	partialsMutex.Lock()
+	defer partialsMutex.Unlock()
+
+	for name := range batch {
+		if partials.get(name) != nil {
+			panic(fmt.Errorf("Partial already registered: %s", name))
+		}
 	}
+
+	partials.setAll(batch)
 }
 
 // RegisterPartialTemplate registers a global partial with given parsed template. That partial will be available to all templates.
@@ -55,11 +80,11 @@ func RegisterPartialTemplate(name string, tpl *Template) {
 	partialsMutex.Lock()
 	defer partialsMutex.Unlock()
 
-	if partials[name] != nil {
+	if partials.get(name) != nil {
 		panic(fmt.Errorf("Partial already registered: %s", name))
 	}
 
-	partials[name] = newPartial(name, "", tpl)
+	partials.set(parser.Intern(name), newPartial(name, "", tpl))
 }
 
 // RemovePartial removes the partial registered under the given name. The partial will not be available globally anymore. This does not affect partials registered on a specific template.
@@ -67,7 +92,7 @@ func RemovePartial(name string) {
 	partialsMutex.Lock()
 	defer partialsMutex.Unlock()
 
-	delete(partials, name)
+	partials.remove(name)
 }
 
 // RemoveAllPartials removes all globally registered partials. This does not affect partials registered on a specific template.
@@ -75,24 +100,32 @@ func RemoveAllPartials() {
 	partialsMutex.Lock()
 	defer partialsMutex.Unlock()
 
-	partials = make(map[string]*partial)
+	partials.reset()
 }
 
 // findPartial finds a registered global partial
 func findPartial(name string) *partial {
-	partialsMutex.RLock()
-	defer partialsMutex.RUnlock()
-
-	return partials[name]
+	return partials.get(name)
 }
 
 // template returns parsed partial template
 func (p *partial) template() (*Template, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	if p.tpl == nil {
 		var err error
 
-		p.tpl, err = Parse(p.source)
+		if p.mustache {
+			p.tpl = newMustacheTemplate(p.source, defaultMustacheDelims)
+			err = p.tpl.parse()
+		} else {
+			p.tpl, err = parsePartial(p.source)
+		}
+
 		if err != nil {
+			p.tpl = nil
+
 			return nil, err
 		}
 	}
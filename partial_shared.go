@@ -0,0 +1,66 @@
+//go:build go1.24
+// +build go1.24
+
+package raymond
+
+import (
+	"crypto/sha256"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+var (
+	// parsed partials, by source hash
+	sharedPartials = make(map[[sha256.Size]byte]weak.Pointer[Template])
+
+	// protects sharedPartials
+	sharedPartialsMutex sync.Mutex
+)
+
+// parsePartial returns the parsed template for given partial source
+//
+// Partials with identical sources share the same parsed and compiled program, so that templates including the same partials do not hold N copies of it. A shared program is released when no partial references it anymore.
+func parsePartial(source string) (*Template, error) {
+	hash := sha256.Sum256([]byte(source))
+
+	if tpl := findSharedPartial(hash); tpl != nil {
+		return tpl, nil
+	}
+
+	// parse outside of lock, so that a slow parsing does not block other partials
+	tpl, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedPartialsMutex.Lock()
+	defer sharedPartialsMutex.Unlock()
+
+	// same partial parsed concurrently
+	if shared := sharedPartials[hash].Value(); shared != nil {
+		return shared, nil
+	}
+
+	ptr := weak.Make(tpl)
+	sharedPartials[hash] = ptr
+
+	runtime.AddCleanup(tpl, func(hash [sha256.Size]byte) {
+		sharedPartialsMutex.Lock()
+		defer sharedPartialsMutex.Unlock()
+
+		if sharedPartials[hash] == ptr {
+			delete(sharedPartials, hash)
+		}
+	}, hash)
+
+	return tpl, nil
+}
+
+// findSharedPartial returns the parsed partial with given source hash, or nil if not found
+func findSharedPartial(hash [sha256.Size]byte) *Template {
+	sharedPartialsMutex.Lock()
+	defer sharedPartialsMutex.Unlock()
+
+	return sharedPartials[hash].Value()
+}
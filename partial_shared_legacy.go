@@ -0,0 +1,9 @@
+//go:build !go1.24
+// +build !go1.24
+
+package raymond
+
+// parsePartial returns the parsed template for given partial source: sharing programs between identical partials needs the weak package, available with Go 1.24+.
+func parsePartial(source string) (*Template, error) {
+	return Parse(source)
+}
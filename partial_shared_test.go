@@ -0,0 +1,54 @@
+//go:build go1.24
+// +build go1.24
+
+package raymond
+
+import "testing"
+
+func TestSharedPartials(t *testing.T) {
+	t.Parallel()
+
+	tpl1 := MustParse(`{{> item}}`)
+	tpl1.RegisterPartial("item", `<li>{{name}} (shared)</li>`)
+
+	tpl2 := MustParse(`<ul>{{> entry}}</ul>`)
+	tpl2.RegisterPartial("entry", `<li>{{name}} (shared)</li>`)
+
+	tpl3 := MustParse(`{{> item}}`)
+	tpl3.RegisterPartial("item", `<li>{{name}} (not shared)</li>`)
+
+	ctx := map[string]string{"name": "foo"}
+
+	if output := tpl1.MustExec(ctx); output != "<li>foo (shared)</li>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if output := tpl2.MustExec(ctx); output != "<ul><li>foo (shared)</li></ul>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if output := tpl3.MustExec(ctx); output != "<li>foo (not shared)</li>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if tpl1.findPartial("item").tpl != tpl2.findPartial("entry").tpl {
+		t.Errorf("Identical partials not shared")
+	}
+
+	if tpl1.findPartial("item").tpl == tpl3.findPartial("item").tpl {
+		t.Errorf("Different partials shared")
+	}
+}
+
+func TestSharedPartialsError(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 2; i++ {
+		tpl := MustParse(`{{> broken}}`)
+		tpl.RegisterPartial("broken", `{{#if}}`)
+
+		if _, err := tpl.Exec(nil); err == nil {
+			t.Errorf("Parsing error expected")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package raymond
+
+import (
+	"reflect"
+	"sync"
+)
+
+// planKey identifies a field resolution plan
+type planKey struct {
+	typ reflect.Type
+
+	// true if methods are looked up on a pointer to value
+	addr bool
+
+	name string
+}
+
+// fieldPlan is the result of the reflection lookups needed to resolve a field name on a type
+//
+// Plans are computed the first time a field name is resolved on a type, then reused by all evaluations.
+type fieldPlan struct {
+	// index of method in method set, or -1 if this is not a method
+	method int
+
+	// index of struct field, or nil if this is not a struct field
+	field []int
+
+	// true if struct field was found with the `handlebars` struct tag
+	tag bool
+
+	// Go name of method or struct field, empty if not found
+	member string
+
+	// true if members can't be resolved on type, because struct reflection is not available
+	unsupported bool
+}
+
+var (
+	// field resolution plans cache
+	plans = make(map[planKey]*fieldPlan)
+
+	// protects plans
+	plansMutex sync.RWMutex
+)
+
+// findFieldPlan returns the plan to resolve given field name on given value
+func findFieldPlan(ctx reflect.Value, name string) *fieldPlan {
+	key := planKey{
+		typ:  ctx.Type(),
+		addr: (ctx.Kind() != reflect.Interface) && ctx.CanAddr(),
+		name: name,
+	}
+
+	plansMutex.RLock()
+	plan := plans[key]
+	plansMutex.RUnlock()
+
+	if plan != nil {
+		return plan
+	}
+
+	plan = newFieldPlan(key)
+
+	plansMutex.Lock()
+	plans[key] = plan
+	plansMutex.Unlock()
+
+	return plan
+}
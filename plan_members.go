@@ -0,0 +1,58 @@
+//go:build !tinygo && !raymond_tiny
+
+package raymond
+
+import (
+	"reflect"
+	"strings"
+)
+
+// structReflection is true as struct fields and methods are resolved in this build mode
+const structReflection = true
+
+// newFieldPlan computes plan with given key
+//
+// Lookup order is: method, then exported struct field, then `handlebars` struct tag.
+func newFieldPlan(key planKey) *fieldPlan {
+	result := &fieldPlan{method: -1}
+
+	methType := key.typ
+	if key.addr {
+		methType = reflect.PtrTo(key.typ)
+	}
+
+	method, ok := methType.MethodByName(key.name)
+	if !ok {
+		// example: subject() => Subject()
+		method, ok = methType.MethodByName(strings.Title(key.name))
+	}
+
+	if ok {
+		result.method = method.Index
+		result.member = method.Name
+		return result
+	}
+
+	if key.typ.Kind() != reflect.Struct {
+		return result
+	}
+
+	// example: firstName => FirstName
+	if tField, ok := key.typ.FieldByName(strings.Title(key.name)); ok && (tField.PkgPath == "") {
+		result.field = tField.Index
+		result.member = tField.Name
+		return result
+	}
+
+	// attempts to find template variable name as a struct tag
+	for i := 0; i < key.typ.NumField(); i++ {
+		if key.typ.Field(i).Tag.Get("handlebars") == key.name {
+			result.field = []int{i}
+			result.tag = true
+			result.member = key.typ.Field(i).Name
+			break
+		}
+	}
+
+	return result
+}
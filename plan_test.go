@@ -0,0 +1,85 @@
+package raymond
+
+import (
+	"reflect"
+	"testing"
+)
+
+type planTestPerson struct {
+	FirstName string
+	Nick      string `handlebars:"nickname"`
+	Age       int
+	secret    string
+}
+
+func (p planTestPerson) Name() string {
+	return p.FirstName
+}
+
+func (p *planTestPerson) Older() int {
+	return p.Age + 1
+}
+
+func TestFieldPlan(t *testing.T) {
+	skipTiny(t)
+	t.Parallel()
+
+	val := reflect.ValueOf(planTestPerson{})
+	ptr := reflect.ValueOf(&planTestPerson{}).Elem()
+
+	tests := []struct {
+		ctx    reflect.Value
+		name   string
+		method bool
+		field  []int
+		tag    bool
+	}{
+		{val, "name", true, nil, false},
+		{val, "Name", true, nil, false},
+		{val, "firstName", false, []int{0}, false},
+		{val, "nickname", false, []int{1}, true},
+		{val, "secret", false, nil, false},
+		{val, "unknown", false, nil, false},
+
+		// pointer methods are only found on addressable values
+		{val, "older", false, nil, false},
+		{ptr, "older", true, nil, false},
+	}
+
+	for _, test := range tests {
+		plan := findFieldPlan(test.ctx, test.name)
+
+		if (plan.method >= 0) != test.method || !reflect.DeepEqual(plan.field, test.field) || (plan.tag != test.tag) {
+			t.Errorf("Unexpected plan for %s on %s (addressable: %v): %+v", test.name, test.ctx.Type(), test.ctx.CanAddr(), plan)
+		}
+
+		if findFieldPlan(test.ctx, test.name) != plan {
+			t.Errorf("Plan for %s not cached", test.name)
+		}
+	}
+}
+
+func TestFieldPlanEval(t *testing.T) {
+	skipTiny(t)
+	t.Parallel()
+
+	tpl := MustParse(`{{name}} {{nickname}} {{age}} {{#each people}}{{name}}/{{older}} {{/each}}`)
+
+	ctx := map[string]interface{}{
+		"name":     "foo",
+		"nickname": "bar",
+		"age":      1,
+		"people":   []*planTestPerson{{FirstName: "Jean", Age: 40}, {FirstName: "Marc", Age: 30}},
+	}
+
+	// evaluate twice, with computed then cached plans
+	for i := 0; i < 2; i++ {
+		if output := tpl.MustExec(ctx); output != "foo bar 1 Jean/41 Marc/31 " {
+			t.Errorf("Unexpected output: %q", output)
+		}
+
+		if output := tpl.MustExec(planTestPerson{FirstName: "Jean", Nick: "JJ", Age: 40}); output != "Jean JJ 40 " {
+			t.Errorf("Unexpected output: %q", output)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build tinygo || raymond_tiny
+
+package raymond
+
+import "reflect"
+
+// structReflection is false as struct fields and methods are not resolved in this build mode
+const structReflection = false
+
+// newFieldPlan computes plan with given key
+//
+// The method and struct field lookups are costly or not implemented on TinyGo, so fields are never resolved on structs: contexts must be made of maps and slices, and resolving a field on a struct is an evaluation error.
+func newFieldPlan(key planKey) *fieldPlan {
+	return &fieldPlan{method: -1, unsupported: key.typ.Kind() == reflect.Struct}
+}
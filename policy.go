@@ -0,0 +1,258 @@
+package raymond
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SecurityPolicy bundles the safety settings of a template: the limits of the resources used to parse and evaluate it, and the sandbox restricting what untrusted templates are allowed to do.
+//
+// A policy is set with the Policy parse option and applies to all evaluations of template, and it can be tightened for an evaluation with the Policy evaluation option. Limits set to zero are not enforced.
+type SecurityPolicy struct {
+	// MaxInput is the maximum size in bytes of template source, checked when template is parsed.
+	MaxInput int
+
+	// MaxDepth is the maximum nesting level of blocks and partials during evaluation, which prevents runaway recursive partials.
+	MaxDepth int
+
+	// MaxIterations is the maximum number of iterations of all blocks, #each, #times and #range helpers of an evaluation.
+	MaxIterations int64
+
+	// MaxOutput is the maximum number of bytes produced by an evaluation, enforced as the MemoryBudget evaluation option, unless that option sets a smaller budget.
+	MaxOutput int64
+
+	// Timeout is the maximum duration of an evaluation. It is checked when entering blocks, partials and iterations, so that a slow helper is not interrupted.
+	Timeout time.Duration
+
+	// Sandbox restricts the helpers, partials and unescaped output that template is allowed to use. If nil, template is trusted.
+	Sandbox *SandboxPolicy
+}
+
+// PolicyTrusted returns the policy of templates authored by the application itself: no limit is enforced and all helpers and partials are available. This is the default.
+func PolicyTrusted() SecurityPolicy {
+	return SecurityPolicy{}
+}
+
+// PolicyUntrusted returns a policy suitable for templates authored by untrusted parties: sizes, depth, iterations and duration are limited, and templates can only use the built-in helpers that neither have side effects nor read the environment, and no partial.
+//
+// Add the helpers and partials provided to template authors to the returned Sandbox.
+func PolicyUntrusted() SecurityPolicy {
+	return SecurityPolicy{
+		MaxInput:      256 << 10,
+		MaxDepth:      32,
+		MaxIterations: 100000,
+		MaxOutput:     8 << 20,
+		Timeout:       time.Second,
+		Sandbox: &SandboxPolicy{
+			Helpers: []string{"if", "unless", "with", "each", "lookup", "equal", "concat", "switch", "case", "default", "indent", "nindent"},
+		},
+	}
+}
+
+// Merge returns a policy that is at least as strict as both receiver and given policy: the smaller of each limit is kept, and helpers, partials and unescaped output must be allowed by both sandboxes, while members denied by either sandbox are denied.
+func (p SecurityPolicy) Merge(other SecurityPolicy) SecurityPolicy {
+	return SecurityPolicy{
+		MaxInput:      int(minLimit(int64(p.MaxInput), int64(other.MaxInput))),
+		MaxDepth:      int(minLimit(int64(p.MaxDepth), int64(other.MaxDepth))),
+		MaxIterations: minLimit(p.MaxIterations, other.MaxIterations),
+		MaxOutput:     minLimit(p.MaxOutput, other.MaxOutput),
+		Timeout:       time.Duration(minLimit(int64(p.Timeout), int64(other.Timeout))),
+		Sandbox:       mergeSandbox(p.Sandbox, other.Sandbox),
+	}
+}
+
+// minLimit returns the smaller of given limits, zero meaning no limit
+func minLimit(a, b int64) int64 {
+	if (a == 0) || ((b != 0) && (b < a)) {
+		return b
+	}
+
+	return a
+}
+
+// mergeSandbox returns a sandbox policy that only allows what is allowed by both given policies, nil meaning no restriction
+func mergeSandbox(a, b *SandboxPolicy) *SandboxPolicy {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+
+	return &SandboxPolicy{
+		Helpers:        intersectNames(a.Helpers, b.Helpers),
+		Partials:       intersectNames(a.Partials, b.Partials),
+		AllowUnescaped: a.AllowUnescaped && b.AllowUnescaped,
+		DeniedMembers:  unionNames(a.DeniedMembers, b.DeniedMembers),
+		DenyMethods:    a.DenyMethods || b.DenyMethods,
+	}
+}
+
+// intersectNames returns the names present in both given lists
+func intersectNames(a, b []string) []string {
+	var result []string
+
+	for _, name := range a {
+		for _, other := range b {
+			if name == other {
+				result = append(result, name)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// unionNames returns the names present in any of given lists
+func unionNames(a, b []string) []string {
+	result := append([]string(nil), a...)
+
+	for _, name := range b {
+		found := false
+		for _, other := range a {
+			if name == other {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+// LimitExceededError is the error returned when parsing or evaluating a template exceeds a limit of its security policy, except MaxOutput that results in a *BudgetExceededError.
+type LimitExceededError struct {
+	// Limit is the name of the exceeded SecurityPolicy field, eg. "MaxDepth"
+	Limit string
+
+	// Line is the line of the statement being evaluated, or zero if unknown
+	Line int
+}
+
+// Error implements the error interface.
+func (err *LimitExceededError) Error() string {
+	if err.Line == 0 {
+		return fmt.Sprintf("Security policy limit exceeded: %s", err.Limit)
+	}
+
+	return fmt.Sprintf("Security policy limit exceeded on line %d: %s", err.Line, err.Limit)
+}
+
+// setPolicy sets the security policy of template
+func (tpl *Template) setPolicy(policy *SecurityPolicy) {
+	if policy == nil {
+		return
+	}
+
+	tpl.policy = *policy
+	tpl.sandbox = newSandbox(policy.Sandbox)
+}
+
+// checkInput returns a *LimitExceededError if given template input is larger than allowed by policy
+func (p *SecurityPolicy) checkInput(size int) error {
+	if (p.MaxInput > 0) && (size > p.MaxInput) {
+		return &LimitExceededError{Limit: "MaxInput"}
+	}
+
+	return nil
+}
+
+//
+// Evaluation
+//
+
+// evalUsage tracks the iterations and duration of an evaluation, shared with partials rendered concurrently
+type evalUsage struct {
+	iterations    int64
+	maxIterations int64
+
+	timeout  time.Duration
+	deadline time.Time
+}
+
+// start resets usage at the beginning of an evaluation
+func (u *evalUsage) start() {
+	u.iterations = 0
+
+	if u.timeout > 0 {
+		u.deadline = time.Now().Add(u.timeout)
+	}
+}
+
+// setPolicy sets up visitor with the security policy of template, tightened by given evaluation policy
+func (v *evalVisitor) setPolicy(opts ExecOptions) {
+	policy := v.tpl.policy
+	v.sandbox = v.tpl.sandbox
+
+	if opts.Policy != nil {
+		policy = policy.Merge(*opts.Policy)
+
+		if opts.Policy.Sandbox != nil {
+			v.sandbox = newSandbox(policy.Sandbox)
+		}
+	}
+
+	if (policy.MaxOutput > 0) && ((v.budget == nil) || (policy.MaxOutput < v.budget.limit)) {
+		v.budget = &memoryBudget{limit: policy.MaxOutput}
+	}
+
+	v.maxDepth = policy.MaxDepth
+
+	if (policy.MaxIterations > 0) || (policy.Timeout > 0) {
+		v.usage = &evalUsage{maxIterations: policy.MaxIterations, timeout: policy.Timeout}
+		v.usage.start()
+	}
+}
+
+// nest increments the nesting level of blocks and partials, and panics with a *LimitExceededError if maximum depth or timeout is exceeded
+//
+// Call unnest() once block or partial is evaluated.
+func (v *evalVisitor) nest(line int) {
+	v.depth++
+
+	if (v.maxDepth > 0) && (v.depth > v.maxDepth) {
+		panic(&LimitExceededError{Limit: "MaxDepth", Line: line})
+	}
+
+	v.checkDeadline(line)
+}
+
+// unnest decrements the nesting level of blocks and partials
+func (v *evalVisitor) unnest() {
+	v.depth--
+}
+
+// iterate records an iteration, and panics with a *LimitExceededError if maximum number of iterations or timeout is exceeded
+func (v *evalVisitor) iterate() {
+	if v.usage == nil {
+		return
+	}
+
+	if n := atomic.AddInt64(&v.usage.iterations, 1); (v.usage.maxIterations > 0) && (n > v.usage.maxIterations) {
+		panic(&LimitExceededError{Limit: "MaxIterations", Line: v.curLine()})
+	}
+
+	v.checkDeadline(v.curLine())
+}
+
+// checkDeadline panics with a *LimitExceededError if evaluation timeout is exceeded
+func (v *evalVisitor) checkDeadline(line int) {
+	if (v.usage != nil) && (v.usage.timeout > 0) && time.Now().After(v.usage.deadline) {
+		panic(&LimitExceededError{Limit: "Timeout", Line: line})
+	}
+}
+
+// curLine returns the line of current node, or zero if unknown
+func (v *evalVisitor) curLine() int {
+	if v.curNode == nil {
+		return 0
+	}
+
+	return v.curNode.Location().Line
+}
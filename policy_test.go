@@ -0,0 +1,146 @@
+package raymond
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyMaxInput(t *testing.T) {
+	t.Parallel()
+
+	policy := &SecurityPolicy{MaxInput: 10}
+
+	if _, err := ParseWithOptions("0123456789", ParseOptions{Policy: policy}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	_, err := ParseWithOptions("0123456789a", ParseOptions{Policy: policy})
+
+	var limitErr *LimitExceededError
+	if !errors.As(err, &limitErr) || (limitErr.Limit != "MaxInput") {
+		t.Errorf("Expected MaxInput limit to be exceeded, got: %v", err)
+	}
+}
+
+func TestPolicyTimeout(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions(`{{#each items}}{{slow}}{{/each}}`, ParseOptions{Policy: &SecurityPolicy{Timeout: 20 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterHelper("slow", func() string {
+		time.Sleep(10 * time.Millisecond)
+		return "x"
+	})
+
+	_, err = tpl.Exec(map[string]interface{}{"items": make([]int, 100)})
+
+	var limitErr *LimitExceededError
+	if !errors.As(err, &limitErr) || (limitErr.Limit != "Timeout") {
+		t.Errorf("Expected Timeout limit to be exceeded, got: %v", err)
+	}
+}
+
+func TestPolicyExec(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{{this}}}{{/each}}`)
+	ctx := map[string]interface{}{"items": []string{"<a>", "<b>", "<c>"}}
+
+	// trusted template
+	if output := tpl.MustExec(ctx); output != "<a><b><c>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// tightened for one evaluation
+	_, err := tpl.ExecWithOptions(ctx, ExecOptions{Policy: &SecurityPolicy{MaxIterations: 2}})
+	if err == nil || !strings.Contains(err.Error(), "MaxIterations") {
+		t.Errorf("Expected MaxIterations limit to be exceeded, got: %v", err)
+	}
+
+	untrusted := PolicyUntrusted()
+
+	_, err = tpl.ExecWithOptions(ctx, ExecOptions{Policy: &untrusted})
+
+	var sandboxErr *SandboxError
+	if !errors.As(err, &sandboxErr) {
+		t.Errorf("Expected sandbox violation, got: %v", err)
+	}
+
+	// the policy of template can't be loosened by evaluation
+	tpl, err = ParseWithOptions(`{{#each items}}{{this}}{{/each}}`, ParseOptions{Policy: &SecurityPolicy{MaxIterations: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := PolicyTrusted()
+
+	if _, err = tpl.ExecWithOptions(ctx, ExecOptions{Policy: &trusted}); err == nil {
+		t.Errorf("Expected MaxIterations limit to be exceeded")
+	}
+}
+
+func TestPolicyMerge(t *testing.T) {
+	t.Parallel()
+
+	a := SecurityPolicy{
+		MaxDepth:  10,
+		MaxOutput: 100,
+		Sandbox:   &SandboxPolicy{Helpers: []string{"if", "each", "upper"}, AllowUnescaped: true},
+	}
+
+	b := SecurityPolicy{
+		MaxDepth: 5,
+		Timeout:  time.Second,
+		Sandbox:  &SandboxPolicy{Helpers: []string{"each", "upper", "lower"}, Partials: []string{"header"}},
+	}
+
+	expected := SecurityPolicy{
+		MaxDepth:  5,
+		MaxOutput: 100,
+		Timeout:   time.Second,
+		Sandbox:   &SandboxPolicy{Helpers: []string{"each", "upper"}},
+	}
+
+	if merged := a.Merge(b); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Unexpected merged policy:\nexpected:\n\t%+v %+v\ngot:\n\t%+v %+v", expected, *expected.Sandbox, merged, *merged.Sandbox)
+	}
+
+	if merged := PolicyTrusted().Merge(a); !reflect.DeepEqual(merged, a) {
+		t.Errorf("Merging with trusted policy should not change policy, got: %+v", merged)
+	}
+}
+
+func TestPolicyUntrusted(t *testing.T) {
+	t.Parallel()
+
+	policy := PolicyUntrusted()
+	policy.Sandbox.Partials = append(policy.Sandbox.Partials, "item")
+
+	tpl, err := ParseWithOptions(`{{#each items}}{{#if this}}{{> item}}{{/if}}{{/each}}`, ParseOptions{Policy: &policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterPartial("item", `<{{this}}>`)
+
+	if output := tpl.MustExec(map[string]interface{}{"items": []string{"a", "", "<b>"}}); output != "<a><&lt;b&gt;>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// presets are not shared
+	if other := PolicyUntrusted(); len(other.Sandbox.Partials) != 0 {
+		t.Errorf("Preset policy has been modified: %+v", other.Sandbox)
+	}
+
+	for _, source := range []string{`{{log "x"}}`, `{{{x}}}`, `{{> item}}`, `{{uuid "x"}}`} {
+		if _, err := ParseWithOptions(source, ParseOptions{Policy: &SecurityPolicy{Sandbox: PolicyUntrusted().Sandbox}}); err == nil {
+			t.Errorf("Expected sandbox violation with untrusted policy: %s", source)
+		}
+	}
+}
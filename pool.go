@@ -0,0 +1,198 @@
+package raymond
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// maxPooledBufferSize is the capacity above which an output buffer is not put back to the pool, so that a huge rendering does not pin memory forever
+const maxPooledBufferSize = 1 << 20
+
+// Buffer is a pooled output buffer, returned by Template.ExecBuffer().
+//
+// Call Release() when done with it, so that it is reused by subsequent evaluations.
+type Buffer struct {
+	bytes.Buffer
+}
+
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} { return new(Buffer) },
+	}
+
+	bufioWriterPool = sync.Pool{
+		New: func() interface{} { return bufio.NewWriter(nil) },
+	}
+
+	evalVisitorPool = sync.Pool{
+		New: func() interface{} {
+			return &evalVisitor{
+				exprFunc:  make(map[*ast.Expression]bool),
+				rootFrame: NewDataFrame(),
+			}
+		},
+	}
+)
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *Buffer {
+	return bufferPool.Get().(*Buffer)
+}
+
+// Release puts buffer back to the pool. The buffer, and any slice returned by its Bytes() method, must not be used after that call.
+func (b *Buffer) Release() {
+	if b.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// getBufioWriter returns a buffered writer from the pool, that writes to given writer
+func getBufioWriter(w io.Writer) *bufio.Writer {
+	bw := bufioWriterPool.Get().(*bufio.Writer)
+	bw.Reset(w)
+
+	return bw
+}
+
+// releaseBufioWriter puts given buffered writer back to the pool
+func releaseBufioWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	bufioWriterPool.Put(bw)
+}
+
+// release resets visitor and puts it back to the pool
+//
+// Stacks, memoized function calls and data frames are kept, so that they are reused by subsequent evaluations.
+func (v *evalVisitor) release() {
+	v.tpl = nil
+	v.code = nil
+	v.rand = nil
+	v.randSeeded = false
+	v.deterministic = false
+	v.clock = nil
+	v.partialSem = nil
+	v.profiler = nil
+	v.coverage = nil
+	v.budget = nil
+	v.sharedMemo = nil
+	v.nonce = ""
+	v.nonceAttr = ""
+	v.sandbox = nil
+	v.maxDepth = 0
+	v.usage = nil
+	v.secrets = nil
+
+	for key := range v.memo {
+		delete(v.memo, key)
+	}
+
+	v.reset()
+
+	evalVisitorPool.Put(v)
+}
+
+// reset clears the state of the current evaluation, but keeps the template and evaluation options, so that visitor can evaluate another context
+func (v *evalVisitor) reset() {
+	v.curNode = nil
+	v.curPartial = ""
+	v.profile = v.profile[:0]
+	v.depth = 0
+
+	// do not retain contexts nor AST nodes
+	for i := range v.ctx {
+		v.ctx[i] = reflect.Value{}
+	}
+	v.ctx = v.ctx[:0]
+
+	for i := range v.blockParams {
+		v.blockParams[i] = nil
+	}
+	v.blockParams = v.blockParams[:0]
+
+	for i := range v.blocks {
+		v.blocks[i] = nil
+	}
+	v.blocks = v.blocks[:0]
+
+	for i := range v.exprs {
+		v.exprs[i] = nil
+	}
+	v.exprs = v.exprs[:0]
+
+	for expr := range v.exprFunc {
+		delete(v.exprFunc, expr)
+	}
+
+	v.rootFrame.reset()
+	v.dataFrame = nil
+}
+
+// fork returns a visitor from the pool with a copy of receiver evaluation state, to evaluate a statement concurrently
+//
+// Stacks and current data frame are copied, while contexts, block params and parent data frames are shared, so that helpers setting private data do not race. Call release() once evaluation is done.
+func (v *evalVisitor) fork() *evalVisitor {
+	result := evalVisitorPool.Get().(*evalVisitor)
+
+	result.tpl = v.tpl
+	result.code = v.code
+	result.curNode = v.curNode
+	result.curPartial = v.curPartial
+	result.partialSem = v.partialSem
+	result.budget = v.budget
+	result.coverage = v.coverage
+	result.deterministic = v.deterministic
+	result.clock = v.clock
+	result.sharedMemo = v.sharedMemo
+	result.nonce = v.nonce
+	result.nonceAttr = v.nonceAttr
+	result.sandbox = v.sandbox
+	result.depth = v.depth
+	result.maxDepth = v.maxDepth
+	result.secrets = v.secrets
+	result.usage = v.usage
+	result.profiler = v.profiler
+
+	result.ctx = append(result.ctx, v.ctx...)
+	result.blockParams = append(result.blockParams, v.blockParams...)
+	result.blocks = append(result.blocks, v.blocks...)
+	result.exprs = append(result.exprs, v.exprs...)
+	result.profile = append(result.profile, v.profile...)
+
+	result.dataFrame = v.dataFrame.clone()
+
+	return result
+}
+
+// newIterDataFrame returns a data frame with current data frame as parent and with iteration data set (@index, @key, @first, @last)
+//
+// The data frame is taken from the free list of visitor: call releaseDataFrame() once iteration is evaluated.
+func (v *evalVisitor) newIterDataFrame(length int, i int, key interface{}) *DataFrame {
+	v.iterate()
+
+	var frame *DataFrame
+
+	if n := len(v.frames); n > 0 {
+		frame, v.frames = v.frames[n-1], v.frames[:n-1]
+	} else {
+		frame = NewDataFrame()
+	}
+
+	frame.copyFrom(v.dataFrame)
+	frame.setIterData(length, i, key)
+
+	return frame
+}
+
+// releaseDataFrame resets given data frame and puts it back to the free list of visitor
+func (v *evalVisitor) releaseDataFrame(frame *DataFrame) {
+	frame.reset()
+	v.frames = append(v.frames, frame)
+}
@@ -0,0 +1,111 @@
+package raymond
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestExecBuffer(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{@index}}:{{this}} {{/each}}`)
+
+	for i := 0; i < 3; i++ {
+		buf, err := tpl.ExecBuffer(map[string][]string{"items": {"a", "b"}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if output := buf.String(); output != "0:a 1:b " {
+			t.Errorf("Unexpected output: %q", output)
+		}
+
+		buf.Release()
+	}
+}
+
+func TestExecBufferError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#foo}}{{/foo}}`)
+	tpl.RegisterHelper("foo", func(options *Options) string {
+		options.eval.errorf("foo error")
+		return ""
+	})
+
+	buf, err := tpl.ExecBuffer(nil)
+	if err == nil {
+		t.Errorf("Evaluation error expected")
+	}
+
+	if buf != nil {
+		t.Errorf("Unexpected buffer returned on error")
+	}
+
+	// visitor must have been reset after failure
+	if output := MustRender(`{{#each items}}{{@first}}{{/each}}`, map[string][]int{"items": {1, 2}}); output != "truefalse" {
+		t.Errorf("Unexpected output after error: %q", output)
+	}
+}
+
+func TestPooledDataFrames(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each outer}}[{{#each this}}{{@../index}}.{{@index}}{{#if @last}}!{{/if}} {{/each}}]{{/each}}`)
+	ctx := map[string][][]int{"outer": {{1, 2}, {3}}}
+
+	for i := 0; i < 3; i++ {
+		if output := tpl.MustExec(ctx); output != "[0.0 0.1! ][1.0! ]" {
+			t.Errorf("Unexpected output: %q", output)
+		}
+	}
+
+	frame := NewDataFrame()
+	frame.Set("foo", "bar")
+
+	output, err := MustParse(`{{@foo}}{{#each items}}{{@foo}}{{/each}}`).ExecWith(map[string][]int{"items": {1}}, frame)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "barbar" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if frame.Get("index") != nil {
+		t.Errorf("Iteration data leaked to initial data frame")
+	}
+}
+
+func TestPooledConcurrency(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{name}}{{/each}}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("name%d", i)
+			ctx := map[string]interface{}{"items": []map[string]string{{"name": name}, {"name": name}}}
+
+			for j := 0; j < 100; j++ {
+				buf, err := tpl.ExecBuffer(ctx)
+				if err != nil {
+					t.Errorf("Unexpected error: %s", err)
+					return
+				}
+
+				if output := buf.String(); output != name+name {
+					t.Errorf("Unexpected output: %q", output)
+				}
+
+				buf.Release()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
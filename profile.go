@@ -0,0 +1,213 @@
+package raymond
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// Profiled statement kinds
+const (
+	ProfileTemplate = "template"
+	ProfileMustache = "mustache"
+	ProfileBlock    = "block"
+	ProfilePartial  = "partial"
+	ProfileHelper   = "helper"
+)
+
+// ProfileEntry represents the cumulative render time and invocation count of a template, a statement, a helper or a partial.
+type ProfileEntry struct {
+	// Kind is one of ProfileTemplate, ProfileMustache, ProfileBlock, ProfilePartial or ProfileHelper
+	Kind string
+
+	// Name is the expression of statement, or the name of helper or partial
+	Name string
+
+	// Line is the line of statement in its template, or zero for templates and helpers
+	Line int
+
+	// Calls is the number of invocations
+	Calls int
+
+	// Total is the cumulative render time, including nested statements
+	Total time.Duration
+}
+
+// Profiler records the render time and invocation counts of statements, helpers and partials, to find the expensive parts of a slow template.
+//
+// Pass it with the Profiler evaluation option. A profiler is safe for concurrent use, and accumulates records across evaluations until Reset() is called.
+type Profiler struct {
+	mutex   sync.Mutex // protects entries and stacks
+	entries map[profileKey]*ProfileEntry
+
+	// self time, by folded stack
+	stacks map[string]time.Duration
+
+	// returns current time, replaced in tests
+	now func() time.Time
+}
+
+// profileKey identifies a profile entry
+type profileKey struct {
+	kind string
+	name string
+	line int
+}
+
+// profileFrame is a statement being rendered
+type profileFrame struct {
+	key   profileKey
+	start time.Time
+
+	// time spent rendering nested statements
+	nested time.Duration
+}
+
+// NewProfiler instanciates a new profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		entries: make(map[profileKey]*ProfileEntry),
+		stacks:  make(map[string]time.Duration),
+		now:     time.Now,
+	}
+}
+
+// Entries returns recorded entries, the most expensive first.
+func (p *Profiler) Entries() []ProfileEntry {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := make([]ProfileEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		result = append(result, *entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+
+		return result[i].label() < result[j].label()
+	})
+
+	return result
+}
+
+// WriteFolded writes recorded self times, in nanoseconds, in the folded stacks format used by flame graph tools (eg. flamegraph.pl, speedscope, pprof).
+//
+// Each line is a stack of statements separated by semicolons, followed by the time spent in the last statement itself.
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stacks := make([]string, 0, len(p.stacks))
+	for stack := range p.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, p.stacks[stack].Nanoseconds()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reset removes all records.
+func (p *Profiler) Reset() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.entries = make(map[profileKey]*ProfileEntry)
+	p.stacks = make(map[string]time.Duration)
+}
+
+// record records a rendered statement
+func (p *Profiler) record(key profileKey, stack string, total time.Duration, self time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	entry := p.entries[key]
+	if entry == nil {
+		entry = &ProfileEntry{Kind: key.kind, Name: key.name, Line: key.line}
+		p.entries[key] = entry
+	}
+
+	entry.Calls++
+	entry.Total += total
+
+	p.stacks[stack] += self
+}
+
+// label returns a representation of entry, used in folded stacks
+func (e *ProfileEntry) label() string {
+	return profileKey{e.Kind, e.Name, e.Line}.label()
+}
+
+// label returns a representation of key, used in folded stacks
+func (k profileKey) label() string {
+	// semicolons separate stack frames
+	name := strings.Replace(k.name, ";", ",", -1)
+
+	if name == "" {
+		return k.kind
+	}
+
+	if k.line == 0 {
+		return k.kind + " " + name
+	}
+
+	return fmt.Sprintf("%s %s:%d", k.kind, name, k.line)
+}
+
+// exprName returns the name of given expression, used in profile entries
+func exprName(expr *ast.Expression) string {
+	if name := expr.Canonical(); name != "" {
+		return name
+	}
+
+	return "(subexpression)"
+}
+
+// profileStart starts recording a statement, if a profiler is set
+func (v *evalVisitor) profileStart(kind string, name string, line int) {
+	if v.profiler == nil {
+		return
+	}
+
+	v.profile = append(v.profile, profileFrame{
+		key:   profileKey{kind, name, line},
+		start: v.profiler.now(),
+	})
+}
+
+// profileEnd stops recording last started statement, if a profiler is set
+func (v *evalVisitor) profileEnd() {
+	if v.profiler == nil {
+		return
+	}
+
+	n := len(v.profile) - 1
+	frame := v.profile[n]
+
+	total := v.profiler.now().Sub(frame.start)
+
+	labels := make([]string, n+1)
+	for i := range v.profile {
+		labels[i] = v.profile[i].key.label()
+	}
+
+	v.profiler.record(frame.key, strings.Join(labels, ";"), total, total-frame.nested)
+
+	v.profile = v.profile[:n]
+	if n > 0 {
+		v.profile[n-1].nested += total
+	}
+}
@@ -0,0 +1,131 @@
+package raymond
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfiler(t *testing.T) {
+	t.Parallel()
+
+	// each call to now() advances clock by one millisecond
+	now := time.Date(2018, 3, 22, 0, 0, 0, 0, time.UTC)
+
+	profiler := NewProfiler()
+	profiler.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	tpl := MustParse("<h1>{{title}}</h1>\n<ul>{{#each items}}{{> item}}{{/each}}</ul>")
+	tpl.RegisterPartial("item", `<li>{{upper name}}</li>`)
+	tpl.RegisterHelper("upper", func(str string) string {
+		return strings.ToUpper(str)
+	})
+
+	ctx := map[string]interface{}{
+		"title": "Items",
+		"items": []map[string]string{{"name": "foo"}, {"name": "bar"}},
+	}
+
+	output, err := tpl.ExecWithOptions(ctx, ExecOptions{Profiler: profiler})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "<h1>Items</h1>\n<ul><li>FOO</li><li>BAR</li></ul>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	calls := make(map[string]int)
+	for _, entry := range profiler.Entries() {
+		calls[profileKey{entry.Kind, entry.Name, entry.Line}.label()] = entry.Calls
+	}
+
+	expectedCalls := map[string]int{
+		"template":         1,
+		"mustache title:1": 1,
+		"block each:2":     1,
+		"helper each":      1,
+		"partial item:2":   2,
+		"mustache upper:1": 2,
+		"helper upper":     2,
+	}
+
+	if len(calls) != len(expectedCalls) {
+		t.Errorf("Unexpected entries\nexpected:\n\t%v\ngot:\n\t%v", expectedCalls, calls)
+	}
+
+	for label, nb := range expectedCalls {
+		if calls[label] != nb {
+			t.Errorf("Unexpected calls for %s: %d", label, calls[label])
+		}
+	}
+
+	if entries := profiler.Entries(); entries[0].Kind != ProfileTemplate {
+		t.Errorf("Template is not the most expensive entry: %+v", entries[0])
+	}
+
+	var buf bytes.Buffer
+	if err := profiler.WriteFolded(&buf); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// self times must sum up to template total time
+	var stacks []string
+	var sum int64
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		i := strings.LastIndex(line, " ")
+
+		nanos, err := strconv.ParseInt(line[i+1:], 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid folded stack line: %q", line)
+		}
+
+		stacks = append(stacks, line[:i])
+		sum += nanos
+	}
+
+	expectedStacks := []string{
+		"template",
+		"template;block each:2",
+		"template;block each:2;helper each",
+		"template;block each:2;helper each;partial item:2",
+		"template;block each:2;helper each;partial item:2;mustache upper:1",
+		"template;block each:2;helper each;partial item:2;mustache upper:1;helper upper",
+		"template;mustache title:1",
+	}
+
+	if strings.Join(stacks, "\n") != strings.Join(expectedStacks, "\n") {
+		t.Errorf("Unexpected stacks\nexpected:\n\t%q\ngot:\n\t%q", expectedStacks, stacks)
+	}
+
+	if total := profiler.Entries()[0].Total; time.Duration(sum) != total {
+		t.Errorf("Self times sum %s differs from template total time %s", time.Duration(sum), total)
+	}
+
+	profiler.Reset()
+	if len(profiler.Entries()) != 0 {
+		t.Errorf("Profiler not reset")
+	}
+}
+
+func TestProfilerError(t *testing.T) {
+	t.Parallel()
+
+	profiler := NewProfiler()
+
+	tpl := MustParse(`{{#each items}}{{> missing}}{{/each}}`)
+	if _, err := tpl.ExecWithOptions(map[string][]int{"items": {1}}, ExecOptions{Profiler: profiler}); err == nil {
+		t.Errorf("Evaluation error expected")
+	}
+
+	// visitor must have been reset after failure
+	if output := tpl.Clone().MustExec(nil); output != "" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
@@ -0,0 +1,214 @@
+// Package protoctx resolves protobuf messages in raymond contexts, so that gRPC services render templates directly over their payloads, without converting them to maps first.
+//
+// Register the resolver once, eg. in an init function, then render templates with messages as context:
+//
+//	protoctx.Register()
+//
+//	// {{userName}} has {{#each orders}}{{id}} {{/each}}
+//	result, err := tpl.Exec(&pb.User{UserName: "jean", Orders: orders})
+//
+// Message fields are resolved by JSON name, eg. "userName" or a custom json_name, and then by proto name, eg. "user_name". Values are converted like the protobuf JSON mapping does:
+//
+//   - fields without presence that are not set have their zero value, and fields with presence that are not set, eg. message and optional fields, are undefined
+//   - enums are their value name, eg. "STATUS_ACTIVE", and bytes are base64 encoded
+//   - google.protobuf.Struct, Value and ListValue are objects, values and lists
+//   - google.protobuf.Timestamp is a time.Time, google.protobuf.Duration is a time.Duration, and wrappers such as google.protobuf.StringValue are their value
+package protoctx
+
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/aymerick/raymond"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Resolver is a raymond.ValueResolver for protobuf messages.
+type Resolver struct {
+	// UseEnumNumbers resolves enums to their number instead of their value name.
+	UseEnumNumbers bool
+}
+
+// Register registers a resolver with default options for all templates.
+func Register() {
+	raymond.RegisterValueResolver(Resolver{})
+}
+
+// ResolveValue converts well-known types to native values. Other messages are not converted: their fields are resolved by ResolveField().
+func (r Resolver) ResolveValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case *structpb.Value:
+		return structValue(v), true
+	case *structpb.Struct:
+		return structFields(v), true
+	case *structpb.ListValue:
+		return listValues(v), true
+	case proto.Message:
+		msg := v.ProtoReflect()
+		if !msg.IsValid() {
+			// nil message
+			return nil, true
+		}
+
+		return wellKnownValue(msg)
+	}
+
+	return nil, false
+}
+
+// ResolveField returns the field of a message with given JSON or proto name, or the field of a google.protobuf.Struct with given name.
+func (r Resolver) ResolveField(value interface{}, name string) (interface{}, bool) {
+	switch v := value.(type) {
+	case *structpb.Struct:
+		field, ok := v.GetFields()[name]
+		return field, ok
+	case proto.Message:
+		msg := v.ProtoReflect()
+		if !msg.IsValid() {
+			return nil, true
+		}
+
+		fields := msg.Descriptor().Fields()
+
+		fd := fields.ByJSONName(name)
+		if fd == nil {
+			fd = fields.ByName(protoreflect.Name(name))
+		}
+
+		if fd == nil {
+			return nil, false
+		}
+
+		return r.fieldValue(msg, fd), true
+	}
+
+	return nil, false
+}
+
+// fieldValue returns the value of given field of given message
+func (r Resolver) fieldValue(msg protoreflect.Message, fd protoreflect.FieldDescriptor) interface{} {
+	switch {
+	case fd.IsList():
+		list := msg.Get(fd).List()
+
+		result := make([]interface{}, list.Len())
+		for i := range result {
+			result[i] = r.singularValue(fd, list.Get(i))
+		}
+
+		return result
+	case fd.IsMap():
+		result := make(map[string]interface{})
+
+		msg.Get(fd).Map().Range(func(key protoreflect.MapKey, val protoreflect.Value) bool {
+			result[key.String()] = r.singularValue(fd.MapValue(), val)
+			return true
+		})
+
+		return result
+	case fd.HasPresence() && !msg.Has(fd):
+		return nil
+	default:
+		return r.singularValue(fd, msg.Get(fd))
+	}
+}
+
+// singularValue converts given value of given field
+func (r Resolver) singularValue(fd protoreflect.FieldDescriptor, val protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		// converted by ResolveValue() when evaluated
+		return val.Message().Interface()
+	case protoreflect.EnumKind:
+		if !r.UseEnumNumbers {
+			if enumVal := fd.Enum().Values().ByNumber(val.Enum()); enumVal != nil {
+				return string(enumVal.Name())
+			}
+		}
+
+		return int32(val.Enum())
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(val.Bytes())
+	default:
+		return val.Interface()
+	}
+}
+
+// wellKnownValue converts the timestamp, duration and wrapper well-known types, and returns false for other messages
+func wellKnownValue(msg protoreflect.Message) (interface{}, bool) {
+	desc := msg.Descriptor()
+	if desc.ParentFile().Package() != "google.protobuf" {
+		return nil, false
+	}
+
+	fields := desc.Fields()
+
+	switch desc.Name() {
+	case "Timestamp":
+		seconds, nanos := msg.Get(fields.ByName("seconds")).Int(), msg.Get(fields.ByName("nanos")).Int()
+		return time.Unix(seconds, nanos).UTC(), true
+	case "Duration":
+		seconds, nanos := msg.Get(fields.ByName("seconds")).Int(), msg.Get(fields.ByName("nanos")).Int()
+		return time.Duration(seconds)*time.Second + time.Duration(nanos), true
+	case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value", "Int32Value", "UInt32Value", "BoolValue", "StringValue":
+		return msg.Get(fields.ByName("value")).Interface(), true
+	case "BytesValue":
+		return base64.StdEncoding.EncodeToString(msg.Get(fields.ByName("value")).Bytes()), true
+	}
+
+	return nil, false
+}
+
+// structValue converts given struct value, nested structs and lists being converted when evaluated
+func structValue(v *structpb.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return kind.NumberValue
+	case *structpb.Value_StringValue:
+		return kind.StringValue
+	case *structpb.Value_BoolValue:
+		return kind.BoolValue
+	case *structpb.Value_StructValue:
+		return structFields(kind.StructValue)
+	case *structpb.Value_ListValue:
+		return listValues(kind.ListValue)
+	default:
+		return nil
+	}
+}
+
+// structFields returns the fields of given struct, nested structs and lists being converted when evaluated
+func structFields(s *structpb.Struct) map[string]interface{} {
+	result := make(map[string]interface{}, len(s.GetFields()))
+
+	for name, field := range s.GetFields() {
+		result[name] = shallowValue(field)
+	}
+
+	return result
+}
+
+// listValues returns the values of given list, nested structs and lists being converted when evaluated
+func listValues(l *structpb.ListValue) []interface{} {
+	result := make([]interface{}, len(l.GetValues()))
+
+	for i, value := range l.GetValues() {
+		result[i] = shallowValue(value)
+	}
+
+	return result
+}
+
+// shallowValue converts given scalar value, and keeps struct and list values, that are converted when evaluated
+func shallowValue(v *structpb.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_StructValue:
+		return kind.StructValue
+	case *structpb.Value_ListValue:
+		return kind.ListValue
+	default:
+		return structValue(v)
+	}
+}
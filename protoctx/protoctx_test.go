@@ -0,0 +1,101 @@
+package protoctx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aymerick/raymond"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func init() {
+	Register()
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name: proto.String("user.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("user_name"),
+				JsonName: proto.String("userName"),
+				Number:   proto.Int32(1),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			}},
+		}},
+	}
+
+	tpl := raymond.MustParse(`{{name}} {{#each messageType}}{{name}}:{{#each field}}{{jsonName}}={{type}}#{{number}}{{/each}}{{/each}} {{#each message_type}}{{name}}{{/each}} [{{syntax}}] {{#if options}}options{{else}}no options{{/if}}`)
+
+	if output := tpl.MustExec(file); output != "user.proto User:userName=TYPE_STRING#1 User [] no options" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	field := file.MessageType[0].Field[0]
+
+	if value, ok := (Resolver{UseEnumNumbers: true}).ResolveField(field, "type"); !ok || (value != int32(descriptorpb.FieldDescriptorProto_TYPE_STRING)) {
+		t.Errorf("Unexpected enum number: %v", value)
+	}
+}
+
+func TestStruct(t *testing.T) {
+	t.Parallel()
+
+	s, err := structpb.NewStruct(map[string]interface{}{
+		"title":  "Hello",
+		"tags":   []interface{}{"a", "b"},
+		"author": map[string]interface{}{"name": "Jean"},
+		"posts":  []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}},
+		"count":  3,
+		"draft":  false,
+		"none":   nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := raymond.MustParse(`{{title}} {{#each tags}}{{this}},{{/each}} {{author.name}} {{count}} {{#if draft}}draft{{else}}published{{/if}} [{{none}}] {{#each author}}{{@key}}={{this}}{{/each}} {{#each posts}}{{id}}{{/each}}`)
+
+	if output := tpl.MustExec(s); output != "Hello a,b, Jean 3 published [] name=Jean 12" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// struct value in a Go context
+	tpl = raymond.MustParse(`{{meta.title}} {{#each list}}{{this}}{{/each}}`)
+
+	list, _ := structpb.NewList([]interface{}{1, "x", true})
+	ctx := map[string]interface{}{"meta": structpb.NewStructValue(s), "list": list}
+
+	if output := tpl.MustExec(ctx); output != "Hello 1xtrue" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestWellKnownTypes(t *testing.T) {
+	t.Parallel()
+
+	ctx := map[string]interface{}{
+		"at":    timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		"label": wrapperspb.String("<b>"),
+		"zero":  wrapperspb.Int32(0),
+		"unset": (*timestamppb.Timestamp)(nil),
+	}
+
+	tpl := raymond.MustParse(`{{at}} {{label}} {{#if zero}}set{{else}}zero{{/if}} [{{unset}}]`)
+
+	if output := tpl.MustExec(ctx); output != "2024-01-02T03:04:05Z &lt;b&gt; zero []" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if value, ok := (Resolver{}).ResolveValue(durationpb.New(90 * time.Second)); !ok || (value != 90*time.Second) {
+		t.Errorf("Unexpected duration: %v", value)
+	}
+}
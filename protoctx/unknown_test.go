@@ -0,0 +1,22 @@
+//go:build !tinygo && !raymond_tiny
+
+package protoctx
+
+import (
+	"testing"
+
+	"github.com/aymerick/raymond"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// TestMessageUnknownField needs struct reflection, as unknown fields are then resolved natively on message
+func TestMessageUnknownField(t *testing.T) {
+	t.Parallel()
+
+	file := &descriptorpb.FileDescriptorProto{Name: proto.String("user.proto")}
+
+	if output := raymond.MustParse(`{{name}} [{{unknown}}]`).MustExec(file); output != "user.proto []" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build race
+// +build race
+
+package raymond
+
+func init() {
+	// sync.Pool randomly drops items with the race detector, so allocations can't be checked
+	raceEnabled = true
+}
@@ -0,0 +1,92 @@
+package raymond
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// randomChars are the characters used by the randomString helper
+const randomChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Rand returns the source of randomness of current evaluation.
+//
+// Helpers should use it instead of math/rand functions, so that their output is stable when a seeded source is provided with ExecOptions.
+func (options *Options) Rand() *rand.Rand {
+	return options.eval.randGen()
+}
+
+// randGen returns the evaluation source of randomness
+func (v *evalVisitor) randGen() *rand.Rand {
+	if v.rand == nil {
+		v.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return v.rand
+}
+
+// randReader returns the reader to use to generate random bytes
+func (v *evalVisitor) randReader() io.Reader {
+	if !v.randSeeded {
+		return crand.Reader
+	}
+
+	return v.rand
+}
+
+//
+// Random helpers
+//
+
+// #uuid helper
+func uuidHelper(options *Options) interface{} {
+	var b [16]byte
+
+	if _, err := io.ReadFull(options.eval.randReader(), b[:]); err != nil {
+		options.eval.errPanic(err)
+	}
+
+	// version 4, variant RFC 4122
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// #randomInt helper
+//
+// Returns a random integer in [min, max).
+func randomIntHelper(min interface{}, max interface{}, options *Options) interface{} {
+	from, okFrom := intValue(min)
+	to, okTo := intValue(max)
+	if !okFrom || !okTo {
+		options.eval.errorf("randomInt helper expects numbers, got: %q %q", Str(min), Str(max))
+	}
+
+	if to <= from {
+		options.eval.errorf("randomInt helper max must be greater than min, got: %d %d", from, to)
+	}
+
+	return from + options.Rand().Intn(to-from)
+}
+
+// #randomString helper
+//
+// Returns a random alphanumeric string of given length.
+func randomStringHelper(length interface{}, options *Options) interface{} {
+	nb, ok := intValue(length)
+	if !ok || nb < 0 {
+		options.eval.errorf("randomString helper expects a positive number, got: %q", Str(length))
+	}
+
+	rnd := options.Rand()
+
+	b := make([]byte, nb)
+	for i := range b {
+		b[i] = randomChars[rnd.Intn(len(randomChars))]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,72 @@
+package raymond
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+var rUUID = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestUUIDHelper(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{uuid}}`)
+
+	first := tpl.MustExec(nil)
+	if !rUUID.MatchString(first) {
+		t.Errorf("Invalid uuid: %q", first)
+	}
+
+	if second := tpl.MustExec(nil); second == first {
+		t.Errorf("Same uuid generated twice: %q", first)
+	}
+}
+
+func TestRandomHelpers(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{randomInt 10 20}} {{randomString 8}}`)
+
+	output := tpl.MustExec(nil)
+	if !regexp.MustCompile(`^1[0-9] [a-zA-Z0-9]{8}$`).MatchString(output) {
+		t.Errorf("Unexpected random helpers output: %q", output)
+	}
+}
+
+func TestRandomHelpersSeeded(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{uuid}} {{randomInt 0 1000000}} {{randomString 16}}`)
+
+	render := func(seed int64) string {
+		output, err := tpl.ExecWithOptions(nil, ExecOptions{Rand: rand.NewSource(seed)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output
+	}
+
+	if first, second := render(42), render(42); first != second {
+		t.Errorf("Seeded random helpers output differ: %q != %q", first, second)
+	}
+
+	if first, second := render(42), render(43); first == second {
+		t.Errorf("Random helpers output should differ with different seeds: %q", first)
+	}
+}
+
+func TestRandomHelpersCustom(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{dice}}`)
+	tpl.RegisterHelper("dice", func(options *Options) int {
+		return 1 + options.Rand().Intn(6)
+	})
+
+	first, _ := tpl.ExecWithOptions(nil, ExecOptions{Rand: rand.NewSource(1)})
+	second, _ := tpl.ExecWithOptions(nil, ExecOptions{Rand: rand.NewSource(1)})
+	if first != second {
+		t.Errorf("Seeded custom helper output differ: %q != %q", first, second)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build go1.16
+// +build go1.16
+
+package raymond
+
+import (
+	"encoding/base64"
+	"io/fs"
+	"strings"
+)
+
+// ReadFileHelper returns a helper that reads files from given file system.
+//
+// Paths are resolved relatively to the root of given file system, and absolute paths or paths containing `..` elements abort template evaluation. The `base64=true` hash argument encodes file content in base64, and the `indent=N` hash argument prefixes every line with N spaces.
+//
+// The helper is not registered by default, so that templates can't access files unless explicitly configured:
+//
+//	tpl.RegisterHelper("readFile", raymond.ReadFileHelper(os.DirFS("files")))
+//
+// Then in template:
+//
+//	ca.pem: {{{readFile "certs/ca.pem" base64=true}}}
+//	script: |
+//	{{{readFile "scripts/init.sh" indent=2}}}
+func ReadFileHelper(fsys fs.FS) interface{} {
+	return func(name string, options *Options) interface{} {
+		if !fs.ValidPath(name) {
+			options.eval.errorf("Invalid file path: %q", name)
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			options.eval.errorf("Failed to read file %q: %s", name, err)
+		}
+
+		result := string(content)
+
+		if IsTrue(options.HashProp("base64")) {
+			result = base64.StdEncoding.EncodeToString(content)
+		}
+
+		if n, ok := intValue(options.HashProp("indent")); ok && n > 0 {
+			result = indentLines(result, strings.Repeat(" ", n))
+		}
+
+		return result
+	}
+}
@@ -0,0 +1,95 @@
+//go:build go1.16
+// +build go1.16
+
+package raymond
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+var readFileFS = fstest.MapFS{
+	"hello.txt":        {Data: []byte("Hello <world>")},
+	"scripts/init.sh":  {Data: []byte("#!/bin/sh\necho init\n")},
+	"certs/secret.bin": {Data: []byte("secret")},
+}
+
+var readFileTests = []Test{
+	{
+		"readFile helper",
+		`{{{readFile "hello.txt"}}}|{{readFile "hello.txt"}}`,
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		`Hello <world>|Hello &lt;world&gt;`,
+	},
+	{
+		"readFile helper with base64",
+		`{{readFile "certs/secret.bin" base64=true}}`,
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		`c2VjcmV0`,
+	},
+	{
+		"readFile helper with indent",
+		"script: |\n{{{readFile \"scripts/init.sh\" indent=2}}}",
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		"script: |\n  #!/bin/sh\n  echo init\n",
+	},
+	{
+		"readFile helper with base64 and indent",
+		"secret:\n{{readFile \"certs/secret.bin\" base64=true indent=4}}",
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		"secret:\n    c2VjcmV0",
+	},
+}
+
+var readFileErrors = []Test{
+	{
+		"readFile helper with path traversal",
+		`{{readFile "../etc/passwd"}}`,
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		`Invalid file path: "../etc/passwd"`,
+	},
+	{
+		"readFile helper with absolute path",
+		`{{readFile "/etc/passwd"}}`,
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		`Invalid file path: "/etc/passwd"`,
+	},
+	{
+		"readFile helper with unclean path",
+		`{{readFile "scripts/../hello.txt"}}`,
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		`Invalid file path`,
+	},
+	{
+		"readFile helper with missing file",
+		`{{readFile "missing.txt"}}`,
+		nil, nil,
+		map[string]interface{}{"readFile": ReadFileHelper(readFileFS)},
+		nil,
+		`Failed to read file "missing.txt"`,
+	},
+}
+
+func TestReadFileHelper(t *testing.T) {
+	t.Parallel()
+
+	launchTests(t, readFileTests)
+}
+
+func TestReadFileHelperErrors(t *testing.T) {
+	launchErrorTests(t, readFileErrors)
+}
@@ -0,0 +1,91 @@
+package raymond
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aymerick/raymond/parser"
+)
+
+// RedactedError is the error returned instead of parse and evaluation errors when the RedactErrors parse option is set.
+//
+// Its message only tells what kind of error occurred and where: it never includes template source nor context values. The detailed error is available with errors.Unwrap() or errors.As(), so that it can be inspected without being logged.
+type RedactedError struct {
+	// Kind is the kind of error: "parse", "evaluation", "sandbox", "limit" or "budget"
+	Kind string
+
+	// Template is the name of the partial where error occurred, or empty for the template itself
+	Template string
+
+	// Line is the line of error in template or partial source, or zero if unknown
+	Line int
+
+	// Err is the detailed error
+	Err error
+}
+
+// Error implements the error interface.
+func (err *RedactedError) Error() string {
+	msg := fmt.Sprintf("Template %s error", err.Kind)
+
+	if err.Template != "" {
+		msg += fmt.Sprintf(" in partial %s", err.Template)
+	}
+
+	if err.Line != 0 {
+		msg += fmt.Sprintf(" on line %d", err.Line)
+	}
+
+	return msg
+}
+
+// Unwrap returns the detailed error.
+func (err *RedactedError) Unwrap() error {
+	return err.Err
+}
+
+// newRedactedError returns a *RedactedError hiding given error, that is of given kind unless its type tells otherwise, and that occurred in given partial on given line unless it knows its own line
+func newRedactedError(err error, kind string, partial string, line int) *RedactedError {
+	result := &RedactedError{Kind: kind, Template: partial, Line: line, Err: err}
+
+	var (
+		parseErr   *parser.Error
+		sandboxErr *SandboxError
+		limitErr   *LimitExceededError
+		budgetErr  *BudgetExceededError
+	)
+
+	switch {
+	case errors.As(err, &parseErr):
+		result.Kind = "parse"
+		result.Line = parseErr.Line
+	case errors.As(err, &sandboxErr):
+		result.Kind = "sandbox"
+		result.Line = sandboxErr.Line
+	case errors.As(err, &limitErr):
+		result.Kind = "limit"
+		result.Line = limitErr.Line
+	case errors.As(err, &budgetErr):
+		result.Kind = "budget"
+	}
+
+	return result
+}
+
+// redactParseError replaces given parse error with a *RedactedError if RedactErrors option is set
+func (tpl *Template) redactParseError(err error) error {
+	if (err == nil) || !tpl.redactErrors {
+		return err
+	}
+
+	return newRedactedError(err, "parse", "", 0)
+}
+
+// redactDetails replaces evaluation error with a *RedactedError if RedactErrors option is set
+func (v *evalVisitor) redactDetails(errp *error) {
+	if (*errp == nil) || !v.tpl.redactErrors {
+		return
+	}
+
+	*errp = newRedactedError(*errp, "evaluation", v.curPartial, v.curLine())
+}
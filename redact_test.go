@@ -0,0 +1,108 @@
+package raymond
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var redactTests = []struct {
+	name     string
+	input    string
+	policy   *SecurityPolicy
+	data     interface{}
+	kind     string
+	partial  string
+	line     int
+	detailed string
+}{
+	{"parse", "foo\n{{#if secret}}", nil, nil, "parse", "", 2, "Expecting OpenEndBlock"},
+	{"evaluation", "foo\n\n{{fail password}}", nil, map[string]string{"password": "hunter2"}, "evaluation", "", 3, "hunter2"},
+	{"partial", "{{#each items}}\n{{> item}}{{/each}}", nil, map[string][]string{"items": {"hunter2"}}, "evaluation", "item", 2, "hunter2"},
+	{"partial parse", "{{> broken}}", nil, nil, "parse", "broken", 2, "Syntax error"},
+	{"sandbox", "\n{{{password}}}", &SecurityPolicy{Sandbox: &SandboxPolicy{}}, nil, "sandbox", "", 2, "unescaped output"},
+	{"limit", "{{#each items}}{{this}}{{/each}}", &SecurityPolicy{MaxIterations: 1}, map[string][]int{"items": {1, 2}}, "limit", "", 1, "MaxIterations"},
+}
+
+func TestRedactErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range redactTests {
+		err := func() error {
+			tpl, err := ParseWithOptions(test.input, ParseOptions{RedactErrors: true, Policy: test.policy})
+			if err != nil {
+				return err
+			}
+
+			tpl.RegisterHelper("fail", func(value string) string {
+				panic(errors.New("can't handle " + value))
+			})
+			tpl.RegisterPartial("item", "foo\n{{fail this}}")
+			tpl.RegisterPartial("broken", "{{#if a}}\n{{/if}}{{/if}}")
+
+			_, err = tpl.Exec(test.data)
+			return err
+		}()
+
+		var redacted *RedactedError
+		if !errors.As(err, &redacted) {
+			t.Errorf("Test '%s' failed - Expected a *RedactedError, got: %v", test.name, err)
+			continue
+		}
+
+		if (redacted.Kind != test.kind) || (redacted.Template != test.partial) || (redacted.Line != test.line) {
+			t.Errorf("Test '%s' failed - Unexpected error: %+v", test.name, redacted)
+		}
+
+		if strings.Contains(err.Error(), test.detailed) || (strings.Count(err.Error(), "\n") > 0) {
+			t.Errorf("Test '%s' failed - Error leaks details: %q", test.name, err)
+		}
+
+		if !strings.Contains(errors.Unwrap(err).Error(), test.detailed) {
+			t.Errorf("Test '%s' failed - Expected detailed error to contain %q, got: %q", test.name, test.detailed, errors.Unwrap(err))
+		}
+	}
+}
+
+func TestRedactErrorsMessage(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions("{{> item}}", ParseOptions{RedactErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fail := func() string {
+		panic(errors.New("secret"))
+	}
+
+	tpl.RegisterPartial("item", "\n{{fail}}")
+	tpl.RegisterHelper("fail", fail)
+
+	expected := "Template evaluation error in partial item on line 2"
+
+	if _, err := tpl.Clone().Exec(nil); (err == nil) || (err.Error() != expected) {
+		t.Errorf("Expected error %q, got: %v", expected, err)
+	}
+
+	// error of a partial rendered concurrently
+	tpl, err = ParseWithOptions("{{> ok}}{{> item}}", ParseOptions{RedactErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterPartial("ok", "ok")
+	tpl.RegisterPartial("item", "\n{{fail}}")
+	tpl.RegisterHelper("fail", fail)
+
+	if _, err := tpl.ExecWithOptions(nil, ExecOptions{PartialConcurrency: 2}); (err == nil) || (err.Error() != expected) {
+		t.Errorf("Expected error %q, got: %v", expected, err)
+	}
+
+	tpl = MustParse("{{fail}}")
+	tpl.RegisterHelper("fail", fail)
+
+	if _, err := tpl.Exec(nil); (err == nil) || !strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected detailed error by default, got: %v", err)
+	}
+}
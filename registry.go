@@ -0,0 +1,142 @@
+package raymond
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Helpers, partials and middlewares are stored in copy-on-write registries: evaluations load the current immutable map without taking any lock, while registrations copy the map and atomically replace it.
+//
+// Registrations are rare, and must be serialized by caller.
+
+// helperRegistry is a copy-on-write map of helpers
+type helperRegistry struct {
+	m atomic.Value // map[string]reflect.Value
+}
+
+// load returns current helpers, that must not be modified
+func (r *helperRegistry) load() map[string]reflect.Value {
+	m, _ := r.m.Load().(map[string]reflect.Value)
+	return m
+}
+
+// get returns helper with given name, or zero if not found
+func (r *helperRegistry) get(name string) reflect.Value {
+	return r.load()[name]
+}
+
+// set registers a helper
+func (r *helperRegistry) set(name string, helper reflect.Value) {
+	r.setAll(map[string]reflect.Value{name: helper})
+}
+
+// setAll registers several helpers, with a single copy of the map
+func (r *helperRegistry) setAll(batch map[string]reflect.Value) {
+	old := r.load()
+
+	m := make(map[string]reflect.Value, len(old)+len(batch))
+	for k, v := range old {
+		m[k] = v
+	}
+	for k, v := range batch {
+		m[k] = v
+	}
+
+	r.m.Store(m)
+}
+
+// remove unregisters a helper
+func (r *helperRegistry) remove(name string) {
+	old := r.load()
+
+	m := make(map[string]reflect.Value, len(old))
+	for k, v := range old {
+		if k != name {
+			m[k] = v
+		}
+	}
+
+	r.m.Store(m)
+}
+
+// reset unregisters all helpers
+func (r *helperRegistry) reset() {
+	r.m.Store(map[string]reflect.Value{})
+}
+
+// partialRegistry is a copy-on-write map of partials
+type partialRegistry struct {
+	m atomic.Value // map[string]*partial
+}
+
+// load returns current partials, that must not be modified
+func (r *partialRegistry) load() map[string]*partial {
+	m, _ := r.m.Load().(map[string]*partial)
+	return m
+}
+
+// get returns partial with given name, or nil if not found
+func (r *partialRegistry) get(name string) *partial {
+	return r.load()[name]
+}
+
+// set registers a partial
+func (r *partialRegistry) set(name string, p *partial) {
+	r.setAll(map[string]*partial{name: p})
+}
+
+// setAll registers several partials, with a single copy of the map
+func (r *partialRegistry) setAll(batch map[string]*partial) {
+	old := r.load()
+
+	m := make(map[string]*partial, len(old)+len(batch))
+	for k, v := range old {
+		m[k] = v
+	}
+	for k, v := range batch {
+		m[k] = v
+	}
+
+	r.m.Store(m)
+}
+
+// remove unregisters a partial
+func (r *partialRegistry) remove(name string) {
+	old := r.load()
+
+	m := make(map[string]*partial, len(old))
+	for k, v := range old {
+		if k != name {
+			m[k] = v
+		}
+	}
+
+	r.m.Store(m)
+}
+
+// reset unregisters all partials
+func (r *partialRegistry) reset() {
+	r.m.Store(map[string]*partial{})
+}
+
+// middlewareRegistry is a copy-on-write list of helper middlewares
+type middlewareRegistry struct {
+	m atomic.Value // []HelperMiddleware
+}
+
+// load returns current middlewares, that must not be modified
+func (r *middlewareRegistry) load() []HelperMiddleware {
+	m, _ := r.m.Load().([]HelperMiddleware)
+	return m
+}
+
+// add appends given middlewares
+func (r *middlewareRegistry) add(middlewares ...HelperMiddleware) {
+	old := r.load()
+
+	m := make([]HelperMiddleware, 0, len(old)+len(middlewares))
+	m = append(m, old...)
+	m = append(m, middlewares...)
+
+	r.m.Store(m)
+}
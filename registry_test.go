@@ -0,0 +1,78 @@
+package raymond
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRegistryConcurrency(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{> item}}{{/each}}`)
+	tpl.RegisterPartial("item", `{{upper this}}`)
+	tpl.RegisterHelper("upper", func(str string) string {
+		return "<" + str + ">"
+	})
+
+	ctx := map[string][]string{"items": {"a", "b"}}
+
+	var wg sync.WaitGroup
+
+	// registrations while evaluating
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 50; i++ {
+			tpl.RegisterHelper(fmt.Sprintf("helper%d", i), func() string { return "" })
+			tpl.RegisterPartial(fmt.Sprintf("partial%d", i), "")
+			tpl.UseHelperMiddleware(func(next HelperFunc) HelperFunc { return next })
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				if output := tpl.MustExec(ctx); output != "&lt;a&gt;&lt;b&gt;" {
+					t.Errorf("Unexpected output: %q", output)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if nb := len(tpl.helpers.load()); nb != 51 {
+		t.Errorf("Unexpected number of helpers: %d", nb)
+	}
+
+	if nb := len(tpl.partials.load()); nb != 51 {
+		t.Errorf("Unexpected number of partials: %d", nb)
+	}
+
+	if nb := len(tpl.middlewares.load()); nb != 50 {
+		t.Errorf("Unexpected number of middlewares: %d", nb)
+	}
+}
+
+func TestRegistryClone(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{foo}}`)
+	tpl.RegisterHelper("foo", func() string { return "foo" })
+
+	cloned := tpl.Clone()
+	cloned.RegisterHelper("bar", func() string { return "bar" })
+
+	if tpl.findHelper("bar") != zero {
+		t.Errorf("Helper registered on cloned template leaked to original template")
+	}
+
+	if (cloned.findHelper("foo") == zero) || (cloned.findHelper("bar") == zero) {
+		t.Errorf("Helpers missing on cloned template")
+	}
+}
@@ -0,0 +1,106 @@
+package raymond
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// ValueResolver resolves context values of types that raymond does not traverse natively, eg. protobuf messages.
+//
+// Resolvers are called with values as found in context, and a struct found by value is passed as a pointer when it is addressable, so that types with pointer methods are recognized.
+type ValueResolver interface {
+	// ResolveValue returns the value to evaluate instead of given value, eg. the value of a wrapper type, with true if resolver handles given value. A nil result is an undefined value.
+	ResolveValue(value interface{}) (interface{}, bool)
+
+	// ResolveField returns the field of given value with given name, with true if resolver handles given value and that field exists. A nil result is a field with an undefined value.
+	//
+	// When false is returned, the field is resolved natively, and then in parent contexts if it is still not found.
+	ResolveField(value interface{}, name string) (interface{}, bool)
+}
+
+// valueResolvers are the global value resolvers
+var valueResolvers resolverRegistry
+
+// RegisterValueResolver registers a global value resolver, that is used by all templates.
+//
+// Resolvers are tried in registration order, before native resolution of methods, struct fields, map keys and slice indexes.
+func RegisterValueResolver(resolver ValueResolver) {
+	valueResolvers.add(resolver)
+}
+
+// RemoveAllValueResolvers unregisters all global value resolvers.
+func RemoveAllValueResolvers() {
+	valueResolvers.reset()
+}
+
+// resolverRegistry is a copy-on-write list of value resolvers
+type resolverRegistry struct {
+	m atomic.Value // []ValueResolver
+}
+
+// load returns current resolvers, that must not be modified
+func (r *resolverRegistry) load() []ValueResolver {
+	m, _ := r.m.Load().([]ValueResolver)
+	return m
+}
+
+// add appends given resolver
+func (r *resolverRegistry) add(resolver ValueResolver) {
+	old := r.load()
+
+	m := make([]ValueResolver, 0, len(old)+1)
+	m = append(m, old...)
+	m = append(m, resolver)
+
+	r.m.Store(m)
+}
+
+// reset unregisters all resolvers
+func (r *resolverRegistry) reset() {
+	r.m.Store([]ValueResolver(nil))
+}
+
+// resolverValue returns the value passed to resolvers for given value, and false if it can't be passed
+func resolverValue(val reflect.Value) (interface{}, bool) {
+	if !val.IsValid() || !val.CanInterface() {
+		return nil, false
+	}
+
+	if (val.Kind() == reflect.Struct) && val.CanAddr() {
+		return val.Addr().Interface(), true
+	}
+
+	return val.Interface(), true
+}
+
+// resolveField returns given field of given value with registered resolvers, and false if no resolver handles that value
+func resolveField(resolvers []ValueResolver, ctx reflect.Value, name string) (reflect.Value, bool) {
+	value, ok := resolverValue(ctx)
+	if !ok {
+		return zero, false
+	}
+
+	for _, resolver := range resolvers {
+		if result, ok := resolver.ResolveField(value, name); ok {
+			return reflect.ValueOf(result), true
+		}
+	}
+
+	return zero, false
+}
+
+// resolveValue returns the value to evaluate instead of given value with registered resolvers, or given value if no resolver handles it
+func resolveValue(resolvers []ValueResolver, val reflect.Value) reflect.Value {
+	value, ok := resolverValue(val)
+	if !ok {
+		return val
+	}
+
+	for _, resolver := range resolvers {
+		if result, ok := resolver.ResolveValue(value); ok {
+			return reflect.ValueOf(result)
+		}
+	}
+
+	return val
+}
@@ -0,0 +1,70 @@
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+// record is a context value with dynamic fields
+type record struct {
+	fields map[string]interface{}
+}
+
+// optional is a context value that may be undefined
+type optional struct {
+	value interface{}
+	valid bool
+}
+
+// testResolver resolves records and optionals
+type testResolver struct{}
+
+func (testResolver) ResolveValue(value interface{}) (interface{}, bool) {
+	if opt, ok := value.(*optional); ok {
+		value = *opt
+	}
+
+	if opt, ok := value.(optional); ok {
+		if !opt.valid {
+			return nil, true
+		}
+
+		return opt.value, true
+	}
+
+	return nil, false
+}
+
+func (testResolver) ResolveField(value interface{}, name string) (interface{}, bool) {
+	if rec, ok := value.(*record); ok {
+		value = *rec
+	}
+
+	if rec, ok := value.(record); ok {
+		return rec.fields[strings.ToLower(name)], true
+	}
+
+	return nil, false
+}
+
+// not parallel: resolvers are global
+func TestValueResolver(t *testing.T) {
+	RegisterValueResolver(testResolver{})
+	defer RemoveAllValueResolvers()
+
+	ctx := map[string]interface{}{
+		"user": &record{fields: map[string]interface{}{
+			"name":  "Jean",
+			"tags":  []string{"a", "b"},
+			"phone": optional{valid: false},
+			"email": optional{value: "jean@example.com", valid: true},
+		}},
+		"users": []record{{fields: map[string]interface{}{"name": "Ann"}}},
+	}
+
+	tpl := MustParse(`{{user.NAME}} {{#each user.tags}}{{.}}{{/each}} {{#if user.phone}}phone{{else}}no phone{{/if}} {{user.email}} {{user.fields}} {{#each users}}{{name}}{{/each}}`)
+
+	if output := tpl.MustExec(ctx); output != "Jean ab no phone jean@example.com  Ann" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
@@ -0,0 +1,240 @@
+package raymond
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// SandboxPolicy restricts the helpers, partials, unescaped output and context members that a template is allowed to use, so that templates authored by untrusted parties (eg. the customers of a SaaS product) can be rendered safely. It is set with the Sandbox field of a SecurityPolicy, along with resource limits.
+//
+// The policy is checked when template is parsed, and enforced again during each evaluation, including for partials. Decorators and custom delimiters are not supported by raymond, so they can't be used by sandboxed templates either.
+type SandboxPolicy struct {
+	// Helpers is the list of helpers that template is allowed to call, including built-in ones (eg. "if", "each"). Other helpers are not resolved, so their names are looked up in context like any other field, and calling them with arguments is a violation.
+	Helpers []string
+
+	// Partials is the list of partials that template is allowed to include, including dynamic ones.
+	Partials []string
+
+	// AllowUnescaped permits triple-stash and {{& }} mustaches, that output values without escaping them.
+	AllowUnescaped bool
+
+	// DeniedMembers is the list of struct fields and methods that template is not allowed to resolve on context values, either by Go name (eg. "Delete") or qualified with the name of the struct type (eg. "User.Password"). Resolving them is a violation.
+	DeniedMembers []string
+
+	// DenyMethods forbids calling the methods of context values, and the functions found in context. Resolving them is a violation.
+	DenyMethods bool
+}
+
+// SandboxError is the error returned when a template violates its sandbox policy.
+type SandboxError struct {
+	// Line is the line of offending statement in template source, or zero if unknown
+	Line int
+
+	// Reason describes the violation
+	Reason string
+}
+
+// Error implements the error interface.
+func (err *SandboxError) Error() string {
+	if err.Line == 0 {
+		return fmt.Sprintf("Sandbox violation: %s", err.Reason)
+	}
+
+	return fmt.Sprintf("Sandbox violation on line %d: %s", err.Line, err.Reason)
+}
+
+// sandbox is a sandbox policy prepared for lookups
+type sandbox struct {
+	policy   SandboxPolicy
+	helpers  map[string]bool
+	partials map[string]bool
+	members  map[string]bool
+}
+
+// newSandbox instanciates a new sandbox with given policy, or returns nil if policy is nil
+func newSandbox(policy *SandboxPolicy) *sandbox {
+	if policy == nil {
+		return nil
+	}
+
+	result := &sandbox{
+		policy:   *policy,
+		helpers:  make(map[string]bool, len(policy.Helpers)),
+		partials: make(map[string]bool, len(policy.Partials)),
+		members:  make(map[string]bool, len(policy.DeniedMembers)),
+	}
+
+	for _, name := range policy.Helpers {
+		result.helpers[name] = true
+	}
+
+	for _, name := range policy.Partials {
+		result.partials[name] = true
+	}
+
+	for _, name := range policy.DeniedMembers {
+		result.members[name] = true
+	}
+
+	return result
+}
+
+// check returns a *SandboxError if given program violates the sandbox policy
+//
+// Only violations that can be detected without a context are reported: helper calls with arguments, static partial names and unescaped mustaches.
+func (s *sandbox) check(program *ast.Program) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			violation, ok := e.(*SandboxError)
+			if !ok {
+				panic(e)
+			}
+
+			err = violation
+		}
+	}()
+
+	s.checkProgram(program)
+
+	return nil
+}
+
+// checkProgram checks given program and its nested programs, and panics with a *SandboxError on violation
+func (s *sandbox) checkProgram(program *ast.Program) {
+	if program == nil {
+		return
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.MustacheStatement:
+			if n.Unescaped && !s.policy.AllowUnescaped {
+				panic(&SandboxError{Line: n.Line, Reason: "unescaped output is not allowed"})
+			}
+
+			s.checkExpression(n.Expression, false)
+		case *ast.BlockStatement:
+			s.checkExpression(n.Expression, false)
+			s.checkProgram(n.Program)
+			s.checkProgram(n.Inverse)
+		case *ast.PartialStatement:
+			if name, ok := ast.HelperNameStr(n.Name); ok && !s.partials[name] {
+				panic(&SandboxError{Line: n.Line, Reason: fmt.Sprintf("partial %s is not allowed", name)})
+			}
+
+			s.checkNode(n.Name)
+			s.checkNodes(n.Params)
+			s.checkHash(n.Hash)
+		}
+	}
+}
+
+// checkExpression checks given expression, that is necessarily a helper call if call is true
+func (s *sandbox) checkExpression(node *ast.Expression, call bool) {
+	if call || (len(node.Params) > 0) || (node.Hash != nil) {
+		name := node.HelperName()
+		if name == "" {
+			name = node.NamespacedHelperName()
+		}
+
+		if (name != "") && !s.helpers[name] {
+			panic(&SandboxError{Line: node.Line, Reason: fmt.Sprintf("helper %s is not allowed", name)})
+		}
+	}
+
+	s.checkNode(node.Path)
+	s.checkNodes(node.Params)
+	s.checkHash(node.Hash)
+}
+
+// checkNodes checks the subexpressions of given nodes
+func (s *sandbox) checkNodes(nodes []ast.Node) {
+	for _, node := range nodes {
+		s.checkNode(node)
+	}
+}
+
+// checkHash checks the subexpressions of given hash
+func (s *sandbox) checkHash(node *ast.Hash) {
+	if node == nil {
+		return
+	}
+
+	for _, pair := range node.Pairs {
+		s.checkNode(pair.Val)
+	}
+}
+
+// checkNode checks given node if it is a subexpression
+func (s *sandbox) checkNode(node ast.Node) {
+	if sub, ok := node.(*ast.SubExpression); ok {
+		s.checkExpression(sub.Expression, true)
+	}
+}
+
+// checkSandbox returns a *SandboxError if parsed program of template violates its sandbox policy
+func (tpl *Template) checkSandbox() error {
+	if tpl.sandbox == nil {
+		return nil
+	}
+
+	if err := tpl.sandbox.check(tpl.program); err != nil {
+		tpl.program = nil
+		return err
+	}
+
+	return nil
+}
+
+// allowHelper returns true if given helper can be called by template
+func (s *sandbox) allowHelper(name string) bool {
+	return (s == nil) || s.helpers[name]
+}
+
+//
+// Evaluation
+//
+
+// checkUnescaped panics with a *SandboxError if unescaped output is not allowed
+func (v *evalVisitor) checkUnescaped(node *ast.MustacheStatement) {
+	if s := v.sandbox; (s != nil) && !s.policy.AllowUnescaped {
+		panic(&SandboxError{Line: node.Line, Reason: "unescaped output is not allowed"})
+	}
+}
+
+// allowMember returns false if resolving given member of given struct type is denied
+func (s *sandbox) allowMember(typ reflect.Type, member string) bool {
+	return (s == nil) || !(s.members[member] || s.members[typ.Name()+"."+member])
+}
+
+// checkPartial panics with a *SandboxError if given partial is not allowed
+func (v *evalVisitor) checkPartial(name string, node *ast.PartialStatement) {
+	if s := v.sandbox; (s != nil) && !s.partials[name] {
+		panic(&SandboxError{Line: node.Line, Reason: fmt.Sprintf("partial %s is not allowed", name)})
+	}
+}
+
+// checkMember panics with a *SandboxError if resolving the member of given plan on given context is not allowed
+func (v *evalVisitor) checkMember(ctx reflect.Value, plan *fieldPlan) {
+	s := v.sandbox
+	if (s == nil) || (plan.member == "") {
+		return
+	}
+
+	if (plan.method >= 0) && s.policy.DenyMethods {
+		panic(&SandboxError{Line: v.curLine(), Reason: fmt.Sprintf("method %s is not allowed", plan.member)})
+	}
+
+	if !s.allowMember(ctx.Type(), plan.member) {
+		panic(&SandboxError{Line: v.curLine(), Reason: fmt.Sprintf("member %s is not allowed", plan.member)})
+	}
+}
+
+// checkFunc panics with a *SandboxError if calling given function found in context is not allowed
+func (v *evalVisitor) checkFunc(name string) {
+	if s := v.sandbox; (s != nil) && s.policy.DenyMethods {
+		panic(&SandboxError{Line: v.curLine(), Reason: fmt.Sprintf("function %s is not allowed", name)})
+	}
+}
@@ -0,0 +1,290 @@
+package raymond
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var sandboxPolicy = SecurityPolicy{
+	MaxDepth:      4,
+	MaxIterations: 10,
+	MaxOutput:     100,
+	Sandbox: &SandboxPolicy{
+		Helpers:  []string{"if", "each", "lookup", "upper"},
+		Partials: []string{"item", "loop"},
+	},
+}
+
+var sandboxTests = []struct {
+	name   string
+	input  string
+	data   interface{}
+	output string
+	err    string
+}{
+	{
+		"allowed",
+		`{{#if ok}}{{#each items}}{{upper this}}{{> item}}{{/each}}{{/if}}`,
+		map[string]interface{}{"ok": true, "items": []string{"a", "b"}},
+		"A[a]B[b]",
+		"",
+	},
+	{
+		"helper not resolved",
+		`{{log}} {{uuid}}`,
+		map[string]string{"log": "field", "uuid": "<id>"},
+		"field &lt;id&gt;",
+		"",
+	},
+	{
+		"helper call",
+		"\n{{equal a b}}",
+		nil,
+		"",
+		"Sandbox violation on line 2: helper equal is not allowed",
+	},
+	{
+		"block helper call",
+		`{{#with author}}{{/with}}`,
+		nil,
+		"",
+		"Sandbox violation on line 1: helper with is not allowed",
+	},
+	{
+		"subexpression",
+		`{{upper (concat "a" "b")}}`,
+		nil,
+		"",
+		"Sandbox violation on line 1: helper concat is not allowed",
+	},
+	{
+		"unescaped",
+		`{{{html}}}`,
+		nil,
+		"",
+		"Sandbox violation on line 1: unescaped output is not allowed",
+	},
+	{
+		"unescaped ampersand",
+		`{{& html}}`,
+		nil,
+		"",
+		"Sandbox violation on line 1: unescaped output is not allowed",
+	},
+	{
+		"partial",
+		`{{> secret}}`,
+		nil,
+		"",
+		"Sandbox violation on line 1: partial secret is not allowed",
+	},
+	{
+		"dynamic partial",
+		`{{> (lookup . "name")}}`,
+		map[string]string{"name": "secret"},
+		"",
+		"Sandbox violation on line 1: partial secret is not allowed",
+	},
+	{
+		"unescaped in partial",
+		`{{> item}}`,
+		map[string]interface{}{"raw": true},
+		"",
+		"Sandbox violation on line 1: unescaped output is not allowed",
+	},
+	{
+		"depth",
+		`{{> loop}}`,
+		map[string]interface{}{},
+		"",
+		"Security policy limit exceeded on line 1: MaxDepth",
+	},
+	{
+		"iterations",
+		`{{#each items}}{{this}}{{/each}}`,
+		map[string]interface{}{"items": make([]int, 11)},
+		"",
+		"Security policy limit exceeded on line 1: MaxIterations",
+	},
+	{
+		"output",
+		`{{#each items}}{{this}}{{/each}}`,
+		map[string]interface{}{"items": []string{strings.Repeat("x", 60), strings.Repeat("x", 60)}},
+		"",
+		"Memory budget exceeded",
+	},
+}
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range sandboxTests {
+		tpl, err := ParseWithOptions(test.input, ParseOptions{Policy: &sandboxPolicy})
+		if err == nil {
+			tpl.RegisterHelper("upper", strings.ToUpper)
+			tpl.RegisterPartial("item", `{{#if raw}}{{{raw}}}{{else}}[{{this}}]{{/if}}`)
+			tpl.RegisterPartial("loop", `{{#if true}}{{> loop}}{{/if}}`)
+			tpl.RegisterPartial("secret", `secret`)
+
+			var output string
+			if output, err = tpl.Exec(test.data); (err == nil) && (output != test.output) {
+				t.Errorf("Test '%s' failed\ninput:\n\t%s\nexpected:\n\t%s\ngot:\n\t%s", test.name, test.input, test.output, output)
+			}
+		}
+
+		switch {
+		case (err == nil) && (test.err != ""):
+			t.Errorf("Test '%s' failed - Expected error: %s", test.name, test.err)
+		case (err != nil) && (test.err == ""):
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		case (err != nil) && !strings.Contains(err.Error(), test.err):
+			t.Errorf("Test '%s' failed - Expected error %q, got %q", test.name, test.err, err)
+		}
+	}
+}
+
+func TestSandboxError(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseWithOptions(`{{{html}}}`, ParseOptions{Policy: &SecurityPolicy{Sandbox: &SandboxPolicy{}}})
+
+	var sandboxErr *SandboxError
+	if !errors.As(err, &sandboxErr) || (sandboxErr.Line != 1) {
+		t.Errorf("Expected a *SandboxError, got: %v", err)
+	}
+
+	tpl, err := ParseWithOptions(`{{{html}}}`, ParseOptions{Policy: &SecurityPolicy{Sandbox: &SandboxPolicy{AllowUnescaped: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output := tpl.MustExec(map[string]string{"html": "<b>"}); output != "<b>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestSandboxTrusted(t *testing.T) {
+	t.Parallel()
+
+	// the same partials are rendered without restrictions by trusted templates
+	tpl := MustParse(`{{#each items}}{{> item}}{{/each}}`)
+	tpl.RegisterPartial("item", `{{{lookup this "html"}}}`)
+
+	items := make([]map[string]string, 20)
+	for i := range items {
+		items[i] = map[string]string{"html": "<b>"}
+	}
+
+	if output := tpl.MustExec(map[string]interface{}{"items": items}); output != strings.Repeat("<b>", 20) {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestSandboxBinary(t *testing.T) {
+	t.Parallel()
+
+	data, err := MustParse(`{{lookup a b}}`).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseBinaryWithOptions(data, ParseOptions{Policy: &SecurityPolicy{Sandbox: &SandboxPolicy{}}}); err == nil {
+		t.Errorf("Expected sandbox violation when loading binary template")
+	}
+}
+
+type sandboxAccount struct {
+	Name     string
+	Password string
+	Owner    *sandboxUser
+	Hook     func() string
+}
+
+type sandboxUser struct {
+	Login string
+	Token string `handlebars:"apiToken"`
+}
+
+func (a *sandboxAccount) Delete() string {
+	return "deleted"
+}
+
+func (a sandboxAccount) Title() string {
+	return "Account " + a.Name
+}
+
+var sandboxMembersTests = []struct {
+	name   string
+	policy SandboxPolicy
+	input  string
+	output string
+	err    string
+}{
+	{"no restriction", SandboxPolicy{}, `{{name}} {{title}} {{delete}} {{hook}}`, "foo Account foo deleted hook", ""},
+	{"allowed members", SandboxPolicy{DeniedMembers: []string{"Password", "Delete"}}, `{{name}} {{title}} {{owner.login}}`, "foo Account foo bar", ""},
+	{"denied field", SandboxPolicy{DeniedMembers: []string{"Password"}}, "\n{{password}}", "", "Sandbox violation on line 2: member Password is not allowed"},
+	{"denied method", SandboxPolicy{DeniedMembers: []string{"Delete"}}, `{{delete}}`, "", "Sandbox violation on line 1: member Delete is not allowed"},
+	{"denied tagged field", SandboxPolicy{DeniedMembers: []string{"Token"}}, `{{owner.apiToken}}`, "", "Sandbox violation on line 1: member Token is not allowed"},
+	{"qualified member", SandboxPolicy{DeniedMembers: []string{"sandboxUser.Login"}}, `{{name}}{{owner.login}}`, "", "Sandbox violation on line 1: member Login is not allowed"},
+	{"qualified member of other type", SandboxPolicy{DeniedMembers: []string{"sandboxUser.Name"}}, `{{name}}`, "foo", ""},
+	{"in block", SandboxPolicy{DeniedMembers: []string{"Login"}}, `{{#with owner}}{{login}}{{/with}}`, "", "Sandbox violation on line 1: member Login is not allowed"},
+	{"each skips denied fields", SandboxPolicy{DeniedMembers: []string{"Token"}}, `{{#each owner}}{{@key}}={{this}};{{/each}}`, "Login=bar;", ""},
+	{"each skips qualified denied fields", SandboxPolicy{DeniedMembers: []string{"sandboxUser.Login"}}, `{{#each owner}}{{@key}}={{this}};{{/each}}`, "Token=token;", ""},
+	{"deny methods", SandboxPolicy{DenyMethods: true}, `{{name}}{{title}}`, "", "Sandbox violation on line 1: method Title is not allowed"},
+	{"deny pointer methods", SandboxPolicy{DenyMethods: true}, `{{#if delete}}{{/if}}`, "", "Sandbox violation on line 1: method Delete is not allowed"},
+	{"deny functions", SandboxPolicy{DenyMethods: true}, `{{hook}}`, "", "Sandbox violation on line 1: function hook is not allowed"},
+	{"deny methods with fields", SandboxPolicy{DenyMethods: true}, `{{name}} {{owner.login}}`, "foo bar", ""},
+}
+
+func TestSandboxMembers(t *testing.T) {
+	skipTiny(t)
+	t.Parallel()
+
+	for _, test := range sandboxMembersTests {
+		test.policy.Helpers = []string{"if", "with", "each"}
+
+		tpl, err := ParseWithOptions(test.input, ParseOptions{Policy: &SecurityPolicy{Sandbox: &test.policy}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		account := &sandboxAccount{
+			Name:     "foo",
+			Password: "secret",
+			Owner:    &sandboxUser{Login: "bar", Token: "token"},
+			Hook:     func() string { return "hook" },
+		}
+
+		output, err := tpl.Exec(account)
+
+		switch {
+		case test.err != "":
+			var sandboxErr *SandboxError
+			if !errors.As(err, &sandboxErr) || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test '%s' failed - Expected error %q, got %v", test.name, test.err, err)
+			}
+		case err != nil:
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+		case output != test.output:
+			t.Errorf("Test '%s' failed\nexpected:\n\t%s\ngot:\n\t%s", test.name, test.output, output)
+		}
+	}
+}
+
+func TestSandboxMembersMerge(t *testing.T) {
+	skipTiny(t)
+	t.Parallel()
+
+	tpl, err := ParseWithOptions(`{{name}}{{password}}`, ParseOptions{Policy: &SecurityPolicy{Sandbox: &SandboxPolicy{DeniedMembers: []string{"Delete"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &SecurityPolicy{Sandbox: &SandboxPolicy{DeniedMembers: []string{"Password"}}}
+
+	_, err = tpl.ExecWithOptions(&sandboxAccount{Name: "foo"}, ExecOptions{Policy: policy})
+	if (err == nil) || !strings.Contains(err.Error(), "member Password is not allowed") {
+		t.Errorf("Expected denied member of evaluation policy, got: %v", err)
+	}
+}
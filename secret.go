@@ -0,0 +1,167 @@
+package raymond
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Redacted replaces secret values in evaluation errors and logs.
+const Redacted = "[REDACTED]"
+
+// SecretClassifier returns true if given value, resolved from given path (eg. "user.password"), is a secret.
+//
+// The path of a subexpression result is the name of called helper.
+type SecretClassifier func(path string, value interface{}) bool
+
+var (
+	// secretClassifiers stores all registered classifiers: []SecretClassifier
+	secretClassifiers atomic.Value
+
+	// serializes classifiers registrations
+	secretClassifiersMutex sync.Mutex
+)
+
+// RegisterSecretClassifier registers a classifier that flags secret context values, so that they are redacted from evaluation errors and from {{log}} output.
+//
+// Values are classified as they are resolved, so that helper errors including them (eg. a config renderer failing on a malformed credential) do not leak them. Classifiers are called for each resolved value, and must be safe for concurrent use.
+func RegisterSecretClassifier(classifier SecretClassifier) {
+	secretClassifiersMutex.Lock()
+	defer secretClassifiersMutex.Unlock()
+
+	old := loadSecretClassifiers()
+
+	classifiers := make([]SecretClassifier, len(old), len(old)+1)
+	copy(classifiers, old)
+
+	secretClassifiers.Store(append(classifiers, classifier))
+}
+
+// RemoveAllSecretClassifiers unregisters all secret classifiers.
+func RemoveAllSecretClassifiers() {
+	secretClassifiersMutex.Lock()
+	defer secretClassifiersMutex.Unlock()
+
+	secretClassifiers.Store([]SecretClassifier(nil))
+}
+
+// loadSecretClassifiers returns registered classifiers, that must not be modified
+func loadSecretClassifiers() []SecretClassifier {
+	classifiers, _ := secretClassifiers.Load().([]SecretClassifier)
+	return classifiers
+}
+
+// SecretFields returns a classifier that flags the values of fields with given names, whatever their parents, case insensitively.
+//
+// For example, SecretFields("password", "apiKey") flags {{user.password}}, {{../APIKey}} and {{@password}}.
+func SecretFields(names ...string) SecretClassifier {
+	return func(path string, value interface{}) bool {
+		field := strings.TrimPrefix(path, "@")
+		if i := strings.LastIndexAny(field, "./"); i >= 0 {
+			field = field[i+1:]
+		}
+
+		for _, name := range names {
+			if strings.EqualFold(field, name) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// secretSet stores the string representations of the secret values resolved by an evaluation, shared with partials rendered concurrently
+type secretSet struct {
+	classifiers []SecretClassifier
+
+	mutex  sync.RWMutex
+	values []string
+}
+
+// newSecretSet instanciates a new secret set, or returns nil if no classifier is registered
+func newSecretSet() *secretSet {
+	classifiers := loadSecretClassifiers()
+	if len(classifiers) == 0 {
+		return nil
+	}
+
+	return &secretSet{classifiers: classifiers}
+}
+
+// classify records given value if it is a secret
+func (s *secretSet) classify(path string, value interface{}) {
+	if value == nil {
+		return
+	}
+
+	for _, classifier := range s.classifiers {
+		if classifier(path, value) {
+			s.add(Str(value))
+			return
+		}
+	}
+}
+
+// add records given secret string
+func (s *secretSet) add(secret string) {
+	if secret == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, value := range s.values {
+		if value == secret {
+			return
+		}
+	}
+
+	s.values = append(s.values, secret)
+}
+
+// reset forgets recorded secrets
+func (s *secretSet) reset() {
+	s.mutex.Lock()
+	s.values = s.values[:0]
+	s.mutex.Unlock()
+}
+
+// redact replaces all recorded secrets in given string
+func (s *secretSet) redact(str string) string {
+	if s == nil {
+		return str
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, secret := range s.values {
+		str = strings.Replace(str, secret, Redacted, -1)
+	}
+
+	return str
+}
+
+// classify records the value resolved from given path if it is a secret
+func (v *evalVisitor) classify(path string, value interface{}) {
+	if v.secrets != nil {
+		v.secrets.classify(path, value)
+	}
+}
+
+// redactError replaces given error by a redacted one if its message contains a secret
+//
+// The original error is not wrapped, so that it can't leak secrets.
+func (v *evalVisitor) redactError(errp *error) {
+	if (*errp == nil) || (v.secrets == nil) {
+		return
+	}
+
+	msg := (*errp).Error()
+	if redacted := v.secrets.redact(msg); redacted != msg {
+		*errp = errors.New(redacted)
+	}
+}
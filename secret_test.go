@@ -0,0 +1,87 @@
+package raymond
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	RegisterSecretClassifier(SecretFields("dbPassword", "token"))
+	RegisterSecretClassifier(func(path string, value interface{}) bool {
+		return strings.HasPrefix(Str(value), "sk_")
+	})
+	defer RemoveAllSecretClassifiers()
+
+	tpl := MustParse(`{{#with db}}{{connect host dbPassword}}{{/with}}`)
+	tpl.RegisterHelper("connect", func(host string, password string) string {
+		panic(fmt.Errorf("failed to connect to %s with password %s", host, password))
+	})
+
+	ctx := map[string]interface{}{
+		"db": map[string]string{"host": "db.local", "dbPassword": "hunter2"},
+	}
+
+	_, err := tpl.Exec(ctx)
+	if (err == nil) || strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), "failed to connect to db.local with password [REDACTED]") {
+		t.Errorf("Expected redacted error, got: %v", err)
+	}
+
+	// secret values are still rendered
+	tpl = MustParse(`{{token}} {{key}} {{host}}`)
+	if output := tpl.MustExec(map[string]string{"token": "abc", "key": "sk_123", "host": "h"}); output != "abc sk_123 h" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	// logs
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	tpl = MustParse(`{{log token}}{{log (concat "key=" key)}}{{log host}}`)
+	tpl.MustExec(map[string]string{"token": "abc", "key": "sk_123", "host": "h"})
+
+	if logs := buf.String(); strings.Contains(logs, "abc") || strings.Contains(logs, "sk_123") || !strings.Contains(logs, "[REDACTED]\n") || !strings.Contains(logs, "key=[REDACTED]\n") || !strings.Contains(logs, "h\n") {
+		t.Errorf("Unexpected logs: %q", logs)
+	}
+}
+
+func TestSecretFields(t *testing.T) {
+	t.Parallel()
+
+	classifier := SecretFields("password", "apiKey")
+
+	tests := []struct {
+		path   string
+		secret bool
+	}{
+		{"password", true},
+		{"user.password", true},
+		{"../APIKey", true},
+		{"@password", true},
+		{"user.passwords", false},
+		{"password.length", false},
+	}
+
+	for _, test := range tests {
+		if secret := classifier(test.path, "x"); secret != test.secret {
+			t.Errorf("Unexpected classification of %s: %v", test.path, secret)
+		}
+	}
+}
+
+func TestSecretRedactionDisabled(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{fail password}}`)
+	tpl.RegisterHelper("fail", func(str string) string {
+		panic(fmt.Errorf("invalid %s", str))
+	})
+
+	if _, err := tpl.Exec(map[string]string{"password": "hunter2"}); (err == nil) || !strings.Contains(err.Error(), "invalid hunter2") {
+		t.Errorf("Unexpected error without classifiers: %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package raymond
+
+import (
+	"strings"
+
+	"github.com/aymerick/raymond/lexer"
+)
+
+// SemanticKind is the kind of a SemanticToken.
+type SemanticKind int
+
+const (
+	// SemanticContent is content outside mustaches
+	SemanticContent SemanticKind = iota
+
+	// SemanticComment is a comment, with its delimiters
+	SemanticComment
+
+	// SemanticDelimiter is a mustache, subexpression, hash or block params delimiter, eg. "{{#", ")", "=" or "|"
+	SemanticDelimiter
+
+	// SemanticKeyword is the "else" of inverse sections, or the "as" of block params
+	SemanticKeyword
+
+	// SemanticHelper is a helper name
+	SemanticHelper
+
+	// SemanticPath is a context path, eg. "../author.name"
+	SemanticPath
+
+	// SemanticData is a data variable, eg. "@index"
+	SemanticData
+
+	// SemanticBlockParam is a block param declaration, eg. "item" in "as |item|"
+	SemanticBlockParam
+
+	// SemanticHashKey is the key of a hash argument
+	SemanticHashKey
+
+	// SemanticPartial is a partial name
+	SemanticPartial
+
+	// SemanticString is a string literal, with its quotes
+	SemanticString
+
+	// SemanticNumber is a number literal
+	SemanticNumber
+
+	// SemanticBoolean is a boolean literal
+	SemanticBoolean
+)
+
+// semanticKinds are the names and TextMate scopes of semantic kinds
+var semanticKinds = [...]struct {
+	name  string
+	scope string
+}{
+	SemanticContent:    {"content", "text.html.handlebars"},
+	SemanticComment:    {"comment", "comment.block.handlebars"},
+	SemanticDelimiter:  {"delimiter", "punctuation.definition.tag.handlebars"},
+	SemanticKeyword:    {"keyword", "keyword.control.handlebars"},
+	SemanticHelper:     {"helper", "entity.name.function.handlebars"},
+	SemanticPath:       {"path", "variable.other.handlebars"},
+	SemanticData:       {"data", "variable.language.handlebars"},
+	SemanticBlockParam: {"blockParam", "variable.parameter.handlebars"},
+	SemanticHashKey:    {"hashKey", "entity.other.attribute-name.handlebars"},
+	SemanticPartial:    {"partial", "entity.name.tag.partial.handlebars"},
+	SemanticString:     {"string", "string.quoted.handlebars"},
+	SemanticNumber:     {"number", "constant.numeric.handlebars"},
+	SemanticBoolean:    {"boolean", "constant.language.boolean.handlebars"},
+}
+
+// String returns the name of kind, eg. "helper".
+func (kind SemanticKind) String() string {
+	return semanticKinds[kind].name
+}
+
+// Scope returns the TextMate scope of kind, eg. "entity.name.function.handlebars".
+func (kind SemanticKind) Scope() string {
+	return semanticKinds[kind].scope
+}
+
+// SemanticToken is a highlighted range of a template source.
+type SemanticToken struct {
+	Kind SemanticKind
+
+	// Pos is the byte offset of the first character in source
+	Pos int
+
+	// End is the byte offset after the last character in source
+	End int
+
+	// Line is the line of the first character in source, starting at 1
+	Line int
+}
+
+// semanticFrame is a mustache or subexpression being tokenized
+type semanticFrame struct {
+	// kind of opening token
+	open lexer.TokenKind
+
+	// true until its name, ie. its first element, is tokenized
+	name bool
+}
+
+// SemanticTokens returns the semantic tokens of given template source, in source order, for editors and highlighters.
+//
+// Tokens are computed from the lexer, and classified like the parser does: helper names, paths, data variables, hash keys, block params, partial names and literals. A name without arguments is a helper if it is a registered global helper, and a path otherwise. Whitespace inside mustaches and the backslash of escaped mustaches are not part of any token.
+//
+// Tokens are returned up to the first lexer error, even if source can't be parsed: the parse error is then returned too.
+func SemanticTokens(source string) ([]SemanticToken, error) {
+	tpl := newTemplate(source)
+
+	result := tpl.SemanticTokens()
+
+	return result, tpl.parse()
+}
+
+// SemanticTokens returns the semantic tokens of template source, where helpers registered on that template are also recognized. See SemanticTokens().
+func (tpl *Template) SemanticTokens() []SemanticToken {
+	return semanticTokens(tpl.source, func(name string) bool {
+		_, ok := tpl.Helper(name)
+		return ok
+	})
+}
+
+// semanticTokens returns the semantic tokens of given source, with given function that checks if a name is a registered helper
+func semanticTokens(source string, isHelper func(name string) bool) []SemanticToken {
+	var result []SemanticToken
+
+	// lines are counted from offsets, as the lexer does not count newlines in ignored whitespace
+	line, linePos := 1, 0
+
+	emit := func(kind SemanticKind, pos int, end int) {
+		if end <= pos {
+			return
+		}
+
+		line += strings.Count(source[linePos:pos], "\n")
+		linePos = pos
+
+		result = append(result, SemanticToken{Kind: kind, Pos: pos, End: end, Line: line})
+	}
+
+	// opened mustache and subexpressions
+	var frames []*semanticFrame
+
+	// kinds of the names of opened blocks, so that end blocks get the same kind
+	var blocks []SemanticKind
+
+	inBlockParams := false
+
+	// element emits an element of a mustache or subexpression
+	element := func(kind SemanticKind, pos int, end int) {
+		if (len(frames) > 0) && frames[len(frames)-1].name {
+			frame := frames[len(frames)-1]
+			frame.name = false
+
+			switch frame.open {
+			case lexer.TokenOpenBlock, lexer.TokenOpenInverse, lexer.TokenOpenRawBlock:
+				blocks = append(blocks, kind)
+			}
+		}
+
+		emit(kind, pos, end)
+	}
+
+	tokens := lexer.Collect(source)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		end := tok.End
+
+		switch tok.Kind {
+		case lexer.TokenContent:
+			emit(SemanticContent, tok.Pos, end)
+		case lexer.TokenComment:
+			emit(SemanticComment, tok.Pos, end)
+		case lexer.TokenOpen, lexer.TokenOpenUnescaped, lexer.TokenOpenBlock, lexer.TokenOpenEndBlock, lexer.TokenOpenRawBlock, lexer.TokenOpenEndRawBlock, lexer.TokenOpenInverse, lexer.TokenOpenPartial, lexer.TokenOpenSexpr:
+			emit(SemanticDelimiter, tok.Pos, end)
+			frames = append(frames, &semanticFrame{open: tok.Kind, name: true})
+		case lexer.TokenInverse, lexer.TokenOpenInverseChain:
+			// eg. "{{~ else ~}}" or "{{else"
+			if k := strings.Index(tok.Val, "else"); k != -1 {
+				emit(SemanticDelimiter, tok.Pos, tok.Pos+len(strings.TrimRight(tok.Val[:k], " \t\r\n")))
+				emit(SemanticKeyword, tok.Pos+k, tok.Pos+k+4)
+				emit(SemanticDelimiter, end-len(strings.TrimLeft(tok.Val[k+4:], " \t\r\n")), end)
+			} else {
+				emit(SemanticDelimiter, tok.Pos, end)
+			}
+
+			if tok.Kind == lexer.TokenOpenInverseChain {
+				frames = append(frames, &semanticFrame{open: tok.Kind, name: true})
+			}
+		case lexer.TokenClose, lexer.TokenCloseUnescaped, lexer.TokenCloseRawBlock:
+			emit(SemanticDelimiter, tok.Pos, end)
+			frames, inBlockParams = nil, false
+		case lexer.TokenCloseSexpr:
+			emit(SemanticDelimiter, tok.Pos, end)
+
+			if len(frames) > 0 {
+				frames = frames[:len(frames)-1]
+			}
+		case lexer.TokenEquals:
+			emit(SemanticDelimiter, tok.Pos, end)
+		case lexer.TokenOpenBlockParams:
+			// eg. "as |"
+			emit(SemanticKeyword, tok.Pos, tok.Pos+2)
+			emit(SemanticDelimiter, end-1, end)
+			inBlockParams = true
+		case lexer.TokenCloseBlockParams:
+			emit(SemanticDelimiter, tok.Pos, end)
+			inBlockParams = false
+		case lexer.TokenString:
+			kind := SemanticString
+			if frame := semanticTop(frames); (frame != nil) && frame.name && (frame.open == lexer.TokenOpenPartial) {
+				kind = SemanticPartial
+			}
+
+			// source of string excludes its delimiters
+			element(kind, tok.Pos-1, tok.End+1)
+		case lexer.TokenNumber:
+			element(SemanticNumber, tok.Pos, end)
+		case lexer.TokenBoolean:
+			element(SemanticBoolean, tok.Pos, end)
+		case lexer.TokenNull, lexer.TokenUndefined:
+			element(SemanticKeyword, tok.Pos, end)
+		case lexer.TokenChainHelper:
+			element(SemanticHelper, tok.Pos, end)
+		case lexer.TokenData:
+			if (i+1 < len(tokens)) && (tokens[i+1].Kind == lexer.TokenID) {
+				i, end = semanticPathEnd(tokens, i+1)
+			}
+
+			element(SemanticData, tok.Pos, end)
+		case lexer.TokenID:
+			switch {
+			case inBlockParams:
+				emit(SemanticBlockParam, tok.Pos, end)
+				continue
+			case (i+1 < len(tokens)) && (tokens[i+1].Kind == lexer.TokenEquals):
+				emit(SemanticHashKey, tok.Pos, end)
+				continue
+			}
+
+			i, end = semanticPathEnd(tokens, i)
+
+			kind := SemanticPath
+
+			if frame := semanticTop(frames); (frame != nil) && frame.name {
+				switch frame.open {
+				case lexer.TokenOpenPartial:
+					kind = SemanticPartial
+				case lexer.TokenOpenSexpr:
+					kind = SemanticHelper
+				case lexer.TokenOpenEndBlock, lexer.TokenOpenEndRawBlock:
+					if len(blocks) > 0 {
+						kind, blocks = blocks[len(blocks)-1], blocks[:len(blocks)-1]
+					}
+				default:
+					if ((i+1 < len(tokens)) && semanticParamStart(tokens[i+1].Kind)) || isHelper(source[tok.Pos:end]) {
+						kind = SemanticHelper
+					}
+				}
+			}
+
+			element(kind, tok.Pos, end)
+		}
+	}
+
+	return result
+}
+
+// semanticTop returns the innermost opened frame, or nil if there is none
+func semanticTop(frames []*semanticFrame) *semanticFrame {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	return frames[len(frames)-1]
+}
+
+// semanticParamStart returns true if a token of given kind starts a param or a hash
+func semanticParamStart(kind lexer.TokenKind) bool {
+	switch kind {
+	case lexer.TokenID, lexer.TokenString, lexer.TokenNumber, lexer.TokenBoolean, lexer.TokenNull, lexer.TokenUndefined, lexer.TokenData, lexer.TokenOpenSexpr:
+		return true
+	}
+
+	return false
+}
+
+// semanticPathEnd returns the index of the last token and the end offset of the path that starts with the ID token at given index
+func semanticPathEnd(tokens []lexer.Token, i int) (int, int) {
+	end := tokens[i].End
+
+	for (i+2 < len(tokens)) && (tokens[i+1].Kind == lexer.TokenSep) && (tokens[i+1].Pos == end) && (tokens[i+2].Kind == lexer.TokenID) {
+		i += 2
+		end = tokens[i].End
+	}
+
+	return i, end
+}
@@ -0,0 +1,105 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var semanticTokensTests = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{"content", "foo\nbar", "content(foo\nbar)"},
+	{"path", "{{ ../foo/bar.[baz qux] }}", "delimiter({{) path(../foo/bar.[baz qux]) delimiter(}})"},
+	{"data", "{{@root.foo}}", "delimiter({{) data(@root.foo) delimiter(}})"},
+	{"helper call", `{{foo bar 1 true "a\"b" 'c'}}`, `delimiter({{) helper(foo) path(bar) number(1) boolean(true) string("a\"b") string('c') delimiter(}})`},
+	{"registered helper", "{{lookup}} {{foo}}", "delimiter({{) helper(lookup) delimiter(}}) content( ) delimiter({{) path(foo) delimiter(}})"},
+	{"hash", "{{foo bar=1 baz=qux}}", "delimiter({{) helper(foo) hashKey(bar) delimiter(=) number(1) hashKey(baz) delimiter(=) path(qux) delimiter(}})"},
+	{"subexpression", "{{foo (bar) (baz qux)}}", "delimiter({{) helper(foo) delimiter(() helper(bar) delimiter()) delimiter(() helper(baz) path(qux) delimiter()) delimiter(}})"},
+	{"unescaped", "{{~{ foo }~}} {{&bar}}", "delimiter({{~{) path(foo) delimiter(}~}}) content( ) delimiter({{&) path(bar) delimiter(}})"},
+	{"block", "{{#each items as |item i|}}{{item}}{{ else }}-{{/each}}", "delimiter({{#) helper(each) path(items) keyword(as) delimiter(|) blockParam(item) blockParam(i) delimiter(|) delimiter(}}) delimiter({{) path(item) delimiter(}}) delimiter({{) keyword(else) delimiter(}}) content(-) delimiter({{/) helper(each) delimiter(}})"},
+	{"section", "{{#foo}}{{^}}{{/foo}}{{#bar baz}}{{/bar}}", "delimiter({{#) path(foo) delimiter(}}) delimiter({{^}}) delimiter({{/) path(foo) delimiter(}}) delimiter({{#) helper(bar) path(baz) delimiter(}}) delimiter({{/) helper(bar) delimiter(}})"},
+	{"else chain", "{{#if a}}{{~else if b}}{{/if}}", "delimiter({{#) helper(if) path(a) delimiter(}}) delimiter({{~) keyword(else) helper(if) path(b) delimiter(}}) delimiter({{/) helper(if) delimiter(}})"},
+	{"partial", `{{> foo/bar baz x=1}}{{> "qux"}}{{> (name)}}`, `delimiter({{>) partial(foo/bar) path(baz) hashKey(x) delimiter(=) number(1) delimiter(}}) delimiter({{>) partial("qux") delimiter(}}) delimiter({{>) delimiter(() helper(name) delimiter()) delimiter(}})`},
+	{"comments", "{{! foo }}{{!-- {{bar}} --}}", "comment({{! foo }}) comment({{!-- {{bar}} --}})"},
+	{"raw block", "{{{{raw}}}}{{foo}}{{{{/raw}}}}", "delimiter({{{{) path(raw) delimiter(}}}}) content({{foo}}) delimiter({{{{/) path(raw) delimiter(}}}})"},
+	{"escaped mustache", `a\{{foo}}`, "content(a) content({{foo}})"},
+}
+
+// semanticString returns a string representation of given tokens of given source
+func semanticString(source string, tokens []SemanticToken) string {
+	result := make([]string, len(tokens))
+	for i, tok := range tokens {
+		result[i] = fmt.Sprintf("%s(%s)", tok.Kind, source[tok.Pos:tok.End])
+	}
+
+	return strings.Join(result, " ")
+}
+
+func TestSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range semanticTokensTests {
+		tokens, err := SemanticTokens(test.input)
+		if err != nil {
+			t.Errorf("Test '%s' failed - Unexpected error: %s", test.name, err)
+			continue
+		}
+
+		if output := semanticString(test.input, tokens); output != test.expected {
+			t.Errorf("Test '%s' failed\ninput:\n\t%q\nexpected\n\t%s\ngot\n\t%s", test.name, test.input, test.expected, output)
+		}
+	}
+}
+
+func TestSemanticTokensLines(t *testing.T) {
+	t.Parallel()
+
+	tokens, _ := SemanticTokens("a\n{{foo\nbar}}")
+
+	lines := make([]int, len(tokens))
+	for i, tok := range tokens {
+		lines[i] = tok.Line
+	}
+
+	if fmt.Sprint(lines) != "[1 2 2 3 3]" {
+		t.Errorf("Unexpected token lines: %v", lines)
+	}
+}
+
+func TestSemanticTokensError(t *testing.T) {
+	t.Parallel()
+
+	source := "{{#if a}}{{foo bar}}"
+
+	tokens, err := SemanticTokens(source)
+	if err == nil {
+		t.Errorf("Expected a parse error")
+	}
+
+	expected := "delimiter({{#) helper(if) path(a) delimiter(}}) delimiter({{) helper(foo) path(bar) delimiter(}})"
+	if output := semanticString(source, tokens); output != expected {
+		t.Errorf("Expected tokens of invalid template\n\t%s\ngot\n\t%s", expected, output)
+	}
+}
+
+func TestTemplateSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse("{{foo}}")
+	tpl.RegisterHelper("foo", func() string { return "" })
+
+	if output := semanticString("{{foo}}", tpl.SemanticTokens()); output != "delimiter({{) helper(foo) delimiter(}})" {
+		t.Errorf("Expected template helper to be recognized, got: %s", output)
+	}
+}
+
+func TestSemanticKindScope(t *testing.T) {
+	t.Parallel()
+
+	if scope := SemanticHelper.Scope(); scope != "entity.name.function.handlebars" {
+		t.Errorf("Unexpected scope: %s", scope)
+	}
+}
@@ -0,0 +1,460 @@
+package raymond
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/lexer"
+	"github.com/aymerick/raymond/parser"
+	"gopkg.in/yaml.v2"
+)
+
+// SpecResult is the result of a compatibility spec test.
+type SpecResult struct {
+	// Suite is the name of the spec suite, eg. "sections"
+	Suite string
+
+	// Name is the name of the test in its suite
+	Name string
+
+	// Expected is the expected output, and Output the actual one
+	Expected string
+	Output   string
+
+	// Err is the error returned when parsing or evaluating template, nil if there is none
+	Err error
+}
+
+// Passed returns true if template rendered the expected output.
+func (r SpecResult) Passed() bool {
+	return (r.Err == nil) && (r.Output == r.Expected)
+}
+
+// SpecSuite is the summary of the results of a spec suite.
+type SpecSuite struct {
+	Name   string
+	Passed int
+	Total  int
+}
+
+// PassRate returns the ratio of passed tests, between 0 and 1.
+func (s SpecSuite) PassRate() float64 {
+	return passRate(s.Passed, s.Total)
+}
+
+// SpecReport is the result of a compatibility spec run, that tracks how close this package is to another implementation.
+type SpecReport struct {
+	// Results lists the results of all tests, in suite order then in test order
+	Results []SpecResult
+}
+
+// Passed returns the number of passed tests.
+func (r *SpecReport) Passed() int {
+	result := 0
+
+	for _, res := range r.Results {
+		if res.Passed() {
+			result++
+		}
+	}
+
+	return result
+}
+
+// PassRate returns the ratio of passed tests, between 0 and 1.
+func (r *SpecReport) PassRate() float64 {
+	return passRate(r.Passed(), len(r.Results))
+}
+
+// Failures returns the results of failed tests.
+func (r *SpecReport) Failures() []SpecResult {
+	var result []SpecResult
+
+	for _, res := range r.Results {
+		if !res.Passed() {
+			result = append(result, res)
+		}
+	}
+
+	return result
+}
+
+// Suites returns the summary of each suite, in suite order.
+func (r *SpecReport) Suites() []SpecSuite {
+	var result []SpecSuite
+
+	for _, res := range r.Results {
+		if (len(result) == 0) || (result[len(result)-1].Name != res.Suite) {
+			result = append(result, SpecSuite{Name: res.Suite})
+		}
+
+		suite := &result[len(result)-1]
+		suite.Total++
+
+		if res.Passed() {
+			suite.Passed++
+		}
+	}
+
+	return result
+}
+
+// passRate returns the ratio of passed tests, or 0 if there is no test
+func passRate(passed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(passed) / float64(total)
+}
+
+//
+// Mustache spec
+//
+
+// mustacheSpecFile is a suite of the Mustache spec
+type mustacheSpecFile struct {
+	Tests []mustacheSpecTest
+}
+
+// mustacheSpecTest is a test of the Mustache spec
+type mustacheSpecTest struct {
+	Name     string
+	Data     interface{}
+	Template string
+	Expected string
+	Partials map[string]string
+}
+
+// mustacheSpecLambdas returns the Go implementations of the lambdas of the Mustache spec, indexed by test name
+//
+// The spec provides lambdas source code in several languages, but not in Go. A new lambda is instanciated for each run, as some of them have a state.
+var mustacheSpecLambdas = map[string]func() interface{}{
+	"Interpolation": func() interface{} {
+		return func() string { return "world" }
+	},
+	"Interpolation - Expansion": func() interface{} {
+		return func() string { return "{{planet}}" }
+	},
+	"Interpolation - Alternate Delimiters": func() interface{} {
+		return func() string { return "|planet| => {{planet}}" }
+	},
+	"Interpolation - Multiple Calls": func() interface{} {
+		calls := 0
+		return func() int {
+			calls++
+			return calls
+		}
+	},
+	"Escaping": func() interface{} {
+		return func() string { return ">" }
+	},
+	"Section": func() interface{} {
+		return func(text string) string {
+			if text == "{{x}}" {
+				return "yes"
+			}
+			return "no"
+		}
+	},
+	"Section - Expansion": func() interface{} {
+		return func(text string) string { return text + "{{planet}}" + text }
+	},
+	"Section - Alternate Delimiters": func() interface{} {
+		return func(text string) string { return text + "{{planet}} => |planet|" + text }
+	},
+	"Section - Multiple Calls": func() interface{} {
+		return func(text string) string { return "__" + text + "__" }
+	},
+	"Inverted Section": func() interface{} {
+		return func(text string) bool { return false }
+	},
+}
+
+// RunMustacheSpec runs the tests of the Mustache spec found in given directory, ie. the specs/*.yml files of https://github.com/mustache/spec, with templates parsed with the Mustache parse option.
+//
+// Suites are named after files, eg. "sections" or "~lambdas" for optional modules. Lambdas are replaced by their Go implementations. An error is returned only if spec files can't be read.
+func RunMustacheSpec(dir string) (*SpecReport, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	result := &SpecReport{}
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var file mustacheSpecFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+
+		suite := strings.TrimSuffix(filepath.Base(path), ".yml")
+
+		for _, test := range file.Tests {
+			result.Results = append(result.Results, runMustacheSpecTest(suite, test))
+		}
+	}
+
+	return result, nil
+}
+
+// runMustacheSpecTest runs a test of the Mustache spec
+func runMustacheSpecTest(suite string, test mustacheSpecTest) SpecResult {
+	result := SpecResult{Suite: suite, Name: test.Name, Expected: test.Expected}
+
+	if data, ok := test.Data.(map[interface{}]interface{}); ok {
+		if newLambda, ok := mustacheSpecLambdas[test.Name]; ok && (data["lambda"] != nil) {
+			data["lambda"] = newLambda()
+		}
+	}
+
+	tpl, err := ParseWithOptions(test.Template, ParseOptions{Mustache: true})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for name, source := range test.Partials {
+		tpl.RegisterPartial(name, source)
+	}
+
+	result.Output, result.Err = tpl.Exec(test.Data)
+
+	return result
+}
+
+//
+// Handlebars spec
+//
+
+// handlebarsSpecFile is a suite of the handlebars.js spec, converted to JSON
+type handlebarsSpecFile struct {
+	Tests []handlebarsSpecTest `json:"tests"`
+}
+
+// handlebarsSpecTest is a test of the handlebars.js spec
+//
+// A test with tokens is a tokenizer test, a test with an AST is a parser test, and other tests are runtime tests.
+type handlebarsSpecTest struct {
+	Name     string      `json:"name"`
+	Template string      `json:"template"`
+	Data     interface{} `json:"data"`
+
+	// expected tokens, as [name, value] pairs, eg. ["OPEN", "{{"]
+	Tokens [][2]string `json:"tokens"`
+
+	// expected AST, as printed by handlebars.js PrintVisitor
+	AST string `json:"ast"`
+
+	// names of the helpers used by template, implemented in handlebarsSpecHelpers
+	Helpers  []string          `json:"helpers"`
+	Partials map[string]string `json:"partials"`
+	Expected string            `json:"expected"`
+
+	// template is expected to fail to parse or evaluate
+	Exception bool `json:"exception"`
+}
+
+// handlebarsTokenNames are the handlebars.js names of token kinds
+var handlebarsTokenNames = map[lexer.TokenKind]string{
+	lexer.TokenContent:          "CONTENT",
+	lexer.TokenComment:          "COMMENT",
+	lexer.TokenOpen:             "OPEN",
+	lexer.TokenClose:            "CLOSE",
+	lexer.TokenOpenUnescaped:    "OPEN_UNESCAPED",
+	lexer.TokenCloseUnescaped:   "CLOSE_UNESCAPED",
+	lexer.TokenOpenBlock:        "OPEN_BLOCK",
+	lexer.TokenOpenEndBlock:     "OPEN_ENDBLOCK",
+	lexer.TokenOpenRawBlock:     "OPEN_RAW_BLOCK",
+	lexer.TokenCloseRawBlock:    "CLOSE_RAW_BLOCK",
+	lexer.TokenOpenEndRawBlock:  "END_RAW_BLOCK",
+	lexer.TokenOpenBlockParams:  "OPEN_BLOCK_PARAMS",
+	lexer.TokenCloseBlockParams: "CLOSE_BLOCK_PARAMS",
+	lexer.TokenInverse:          "INVERSE",
+	lexer.TokenOpenInverse:      "OPEN_INVERSE",
+	lexer.TokenOpenInverseChain: "OPEN_INVERSE_CHAIN",
+	lexer.TokenOpenPartial:      "OPEN_PARTIAL",
+	lexer.TokenOpenSexpr:        "OPEN_SEXPR",
+	lexer.TokenCloseSexpr:       "CLOSE_SEXPR",
+	lexer.TokenID:               "ID",
+	lexer.TokenEquals:           "EQUALS",
+	lexer.TokenString:           "STRING",
+	lexer.TokenNumber:           "NUMBER",
+	lexer.TokenBoolean:          "BOOLEAN",
+	lexer.TokenNull:             "NULL",
+	lexer.TokenUndefined:        "UNDEFINED",
+	lexer.TokenChainHelper:      "ID",
+	lexer.TokenData:             "DATA",
+	lexer.TokenSep:              "SEP",
+}
+
+// handlebarsSpecHelpers are the Go implementations of the helpers of the handlebars.js spec, indexed by name
+//
+// The spec defines helpers in JavaScript, so runtime tests only reference them by name.
+var handlebarsSpecHelpers = map[string]interface{}{
+	"link": func(prefix string, options *Options) SafeString {
+		return SafeString(fmt.Sprintf(`<a href="%s/%s">%s</a>`, prefix, options.ValueStr("url"), options.ValueStr("text")))
+	},
+	"goodbyes": func(options *Options) string {
+		return options.FnWith(map[string]string{"text": "GOODBYE"})
+	},
+	"list": func(items []interface{}, options *Options) string {
+		if len(items) == 0 {
+			return "<p>" + options.Inverse() + "</p>"
+		}
+
+		result := "<ul>"
+		for _, item := range items {
+			result += "<li>" + options.FnWith(item) + "</li>"
+		}
+		return result + "</ul>"
+	},
+	"foo": func(val string) string {
+		return val + val
+	},
+	"bar": func() string {
+		return "LOL"
+	},
+	"blog": func(val interface{}) string {
+		return "val is " + Str(val)
+	},
+	"equal": func(a, b interface{}) bool {
+		return a == b
+	},
+}
+
+// RunHandlebarsSpec runs the tests of the handlebars.js spec found in given directory, ie. the tokenizer, parser and runtime suites of https://github.com/handlebars-lang/handlebars.js converted to *.json files.
+//
+// Suites are named after files, eg. "tokenizer", "parser" or "blocks". Tokens are compared with their handlebars.js names, ASTs with the output of Template.PrintAST(), and helpers are replaced by their Go implementations. An error is returned only if spec files can't be read.
+func RunHandlebarsSpec(dir string) (*SpecReport, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	result := &SpecReport{}
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var file handlebarsSpecFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+
+		suite := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		for _, test := range file.Tests {
+			result.Results = append(result.Results, runHandlebarsSpecTest(suite, test))
+		}
+	}
+
+	return result, nil
+}
+
+// runHandlebarsSpecTest runs a test of the handlebars.js spec
+func runHandlebarsSpecTest(suite string, test handlebarsSpecTest) SpecResult {
+	result := SpecResult{Suite: suite, Name: test.Name}
+
+	var err error
+
+	switch {
+	case test.Tokens != nil:
+		result.Expected = handlebarsTokensStr(test.Tokens)
+		result.Output, err = handlebarsTokens(test.Template)
+	case test.AST != "":
+		result.Expected = test.AST
+
+		var program *ast.Program
+		if program, err = parser.Parse(test.Template); err == nil {
+			result.Output = ast.Print(program)
+		}
+	default:
+		result.Expected = test.Expected
+		result.Output, err = execHandlebarsSpecTest(test)
+	}
+
+	if test.Exception {
+		// only the failure is checked
+		result.Expected = ""
+		result.Output = ""
+
+		if err == nil {
+			err = errors.New("Expected template to fail")
+		} else {
+			err = nil
+		}
+	}
+
+	result.Err = err
+
+	return result
+}
+
+// handlebarsTokens returns the tokens of given template, with their handlebars.js names
+func handlebarsTokens(template string) (string, error) {
+	var tokens [][2]string
+
+	for _, token := range lexer.Collect(template) {
+		switch token.Kind {
+		case lexer.TokenError:
+			return "", errors.New(token.Val)
+		case lexer.TokenEOF:
+		default:
+			tokens = append(tokens, [2]string{handlebarsTokenNames[token.Kind], token.Val})
+		}
+	}
+
+	return handlebarsTokensStr(tokens), nil
+}
+
+// handlebarsTokensStr returns the string representation of given tokens, as [name, value] pairs
+func handlebarsTokensStr(tokens [][2]string) string {
+	var result []string
+
+	for _, token := range tokens {
+		result = append(result, fmt.Sprintf("%s{%q}", token[0], token[1]))
+	}
+
+	return strings.Join(result, " ")
+}
+
+// execHandlebarsSpecTest parses and evaluates the template of a runtime test
+func execHandlebarsSpecTest(test handlebarsSpecTest) (string, error) {
+	tpl, err := Parse(test.Template)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range test.Helpers {
+		helper, ok := handlebarsSpecHelpers[name]
+		if !ok {
+			return "", fmt.Errorf("No Go implementation of helper: %s", name)
+		}
+
+		tpl.RegisterHelper(name, helper)
+	}
+
+	tpl.RegisterPartials(test.Partials)
+
+	// JSON objects are decoded as maps, so their keys are iterated in sorted order instead of the random Go one
+	return tpl.ExecWithOptions(test.Data, ExecOptions{Deterministic: true})
+}
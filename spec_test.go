@@ -0,0 +1,193 @@
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunMustacheSpec(t *testing.T) {
+	t.Parallel()
+
+	report, err := RunMustacheSpec("testdata/mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suite := range report.Suites() {
+		if suite.Total == 0 {
+			t.Errorf("Empty suite: %s", suite.Name)
+		}
+
+		// template inheritance is not supported
+		if (suite.Name != "~inheritance") && (suite.PassRate() != 1) {
+			t.Errorf("Suite %s passed %d/%d tests", suite.Name, suite.Passed, suite.Total)
+		}
+	}
+
+	for _, res := range report.Failures() {
+		if res.Suite != "~inheritance" {
+			t.Errorf("Test %s/%s failed: %v\n\texpected: %q\n\tgot: %q", res.Suite, res.Name, res.Err, res.Expected, res.Output)
+		}
+	}
+
+	if report.Passed() != len(report.Results)-1 {
+		t.Errorf("Unexpected pass count: %d/%d", report.Passed(), len(report.Results))
+	}
+}
+
+func TestRunMustacheSpecMissingDir(t *testing.T) {
+	t.Parallel()
+
+	report, err := RunMustacheSpec("testdata/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (len(report.Results) != 0) || (report.PassRate() != 0) {
+		t.Errorf("Unexpected report for missing directory: %v", report.Results)
+	}
+}
+
+var mustacheModeTests = []Test{
+	{
+		"helpers are not resolved",
+		"{{#if}}{{if}}{{/if}} {{#each}}{{.}}{{/each}}",
+		map[string]interface{}{"if": "yes", "each": []int{1, 2}},
+		nil, nil, nil,
+		"yes 12",
+	},
+	{
+		"set delimiters",
+		"{{=<% %>=}}<% a %> {{a}} <%={{ }}=%>{{a}}",
+		map[string]string{"a": "A"},
+		nil, nil, nil,
+		"A {{a}} A",
+	},
+	{
+		"missing partial",
+		"[{{> missing}}]",
+		nil, nil, nil, nil,
+		"[]",
+	},
+	{
+		"standalone partial indentation",
+		"<\n  {{> item}}\n>",
+		map[string]string{"a": "1\n2"},
+		nil, nil,
+		map[string]string{"item": "{{{a}}}\n"},
+		"<\n  1\n2\n>",
+	},
+	{
+		"section lambda",
+		"{{#wrap}}{{a}}{{/wrap}}",
+		map[string]interface{}{"a": "<A>", "wrap": func(text string) string { return "<b>" + text + "</b>" }},
+		nil, nil, nil,
+		"<b>&lt;A&gt;</b>",
+	},
+}
+
+func TestMustacheMode(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range mustacheModeTests {
+		tpl, err := ParseWithOptions(test.input, ParseOptions{Mustache: true})
+		if err != nil {
+			t.Errorf("Test '%s' failed to parse: %s", test.name, err)
+			continue
+		}
+
+		for name, source := range test.partials {
+			tpl.RegisterPartial(name, source)
+		}
+
+		if output, err := tpl.Exec(test.data); err != nil {
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		} else if output != test.output {
+			t.Errorf("Test '%s' failed\ninput:\n\t'%s'\nexpected:\n\t%q\ngot:\n\t%q", test.name, test.input, test.output, output)
+		}
+	}
+}
+
+func TestMustacheModeErrors(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]string{
+		"{{a":           "Unclosed tag",
+		"{{= | =}}":     "Invalid set delimiters tag",
+		"\n{{> *name}}": "line 2: Dynamic partial names are not supported",
+		"{{<parent}}":   "Template inheritance is not supported",
+		"{{a b}}":       "Invalid name",
+		"{{#a}}{{/b}}":  "",
+	} {
+		_, err := ParseWithOptions(input, ParseOptions{Mustache: true})
+		if err == nil {
+			t.Errorf("Expected error for template: %s", input)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Unexpected error for template %s: %s", input, err)
+		}
+	}
+}
+
+// handlebarsSpecKnownFailures are the handlebars.js spec tests that are known to fail
+var handlebarsSpecKnownFailures = map[string]bool{
+	// JSON numbers are floats, and includeZero only handles integers
+	"builtins/#if - if with zero and includeZero option shows the contents": true,
+
+	// JSON objects are Go maps, iterated in sorted key order instead of insertion order
+	"builtins/#each - each with an object and @key (struct)": true,
+}
+
+func TestRunHandlebarsSpec(t *testing.T) {
+	t.Parallel()
+
+	report, err := RunHandlebarsSpec("testdata/handlebars")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suites := make(map[string]bool)
+	for _, suite := range report.Suites() {
+		suites[suite.Name] = true
+	}
+
+	for _, name := range []string{"tokenizer", "parser", "basic", "blocks", "helpers"} {
+		if !suites[name] {
+			t.Errorf("Missing suite: %s", name)
+		}
+	}
+
+	failures := report.Failures()
+	for _, res := range failures {
+		if !handlebarsSpecKnownFailures[res.Suite+"/"+res.Name] {
+			t.Errorf("Test %s/%s failed: %v\n\texpected: %q\n\tgot: %q", res.Suite, res.Name, res.Err, res.Expected, res.Output)
+		}
+	}
+
+	if len(failures) != len(handlebarsSpecKnownFailures) {
+		t.Errorf("Expected %d known failures, got %d", len(handlebarsSpecKnownFailures), len(failures))
+	}
+}
+
+func TestHandlebarsSpecTest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		test   handlebarsSpecTest
+		passed bool
+	}{
+		{handlebarsSpecTest{Template: "{{foo}}", Tokens: [][2]string{{"OPEN", "{{"}, {"ID", "foo"}, {"CLOSE", "}}"}}}, true},
+		{handlebarsSpecTest{Template: "{{foo}}", Tokens: [][2]string{{"OPEN", "{{"}, {"ID", "bar"}, {"CLOSE", "}}"}}}, false},
+		{handlebarsSpecTest{Template: "{{foo", Tokens: [][2]string{{"OPEN", "{{"}, {"ID", "foo"}}}, false},
+		{handlebarsSpecTest{Template: "{{foo", Exception: true}, true},
+		{handlebarsSpecTest{Template: "{{foo}}", AST: "{{ PATH:foo [] }}\n"}, true},
+		{handlebarsSpecTest{Template: "{{foo}}", Exception: true}, false},
+		{handlebarsSpecTest{Template: "{{unknown}}", Helpers: []string{"unknown"}}, false},
+		{handlebarsSpecTest{Template: "{{foo}}", Data: map[string]interface{}{"foo": "<"}, Expected: "&lt;"}, true},
+	}
+
+	for _, test := range tests {
+		if res := runHandlebarsSpecTest("test", test.test); res.Passed() != test.passed {
+			t.Errorf("Unexpected result for template %q: %v", test.test.Template, res)
+		}
+	}
+}
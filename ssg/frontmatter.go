@@ -0,0 +1,74 @@
+package ssg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aymerick/raymond/internal/yamlconv"
+	"gopkg.in/yaml.v2"
+)
+
+// frontMatterDelim is the line that starts and ends front matter
+const frontMatterDelim = "---"
+
+// splitFrontMatter returns the front matter decoded from the start of given source, and the rest of source
+//
+// Front matter is a YAML mapping between two --- lines, at the very start of source. Without front matter, an empty map is returned with source as is.
+func splitFrontMatter(source string) (map[string]interface{}, string, error) {
+	result := make(map[string]interface{})
+
+	rest, ok := cutLine(source, frontMatterDelim)
+	if !ok {
+		return result, source, nil
+	}
+
+	var yml strings.Builder
+
+	for {
+		if rest == "" {
+			return nil, "", fmt.Errorf("front matter is not closed by a %s line", frontMatterDelim)
+		}
+
+		line := rest
+		if i := strings.IndexByte(rest, '\n'); i != -1 {
+			line, rest = rest[:i+1], rest[i+1:]
+		} else {
+			rest = ""
+		}
+
+		if strings.TrimRight(line, "\r\n") == frontMatterDelim {
+			break
+		}
+
+		yml.WriteString(line)
+	}
+
+	var data map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(yml.String()), &data); err != nil {
+		return nil, "", fmt.Errorf("failed to parse front matter: %s", err)
+	}
+
+	for key, val := range data {
+		result[fmt.Sprint(key)] = yamlconv.Value(val)
+	}
+
+	return result, rest, nil
+}
+
+// cutLine returns given source without its first line, and true if that line is given one
+func cutLine(source string, line string) (string, bool) {
+	if !strings.HasPrefix(source, line) {
+		return source, false
+	}
+
+	rest := source[len(line):]
+
+	switch {
+	case strings.HasPrefix(rest, "\n"):
+		return rest[1:], true
+	case strings.HasPrefix(rest, "\r\n"):
+		return rest[2:], true
+	default:
+		return source, false
+	}
+}
@@ -0,0 +1,426 @@
+// Package ssg generates static sites from a source tree of raymond templates.
+//
+// A source tree contains pages, layouts, partials and static assets:
+//
+//	index.hbs              page rendered to index.html
+//	blog/first-post.hbs    page rendered to blog/first-post.html
+//	feed.xml.hbs           page rendered to feed.xml
+//	layouts/default.hbs    layout, named "default"
+//	partials/nav.hbs       partial, named "nav"
+//	css/site.css           asset, copied as is
+//
+// Pages may start with a YAML front matter, between two --- lines, that is the context of the page:
+//
+//	---
+//	title: First post
+//	layout: post
+//	---
+//	<h1>{{title}}</h1>
+//
+// A page is wrapped in the layout named by its layout key, or in the Layout option if it has none. Layouts are rendered with the context of the page, where the output of the page is {{@body}}, and may have a front matter with a layout key too, to be wrapped in another layout.
+//
+// The context of a page also has a url key, the URL path of the page, eg. "/blog/first-post.html", or "/blog/" for "blog/index.hbs". The contexts of all pages are the @pages data variable, sorted by source path, to list them, eg. in an index page, and the Site option is the @site data variable.
+//
+// Files and directories whose name starts with "." or "_" are skipped, so that an output directory like "_site" can be in the source tree.
+package ssg
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+// Options represents site options.
+type Options struct {
+	// LayoutsDir is the directory of layouts, relative to source root. Default is "layouts".
+	LayoutsDir string
+
+	// PartialsDir is the directory of partials, relative to source root. Default is "partials".
+	PartialsDir string
+
+	// Extensions are the extensions of template files. Default is ".hbs" and ".handlebars".
+	Extensions []string
+
+	// Layout is the name of the layout of pages without a layout key in their front matter. If empty, those pages are not wrapped.
+	Layout string
+
+	// Site is the value of the @site data variable, eg. the site title and base URL.
+	Site interface{}
+
+	// Drafts includes pages with a draft key set to true in their front matter. By default, they are skipped.
+	Drafts bool
+
+	// Helpers are registered for all pages and layouts.
+	Helpers map[string]interface{}
+
+	// ParseOptions are the options used to parse pages, layouts and partials.
+	ParseOptions raymond.ParseOptions
+}
+
+// defaultExtensions are the default extensions of template files
+var defaultExtensions = []string{".hbs", ".handlebars"}
+
+// Page represents a page of a site.
+type Page struct {
+	// Path is the path of the page source, relative to source root, eg. "blog/index.hbs".
+	Path string
+
+	// OutputPath is the path of the rendered page, relative to output directory, eg. "blog/index.html".
+	OutputPath string
+
+	// URL is the URL path of the page, eg. "/blog/".
+	URL string
+
+	// FrontMatter is the front matter of the page, or an empty map if it has none.
+	FrontMatter map[string]interface{}
+
+	tpl *raymond.Template
+	ctx map[string]interface{}
+}
+
+// layout represents a parsed layout
+type layout struct {
+	tpl *raymond.Template
+
+	// name of parent layout, or empty
+	parent string
+}
+
+// Site represents a site loaded from a source tree.
+type Site struct {
+	fsys    fs.FS
+	opts    Options
+	pages   []*Page
+	layouts map[string]*layout
+	assets  []string
+	data    []interface{}
+}
+
+// Load parses the pages, layouts and partials of given source tree, eg. os.DirFS("site").
+//
+// Parse errors are prefixed with the path of the invalid file.
+func Load(fsys fs.FS, opts Options) (*Site, error) {
+	if len(opts.Extensions) == 0 {
+		opts.Extensions = defaultExtensions
+	}
+
+	if opts.LayoutsDir == "" {
+		opts.LayoutsDir = "layouts"
+	}
+
+	if opts.PartialsDir == "" {
+		opts.PartialsDir = "partials"
+	}
+
+	layoutsDir := path.Clean(opts.LayoutsDir) + "/"
+	partialsDir := path.Clean(opts.PartialsDir) + "/"
+
+	result := &Site{
+		fsys:    fsys,
+		opts:    opts,
+		layouts: make(map[string]*layout),
+	}
+
+	partials := make(map[string]*raymond.Template)
+	outputs := make(map[string]string)
+
+	err := fs.WalkDir(fsys, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if (filePath != ".") && isSkipped(entry.Name()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		name, isTemplate := trimExtension(filePath, opts.Extensions)
+
+		switch {
+		case strings.HasPrefix(filePath, layoutsDir):
+			if !isTemplate {
+				return nil
+			}
+
+			frontMatter, tpl, err := result.parse(filePath)
+			if err != nil {
+				return err
+			}
+
+			parent, _ := frontMatter["layout"].(string)
+
+			result.layouts[strings.TrimPrefix(name, layoutsDir)] = &layout{tpl: tpl, parent: parent}
+
+		case strings.HasPrefix(filePath, partialsDir):
+			if !isTemplate {
+				return nil
+			}
+
+			source, err := fs.ReadFile(fsys, filePath)
+			if err != nil {
+				return err
+			}
+
+			tpl, err := raymond.ParseWithOptions(string(source), opts.ParseOptions)
+			if err != nil {
+				return fmt.Errorf("ssg: %s: %w", filePath, err)
+			}
+
+			partials[strings.TrimPrefix(name, partialsDir)] = tpl
+
+		case isTemplate:
+			frontMatter, tpl, err := result.parse(filePath)
+			if err != nil {
+				return err
+			}
+
+			if draft, _ := frontMatter["draft"].(bool); draft && !opts.Drafts {
+				return nil
+			}
+
+			page := newPage(filePath, name, frontMatter, tpl)
+
+			if err := checkOutput(outputs, page.OutputPath, filePath); err != nil {
+				return err
+			}
+
+			result.pages = append(result.pages, page)
+
+		default:
+			if err := checkOutput(outputs, filePath, filePath); err != nil {
+				return err
+			}
+
+			result.assets = append(result.assets, filePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range result.pages {
+		registerPartials(page.tpl, partials)
+		result.data = append(result.data, page.ctx)
+	}
+
+	for _, l := range result.layouts {
+		registerPartials(l.tpl, partials)
+	}
+
+	return result, nil
+}
+
+// parse parses the template file with given path, and returns its front matter
+func (s *Site) parse(filePath string) (map[string]interface{}, *raymond.Template, error) {
+	source, err := fs.ReadFile(s.fsys, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	frontMatter, body, err := splitFrontMatter(string(source))
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssg: %s: %w", filePath, err)
+	}
+
+	tpl, err := raymond.ParseWithOptions(body, s.opts.ParseOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssg: %s: %w", filePath, err)
+	}
+
+	tpl.RegisterHelpers(s.opts.Helpers)
+
+	return frontMatter, tpl, nil
+}
+
+// newPage instanciates a page with given source path, name, front matter and template
+func newPage(filePath string, name string, frontMatter map[string]interface{}, tpl *raymond.Template) *Page {
+	outputPath := name
+	if path.Ext(name) == "" {
+		outputPath += ".html"
+	}
+
+	url := "/" + outputPath
+	if path.Base(outputPath) == "index.html" {
+		url = strings.TrimSuffix(url, "index.html")
+	}
+
+	ctx := make(map[string]interface{}, len(frontMatter)+1)
+	for key, val := range frontMatter {
+		ctx[key] = val
+	}
+	ctx["url"] = url
+
+	return &Page{
+		Path:        filePath,
+		OutputPath:  outputPath,
+		URL:         url,
+		FrontMatter: frontMatter,
+		tpl:         tpl,
+		ctx:         ctx,
+	}
+}
+
+// checkOutput records that given source file is written to given output path, and fails if another one already is
+func checkOutput(outputs map[string]string, outputPath string, filePath string) error {
+	if other, ok := outputs[outputPath]; ok {
+		return fmt.Errorf("ssg: %s and %s are both written to %s", other, filePath, outputPath)
+	}
+
+	outputs[outputPath] = filePath
+
+	return nil
+}
+
+// registerPartials registers given partials for given template
+func registerPartials(tpl *raymond.Template, partials map[string]*raymond.Template) {
+	for name, partial := range partials {
+		tpl.RegisterPartialTemplate(name, partial)
+	}
+}
+
+// isSkipped returns true if file or directory with given name is skipped
+func isSkipped(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// trimExtension returns given file path without its extension, and true if that extension is one of given ones
+func trimExtension(filePath string, extensions []string) (string, bool) {
+	for _, ext := range extensions {
+		if strings.HasSuffix(filePath, ext) {
+			return strings.TrimSuffix(filePath, ext), true
+		}
+	}
+
+	return "", false
+}
+
+// Pages returns the pages of site, sorted by source path.
+func (s *Site) Pages() []*Page {
+	return s.pages
+}
+
+// Assets returns the paths of the static assets of site, sorted.
+func (s *Site) Assets() []string {
+	return s.assets
+}
+
+// Render renders given page, wrapped in its layouts.
+func (s *Site) Render(page *Page) (string, error) {
+	data := raymond.NewDataFrame()
+	data.Set("site", s.opts.Site)
+	data.Set("pages", s.data)
+
+	result, err := page.tpl.ExecWithOptions(page.ctx, raymond.ExecOptions{Data: data})
+	if err != nil {
+		return "", fmt.Errorf("ssg: %s: %w", page.Path, err)
+	}
+
+	name := s.opts.Layout
+	if val, ok := page.FrontMatter["layout"]; ok {
+		name, _ = val.(string)
+	}
+
+	visited := make(map[string]bool)
+
+	for name != "" {
+		if visited[name] {
+			return "", fmt.Errorf("ssg: %s: layout %q wraps itself", page.Path, name)
+		}
+		visited[name] = true
+
+		l, ok := s.layouts[name]
+		if !ok {
+			return "", fmt.Errorf("ssg: %s: layout %q not found", page.Path, name)
+		}
+
+		frame := data.Copy()
+		frame.Set("body", raymond.SafeString(result))
+
+		if result, err = l.tpl.ExecWithOptions(page.ctx, raymond.ExecOptions{Data: frame}); err != nil {
+			return "", fmt.Errorf("ssg: %s: layout %s: %w", page.Path, name, err)
+		}
+
+		name = l.parent
+	}
+
+	return result, nil
+}
+
+// Build renders all pages and copies all assets to given output directory, that is created if needed. Existing files of output directory are overwritten, and others are kept.
+func (s *Site) Build(outDir string) error {
+	for _, page := range s.pages {
+		result, err := s.Render(page)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFile(outDir, page.OutputPath, strings.NewReader(result)); err != nil {
+			return err
+		}
+	}
+
+	for _, asset := range s.assets {
+		if err := s.copyAsset(outDir, asset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyAsset copies the asset with given path to given output directory
+func (s *Site) copyAsset(outDir string, asset string) error {
+	f, err := s.fsys.Open(asset)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writeFile(outDir, asset, f)
+}
+
+// writeFile writes the content of given reader to the file with given slash separated path, in given directory
+func writeFile(dir string, filePath string, r io.Reader) error {
+	dest := filepath.Join(dir, filepath.FromSlash(filePath))
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Build loads the source tree of given directory, and builds it to given output directory.
+func Build(srcDir string, outDir string, opts Options) error {
+	site, err := Load(os.DirFS(srcDir), opts)
+	if err != nil {
+		return err
+	}
+
+	return site.Build(outDir)
+}
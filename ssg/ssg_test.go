@@ -0,0 +1,187 @@
+package ssg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testSite() fstest.MapFS {
+	return fstest.MapFS{
+		"index.hbs":            {Data: []byte("---\ntitle: Home\n---\n<ul>{{#each @pages}}{{#if date}}<li><a href=\"{{url}}\">{{title}}</a></li>{{/if}}{{/each}}</ul>")},
+		"blog/first.hbs":       {Data: []byte("---\ntitle: First <post>\ndate: 2024-01-02\nlayout: post\n---\n<p>{{shout title}}</p>")},
+		"blog/draft.hbs":       {Data: []byte("---\ntitle: Draft\ndate: 2024-02-03\ndraft: true\n---\nwip")},
+		"feed.xml.hbs":         {Data: []byte("<feed>{{@site.title}}</feed>")},
+		"layouts/default.hbs":  {Data: []byte("<title>{{title}} - {{@site.title}}</title>{{> nav}}<main>{{@body}}</main>")},
+		"layouts/post.hbs":     {Data: []byte("---\nlayout: default\n---\n<article>{{@body}}</article>")},
+		"partials/nav.hbs":     {Data: []byte("<nav>{{url}}</nav>")},
+		"css/site.css":         {Data: []byte("body { margin: 0; }")},
+		".git/config":          {Data: []byte("ignored")},
+		"_site/index.html":     {Data: []byte("ignored")},
+		"layouts/README.txt":   {Data: []byte("ignored")},
+		"partials/nav.js.orig": {Data: []byte("ignored")},
+	}
+}
+
+func testOptions() Options {
+	return Options{
+		Layout:  "default",
+		Site:    map[string]string{"title": "Blog"},
+		Helpers: map[string]interface{}{"shout": strings.ToUpper},
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	site, err := Load(testSite(), testOptions())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"blog/first.html": "<title>First &lt;post&gt; - Blog</title><nav>/blog/first.html</nav><main><article><p>FIRST &lt;POST&gt;</p></article></main>",
+		"feed.xml":        "<title> - Blog</title><nav>/feed.xml</nav><main><feed>Blog</feed></main>",
+		"index.html":      "<title>Home - Blog</title><nav>/</nav><main><ul><li><a href=\"/blog/first.html\">First &lt;post&gt;</a></li></ul></main>",
+	}
+
+	if len(site.Pages()) != len(expected) {
+		t.Fatalf("Unexpected pages: %d", len(site.Pages()))
+	}
+
+	for _, page := range site.Pages() {
+		result, err := site.Render(page)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if result != expected[page.OutputPath] {
+			t.Errorf("Unexpected output of %s:\nexpected: %q\ngot:      %q", page.Path, expected[page.OutputPath], result)
+		}
+	}
+
+	if assets := site.Assets(); (len(assets) != 1) || (assets[0] != "css/site.css") {
+		t.Errorf("Unexpected assets: %v", assets)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions()
+	opts.Drafts = true
+
+	site, err := Load(testSite(), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(site.Pages()) != 4 {
+		t.Errorf("Unexpected pages: %d", len(site.Pages()))
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fsys     fstest.MapFS
+		expected string
+	}{
+		{fstest.MapFS{"page.hbs": {Data: []byte("{{#if}}")}}, "ssg: page.hbs: "},
+		{fstest.MapFS{"page.hbs": {Data: []byte("---\ntitle: x\n")}}, "ssg: page.hbs: front matter is not closed"},
+		{fstest.MapFS{"page.hbs": {Data: []byte("---\n- x\n---\n")}}, "ssg: page.hbs: failed to parse front matter"},
+		{fstest.MapFS{"partials/p.hbs": {Data: []byte("{{/if}}")}}, "ssg: partials/p.hbs: "},
+		{fstest.MapFS{"page.hbs": {}, "page.handlebars": {}}, "ssg: page.handlebars and page.hbs are both written to page.html"},
+	}
+
+	for _, test := range tests {
+		if _, err := Load(test.fsys, Options{}); (err == nil) || !strings.HasPrefix(err.Error(), test.expected) {
+			t.Errorf("Expected error %q, got %v", test.expected, err)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	t.Parallel()
+
+	site, err := Load(fstest.MapFS{
+		"missing.hbs":   {Data: []byte("---\nlayout: missing\n---\n")},
+		"loop.hbs":      {Data: []byte("---\nlayout: a\n---\n")},
+		"layouts/a.hbs": {Data: []byte("---\nlayout: b\n---\n")},
+		"layouts/b.hbs": {Data: []byte("---\nlayout: a\n---\n")},
+		"no-layout.hbs": {Data: []byte("---\nlayout:\n---\nok")},
+	}, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"loop.hbs":    `ssg: loop.hbs: layout "a" wraps itself`,
+		"missing.hbs": `ssg: missing.hbs: layout "missing" not found`,
+	}
+
+	for _, page := range site.Pages() {
+		result, err := site.Render(page)
+
+		if page.Path == "no-layout.hbs" {
+			if (err != nil) || (result != "ok") {
+				t.Errorf("Unexpected result: %q %v", result, err)
+			}
+		} else if (err == nil) || (err.Error() != expected[page.Path]) {
+			t.Errorf("Unexpected error for %s: %v", page.Path, err)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	site, err := Load(testSite(), testOptions())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	outDir := t.TempDir()
+	if err := site.Build(outDir); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for filePath, expected := range map[string]string{
+		"css/site.css":    "body { margin: 0; }",
+		"feed.xml":        "<title> - Blog</title><nav>/feed.xml</nav><main><feed>Blog</feed></main>",
+		"blog/first.html": "<article>",
+	} {
+		data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(filePath)))
+		if (err != nil) || !strings.Contains(string(data), expected) {
+			t.Errorf("Unexpected %s: %q %v", filePath, data, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "blog", "draft.html")); !os.IsNotExist(err) {
+		t.Errorf("Draft should not be written: %v", err)
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	frontMatter, body, err := splitFrontMatter("---\r\ntags: [a, b]\r\nauthor: {name: Jean}\r\n---\r\nbody\n---\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	author, _ := frontMatter["author"].(map[string]interface{})
+	if (body != "body\n---\n") || (len(frontMatter["tags"].([]interface{})) != 2) || (author["name"] != "Jean") {
+		t.Errorf("Unexpected result: %v %q", frontMatter, body)
+	}
+
+	for _, source := range []string{"no front matter", "----\nx\n", "text\n---\nx: 1\n---\n"} {
+		frontMatter, body, err := splitFrontMatter(source)
+		if (err != nil) || (len(frontMatter) != 0) || (body != source) {
+			t.Errorf("Unexpected result for %q: %v %q %v", source, frontMatter, body, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
 // SafeString represents a string that must not be escaped.
@@ -21,6 +22,11 @@ func isSafeString(value interface{}) bool {
 
 // Str returns string representation of any basic type value.
 func Str(value interface{}) string {
+	if str, ok := value.(string); ok {
+		// fast path
+		return str
+	}
+
 	return strValue(reflect.ValueOf(value))
 }
 
@@ -28,11 +34,15 @@ func Str(value interface{}) string {
 func strValue(value reflect.Value) string {
 	result := ""
 
-	ival, ok := printableValue(value)
+	ival, ok := printableValue(adaptValue(value))
 	if !ok {
 		panic(fmt.Errorf("Can't print value: %q", value))
 	}
 
+	if t, ok := ival.(time.Time); ok {
+		return formatTime(t)
+	}
+
 	val := reflect.ValueOf(ival)
 
 	switch val.Kind() {
@@ -52,7 +62,15 @@ func strValue(value reflect.Value) string {
 	case reflect.Invalid:
 		result = ""
 	default:
-		result = fmt.Sprintf("%s", ival)
+		switch v := ival.(type) {
+		case error:
+			result = v.Error()
+		case fmt.Stringer:
+			// not formatted with %s, that is not supported by some formatters, eg. big.Float
+			result = v.String()
+		default:
+			result = fmt.Sprintf("%s", ival)
+		}
 	}
 
 	return result
@@ -71,7 +89,14 @@ func printableValue(v reflect.Value) (interface{}, bool) {
 	}
 
 	if !v.Type().Implements(errorType) && !v.Type().Implements(fmtStringerType) {
-		if v.CanAddr() && (reflect.PtrTo(v.Type()).Implements(errorType) || reflect.PtrTo(v.Type()).Implements(fmtStringerType)) {
+		if reflect.PtrTo(v.Type()).Implements(errorType) || reflect.PtrTo(v.Type()).Implements(fmtStringerType) {
+			if !v.CanAddr() {
+				// copy value, so that pointer methods are callable, eg. the String() method of big.Float
+				ptr := reflect.New(v.Type())
+				ptr.Elem().Set(v)
+				v = ptr.Elem()
+			}
+
 			v = v.Addr()
 		} else {
 			switch v.Kind() {
@@ -0,0 +1,269 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// tableState is shared by #table, #table.row and table.cell helpers thanks to the private data frame
+type tableState struct {
+	rows [][]string
+
+	// current row, nil when not inside a #table.row block
+	row []string
+}
+
+// tableData is the private data key used to store the table state
+const tableData = "table"
+
+// enclosingTable returns the state of enclosing #table block
+func (options *Options) enclosingTable(helper string) *tableState {
+	state, ok := options.Data(tableData).(*tableState)
+	if !ok {
+		options.eval.errorf("%s helper must be used inside a table block", helper)
+	}
+
+	return state
+}
+
+// isBlock returns true if current helper is called by a block statement
+func (options *Options) isBlock() bool {
+	block := options.eval.curBlock()
+
+	return (block != nil) && (block.Expression == options.eval.curExpr())
+}
+
+//
+// Table helpers
+//
+
+// #table block helper
+func tableHelper(options *Options) interface{} {
+	state := &tableState{}
+
+	header := IsTrue(options.HashProp("header"))
+
+	columns := tableColumns(options.HashProp("columns"))
+	if len(columns) > 0 {
+		state.rows = append(state.rows, columns)
+		header = true
+	}
+
+	if rows := options.HashProp("rows"); rows != nil {
+		state.rows = append(state.rows, tableDataRows(options, rows, columns)...)
+	}
+
+	if options.isBlock() {
+		frame := options.NewDataFrame()
+		frame.Set(tableData, state)
+
+		// block output is ignored, only rows matter
+		options.FnData(frame)
+	}
+
+	switch format := options.HashStr("format"); format {
+	case "", "ascii":
+		return renderASCIITable(state.rows, header)
+	case "markdown":
+		return renderMarkdownTable(state.rows)
+	default:
+		options.eval.errorf("Unknown table format: %q", format)
+	}
+
+	return ""
+}
+
+// #table.row block helper
+func tableRowHelper(options *Options) interface{} {
+	state := options.enclosingTable("table.row")
+
+	parent := state.row
+	state.row = []string{}
+
+	// block output is ignored, only cells matter
+	options.Fn()
+
+	state.rows = append(state.rows, state.row)
+	state.row = parent
+
+	return ""
+}
+
+// table.cell helper
+func tableCellHelper(value interface{}, options *Options) interface{} {
+	state := options.enclosingTable("table.cell")
+	if state.row == nil {
+		options.eval.errorf("table.cell helper must be used inside a table.row block")
+	}
+
+	state.row = append(state.row, Str(value))
+
+	return ""
+}
+
+// tableColumns returns the columns provided with the `columns` hash argument, as a comma separated string or a list of strings
+func tableColumns(value interface{}) []string {
+	if value == nil {
+		return nil
+	}
+
+	if str, ok := value.(string); ok {
+		var result []string
+		for _, col := range strings.Split(str, ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				result = append(result, col)
+			}
+		}
+
+		return result
+	}
+
+	var result []string
+
+	val, _ := indirect(reflect.ValueOf(value))
+	if (val.Kind() == reflect.Array) || (val.Kind() == reflect.Slice) {
+		for i := 0; i < val.Len(); i++ {
+			result = append(result, Str(val.Index(i).Interface()))
+		}
+	}
+
+	return result
+}
+
+// tableDataRows returns the rows provided with the `rows` hash argument
+//
+// Each row is either a list of cells, or an object whose fields are selected by columns.
+func tableDataRows(options *Options, value interface{}, columns []string) [][]string {
+	val, _ := indirect(reflect.ValueOf(value))
+	if (val.Kind() != reflect.Array) && (val.Kind() != reflect.Slice) {
+		options.eval.errorf("table helper rows must be a list, got: %s", val.Kind())
+	}
+
+	var result [][]string
+
+	for i := 0; i < val.Len(); i++ {
+		item := val.Index(i).Interface()
+
+		var row []string
+
+		if len(columns) > 0 {
+			for _, col := range columns {
+				row = append(row, Str(options.Eval(item, col)))
+			}
+		} else {
+			itemVal, _ := indirect(reflect.ValueOf(item))
+			if (itemVal.Kind() != reflect.Array) && (itemVal.Kind() != reflect.Slice) {
+				options.eval.errorf("table helper needs columns to render row %d", i)
+			}
+
+			for j := 0; j < itemVal.Len(); j++ {
+				row = append(row, Str(itemVal.Index(j).Interface()))
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	return result
+}
+
+// tableWidths returns the width of each column of given rows
+func tableWidths(rows [][]string, minWidth int) []int {
+	var result []int
+
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(result) {
+				result = append(result, minWidth)
+			}
+
+			if w := utf8.RuneCountInString(cell); w > result[i] {
+				result[i] = w
+			}
+		}
+	}
+
+	return result
+}
+
+// writeTableRow writes a row of cells padded to given widths
+func writeTableRow(buf *strings.Builder, row []string, widths []int) {
+	buf.WriteString("|")
+
+	for i, width := range widths {
+		cell := ""
+		if i < len(row) {
+			cell = row[i]
+		}
+
+		buf.WriteString(" ")
+		buf.WriteString(cell)
+		buf.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(cell)))
+		buf.WriteString(" |")
+	}
+
+	buf.WriteString("\n")
+}
+
+// renderASCIITable renders given rows as an ASCII table, with a separator after first row if header is true
+func renderASCIITable(rows [][]string, header bool) string {
+	if len(rows) == 0 {
+		return ""
+	}
+
+	widths := tableWidths(rows, 0)
+
+	sep := "+"
+	for _, width := range widths {
+		sep += strings.Repeat("-", width+2) + "+"
+	}
+	sep += "\n"
+
+	var buf strings.Builder
+
+	buf.WriteString(sep)
+	for i, row := range rows {
+		writeTableRow(&buf, row, widths)
+
+		if (i == 0) && header && (len(rows) > 1) {
+			buf.WriteString(sep)
+		}
+	}
+	buf.WriteString(sep)
+
+	return buf.String()
+}
+
+// renderMarkdownTable renders given rows as a markdown table, the first row being the header
+func renderMarkdownTable(rows [][]string) string {
+	if len(rows) == 0 {
+		return ""
+	}
+
+	escaped := make([][]string, len(rows))
+	for i, row := range rows {
+		for _, cell := range row {
+			escaped[i] = append(escaped[i], strings.Replace(cell, "|", `\|`, -1))
+		}
+	}
+
+	// markdown delimiter row needs at least three dashes
+	widths := tableWidths(escaped, 3)
+
+	var buf strings.Builder
+
+	for i, row := range escaped {
+		writeTableRow(&buf, row, widths)
+
+		if i == 0 {
+			buf.WriteString("|")
+			for _, width := range widths {
+				buf.WriteString(" " + strings.Repeat("-", width) + " |")
+			}
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
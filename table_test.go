@@ -0,0 +1,125 @@
+package raymond
+
+import "testing"
+
+var tablePeople = []map[string]interface{}{
+	{"name": "Alice", "age": 42},
+	{"name": "Bob", "age": 7},
+}
+
+var tableTests = []Test{
+	{
+		"table block helper",
+		"{{#table header=true}}\n" +
+			"{{#table.row}}{{table.cell \"Name\"}}{{table.cell \"Age\"}}{{/table.row}}\n" +
+			"{{#each people}}\n" +
+			"{{#table.row}}{{table.cell name}}{{table.cell age}}{{/table.row}}\n" +
+			"{{/each}}\n" +
+			"{{/table}}",
+		map[string]interface{}{"people": tablePeople},
+		nil, nil, nil,
+		"+-------+-----+\n" +
+			"| Name  | Age |\n" +
+			"+-------+-----+\n" +
+			"| Alice | 42  |\n" +
+			"| Bob   | 7   |\n" +
+			"+-------+-----+\n",
+	},
+	{
+		"table block helper without header",
+		`{{#table}}{{#table.row}}{{table.cell "a"}}{{table.cell "bb"}}{{/table.row}}{{#table.row}}{{table.cell "ccc"}}{{/table.row}}{{/table}}`,
+		nil, nil, nil, nil,
+		"+-----+----+\n" +
+			"| a   | bb |\n" +
+			"| ccc |    |\n" +
+			"+-----+----+\n",
+	},
+	{
+		"table block helper with markdown format",
+		`{{#table format="markdown"}}{{#table.row}}{{table.cell "Name"}}{{table.cell "Note"}}{{/table.row}}{{#table.row}}{{table.cell "Bob"}}{{table.cell "a|b"}}{{/table.row}}{{/table}}`,
+		nil, nil, nil, nil,
+		"| Name | Note |\n" +
+			"| ---- | ---- |\n" +
+			"| Bob  | a\\|b |\n",
+	},
+	{
+		"table helper with rows and columns",
+		`{{{table rows=people columns="name, age" format="markdown"}}}`,
+		map[string]interface{}{"people": tablePeople},
+		nil, nil, nil,
+		"| name  | age |\n" +
+			"| ----- | --- |\n" +
+			"| Alice | 42  |\n" +
+			"| Bob   | 7   |\n",
+	},
+	{
+		"table helper with list rows",
+		`{{{table rows=rows}}}`,
+		map[string]interface{}{"rows": [][]interface{}{{"x", 1}, {"y", 22}}},
+		nil, nil, nil,
+		"+---+----+\n" +
+			"| x | 1  |\n" +
+			"| y | 22 |\n" +
+			"+---+----+\n",
+	},
+	{
+		"table helper with non-ASCII characters",
+		`{{#table}}{{#table.row}}{{table.cell "été"}}{{/table.row}}{{#table.row}}{{table.cell "a"}}{{/table.row}}{{/table}}`,
+		nil, nil, nil, nil,
+		"+-----+\n" +
+			"| été |\n" +
+			"| a   |\n" +
+			"+-----+\n",
+	},
+	{
+		"table helper inside another block",
+		`{{#if ok}}{{{table rows=rows}}}{{/if}}`,
+		map[string]interface{}{"ok": true, "rows": [][]string{{"x"}}},
+		nil, nil, nil,
+		"+---+\n| x |\n+---+\n",
+	},
+	{
+		"table helper without rows",
+		`{{#table}}{{/table}}`,
+		nil, nil, nil, nil,
+		"",
+	},
+}
+
+var tableErrors = []Test{
+	{
+		"table.row helper outside table",
+		`{{#table.row}}{{/table.row}}`,
+		nil, nil, nil, nil,
+		"table.row helper must be used inside a table block",
+	},
+	{
+		"table.cell helper outside row",
+		`{{#table}}{{table.cell "a"}}{{/table}}`,
+		nil, nil, nil, nil,
+		"table.cell helper must be used inside a table.row block",
+	},
+	{
+		"table helper with unknown format",
+		`{{#table format="html"}}{{/table}}`,
+		nil, nil, nil, nil,
+		`Unknown table format: "html"`,
+	},
+	{
+		"table helper with object rows and no columns",
+		`{{table rows=people}}`,
+		map[string]interface{}{"people": tablePeople},
+		nil, nil, nil,
+		"table helper needs columns to render row 0",
+	},
+}
+
+func TestTableHelper(t *testing.T) {
+	t.Parallel()
+
+	launchTests(t, tableTests)
+}
+
+func TestTableHelperErrors(t *testing.T) {
+	launchErrorTests(t, tableErrors)
+}
@@ -2,30 +2,99 @@ package raymond
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"reflect"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/aymerick/raymond/ast"
 	"github.com/aymerick/raymond/parser"
 )
 
 // Template represents a handlebars template.
+//
+// A parsed template is safe for concurrent evaluations. Evaluations never take a lock to find helpers, partials and middlewares: registries are immutable maps, copied and atomically replaced on registration. Helpers and partials can thus be registered while the template is being evaluated, the new ones being available to subsequent evaluations.
 type Template struct {
-	source   string
-	program  *ast.Program
-	helpers  map[string]reflect.Value
-	partials map[string]*partial
-	mutex    sync.RWMutex // protects helpers and partials
+	source      string
+	program     *ast.Program
+	code        *compiledPrograms
+	helpers     helperRegistry
+	helpersInfo map[string]HelperInfo
+	middlewares middlewareRegistry
+	partials    partialRegistry
+	mutex       sync.RWMutex // serializes registrations, and protects helpersInfo
+
+	// allocator of program nodes, nil if nodes are allocated individually
+	arena *ast.Arena
+
+	// maximum size of content nodes
+	contentChunkSize int
+
+	// escape mustaches depending on where they sit in HTML
+	contextualEscaping bool
+
+	// characters escaped in HTML
+	escaping Escaping
+
+	// numbers behave like in JavaScript
+	jsNumbers bool
+
+	// hide the details of parse and evaluation errors
+	redactErrors bool
+
+	// handling of invalid UTF-8 in source and output
+	invalidUTF8 UTF8Policy
+
+	// security policy, and its sandbox prepared for lookups, nil if template is trusted
+	policy  SecurityPolicy
+	sandbox *sandbox
+
+	// Mustache delimiters in effect at template start, nil if this is a Handlebars template
+	mustache *mustacheDelims
+}
+
+// ParseOptions represents the options used to parse a template.
+type ParseOptions struct {
+	// Arena allocates all AST nodes of template from a few slabs, released with the template, instead of one by one. This reduces GC scanning cost when lots of templates are kept in memory.
+	Arena bool
+
+	// ContentChunkSize is the maximum size in bytes of content nodes, zero meaning DefaultContentChunkSize. Larger static spans between mustaches are split in several chunks, rendered one after the other, so that memory usage does not depend on their size.
+	ContentChunkSize int
+
+	// ContextualEscaping escapes each mustache depending on where it sits in HTML, like html/template does: in attribute values, URLs, scripts and styles. By default, all mustaches are HTML escaped.
+	ContextualEscaping bool
+
+	// Escaping is the set of characters escaped by mustaches: EscapeHandlebars (the default) escapes the same characters as handlebars.js, EscapeGo the same ones as html.EscapeString(), and EscapeNone none of them, for templates that do not output HTML. Choose the one of the runtime templates are migrated from, to get identical outputs.
+	Escaping Escaping
+
+	// JSNumbers makes numbers behave like in JavaScript, for templates shared with a handlebars.js frontend: rendered numbers are converted to float64 and formatted like Number.prototype.toString() does, the #equal, #switch and #case helpers compare values with JS loose equality (==), numeric arguments of the #times, #range, #indent and #nindent helpers are converted with JS Number() rules, and NaN is falsy.
+	JSNumbers bool
+
+	// RedactErrors replaces parse and evaluation errors with a *RedactedError, whose message only includes the kind of error, the partial name and the line: template source and context values are never included. Use it in production, and keep detailed errors in development.
+	RedactErrors bool
+
+	// InvalidUTF8 defines how invalid UTF-8 sequences are handled, in template source and in the values and helper results rendered by template: they are kept as is (UTF8PassThrough, the default), replaced with U+FFFD (UTF8Replace), or rejected with an error (UTF8Error). Partials are checked when rendered.
+	InvalidUTF8 UTF8Policy
+
+	// Policy is the security policy of template, that limits its size and evaluations, and restricts what it is allowed to use. If nil, template is trusted.
+	Policy *SecurityPolicy
+
+	// Mustache parses template with strict Mustache semantics, as defined by the Mustache spec: set delimiters tags are supported, names are never resolved to helpers, lambdas found in context receive the raw source of sections and their string results are rendered as templates, missing partials render nothing, and each line of standalone partials is indented. Partials registered on template are parsed as Mustache too.
+	Mustache bool
 }
 
+// DefaultContentChunkSize is the default maximum size in bytes of content nodes.
+const DefaultContentChunkSize = 64 << 10
+
 // newTemplate instanciate a new template without parsing it
 func newTemplate(source string) *Template {
 	return &Template{
-		source:   source,
-		helpers:  make(map[string]reflect.Value),
-		partials: make(map[string]*partial),
+		source:           source,
+		helpersInfo:      make(map[string]HelperInfo),
+		contentChunkSize: DefaultContentChunkSize,
 	}
 }
 
@@ -41,6 +110,40 @@ func Parse(source string) (*Template, error) {
 	return tpl, nil
 }
 
+// ParseWithOptions instanciates a template by parsing given source, with given options.
+func ParseWithOptions(source string, opts ParseOptions) (*Template, error) {
+	tpl := newTemplate(source)
+
+	if opts.Arena {
+		tpl.arena = ast.NewArena()
+	}
+
+	if opts.ContentChunkSize > 0 {
+		tpl.contentChunkSize = opts.ContentChunkSize
+	}
+
+	tpl.contextualEscaping = opts.ContextualEscaping
+	tpl.escaping = opts.Escaping
+	tpl.jsNumbers = opts.JSNumbers
+	tpl.redactErrors = opts.RedactErrors
+	tpl.invalidUTF8 = opts.InvalidUTF8
+	tpl.setPolicy(opts.Policy)
+
+	if opts.Mustache {
+		tpl.mustache = &mustacheDelims{open: defaultMustacheDelims.open, close: defaultMustacheDelims.close}
+	}
+
+	if err := tpl.parse(); err != nil {
+		return nil, tpl.redactParseError(err)
+	}
+
+	if err := tpl.checkEscaping(); err != nil {
+		return nil, tpl.redactParseError(err)
+	}
+
+	return tpl, nil
+}
+
 // MustParse instanciates a template by parsing given source. It panics on error.
 func MustParse(source string) *Template {
 	result, err := Parse(source)
@@ -65,42 +168,94 @@ func ParseFile(filePath string) (*Template, error) {
 // It can be called several times, the parsing will be done only once.
 func (tpl *Template) parse() error {
 	if tpl.program == nil {
+		if err := tpl.policy.checkInput(len(tpl.source)); err != nil {
+			return err
+		}
+
+		source := tpl.source
+
+		var sections map[int]mustacheSection
 		var err error
 
-		tpl.program, err = parser.Parse(tpl.source)
+		if tpl.mustache != nil {
+			if source, sections, err = translateMustache(source, *tpl.mustache); err != nil {
+				return err
+			}
+		}
+
+		tpl.program, err = parser.ParseWithOptions(source, parser.Options{
+			Arena:          tpl.arena,
+			MaxContentSize: tpl.contentChunkSize,
+			InvalidUTF8:    tpl.invalidUTF8,
+		})
 		if err != nil {
 			return err
 		}
+
+		if err := tpl.checkSandbox(); err != nil {
+			return err
+		}
+
+		tpl.code = compile(tpl.program)
+
+		if tpl.mustache != nil {
+			tpl.code.sections = mustacheSections(tpl.program, sections)
+		}
 	}
 
 	return nil
 }
 
+// checkEscaping returns an error if template calls partials where they can't be contextually escaped
+func (tpl *Template) checkEscaping() error {
+	if !tpl.contextualEscaping {
+		return nil
+	}
+
+	return tpl.code.checkEscaping(false)
+}
+
 // Clone returns a copy of that template.
 func (tpl *Template) Clone() *Template {
 	result := newTemplate(tpl.source)
 
 	result.program = tpl.program
+	result.code = tpl.code
+	result.arena = tpl.arena
+	result.contentChunkSize = tpl.contentChunkSize
+	result.contextualEscaping = tpl.contextualEscaping
+	result.escaping = tpl.escaping
+	result.jsNumbers = tpl.jsNumbers
+	result.redactErrors = tpl.redactErrors
+	result.invalidUTF8 = tpl.invalidUTF8
+	result.policy = tpl.policy
+	result.sandbox = tpl.sandbox
+	result.mustache = tpl.mustache
 
 	tpl.mutex.RLock()
 	defer tpl.mutex.RUnlock()
 
-	for name, helper := range tpl.helpers {
-		result.RegisterHelper(name, helper.Interface())
+	// registries are immutable, so they are shared until a registration occurs
+	result.helpers.m.Store(tpl.helpers.load())
+	result.middlewares.m.Store(tpl.middlewares.load())
+
+	for name, info := range tpl.helpersInfo {
+		result.helpersInfo[name] = info
 	}
 
-	for name, partial := range tpl.partials {
-		result.addPartial(name, partial.source, partial.tpl)
+	for _, partial := range tpl.partials.load() {
+		p := newPartial(partial.name, partial.source, partial.tpl)
+		p.pure = partial.pure
+		p.mustache = partial.mustache
+
+		result.addPartial(p)
 	}
 
 	return result
 }
 
 func (tpl *Template) findHelper(name string) reflect.Value {
-	tpl.mutex.RLock()
-	defer tpl.mutex.RUnlock()
-
-	return tpl.helpers[name]
+	return tpl.helpers.get(name)
 }
 
 // RegisterHelper registers a helper for that template.
@@ -108,51 +263,82 @@ func (tpl *Template) RegisterHelper(name string, helper interface{}) {
 	tpl.mutex.Lock()
 	defer tpl.mutex.Unlock()
 
-	if tpl.helpers[name] != zero {
+	if tpl.helpers.get(name) != zero {
 		panic(fmt.Sprintf("Helper %s already registered", name))
 	}
 
 	val := reflect.ValueOf(helper)
 	ensureValidHelper(name, val)
 
-	tpl.helpers[name] = val
+	tpl.helpers.set(parser.Intern(name), val)
 }
 
 // RegisterHelpers registers several helpers for that template.
 func (tpl *Template) RegisterHelpers(helpers map[string]interface{}) {
-	for name, helper := range helpers {
-		tpl.RegisterHelper(name, helper)
+	batch := newHelperBatch(helpers)
+
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	for name := range batch {
+		if tpl.helpers.get(name) != zero {
+			panic(fmt.Sprintf("Helper %s already registered", name))
+		}
 	}
+
+	tpl.helpers.setAll(batch)
 }
 
-func (tpl *Template) addPartial(name string, source string, template *Template) {
+// RegisterNamespace registers several helpers under given namespace for that template.
+//
+// For example, an `upper` helper registered under the `str` namespace is called with `{{str.upper name}}`.
+func (tpl *Template) RegisterNamespace(namespace string, helpers map[string]interface{}) {
+	tpl.RegisterHelpers(namespacedHelpers(namespace, helpers))
+}
+
+func (tpl *Template) addPartial(p *partial) {
 	tpl.mutex.Lock()
 	defer tpl.mutex.Unlock()
 
-	if tpl.partials[name] != nil {
-		panic(fmt.Sprintf("Partial %s already registered", name))
+	if tpl.partials.get(p.name) != nil {
+		panic(fmt.Sprintf("Partial %s already registered", p.name))
 	}
 
-	tpl.partials[name] = newPartial(name, source, template)
+	tpl.partials.set(parser.Intern(p.name), p)
 }
 
 func (tpl *Template) findPartial(name string) *partial {
-	tpl.mutex.RLock()
-	defer tpl.mutex.RUnlock()
-
-	return tpl.partials[name]
+	return tpl.partials.get(name)
 }
 
 // RegisterPartial registers a partial for that template.
 func (tpl *Template) RegisterPartial(name string, source string) {
-	tpl.addPartial(name, source, nil)
+	p := newPartial(name, source, nil)
+	p.mustache = tpl.mustache != nil
+
+	tpl.addPartial(p)
 }
 
 // RegisterPartials registers several partials for that template.
 func (tpl *Template) RegisterPartials(partials map[string]string) {
-	for name, partial := range partials {
-		tpl.RegisterPartial(name, partial)
+	batch := make(map[string]*partial, len(partials))
+	for name, source := range partials {
+		p := newPartial(name, source, nil)
+		p.mustache = tpl.mustache != nil
+
+		batch[parser.Intern(name)] = p
+	}
+
+	tpl.mutex.Lock()
+	defer tpl.mutex.Unlock()
+
+	for name := range batch {
+		if tpl.partials.get(name) != nil {
+			panic(fmt.Sprintf("Partial %s already registered", name))
+		}
 	}
+
+	tpl.partials.setAll(batch)
 }
 
 // RegisterPartialFile reads given file and registers its content as a partial with given name.
@@ -186,7 +372,51 @@ func (tpl *Template) RegisterPartialFiles(filePaths ...string) error {
 
 // RegisterPartialTemplate registers an already parsed partial for that template.
 func (tpl *Template) RegisterPartialTemplate(name string, template *Template) {
-	tpl.addPartial(name, "", template)
+	tpl.addPartial(newPartial(name, "", template))
+}
+
+// ExecOptions represents template evaluation options.
+type ExecOptions struct {
+	// Data is the initial private data frame. If nil, an empty data frame is used.
+	Data *DataFrame
+
+	// Rand is the source of randomness used by random helpers. If nil, a time seeded source is used (and uuid helper uses crypto/rand).
+	//
+	// Provide a seeded source to get a stable output.
+	Rand rand.Source
+
+	// PartialConcurrency is the maximum number of partials rendered concurrently. If zero, partials are rendered sequentially.
+	//
+	// When set, the partials of a program are rendered concurrently into ordered buffers, which improves latency when they call slow helpers (eg. helpers doing I/O). Helpers used by partials must then be safe for concurrent use, and partials must not depend on the side effects of the statements that precede them. This is ignored when Rand, Deterministic or Profiler is set, to keep output and records stable.
+	PartialConcurrency int
+
+	// Profiler records the render time and invocation counts of statements, helpers and partials. If nil, nothing is recorded.
+	Profiler *Profiler
+
+	// Coverage records which programs of templates are rendered, ie. which branches of blocks. If nil, nothing is recorded.
+	Coverage *Coverage
+
+	// MemoryBudget is the approximate number of bytes an evaluation is allowed to produce, counting both output and intermediate results (eg. the output of a block helper is counted when the helper renders it, and again when it is written). If exceeded, evaluation stops with a *BudgetExceededError. Zero means no limit.
+	//
+	// This permits multi-tenant renderers to enforce fairness between templates.
+	MemoryBudget int64
+
+	// PartialMemo memoizes the outputs of pure partials across evaluations. If nil, outputs are only memoized during an evaluation.
+	PartialMemo *PartialMemo
+
+	// Nonce is the Content-Security-Policy nonce of the response, eg. generated with NewNonce(). If set, it is injected as a nonce attribute into all script and style start tags of template and partials, and is available as @nonce.
+	//
+	// This permits to serve a strict policy such as `script-src 'nonce-...'` without marking up every tag by hand.
+	Nonce string
+
+	// Policy tightens the security policy of template for this evaluation: the smaller of each limit is kept, and a sandbox is merged with the one of template. The MaxInput limit is ignored. If nil, the policy of template applies.
+	Policy *SecurityPolicy
+
+	// Deterministic guarantees byte-identical outputs across runs, so that rendered templates can be snapshot tested: maps are iterated in sorted key order by the #each helper, Options.Now() returns DeterministicTime unless Now is set, and random helpers use a source seeded with DeterministicSeed unless Rand is set.
+	Deterministic bool
+
+	// Now returns the current time returned by Options.Now() to helpers. If nil, time.Now is used.
+	Now func() time.Time
 }
 
 // Exec evaluates template with given context.
@@ -205,24 +435,153 @@ func (tpl *Template) MustExec(ctx interface{}) string {
 
 // ExecWith evaluates template with given context and private data frame.
 func (tpl *Template) ExecWith(ctx interface{}, privData *DataFrame) (result string, err error) {
-	defer errRecover(&err)
+	return tpl.ExecWithOptions(ctx, ExecOptions{Data: privData})
+}
 
-	// parses template if necessary
-	err = tpl.parse()
+// ExecWithOptions evaluates template with given context and evaluation options.
+func (tpl *Template) ExecWithOptions(ctx interface{}, opts ExecOptions) (result string, err error) {
+	if text, ok := tpl.staticText(); ok && (opts.Profiler == nil) && (opts.Coverage == nil) && (opts.MemoryBudget == 0) {
+		// nothing to evaluate
+		return text, nil
+	}
+
+	buf, err := tpl.ExecBufferWithOptions(ctx, opts)
 	if err != nil {
-		return
+		return "", err
+	}
+
+	result = buf.String()
+	buf.Release()
+
+	return result, nil
+}
+
+// ExecBuffer evaluates template with given context, and returns result in a pooled buffer.
+//
+// Call Release() on returned buffer once done with it, for example after writing it to an http.ResponseWriter: this avoids allocating a new output buffer for each evaluation.
+func (tpl *Template) ExecBuffer(ctx interface{}) (*Buffer, error) {
+	return tpl.ExecBufferWithOptions(ctx, ExecOptions{})
+}
+
+// ExecBufferWithOptions evaluates template with given context and evaluation options, and returns result in a pooled buffer.
+//
+// Call Release() on returned buffer once done with it.
+func (tpl *Template) ExecBufferWithOptions(ctx interface{}, opts ExecOptions) (*Buffer, error) {
+	buf := getBuffer()
+
+	if err := tpl.exec(&buf.Buffer, ctx, opts); err != nil {
+		buf.Release()
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// ExecTo evaluates template with given context, and writes result to given writer.
+//
+// The result is written as evaluation goes, without building intermediate strings, so the writer may have received a partial output when an error is returned. Use ExecBuffer() to get all or nothing.
+func (tpl *Template) ExecTo(w io.Writer, ctx interface{}) error {
+	return tpl.ExecToWithOptions(w, ctx, ExecOptions{})
+}
+
+// ExecToWithOptions evaluates template with given context and evaluation options, and writes result to given writer.
+func (tpl *Template) ExecToWithOptions(w io.Writer, ctx interface{}, opts ExecOptions) error {
+	if sw, ok := w.(writer); ok {
+		return tpl.exec(sw, ctx, opts)
+	}
+
+	// buffer writes to writers that do not support strings
+	bw := getBufioWriter(w)
+	defer releaseBufioWriter(bw)
+
+	if err := tpl.exec(bw, ctx, opts); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// exec evaluates template with given context and evaluation options, and writes result to given writer
+func (tpl *Template) exec(w writer, ctx interface{}, opts ExecOptions) error {
+	// parses template if necessary
+	if err := tpl.parse(); err != nil {
+		return tpl.redactParseError(err)
 	}
 
 	// setup visitor
-	v := newEvalVisitor(tpl, ctx, privData)
+	v := newEvalVisitor(tpl, ctx, opts.Data)
+	defer v.release()
+
+	v.setOptions(opts)
+
+	return v.render(w)
+}
+
+// setOptions sets up visitor with given evaluation options
+func (v *evalVisitor) setOptions(opts ExecOptions) {
+	source := opts.Rand
+	if (source == nil) && opts.Deterministic {
+		source = rand.NewSource(DeterministicSeed)
+	}
+
+	if source != nil {
+		v.rand = rand.New(source)
+		v.randSeeded = true
+	} else if (opts.PartialConcurrency > 0) && (opts.Profiler == nil) {
+		v.partialSem = make(chan struct{}, opts.PartialConcurrency)
+	}
+
+	if opts.MemoryBudget > 0 {
+		v.budget = &memoryBudget{limit: opts.MemoryBudget}
+	}
+
+	v.setPolicy(opts)
+
+	if (v.sandbox == nil) && (opts.Coverage == nil) {
+		// outputs of pure partials depend on the helpers allowed by sandbox, and must be rendered once per evaluation to record coverage
+		v.sharedMemo = opts.PartialMemo
+	}
+
+	v.profiler = opts.Profiler
+	v.coverage = opts.Coverage
+	v.deterministic = opts.Deterministic
+	v.clock = opts.Now
+	v.secrets = newSecretSet()
+	v.nonce = opts.Nonce
+	v.nonceAttr = nonceAttr(opts.Nonce)
+}
+
+// render evaluates template program, and writes result to given writer
+func (v *evalVisitor) render(w writer) (err error) {
+	defer v.redactError(&err)
+	defer v.redactDetails(&err)
+	defer errRecover(&err)
+
+	v.profileStart(ProfileTemplate, "", 0)
 
 	// visit AST
-	result, _ = tpl.program.Accept(v).(string)
+	v.programTo(w, v.tpl.program)
+
+	v.profileEnd()
 
 	// named return values
 	return
 }
 
+// staticText returns template output and true if template only contains content
+func (tpl *Template) staticText() (string, bool) {
+	if tpl.parse() != nil {
+		return "", false
+	}
+
+	code := tpl.code.get(tpl.program)
+	if (code == nil) || !code.static {
+		return "", false
+	}
+
+	return code.text, true
+}
+
 // errRecover recovers evaluation panic
 func errRecover(errp *error) {
 	e := recover()
@@ -1,7 +1,11 @@
 package raymond
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -46,6 +50,28 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseWithOptions(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions(sourceBasic, ParseOptions{Arena: true})
+	if err != nil {
+		t.Fatalf("Failed to parse template: %s", err)
+	}
+
+	if tpl.arena.Len() == 0 {
+		t.Errorf("Template nodes not allocated from arena")
+	}
+
+	if str := tpl.PrintAST(); str != basicAST {
+		t.Errorf("Template parsing incorrect: %s", str)
+	}
+
+	output := tpl.Clone().MustExec(map[string]string{"title": "foo", "body": "bar"})
+	if expected := "<div class=\"entry\">\n  <h1>foo</h1>\n  <div class=\"body\">\n    bar\n  </div>\n</div>"; output != expected {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
 func TestClone(t *testing.T) {
 	t.Parallel()
 
@@ -55,27 +81,104 @@ func TestClone(t *testing.T) {
 	tpl := MustParse(sourceBasic)
 	tpl.RegisterPartial("p", sourcePartial)
 
-	if (len(tpl.partials) != 1) || (tpl.partials["p"] == nil) {
+	if (len(tpl.partials.load()) != 1) || (tpl.partials.get("p") == nil) {
 		t.Errorf("What?")
 	}
 
 	cloned := tpl.Clone()
 
-	if (len(cloned.partials) != 1) || (cloned.partials["p"] == nil) {
+	if (len(cloned.partials.load()) != 1) || (cloned.partials.get("p") == nil) {
 		t.Errorf("Template partials must be cloned")
 	}
 
 	cloned.RegisterPartial("p2", sourcePartial2)
 
-	if (len(cloned.partials) != 2) || (cloned.partials["p"] == nil) || (cloned.partials["p2"] == nil) {
+	if (len(cloned.partials.load()) != 2) || (cloned.partials.get("p") == nil) || (cloned.partials.get("p2") == nil) {
 		t.Errorf("Failed to register a partial on cloned template")
 	}
 
-	if (len(tpl.partials) != 1) || (tpl.partials["p"] == nil) {
+	if (len(tpl.partials.load()) != 1) || (tpl.partials.get("p") == nil) {
 		t.Errorf("Modification of a cloned template MUST NOT affect original template")
 	}
 }
 
+// bytesWriter is an io.Writer that does not implement WriteString()
+type bytesWriter struct {
+	buf []byte
+}
+
+func (w *bytesWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// failingWriter fails after given number of writes
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	return w.WriteString(string(p))
+}
+
+func (w *failingWriter) WriteString(s string) (int, error) {
+	if w.writes == 0 {
+		return 0, errors.New("write failed")
+	}
+
+	w.writes--
+	return len(s), nil
+}
+
+func TestExecTo(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}<{{this}}>{{{this}}}{{> p}}{{/each}}{{#if ok}}{{else}}!{{/if}}`)
+	tpl.RegisterPartial("p", ` {{@index}}
+`)
+
+	ctx := map[string][]string{"items": {"a&b", "c"}}
+	expected := `<a&amp;b>a&b 0
+<c>c 1
+!`
+
+	var sb strings.Builder
+	if err := tpl.ExecTo(&sb, ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if sb.String() != expected {
+		t.Errorf("Unexpected output\nexpected:\n\t%q\ngot:\n\t%q", expected, sb.String())
+	}
+
+	var bw bytesWriter
+	if err := tpl.ExecTo(&bw, ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if string(bw.buf) != expected {
+		t.Errorf("Unexpected output\nexpected:\n\t%q\ngot:\n\t%q", expected, bw.buf)
+	}
+}
+
+func TestExecToError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#items}}{{this}}{{/items}}`)
+	ctx := map[string][]string{"items": {"a", "b", "c"}}
+
+	err := tpl.ExecTo(&failingWriter{writes: 2}, ctx)
+	if (err == nil) || !strings.Contains(err.Error(), "write failed") {
+		t.Errorf("Expected write error, got: %v", err)
+	}
+
+	// buffered writer errors are returned on flush
+	err = tpl.ExecTo(struct{ io.Writer }{&failingWriter{}}, ctx)
+	if (err == nil) || !strings.Contains(err.Error(), "write failed") {
+		t.Errorf("Expected write error, got: %v", err)
+	}
+}
+
 func ExampleTemplate_Exec() {
 	source := "<h1>{{title}}</h1><p>{{body.content}}</p>"
 
@@ -140,6 +243,25 @@ func ExampleTemplate_ExecWith() {
 	// Output: <h1>foo</h1><p>bar and unicorns</p>
 }
 
+func ExampleTemplate_ExecTo() {
+	source := "<h1>{{title}}</h1><p>{{body.content}}</p>"
+
+	ctx := map[string]interface{}{
+		"title": "foo",
+		"body":  map[string]string{"content": "bar"},
+	}
+
+	// parse template
+	tpl := MustParse(source)
+
+	// evaluate template and write result to stdout
+	if err := tpl.ExecTo(os.Stdout, ctx); err != nil {
+		panic(err)
+	}
+
+	// Output: <h1>foo</h1><p>bar</p>
+}
+
 func ExampleTemplate_PrintAST() {
 	source := "<h1>{{title}}</h1><p>{{#body}}{{content}} and {{@baz.bat}}{{/body}}</p>"
 
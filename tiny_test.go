@@ -0,0 +1,35 @@
+//go:build raymond_tiny
+
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTinyContexts(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name string
+	}
+
+	tpl := MustParse(`{{#each users}}{{name}}{{#if admin}}*{{/if}} {{/each}}`)
+
+	ctx := map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "jean", "admin": true},
+			map[string]string{"name": "marcel"},
+		},
+	}
+
+	if result := tpl.MustExec(ctx); result != "jean* marcel " {
+		t.Errorf("Unexpected output: %q", result)
+	}
+
+	// struct fields are not silently ignored
+	_, err := MustParse(`{{struct.Name}}`).Exec(map[string]interface{}{"struct": user{Name: "jean"}})
+	if (err == nil) || !strings.Contains(err.Error(), "struct fields and methods are not supported in tiny build mode") {
+		t.Errorf("Expected struct field error, got: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package raymond
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aymerick/raymond/lexer"
+)
+
+// UTF8Policy defines how invalid UTF-8 sequences are handled in template sources, and in the values and helper results rendered by templates.
+type UTF8Policy = lexer.UTF8Policy
+
+const (
+	// UTF8PassThrough keeps invalid UTF-8 sequences as is. This is the default.
+	UTF8PassThrough = lexer.UTF8PassThrough
+
+	// UTF8Replace replaces each run of invalid UTF-8 bytes with the replacement rune U+FFFD.
+	UTF8Replace = lexer.UTF8Replace
+
+	// UTF8Error fails parsing or evaluation on the first invalid UTF-8 sequence.
+	UTF8Error = lexer.UTF8Error
+)
+
+// validUTF8 applies the UTF-8 policy of template to given output string
+func (v *evalVisitor) validUTF8(str string) string {
+	if (v.tpl.invalidUTF8 == UTF8PassThrough) || utf8.ValidString(str) {
+		return str
+	}
+
+	if v.tpl.invalidUTF8 == UTF8Error {
+		// the value is not reported, as it may be a secret
+		v.errorf("Invalid UTF-8 sequence in output")
+	}
+
+	return strings.ToValidUTF8(str, string(utf8.RuneError))
+}
@@ -0,0 +1,75 @@
+package raymond
+
+import (
+	"strings"
+	"testing"
+)
+
+var invalidUTF8Tests = []struct {
+	name   string
+	input  string
+	data   interface{}
+	policy UTF8Policy
+	output string
+	err    string
+}{
+	{"source pass through", "a\xffb", nil, UTF8PassThrough, "a\xffb", ""},
+	{"source replace", "a\xff\xfeb {{foo}}", nil, UTF8Replace, "a�b ", ""},
+	{"source error", "a\n\xffb", nil, UTF8Error, "", "Invalid UTF-8 sequence at byte 2"},
+
+	{"value pass through", "{{foo}} {{{foo}}}", map[string]string{"foo": "<\xff>"}, UTF8PassThrough, "&lt;\xff&gt; <\xff>", ""},
+	{"value replace", "{{foo}} {{{foo}}}", map[string]string{"foo": "<\xff>"}, UTF8Replace, "&lt;�&gt; <�>", ""},
+	{"escaped value error", "{{foo}}", map[string]string{"foo": "\xff"}, UTF8Error, "", "Invalid UTF-8 sequence in output"},
+	{"unescaped value error", "{{{foo}}}", map[string]string{"foo": "\xff"}, UTF8Error, "", "Invalid UTF-8 sequence in output"},
+	{"block result error", "{{#each foo}}{{this}}{{/each}}", map[string][]SafeString{"foo": {"a", "\xff"}}, UTF8Error, "", "Invalid UTF-8 sequence in output"},
+
+	{"valid replacement rune", "�{{foo}}", map[string]string{"foo": "�"}, UTF8Error, "��", ""},
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range invalidUTF8Tests {
+		output, err := func() (string, error) {
+			tpl, err := ParseWithOptions(test.input, ParseOptions{InvalidUTF8: test.policy})
+			if err != nil {
+				return "", err
+			}
+
+			return tpl.Exec(test.data)
+		}()
+
+		switch {
+		case test.err != "":
+			if (err == nil) || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test '%s' failed, expected error %q, got: %v", test.name, test.err, err)
+			}
+		case err != nil:
+			t.Errorf("Test '%s' failed: %s", test.name, err)
+		case output != test.output:
+			t.Errorf("Test '%s' failed\nexpected:\n\t%q\ngot:\n\t%q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestInvalidUTF8Partial(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := ParseWithOptions("{{> part}}{{#if foo}}{{> other}}{{/if}}", ParseOptions{InvalidUTF8: UTF8Replace})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl.RegisterPartial("part", "a\xffb")
+
+	if output := tpl.MustExec(nil); output != "a�b" {
+		t.Errorf("Unexpected partial output: %q", output)
+	}
+
+	clone := tpl.Clone()
+	clone.RegisterPartial("other", "{{foo}}")
+
+	if output := clone.MustExec(map[string]string{"foo": "\xff"}); output != "a�b�" {
+		t.Errorf("Policy not cloned: %q", output)
+	}
+}
@@ -1,8 +1,11 @@
 package raymond
 
 import (
+	"math"
 	"path"
 	"reflect"
+	"strconv"
+	"time"
 )
 
 // indirect returns the item at the end of indirection, and a bool to indicate if it's nil.
@@ -36,6 +39,8 @@ func IsTrue(obj interface{}) bool {
 //
 // NOTE: borrowed from https://github.com/golang/go/tree/master/src/text/template/exec.go
 func isTrueValue(val reflect.Value) (truth, ok bool) {
+	val = adaptValue(val)
+
 	if !val.IsValid() {
 		// Something like var x interface{}, never set. It's a form of nil.
 		return false, true
@@ -56,13 +61,48 @@ func isTrueValue(val reflect.Value) (truth, ok bool) {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		truth = val.Uint() != 0
 	case reflect.Struct:
-		truth = true // Struct values are always true.
+		// Struct values are always true, except the zero time
+		truth = (val.Type() != timeType) || !val.CanInterface() || !val.Interface().(time.Time).IsZero()
 	default:
 		return
 	}
 	return truth, true
 }
 
+// intValue returns the integer value of given number or numeric string, and a boolean set to false if it is not an integer
+func intValue(value interface{}) (int, bool) {
+	val, _ := indirect(reflect.ValueOf(value))
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := val.Int(); int64(int(i)) == i {
+			return int(i), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := val.Uint(); u <= math.MaxInt {
+			return int(u), true
+		}
+	case reflect.Float32, reflect.Float64:
+		return floatIntValue(val.Float())
+	case reflect.String:
+		if i, err := strconv.Atoi(val.String()); err == nil {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// floatIntValue returns the integer value of given float, and a boolean set to false if it is not an integer, or if it overflows an int, like NaN and infinities
+func floatIntValue(f float64) (int, bool) {
+	// float64(math.MaxInt) may be rounded up to a power of two, that overflows
+	if (f != math.Trunc(f)) || (f < math.MinInt) || (f >= math.MaxInt) {
+		return 0, false
+	}
+
+	return int(f), true
+}
+
 // canBeNil reports whether an untyped nil can be assigned to the type. See reflect.Zero.
 //
 // NOTE: borrowed from https://github.com/golang/go/tree/master/src/text/template/exec.go
@@ -0,0 +1,45 @@
+package raymond
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+//
+// Built-in adaptation of context values, so that database query results are rendered without wrapper code
+//
+
+// timeType is the type of time.Time values
+var timeType = reflect.TypeOf(time.Time{})
+
+// isSQLNullType returns true if given type is a database/sql null type, eg. sql.NullString or sql.Null[T]
+func isSQLNullType(typ reflect.Type) bool {
+	return (typ.Kind() == reflect.Struct) && (typ.PkgPath() == "database/sql") && strings.HasPrefix(typ.Name(), "Null")
+}
+
+// adaptValue returns the value to evaluate instead of given value
+//
+// Pointers are dereferenced, and nil pointers are undefined. Null types of database/sql, eg. sql.NullString, are replaced by their value, or are undefined if they are not valid.
+func adaptValue(val reflect.Value) reflect.Value {
+	val, isNil := indirect(val)
+	if isNil {
+		return zero
+	}
+
+	if val.IsValid() && isSQLNullType(val.Type()) {
+		if valid := val.FieldByName("Valid"); (valid.Kind() != reflect.Bool) || !valid.Bool() {
+			return zero
+		}
+
+		// the value is the first field, eg. String for sql.NullString and V for sql.Null[T]
+		return adaptValue(val.Field(0))
+	}
+
+	return val
+}
+
+// formatTime returns the string representation of given time, in RFC 3339 format
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
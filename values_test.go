@@ -0,0 +1,72 @@
+package raymond
+
+import (
+	"database/sql"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// userRow is a database query result
+type userRow struct {
+	ID        int64
+	Name      *string
+	Nickname  *string
+	Email     sql.NullString
+	Phone     sql.NullString
+	Age       sql.NullInt32
+	Score     sql.Null[float64]
+	Admin     sql.NullBool
+	CreatedAt time.Time
+	DeletedAt sql.NullTime
+	LastLogin *time.Time
+	Balance   *big.Float
+	Ratio     big.Rat
+}
+
+func TestDatabaseValues(t *testing.T) {
+	skipTiny(t)
+	t.Parallel()
+
+	name := "Jean"
+	created := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+
+	row := userRow{
+		ID:        42,
+		Name:      &name,
+		Email:     sql.NullString{String: "jean@example.com", Valid: true},
+		Phone:     sql.NullString{String: "ignored", Valid: false},
+		Age:       sql.NullInt32{Int32: 0, Valid: true},
+		Score:     sql.Null[float64]{V: 9.5, Valid: true},
+		CreatedAt: created,
+		Balance:   big.NewFloat(12.5),
+	}
+	row.Ratio.SetFrac64(1, 3)
+
+	for _, test := range []struct {
+		source   string
+		expected string
+	}{
+		{"{{name}} [{{nickname}}] {{#if nickname}}nick{{else}}no nick{{/if}}", "Jean [] no nick"},
+		{"{{email}} [{{phone}}] {{#if phone}}phone{{else}}no phone{{/if}}", "jean@example.com [] no phone"},
+		{"{{age}} {{#if age}}aged{{else}}unknown age{{/if}} {{score}} [{{admin}}]", "0 unknown age 9.5 []"},
+		{"{{createdAt}} [{{deletedAt}}] [{{lastLogin}}] {{#if deletedAt}}deleted{{/if}}", "2024-01-02T03:04:05.6+01:00 [] [] "},
+		{"{{balance}} {{ratio}}", "12.5 1/3"},
+		{"{{#with name}}{{.}}{{/with}}{{#with nickname}}{{.}}{{else}}-{{/with}}", "Jean-"},
+	} {
+		if output := MustRender(test.source, row); output != test.expected {
+			t.Errorf("Unexpected output for %q: %q, expected %q", test.source, output, test.expected)
+		}
+	}
+
+	// a nil field is resolved, so it is not looked up in parent contexts
+	ctx := map[string]interface{}{"nickname": "parent", "users": []userRow{row}}
+
+	if output := MustRender("{{#each users}}[{{nickname}}]{{/each}}", ctx); output != "[]" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	if IsTrue(time.Time{}) || !IsTrue(created) || IsTrue(sql.NullBool{Bool: true}) {
+		t.Errorf("Unexpected truthiness of time or null values")
+	}
+}
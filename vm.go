@@ -0,0 +1,223 @@
+package raymond
+
+import (
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// compiled returns the bytecode of given program, or nil if it must be interpreted
+func (v *evalVisitor) compiled(program *ast.Program) *bytecode {
+	return v.code.get(program)
+}
+
+// run executes given bytecode and returns string result
+func (v *evalVisitor) run(code *bytecode) string {
+	if code.static {
+		// pre-rendered
+		return code.text
+	}
+
+	var buf strings.Builder
+	buf.Grow(code.size)
+
+	v.exec(code, &buf)
+
+	return buf.String()
+}
+
+// programStr evaluates given program and returns string result
+//
+// Contrary to program.Accept(), the result of a compiled program is not boxed in an interface.
+func (v *evalVisitor) programStr(program *ast.Program) string {
+	v.at(program)
+	v.cover(program)
+
+	if code := v.compiled(program); code != nil {
+		return v.run(code)
+	}
+
+	result, _ := program.Accept(v).(string)
+	return result
+}
+
+// programTo evaluates given program and writes result to given writer
+func (v *evalVisitor) programTo(w writer, program *ast.Program) {
+	v.at(program)
+	v.cover(program)
+
+	if code := v.compiled(program); code != nil {
+		v.exec(code, w)
+		return
+	}
+
+	result, _ := program.Accept(v).(string)
+	v.write(w, result)
+}
+
+// exec executes given bytecode and writes result to given writer
+func (v *evalVisitor) exec(code *bytecode, w writer) {
+	var jobs []*partialJob
+	if (v.partialSem != nil) && (len(code.partials) > 1) {
+		jobs = v.startPartials(code)
+		defer waitPartials(jobs)
+	}
+
+	for _, in := range code.code {
+		switch arg := in.operand(); in.op() {
+		case opContent:
+			v.write(w, code.consts[arg])
+		case opPath:
+			v.writeMustachePath(w, &code.paths[arg])
+		case opMustache:
+			v.writeMustache(w, code.mustaches[arg])
+		case opBlock:
+			v.writeBlock(w, code.blocks[arg])
+		case opBranch:
+			v.writeBranch(w, &code.branches[arg])
+		case opNonce:
+			v.write(w, v.nonceAttr)
+		case opPartial:
+			if (jobs != nil) && (jobs[arg] != nil) {
+				v.writePartialJob(w, jobs[arg])
+			} else {
+				v.writePartial(w, code.partials[arg])
+			}
+		default:
+			v.errorf("Unknown opcode: %d", in.op())
+		}
+	}
+}
+
+// write writes given string to given writer, and panics on error
+func (v *evalVisitor) write(w writer, str string) {
+	str = v.validUTF8(str)
+
+	v.account(len(str))
+
+	if _, err := w.WriteString(str); err != nil {
+		v.errPanic(err)
+	}
+}
+
+// writeValue writes string representation of the value of given mustache statement to given writer, escaping it unless the statement is unescaped or this is a safe string
+func (v *evalVisitor) writeValue(w writer, node *ast.MustacheStatement, val interface{}) {
+	if v.tpl.contextualEscaping && !node.Unescaped {
+		if esc := v.code.escaper(node); esc != htmlEscaper {
+			v.write(w, esc.escape(val, v.tpl.escaping))
+			return
+		}
+	}
+
+	if node.Unescaped {
+		v.checkUnescaped(node)
+	}
+
+	str := v.str(val)
+
+	if node.Unescaped || isSafeString(val) {
+		v.write(w, str)
+		return
+	}
+
+	str = v.validUTF8(str)
+
+	v.account(len(str))
+
+	if err := escape(w, str, v.tpl.escaping); err != nil {
+		v.errPanic(err)
+	}
+}
+
+// writeMustachePath evaluates a mustache statement that only contains a path, and writes result to given writer
+//
+// This is a shortcut for VisitMustache() that avoids visiting the whole expression.
+func (v *evalVisitor) writeMustachePath(w writer, op *pathOperand) {
+	if (op.helper != "") && (v.findHelper(op.helper) != zero) {
+		v.writeMustache(w, op.stmt)
+		return
+	}
+
+	expr := op.stmt.Expression
+
+	v.at(expr)
+	v.profileStart(ProfileMustache, op.path.Original, op.stmt.Line)
+
+	v.pushExpr(expr)
+	val := v.evalPathExpression(op.path, true)
+	v.popExpr()
+
+	v.writeValue(w, op.stmt, val)
+
+	v.profileEnd()
+}
+
+// partialJob is a partial statement rendered concurrently
+type partialJob struct {
+	// closed when rendering is done
+	done chan struct{}
+
+	buf *Buffer
+
+	// recovered evaluation panic, with the node and partial being evaluated
+	err     interface{}
+	node    ast.Node
+	partial string
+}
+
+// startPartials starts rendering the partial statements of given bytecode concurrently, and returns jobs indexed like partial statements
+//
+// Partials are started at program start, as evaluation stacks are the same for all statements of a program. A partial job is nil when the concurrency limit is reached: that partial is rendered sequentially.
+func (v *evalVisitor) startPartials(code *bytecode) []*partialJob {
+	jobs := make([]*partialJob, len(code.partials))
+
+	for i, node := range code.partials {
+		select {
+		case v.partialSem <- struct{}{}:
+		default:
+			// limit reached
+			continue
+		}
+
+		job := &partialJob{done: make(chan struct{}), buf: getBuffer()}
+		jobs[i] = job
+
+		go func(fork *evalVisitor, node *ast.PartialStatement, sem chan struct{}) {
+			defer func() {
+				if job.err = recover(); job.err != nil {
+					job.node, job.partial = fork.curNode, fork.curPartial
+				}
+				fork.release()
+				<-sem
+				close(job.done)
+			}()
+
+			fork.writePartial(job.buf, node)
+		}(v.fork(), node, v.partialSem)
+	}
+
+	return jobs
+}
+
+// writePartialJob waits for given partial job, and writes its result to given writer
+func (v *evalVisitor) writePartialJob(w writer, job *partialJob) {
+	<-job.done
+
+	if job.err != nil {
+		// propagates evaluation error
+		v.curNode, v.curPartial = job.node, job.partial
+		panic(job.err)
+	}
+
+	v.write(w, job.buf.String())
+}
+
+// waitPartials waits for all given partial jobs and releases their buffers
+func waitPartials(jobs []*partialJob) {
+	for _, job := range jobs {
+		if job != nil {
+			<-job.done
+			job.buf.Release()
+		}
+	}
+}
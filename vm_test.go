@@ -0,0 +1,121 @@
+package raymond
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// barrier blocks callers until given number of callers are waiting, or until timeout
+type barrier struct {
+	mutex   sync.Mutex
+	waiting int
+	count   int
+	ready   chan struct{}
+}
+
+func newBarrier(count int) *barrier {
+	return &barrier{count: count, ready: make(chan struct{})}
+}
+
+// wait returns true if all callers reached the barrier before timeout
+func (b *barrier) wait() bool {
+	b.mutex.Lock()
+	b.waiting++
+	if b.waiting == b.count {
+		close(b.ready)
+	}
+	b.mutex.Unlock()
+
+	select {
+	case <-b.ready:
+		return true
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+func TestConcurrentPartials(t *testing.T) {
+	t.Parallel()
+
+	b := newBarrier(3)
+
+	tpl := MustParse(`<{{> item name="a"}}|{{> item name="b"}}|{{#if ok}}{{> item name="c"}}{{/if}}{{> item name="d"}}>`)
+	tpl.RegisterPartial("item", `{{name}}{{fetch}}`)
+	tpl.RegisterHelper("fetch", func(options *Options) string {
+		if options.ValueStr("name") == "c" {
+			// not rendered concurrently: not at the same level as the other partials
+			return ""
+		}
+
+		if !b.wait() {
+			return "(sequential)"
+		}
+		return "!"
+	})
+
+	output, err := tpl.ExecWithOptions(map[string]bool{"ok": true}, ExecOptions{PartialConcurrency: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "<a!|b!|cd!>" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestConcurrentPartialsLimit(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{> item}}{{> item}}{{> item}}{{/each}}`)
+	tpl.RegisterPartial("item", `{{this}}`)
+
+	ctx := map[string][]int{"items": {1, 2, 3}}
+
+	for _, concurrency := range []int{1, 2, 10} {
+		output, err := tpl.ExecWithOptions(ctx, ExecOptions{PartialConcurrency: concurrency})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if output != "111222333" {
+			t.Errorf("Unexpected output with concurrency %d: %q", concurrency, output)
+		}
+	}
+}
+
+func TestConcurrentPartialsError(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{> ok}}{{> ko}}{{> ok}}`)
+	tpl.RegisterPartial("ok", `ok`)
+	tpl.RegisterPartial("ko", `{{#if}}{{/if}}`)
+
+	_, err := tpl.ExecWithOptions(nil, ExecOptions{PartialConcurrency: 3})
+	if (err == nil) || !strings.Contains(err.Error(), "Evaluation error") {
+		t.Errorf("Expected evaluation error, got: %v", err)
+	}
+}
+
+func TestConcurrentPartialsData(t *testing.T) {
+	t.Parallel()
+
+	tpl := MustParse(`{{#each items}}{{> item}}{{> item}}{{/each}}`)
+	tpl.RegisterPartial("item", `{{setData this}}{{@value}};`)
+	tpl.RegisterHelper("setData", func(val int, options *Options) string {
+		options.DataFrame().Set("value", val)
+		return ""
+	})
+
+	ctx := map[string][]int{"items": {1, 2, 3}}
+
+	output, err := tpl.ExecWithOptions(ctx, ExecOptions{PartialConcurrency: 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if output != "1;1;2;2;3;3;" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
@@ -0,0 +1,24 @@
+// Package wasm exposes raymond to JavaScript when compiled to WebAssembly, so that browsers and Node.js render templates with this exact engine, and get the same output as Go servers.
+//
+// The package is only implemented for the js/wasm target. The cmd/hbs-wasm command exports the API as the `raymond` global:
+//
+//	$ GOOS=js GOARCH=wasm go build -o raymond.wasm github.com/aymerick/raymond/cmd/hbs-wasm
+//
+// Then, in JavaScript, once the module is running with the wasm_exec.js support file of the Go distribution:
+//
+//	raymond.registerHelper("upper", (str, options) => str.toUpperCase());
+//	raymond.registerPartial("user", "<b>{{upper name}}</b>");
+//
+//	const tpl = raymond.compile("Hello {{> user}}", { jsNumbers: true });
+//	tpl.render({ name: "jean" }); // "Hello <b>JEAN</b>"
+//
+// The exported object has the following functions:
+//
+//   - compile(source, options) parses a template and returns an object with render(context, data) and release() functions, or throws a parse error. A released template can not be rendered anymore. Options are the `jsNumbers`, `contextualEscaping` and `mustache` parse options.
+//   - render(source, context, data) parses and renders a template.
+//   - registerHelper(name, fn) and unregisterHelper(name) manage helpers, that are called like handlebars.js helpers: with the parameters and an options object, that has the `name` and `hash` properties and the `fn(context)` and `inverse(context)` functions of blocks.
+//   - registerPartial(name, source) and unregisterPartial(name) manage partials.
+//   - SafeString(str) marks a helper result as safe, so that it is not escaped. Objects with a toHTML() function, eg. handlebars.js SafeString instances, are safe too.
+//
+// Helpers and partials are looked up when templates are rendered, so they may be registered after compilation. Contexts and data are converted from JavaScript values to maps, slices, float64 numbers, strings and booleans. Rendering errors, including the errors thrown by helpers, are thrown as Error instances.
+package wasm
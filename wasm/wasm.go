@@ -0,0 +1,428 @@
+//go:build js && wasm
+
+package wasm
+
+import (
+	"fmt"
+	"reflect"
+	"syscall/js"
+
+	"github.com/aymerick/raymond"
+)
+
+// helpers are the helpers registered from JavaScript, by name
+//
+// JavaScript is single threaded, so registries are not protected.
+var helpers = make(map[string]js.Value)
+
+// partials are the partials registered from JavaScript, by name
+var partials = make(map[string]*raymond.Template)
+
+// throwing wraps a Go function, so that the Error returned by that function is thrown
+var throwing = js.Global().Get("Function").New("fn", `return function() {
+	const result = fn.apply(this, arguments);
+	if (result instanceof Error) {
+		throw result;
+	}
+	return result;
+};`)
+
+// safeString is the SafeString constructor exported to JavaScript, compatible with the handlebars.js one
+var safeString = js.Global().Get("Function").New(`function SafeString(str) {
+	if (!(this instanceof SafeString)) {
+		return new SafeString(str);
+	}
+	this.string = str;
+}
+SafeString.prototype.toString = SafeString.prototype.toHTML = function() {
+	return "" + this.string;
+};
+return SafeString;`).Invoke()
+
+// released replaces the render function of released templates
+var released = js.Global().Get("Function").New(`throw new Error("template is released");`)
+
+// Export sets the JavaScript global with given name to an object with the compile, render, registerHelper, unregisterHelper, registerPartial, unregisterPartial and SafeString functions.
+//
+// Exported functions are never released, so Export is meant to be called once, by the main function of the WebAssembly module.
+func Export(name string) {
+	obj := js.Global().Get("Object").New()
+
+	obj.Set("compile", export(compile))
+	obj.Set("render", export(render))
+	obj.Set("registerHelper", export(registerHelper))
+	obj.Set("unregisterHelper", export(unregisterHelper))
+	obj.Set("registerPartial", export(registerPartial))
+	obj.Set("unregisterPartial", export(unregisterPartial))
+	obj.Set("SafeString", safeString)
+
+	js.Global().Set(name, obj)
+}
+
+// export returns a JavaScript function that calls given function, and throws its error
+func export(f func(args []js.Value) (interface{}, error)) js.Value {
+	return throwing.Invoke(newFunc(f))
+}
+
+// newFunc returns a Go function callable from JavaScript, that returns an Error instead of the error of given function, or instead of a panic
+func newFunc(f func(args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) (result interface{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = jsError(fmt.Errorf("%v", r))
+			}
+		}()
+
+		value, err := f(args)
+		if err != nil {
+			return jsError(err)
+		}
+
+		return value
+	})
+}
+
+// jsError returns a JavaScript Error with the message of given error
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
+
+// arg returns the argument at given position, or undefined if missing
+func arg(args []js.Value, pos int) js.Value {
+	if pos < len(args) {
+		return args[pos]
+	}
+
+	return js.Undefined()
+}
+
+// stringArg returns the string argument at given position, or an error if it is not a string
+func stringArg(args []js.Value, pos int, name string) (string, error) {
+	value := arg(args, pos)
+	if value.Type() != js.TypeString {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+
+	return value.String(), nil
+}
+
+// compile implements compile(source, options)
+func compile(args []js.Value) (interface{}, error) {
+	source, err := stringArg(args, 0, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	var opts raymond.ParseOptions
+
+	if options := arg(args, 1); options.Type() == js.TypeObject {
+		opts.JSNumbers = options.Get("jsNumbers").Truthy()
+		opts.ContextualEscaping = options.Get("contextualEscaping").Truthy()
+		opts.Mustache = options.Get("mustache").Truthy()
+	}
+
+	tpl, err := raymond.ParseWithOptions(source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := js.Global().Get("Object").New()
+	obj.Set("source", source)
+
+	renderFunc := newFunc(func(args []js.Value) (interface{}, error) {
+		return execute(tpl, arg(args, 0), arg(args, 1))
+	})
+
+	var releaseFunc js.Func
+	releaseFunc = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		obj.Set("render", released)
+		obj.Set("release", js.Global().Get("Function").New())
+
+		renderFunc.Release()
+		releaseFunc.Release()
+
+		return nil
+	})
+
+	obj.Set("render", throwing.Invoke(renderFunc))
+	obj.Set("release", releaseFunc)
+
+	return obj, nil
+}
+
+// render implements render(source, context, data)
+func render(args []js.Value) (interface{}, error) {
+	source, err := stringArg(args, 0, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := raymond.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return execute(tpl, arg(args, 1), arg(args, 2))
+}
+
+// registerHelper implements registerHelper(name, fn)
+func registerHelper(args []js.Value) (interface{}, error) {
+	name, err := stringArg(args, 0, "helper name")
+	if err != nil {
+		return nil, err
+	}
+
+	fn := arg(args, 1)
+	if fn.Type() != js.TypeFunction {
+		return nil, fmt.Errorf("helper %s must be a function", name)
+	}
+
+	helpers[name] = fn
+
+	return nil, nil
+}
+
+// unregisterHelper implements unregisterHelper(name)
+func unregisterHelper(args []js.Value) (interface{}, error) {
+	name, err := stringArg(args, 0, "helper name")
+	if err != nil {
+		return nil, err
+	}
+
+	delete(helpers, name)
+
+	return nil, nil
+}
+
+// registerPartial implements registerPartial(name, source)
+func registerPartial(args []js.Value) (interface{}, error) {
+	name, err := stringArg(args, 0, "partial name")
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := stringArg(args, 1, "partial source")
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := raymond.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	partials[name] = tpl
+
+	return nil, nil
+}
+
+// unregisterPartial implements unregisterPartial(name)
+func unregisterPartial(args []js.Value) (interface{}, error) {
+	name, err := stringArg(args, 0, "partial name")
+	if err != nil {
+		return nil, err
+	}
+
+	delete(partials, name)
+
+	return nil, nil
+}
+
+// execute renders given template with given JavaScript context and data, and with the registered helpers and partials
+func execute(tpl *raymond.Template, ctx js.Value, data js.Value) (string, error) {
+	// helpers and partials are registered on a clone, so that they may change between renders
+	tpl = tpl.Clone()
+
+	for name := range helpers {
+		// calls are intercepted by middleware, so that helpers accept any arguments
+		tpl.RegisterHelper(name, func(options *raymond.Options) interface{} { return nil })
+	}
+
+	tpl.UseHelperMiddleware(helperMiddleware)
+
+	for name, partial := range partials {
+		tpl.RegisterPartialTemplate(name, partial)
+	}
+
+	frame := raymond.NewDataFrame()
+
+	if data.Type() == js.TypeObject {
+		values, _ := fromJS(data).(map[string]interface{})
+		for name, value := range values {
+			frame.Set(name, value)
+		}
+	}
+
+	return tpl.ExecWithOptions(fromJS(ctx), raymond.ExecOptions{Data: frame})
+}
+
+// helperMiddleware calls the helpers registered from JavaScript
+func helperMiddleware(next raymond.HelperFunc) raymond.HelperFunc {
+	return func(call *raymond.HelperCall) (interface{}, error) {
+		fn, ok := helpers[call.Name]
+		if !ok {
+			return next(call)
+		}
+
+		return callHelper(fn, call.Name, call.Options)
+	}
+}
+
+// callHelper calls given JavaScript helper like handlebars.js does: with the context as `this`, and with the parameters followed by an options object
+func callHelper(fn js.Value, name string, options *raymond.Options) (interface{}, error) {
+	// an evaluation error of a block can't be propagated through JavaScript, so it is returned once helper returns
+	var blockErr error
+
+	block := func(eval func(args []js.Value) string) js.Func {
+		return js.FuncOf(func(this js.Value, args []js.Value) (result interface{}) {
+			defer func() {
+				if r := recover(); r != nil {
+					if blockErr == nil {
+						blockErr = fmt.Errorf("%v", r)
+						if err, ok := r.(error); ok {
+							blockErr = err
+						}
+					}
+
+					result = ""
+				}
+			}()
+
+			return eval(args)
+		})
+	}
+
+	fnFunc := block(func(args []js.Value) string {
+		if len(args) == 0 {
+			return options.Fn()
+		}
+
+		return options.FnWith(fromJS(args[0]))
+	})
+	defer fnFunc.Release()
+
+	inverseFunc := block(func(args []js.Value) string {
+		return options.Inverse()
+	})
+	defer inverseFunc.Release()
+
+	opts := js.Global().Get("Object").New()
+	opts.Set("name", name)
+	opts.Set("hash", toJS(options.Hash()))
+	opts.Set("fn", fnFunc)
+	opts.Set("inverse", inverseFunc)
+
+	// like handlebars.js, an undefined context is an empty object, so that `this` is not the global object
+	this := toJS(options.Ctx())
+	if this == nil {
+		this = js.Global().Get("Object").New()
+	}
+
+	args := []interface{}{this}
+	for _, param := range options.Params() {
+		args = append(args, toJS(param))
+	}
+	args = append(args, opts)
+
+	result := fn.Call("call", args...)
+	if blockErr != nil {
+		return nil, blockErr
+	}
+
+	return fromJS(result), nil
+}
+
+// fromJS converts given JavaScript value to a context value
+//
+// Arrays are converted to slices, objects to maps, dates to their ISO string like JSON.stringify() does, and objects with a toHTML() function to safe strings. Functions, symbols and circular references are undefined.
+func fromJS(value js.Value) interface{} {
+	return fromJSValue(value, nil)
+}
+
+// fromJSValue converts given JavaScript value, contained by given ancestors, to a context value
+func fromJSValue(value js.Value, ancestors []js.Value) interface{} {
+	switch value.Type() {
+	case js.TypeBoolean:
+		return value.Bool()
+	case js.TypeNumber:
+		return value.Float()
+	case js.TypeString:
+		return value.String()
+	case js.TypeObject:
+		if value.Get("toHTML").Type() == js.TypeFunction {
+			return raymond.SafeString(value.Call("toHTML").String())
+		}
+
+		if value.InstanceOf(js.Global().Get("Date")) {
+			return value.Call("toISOString").String()
+		}
+
+		for _, ancestor := range ancestors {
+			if value.Equal(ancestor) {
+				return nil
+			}
+		}
+
+		ancestors = append(ancestors, value)
+
+		if js.Global().Get("Array").Call("isArray", value).Bool() {
+			result := make([]interface{}, value.Length())
+			for i := range result {
+				result[i] = fromJSValue(value.Index(i), ancestors)
+			}
+
+			return result
+		}
+
+		keys := js.Global().Get("Object").Call("keys", value)
+
+		result := make(map[string]interface{}, keys.Length())
+		for i := 0; i < keys.Length(); i++ {
+			key := keys.Index(i).String()
+			result[key] = fromJSValue(value.Get(key), ancestors)
+		}
+
+		return result
+	default:
+		return nil
+	}
+}
+
+// toJS converts given context value to a value accepted by js.ValueOf()
+//
+// Safe strings are converted to SafeString instances, and other values that are not numbers, strings, booleans, slices or maps are converted to their string representation.
+func toJS(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, js.Value:
+		return v
+	case raymond.SafeString:
+		return safeString.New(string(v))
+	}
+
+	val := reflect.ValueOf(value)
+
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, val.Len())
+		for i := range result {
+			result[i] = toJS(val.Index(i).Interface())
+		}
+
+		return result
+	case reflect.Map:
+		result := make(map[string]interface{}, val.Len())
+
+		iter := val.MapRange()
+		for iter.Next() {
+			result[fmt.Sprint(iter.Key().Interface())] = toJS(iter.Value().Interface())
+		}
+
+		return result
+	case reflect.Ptr:
+		if val.IsNil() {
+			return nil
+		}
+	}
+
+	return raymond.Str(value)
+}
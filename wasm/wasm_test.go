@@ -0,0 +1,121 @@
+//go:build !js
+
+package wasm
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testScript = `
+const r = globalThis.raymond;
+
+r.registerHelper("upper", (str, options) => str.toUpperCase());
+r.registerHelper("bold", function(options) { return new r.SafeString("<b>" + options.fn(this) + "</b>"); });
+r.registerHelper("join", function(items, options) { return items.map((item) => options.fn(item)).join(options.hash.sep); });
+r.registerHelper("boom", () => { throw new Error("kaboom"); });
+r.registerPartial("user", "{{upper name}}");
+
+const results = [];
+const attempt = (f) => { try { results.push(f()); } catch (e) { results.push("error: " + e.message); } };
+
+const tpl = r.compile("Hi {{> user}} {{#bold}}{{name}}{{/bold}} {{#join items sep=', '}}<{{this}}>{{/join}} {{big}} {{@locale}} {{when}}", { jsNumbers: true });
+attempt(() => tpl.render({ name: "<ann>", items: [1, 2], big: 1e21, when: new Date(0) }, { locale: "fr" }));
+
+const cycle = { x: 3 };
+cycle.self = cycle;
+attempt(() => r.render("{{x}}/{{self.x}}", cycle));
+
+attempt(() => r.compile("{{#if}}"));
+attempt(() => r.render("{{#bold}}{{boom}}{{/bold}}"));
+
+tpl.release();
+attempt(() => tpl.render({}));
+
+r.unregisterHelper("upper");
+attempt(() => r.render("{{upper}}", { upper: "field" }));
+
+console.log(JSON.stringify(results));
+process.exit(0);
+`
+
+func TestWasm(t *testing.T) {
+	t.Parallel()
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Skipf("go not found: %s", err)
+	}
+
+	// wasm_exec.js moved from misc/wasm to lib/wasm in Go 1.24
+	support := filepath.Join(strings.TrimSpace(string(goroot)), "lib", "wasm", "wasm_exec.js")
+	if _, err := os.Stat(support); err != nil {
+		support = filepath.Join(strings.TrimSpace(string(goroot)), "misc", "wasm", "wasm_exec.js")
+	}
+
+	dir := t.TempDir()
+	module := filepath.Join(dir, "raymond.wasm")
+
+	build := exec.Command("go", "build", "-o", module, "../cmd/hbs-wasm")
+	build.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build module: %s\n%s", err, out)
+	}
+
+	script := `require(` + jsonString(support) + `);
+const go = new Go();
+WebAssembly.instantiate(require("fs").readFileSync(` + jsonString(module) + `), go.importObject).then(({ instance }) => {
+	go.run(instance);
+` + testScript + `
+});`
+
+	path := filepath.Join(dir, "test.js")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(node, path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run script: %s\n%s", err, out)
+	}
+
+	var results []string
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("Unexpected script output: %s", out)
+	}
+
+	expected := []string{
+		"Hi &lt;ANN&gt; <b>&lt;ann&gt;</b> <1>, <2> 1e+21 fr 1970-01-01T00:00:00.000Z",
+		"3/",
+		"error: Parse error on line 1:\nExpecting OpenEndBlock, got: 'EOF'",
+		"error: Evaluation error: JavaScript error: kaboom\nCurrent node:\n\tExpr{Path:Path{Original:'boom', Pos:11}, Pos:9}",
+		"error: template is released",
+		"field",
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got: %q", len(expected), results)
+	}
+
+	for i, result := range results {
+		if result != expected[i] {
+			t.Errorf("Result %d: expected %q, got %q", i, expected[i], result)
+		}
+	}
+}
+
+// jsonString returns given string as a JavaScript string literal
+func jsonString(str string) string {
+	b, _ := json.Marshal(str)
+	return string(b)
+}
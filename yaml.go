@@ -0,0 +1,46 @@
+package raymond
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aymerick/raymond/internal/yamlconv"
+	"gopkg.in/yaml.v2"
+)
+
+//
+// YAML helpers
+//
+
+// toYaml helper
+func toYamlHelper(value interface{}, options *Options) interface{} {
+	data, err := marshalYaml(value)
+	if err != nil {
+		options.eval.errorf("Failed to convert value to YAML: %s", err)
+	}
+
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// marshalYaml returns YAML representation of given value
+func marshalYaml(value interface{}) (data []byte, err error) {
+	// YAML encoder panics on unsupported types
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return yaml.Marshal(value)
+}
+
+// fromYaml helper
+func fromYamlHelper(str string, options *Options) interface{} {
+	var result interface{}
+
+	if err := yaml.Unmarshal([]byte(str), &result); err != nil {
+		options.eval.errorf("Failed to parse YAML: %s", err)
+	}
+
+	return yamlconv.Value(result)
+}
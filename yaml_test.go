@@ -0,0 +1,79 @@
+package raymond
+
+import "testing"
+
+var yamlTests = []Test{
+	{
+		"toYaml helper",
+		"{{{toYaml this}}}",
+		map[string]interface{}{"name": "app", "ports": []int{80, 443}, "labels": map[string]string{"tier": "web"}},
+		nil, nil, nil,
+		"labels:\n  tier: web\nname: app\nports:\n- 80\n- 443",
+	},
+	{
+		"toYaml helper with struct",
+		"{{{toYaml this}}}",
+		struct {
+			Name  string `yaml:"name"`
+			Debug bool   `yaml:"debug,omitempty"`
+		}{"app", false},
+		nil, nil, nil,
+		"name: app",
+	},
+	{
+		"toYaml helper with HTML escaping",
+		"{{toYaml this}}",
+		map[string]string{"cmd": "a & b"},
+		nil, nil, nil,
+		"cmd: a &amp; b",
+	},
+	{
+		"toYaml helper in indented partial",
+		"spec:\n  {{> values spec}}\nend",
+		map[string]interface{}{"spec": map[string]interface{}{"replicas": 2, "env": map[string]string{"A": "1"}}},
+		nil, nil,
+		map[string]string{"values": "{{{toYaml this}}}\n"},
+		"spec:\n  env:\n    A: \"1\"\n  replicas: 2\nend",
+	},
+	{
+		"fromYaml helper",
+		"{{#with (fromYaml config)}}{{name}}:{{#each ports}} {{this}}{{/each}} {{labels.tier}}{{/with}}",
+		map[string]string{"config": "name: app\nports: [80, 443]\nlabels:\n  tier: web\n"},
+		nil, nil, nil,
+		"app: 80 443 web",
+	},
+	{
+		"fromYaml and toYaml helpers",
+		"{{{toYaml (fromYaml config)}}}",
+		map[string]string{"config": "{b: [1, {c: d}], a: true}"},
+		nil, nil, nil,
+		"a: true\nb:\n- 1\n- c: d",
+	},
+}
+
+var yamlErrors = []Test{
+	{
+		"fromYaml helper with invalid YAML",
+		"{{fromYaml config}}",
+		map[string]string{"config": "a: [1"},
+		nil, nil, nil,
+		"Failed to parse YAML",
+	},
+	{
+		"toYaml helper with invalid value",
+		"{{toYaml this}}",
+		map[string]interface{}{"fn": make(chan int)},
+		nil, nil, nil,
+		"Failed to convert value to YAML",
+	},
+}
+
+func TestYamlHelpers(t *testing.T) {
+	t.Parallel()
+
+	launchTests(t, yamlTests)
+}
+
+func TestYamlHelpersErrors(t *testing.T) {
+	launchErrorTests(t, yamlErrors)
+}